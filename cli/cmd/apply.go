@@ -0,0 +1,78 @@
+//go:build !test
+// +build !test
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cello-proj/cello/cli/internal/api"
+	"github.com/cello-proj/cello/internal/requests"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Applies a spec of projects and targets",
+	Long:  "Creates, updates and, when the spec prunes, deletes projects and targets so they match the spec. Requires admin credentials.",
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := argoCloudOpsUserToken()
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		b, err := os.ReadFile(specFile)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("unable to read spec, error: %w", err))
+		}
+
+		var spec requests.Apply
+		if err := yaml.UnmarshalStrict(b, &spec); err != nil {
+			cobra.CheckErr(fmt.Errorf("unable to parse spec, error: %w", err))
+		}
+
+		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
+
+		// The changes applied before a failure are printed too, so the
+		// tokens of created projects aren't lost.
+		resp, err := apiCl.Apply(context.Background(), spec, dryRun)
+		if err == nil && len(resp.Changes) == 0 {
+			fmt.Println("no changes")
+			return
+		}
+
+		for _, c := range resp.Changes {
+			if c.Target != "" {
+				fmt.Printf("%s target '%s' of project '%s'\n", c.Action, c.Target, c.Project)
+			} else {
+				fmt.Printf("%s project '%s'\n", c.Action, c.Project)
+			}
+
+			if c.Token != "" {
+				fmt.Printf("  token: %s\n  token_id: %s\n", c.Token, c.TokenID)
+			}
+		}
+
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		if resp.DryRun {
+			fmt.Println("dry run, no changes were applied")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVarP(&specFile, "file", "f", "", "Path to the spec file")
+	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show the changes which would be applied")
+
+	applyCmd.MarkFlagRequired("file")
+}
@@ -0,0 +1,46 @@
+//go:build !test
+// +build !test
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cello-proj/cello/cli/internal/api"
+
+	"github.com/spf13/cobra"
+)
+
+// batchCmd represents the batch command.
+var batchCmd = &cobra.Command{
+	Use:   "batch [batch id]",
+	Short: "Gets status of a batch of workflows",
+	Long:  "Gets status of a batch of workflows created by running a manifest on its targets",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		apiCl := api.NewClient(argoCloudOpsServiceAddr(), "")
+
+		batch, err := apiCl.GetBatch(context.Background(), projectName, args[0])
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		// Our current "contract" is to output json.
+		output, err := json.Marshal(batch)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("unable to generate output, error: %w", err))
+		}
+
+		fmt.Println(string(output))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+
+	batchCmd.Flags().StringVarP(&projectName, "project_name", "n", "", "Name of project")
+
+	batchCmd.MarkFlagRequired("project_name")
+}
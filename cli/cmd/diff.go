@@ -25,7 +25,20 @@ var diffCmd = &cobra.Command{
 
 		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
-		resp, err := apiCl.Diff(context.Background(), api.TargetOperationInput{Path: gitPath, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
+		input := api.TargetOperationInput{Path: gitPath, ProjectName: projectName, Ref: gitRef, SHA: gitSHA, TargetName: targetName}
+
+		// Without a target, the manifest is run on its targets as a batch.
+		if targetName == "" {
+			resp, err := apiCl.DiffBatch(context.Background(), input)
+			if err != nil {
+				cobra.CheckErr(err)
+			}
+
+			fmt.Print(resp.BatchID)
+			return
+		}
+
+		resp, err := apiCl.Diff(context.Background(), input)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -41,12 +54,13 @@ func init() {
 	// TODO these should be '-' separated.
 	diffCmd.Flags().StringVarP(&gitPath, "path", "p", "", "Path to manifest within git repository")
 	diffCmd.Flags().StringVarP(&gitSHA, "sha", "s", "", "Commit sha to use when creating workflow through git")
+	diffCmd.Flags().StringVarP(&gitRef, "ref", "r", "", "Branch, tag or commit sha to use when creating workflow through git, resolved to a commit sha by the service")
 	diffCmd.Flags().StringVarP(&projectName, "project_name", "n", "", "Name of project")
 	// TODO inconsistent
-	diffCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target")
+	diffCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target, omit to run the manifest on its targets as a batch")
 
 	diffCmd.MarkFlagRequired("path")
-	diffCmd.MarkFlagRequired("sha")
+	diffCmd.MarkFlagsOneRequired("sha", "ref")
+	diffCmd.MarkFlagsMutuallyExclusive("sha", "ref")
 	diffCmd.MarkFlagRequired("project_name")
-	diffCmd.MarkFlagRequired("target_name")
 }
@@ -25,7 +25,20 @@ var execCmd = &cobra.Command{
 
 		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
-		resp, err := apiCl.Exec(context.Background(), api.TargetOperationInput{Path: gitPath, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
+		input := api.TargetOperationInput{Path: gitPath, ProjectName: projectName, Ref: gitRef, SHA: gitSHA, TargetName: targetName}
+
+		// Without a target, the manifest is run on its targets as a batch.
+		if targetName == "" {
+			resp, err := apiCl.ExecBatch(context.Background(), input)
+			if err != nil {
+				cobra.CheckErr(err)
+			}
+
+			fmt.Print(resp.BatchID)
+			return
+		}
+
+		resp, err := apiCl.Exec(context.Background(), input)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -41,12 +54,13 @@ func init() {
 	// TODO these should be '-' separated.
 	execCmd.Flags().StringVarP(&gitPath, "path", "p", "", "Path to manifest within git repository")
 	execCmd.Flags().StringVarP(&gitSHA, "sha", "s", "", "Commit sha to use when creating workflow through git")
+	execCmd.Flags().StringVarP(&gitRef, "ref", "r", "", "Branch, tag or commit sha to use when creating workflow through git, resolved to a commit sha by the service")
 	execCmd.Flags().StringVarP(&projectName, "project_name", "n", "", "Name of project")
 	// TODO inconsistent
-	execCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target")
+	execCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target, omit to run the manifest on its targets as a batch")
 
 	execCmd.MarkFlagRequired("path")
-	execCmd.MarkFlagRequired("sha")
+	execCmd.MarkFlagsOneRequired("sha", "ref")
+	execCmd.MarkFlagsMutuallyExclusive("sha", "ref")
 	execCmd.MarkFlagRequired("project_name")
-	execCmd.MarkFlagRequired("target_name")
 }
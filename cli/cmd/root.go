@@ -17,12 +17,15 @@ var rootCmd = &cobra.Command{
 var (
 	// Flags
 	argumentsCSV            string
+	dryRun                  bool
 	environmentVariablesCSV string
 	framework               string
 	gitPath                 string
+	gitRef                  string
 	gitSHA                  string
 	parametersCSV           string
 	projectName             string
+	specFile                string
 	streamLogs              bool
 	targetName              string
 	workflowTemplateName    string
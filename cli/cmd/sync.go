@@ -25,7 +25,20 @@ var syncCmd = &cobra.Command{
 
 		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
-		resp, err := apiCl.Sync(context.Background(), api.TargetOperationInput{Path: gitPath, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
+		input := api.TargetOperationInput{Path: gitPath, ProjectName: projectName, Ref: gitRef, SHA: gitSHA, TargetName: targetName}
+
+		// Without a target, the manifest is run on its targets as a batch.
+		if targetName == "" {
+			resp, err := apiCl.SyncBatch(context.Background(), input)
+			if err != nil {
+				cobra.CheckErr(err)
+			}
+
+			fmt.Print(resp.BatchID)
+			return
+		}
+
+		resp, err := apiCl.Sync(context.Background(), input)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -41,12 +54,13 @@ func init() {
 	// TODO these should be '-' separated.
 	syncCmd.Flags().StringVarP(&gitPath, "path", "p", "", "Path to manifest within git repository")
 	syncCmd.Flags().StringVarP(&gitSHA, "sha", "s", "", "Commit sha to use when creating workflow through git")
+	syncCmd.Flags().StringVarP(&gitRef, "ref", "r", "", "Branch, tag or commit sha to use when creating workflow through git, resolved to a commit sha by the service")
 	syncCmd.Flags().StringVarP(&projectName, "project_name", "n", "", "Name of project")
 	// TODO inconsistent
-	syncCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target")
+	syncCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target, omit to run the manifest on its targets as a batch")
 
 	syncCmd.MarkFlagRequired("path")
-	syncCmd.MarkFlagRequired("sha")
+	syncCmd.MarkFlagsOneRequired("sha", "ref")
+	syncCmd.MarkFlagsMutuallyExclusive("sha", "ref")
 	syncCmd.MarkFlagRequired("project_name")
-	syncCmd.MarkFlagRequired("target_name")
 }
@@ -55,8 +55,12 @@ func NewClient(endpoint, authToken string) Client {
 type TargetOperationInput struct {
 	Path        string
 	ProjectName string
-	SHA         string
-	TargetName  string
+	// Ref is a branch, tag or commit sha, used instead of SHA.
+	Ref string
+	SHA string
+	// TargetName is not used by batch operations, which run on the targets of
+	// the manifest.
+	TargetName string
 }
 
 // GetLogs gets the logs of a workflow.
@@ -103,6 +107,8 @@ func (c *Client) streamLogsToWriterAtCursor(ctx context.Context, w io.Writer, wo
 		return fmt.Errorf("unable to create api request: %w", err)
 	}
 
+	req.Header.Add("Authorization", c.authToken)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("unable to make api call: %w", err)
@@ -160,6 +166,72 @@ func (c *Client) GetWorkflows(ctx context.Context, project, target string) (resp
 	return output, nil
 }
 
+// Apply submits a spec of projects and targets. With dryRun, the changes are
+// only planned.
+func (c *Client) Apply(ctx context.Context, input requests.Apply, dryRun bool) (responses.Apply, error) {
+	url := fmt.Sprintf("%s/admin/apply?dry_run=%t", c.endpoint, dryRun)
+
+	if err := input.Validate(); err != nil {
+		return responses.Apply{}, err
+	}
+
+	reqBody, err := json.Marshal(input)
+	if err != nil {
+		return responses.Apply{}, fmt.Errorf("unable to create api request body, error: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return responses.Apply{}, fmt.Errorf("unable to create api request: %w", err)
+	}
+
+	req.Header.Add("Authorization", c.authToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return responses.Apply{}, fmt.Errorf("unable to make api call: %w", err)
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return responses.Apply{}, fmt.Errorf("error reading response body. status code: %d, error: %w", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode >= 300 || resp.StatusCode < 200 {
+		// Failed applies return the changes applied before the failure.
+		var output responses.Apply
+		if err := json.Unmarshal(body, &output); err == nil && output.ErrorMessage != "" {
+			return output, fmt.Errorf("received unexpected status code: %d, error: %s", resp.StatusCode, output.ErrorMessage)
+		}
+		return responses.Apply{}, fmt.Errorf("received unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var output responses.Apply
+	if err := json.Unmarshal(body, &output); err != nil {
+		return responses.Apply{}, fmt.Errorf("unable to parse response: %w", err)
+	}
+
+	return output, nil
+}
+
+// GetBatch gets the status of a batch of a project.
+func (c *Client) GetBatch(ctx context.Context, project, batchID string) (responses.Batch, error) {
+	url := fmt.Sprintf("%s/projects/%s/batches/%s", c.endpoint, project, batchID)
+
+	body, err := c.getRequest(ctx, url)
+	if err != nil {
+		return responses.Batch{}, err
+	}
+
+	var output responses.Batch
+	if err := json.Unmarshal(body, &output); err != nil {
+		return responses.Batch{}, fmt.Errorf("unable to parse response: %w", err)
+	}
+
+	return output, nil
+}
+
 // Diff submits a "diff" for the provided project target.
 func (c *Client) Diff(ctx context.Context, input TargetOperationInput) (responses.Diff, error) {
 	output, err := c.targetOperation(ctx, input, diff)
@@ -170,6 +242,12 @@ func (c *Client) Diff(ctx context.Context, input TargetOperationInput) (response
 	return responses.Diff(output), nil
 }
 
+// DiffBatch submits a "diff" for the targets of the manifest of the provided
+// project.
+func (c *Client) DiffBatch(ctx context.Context, input TargetOperationInput) (responses.CreateBatch, error) {
+	return c.batchOperation(ctx, input, diff)
+}
+
 // Exec submits an "exec" for the provided project target.
 func (c *Client) Exec(ctx context.Context, input TargetOperationInput) (responses.Exec, error) {
 	output, err := c.targetOperation(ctx, input, exec)
@@ -180,6 +258,12 @@ func (c *Client) Exec(ctx context.Context, input TargetOperationInput) (response
 	return responses.Exec(output), nil
 }
 
+// ExecBatch submits an "exec" for the targets of the manifest of the provided
+// project.
+func (c *Client) ExecBatch(ctx context.Context, input TargetOperationInput) (responses.CreateBatch, error) {
+	return c.batchOperation(ctx, input, exec)
+}
+
 // ExecuteWorkflow submits a workflow execution request.
 func (c *Client) ExecuteWorkflow(ctx context.Context, input requests.CreateWorkflow) (responses.ExecuteWorkflow, error) {
 	// TODO this should probably be refactored to be a different operation type
@@ -231,12 +315,20 @@ func (c *Client) Sync(ctx context.Context, input TargetOperationInput) (response
 	return responses.Sync(output), nil
 }
 
+// SyncBatch submits a "sync" for the targets of the manifest of the provided
+// project.
+func (c *Client) SyncBatch(ctx context.Context, input TargetOperationInput) (responses.CreateBatch, error) {
+	return c.batchOperation(ctx, input, sync)
+}
+
 func (c *Client) getRequest(ctx context.Context, url string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create api request: %w", err)
 	}
 
+	req.Header.Add("Authorization", c.authToken)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("unable to make api call: %w", err)
@@ -258,47 +350,75 @@ func (c *Client) getRequest(ctx context.Context, url string) ([]byte, error) {
 func (c *Client) targetOperation(ctx context.Context, input TargetOperationInput, operationType string) (responses.TargetOperation, error) {
 	url := fmt.Sprintf("%s/projects/%s/targets/%s/operations", c.endpoint, input.ProjectName, input.TargetName)
 
+	body, err := c.operationRequest(ctx, url, input, operationType)
+	if err != nil {
+		return responses.TargetOperation{}, err
+	}
+
+	var output responses.TargetOperation
+	if err := json.Unmarshal(body, &output); err != nil {
+		return responses.TargetOperation{}, fmt.Errorf("unable to parse response: %w", err)
+	}
+
+	return output, nil
+}
+
+func (c *Client) batchOperation(ctx context.Context, input TargetOperationInput, operationType string) (responses.CreateBatch, error) {
+	url := fmt.Sprintf("%s/projects/%s/operations", c.endpoint, input.ProjectName)
+
+	body, err := c.operationRequest(ctx, url, input, operationType)
+	if err != nil {
+		return responses.CreateBatch{}, err
+	}
+
+	var output responses.CreateBatch
+	if err := json.Unmarshal(body, &output); err != nil {
+		return responses.CreateBatch{}, fmt.Errorf("unable to parse response: %w", err)
+	}
+
+	return output, nil
+}
+
+// operationRequest posts an operation of the manifest in git, and returns the
+// response body.
+func (c *Client) operationRequest(ctx context.Context, url string, input TargetOperationInput, operationType string) ([]byte, error) {
 	targetReq := requests.TargetOperation{
 		Path: input.Path,
+		Ref:  input.Ref,
 		SHA:  input.SHA,
 		Type: operationType,
 	}
 
 	if err := targetReq.Validate(); err != nil {
-		return responses.TargetOperation{}, err
+		return nil, err
 	}
 
 	reqBody, err := json.Marshal(targetReq)
 	if err != nil {
-		return responses.TargetOperation{}, fmt.Errorf("unable to create api request body, error: %w", err)
+		return nil, fmt.Errorf("unable to create api request body, error: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
 	if err != nil {
-		return responses.TargetOperation{}, fmt.Errorf("unable to create api request: %w", err)
+		return nil, fmt.Errorf("unable to create api request: %w", err)
 	}
 
 	req.Header.Add("Authorization", c.authToken)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return responses.TargetOperation{}, fmt.Errorf("unable to make api call: %w", err)
+		return nil, fmt.Errorf("unable to make api call: %w", err)
 	}
 
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return responses.TargetOperation{}, fmt.Errorf("error reading response body. status code: %d, error: %w", resp.StatusCode, err)
+		return nil, fmt.Errorf("error reading response body. status code: %d, error: %w", resp.StatusCode, err)
 	}
 
 	if resp.StatusCode >= 300 || resp.StatusCode < 200 {
-		return responses.TargetOperation{}, fmt.Errorf("received unexpected status code: %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var output responses.TargetOperation
-	if err := json.Unmarshal(body, &output); err != nil {
-		return responses.TargetOperation{}, fmt.Errorf("unable to parse response: %w", err)
+		return nil, fmt.Errorf("received unexpected status code: %d, body: %s", resp.StatusCode, string(body))
 	}
 
-	return output, nil
+	return body, nil
 }
@@ -97,6 +97,8 @@ func TestGetLogs(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -106,6 +108,7 @@ func TestGetLogs(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -186,6 +189,8 @@ func TestStreamLogs(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -195,6 +200,7 @@ func TestStreamLogs(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -289,6 +295,8 @@ func TestGetWorkflowStatus(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -298,6 +306,7 @@ func TestGetWorkflowStatus(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -384,6 +393,8 @@ func TestGetWorkflows(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -393,6 +404,7 @@ func TestGetWorkflows(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -417,6 +429,120 @@ func TestGetWorkflows(t *testing.T) {
 	}
 }
 
+func TestApply(t *testing.T) {
+	validInput := requests.Apply{
+		Projects: []requests.ApplyProject{
+			{Name: "project1", Repository: "git@github.com:myorg/project1.git"},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		input             requests.Apply
+		dryRun            bool
+		apiRespBody       []byte
+		apiRespStatusCode int
+		mockHTTPClient    *mockHTTPClient // Only used when needed.
+		want              responses.Apply
+		wantAPIReqBody    []byte
+		wantErr           error
+	}{
+		{
+			name:              "good dry run",
+			input:             validInput,
+			dryRun:            true,
+			apiRespBody:       readFile(t, "apply_response_good.json"),
+			apiRespStatusCode: http.StatusOK,
+			want: responses.Apply{
+				Changes: []responses.ApplyChange{{Action: "create", Project: "project1"}},
+				DryRun:  true,
+			},
+			wantAPIReqBody: readFile(t, "apply_request_good.json"),
+		},
+		{
+			name: "invalid input",
+			input: requests.Apply{
+				Projects: []requests.ApplyProject{{Name: "project1", Repository: "invalid-repo"}},
+			},
+			wantErr: fmt.Errorf("project 'project1': repository must be a git uri"),
+		},
+		{
+			name:              "error non-200 response",
+			input:             validInput,
+			apiRespBody:       []byte("boom"),
+			apiRespStatusCode: http.StatusInternalServerError,
+			wantAPIReqBody:    readFile(t, "apply_request_good.json"),
+			wantErr:           fmt.Errorf("received unexpected status code: 500, body: boom"),
+		},
+		{
+			name:              "error applying changes returns applied changes",
+			input:             validInput,
+			apiRespBody:       readFile(t, "apply_response_error.json"),
+			apiRespStatusCode: http.StatusInternalServerError,
+			want: responses.Apply{
+				Changes:      []responses.ApplyChange{{Action: "create", Project: "project1", Token: "vault:abcd:1234", TokenID: "abcd"}},
+				ErrorMessage: "error applying changes, unable to create target 'target1' of project 'project1'",
+			},
+			wantAPIReqBody: readFile(t, "apply_request_good.json"),
+			wantErr:        fmt.Errorf("received unexpected status code: 500, error: error applying changes, unable to create target 'target1' of project 'project1'"),
+		},
+		{
+			name:           "error making http request",
+			input:          validInput,
+			mockHTTPClient: &mockHTTPClient{errDo: fmt.Errorf("boom")},
+			wantErr:        fmt.Errorf("unable to make api call: boom"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/admin/apply" {
+					http.NotFound(w, r)
+				}
+
+				if r.Method != http.MethodPost {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				assert.Equal(t, fmt.Sprint(tt.dryRun), r.URL.Query().Get("dry_run"))
+
+				body, err := io.ReadAll(r.Body)
+				r.Body.Close()
+
+				assert.Nil(t, err, "unable to read request body")
+
+				assert.JSONEq(t, string(tt.wantAPIReqBody), string(body))
+				assert.Equal(t, authToken, r.Header.Get("Authorization"))
+
+				w.WriteHeader(tt.apiRespStatusCode)
+				fmt.Fprint(w, string(tt.apiRespBody))
+			}))
+			defer server.Close()
+
+			client := Client{
+				authToken:  authToken,
+				endpoint:   server.URL,
+				httpClient: &http.Client{},
+			}
+
+			if tt.mockHTTPClient != nil {
+				client.httpClient = tt.mockHTTPClient
+			}
+
+			output, err := client.Apply(context.Background(), tt.input, tt.dryRun)
+
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.want, output)
+		})
+	}
+}
+
 func TestDiff(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -522,10 +648,10 @@ func TestDiff(t *testing.T) {
 			got, err := client.Diff(
 				context.Background(),
 				TargetOperationInput{
-					"./prod/target1.yaml",
-					"project1",
-					"7fa96067f580a20c3908f5b872377181091ffaec",
-					"target1",
+					Path:        "./prod/target1.yaml",
+					ProjectName: "project1",
+					SHA:         "7fa96067f580a20c3908f5b872377181091ffaec",
+					TargetName:  "target1",
 				},
 			)
 
@@ -662,6 +788,7 @@ func TestExecuteWorkflow(t *testing.T) {
 func TestSync(t *testing.T) {
 	tests := []struct {
 		name                  string
+		ref                   string
 		apiRespBody           []byte
 		apiRespStatusCode     int
 		endpoint              string          // Used to create new request error.
@@ -680,6 +807,17 @@ func TestSync(t *testing.T) {
 			},
 			wantAPIReqBody: readFile(t, "sync_request_good.json"),
 		},
+		{
+			name:              "good with ref",
+			ref:               "main",
+			apiRespBody:       readFile(t, "sync_response_ref.json"),
+			apiRespStatusCode: http.StatusOK,
+			want: responses.Sync{
+				WorkflowName: "workflow1",
+				SHA:          "7fa96067f580a20c3908f5b872377181091ffaec",
+			},
+			wantAPIReqBody: readFile(t, "sync_request_ref.json"),
+		},
 		{
 			name:              "error non-200 response",
 			apiRespBody:       []byte("boom"),
@@ -760,15 +898,18 @@ func TestSync(t *testing.T) {
 				client.httpClient = tt.mockHTTPClient
 			}
 
-			got, err := client.Sync(
-				context.Background(),
-				TargetOperationInput{
-					"./prod/target1.yaml",
-					"project1",
-					"7fa96067f580a20c3908f5b872377181091ffaec",
-					"target1",
-				},
-			)
+			input := TargetOperationInput{
+				Path:        "./prod/target1.yaml",
+				ProjectName: "project1",
+				SHA:         "7fa96067f580a20c3908f5b872377181091ffaec",
+				TargetName:  "target1",
+			}
+			if tt.ref != "" {
+				input.Ref = tt.ref
+				input.SHA = ""
+			}
+
+			got, err := client.Sync(context.Background(), input)
 
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
@@ -884,10 +1025,10 @@ func TestExec(t *testing.T) {
 			got, err := client.Exec(
 				context.Background(),
 				TargetOperationInput{
-					"./prod/target1.yaml",
-					"project1",
-					"7fa96067f580a20c3908f5b872377181091ffaec",
-					"target1",
+					Path:        "./prod/target1.yaml",
+					ProjectName: "project1",
+					SHA:         "7fa96067f580a20c3908f5b872377181091ffaec",
+					TargetName:  "target1",
 				},
 			)
 
@@ -937,3 +1078,162 @@ var (
 		WorkflowTemplateName: "cello-single-step-vault-aws",
 	}
 )
+
+func TestSyncBatch(t *testing.T) {
+	tests := []struct {
+		name              string
+		apiRespBody       []byte
+		apiRespStatusCode int
+		want              responses.CreateBatch
+		wantErr           error
+	}{
+		{
+			name:              "good",
+			apiRespBody:       readFile(t, "sync_batch_response_good.json"),
+			apiRespStatusCode: http.StatusOK,
+			want: responses.CreateBatch{
+				BatchID: "0b6c6f4e-5d0c-4c8e-9a43-0d7f7f9e8a1b",
+				SHA:     "7fa96067f580a20c3908f5b872377181091ffaec",
+			},
+		},
+		{
+			name:              "error non-200 response",
+			apiRespBody:       []byte("boom"),
+			apiRespStatusCode: http.StatusInternalServerError,
+			wantErr:           fmt.Errorf("received unexpected status code: 500, body: boom"),
+		},
+		{
+			name:              "error non-json response",
+			apiRespBody:       []byte("boom"),
+			apiRespStatusCode: 200,
+			wantErr:           fmt.Errorf("unable to parse response: invalid character 'b' looking for beginning of value"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/projects/project1/operations" {
+					http.NotFound(w, r)
+					return
+				}
+
+				if r.Method != http.MethodPost {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				body, err := io.ReadAll(r.Body)
+				r.Body.Close()
+
+				assert.Nil(t, err, "unable to read request body")
+
+				assert.JSONEq(t, string(body), string(readFile(t, "sync_request_good.json")))
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
+				w.WriteHeader(tt.apiRespStatusCode)
+				fmt.Fprint(w, string(tt.apiRespBody))
+			}))
+			defer server.Close()
+
+			client := Client{
+				authToken:  authToken,
+				endpoint:   server.URL,
+				httpClient: &http.Client{},
+			}
+
+			got, err := client.SyncBatch(context.Background(), TargetOperationInput{
+				Path:        "./prod/target1.yaml",
+				ProjectName: "project1",
+				SHA:         "7fa96067f580a20c3908f5b872377181091ffaec",
+			})
+
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBatch(t *testing.T) {
+	tests := []struct {
+		name              string
+		apiRespBody       []byte
+		apiRespStatusCode int
+		want              responses.Batch
+		wantErr           error
+	}{
+		{
+			name:              "good",
+			apiRespBody:       readFile(t, "get_batch_good.json"),
+			apiRespStatusCode: http.StatusOK,
+			want: responses.Batch{
+				BatchID:       "0b6c6f4e-5d0c-4c8e-9a43-0d7f7f9e8a1b",
+				CreatedAt:     "2026-01-01T00:00:00Z",
+				Parallelism:   1,
+				Project:       "project1",
+				SHA:           "7fa96067f580a20c3908f5b872377181091ffaec",
+				Status:        "failed",
+				StopOnFailure: true,
+				Type:          "sync",
+				UpdatedAt:     "2026-01-01T00:05:00Z",
+				Workflows: []responses.BatchWorkflow{
+					{Status: "failed", Target: "dev_east", WorkflowName: "project1-dev_east-abcde"},
+					{Status: "skipped", Target: "prod_east", Wave: 1},
+				},
+			},
+		},
+		{
+			name:              "error non-200 response",
+			apiRespBody:       []byte("boom"),
+			apiRespStatusCode: http.StatusNotFound,
+			wantErr:           fmt.Errorf("received unexpected status code: 404, body: boom"),
+		},
+		{
+			name:              "error non-json response",
+			apiRespBody:       []byte("boom"),
+			apiRespStatusCode: 200,
+			wantErr:           fmt.Errorf("unable to parse response: invalid character 'b' looking for beginning of value"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/projects/project1/batches/batch1" {
+					http.NotFound(w, r)
+					return
+				}
+
+				if r.Method != http.MethodGet {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
+				w.WriteHeader(tt.apiRespStatusCode)
+				fmt.Fprint(w, string(tt.apiRespBody))
+			}))
+			defer server.Close()
+
+			client := Client{
+				authToken:  authToken,
+				endpoint:   server.URL,
+				httpClient: &http.Client{},
+			}
+
+			got, err := client.GetBatch(context.Background(), "project1", "batch1")
+
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, got, tt.want)
+			}
+		})
+	}
+}
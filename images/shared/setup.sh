@@ -18,6 +18,7 @@ usage() {
     echo
     echo "CODE_URI env variable must be set with S3 uri for zip archive "
     echo "VAULT_ADDR env variable must have valid vault endpoint"
+    echo "VAULT_AWS_MOUNT and VAULT_PROJECT_PREFIX env variables can be set when Cello uses a custom Vault layout"
     echo
 }
 
@@ -62,8 +63,9 @@ echo "VAULT_ADDR: $VAULT_ADDR"
 #
 # Get credentials from vault
 #
-vault_project_prefix='aws/sts/argo-cloudops'
-target="${vault_project_prefix}-projects-${PROJECT_NAME}-target-${TARGET_NAME}"
+vault_aws_mount="${VAULT_AWS_MOUNT:-aws}"
+vault_project_prefix="${VAULT_PROJECT_PREFIX:-argo-cloudops-projects}"
+target="${vault_aws_mount}/sts/${vault_project_prefix}-${PROJECT_NAME}-target-${TARGET_NAME}"
 
 token_head=`echo $VAULT_TOKEN |cut -b1-8`
 echo "Exchanging token '${token_head}...' via '$VAULT_ADDR' for target '$target'"
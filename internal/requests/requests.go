@@ -3,7 +3,10 @@ package requests
 import (
 	"errors"
 	"fmt"
+	"path"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/cello-proj/cello/internal/types"
 	"github.com/cello-proj/cello/internal/validations"
@@ -16,10 +19,18 @@ type CreateWorkflow struct {
 	EnvironmentVariables map[string]string   `json:"environment_variables" yaml:"environment_variables"`
 	// We don't validate the specific framework as it's dynamic and can only be
 	// done server side.
-	Framework   string            `json:"framework" yaml:"framework" valid:"required~framework is required"`
+	Framework string `json:"framework" yaml:"framework" valid:"required~framework is required"`
+	// Overlays override the manifest for the targets matching them. They're
+	// only used by manifests in git.
+	Overlays    []ManifestOverlay `json:"overlays,omitempty" yaml:"overlays,omitempty"`
 	Parameters  map[string]string `json:"parameters" yaml:"parameters"`
 	ProjectName string            `json:"project_name" yaml:"project_name" valid:"required~project_name is required,alphanum~project_name must be alphanumeric,stringlength(4|32)~project_name must be between 4 and 32 characters"`
-	TargetName  string            `json:"target_name" yaml:"target_name" valid:"required~target_name is required,alphanumunderscore~target_name must be alphanumeric underscore,stringlength(4|32)~target_name must be between 4 and 32 characters"`
+	// Rollout configures how workflows of manifests with targets are run.
+	Rollout    *Rollout `json:"rollout,omitempty" yaml:"rollout,omitempty"`
+	TargetName string   `json:"target_name" yaml:"target_name" valid:"required~target_name is required,alphanumunderscore~target_name must be alphanumeric underscore,stringlength(4|32)~target_name must be between 4 and 32 characters"`
+	// Targets fan the manifest out to several targets of the project, with
+	// one workflow per target. They're only used by manifests in git.
+	Targets []ManifestTarget `json:"targets,omitempty" yaml:"targets,omitempty"`
 	// We don't validate the specific type as it's dynamic and can only be done
 	// server side.
 	Type                 string `json:"type" yaml:"type" valid:"required~type is required"`
@@ -38,6 +49,221 @@ func (req CreateWorkflow) Validate(optionalValidations ...func() error) error {
 	return validations.Validate(v...)
 }
 
+// SetTarget sets the project and target of the workflow to the ones the
+// operation was requested for. Workflows which omit them inherit them, while
+// workflows declaring another project or target are rejected.
+func (req *CreateWorkflow) SetTarget(projectName, targetName string) error {
+	if req.ProjectName != "" && req.ProjectName != projectName {
+		return fmt.Errorf("manifest project_name '%s' does not match project '%s'", req.ProjectName, projectName)
+	}
+
+	if req.TargetName != "" && req.TargetName != targetName {
+		return fmt.Errorf("manifest target_name '%s' does not match target '%s'", req.TargetName, targetName)
+	}
+
+	req.ProjectName = projectName
+	req.TargetName = targetName
+	return nil
+}
+
+// ManifestTarget is a target, or a glob of targets, of a manifest. Its
+// arguments, environment variables and parameters override the ones of the
+// manifest.
+type ManifestTarget struct {
+	Arguments            map[string][]string `json:"arguments,omitempty" yaml:"arguments,omitempty"`
+	EnvironmentVariables map[string]string   `json:"environment_variables,omitempty" yaml:"environment_variables,omitempty"`
+	// Name is a target name or glob (e.g. 'prod_*').
+	Name       string            `json:"name" yaml:"name"`
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	// Wave orders the workflows. Workflows of a wave start once the workflows
+	// of the earlier waves finished.
+	Wave int `json:"wave,omitempty" yaml:"wave,omitempty"`
+}
+
+// Rollout configures how the workflows of manifests with targets are run.
+type Rollout struct {
+	// Parallelism is how many workflows of a wave run at once. All of them
+	// run at once when it's 0.
+	Parallelism int `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+	// StopOnFailure skips the later waves once a workflow failed.
+	StopOnFailure bool `json:"stop_on_failure,omitempty" yaml:"stop_on_failure,omitempty"`
+}
+
+// ValidateTargets validates the targets and rollout of manifests with
+// targets.
+func (req CreateWorkflow) ValidateTargets() error {
+	if len(req.Targets) == 0 {
+		return errors.New("manifest targets are required")
+	}
+
+	if req.TargetName != "" {
+		return errors.New("manifest target_name cannot be set with targets")
+	}
+
+	for _, t := range req.Targets {
+		if _, err := path.Match(t.Name, ""); t.Name == "" || err != nil {
+			return fmt.Errorf("manifest targets contains an invalid target '%s'", t.Name)
+		}
+		if t.Wave < 0 {
+			return fmt.Errorf("manifest targets wave of '%s' cannot be negative", t.Name)
+		}
+	}
+
+	if req.Rollout != nil && req.Rollout.Parallelism < 0 {
+		return errors.New("manifest rollout parallelism cannot be negative")
+	}
+
+	return nil
+}
+
+// MatchTarget returns the first target of the manifest matching the target
+// name. Target names are matched case insensitively as workflow names are
+// lower case.
+func (req CreateWorkflow) MatchTarget(targetName string) (ManifestTarget, bool) {
+	for _, t := range req.Targets {
+		if ok, _ := path.Match(strings.ToLower(t.Name), strings.ToLower(targetName)); ok {
+			return t, true
+		}
+	}
+
+	return ManifestTarget{}, false
+}
+
+// ForTarget returns the workflow of the target of the manifest, with the
+// overrides of the target.
+func (req CreateWorkflow) ForTarget(t ManifestTarget, targetName string) CreateWorkflow {
+	cwr := req.override(t.Arguments, t.EnvironmentVariables, t.Parameters)
+	cwr.TargetName = targetName
+	cwr.Targets = nil
+	cwr.Rollout = nil
+	return cwr
+}
+
+// ManifestOverlay overrides the arguments, environment variables and
+// parameters of a manifest for the targets matching its name.
+type ManifestOverlay struct {
+	Arguments            map[string][]string `json:"arguments,omitempty" yaml:"arguments,omitempty"`
+	EnvironmentVariables map[string]string   `json:"environment_variables,omitempty" yaml:"environment_variables,omitempty"`
+	// Name is a target name or glob (e.g. 'prod_*').
+	Name       string            `json:"name" yaml:"name"`
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// ValidateOverlays validates the overlays of the manifest.
+func (req CreateWorkflow) ValidateOverlays() error {
+	for _, o := range req.Overlays {
+		if _, err := path.Match(o.Name, ""); o.Name == "" || err != nil {
+			return fmt.Errorf("manifest overlays contains an invalid target '%s'", o.Name)
+		}
+	}
+
+	return nil
+}
+
+// ApplyOverlays returns the workflow with the overlays matching the target
+// name applied in order, so later overlays override earlier ones.
+func (req CreateWorkflow) ApplyOverlays(targetName string) CreateWorkflow {
+	cwr := req
+	for _, o := range req.Overlays {
+		if ok, _ := path.Match(strings.ToLower(o.Name), strings.ToLower(targetName)); ok {
+			cwr = cwr.override(o.Arguments, o.EnvironmentVariables, o.Parameters)
+		}
+	}
+	cwr.Overlays = nil
+	return cwr
+}
+
+// manifestVariable matches '${name}' manifest variables, and '$${name}' which
+// escapes them.
+var manifestVariable = regexp.MustCompile(`\$(\$?)\{([^}]*)\}`)
+
+// targetVariablePrefix is the prefix of the variables of target properties.
+const targetVariablePrefix = "target."
+
+// Interpolate returns the workflow with the variables referenced by its
+// arguments, environment variables and parameters replaced by their values.
+// References to other variables are kept, so shell variables can still be
+// used, except for unknown target variables which are likely typos.
+func (req CreateWorkflow) Interpolate(vars map[string]string) (CreateWorkflow, error) {
+	var err error
+	interpolate := func(s string) string {
+		return manifestVariable.ReplaceAllStringFunc(s, func(ref string) string {
+			m := manifestVariable.FindStringSubmatch(ref)
+			escaped, name := m[1] != "", m[2]
+			if escaped {
+				return ref[1:]
+			}
+
+			if v, ok := vars[name]; ok {
+				return v
+			}
+
+			if strings.HasPrefix(name, targetVariablePrefix) && err == nil {
+				err = fmt.Errorf("manifest references unknown variable '%s'", name)
+			}
+			return ref
+		})
+	}
+
+	cwr := req
+	if req.Arguments != nil {
+		cwr.Arguments = make(map[string][]string, len(req.Arguments))
+		for k, args := range req.Arguments {
+			interpolated := make([]string, len(args))
+			for i, arg := range args {
+				interpolated[i] = interpolate(arg)
+			}
+			cwr.Arguments[k] = interpolated
+		}
+	}
+
+	interpolateStrings := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+		interpolated := make(map[string]string, len(m))
+		for k, v := range m {
+			interpolated[k] = interpolate(v)
+		}
+		return interpolated
+	}
+	cwr.EnvironmentVariables = interpolateStrings(req.EnvironmentVariables)
+	cwr.Parameters = interpolateStrings(req.Parameters)
+
+	return cwr, err
+}
+
+// override returns the workflow with the arguments, environment variables and
+// parameters overridden.
+func (req CreateWorkflow) override(arguments map[string][]string, environmentVariables, parameters map[string]string) CreateWorkflow {
+	cwr := req
+
+	cwr.Arguments = map[string][]string{}
+	for k, v := range req.Arguments {
+		cwr.Arguments[k] = v
+	}
+	for k, v := range arguments {
+		cwr.Arguments[k] = v
+	}
+
+	cwr.EnvironmentVariables = mergeStrings(req.EnvironmentVariables, environmentVariables)
+	cwr.Parameters = mergeStrings(req.Parameters, parameters)
+	return cwr
+}
+
+// mergeStrings returns the values of base overridden by the ones of
+// overrides.
+func mergeStrings(base, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
 // ValidateType is an optional validation should be passed as parameter to Validate().
 func (req CreateWorkflow) ValidateType(types []string) func() error {
 	return func() error {
@@ -98,22 +324,71 @@ func (req CreateWorkflow) validateArguments() error {
 	return nil
 }
 
-// CreateGitWorkflow from git manifest request
+// CreateGitWorkflow from git manifest request. Either the commit hash or a
+// ref, which is a branch, tag or commit sha, is required. The type overrides
+// the type of the manifest when it's provided.
 type CreateGitWorkflow struct {
-	CommitHash string `json:"sha" valid:"required~sha is required,alphanum~sha must be alphanumeric"`
+	CommitHash string `json:"sha,omitempty" valid:"alphanum~sha must be alphanumeric"`
 	Path       string `json:"path" valid:"required~path is required"`
+	Ref        string `json:"ref,omitempty"`
+	Type       string `json:"type,omitempty"`
 }
 
 // Validate validates CreateGitWorkflow.
 func (req CreateGitWorkflow) Validate() error {
-	return validations.ValidateStruct(req)
+	v := []func() error{
+		func() error { return validations.ValidateStruct(req) },
+		func() error { return validateGitRef(req.CommitHash, req.Ref) },
+		func() error { return validateManifestPath(req.Path) },
+	}
+
+	return validations.Validate(v...)
+}
+
+// validateGitRef validates that exactly one of the commit hash or ref is
+// provided.
+func validateGitRef(sha, ref string) error {
+	if sha == "" && ref == "" {
+		return errors.New("sha or ref is required")
+	}
+
+	if sha != "" && ref != "" {
+		return errors.New("only one of sha or ref can be provided")
+	}
+
+	if sha == "" && !validations.IsValidGitRef(ref) {
+		return errors.New("ref must be a branch, tag or commit sha")
+	}
+
+	if ref == "" && !validations.IsValidCommitSHA(sha) {
+		return errors.New("sha must be a full or abbreviated commit sha")
+	}
+	return nil
+}
+
+// validateManifestPath validates that the path of the manifest is inside the
+// repository.
+func validateManifestPath(path string) error {
+	if !validations.IsValidRepositoryPath(path) {
+		return errors.New("path must be inside the repository")
+	}
+	return nil
 }
 
 // CreateTarget request.
 type CreateTarget types.Target
 
+// UpdateGitCredentials request.
+type UpdateGitCredentials types.GitCredentials
+
+// Validate validates UpdateGitCredentials.
+func (req UpdateGitCredentials) Validate() error {
+	return types.GitCredentials(req).Validate()
+}
+
 // CreateProject request.
 type CreateProject struct {
+	types.ProjectMetadata
 	Name       string `json:"name" valid:"required~name is required,alphanum~name must be alphanumeric,stringlength(4|32)~name must be between 4 and 32 characters"`
 	Repository string `json:"repository" valid:"required~repository is required"`
 }
@@ -128,16 +403,339 @@ func (req CreateProject) Validate() error {
 			}
 			return nil
 		},
+		req.ProjectMetadata.Validate,
+	}
+
+	return validations.Validate(v...)
+}
+
+// Project list limits.
+const (
+	DefaultListProjectsLimit = 50
+	MaxListProjectsLimit     = 500
+)
+
+// ListProjects request. The fields are provided as query parameters.
+type ListProjects struct {
+	Limit int
+	// Name filters the projects by a case insensitive substring of their name.
+	Name   string `valid:"alphanum~name must be alphanumeric"`
+	Offset int
+}
+
+// Validate validates ListProjects.
+func (req ListProjects) Validate() error {
+	v := []func() error{
+		func() error {
+			if req.Limit < 1 || req.Limit > MaxListProjectsLimit {
+				return fmt.Errorf("limit must be between 1 and %d", MaxListProjectsLimit)
+			}
+			return nil
+		},
+		func() error {
+			if req.Offset < 0 {
+				return errors.New("offset cannot be negative")
+			}
+			return nil
+		},
+		// Project names are alphanumeric, which also keeps the filter free of
+		// pattern characters.
+		func() error { return validations.ValidateStruct(req) },
+	}
+
+	return validations.Validate(v...)
+}
+
+// UpdateProject request. Only the fields which are provided are updated.
+// Labels replace the existing labels.
+type UpdateProject struct {
+	Contact              *string                   `json:"contact"`
+	Description          *string                   `json:"description"`
+	Labels               *map[string]string        `json:"labels"`
+	ManifestRoot         *string                   `json:"manifest_root"`
+	Repository           *string                   `json:"repository"`
+	RequireSignedCommits *types.SignedCommitPolicy `json:"require_signed_commits"`
+	Team                 *string                   `json:"team"`
+}
+
+// Validate validates UpdateProject.
+func (req UpdateProject) Validate() error {
+	v := []func() error{
+		func() error {
+			if req.Repository != nil && !validations.IsValidGitURI(*req.Repository) {
+				return errors.New("repository must be a git uri")
+			}
+			return nil
+		},
+		func() error {
+			metadata, _ := req.Apply(types.ProjectMetadata{}, "")
+			return metadata.Validate()
+		},
+	}
+
+	return validations.Validate(v...)
+}
+
+// Apply returns the metadata and repository updated with the provided fields.
+func (req UpdateProject) Apply(metadata types.ProjectMetadata, repository string) (types.ProjectMetadata, string) {
+	if req.Contact != nil {
+		metadata.Contact = *req.Contact
+	}
+	if req.Description != nil {
+		metadata.Description = *req.Description
+	}
+	if req.Labels != nil {
+		metadata.Labels = *req.Labels
+	}
+	if req.ManifestRoot != nil {
+		metadata.ManifestRoot = *req.ManifestRoot
+	}
+	if req.Repository != nil {
+		repository = *req.Repository
+	}
+	if req.RequireSignedCommits != nil {
+		metadata.RequireSignedCommits = *req.RequireSignedCommits
+	}
+	if req.Team != nil {
+		metadata.Team = *req.Team
+	}
+
+	return metadata, repository
+}
+
+// Apply request. It declares the projects and their targets which should
+// exist.
+type Apply struct {
+	Projects []ApplyProject `json:"projects" yaml:"projects"`
+	// Prune deletes the projects, and the targets of declared projects, which
+	// are not declared.
+	Prune bool `json:"prune" yaml:"prune"`
+}
+
+// ApplyProject declares a project and its targets.
+type ApplyProject struct {
+	types.ProjectMetadata `yaml:",inline"`
+	Name                  string         `json:"name" yaml:"name"`
+	Repository            string         `json:"repository" yaml:"repository"`
+	Targets               []types.Target `json:"targets" yaml:"targets"`
+}
+
+// Validate validates Apply.
+func (req Apply) Validate() error {
+	projects := map[string]bool{}
+	for _, p := range req.Projects {
+		if err := p.Validate(); err != nil {
+			if p.Name == "" {
+				return err
+			}
+			return fmt.Errorf("project '%s': %w", p.Name, err)
+		}
+
+		if projects[p.Name] {
+			return fmt.Errorf("project '%s' is declared more than once", p.Name)
+		}
+		projects[p.Name] = true
+	}
+
+	return nil
+}
+
+// Validate validates ApplyProject.
+func (req ApplyProject) Validate() error {
+	v := []func() error{
+		CreateProject{
+			ProjectMetadata: req.ProjectMetadata,
+			Name:            req.Name,
+			Repository:      req.Repository,
+		}.Validate,
+		func() error {
+			targets := map[string]bool{}
+			for _, t := range req.Targets {
+				if err := t.Validate(); err != nil {
+					return fmt.Errorf("target '%s': %w", t.Name, err)
+				}
+
+				if targets[t.Name] {
+					return fmt.Errorf("target '%s' is declared more than once", t.Name)
+				}
+				targets[t.Name] = true
+			}
+			return nil
+		},
+	}
+
+	return validations.Validate(v...)
+}
+
+// Import request. It's a document returned by the export.
+type Import types.Export
+
+// Validate validates Import.
+func (req Import) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error {
+			if req.Version != types.ExportVersion {
+				return fmt.Errorf("version must be %d", types.ExportVersion)
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateProjects is an optional validation should be passed as parameter to
+// Validate(). Only the projects which are imported are validated.
+func (req Import) ValidateProjects(projects []string) func() error {
+	return func() error {
+		apply, err := req.Apply(projects)
+		if err != nil {
+			return err
+		}
+		return apply.Validate()
+	}
+}
+
+// Apply returns the apply request which recreates the projects and their
+// targets. When projects is not empty, only those projects are included.
+func (req Import) Apply(projects []string) (Apply, error) {
+	exported := map[string]bool{}
+	for _, p := range req.Projects {
+		exported[p.Name] = true
+	}
+
+	include := map[string]bool{}
+	for _, p := range projects {
+		if !exported[p] {
+			return Apply{}, fmt.Errorf("project '%s' is not in the import", p)
+		}
+		include[p] = true
+	}
+
+	apply := Apply{Projects: []ApplyProject{}}
+	for _, p := range req.Projects {
+		if len(include) > 0 && !include[p.Name] {
+			continue
+		}
+
+		apply.Projects = append(apply.Projects, ApplyProject{
+			ProjectMetadata: p.ProjectMetadata,
+			Name:            p.Name,
+			Repository:      p.Repository,
+			Targets:         p.Targets,
+		})
+	}
+
+	return apply, nil
+}
+
+// CreateToken request.
+type CreateToken struct {
+	Scopes types.TokenScopes `json:"scopes"`
+	// TTL is a duration (e.g. '720h'). The credentials provider default is used
+	// when it is not provided.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Validate validates CreateToken.
+func (req CreateToken) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		req.Scopes.Validate,
+		func() error { return validateDuration("ttl", req.TTL) },
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateMaxTTL is an optional validation should be passed as parameter to Validate().
+func (req CreateToken) ValidateMaxTTL(maxTTL time.Duration) func() error {
+	return func() error { return validateMaxDuration("ttl", req.TTL, maxTTL) }
+}
+
+// TTLDuration returns the TTL as a duration. It's 0 when no TTL is provided.
+func (req CreateToken) TTLDuration() time.Duration {
+	return parseDuration(req.TTL)
+}
+
+// RotateToken request.
+type RotateToken struct {
+	// GracePeriod is a duration (e.g. '1h') during which the rotated token can
+	// still be used. The rotated token is revoked immediately when it is not
+	// provided.
+	GracePeriod string `json:"grace_period,omitempty"`
+	// TTL is a duration (e.g. '720h') for the replacement token. The
+	// credentials provider default is used when it is not provided.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Validate validates RotateToken.
+func (req RotateToken) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error { return validateDuration("grace_period", req.GracePeriod) },
+		func() error { return validateDuration("ttl", req.TTL) },
 	}
+	v = append(v, optionalValidations...)
 
 	return validations.Validate(v...)
 }
 
+// ValidateMaxTTL is an optional validation should be passed as parameter to Validate().
+func (req RotateToken) ValidateMaxTTL(maxTTL time.Duration) func() error {
+	return func() error { return validateMaxDuration("ttl", req.TTL, maxTTL) }
+}
+
+// GracePeriodDuration returns the GracePeriod as a duration. It's 0 when no
+// GracePeriod is provided.
+func (req RotateToken) GracePeriodDuration() time.Duration {
+	return parseDuration(req.GracePeriod)
+}
+
+// TTLDuration returns the TTL as a duration. It's 0 when no TTL is provided.
+func (req RotateToken) TTLDuration() time.Duration {
+	return parseDuration(req.TTL)
+}
+
+// validateDuration validates an optional positive duration field.
+func validateDuration(field, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !validations.IsValidDuration(value) {
+		return fmt.Errorf("%s must be a positive duration (e.g. '720h')", field)
+	}
+
+	return nil
+}
+
+// validateMaxDuration validates an optional duration field is not greater than
+// maxDuration.
+func validateMaxDuration(field, value string, maxDuration time.Duration) error {
+	if parseDuration(value) > maxDuration {
+		return fmt.Errorf("%s cannot be greater than %s", field, maxDuration)
+	}
+
+	return nil
+}
+
+// parseDuration returns 0 for empty or invalid durations.
+func parseDuration(value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+
+	return d
+}
+
 // TargetOperation represents a target operation request.
 // TODO evaluate this vs. CreateGitWorkflow.
 type TargetOperation struct {
 	Path string `json:"path" valid:"required~path is required"`
-	SHA  string `json:"sha" valid:"required~sha is required,alphanum~sha must be alphanumeric"`
+	Ref  string `json:"ref,omitempty"`
+	SHA  string `json:"sha,omitempty" valid:"alphanum~sha must be alphanumeric"`
 	// We don't validate the specific type as it's dynamic and can only be done
 	// server side.
 	Type string `json:"type" valid:"required~type is required"`
@@ -145,10 +743,17 @@ type TargetOperation struct {
 
 // Validate validates TargetOperation.
 func (req TargetOperation) Validate() error {
-	return validations.ValidateStruct(req)
+	v := []func() error{
+		func() error { return validations.ValidateStruct(req) },
+		func() error { return validateGitRef(req.SHA, req.Ref) },
+		func() error { return validateManifestPath(req.Path) },
+	}
+
+	return validations.Validate(v...)
 }
 
 // UpdateTarget request.
 type UpdateTarget struct {
+	types.TargetMetadata
 	Properties types.TargetProperties `json:"properties"`
 }
@@ -2,11 +2,15 @@ package requests
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/cello-proj/cello/internal/types"
 	"github.com/cello-proj/cello/internal/validations"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 )
 
 func TestCreateWorkflowValidate(t *testing.T) {
@@ -380,6 +384,232 @@ func TestCreateWorkflowValidate(t *testing.T) {
 	}
 }
 
+func TestCreateWorkflowValidateTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CreateWorkflow
+		wantErr error
+	}{
+		{
+			name: "valid",
+			req: CreateWorkflow{
+				Rollout: &Rollout{Parallelism: 2, StopOnFailure: true},
+				Targets: []ManifestTarget{{Name: "dev_*"}, {Name: "prod_account", Wave: 1}},
+			},
+		},
+		{
+			name:    "no targets",
+			req:     CreateWorkflow{TargetName: "target1"},
+			wantErr: errors.New("manifest targets are required"),
+		},
+		{
+			name:    "target name with targets",
+			req:     CreateWorkflow{TargetName: "target1", Targets: []ManifestTarget{{Name: "dev_*"}}},
+			wantErr: errors.New("manifest target_name cannot be set with targets"),
+		},
+		{
+			name:    "invalid glob",
+			req:     CreateWorkflow{Targets: []ManifestTarget{{Name: "dev_["}}},
+			wantErr: errors.New("manifest targets contains an invalid target 'dev_['"),
+		},
+		{
+			name:    "negative wave",
+			req:     CreateWorkflow{Targets: []ManifestTarget{{Name: "dev_*", Wave: -1}}},
+			wantErr: errors.New("manifest targets wave of 'dev_*' cannot be negative"),
+		},
+		{
+			name:    "negative parallelism",
+			req:     CreateWorkflow{Rollout: &Rollout{Parallelism: -1}, Targets: []ManifestTarget{{Name: "dev_*"}}},
+			wantErr: errors.New("manifest rollout parallelism cannot be negative"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.ValidateTargets()
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestCreateWorkflowForTarget(t *testing.T) {
+	req := CreateWorkflow{
+		Arguments:            map[string][]string{"execute": {"--all"}, "init": {"-upgrade"}},
+		EnvironmentVariables: map[string]string{"STAGE": "dev", "TEAM": "platform"},
+		Parameters:           map[string]string{"execute_container_image_uri": "image:v1"},
+		ProjectName:          "project1",
+		Rollout:              &Rollout{Parallelism: 2},
+		Targets: []ManifestTarget{
+			{Name: "DEV_*"},
+			{
+				Name:                 "prod_*",
+				Arguments:            map[string][]string{"execute": {"--prod"}},
+				EnvironmentVariables: map[string]string{"STAGE": "prod"},
+				Parameters:           map[string]string{"execute_container_image_uri": "image:v2"},
+				Wave:                 1,
+			},
+		},
+		Type: "sync",
+	}
+
+	mt, ok := req.MatchTarget("prod_account")
+	assert.True(t, ok)
+	assert.Equal(t, 1, mt.Wave)
+
+	assert.Equal(t, CreateWorkflow{
+		Arguments:            map[string][]string{"execute": {"--prod"}, "init": {"-upgrade"}},
+		EnvironmentVariables: map[string]string{"STAGE": "prod", "TEAM": "platform"},
+		Parameters:           map[string]string{"execute_container_image_uri": "image:v2"},
+		ProjectName:          "project1",
+		TargetName:           "prod_account",
+		Type:                 "sync",
+	}, req.ForTarget(mt, "prod_account"))
+
+	// The manifest is left unchanged.
+	assert.Equal(t, "dev", req.EnvironmentVariables["STAGE"])
+
+	mt, ok = req.MatchTarget("dev_account")
+	assert.True(t, ok)
+	assert.Equal(t, "DEV_*", mt.Name)
+
+	_, ok = req.MatchTarget("staging_account")
+	assert.False(t, ok)
+}
+
+func TestCreateWorkflowApplyOverlays(t *testing.T) {
+	req := CreateWorkflow{
+		Arguments:            map[string][]string{"execute": {"--all"}},
+		EnvironmentVariables: map[string]string{"STAGE": "dev", "TEAM": "platform"},
+		Overlays: []ManifestOverlay{
+			{Name: "prod_*", EnvironmentVariables: map[string]string{"STAGE": "prod"}},
+			{Name: "PROD_EAST", Arguments: map[string][]string{"execute": {"--east"}}, EnvironmentVariables: map[string]string{"STAGE": "prod-east"}},
+		},
+		Parameters: map[string]string{"execute_container_image_uri": "image:v1"},
+	}
+	assert.Nil(t, req.ValidateOverlays())
+
+	assert.Equal(t, CreateWorkflow{
+		Arguments:            map[string][]string{"execute": {"--east"}},
+		EnvironmentVariables: map[string]string{"STAGE": "prod-east", "TEAM": "platform"},
+		Parameters:           map[string]string{"execute_container_image_uri": "image:v1"},
+	}, req.ApplyOverlays("prod_east"))
+
+	assert.Equal(t, map[string]string{"STAGE": "prod", "TEAM": "platform"}, req.ApplyOverlays("prod_west").EnvironmentVariables)
+	assert.Equal(t, map[string]string{"STAGE": "dev", "TEAM": "platform"}, req.ApplyOverlays("dev_east").EnvironmentVariables)
+
+	invalid := CreateWorkflow{Overlays: []ManifestOverlay{{Name: "prod_["}}}
+	assert.EqualError(t, invalid.ValidateOverlays(), "manifest overlays contains an invalid target 'prod_['")
+}
+
+func TestCreateWorkflowInterpolate(t *testing.T) {
+	vars := map[string]string{
+		"path":          "manifests/app.yaml",
+		"project":       "project1",
+		"sha":           "1234567",
+		"target":        "prod_east",
+		"target.region": "us-east-1",
+	}
+
+	tests := []struct {
+		name    string
+		req     CreateWorkflow
+		want    CreateWorkflow
+		wantErr error
+	}{
+		{
+			name: "interpolates variables",
+			req: CreateWorkflow{
+				Arguments:            map[string][]string{"execute": {"--context", "stage=${target}", "${path}"}},
+				EnvironmentVariables: map[string]string{"AWS_REGION": "${target.region}", "STACK": "${project}-${target}"},
+				Parameters:           map[string]string{"execute_container_image_uri": "image:${sha}"},
+				WorkflowTemplateName: "${project}",
+			},
+			want: CreateWorkflow{
+				Arguments:            map[string][]string{"execute": {"--context", "stage=prod_east", "manifests/app.yaml"}},
+				EnvironmentVariables: map[string]string{"AWS_REGION": "us-east-1", "STACK": "project1-prod_east"},
+				Parameters:           map[string]string{"execute_container_image_uri": "image:1234567"},
+				WorkflowTemplateName: "${project}",
+			},
+		},
+		{
+			name: "keeps escaped and shell variables",
+			req: CreateWorkflow{
+				EnvironmentVariables: map[string]string{"ESCAPED": "$${project}", "SHELL": "${HOME}/$HOME"},
+			},
+			want: CreateWorkflow{
+				EnvironmentVariables: map[string]string{"ESCAPED": "${project}", "SHELL": "${HOME}/$HOME"},
+			},
+		},
+		{
+			name: "unknown target variable",
+			req: CreateWorkflow{
+				Parameters: map[string]string{"execute_container_image_uri": "${target.regoin}"},
+			},
+			wantErr: errors.New("manifest references unknown variable 'target.regoin'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.req.Interpolate(vars)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateWorkflowSetTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CreateWorkflow
+		want    CreateWorkflow
+		wantErr error
+	}{
+		{
+			name: "inherits the project and target",
+			req:  CreateWorkflow{Type: "sync"},
+			want: CreateWorkflow{ProjectName: "project1", TargetName: "target1", Type: "sync"},
+		},
+		{
+			name: "matching project and target",
+			req:  CreateWorkflow{ProjectName: "project1", TargetName: "target1"},
+			want: CreateWorkflow{ProjectName: "project1", TargetName: "target1"},
+		},
+		{
+			name:    "project mismatch",
+			req:     CreateWorkflow{ProjectName: "project2", TargetName: "target1"},
+			wantErr: errors.New("manifest project_name 'project2' does not match project 'project1'"),
+		},
+		{
+			name:    "target mismatch",
+			req:     CreateWorkflow{TargetName: "prod"},
+			wantErr: errors.New("manifest target_name 'prod' does not match target 'target1'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.SetTarget("project1", "target1")
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, tt.req)
+		})
+	}
+}
+
 func TestCreateGitWorkflowValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -395,11 +625,35 @@ func TestCreateGitWorkflowValidate(t *testing.T) {
 			},
 		},
 		{
-			name: "missing commit hash",
+			name: "valid ref",
 			req: CreateGitWorkflow{
 				Path: "./manifest.yaml",
+				Ref:  "release/1.0",
 			},
-			wantErr: errors.New("sha is required"),
+		},
+		{
+			name: "missing commit hash and ref",
+			req: CreateGitWorkflow{
+				Path: "./manifest.yaml",
+			},
+			wantErr: errors.New("sha or ref is required"),
+		},
+		{
+			name: "commit hash and ref",
+			req: CreateGitWorkflow{
+				CommitHash: "8458fd753f9fde51882414564c20df6d4c34a90e",
+				Path:       "./manifest.yaml",
+				Ref:        "main",
+			},
+			wantErr: errors.New("only one of sha or ref can be provided"),
+		},
+		{
+			name: "invalid ref",
+			req: CreateGitWorkflow{
+				Path: "./manifest.yaml",
+				Ref:  "main~1",
+			},
+			wantErr: errors.New("ref must be a branch, tag or commit sha"),
 		},
 		{
 			name: "commit hash must be alphanumeric",
@@ -409,6 +663,14 @@ func TestCreateGitWorkflowValidate(t *testing.T) {
 			},
 			wantErr: errors.New("sha must be alphanumeric"),
 		},
+		{
+			name: "commit hash must be a commit sha",
+			req: CreateGitWorkflow{
+				CommitHash: "main",
+				Path:       "./manifest.yaml",
+			},
+			wantErr: errors.New("sha must be a full or abbreviated commit sha"),
+		},
 		{
 			name: "missing path",
 			req: CreateGitWorkflow{
@@ -416,6 +678,14 @@ func TestCreateGitWorkflowValidate(t *testing.T) {
 			},
 			wantErr: errors.New("path is required"),
 		},
+		{
+			name: "path outside the repository",
+			req: CreateGitWorkflow{
+				CommitHash: "8458fd753f9fde51882414564c20df6d4c34a90e",
+				Path:       "../otherrepo/manifest.yaml",
+			},
+			wantErr: errors.New("path must be inside the repository"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -472,12 +742,30 @@ func TestTargetOperationValidate(t *testing.T) {
 			},
 		},
 		{
-			name: "missing commit hash",
+			name: "valid ref",
+			req: TargetOperation{
+				Path: "./manifest.yaml",
+				Ref:  "v1.0.0",
+				Type: "diff",
+			},
+		},
+		{
+			name: "missing commit hash and ref",
+			req: TargetOperation{
+				Path: "./manifest.yaml",
+				Type: "diff",
+			},
+			wantErr: errors.New("sha or ref is required"),
+		},
+		{
+			name: "commit hash and ref",
 			req: TargetOperation{
 				Path: "./manifest.yaml",
+				Ref:  "main",
+				SHA:  "8458fd753f9fde51882414564c20df6d4c34a90e",
 				Type: "diff",
 			},
-			wantErr: errors.New("sha is required"),
+			wantErr: errors.New("only one of sha or ref can be provided"),
 		},
 		{
 			name: "commit hash must be alphanumeric",
@@ -488,6 +776,15 @@ func TestTargetOperationValidate(t *testing.T) {
 			},
 			wantErr: errors.New("sha must be alphanumeric"),
 		},
+		{
+			name: "commit hash must be a commit sha",
+			req: TargetOperation{
+				SHA:  "main",
+				Path: "./manifest.yaml",
+				Type: "diff",
+			},
+			wantErr: errors.New("sha must be a full or abbreviated commit sha"),
+		},
 		{
 			name: "missing path",
 			req: TargetOperation{
@@ -496,6 +793,15 @@ func TestTargetOperationValidate(t *testing.T) {
 			},
 			wantErr: errors.New("path is required"),
 		},
+		{
+			name: "path outside the repository",
+			req: TargetOperation{
+				SHA:  "8458fd753f9fde51882414564c20df6d4c34a90e",
+				Path: "manifests/../../otherrepo/manifest.yaml",
+				Type: "diff",
+			},
+			wantErr: errors.New("path must be inside the repository"),
+		},
 		{
 			name: "missing type",
 			req: TargetOperation{
@@ -577,6 +883,29 @@ func TestCreateProjectValidate(t *testing.T) {
 			},
 			wantErr: errors.New("repository must be a git uri"),
 		},
+		{
+			name: "valid metadata",
+			req: CreateProject{
+				ProjectMetadata: types.ProjectMetadata{
+					Description: "project description",
+					Labels:      map[string]string{"cost-center": "1234"},
+					Team:        "platform",
+				},
+				Name:       "project1",
+				Repository: "https://github.com/cello-proj/cello.git",
+			},
+		},
+		{
+			name: "invalid metadata",
+			req: CreateProject{
+				ProjectMetadata: types.ProjectMetadata{
+					Labels: map[string]string{"cost-": "1234"},
+				},
+				Name:       "project1",
+				Repository: "https://github.com/cello-proj/cello.git",
+			},
+			wantErr: errors.New("labels contains an invalid label 'cost-'"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -589,3 +918,471 @@ func TestCreateProjectValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestListProjectsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ListProjects
+		wantErr error
+	}{
+		{
+			name: "valid",
+			req:  ListProjects{Limit: 50, Name: "proj", Offset: 100},
+		},
+		{
+			name:    "limit too small",
+			req:     ListProjects{Limit: 0},
+			wantErr: errors.New("limit must be between 1 and 500"),
+		},
+		{
+			name:    "limit too large",
+			req:     ListProjects{Limit: 501},
+			wantErr: errors.New("limit must be between 1 and 500"),
+		},
+		{
+			name:    "negative offset",
+			req:     ListProjects{Limit: 50, Offset: -1},
+			wantErr: errors.New("offset cannot be negative"),
+		},
+		{
+			name:    "name must be alphanumeric",
+			req:     ListProjects{Limit: 50, Name: "proj%"},
+			wantErr: errors.New("name must be alphanumeric"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.req.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.req.Validate())
+			}
+		})
+	}
+}
+
+func TestUpdateProjectValidate(t *testing.T) {
+	repository := "https://github.com/cello-proj/cello.git"
+	invalidRepository := "invalid-repo"
+	team := "platform"
+	longTeam := strings.Repeat("a", 101)
+	manifestRoot := "cello/"
+	invalidManifestRoot := "../cello"
+
+	tests := []struct {
+		name    string
+		req     UpdateProject
+		wantErr error
+	}{
+		{
+			name: "valid empty",
+		},
+		{
+			name: "valid",
+			req: UpdateProject{
+				Labels:       &map[string]string{"cost-center": "1234"},
+				ManifestRoot: &manifestRoot,
+				Repository:   &repository,
+				Team:         &team,
+			},
+		},
+		{
+			name:    "invalid repository",
+			req:     UpdateProject{Repository: &invalidRepository},
+			wantErr: errors.New("repository must be a git uri"),
+		},
+		{
+			name:    "invalid metadata",
+			req:     UpdateProject{Team: &longTeam},
+			wantErr: errors.New("team cannot be longer than 100 characters"),
+		},
+		{
+			name:    "invalid manifest root",
+			req:     UpdateProject{ManifestRoot: &invalidManifestRoot},
+			wantErr: errors.New("manifest_root must be a directory inside the repository"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.req.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.req.Validate())
+			}
+		})
+	}
+}
+
+func TestUpdateProjectApply(t *testing.T) {
+	description := ""
+	labels := map[string]string{"tier": "1"}
+
+	manifestRoot := "cello"
+	requireSigned := types.SignedCommitPolicy{"sync"}
+
+	req := UpdateProject{Description: &description, Labels: &labels, ManifestRoot: &manifestRoot, RequireSignedCommits: &requireSigned}
+	metadata, repository := req.Apply(types.ProjectMetadata{
+		Contact:     "team@example.com",
+		Description: "old description",
+		Labels:      map[string]string{"cost-center": "1234"},
+	}, "https://github.com/cello-proj/cello.git")
+
+	assert.Equal(t, types.ProjectMetadata{Contact: "team@example.com", Labels: labels, ManifestRoot: manifestRoot, RequireSignedCommits: requireSigned}, metadata)
+	assert.Equal(t, "https://github.com/cello-proj/cello.git", repository)
+}
+
+func TestApplyValidate(t *testing.T) {
+	target := types.Target{
+		Name: "target1",
+		Type: "aws_account",
+		Properties: types.TargetProperties{
+			CredentialType: "assumed_role",
+			RoleArn:        "arn:aws:iam::123456789012:role/target1",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		req     Apply
+		wantErr error
+	}{
+		{
+			name: "valid empty",
+		},
+		{
+			name: "valid",
+			req: Apply{
+				Projects: []ApplyProject{
+					{
+						Name:       "project1",
+						Repository: "https://github.com/cello-proj/cello.git",
+						Targets:    []types.Target{target},
+					},
+				},
+				Prune: true,
+			},
+		},
+		{
+			name: "invalid project",
+			req: Apply{
+				Projects: []ApplyProject{
+					{Name: "project1", Repository: "invalid-repo"},
+				},
+			},
+			wantErr: errors.New("project 'project1': repository must be a git uri"),
+		},
+		{
+			name: "project declared more than once",
+			req: Apply{
+				Projects: []ApplyProject{
+					{Name: "project1", Repository: "https://github.com/cello-proj/cello.git"},
+					{Name: "project1", Repository: "https://github.com/cello-proj/cello.git"},
+				},
+			},
+			wantErr: errors.New("project 'project1' is declared more than once"),
+		},
+		{
+			name: "invalid target",
+			req: Apply{
+				Projects: []ApplyProject{
+					{
+						Name:       "project1",
+						Repository: "https://github.com/cello-proj/cello.git",
+						Targets:    []types.Target{{Name: "target1", Type: "aws_account"}},
+					},
+				},
+			},
+			wantErr: errors.New("project 'project1': target 'target1': credential_type is required;role_arn is required"),
+		},
+		{
+			name: "target declared more than once",
+			req: Apply{
+				Projects: []ApplyProject{
+					{
+						Name:       "project1",
+						Repository: "https://github.com/cello-proj/cello.git",
+						Targets:    []types.Target{target, target},
+					},
+				},
+			},
+			wantErr: errors.New("project 'project1': target 'target1' is declared more than once"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.req.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.req.Validate())
+			}
+		})
+	}
+}
+
+func TestApplyUnmarshalYAML(t *testing.T) {
+	spec := `
+prune: true
+projects:
+  - name: project1
+    repository: git@github.com:myorg/project1.git
+    team: platform
+    labels:
+      cost-center: "1234"
+    targets:
+      - name: target1
+        type: aws_account
+        properties:
+          credential_type: assumed_role
+          role_arn: arn:aws:iam::123456789012:role/target1
+          region: us-west-2
+`
+
+	var got Apply
+	assert.Nil(t, yaml.UnmarshalStrict([]byte(spec), &got))
+	assert.Equal(t, Apply{
+		Projects: []ApplyProject{
+			{
+				ProjectMetadata: types.ProjectMetadata{
+					Labels: map[string]string{"cost-center": "1234"},
+					Team:   "platform",
+				},
+				Name:       "project1",
+				Repository: "git@github.com:myorg/project1.git",
+				Targets: []types.Target{
+					{
+						Name: "target1",
+						Type: "aws_account",
+						Properties: types.TargetProperties{
+							CredentialType: "assumed_role",
+							Region:         "us-west-2",
+							RoleArn:        "arn:aws:iam::123456789012:role/target1",
+						},
+					},
+				},
+			},
+		},
+		Prune: true,
+	}, got)
+}
+
+func TestImportValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      Import
+		projects []string
+		wantErr  error
+	}{
+		{
+			name: "valid",
+			req: Import{
+				Projects: []types.ExportProject{
+					{Name: "project1", Repository: "https://github.com/cello-proj/cello.git"},
+				},
+				Version: types.ExportVersion,
+			},
+		},
+		{
+			name:    "unsupported version",
+			req:     Import{Version: 2},
+			wantErr: errors.New("version must be 1"),
+		},
+		{
+			name: "invalid project",
+			req: Import{
+				Projects: []types.ExportProject{
+					{Name: "project1", Repository: "invalid-repo"},
+				},
+				Version: types.ExportVersion,
+			},
+			wantErr: errors.New("project 'project1': repository must be a git uri"),
+		},
+		{
+			name: "invalid project not imported",
+			req: Import{
+				Projects: []types.ExportProject{
+					{Name: "project1", Repository: "invalid-repo"},
+					{Name: "project2", Repository: "https://github.com/cello-proj/cello.git"},
+				},
+				Version: types.ExportVersion,
+			},
+			projects: []string{"project2"},
+		},
+		{
+			name: "project not in import",
+			req: Import{
+				Projects: []types.ExportProject{
+					{Name: "project1", Repository: "https://github.com/cello-proj/cello.git"},
+				},
+				Version: types.ExportVersion,
+			},
+			projects: []string{"project2"},
+			wantErr:  errors.New("project 'project2' is not in the import"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(tt.req.ValidateProjects(tt.projects))
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestImportApply(t *testing.T) {
+	req := Import{
+		Projects: []types.ExportProject{
+			{
+				ProjectMetadata: types.ProjectMetadata{Team: "platform"},
+				Name:            "project1",
+				Repository:      "https://github.com/cello-proj/cello.git",
+				Tokens:          []types.ExportToken{{TokenID: "token1"}},
+			},
+			{Name: "project2", Repository: "https://github.com/cello-proj/cello.git"},
+		},
+		Version: types.ExportVersion,
+	}
+
+	tests := []struct {
+		name     string
+		projects []string
+		want     Apply
+		wantErr  error
+	}{
+		{
+			name: "all projects",
+			want: Apply{
+				Projects: []ApplyProject{
+					{
+						ProjectMetadata: types.ProjectMetadata{Team: "platform"},
+						Name:            "project1",
+						Repository:      "https://github.com/cello-proj/cello.git",
+					},
+					{Name: "project2", Repository: "https://github.com/cello-proj/cello.git"},
+				},
+			},
+		},
+		{
+			name:     "subset of projects",
+			projects: []string{"project2"},
+			want: Apply{
+				Projects: []ApplyProject{
+					{Name: "project2", Repository: "https://github.com/cello-proj/cello.git"},
+				},
+			},
+		},
+		{
+			name:     "project not in import",
+			projects: []string{"project3"},
+			wantErr:  errors.New("project 'project3' is not in the import"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := req.Apply(tt.projects)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateTokenValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CreateToken
+		maxTTL  time.Duration
+		wantErr error
+	}{
+		{
+			name:   "valid empty",
+			maxTTL: time.Hour,
+		},
+		{
+			name:   "valid ttl",
+			req:    CreateToken{TTL: "30m"},
+			maxTTL: time.Hour,
+		},
+		{
+			name:    "invalid ttl",
+			req:     CreateToken{TTL: "3600"},
+			maxTTL:  time.Hour,
+			wantErr: errors.New("ttl must be a positive duration (e.g. '720h')"),
+		},
+		{
+			name:    "ttl greater than max",
+			req:     CreateToken{TTL: "2h"},
+			maxTTL:  time.Hour,
+			wantErr: errors.New("ttl cannot be greater than 1h0m0s"),
+		},
+		{
+			name:    "invalid scopes",
+			req:     CreateToken{Scopes: types.TokenScopes{ReadOnly: true, Operations: []string{"diff"}}},
+			maxTTL:  time.Hour,
+			wantErr: errors.New("scopes operations cannot be set for read_only tokens"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(tt.req.ValidateMaxTTL(tt.maxTTL))
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestRotateTokenValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     RotateToken
+		maxTTL  time.Duration
+		wantErr error
+	}{
+		{
+			name:   "valid empty",
+			maxTTL: time.Hour,
+		},
+		{
+			name:   "valid",
+			req:    RotateToken{GracePeriod: "10m", TTL: "1h"},
+			maxTTL: time.Hour,
+		},
+		{
+			name:    "invalid grace period",
+			req:     RotateToken{GracePeriod: "-10m"},
+			maxTTL:  time.Hour,
+			wantErr: errors.New("grace_period must be a positive duration (e.g. '720h')"),
+		},
+		{
+			name:    "ttl greater than max",
+			req:     RotateToken{TTL: "2h"},
+			maxTTL:  time.Hour,
+			wantErr: errors.New("ttl cannot be greater than 1h0m0s"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(tt.req.ValidateMaxTTL(tt.maxTTL))
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
@@ -1,5 +1,40 @@
 package responses
 
+import (
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/types"
+)
+
+// Batch represents the responses for GetBatch.
+type Batch struct {
+	BatchID       string          `json:"batch_id"`
+	CreatedAt     string          `json:"created_at"`
+	Parallelism   int             `json:"parallelism"`
+	Project       string          `json:"project"`
+	SHA           string          `json:"sha"`
+	Status        string          `json:"status"`
+	StopOnFailure bool            `json:"stop_on_failure"`
+	Type          string          `json:"type"`
+	UpdatedAt     string          `json:"updated_at"`
+	Workflows     []BatchWorkflow `json:"workflows"`
+}
+
+// BatchWorkflow represents the workflow of a target of a batch.
+type BatchWorkflow struct {
+	Error        string `json:"error,omitempty"`
+	Status       string `json:"status"`
+	Target       string `json:"target"`
+	Wave         int    `json:"wave"`
+	WorkflowName string `json:"workflow_name,omitempty"`
+}
+
+// CreateBatch represents the responses for CreateBatch.
+type CreateBatch struct {
+	BatchID string `json:"batch_id"`
+	// SHA is the commit the workflows are created from.
+	SHA string `json:"sha"`
+}
+
 // CreateProject represents the responses for CreateProject.
 type CreateProject struct {
 	Token   string `json:"token"`
@@ -8,10 +43,11 @@ type CreateProject struct {
 
 // CreateToken represents the responses for CreateToken.
 type CreateToken struct {
-	CreatedAt string `json:"created_at"`
-	ExpiresAt string `json:"expires_at"`
-	Token     string `json:"token"`
-	TokenID   string `json:"token_id"`
+	CreatedAt string            `json:"created_at"`
+	ExpiresAt string            `json:"expires_at"`
+	Scopes    types.TokenScopes `json:"scopes"`
+	Token     string            `json:"token"`
+	TokenID   string            `json:"token_id"`
 }
 
 // Diff represents the responses for Diff.
@@ -25,17 +61,86 @@ type ExecuteWorkflow struct {
 	WorkflowName string `json:"workflow_name"`
 }
 
+// GetDBStats represents the responses for GetDBStats.
+type GetDBStats struct {
+	Idle              int    `json:"idle"`
+	InUse             int    `json:"in_use"`
+	MaxIdleClosed     int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64  `json:"max_lifetime_closed"`
+	MaxOpenConns      int    `json:"max_open_connections"`
+	OpenConns         int    `json:"open_connections"`
+	WaitCount         int64  `json:"wait_count"`
+	WaitDuration      string `json:"wait_duration"`
+}
+
+// GitCacheRepository represents a repository in the git cache.
+type GitCacheRepository struct {
+	LastUsed   string `json:"last_used"`
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+	SizeBytes  int64  `json:"size_bytes"`
+}
+
+// ListGitCache represents the responses for ListGitCache.
+type ListGitCache []GitCacheRepository
+
 // GetLogs represents the responses for GetLogs.
 type GetLogs struct {
 	Logs []string `json:"logs"`
 }
 
+// Export represents the responses for Export.
+type Export types.Export
+
+// GetTargetCredentials represents the responses for GetTargetCredentials.
+type GetTargetCredentials types.TargetCredentials
+
+// GetTarget represents the responses for GetTarget.
+type GetTarget struct {
+	types.Target
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// ListTargets represents the responses for ListTargets.
+type ListTargets []GetTarget
+
 // GetProject represents the responses for GetProject.
 type GetProject struct {
+	types.ProjectMetadata
 	Name       string `json:"name"`
 	Repository string `json:"repository"`
 }
 
+// ListProjects represents the responses for ListProjects.
+type ListProjects struct {
+	Limit    int          `json:"limit"`
+	Offset   int          `json:"offset"`
+	Projects []GetProject `json:"projects"`
+	Total    int          `json:"total"`
+}
+
+// GetReconciliation represents the responses for GetReconciliation.
+type GetReconciliation struct {
+	Errors     []string                `json:"errors"`
+	Findings   []ReconciliationFinding `json:"findings"`
+	FinishedAt string                  `json:"finished_at"`
+	Holder     string                  `json:"holder"`
+	Repair     bool                    `json:"repair"`
+	StartedAt  string                  `json:"started_at"`
+}
+
+// ReconciliationFinding represents an expired token, or a project or token
+// which only exists in either the credentials provider or the database.
+type ReconciliationFinding struct {
+	Issue    string `json:"issue"`
+	Project  string `json:"project"`
+	Repaired bool   `json:"repaired"`
+	Target   string `json:"target,omitempty"`
+	TokenID  string `json:"token_id,omitempty"`
+}
+
 // GetWorkflows represents the responses for GetWorkflows.
 type GetWorkflows []string
 
@@ -49,10 +154,19 @@ type GetWorkflowStatus struct {
 
 // ListTokens represents the responses for ListTokens.
 type ListTokens struct {
-	CreatedAt string `json:"created_at"`
-	ExpiresAt string `json:"expires_at"`
-	ProjectID string `json:"project,omitempty"`
-	TokenID   string `json:"token_id"`
+	CreatedAt string            `json:"created_at"`
+	ExpiresAt string            `json:"expires_at"`
+	ProjectID string            `json:"project,omitempty"`
+	Scopes    types.TokenScopes `json:"scopes"`
+	TokenID   string            `json:"token_id"`
+}
+
+// PreviewOperation represents the responses for PreviewOperation.
+type PreviewOperation struct {
+	// Manifest is the manifest resolved for the target.
+	Manifest requests.CreateWorkflow `json:"manifest"`
+	// SHA is the commit the manifest is read from.
+	SHA string `json:"sha"`
 }
 
 // Sync represents the responses for Sync.
@@ -61,4 +175,40 @@ type Sync TargetOperation
 // TargetOperation represents the output to a targetOperation.
 type TargetOperation struct {
 	WorkflowName string `json:"workflow_name"`
+	// SHA is the commit the workflow was created from.
+	SHA string `json:"sha,omitempty"`
+}
+
+// Apply represents the responses for Apply. When a change fails, Changes holds
+// the changes applied before it and ErrorMessage why it failed.
+type Apply struct {
+	Changes      []ApplyChange `json:"changes"`
+	DryRun       bool          `json:"dry_run"`
+	ErrorMessage string        `json:"error_message,omitempty"`
+}
+
+// ApplyChange represents a create, update or delete of a project or target.
+// The token of a created project is only returned when the change is applied.
+type ApplyChange struct {
+	Action  string `json:"action"`
+	Project string `json:"project"`
+	Target  string `json:"target,omitempty"`
+	Token   string `json:"token,omitempty"`
+	TokenID string `json:"token_id,omitempty"`
+}
+
+// VaultMigration represents the responses for MigrateVault.
+type VaultMigration struct {
+	DryRun   bool                    `json:"dry_run"`
+	From     string                  `json:"from"`
+	Projects []VaultMigrationProject `json:"projects"`
+	To       string                  `json:"to"`
+}
+
+// VaultMigrationProject represents a project moved to the new project prefix.
+// The tokens of the project are invalidated when it's moved.
+type VaultMigrationProject struct {
+	Name    string   `json:"name"`
+	Targets []string `json:"targets"`
+	Tokens  int      `json:"tokens"`
 }
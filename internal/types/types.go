@@ -2,22 +2,42 @@ package types
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"strings"
 
 	"github.com/cello-proj/cello/internal/validations"
 )
 
 type Target struct {
-	Name       string           `json:"name" valid:"required~name is required,alphanumunderscore~name must be alphanumeric underscore,stringlength(4|32)~name must be between 4 and 32 characters"`
-	Properties TargetProperties `json:"properties"`
-	Type       string           `json:"type" valid:"required~type is required"`
+	TargetMetadata `yaml:",inline"`
+	Name           string           `json:"name" yaml:"name" valid:"required~name is required,alphanumunderscore~name must be alphanumeric underscore,stringlength(4|32)~name must be between 4 and 32 characters"`
+	Properties     TargetProperties `json:"properties" yaml:"properties"`
+	Type           string           `json:"type" yaml:"type" valid:"required~type is required"`
 }
 
+// STS session duration limits in seconds.
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+const (
+	minSTSTTL = 900
+	maxSTSTTL = 43200
+
+	maxSessionTags = 50
+)
+
 // TargetProperties for target
 type TargetProperties struct {
-	CredentialType string   `json:"credential_type" valid:"required~credential_type is required"`
-	PolicyArns     []string `json:"policy_arns"`
-	PolicyDocument string   `json:"policy_document"`
-	RoleArn        string   `json:"role_arn" valid:"required~role_arn is required"`
+	CredentialType string `json:"credential_type" yaml:"credential_type" valid:"required~credential_type is required"`
+	// DefaultSTSTTL and MaxSTSTTL are in seconds. When not set, the Vault AWS
+	// secrets engine defaults are used.
+	DefaultSTSTTL  int               `json:"default_sts_ttl,omitempty" yaml:"default_sts_ttl,omitempty"`
+	ExternalID     string            `json:"external_id,omitempty" yaml:"external_id,omitempty"`
+	MaxSTSTTL      int               `json:"max_sts_ttl,omitempty" yaml:"max_sts_ttl,omitempty"`
+	PolicyArns     []string          `json:"policy_arns" yaml:"policy_arns"`
+	PolicyDocument string            `json:"policy_document" yaml:"policy_document"`
+	Region         string            `json:"region,omitempty" yaml:"region,omitempty"`
+	RoleArn        string            `json:"role_arn" yaml:"role_arn" valid:"required~role_arn is required"`
+	SessionTags    map[string]string `json:"session_tags,omitempty" yaml:"session_tags,omitempty"`
 }
 
 // Validate validates Target.
@@ -31,11 +51,69 @@ func (target Target) Validate() error {
 			return nil
 		},
 		target.Properties.Validate,
+		target.TargetMetadata.Validate,
+	}
+
+	return validations.Validate(v...)
+}
+
+// TargetMetadata is optional information describing a target.
+type TargetMetadata struct {
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Owner       string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	// RequireSignedCommits are the operation types which can only run
+	// manifests from commits signed by a trusted key, on top of the ones of
+	// the project.
+	RequireSignedCommits SignedCommitPolicy `json:"require_signed_commits,omitempty" yaml:"require_signed_commits,omitempty"`
+	Tier                 string             `json:"tier,omitempty" yaml:"tier,omitempty"`
+}
+
+// Target metadata limits.
+const (
+	maxTargetDescriptionLength = 1024
+	maxTargetOwnerLength       = 200
+)
+
+// TargetTiers are the environment tiers of targets.
+var TargetTiers = []string{"development", "staging", "production"}
+
+// Validate validates TargetMetadata.
+func (metadata TargetMetadata) Validate() error {
+	v := []func() error{
+		func() error {
+			if len(metadata.Description) > maxTargetDescriptionLength {
+				return fmt.Errorf("description cannot be longer than %d characters", maxTargetDescriptionLength)
+			}
+			return nil
+		},
+		func() error {
+			if len(metadata.Owner) > maxTargetOwnerLength {
+				return fmt.Errorf("owner cannot be longer than %d characters", maxTargetOwnerLength)
+			}
+			return nil
+		},
+		func() error {
+			if metadata.Tier != "" && !IsValidTargetTier(metadata.Tier) {
+				return fmt.Errorf("tier must be one of '%s'", strings.Join(TargetTiers, "', '"))
+			}
+			return nil
+		},
+		metadata.RequireSignedCommits.Validate,
 	}
 
 	return validations.Validate(v...)
 }
 
+// IsValidTargetTier returns whether the tier is one of TargetTiers.
+func IsValidTargetTier(tier string) bool {
+	for _, t := range TargetTiers {
+		if t == tier {
+			return true
+		}
+	}
+	return false
+}
+
 // Validate validates TargetProperties.
 func (properties TargetProperties) Validate() error {
 	v := []func() error{
@@ -60,14 +138,171 @@ func (properties TargetProperties) Validate() error {
 			}
 			return nil
 		},
+		properties.validateSTSTTLs,
+		func() error {
+			if properties.ExternalID != "" && !validations.IsValidExternalID(properties.ExternalID) {
+				return errors.New("external_id must be 2 to 1224 characters and only contain alphanumeric characters or '+=,.@:/-_'")
+			}
+			return nil
+		},
+		func() error {
+			if properties.Region != "" && !validations.IsValidAWSRegion(properties.Region) {
+				return errors.New("region must be a valid aws region")
+			}
+			return nil
+		},
+		properties.validateSessionTags,
+	}
+
+	return validations.Validate(v...)
+}
+
+// validateSTSTTLs validates the STS TTLs are within the limits allowed by AWS
+// and that the default does not exceed the max.
+func (properties TargetProperties) validateSTSTTLs() error {
+	if properties.DefaultSTSTTL != 0 && (properties.DefaultSTSTTL < minSTSTTL || properties.DefaultSTSTTL > maxSTSTTL) {
+		return fmt.Errorf("default_sts_ttl must be between %d and %d seconds", minSTSTTL, maxSTSTTL)
+	}
+
+	if properties.MaxSTSTTL != 0 && (properties.MaxSTSTTL < minSTSTTL || properties.MaxSTSTTL > maxSTSTTL) {
+		return fmt.Errorf("max_sts_ttl must be between %d and %d seconds", minSTSTTL, maxSTSTTL)
+	}
+
+	if properties.DefaultSTSTTL != 0 && properties.MaxSTSTTL != 0 && properties.DefaultSTSTTL > properties.MaxSTSTTL {
+		return errors.New("default_sts_ttl cannot be greater than max_sts_ttl")
+	}
+
+	return nil
+}
+
+// validateSessionTags validates the session tags.
+func (properties TargetProperties) validateSessionTags() error {
+	if len(properties.SessionTags) > maxSessionTags {
+		return fmt.Errorf("session_tags cannot be more than %d", maxSessionTags)
+	}
+
+	for k, v := range properties.SessionTags {
+		if !validations.IsValidSessionTag(k, v) {
+			return fmt.Errorf("session_tags contains an invalid tag '%s'", k)
+		}
+	}
+
+	return nil
+}
+
+// ProjectMetadata is optional information describing a project.
+type ProjectMetadata struct {
+	Contact     string            `json:"contact,omitempty" yaml:"contact,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// ManifestRoot is the directory of the repository which the manifests of
+	// operations must be in. Manifests can be anywhere in the repository when
+	// it's empty.
+	ManifestRoot string `json:"manifest_root,omitempty" yaml:"manifest_root,omitempty"`
+	// RequireSignedCommits are the operation types (e.g. 'sync') which can
+	// only run manifests from commits signed by a trusted key.
+	RequireSignedCommits SignedCommitPolicy `json:"require_signed_commits,omitempty" yaml:"require_signed_commits,omitempty"`
+	Team                 string             `json:"team,omitempty" yaml:"team,omitempty"`
+}
+
+// Project metadata limits.
+const (
+	maxProjectContactLength      = 200
+	maxProjectDescriptionLength  = 1024
+	maxProjectLabels             = 50
+	maxProjectManifestRootLength = 255
+	maxProjectTeamLength         = 100
+)
+
+// Validate validates ProjectMetadata.
+func (metadata ProjectMetadata) Validate() error {
+	v := []func() error{
+		func() error {
+			if len(metadata.Contact) > maxProjectContactLength {
+				return fmt.Errorf("contact cannot be longer than %d characters", maxProjectContactLength)
+			}
+			return nil
+		},
+		func() error {
+			if len(metadata.Description) > maxProjectDescriptionLength {
+				return fmt.Errorf("description cannot be longer than %d characters", maxProjectDescriptionLength)
+			}
+			return nil
+		},
+		func() error {
+			if len(metadata.ManifestRoot) > maxProjectManifestRootLength {
+				return fmt.Errorf("manifest_root cannot be longer than %d characters", maxProjectManifestRootLength)
+			}
+			if metadata.ManifestRoot != "" && !validations.IsValidRepositoryPath(metadata.ManifestRoot) {
+				return errors.New("manifest_root must be a directory inside the repository")
+			}
+			return nil
+		},
+		func() error {
+			if len(metadata.Team) > maxProjectTeamLength {
+				return fmt.Errorf("team cannot be longer than %d characters", maxProjectTeamLength)
+			}
+			return nil
+		},
+		metadata.RequireSignedCommits.Validate,
+		metadata.validateLabels,
 	}
 
 	return validations.Validate(v...)
 }
 
-// ProjectToken represents a project token.
+// validateLabels validates the labels.
+func (metadata ProjectMetadata) validateLabels() error {
+	if len(metadata.Labels) > maxProjectLabels {
+		return fmt.Errorf("labels cannot be more than %d", maxProjectLabels)
+	}
+
+	for k, v := range metadata.Labels {
+		if !validations.IsValidLabel(k, v) {
+			return fmt.Errorf("labels contains an invalid label '%s'", k)
+		}
+	}
+
+	return nil
+}
+
+// SignedCommitPolicy are the operation types which can only run manifests
+// from commits signed by a trusted key.
+type SignedCommitPolicy []string
+
+// Validate validates SignedCommitPolicy.
+func (p SignedCommitPolicy) Validate() error {
+	seen := map[string]bool{}
+	for _, o := range p {
+		if o == "" {
+			return errors.New("require_signed_commits cannot contain an empty operation")
+		}
+		if seen[o] {
+			return fmt.Errorf("require_signed_commits contains duplicate operation '%s'", o)
+		}
+		seen[o] = true
+	}
+
+	return nil
+}
+
+// Requires returns whether the policy requires signed commits for the
+// operation type.
+func (p SignedCommitPolicy) Requires(operation string) bool {
+	for _, o := range p {
+		if o == operation {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ProjectToken represents a project token. CreatedAt is only set by the
+// GetProjectToken of credentials providers.
 type ProjectToken struct {
-	ID string `json:"token_id"`
+	CreatedAt string `json:"created_at,omitempty"`
+	ID        string `json:"token_id"`
 }
 
 // IsEmpty returns whether a struct is empty.
@@ -75,6 +310,71 @@ func (p ProjectToken) IsEmpty() bool {
 	return p == (ProjectToken{})
 }
 
+// GitCredentials are the credentials a project's repository is read with,
+// either a deploy key or an HTTPS user and password or token.
+type GitCredentials struct {
+	HTTPSPassword string `json:"https_password,omitempty"`
+	HTTPSUsername string `json:"https_username,omitempty"`
+	SSHPrivateKey string `json:"ssh_private_key,omitempty"`
+}
+
+// IsEmpty returns whether a struct is empty.
+func (c GitCredentials) IsEmpty() bool {
+	return c == (GitCredentials{})
+}
+
+// Validate validates GitCredentials.
+func (c GitCredentials) Validate() error {
+	https := c.HTTPSUsername != "" || c.HTTPSPassword != ""
+	if c.SSHPrivateKey != "" && https {
+		return errors.New("only one of ssh_private_key or https_username and https_password can be provided")
+	}
+
+	if c.SSHPrivateKey == "" && (c.HTTPSUsername == "" || c.HTTPSPassword == "") {
+		return errors.New("ssh_private_key, or https_username and https_password, are required")
+	}
+	return nil
+}
+
+// TargetCredentials are temporary AWS credentials of a target.
+type TargetCredentials struct {
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	Expiration      string `json:"expiration,omitempty" yaml:"expiration,omitempty"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+	SessionToken    string `json:"session_token,omitempty" yaml:"session_token,omitempty"`
+}
+
+// ExportVersion is the version of the Export document. It's incremented when
+// the document changes in a way older versions can't be imported with.
+const ExportVersion = 1
+
+// Export is a document of all projects, their targets and the metadata of
+// their tokens. It never contains secrets.
+type Export struct {
+	Projects []ExportProject `json:"projects"`
+	// SkippedProjects are the projects missing from the database, which can't
+	// be exported as their repository is unknown.
+	SkippedProjects []string `json:"skipped_projects,omitempty"`
+	Version         int      `json:"version"`
+}
+
+// ExportProject is a project in an Export.
+type ExportProject struct {
+	ProjectMetadata
+	Name       string        `json:"name"`
+	Repository string        `json:"repository"`
+	Targets    []Target      `json:"targets"`
+	Tokens     []ExportToken `json:"tokens"`
+}
+
+// ExportToken is the metadata of a project token in an Export.
+type ExportToken struct {
+	CreatedAt string      `json:"created_at"`
+	ExpiresAt string      `json:"expires_at"`
+	Scopes    TokenScopes `json:"scopes"`
+	TokenID   string      `json:"token_id"`
+}
+
 // Token represents a secrets object/type for a project.
 type Token struct {
 	CreatedAt    string       `json:"created_at"`
@@ -82,5 +382,73 @@ type Token struct {
 	ProjectID    string       `json:"project_id"`
 	ProjectToken ProjectToken `json:"project_token"`
 	RoleID       string       `json:"role_id"`
+	Scopes       TokenScopes  `json:"scopes"`
 	Secret       string       `json:"secret"`
 }
+
+// TokenScopes restricts what a project token can be used for. A token without
+// scopes can run any operation on any target in the project.
+type TokenScopes struct {
+	// Operations are the operation types (e.g. 'diff') the token can run.
+	Operations []string `json:"operations,omitempty" yaml:"operations,omitempty"`
+	// ReadOnly tokens can only read workflows.
+	ReadOnly bool `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	// Targets are target name globs (e.g. 'prod_*') the token can be used with.
+	Targets []string `json:"targets,omitempty" yaml:"targets,omitempty"`
+}
+
+// Validate validates TokenScopes.
+func (s TokenScopes) Validate() error {
+	if s.ReadOnly && len(s.Operations) > 0 {
+		return errors.New("scopes operations cannot be set for read_only tokens")
+	}
+
+	for _, o := range s.Operations {
+		if o == "" {
+			return errors.New("scopes operations cannot contain an empty operation")
+		}
+	}
+
+	for _, t := range s.Targets {
+		if _, err := path.Match(t, ""); t == "" || err != nil {
+			return fmt.Errorf("scopes targets contains an invalid glob '%s'", t)
+		}
+	}
+
+	return nil
+}
+
+// AllowsOperation returns whether the scopes allow running the operation type.
+func (s TokenScopes) AllowsOperation(operation string) bool {
+	if s.ReadOnly {
+		return false
+	}
+
+	if len(s.Operations) == 0 {
+		return true
+	}
+
+	for _, o := range s.Operations {
+		if o == operation {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsTarget returns whether the scopes allow using the target. Target
+// names are matched case insensitively as workflow names are lower case.
+func (s TokenScopes) AllowsTarget(target string) bool {
+	if len(s.Targets) == 0 {
+		return true
+	}
+
+	for _, t := range s.Targets {
+		if ok, _ := path.Match(strings.ToLower(t), strings.ToLower(target)); ok {
+			return true
+		}
+	}
+
+	return false
+}
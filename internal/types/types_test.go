@@ -2,6 +2,7 @@ package types
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -72,6 +73,73 @@ func TestTargetPropertiesValidate(t *testing.T) {
 			},
 			wantErr: errors.New("policy_arns contains an invalid arn"),
 		},
+		{
+			name: "valid session settings",
+			properties: TargetProperties{
+				CredentialType: "assumed_role",
+				DefaultSTSTTL:  3600,
+				ExternalID:     "cello-external-id",
+				MaxSTSTTL:      7200,
+				Region:         "us-west-2",
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+				SessionTags:    map[string]string{"cello-project": "project1"},
+			},
+		},
+		{
+			name: "default_sts_ttl too short",
+			properties: TargetProperties{
+				CredentialType: "assumed_role",
+				DefaultSTSTTL:  60,
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+			},
+			wantErr: errors.New("default_sts_ttl must be between 900 and 43200 seconds"),
+		},
+		{
+			name: "max_sts_ttl too long",
+			properties: TargetProperties{
+				CredentialType: "assumed_role",
+				MaxSTSTTL:      86400,
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+			},
+			wantErr: errors.New("max_sts_ttl must be between 900 and 43200 seconds"),
+		},
+		{
+			name: "default_sts_ttl greater than max_sts_ttl",
+			properties: TargetProperties{
+				CredentialType: "assumed_role",
+				DefaultSTSTTL:  7200,
+				MaxSTSTTL:      3600,
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+			},
+			wantErr: errors.New("default_sts_ttl cannot be greater than max_sts_ttl"),
+		},
+		{
+			name: "external_id must be valid",
+			properties: TargetProperties{
+				CredentialType: "assumed_role",
+				ExternalID:     "not valid!",
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+			},
+			wantErr: errors.New("external_id must be 2 to 1224 characters and only contain alphanumeric characters or '+=,.@:/-_'"),
+		},
+		{
+			name: "region must be valid",
+			properties: TargetProperties{
+				CredentialType: "assumed_role",
+				Region:         "west",
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+			},
+			wantErr: errors.New("region must be a valid aws region"),
+		},
+		{
+			name: "session tags must be valid",
+			properties: TargetProperties{
+				CredentialType: "assumed_role",
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+				SessionTags:    map[string]string{"aws:project": "project1"},
+			},
+			wantErr: errors.New("session_tags contains an invalid tag 'aws:project'"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -286,3 +354,276 @@ func TestCTargetValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestProjectMetadataValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata ProjectMetadata
+		wantErr  error
+	}{
+		{
+			name: "valid empty",
+		},
+		{
+			name: "valid full",
+			metadata: ProjectMetadata{
+				Contact:              "team@example.com",
+				Description:          "project description",
+				Labels:               map[string]string{"cost-center": "1234"},
+				ManifestRoot:         "cello/",
+				RequireSignedCommits: SignedCommitPolicy{"sync"},
+				Team:                 "platform",
+			},
+		},
+		{
+			name:     "contact too long",
+			metadata: ProjectMetadata{Contact: strings.Repeat("a", 201)},
+			wantErr:  errors.New("contact cannot be longer than 200 characters"),
+		},
+		{
+			name:     "description too long",
+			metadata: ProjectMetadata{Description: strings.Repeat("a", 1025)},
+			wantErr:  errors.New("description cannot be longer than 1024 characters"),
+		},
+		{
+			name:     "manifest root too long",
+			metadata: ProjectMetadata{ManifestRoot: strings.Repeat("a", 256)},
+			wantErr:  errors.New("manifest_root cannot be longer than 255 characters"),
+		},
+		{
+			name:     "manifest root outside the repository",
+			metadata: ProjectMetadata{ManifestRoot: "../cello"},
+			wantErr:  errors.New("manifest_root must be a directory inside the repository"),
+		},
+		{
+			name:     "team too long",
+			metadata: ProjectMetadata{Team: strings.Repeat("a", 101)},
+			wantErr:  errors.New("team cannot be longer than 100 characters"),
+		},
+		{
+			name:     "invalid label",
+			metadata: ProjectMetadata{Labels: map[string]string{"cost-": "1234"}},
+			wantErr:  errors.New("labels contains an invalid label 'cost-'"),
+		},
+		{
+			name:     "require signed commits with empty operation",
+			metadata: ProjectMetadata{RequireSignedCommits: SignedCommitPolicy{""}},
+			wantErr:  errors.New("require_signed_commits cannot contain an empty operation"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.metadata.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.metadata.Validate())
+			}
+		})
+	}
+}
+
+func TestTargetMetadataValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata TargetMetadata
+		wantErr  error
+	}{
+		{
+			name: "valid empty",
+		},
+		{
+			name: "valid full",
+			metadata: TargetMetadata{
+				Description:          "target description",
+				Owner:                "platform",
+				RequireSignedCommits: SignedCommitPolicy{"sync", "diff"},
+				Tier:                 "production",
+			},
+		},
+		{
+			name:     "description too long",
+			metadata: TargetMetadata{Description: strings.Repeat("a", 1025)},
+			wantErr:  errors.New("description cannot be longer than 1024 characters"),
+		},
+		{
+			name:     "owner too long",
+			metadata: TargetMetadata{Owner: strings.Repeat("a", 201)},
+			wantErr:  errors.New("owner cannot be longer than 200 characters"),
+		},
+		{
+			name:     "invalid tier",
+			metadata: TargetMetadata{Tier: "prod"},
+			wantErr:  errors.New("tier must be one of 'development', 'staging', 'production'"),
+		},
+		{
+			name:     "require signed commits with duplicate operation",
+			metadata: TargetMetadata{RequireSignedCommits: SignedCommitPolicy{"sync", "sync"}},
+			wantErr:  errors.New("require_signed_commits contains duplicate operation 'sync'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.metadata.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.metadata.Validate())
+			}
+		})
+	}
+}
+
+func TestGitCredentialsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials GitCredentials
+		wantErr     error
+	}{
+		{
+			name:        "valid ssh",
+			credentials: GitCredentials{SSHPrivateKey: "key"},
+		},
+		{
+			name:        "valid https",
+			credentials: GitCredentials{HTTPSUsername: "user", HTTPSPassword: "token"},
+		},
+		{
+			name:    "empty",
+			wantErr: errors.New("ssh_private_key, or https_username and https_password, are required"),
+		},
+		{
+			name:        "https without password",
+			credentials: GitCredentials{HTTPSUsername: "user"},
+			wantErr:     errors.New("ssh_private_key, or https_username and https_password, are required"),
+		},
+		{
+			name:        "ssh and https",
+			credentials: GitCredentials{SSHPrivateKey: "key", HTTPSUsername: "user", HTTPSPassword: "token"},
+			wantErr:     errors.New("only one of ssh_private_key or https_username and https_password can be provided"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.credentials.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.credentials.Validate())
+			}
+		})
+	}
+}
+
+func TestTokenScopesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  TokenScopes
+		wantErr error
+	}{
+		{
+			name: "valid empty",
+		},
+		{
+			name: "valid full",
+			scopes: TokenScopes{
+				Operations: []string{"diff", "sync"},
+				Targets:    []string{"prod_*", "dev"},
+			},
+		},
+		{
+			name: "valid read only",
+			scopes: TokenScopes{
+				ReadOnly: true,
+				Targets:  []string{"prod_*"},
+			},
+		},
+		{
+			name: "operations cannot be set for read only",
+			scopes: TokenScopes{
+				Operations: []string{"diff"},
+				ReadOnly:   true,
+			},
+			wantErr: errors.New("scopes operations cannot be set for read_only tokens"),
+		},
+		{
+			name: "operations cannot be empty",
+			scopes: TokenScopes{
+				Operations: []string{""},
+			},
+			wantErr: errors.New("scopes operations cannot contain an empty operation"),
+		},
+		{
+			name: "targets must be valid globs",
+			scopes: TokenScopes{
+				Targets: []string{"prod_["},
+			},
+			wantErr: errors.New("scopes targets contains an invalid glob 'prod_['"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.scopes.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.scopes.Validate())
+			}
+		})
+	}
+}
+
+func TestTokenScopesAllows(t *testing.T) {
+	tests := []struct {
+		name          string
+		scopes        TokenScopes
+		operation     string
+		target        string
+		wantOperation bool
+		wantTarget    bool
+	}{
+		{
+			name:          "unscoped allows everything",
+			operation:     "sync",
+			target:        "prod_account",
+			wantOperation: true,
+			wantTarget:    true,
+		},
+		{
+			name:          "operation and target allowed",
+			scopes:        TokenScopes{Operations: []string{"diff"}, Targets: []string{"prod_*"}},
+			operation:     "diff",
+			target:        "prod_account",
+			wantOperation: true,
+			wantTarget:    true,
+		},
+		{
+			name:      "operation and target not allowed",
+			scopes:    TokenScopes{Operations: []string{"diff"}, Targets: []string{"prod_*"}},
+			operation: "sync",
+			target:    "dev_account",
+		},
+		{
+			name:       "target matches case insensitively",
+			scopes:     TokenScopes{ReadOnly: true, Targets: []string{"PROD_*"}},
+			operation:  "diff",
+			target:     "prod_account",
+			wantTarget: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantOperation, tt.scopes.AllowsOperation(tt.operation))
+			assert.Equal(t, tt.wantTarget, tt.scopes.AllowsTarget(tt.target))
+		})
+	}
+}
+
+func TestSignedCommitPolicyRequires(t *testing.T) {
+	policy := SignedCommitPolicy{"sync"}
+
+	assert.True(t, policy.Requires("sync"))
+	assert.False(t, policy.Requires("diff"))
+	assert.False(t, SignedCommitPolicy(nil).Requires("sync"))
+}
@@ -1,8 +1,11 @@
 package validations
 
 import (
+	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/aws/aws-sdk-go/aws/arn"
@@ -97,3 +100,131 @@ func IsValidGitURI(s string) bool {
 	pattern := `((git|ssh|https)|(git@[\w\.]+))(:(//)?)([\w\.@\:/\-~]+)(\.git)(/)?`
 	return regexp.MustCompile(pattern).MatchString(s)
 }
+
+// IsValidGitRef determines if the provided string is a valid branch, tag or
+// commit sha, e.g. 'main', 'release/1.0' or 'refs/tags/v1.0.0', following the
+// rules of git check-ref-format.
+func IsValidGitRef(s string) bool {
+	if s == "@" || len(s) > 255 || strings.HasPrefix(s, "-") || strings.HasSuffix(s, ".") || strings.HasSuffix(s, ".lock") {
+		return false
+	}
+
+	for _, invalid := range []string{"..", "@{"} {
+		if strings.Contains(s, invalid) {
+			return false
+		}
+	}
+
+	for _, part := range strings.Split(s, "/") {
+		if part == "" || strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+
+	pattern := `^[^\x00-\x20\x7f~^:?*\[\\]+$`
+	return regexp.MustCompile(pattern).MatchString(s)
+}
+
+// IsValidCommitSHA determines if the provided string is a full or abbreviated
+// commit sha, of 4 to 40 hexadecimal characters.
+func IsValidCommitSHA(s string) bool {
+	pattern := `^[0-9a-fA-F]{4,40}$`
+	return regexp.MustCompile(pattern).MatchString(s)
+}
+
+// IsFullCommitSHA determines if the provided string is a full commit sha, of
+// 40 hexadecimal characters.
+func IsFullCommitSHA(s string) bool {
+	return len(s) == 40 && IsValidCommitSHA(s)
+}
+
+// CleanRepositoryPath returns the provided path cleaned and relative to the
+// root of a repository, e.g. 'cello/manifest.yaml' for
+// '/cello/./manifest.yaml'. It returns false when the path is empty or escapes
+// the root, e.g. '../other/manifest.yaml'. The root itself is '.'.
+func CleanRepositoryPath(s string) (string, bool) {
+	if s == "" || strings.ContainsRune(s, 0) {
+		return "", false
+	}
+
+	p := path.Clean(strings.TrimLeft(filepath.ToSlash(s), "/"))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	return p, true
+}
+
+// IsValidRepositoryPath determines if the provided string is a path inside a
+// repository, e.g. 'cello/manifest.yaml'.
+func IsValidRepositoryPath(s string) bool {
+	_, ok := CleanRepositoryPath(s)
+	return ok
+}
+
+// IsInRepositoryRoot determines if the provided path is inside the root
+// directory of a repository, e.g. 'cello/manifest.yaml' for 'cello'. Any path
+// of the repository is inside an empty root.
+func IsInRepositoryRoot(root, s string) bool {
+	p, ok := CleanRepositoryPath(s)
+	if !ok {
+		return false
+	}
+
+	if root == "" {
+		return true
+	}
+
+	root, ok = CleanRepositoryPath(root)
+	if !ok {
+		return false
+	}
+
+	return root == "." || strings.HasPrefix(p, root+"/")
+}
+
+// IsValidDuration determines if the provided string is a positive duration,
+// e.g. '720h' or '1h30m'.
+func IsValidDuration(s string) bool {
+	d, err := time.ParseDuration(s)
+	return err == nil && d > 0
+}
+
+// IsValidAWSRegion determines if the provided string is an AWS region name
+// format, e.g. 'us-west-2' or 'us-gov-east-1'.
+func IsValidAWSRegion(s string) bool {
+	pattern := `^[a-z]{2}(-[a-z]+)+-\d+$`
+	return regexp.MustCompile(pattern).MatchString(s)
+}
+
+// IsValidExternalID determines if the provided string is a valid STS external
+// ID.
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+func IsValidExternalID(s string) bool {
+	if len(s) < 2 || len(s) > 1224 {
+		return false
+	}
+
+	pattern := `^[\w+=,.@:/-]+$`
+	return regexp.MustCompile(pattern).MatchString(s)
+}
+
+// IsValidSessionTag determines if the provided key and value are a valid STS
+// session tag. Keys using the reserved 'aws:' prefix are not allowed.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/id_session-tags.html
+func IsValidSessionTag(key, value string) bool {
+	if strings.HasPrefix(strings.ToLower(key), "aws:") {
+		return false
+	}
+
+	keyPattern := `^[\p{L}\p{Z}\p{N}_.:/=+\-@]{1,128}$`
+	valuePattern := `^[\p{L}\p{Z}\p{N}_.:/=+\-@]{0,256}$`
+	return regexp.MustCompile(keyPattern).MatchString(key) && regexp.MustCompile(valuePattern).MatchString(value)
+}
+
+// IsValidLabel determines if the provided key and value are a valid label.
+// Keys start and end with an alphanumeric character and can contain '-', '_',
+// '.' and '/' in between.
+func IsValidLabel(key, value string) bool {
+	keyPattern := `^[a-zA-Z0-9]([a-zA-Z0-9_./-]{0,61}[a-zA-Z0-9])?$`
+	return regexp.MustCompile(keyPattern).MatchString(key) && len(value) <= 256
+}
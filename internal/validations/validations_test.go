@@ -3,6 +3,7 @@ package validations
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -112,6 +113,203 @@ func TestIsValidGitURI(t *testing.T) {
 	}
 }
 
+func TestIsValidCommitSHA(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "full sha",
+			testString: "8458fd753f9fde51882414564c20df6d4c34a90e",
+			want:       true,
+		},
+		{
+			name:       "abbreviated sha",
+			testString: "8458FD7",
+			want:       true,
+		},
+		{
+			name:       "too short",
+			testString: "845",
+		},
+		{
+			name:       "too long",
+			testString: "8458fd753f9fde51882414564c20df6d4c34a90e0",
+		},
+		{
+			name:       "not hexadecimal",
+			testString: "main",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidCommitSHA(tt.testString))
+			assert.Equal(t, tt.want && len(tt.testString) == 40, IsFullCommitSHA(tt.testString))
+		})
+	}
+}
+
+func TestIsValidGitRef(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "valid branch",
+			testString: "main",
+			want:       true,
+		},
+		{
+			name:       "valid branch with slashes",
+			testString: "feature/my-change_1",
+			want:       true,
+		},
+		{
+			name:       "valid full tag name",
+			testString: "refs/tags/v1.0.0",
+			want:       true,
+		},
+		{
+			name:       "valid sha",
+			testString: "8458fd753f9fde51882414564c20df6d4c34a90e",
+			want:       true,
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:       "starts with dash",
+			testString: "-main",
+		},
+		{
+			name:       "contains double dots",
+			testString: "main..other",
+		},
+		{
+			name:       "contains space",
+			testString: "my branch",
+		},
+		{
+			name:       "contains revision syntax",
+			testString: "main~1",
+		},
+		{
+			name:       "contains reflog syntax",
+			testString: "main@{1}",
+		},
+		{
+			name:       "ends with lock",
+			testString: "main.lock",
+		},
+		{
+			name:       "component starts with dot",
+			testString: "feature/.hidden",
+		},
+		{
+			name:       "empty component",
+			testString: "feature//change",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidGitRef(tt.testString))
+		})
+	}
+}
+
+func TestCleanRepositoryPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       string
+		wantOK     bool
+	}{
+		{
+			name:       "relative path",
+			testString: "cello/manifest.yaml",
+			want:       "cello/manifest.yaml",
+			wantOK:     true,
+		},
+		{
+			name:       "absolute path",
+			testString: "//cello/./manifest.yaml",
+			want:       "cello/manifest.yaml",
+			wantOK:     true,
+		},
+		{
+			name:       "parent directories inside the repository",
+			testString: "cello/other/../manifest.yaml",
+			want:       "cello/manifest.yaml",
+			wantOK:     true,
+		},
+		{
+			name:       "root",
+			testString: "/",
+			want:       ".",
+			wantOK:     true,
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:       "escapes the root",
+			testString: "../otherrepo/manifest.yaml",
+		},
+		{
+			name:       "escapes the root from a directory",
+			testString: "cello/../../otherrepo/manifest.yaml",
+		},
+		{
+			name:       "escapes the root from an absolute path",
+			testString: "/../otherrepo/manifest.yaml",
+		},
+		{
+			name:       "parent directory",
+			testString: "..",
+		},
+		{
+			name:       "contains null",
+			testString: "manifest.yaml\x00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CleanRepositoryPath(tt.testString)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, IsValidRepositoryPath(tt.testString))
+		})
+	}
+}
+
+func TestIsInRepositoryRoot(t *testing.T) {
+	tests := []struct {
+		name string
+		root string
+		path string
+		want bool
+	}{
+		{name: "empty root", path: "cello/manifest.yaml", want: true},
+		{name: "repository root", root: "/", path: "cello/manifest.yaml", want: true},
+		{name: "inside the root", root: "cello/", path: "/cello/nested/manifest.yaml", want: true},
+		{name: "sibling with the root as prefix", root: "cello", path: "cello-other/manifest.yaml"},
+		{name: "parent directory of the root", root: "cello", path: "cello/../manifest.yaml"},
+		{name: "the root itself", root: "cello", path: "cello"},
+		{name: "outside the repository", path: "../cello/manifest.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsInRepositoryRoot(tt.root, tt.path))
+		})
+	}
+}
+
 func TestIsValidImageURI(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -215,3 +413,190 @@ func TestIsValidARN(t *testing.T) {
 		})
 	}
 }
+
+func TestIsValidDuration(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "valid duration",
+			testString: "720h",
+			want:       true,
+		},
+		{
+			name:       "valid compound duration",
+			testString: "1h30m",
+			want:       true,
+		},
+		{
+			name:       "zero duration",
+			testString: "0s",
+		},
+		{
+			name:       "negative duration",
+			testString: "-1h",
+		},
+		{
+			name:       "missing unit",
+			testString: "3600",
+		},
+		{
+			name: "empty duration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidDuration(tt.testString))
+		})
+	}
+}
+
+func TestIsValidAWSRegion(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "valid region",
+			testString: "us-west-2",
+			want:       true,
+		},
+		{
+			name:       "valid gov region",
+			testString: "us-gov-east-1",
+			want:       true,
+		},
+		{
+			name:       "invalid region",
+			testString: "US West 2",
+		},
+		{
+			name: "empty region",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidAWSRegion(tt.testString))
+		})
+	}
+}
+
+func TestIsValidExternalID(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "valid external id",
+			testString: "cello-12345=abc,def.ghi@jkl:mno/pqr",
+			want:       true,
+		},
+		{
+			name:       "too short",
+			testString: "a",
+		},
+		{
+			name:       "invalid characters",
+			testString: "external id!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidExternalID(tt.testString))
+		})
+	}
+}
+
+func TestIsValidSessionTag(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  bool
+	}{
+		{
+			name:  "valid tag",
+			key:   "cello-project",
+			value: "project1",
+			want:  true,
+		},
+		{
+			name: "valid tag empty value",
+			key:  "team",
+			want: true,
+		},
+		{
+			name:  "empty key",
+			value: "project1",
+		},
+		{
+			name:  "reserved prefix",
+			key:   "aws:project",
+			value: "project1",
+		},
+		{
+			name:  "invalid characters",
+			key:   "project!",
+			value: "project1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidSessionTag(tt.key, tt.value))
+		})
+	}
+}
+
+func TestIsValidLabel(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  bool
+	}{
+		{
+			name:  "valid label",
+			key:   "cost-center",
+			value: "1234",
+			want:  true,
+		},
+		{
+			name: "valid label with prefix and empty value",
+			key:  "example.com/tier",
+			want: true,
+		},
+		{
+			name:  "empty key",
+			value: "1234",
+		},
+		{
+			name:  "key ends with dash",
+			key:   "cost-",
+			value: "1234",
+		},
+		{
+			name:  "key too long",
+			key:   strings.Repeat("a", 64),
+			value: "1234",
+		},
+		{
+			name:  "value too long",
+			key:   "cost-center",
+			value: strings.Repeat("a", 257),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidLabel(tt.key, tt.value))
+		})
+	}
+}
@@ -6,6 +6,15 @@ export ARGO_ADDR='http://127.0.0.1:9000'
 
 MODE=${1}
 
+# With CELLO_DB_DRIVER=sqlite, entries are stored in a local file instead of
+# Postgres and the schema is migrated on startup.
+if [ "$CELLO_DB_DRIVER" == "sqlite" ]; then
+    if [ -z "$CELLO_DB_NAME" ]; then
+        export CELLO_DB_NAME=cello.db
+    fi
+    export CELLO_DB_MIGRATE=true
+fi
+
 if [ -z "$CELLO_DB_HOST" ]; then
     export CELLO_DB_HOST=localhost
 fi
@@ -118,6 +127,15 @@ path "aws/roles/argo-cloudops-projects-*" {
 path "aws/roles/*" {
   capabilities = [ "read", "list" ]
 }
+
+# Manage git credentials of projects
+path "secret/data/argo-cloudops-projects/*" {
+  capabilities = [ "create", "read", "update", "delete" ]
+}
+
+path "secret/metadata/argo-cloudops-projects/*" {
+  capabilities = [ "delete" ]
+}
 EOF
 
 vault policy write argo-cloudops-service /tmp/argo-cloudops-policy.hcl
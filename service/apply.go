@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Apply change actions.
+const (
+	applyActionCreate = "create"
+	applyActionDelete = "delete"
+	applyActionUpdate = "update"
+)
+
+// applyStep is a change of an apply plan and the function making it.
+type applyStep struct {
+	change responses.ApplyChange
+	run    func(ctx context.Context, change *responses.ApplyChange) error
+}
+
+// Applies a declarative spec of projects and targets
+func (h handler) apply(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "apply")
+
+	level.Debug(l).Log("message", "validating authorization header for apply")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	dryRun, err := parseDryRun(r.URL.Query())
+	if err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	var spec requests.Apply
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request body", "error", err)
+		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(reqBody, &spec); err != nil {
+		level.Error(l).Log("message", "error decoding request", "error", err)
+		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+		return
+	}
+	if err := spec.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	l = log.With(l, "dryRun", dryRun)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	h.runApply(ctx, l, w, cp, a.Provider, spec, dryRun)
+}
+
+// runApply plans the changes of the spec, applies them unless dryRun, and
+// writes the changes as the response. Tokens of created projects are for the
+// provider.
+func (h handler) runApply(ctx context.Context, l log.Logger, w http.ResponseWriter, cp credentials.Provider, provider string, spec requests.Apply, dryRun bool) {
+	level.Debug(l).Log("message", "planning changes")
+	steps, err := h.planApply(ctx, cp, provider, spec)
+	if err != nil {
+		level.Error(l).Log("message", "error planning changes", "error", err)
+		h.errorResponse(w, "error planning changes", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.Apply{
+		Changes: []responses.ApplyChange{},
+		DryRun:  dryRun,
+	}
+
+	for _, step := range steps {
+		change := step.change
+		if !dryRun {
+			level.Info(l).Log("message", "applying change", "action", change.Action, "project", change.Project, "target", change.Target)
+			if err := step.run(ctx, &change); err != nil {
+				level.Error(l).Log("message", "error applying change", "action", change.Action, "project", change.Project, "target", change.Target, "error", err)
+
+				// The changes applied before are returned too, as the
+				// tokens of created projects can't be read again.
+				resp.ErrorMessage = fmt.Sprintf("error applying changes, unable to %s %s", change.Action, describeApplyChange(change))
+				w.WriteHeader(http.StatusInternalServerError)
+				break
+			}
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseDryRun reads the optional dry_run query parameter.
+func parseDryRun(query url.Values) (bool, error) {
+	v := query.Get("dry_run")
+	if v == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.New("dry_run must be a boolean")
+	}
+
+	return dryRun, nil
+}
+
+// planApply returns the steps which make the projects and targets match the
+// spec. Projects are created before their targets and deleted after them.
+func (h handler) planApply(ctx context.Context, cp credentials.Provider, provider string, spec requests.Apply) ([]applyStep, error) {
+	cpProjects, err := cp.ListProjects(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list projects from credentials provider: %w", err)
+	}
+
+	dbProjects, _, err := h.dbClient.ListProjectEntries(ctx, db.ProjectFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list projects from database: %w", err)
+	}
+
+	inCP := map[string]bool{}
+	for _, p := range cpProjects {
+		inCP[p] = true
+	}
+
+	entries := map[string]db.ProjectEntry{}
+	for _, pe := range dbProjects {
+		entries[pe.ProjectID] = pe
+	}
+
+	steps := []applyStep{}
+	declared := map[string]bool{}
+	for _, p := range spec.Projects {
+		declared[p.Name] = true
+
+		if !inCP[p.Name] {
+			steps = append(steps, h.applyCreateProject(cp, provider, p))
+			for _, t := range p.Targets {
+				steps = append(steps, h.applyCreateTarget(cp, p.Name, t))
+			}
+			continue
+		}
+
+		entry, hasEntry := entries[p.Name]
+		if !hasEntry || !projectEntryMatches(entry, p) {
+			steps = append(steps, h.applyUpdateProject(p, hasEntry))
+		}
+
+		targetSteps, err := h.planApplyTargets(ctx, cp, p, spec.Prune)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, targetSteps...)
+	}
+
+	if !spec.Prune {
+		return steps, nil
+	}
+
+	undeclared := []string{}
+	for p := range inCP {
+		if !declared[p] {
+			undeclared = append(undeclared, p)
+		}
+	}
+	for p := range entries {
+		if !declared[p] && !inCP[p] {
+			undeclared = append(undeclared, p)
+		}
+	}
+	sort.Strings(undeclared)
+
+	for _, p := range undeclared {
+		if inCP[p] {
+			targets, err := cp.ListTargets(ctx, p)
+			if err != nil {
+				return nil, fmt.Errorf("unable to list targets of project '%s': %w", p, err)
+			}
+			sort.Strings(targets)
+
+			for _, t := range targets {
+				steps = append(steps, h.applyDeleteTarget(cp, p, t))
+			}
+		}
+
+		steps = append(steps, h.applyDeleteProject(cp, p, inCP[p]))
+	}
+
+	return steps, nil
+}
+
+// planApplyTargets returns the steps which make the targets of an existing
+// project match the spec.
+func (h handler) planApplyTargets(ctx context.Context, cp credentials.Provider, p requests.ApplyProject, prune bool) ([]applyStep, error) {
+	existing, err := cp.ListTargets(ctx, p.Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list targets of project '%s': %w", p.Name, err)
+	}
+	sort.Strings(existing)
+
+	exists := map[string]bool{}
+	for _, t := range existing {
+		exists[t] = true
+	}
+
+	steps := []applyStep{}
+	declared := map[string]bool{}
+	for _, t := range p.Targets {
+		declared[t.Name] = true
+
+		if !exists[t.Name] {
+			steps = append(steps, h.applyCreateTarget(cp, p.Name, t))
+			continue
+		}
+
+		current, err := h.readTarget(ctx, cp, p.Name, t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get target '%s' of project '%s': %w", t.Name, p.Name, err)
+		}
+
+		if !targetsMatch(current.Target, t) {
+			steps = append(steps, h.applyUpdateTarget(cp, p.Name, t))
+		}
+	}
+
+	if prune {
+		for _, t := range existing {
+			if !declared[t] {
+				steps = append(steps, h.applyDeleteTarget(cp, p.Name, t))
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+func (h handler) applyCreateProject(cp credentials.Provider, provider string, p requests.ApplyProject) applyStep {
+	return applyStep{
+		change: responses.ApplyChange{Action: applyActionCreate, Project: p.Name},
+		run: func(ctx context.Context, change *responses.ApplyChange) error {
+			if err := h.dbClient.CreateProjectEntry(ctx, newApplyProjectEntry(p)); err != nil {
+				return err
+			}
+
+			token, err := cp.CreateProject(ctx, p.Name)
+			if err != nil {
+				return err
+			}
+
+			if err := h.dbClient.CreateTokenEntry(ctx, token); err != nil {
+				return err
+			}
+
+			change.Token = newCelloToken(provider, token).Token
+			change.TokenID = token.ProjectToken.ID
+			return nil
+		},
+	}
+}
+
+func (h handler) applyUpdateProject(p requests.ApplyProject, hasEntry bool) applyStep {
+	return applyStep{
+		change: responses.ApplyChange{Action: applyActionUpdate, Project: p.Name},
+		run: func(ctx context.Context, change *responses.ApplyChange) error {
+			// Projects missing from the database get their entry back.
+			if !hasEntry {
+				return h.dbClient.CreateProjectEntry(ctx, newApplyProjectEntry(p))
+			}
+			return h.dbClient.UpdateProjectEntry(ctx, newApplyProjectEntry(p))
+		},
+	}
+}
+
+func (h handler) applyDeleteProject(cp credentials.Provider, project string, inCP bool) applyStep {
+	return applyStep{
+		change: responses.ApplyChange{Action: applyActionDelete, Project: project},
+		run: func(ctx context.Context, change *responses.ApplyChange) error {
+			if inCP {
+				if err := cp.DeleteProject(ctx, project); err != nil {
+					return err
+				}
+			}
+			return h.dbClient.DeleteProjectEntry(ctx, project)
+		},
+	}
+}
+
+func (h handler) applyCreateTarget(cp credentials.Provider, project string, t types.Target) applyStep {
+	return applyStep{
+		change: responses.ApplyChange{Action: applyActionCreate, Project: project, Target: t.Name},
+		run: func(ctx context.Context, change *responses.ApplyChange) error {
+			return h.dbClient.UpsertTargetEntry(ctx, db.NewTargetEntry(project, t), func() error {
+				return cp.CreateTarget(ctx, project, t)
+			})
+		},
+	}
+}
+
+func (h handler) applyUpdateTarget(cp credentials.Provider, project string, t types.Target) applyStep {
+	return applyStep{
+		change: responses.ApplyChange{Action: applyActionUpdate, Project: project, Target: t.Name},
+		run: func(ctx context.Context, change *responses.ApplyChange) error {
+			return h.dbClient.UpsertTargetEntry(ctx, db.NewTargetEntry(project, t), func() error {
+				return cp.UpdateTarget(ctx, project, t)
+			})
+		},
+	}
+}
+
+func (h handler) applyDeleteTarget(cp credentials.Provider, project, target string) applyStep {
+	return applyStep{
+		change: responses.ApplyChange{Action: applyActionDelete, Project: project, Target: target},
+		run: func(ctx context.Context, change *responses.ApplyChange) error {
+			return h.dbClient.DeleteTargetEntry(ctx, project, target, func() error {
+				return cp.DeleteTarget(ctx, project, target)
+			})
+		},
+	}
+}
+
+func newApplyProjectEntry(p requests.ApplyProject) db.ProjectEntry {
+	return db.ProjectEntry{
+		Contact:              p.Contact,
+		Description:          p.Description,
+		Labels:               p.Labels,
+		ManifestRoot:         p.ManifestRoot,
+		RequireSignedCommits: db.SignedCommitPolicy(p.RequireSignedCommits),
+		ProjectID:            p.Name,
+		Repository:           p.Repository,
+		Team:                 p.Team,
+	}
+}
+
+// projectEntryMatches returns whether the entry has the repository and
+// metadata of the declared project.
+func projectEntryMatches(entry db.ProjectEntry, p requests.ApplyProject) bool {
+	current := entry.Metadata()
+	want := p.ProjectMetadata
+	if len(current.Labels) == 0 {
+		current.Labels = nil
+	}
+	if len(want.Labels) == 0 {
+		want.Labels = nil
+	}
+	if len(want.RequireSignedCommits) == 0 {
+		want.RequireSignedCommits = nil
+	}
+
+	return entry.Repository == p.Repository && reflect.DeepEqual(current, want)
+}
+
+// targetsMatch returns whether the targets are the same, treating empty and
+// missing lists and maps as equal.
+func targetsMatch(current, want types.Target) bool {
+	normalize := func(t types.Target) types.Target {
+		if len(t.Properties.PolicyArns) == 0 {
+			t.Properties.PolicyArns = nil
+		}
+		if len(t.Properties.SessionTags) == 0 {
+			t.Properties.SessionTags = nil
+		}
+		if len(t.RequireSignedCommits) == 0 {
+			t.RequireSignedCommits = nil
+		}
+		return t
+	}
+
+	return reflect.DeepEqual(normalize(current), normalize(want))
+}
+
+func describeApplyChange(change responses.ApplyChange) string {
+	if change.Target != "" {
+		return fmt.Sprintf("target '%s' of project '%s'", change.Target, change.Project)
+	}
+	return fmt.Sprintf("project '%s'", change.Project)
+}
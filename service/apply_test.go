@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	th "github.com/cello-proj/cello/service/test/testhelpers"
+
+	upper "github.com/upper/db/v4"
+)
+
+// applyTarget returns an aws_account target with the role.
+func applyTarget(name, role, region string) types.Target {
+	return types.Target{
+		Name: name,
+		Type: "aws_account",
+		Properties: types.TargetProperties{
+			CredentialType: "assumed_role",
+			PolicyArns:     []string{},
+			Region:         region,
+			RoleArn:        fmt.Sprintf("arn:aws:iam::123456789012:role/%s", role),
+		},
+	}
+}
+
+// applyMocks returns mocks with the projects and targets in the credentials
+// provider and the project entries in the database.
+func applyMocks(targets map[string][]types.Target, entries []db.ProjectEntry) (*th.CredsProviderMock, *th.DBClientMock) {
+	cpMock := &th.CredsProviderMock{
+		ListProjectsFunc: func(ctx context.Context) ([]string, error) {
+			projects := []string{}
+			for p := range targets {
+				projects = append(projects, p)
+			}
+			return projects, nil
+		},
+		ListTargetsFunc: func(ctx context.Context, project string) ([]string, error) {
+			names := []string{}
+			for _, t := range targets[project] {
+				names = append(names, t.Name)
+			}
+			return names, nil
+		},
+		GetTargetFunc: func(ctx context.Context, project, target string) (types.Target, error) {
+			for _, t := range targets[project] {
+				if t.Name == target {
+					// The region is stored in the database.
+					t.Properties.Region = ""
+					return t, nil
+				}
+			}
+			return types.Target{}, credentials.ErrTargetNotFound
+		},
+		CreateProjectFunc: func(ctx context.Context, project string) (types.Token, error) {
+			return types.Token{
+				ProjectID:    project,
+				ProjectToken: types.ProjectToken{ID: "secret-id-accessor"},
+				RoleID:       "role-id",
+				Secret:       "secret",
+			}, nil
+		},
+		CreateTargetFunc:  func(ctx context.Context, project string, target types.Target) error { return nil },
+		UpdateTargetFunc:  func(ctx context.Context, project string, target types.Target) error { return nil },
+		DeleteTargetFunc:  func(ctx context.Context, project, target string) error { return nil },
+		DeleteProjectFunc: func(ctx context.Context, project string) error { return nil },
+	}
+
+	dbMock := &th.DBClientMock{
+		ListProjectEntriesFunc: func(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error) {
+			return entries, uint64(len(entries)), nil
+		},
+		ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+			for _, t := range targets[project] {
+				if t.Name == target && t.Properties.Region != "" {
+					return db.TargetEntry{ProjectID: project, TargetName: target, Region: t.Properties.Region}, nil
+				}
+			}
+			return db.TargetEntry{}, upper.ErrNoMoreRows
+		},
+		CreateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error { return nil },
+		UpdateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error { return nil },
+		DeleteProjectEntryFunc: func(ctx context.Context, project string) error { return nil },
+		CreateTokenEntryFunc:   func(ctx context.Context, token types.Token) error { return nil },
+		UpsertTargetEntryFunc:  func(ctx context.Context, te db.TargetEntry, write func() error) error { return write() },
+		DeleteTargetEntryFunc:  func(ctx context.Context, project, target string, remove func() error) error { return remove() },
+	}
+
+	return cpMock, dbMock
+}
+
+func TestApply(t *testing.T) {
+	// project1 has an outdated repository and targets to update, create and
+	// delete, project2 is new and project3 is not declared.
+	driftedCP, driftedDB := applyMocks(
+		map[string][]types.Target{
+			"project1": {
+				applyTarget("target1", "target1", "us-west-2"),
+				applyTarget("target2", "target2", ""),
+				applyTarget("target4", "target4", ""),
+			},
+			"project3": {
+				applyTarget("targetA", "targetA", ""),
+			},
+		},
+		[]db.ProjectEntry{
+			{ProjectID: "project1", Repository: "git@github.com:myorg/old.git", Team: "platform"},
+			{ProjectID: "project3", Repository: "git@github.com:myorg/project3.git"},
+		},
+	)
+
+	appliedCP, appliedDB := applyMocks(
+		map[string][]types.Target{
+			"project1": {
+				applyTarget("target1", "target1", "us-west-2"),
+				applyTarget("target2", "target2-new", ""),
+				applyTarget("target3", "target3", ""),
+			},
+			"project2": {
+				applyTarget("target1", "project2", ""),
+			},
+		},
+		[]db.ProjectEntry{
+			{ProjectID: "project1", Repository: "git@github.com:myorg/project1.git", Team: "platform"},
+			{ProjectID: "project2", Repository: "git@github.com:myorg/project2.git", Labels: db.ProjectLabels{}},
+		},
+	)
+
+	// project2 is created before its target fails to be created.
+	failingCP, failingDB := applyMocks(
+		map[string][]types.Target{
+			"project1": {
+				applyTarget("target1", "target1", "us-west-2"),
+				applyTarget("target2", "target2-new", ""),
+				applyTarget("target3", "target3", ""),
+			},
+		},
+		[]db.ProjectEntry{
+			{ProjectID: "project1", Repository: "git@github.com:myorg/project1.git", Team: "platform"},
+		},
+	)
+	failingCP.CreateTargetFunc = func(ctx context.Context, project string, target types.Target) error { return errors.New("error") }
+
+	tests := []test{
+		{
+			name:       "fails to apply when not admin",
+			req:        loadJSON(t, "TestApply/apply_request.json"),
+			want:       http.StatusUnauthorized,
+			respFile:   "TestApply/fails_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/admin/apply",
+			method:     "POST",
+		},
+		{
+			name:       "dry run returns plan",
+			req:        loadJSON(t, "TestApply/apply_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestApply/dry_run_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/apply?dry_run=true",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				ListProjectsFunc: driftedCP.ListProjectsFunc,
+				ListTargetsFunc:  driftedCP.ListTargetsFunc,
+				GetTargetFunc:    driftedCP.GetTargetFunc,
+				CreateProjectFunc: func(ctx context.Context, project string) (types.Token, error) {
+					return types.Token{}, errors.New("dry run")
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ListProjectEntriesFunc: driftedDB.ListProjectEntriesFunc,
+				ReadTargetEntryFunc:    driftedDB.ReadTargetEntryFunc,
+			},
+		},
+		{
+			name:       "can apply",
+			req:        loadJSON(t, "TestApply/apply_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestApply/apply_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/apply",
+			method:     "POST",
+			cpMock:     driftedCP,
+			dbMock:     driftedDB,
+		},
+		{
+			name:       "no changes when applied",
+			req:        loadJSON(t, "TestApply/apply_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestApply/no_changes_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/apply",
+			method:     "POST",
+			cpMock:     appliedCP,
+			dbMock:     appliedDB,
+		},
+		{
+			name:       "invalid dry run",
+			req:        loadJSON(t, "TestApply/apply_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestApply/invalid_dry_run_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/apply?dry_run=maybe",
+			method:     "POST",
+		},
+		{
+			name:       "project declared more than once",
+			req:        loadJSON(t, "TestApply/duplicate_project_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestApply/duplicate_project_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/apply",
+			method:     "POST",
+		},
+		{
+			name:       "apply error",
+			req:        loadJSON(t, "TestApply/apply_request.json"),
+			want:       http.StatusInternalServerError,
+			respFile:   "TestApply/apply_error_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/apply",
+			method:     "POST",
+			cpMock:     failingCP,
+			dbMock:     failingDB,
+		},
+	}
+	runTests(t, tests)
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+const (
+	// batchMaxStatusErrors is the number of consecutive errors getting the
+	// status of a workflow of a batch after which it's considered failed.
+	batchMaxStatusErrors = 10
+	// batchStaleIntervals is the number of poll intervals after which running
+	// batches which weren't updated are interrupted.
+	batchStaleIntervals = 6
+)
+
+// batchRunner runs the workflows of batches in the background. Batches which
+// are running when the service stops are interrupted, they are not resumed.
+type batchRunner struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	pollInterval time.Duration
+	wg           sync.WaitGroup
+}
+
+func newBatchRunner(pollInterval time.Duration) *batchRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &batchRunner{ctx: ctx, cancel: cancel, pollInterval: pollInterval}
+}
+
+// start runs fn in the background with a context cancelled when the runner
+// stops.
+func (b *batchRunner) start(fn func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn(b.ctx)
+	}()
+}
+
+// wait waits for the running batches to finish.
+func (b *batchRunner) wait() {
+	b.wg.Wait()
+}
+
+// stop interrupts the running batches and waits for them to record it.
+func (b *batchRunner) stop() {
+	b.cancel()
+	b.wait()
+}
+
+// interruptStaleBatches interrupts the running batches which weren't updated
+// for batchStaleIntervals poll intervals every poll interval, until the
+// context is done. Batches of replicas which stopped without interrupting
+// them, e.g. because they crashed, would otherwise be running forever.
+func (h handler) interruptStaleBatches(ctx context.Context) {
+	l := log.With(h.logger, "op", "interrupt-stale-batches")
+
+	ticker := time.NewTicker(h.batches.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		updatedBefore := time.Now().Add(-h.batches.pollInterval * batchStaleIntervals)
+		ids, err := h.dbClient.InterruptBatchEntries(ctx, updatedBefore)
+		if err != nil {
+			level.Error(l).Log("message", "error interrupting stale batches", "error", err)
+		}
+		for _, id := range ids {
+			level.Info(l).Log("message", "interrupted stale batch", "batch", id)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fans the operation of a manifest with targets out to the matching targets
+// of the project, with one workflow per target run in waves.
+func (h handler) createBatchFromGit(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+
+	l := h.requestLogger(r, "op", "create-batch-from-git", "project", projectName)
+
+	ctx := r.Context()
+
+	level.Debug(l).Log("message", "validating authorization header for create batch from git")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var cgwr requests.CreateGitWorkflow
+	if err := json.Unmarshal(reqBody, &cgwr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := cgwr.Validate(); err != nil {
+		level.Error(l).Log("message", "error validating request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	op, l, ok := h.loadGitOperation(ctx, l, w, r, a, projectName, cgwr)
+	if !ok {
+		return
+	}
+	manifest := op.manifest
+
+	if err := manifest.ValidateTargets(); err != nil {
+		level.Error(l).Log("message", "error invalid manifest targets", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating new credentials provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "bad or unknown credentials provider", "error", err)
+		h.errorResponse(w, "bad or unknown credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "listing targets")
+	targetNames, err := h.listTargetNames(ctx, a.Provider, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error listing targets", "error", err)
+		h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
+		return
+	}
+
+	targets, err := batchTargets(manifest, targetNames)
+	if err != nil {
+		level.Error(l).Log("message", "error matching manifest targets", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	rollout := requests.Rollout{}
+	if manifest.Rollout != nil {
+		rollout = *manifest.Rollout
+	}
+
+	requireSigned := false
+	be := db.BatchEntry{
+		BatchID:       uuid.NewString(),
+		ProjectID:     projectName,
+		CommitHash:    op.commitHash,
+		Type:          manifest.Type,
+		Status:        db.BatchStatusRunning,
+		Parallelism:   rollout.Parallelism,
+		StopOnFailure: rollout.StopOnFailure,
+	}
+	workflowRequests := make([]requests.CreateWorkflow, 0, len(targets))
+	for _, bt := range targets {
+		tl := log.With(l, "target", bt.name)
+
+		// All workflows are validated before any is submitted, so the batch
+		// only fails for errors of the workflows themselves.
+		cwr, werr := h.resolveManifest(ctx, tl, op, bt.name)
+		if werr != nil {
+			h.errorResponse(w, werr.message, werr.status)
+			return
+		}
+
+		if werr := h.validateWorkflow(ctx, tl, cp, cwr); werr != nil {
+			h.errorResponse(w, werr.message, werr.status)
+			return
+		}
+
+		required, err := h.requiresSignedCommit(ctx, op.projectEntry, bt.name, cwr.Type)
+		if err != nil {
+			level.Error(tl).Log("message", "error reading target data", "error", err)
+			h.errorResponse(w, "error reading target data", http.StatusInternalServerError)
+			return
+		}
+		requireSigned = requireSigned || required
+
+		workflowRequests = append(workflowRequests, cwr)
+		be.Workflows = append(be.Workflows, db.BatchWorkflow{
+			Status: db.BatchStatusPending,
+			Target: bt.name,
+			Wave:   bt.wave,
+		})
+	}
+
+	workflowLabels := map[string]string{
+		txIDHeader:   r.Header.Get(txIDHeader),
+		gitSHALabel:  op.commitHash,
+		batchIDLabel: be.BatchID,
+	}
+	if requireSigned {
+		signature, ok := h.verifySignedCommit(ctx, l, w, op)
+		if !ok {
+			return
+		}
+		workflowLabels[gitSignatureTypeLabel] = signature.Type
+		workflowLabels[gitSignatureKeyLabel] = signature.KeyID
+	}
+
+	l = log.With(l, "batch", be.BatchID)
+
+	level.Debug(l).Log("message", "creating batch")
+	if err := h.dbClient.CreateBatchEntry(ctx, be); err != nil {
+		level.Error(l).Log("message", "error creating batch", "error", err)
+		h.errorResponse(w, "error creating batch", http.StatusInternalServerError)
+		return
+	}
+
+	// The batch outlives the request, so it isn't cancelled with it.
+	h.batches.start(func(ctx context.Context) {
+		h.runBatch(ctx, l, cp, be, workflowRequests, workflowLabels)
+	})
+
+	jsonData, err := json.Marshal(responses.CreateBatch{BatchID: be.BatchID, SHA: op.commitHash})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing batch response", "error", err)
+		h.errorResponse(w, "error serializing batch response", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, string(jsonData))
+}
+
+// listTargetNames returns the names of the targets of the project in the
+// database or the credentials provider, so targets which weren't imported into
+// the database yet are included. The targets are listed from the named
+// credentials provider.
+func (h handler) listTargetNames(ctx context.Context, provider, project string) ([]string, error) {
+	targetEntries, err := h.dbClient.ListTargetEntries(ctx, project, db.TargetFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list targets from database: %w", err)
+	}
+
+	// Listing targets requires admin credentials.
+	cp, err := h.newAdminCredentialsProvider(provider)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create credentials provider: %w", err)
+	}
+
+	cpTargets, err := cp.ListTargets(ctx, project)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list targets from credentials provider: %w", err)
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, te := range targetEntries {
+		names = append(names, te.TargetName)
+		seen[te.TargetName] = true
+	}
+	for _, name := range cpTargets {
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	return names, nil
+}
+
+// batchTarget is a target of a batch.
+type batchTarget struct {
+	name string
+	wave int
+}
+
+// batchTargets returns the targets of the project matching the manifest
+// targets, with the wave of the first manifest target they match, ordered by
+// wave and target. Every manifest target must match a target.
+func batchTargets(manifest requests.CreateWorkflow, targetNames []string) ([]batchTarget, error) {
+	matched := map[string]bool{}
+	targets := []batchTarget{}
+	for _, name := range targetNames {
+		mt, ok := manifest.MatchTarget(name)
+		if !ok {
+			continue
+		}
+		matched[mt.Name] = true
+		targets = append(targets, batchTarget{name: name, wave: mt.Wave})
+	}
+
+	for _, mt := range manifest.Targets {
+		if !matched[mt.Name] {
+			return nil, fmt.Errorf("manifest target '%s' does not match any targets", mt.Name)
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].wave != targets[j].wave {
+			return targets[i].wave < targets[j].wave
+		}
+		return targets[i].name < targets[j].name
+	})
+	return targets, nil
+}
+
+// runBatch runs the waves of the batch in order, and records the status of
+// its workflows. The workflows of later waves are skipped once a workflow
+// failed when the batch stops on failure. The batch is interrupted when the
+// context is done.
+func (h handler) runBatch(ctx context.Context, l log.Logger, cp credentials.Provider, be db.BatchEntry, cwrs []requests.CreateWorkflow, workflowLabels map[string]string) {
+	level.Info(l).Log("message", "running batch", "workflows", len(be.Workflows))
+
+	failed := false
+	for start := 0; start < len(be.Workflows); {
+		wave := be.Workflows[start].Wave
+		end := start
+		for end < len(be.Workflows) && be.Workflows[end].Wave == wave {
+			end++
+		}
+
+		if failed && be.StopOnFailure {
+			level.Info(l).Log("message", "skipping batch wave", "wave", wave)
+			for i := start; i < end; i++ {
+				be.Workflows[i].Status = db.BatchStatusSkipped
+			}
+			h.updateBatch(ctx, l, be)
+		} else if !h.runBatchWave(ctx, log.With(l, "wave", wave), cp, be, cwrs, start, end, workflowLabels) {
+			failed = true
+		}
+
+		start = end
+
+		if ctx.Err() != nil && unfinished(be) {
+			// The context is done, so the interruption is recorded without
+			// it.
+			be.Interrupt()
+			h.updateBatch(context.WithoutCancel(ctx), l, be)
+			level.Info(l).Log("message", "batch interrupted")
+			return
+		}
+	}
+
+	be.Status = db.BatchStatusSucceeded
+	if failed {
+		be.Status = db.BatchStatusFailed
+	}
+	h.updateBatch(ctx, l, be)
+	level.Info(l).Log("message", "batch finished", "status", be.Status)
+}
+
+// unfinished returns whether workflows of the batch are pending or running.
+func unfinished(be db.BatchEntry) bool {
+	for _, bw := range be.Workflows {
+		if bw.Status == db.BatchStatusPending || bw.Status == db.BatchStatusRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// runBatchWave runs the workflows of the batch from start to end, at most
+// parallelism at once, until they finish or the context is done. It returns
+// whether they all succeeded.
+func (h handler) runBatchWave(ctx context.Context, l log.Logger, cp credentials.Provider, be db.BatchEntry, cwrs []requests.CreateWorkflow, start, end int, workflowLabels map[string]string) bool {
+	succeeded := true
+	running := []int{}
+	statusErrors := map[int]int{}
+	for next := start; next < end || len(running) > 0; {
+		for ; next < end && (be.Parallelism == 0 || len(running) < be.Parallelism) && ctx.Err() == nil; next++ {
+			bw := &be.Workflows[next]
+			workflowName, werr := h.submitWorkflow(ctx, log.With(l, "target", bw.Target), cp, cwrs[next], workflowLabels)
+			if werr != nil {
+				bw.Status = db.BatchStatusFailed
+				bw.Error = werr.message
+				succeeded = false
+				continue
+			}
+			bw.Status = db.BatchStatusRunning
+			bw.WorkflowName = workflowName
+			running = append(running, next)
+		}
+		h.updateBatch(ctx, l, be)
+
+		if len(running) == 0 {
+			if ctx.Err() != nil {
+				return false
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(h.batches.pollInterval):
+		}
+
+		stillRunning := []int{}
+		for _, i := range running {
+			bw := &be.Workflows[i]
+			status, err := h.argo.Status(h.argoContext(ctx), bw.WorkflowName)
+			if err != nil {
+				level.Error(l).Log("message", "error getting workflow status", "workflow", bw.WorkflowName, "error", err)
+
+				// Transient errors are retried with the next poll, while
+				// workflows which can't be found, e.g. because they were
+				// deleted, eventually fail.
+				statusErrors[i]++
+				if statusErrors[i] < batchMaxStatusErrors {
+					stillRunning = append(stillRunning, i)
+					continue
+				}
+				bw.Status = db.BatchStatusFailed
+				bw.Error = fmt.Sprintf("unable to get workflow status: %s", err)
+				succeeded = false
+				continue
+			}
+			delete(statusErrors, i)
+
+			switch status.Status {
+			case db.BatchStatusSucceeded:
+				bw.Status = db.BatchStatusSucceeded
+			case db.BatchStatusFailed, "error":
+				bw.Status = db.BatchStatusFailed
+				succeeded = false
+			default:
+				stillRunning = append(stillRunning, i)
+			}
+		}
+		running = stillRunning
+	}
+
+	h.updateBatch(ctx, l, be)
+	return succeeded
+}
+
+// updateBatch records the status of the batch. Errors are only logged so the
+// batch keeps running.
+func (h handler) updateBatch(ctx context.Context, l log.Logger, be db.BatchEntry) {
+	if err := h.dbClient.UpdateBatchEntry(ctx, be); err != nil {
+		level.Error(l).Log("message", "error updating batch", "error", err)
+	}
+}
+
+// Gets a batch
+func (h handler) getBatch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+	batchID := vars["batchID"]
+
+	l := h.requestLogger(r, "op", "get-batch", "project", projectName, "batch", batchID)
+
+	// The batch is read after the authorization, so unauthorized requests
+	// can't tell which batches exist.
+	a, ok := h.readAuthorization(w, r, l)
+	if !ok {
+		return
+	}
+
+	var scopes types.TokenScopes
+	if a.Key != "admin" {
+		if scopes, ok = h.readProjectScopes(w, r, l, *a, projectName); !ok {
+			return
+		}
+	}
+
+	level.Debug(l).Log("message", "reading batch")
+	be, err := h.dbClient.ReadBatchEntry(r.Context(), projectName, batchID)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "batch not found", http.StatusNotFound)
+			return
+		}
+		level.Error(l).Log("message", "error reading batch", "error", err)
+		h.errorResponse(w, "error reading batch", http.StatusInternalServerError)
+		return
+	}
+
+	targetNames := make([]string, len(be.Workflows))
+	for i, bw := range be.Workflows {
+		targetNames[i] = bw.Target
+	}
+	if !h.authorizeTargetsRead(w, l, scopes, targetNames...) {
+		return
+	}
+
+	resp := responses.Batch{
+		BatchID:       be.BatchID,
+		CreatedAt:     be.CreatedAt,
+		Parallelism:   be.Parallelism,
+		Project:       be.ProjectID,
+		SHA:           be.CommitHash,
+		Status:        be.Status,
+		StopOnFailure: be.StopOnFailure,
+		Type:          be.Type,
+		UpdatedAt:     be.UpdatedAt,
+		Workflows:     []responses.BatchWorkflow{},
+	}
+	for _, bw := range be.Workflows {
+		resp.Workflows = append(resp.Workflows, responses.BatchWorkflow{
+			Error:        bw.Error,
+			Status:       bw.Status,
+			Target:       bw.Target,
+			Wave:         bw.Wave,
+			WorkflowName: bw.WorkflowName,
+		})
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing batch", "error", err)
+		h.errorResponse(w, "error serializing batch", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, string(jsonData))
+}
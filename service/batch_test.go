@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/git"
+	"github.com/cello-proj/cello/service/internal/workflow"
+	th "github.com/cello-proj/cello/service/test/testhelpers"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	upper "github.com/upper/db/v4"
+)
+
+// newTestBatchDB returns a database with project1 and its targets.
+func newTestBatchDB(t *testing.T, targets ...string) db.SQLClient {
+	t.Helper()
+
+	database := newTestDB(t)
+	ctx := context.Background()
+	assert.Nil(t, database.CreateProjectEntry(ctx, db.ProjectEntry{ProjectID: "project1", Repository: "repo"}))
+	for _, target := range targets {
+		te := db.TargetEntry{ProjectID: "project1", TargetName: target}
+		assert.Nil(t, database.UpsertTargetEntry(ctx, te, func() error { return nil }))
+	}
+	return database
+}
+
+func batchCredsProviderMock() *th.CredsProviderMock {
+	return &th.CredsProviderMock{
+		GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+		ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+		TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+		ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{}, nil },
+		LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+			return types.ProjectToken{ID: "secret-id-accessor"}, nil
+		},
+	}
+}
+
+func batchGitClientMock(manifest string) *th.GitClientMock {
+	return &th.GitClientMock{
+		GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+			return loadFileBytes(manifest)
+		},
+	}
+}
+
+func TestCreateBatchFromGit(t *testing.T) {
+	tests := []test{
+		{
+			name:       "manifest must have targets",
+			req:        loadJSON(t, "TestCreateBatchFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateBatchFromGit/without_targets_response.json",
+			method:     "POST",
+			url:        "/projects/project1/operations",
+			cpMock:     batchCredsProviderMock(),
+			db:         newTestBatchDB(t, "dev_east"),
+			gitMock:    batchGitClientMock("TestCreateWorkflowFromGit/manifest_without_target.json"),
+		},
+		{
+			name:       "manifest targets must match targets",
+			req:        loadJSON(t, "TestCreateBatchFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateBatchFromGit/unmatched_target_response.json",
+			method:     "POST",
+			url:        "/projects/project1/operations",
+			cpMock:     batchCredsProviderMock(),
+			db:         newTestBatchDB(t, "dev_east", "prod_east"),
+			gitMock:    batchGitClientMock("TestCreateBatchFromGit/manifest_unmatched_target.json"),
+		},
+		{
+			name:       "token scopes must allow all targets",
+			req:        loadJSON(t, "TestCreateBatchFromGit/good_request.json"),
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateBatchFromGit/scopes_forbid_target_response.json",
+			method:     "POST",
+			url:        "/projects/project1/operations",
+			cpMock:     batchCredsProviderMock(),
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+				ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+					return []db.TargetEntry{{TargetName: "dev_east"}, {TargetName: "prod_east"}}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: token, Scopes: db.TokenScopes{Targets: []string{"dev_*"}}}, nil
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			gitMock: batchGitClientMock("TestCreateBatchFromGit/manifest.json"),
+		},
+		{
+			name:       "batch must exist",
+			want:       http.StatusNotFound,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateBatchFromGit/batch_not_found_response.json",
+			method:     "GET",
+			url:        "/projects/project1/batches/batchdoesnotexist",
+			cpMock:     batchCredsProviderMock(),
+			db:         newTestBatchDB(t),
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestBatches(t *testing.T) {
+	tests := []struct {
+		name string
+		// failTargets are the targets whose workflows fail.
+		failTargets []string
+		// statusErrTargets are the targets whose workflow status can't be
+		// read.
+		statusErrTargets []string
+		// interrupt stops the runner while the first workflow is running.
+		interrupt    bool
+		submitErr    error
+		wantStatus   string
+		wantStatuses map[string]string
+	}{
+		{
+			name:       "runs the workflows of all targets",
+			wantStatus: db.BatchStatusSucceeded,
+			wantStatuses: map[string]string{
+				"dev_east":  db.BatchStatusSucceeded,
+				"dev_west":  db.BatchStatusSucceeded,
+				"prod_east": db.BatchStatusSucceeded,
+			},
+		},
+		{
+			name:        "skips later waves once a workflow failed",
+			failTargets: []string{"dev_east"},
+			wantStatus:  db.BatchStatusFailed,
+			wantStatuses: map[string]string{
+				"dev_east":  db.BatchStatusFailed,
+				"dev_west":  db.BatchStatusSucceeded,
+				"prod_east": db.BatchStatusSkipped,
+			},
+		},
+		{
+			name:             "fails workflows whose status can't be read",
+			statusErrTargets: []string{"dev_east"},
+			wantStatus:       db.BatchStatusFailed,
+			wantStatuses: map[string]string{
+				"dev_east":  db.BatchStatusFailed,
+				"dev_west":  db.BatchStatusSucceeded,
+				"prod_east": db.BatchStatusSkipped,
+			},
+		},
+		{
+			name:       "interrupts batches when the runner stops",
+			interrupt:  true,
+			wantStatus: db.BatchStatusInterrupted,
+			wantStatuses: map[string]string{
+				"dev_east":  db.BatchStatusInterrupted,
+				"dev_west":  db.BatchStatusInterrupted,
+				"prod_east": db.BatchStatusInterrupted,
+			},
+		},
+		{
+			name:       "fails workflows which can't be submitted",
+			submitErr:  errors.New("error"),
+			wantStatus: db.BatchStatusFailed,
+			wantStatuses: map[string]string{
+				"dev_east":  db.BatchStatusFailed,
+				"dev_west":  db.BatchStatusFailed,
+				"prod_east": db.BatchStatusSkipped,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadConfig(testConfigPath)
+			assert.Nil(t, err)
+
+			var mu sync.Mutex
+			running := map[string]bool{}
+			maxRunning := 0
+			labels := map[string]string{}
+			stages := map[string]string{}
+			submitted := make(chan struct{}, 3)
+
+			h := handler{
+				logger:  log.NewNopLogger(),
+				argoCtx: context.Background(),
+				config:  config,
+				newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					cp := batchCredsProviderMock()
+					// prod_east hasn't been imported into the database yet.
+					cp.ListTargetsFunc = func(ctx context.Context, s string) ([]string, error) {
+						return []string{"dev_east", "dev_west", "prod_east"}, nil
+					}
+					return cp, nil
+				},
+				dbClient:  newTestBatchDB(t, "dev_west", "dev_east"),
+				gitClient: batchGitClientMock("TestCreateBatchFromGit/manifest.json"),
+				argo: &th.WorkflowMock{
+					SubmitFunc: func(ctx context.Context, from string, parameters, l map[string]string) (string, error) {
+						if tt.submitErr != nil {
+							return "", tt.submitErr
+						}
+
+						mu.Lock()
+						defer mu.Unlock()
+						target := parameters["target_name"]
+						running[target] = true
+						if len(running) > maxRunning {
+							maxRunning = len(running)
+						}
+						labels = l
+						stages[target] = parameters["environment_variables_string"]
+						submitted <- struct{}{}
+						return "project1-" + target, nil
+					},
+					StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
+						mu.Lock()
+						defer mu.Unlock()
+						target := strings.TrimPrefix(workflowName, "project1-")
+						if tt.interrupt {
+							return &workflow.Status{Name: workflowName, Status: "running"}, nil
+						}
+						delete(running, target)
+						for _, f := range tt.statusErrTargets {
+							if f == target {
+								return nil, errors.New("workflow not found")
+							}
+						}
+						for _, f := range tt.failTargets {
+							if f == target {
+								return &workflow.Status{Name: workflowName, Status: "failed"}, nil
+							}
+						}
+						return &workflow.Status{Name: workflowName, Status: "succeeded"}, nil
+					},
+				},
+				env:     env.Vars{AdminSecret: testPassword},
+				batches: newBatchRunner(time.Millisecond),
+			}
+
+			resp := executeRequestWithHandler(h, http.MethodPost, "/projects/project1/operations", serialize(loadJSON(t, "TestCreateBatchFromGit/good_request.json")), userAuthHeader)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var created responses.CreateBatch
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&created))
+			assert.Equal(t, "1234567890abcdef1234567890abcdef12345678", created.SHA)
+
+			if tt.interrupt {
+				<-submitted
+				h.batches.stop()
+			} else {
+				h.batches.wait()
+			}
+
+			resp = executeRequestWithHandler(h, http.MethodGet, "/projects/project1/batches/"+created.BatchID, serialize(nil), userAuthHeader)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.Nil(t, err)
+
+			var batch responses.Batch
+			assert.Nil(t, json.Unmarshal(body, &batch))
+			assert.Equal(t, created.BatchID, batch.BatchID)
+			assert.Equal(t, tt.wantStatus, batch.Status)
+			assert.Equal(t, 1, batch.Parallelism)
+			assert.True(t, batch.StopOnFailure)
+
+			statuses := map[string]string{}
+			targets := []string{}
+			for _, bw := range batch.Workflows {
+				statuses[bw.Target] = bw.Status
+				targets = append(targets, bw.Target)
+			}
+			assert.Equal(t, tt.wantStatuses, statuses)
+			assert.Equal(t, []string{"dev_east", "dev_west", "prod_east"}, targets)
+
+			if tt.submitErr == nil {
+				assert.Equal(t, 1, maxRunning)
+				assert.Equal(t, created.BatchID, labels[batchIDLabel])
+				assert.Equal(t, "1234567890abcdef1234567890abcdef12345678", labels[gitSHALabel])
+				assert.NotContains(t, stages["dev_east"], "STAGE")
+			}
+			if len(tt.failTargets) == 0 && len(tt.statusErrTargets) == 0 && tt.submitErr == nil && !tt.interrupt {
+				assert.Contains(t, stages["prod_east"], "STAGE='prod'")
+			}
+		})
+	}
+}
+
+func TestBatchTargets(t *testing.T) {
+	manifest := requests.CreateWorkflow{
+		Targets: []requests.ManifestTarget{
+			{Name: "prod_*", Wave: 1},
+			{Name: "dev_*"},
+		},
+	}
+	targetNames := []string{"prod_west", "dev_west", "prod_east", "staging_east"}
+
+	got, err := batchTargets(manifest, targetNames)
+	assert.Nil(t, err)
+	assert.Equal(t, []batchTarget{
+		{name: "dev_west"},
+		{name: "prod_east", wave: 1},
+		{name: "prod_west", wave: 1},
+	}, got)
+
+	_, err = batchTargets(manifest, targetNames[:1])
+	assert.EqualError(t, err, "manifest target 'dev_*' does not match any targets")
+}
+
+func TestGetBatchAuthorization(t *testing.T) {
+	database := newTestBatchDB(t, "dev_east", "prod_east")
+	be := db.BatchEntry{
+		BatchID:   "batch1",
+		ProjectID: "project1",
+		Status:    db.BatchStatusRunning,
+		Workflows: db.BatchWorkflows{{Status: db.BatchStatusRunning, Target: "prod_east"}},
+	}
+	assert.Nil(t, database.CreateBatchEntry(context.Background(), be))
+
+	tests := []test{
+		{
+			name:   "authorization header is required",
+			want:   http.StatusUnauthorized,
+			body:   `{"error_message":"error unauthorized, invalid authorization header format"}`,
+			method: "GET",
+			url:    "/projects/project1/batches/batch1",
+			db:     database,
+		},
+		{
+			name:   "authorization is checked before reading the batch",
+			want:   http.StatusUnauthorized,
+			body:   `{"error_message":"error unauthorized, invalid authorization header format"}`,
+			method: "GET",
+			url:    "/projects/project1/batches/batch2",
+			db:     database,
+		},
+		{
+			name:       "token must be valid for the project of the batch",
+			want:       http.StatusUnauthorized,
+			body:       `{"error_message":"error unauthorized, token is not valid for project"}`,
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/projects/project2/batches/batch1",
+			cpMock: &th.CredsProviderMock{
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{}, credentials.ErrProjectTokenNotFound
+				},
+			},
+			db: database,
+		},
+		{
+			name:       "token scopes must allow the batch targets",
+			want:       http.StatusForbidden,
+			body:       `{"error_message":"error forbidden, token is not allowed to read target 'prod_east'"}`,
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/projects/project1/batches/batch1",
+			cpMock:     batchCredsProviderMock(),
+			db:         database,
+		},
+	}
+
+	// Scope the token of the project to the dev targets.
+	assert.Nil(t, database.CreateTokenEntry(context.Background(), types.Token{
+		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt:    time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		ProjectID:    "project1",
+		ProjectToken: types.ProjectToken{ID: "secret-id-accessor"},
+		Scopes:       types.TokenScopes{Targets: []string{"dev_*"}},
+	}))
+	runTests(t, tests)
+}
+
+func TestListTargetNames(t *testing.T) {
+	database := newTestBatchDB(t, "dev_east")
+
+	var provider string
+	h := handler{
+		logger:   log.NewNopLogger(),
+		dbClient: database,
+		env:      env.Vars{CredentialsProvider: credentials.ProviderVault},
+		newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+			provider = a.Provider
+			return &th.CredsProviderMock{
+				ListTargetsFunc: func(ctx context.Context, s string) ([]string, error) { return []string{"dev_east", "prod_east"}, nil },
+			}, nil
+		},
+	}
+
+	names, err := h.listTargetNames(context.Background(), credentials.ProviderPostgres, "project1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dev_east", "prod_east"}, names)
+	assert.Equal(t, credentials.ProviderPostgres, provider)
+}
+
+func TestInterruptStaleBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var updatedBefore time.Time
+	dbMock := &th.DBClientMock{
+		InterruptBatchEntriesFunc: func(ctx context.Context, before time.Time) ([]string, error) {
+			updatedBefore = before
+			cancel()
+			return []string{"batch1"}, nil
+		},
+	}
+
+	h := handler{logger: log.NewNopLogger(), dbClient: dbMock, batches: newBatchRunner(time.Minute)}
+	h.interruptStaleBatches(ctx)
+
+	assert.Len(t, dbMock.InterruptBatchEntriesCalls(), 1)
+	assert.WithinDuration(t, time.Now().Add(-batchStaleIntervals*time.Minute), updatedBefore, time.Second)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Exports all projects, their targets and the metadata of their tokens
+func (h handler) export(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "export")
+
+	level.Debug(l).Log("message", "validating authorization header for export")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "exporting projects")
+	resp, err := h.exportProjects(ctx, l, cp)
+	if err != nil {
+		level.Error(l).Log("message", "error exporting projects", "error", err)
+		h.errorResponse(w, "error exporting projects", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+}
+
+// exportProjects returns the projects of the credentials provider and the
+// database. Projects missing from the credentials provider are exported
+// without targets so they can still be recreated. Projects missing from the
+// database are skipped, as they can't be recreated without their repository.
+func (h handler) exportProjects(ctx context.Context, l log.Logger, cp credentials.Provider) (responses.Export, error) {
+	resp := responses.Export{
+		Projects: []types.ExportProject{},
+		Version:  types.ExportVersion,
+	}
+
+	cpProjects, err := cp.ListProjects(ctx)
+	if err != nil {
+		return resp, fmt.Errorf("unable to list projects from credentials provider: %w", err)
+	}
+
+	dbProjects, _, err := h.dbClient.ListProjectEntries(ctx, db.ProjectFilter{})
+	if err != nil {
+		return resp, fmt.Errorf("unable to list projects from database: %w", err)
+	}
+
+	inCP := map[string]bool{}
+	for _, p := range cpProjects {
+		inCP[p] = true
+	}
+
+	entries := map[string]db.ProjectEntry{}
+	for _, pe := range dbProjects {
+		entries[pe.ProjectID] = pe
+	}
+
+	projects := []string{}
+	for p := range inCP {
+		projects = append(projects, p)
+	}
+	for p := range entries {
+		if !inCP[p] {
+			projects = append(projects, p)
+		}
+	}
+	sort.Strings(projects)
+
+	for _, project := range projects {
+		entry, ok := entries[project]
+		if !ok {
+			level.Warn(l).Log("message", "skipping export of project missing from database", "project", project)
+			resp.SkippedProjects = append(resp.SkippedProjects, project)
+			continue
+		}
+
+		p := types.ExportProject{
+			ProjectMetadata: entry.Metadata(),
+			Name:            project,
+			Repository:      entry.Repository,
+			Targets:         []types.Target{},
+			Tokens:          []types.ExportToken{},
+		}
+
+		if inCP[project] {
+			p.Targets, err = h.exportTargets(ctx, cp, project)
+			if err != nil {
+				return resp, err
+			}
+		}
+
+		tokens, err := h.dbClient.ListTokenEntries(ctx, project)
+		if err != nil {
+			return resp, fmt.Errorf("unable to list tokens of project '%s': %w", project, err)
+		}
+		for _, t := range tokens {
+			p.Tokens = append(p.Tokens, types.ExportToken{
+				CreatedAt: t.CreatedAt,
+				ExpiresAt: t.ExpiresAt,
+				Scopes:    types.TokenScopes(t.Scopes),
+				TokenID:   t.TokenID,
+			})
+		}
+
+		resp.Projects = append(resp.Projects, p)
+	}
+
+	return resp, nil
+}
+
+func (h handler) exportTargets(ctx context.Context, cp credentials.Provider, project string) ([]types.Target, error) {
+	names, err := cp.ListTargets(ctx, project)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list targets of project '%s': %w", project, err)
+	}
+	sort.Strings(names)
+
+	targets := []types.Target{}
+	for _, name := range names {
+		t, err := h.readTarget(ctx, cp, project, name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get target '%s' of project '%s': %w", name, project, err)
+		}
+
+		targets = append(targets, t.Target)
+	}
+
+	return targets, nil
+}
+
+// Imports projects and targets from an export. Tokens are not restored,
+// created projects get a new token instead.
+func (h handler) importInventory(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "import")
+
+	level.Debug(l).Log("message", "validating authorization header for import")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	dryRun, err := parseDryRun(r.URL.Query())
+	if err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	projects := []string{}
+	if v := r.URL.Query().Get("projects"); v != "" {
+		projects = strings.Split(v, ",")
+	}
+
+	var req requests.Import
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request body", "error", err)
+		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(reqBody, &req); err != nil {
+		level.Error(l).Log("message", "error decoding request", "error", err)
+		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(req.ValidateProjects(projects)); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	spec, err := req.Apply(projects)
+	if err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	l = log.With(l, "dryRun", dryRun)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	h.runApply(ctx, l, w, cp, a.Provider, spec, dryRun)
+}
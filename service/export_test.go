@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+	th "github.com/cello-proj/cello/service/test/testhelpers"
+)
+
+func TestExport(t *testing.T) {
+	// project2 is missing from the database and project3 is missing from the
+	// credentials provider.
+	cpMock, dbMock := applyMocks(
+		map[string][]types.Target{
+			"project1": {
+				applyTarget("target1", "target1", "us-west-2"),
+				applyTarget("target2", "target2", ""),
+			},
+			"project2": {},
+		},
+		[]db.ProjectEntry{
+			{ProjectID: "project1", Repository: "git@github.com:myorg/project1.git", Team: "platform", Labels: db.ProjectLabels{"tier": "1"}},
+			{ProjectID: "project3", Repository: "git@github.com:myorg/project3.git"},
+		},
+	)
+	dbMock.ListTokenEntriesFunc = func(ctx context.Context, project string) ([]db.TokenEntry, error) {
+		if project != "project1" {
+			return []db.TokenEntry{}, nil
+		}
+		return []db.TokenEntry{
+			{
+				CreatedAt: "2022-06-21T14:56:10.341066-07:00",
+				ExpiresAt: "2023-06-21T14:56:10.341066-07:00",
+				ProjectID: "project1",
+				Scopes:    db.TokenScopes{Operations: []string{"diff"}},
+				TokenID:   "secret-id-accessor",
+			},
+		}, nil
+	}
+
+	failingCP, failingDB := applyMocks(map[string][]types.Target{"project1": {}}, []db.ProjectEntry{{ProjectID: "project1", Repository: "git@github.com:myorg/project1.git"}})
+	failingDB.ListTokenEntriesFunc = func(ctx context.Context, project string) ([]db.TokenEntry, error) {
+		return nil, errors.New("error")
+	}
+
+	tests := []test{
+		{
+			name:       "fails to export when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestExport/fails_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/admin/export",
+			method:     "GET",
+		},
+		{
+			name:       "can export",
+			want:       http.StatusOK,
+			respFile:   "TestExport/export_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/export",
+			method:     "GET",
+			cpMock:     cpMock,
+			dbMock:     dbMock,
+		},
+		{
+			name:       "export error",
+			want:       http.StatusInternalServerError,
+			respFile:   "TestExport/export_error_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/export",
+			method:     "GET",
+			cpMock:     failingCP,
+			dbMock:     failingDB,
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestImport(t *testing.T) {
+	emptyCP, emptyDB := applyMocks(map[string][]types.Target{}, []db.ProjectEntry{})
+
+	tests := []test{
+		{
+			name:       "fails to import when not admin",
+			req:        loadJSON(t, "TestImport/import_request.json"),
+			want:       http.StatusUnauthorized,
+			respFile:   "TestImport/fails_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/admin/import",
+			method:     "POST",
+		},
+		{
+			name:       "can import",
+			req:        loadJSON(t, "TestImport/import_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestImport/import_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/import",
+			method:     "POST",
+			cpMock:     emptyCP,
+			dbMock:     emptyDB,
+		},
+		{
+			name:       "can import subset of projects",
+			req:        loadJSON(t, "TestImport/import_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestImport/import_subset_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/import?projects=project3&dry_run=true",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				ListProjectsFunc: emptyCP.ListProjectsFunc,
+			},
+			dbMock: &th.DBClientMock{
+				ListProjectEntriesFunc: emptyDB.ListProjectEntriesFunc,
+			},
+		},
+		{
+			name:       "project not in import",
+			req:        loadJSON(t, "TestImport/import_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestImport/project_not_in_import_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/import?projects=project4",
+			method:     "POST",
+		},
+		{
+			name:       "unsupported version",
+			req:        loadJSON(t, "TestImport/unsupported_version_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestImport/unsupported_version_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/import",
+			method:     "POST",
+		},
+	}
+	runTests(t, tests)
+}
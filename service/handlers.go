@@ -7,11 +7,17 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cello-proj/cello/internal/requests"
 	"github.com/cello-proj/cello/internal/responses"
 	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/internal/validations"
 	"github.com/cello-proj/cello/service/internal/credentials"
 	"github.com/cello-proj/cello/service/internal/db"
 	"github.com/cello-proj/cello/service/internal/env"
@@ -25,10 +31,6 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-const (
-	numOfTokensLimit = 2
-)
-
 // Represents a JWT token.
 type token struct {
 	Token string `json:"token"`
@@ -59,10 +61,34 @@ type handler struct {
 	gitClient              git.Client
 	env                    env.Vars
 	dbClient               db.Client
+	batches                *batchRunner
+	revoker                *tokenRevoker
 }
 
 // Service HealthCheck
 func (h *handler) healthCheck(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "health-check")
+
+	// Vault is only checked when it's configured.
+	if h.env.VaultAddress != "" && !h.vaultHealthy(r) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "Health check failed")
+		return
+	}
+
+	if err := h.dbClient.Health(r.Context()); err != nil {
+		level.Error(l).Log("message", fmt.Sprintf("received code error %s when connecting to database", err.Error()))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "Health check failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "Health check succeeded")
+}
+
+// vaultHealthy returns whether Vault is initialized and unsealed.
+func (h *handler) vaultHealthy(r *http.Request) bool {
 	vaultEndpoint := fmt.Sprintf("%s/v1/sys/health", h.env.VaultAddress)
 	l := h.requestLogger(r, "op", "health-check", "vault-endpoint", vaultEndpoint)
 
@@ -70,9 +96,7 @@ func (h *handler) healthCheck(w http.ResponseWriter, r *http.Request) {
 	response, err := http.Get(vaultEndpoint)
 	if err != nil {
 		level.Error(l).Log("message", "received error connecting to vault", "error", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		fmt.Fprintln(w, "Health check failed")
-		return
+		return false
 	}
 
 	// We don't care about the body but need to read it all and close it
@@ -87,20 +111,10 @@ func (h *handler) healthCheck(w http.ResponseWriter, r *http.Request) {
 
 	if response.StatusCode != 200 && response.StatusCode != 429 {
 		level.Error(l).Log("message", fmt.Sprintf("received code %d which is not 200 (initialized, unsealed, and active) or 429 (unsealed and standby) when connecting to vault", response.StatusCode))
-		w.WriteHeader(http.StatusServiceUnavailable)
-		fmt.Fprintln(w, "Health check failed")
-		return
+		return false
 	}
 
-	if err = h.dbClient.Health(r.Context()); err != nil {
-		level.Error(l).Log("message", fmt.Sprintf("received code error %s when connecting to database", err.Error()))
-		w.WriteHeader(http.StatusServiceUnavailable)
-		fmt.Fprintln(w, "Health check failed")
-		return
-	}
-
-	w.Header().Set("Content-Type", "text/plain")
-	fmt.Fprintln(w, "Health check succeeded")
+	return true
 }
 
 // Lists workflows
@@ -113,8 +127,12 @@ func (h handler) listWorkflows(w http.ResponseWriter, r *http.Request) {
 
 	l := h.requestLogger(r, "op", "list-workflows", "project", projectName, "target", targetName)
 
+	if !h.authorizeWorkflowRead(w, r, l, projectName, targetName) {
+		return
+	}
+
 	level.Debug(l).Log("message", "listing workflows")
-	workflowList, err := h.argo.ListStatus(h.argoCtx)
+	workflowList, err := h.argo.ListStatus(h.argoContext(r.Context()))
 	if err != nil {
 		level.Error(l).Log("message", "error listing workflows", "error", err)
 		h.errorResponse(w, "error listing workflows", http.StatusInternalServerError)
@@ -141,9 +159,9 @@ func (h handler) listWorkflows(w http.ResponseWriter, r *http.Request) {
 }
 
 // Creates workflow init params by pulling manifest from given git repo, commit sha, and code path
-func (h handler) loadCreateWorkflowRequestFromGit(repository, commitHash, path string) (requests.CreateWorkflow, error) {
-	level.Debug(h.logger).Log("message", fmt.Sprintf("retrieving manifest from repository %s at sha %s with path %s", repository, commitHash, path))
-	fileContents, err := h.gitClient.GetManifestFile(repository, commitHash, path)
+func (h handler) loadCreateWorkflowRequestFromGit(ctx context.Context, repository git.Repository, commitHash, root, path string) (requests.CreateWorkflow, error) {
+	level.Debug(h.logger).Log("message", fmt.Sprintf("retrieving manifest from repository %s at sha %s with path %s", repository.URL, commitHash, path))
+	fileContents, err := h.gitClient.GetManifestFile(ctx, repository, commitHash, root, path)
 	if err != nil {
 		return requests.CreateWorkflow{}, err
 	}
@@ -195,33 +213,47 @@ func (h handler) createWorkflowFromGit(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	projectName := vars["projectName"]
-	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
-	if err != nil {
-		level.Error(l).Log("message", "error reading project data", "error", err)
-		h.errorResponse(w, "error reading project data", http.StatusInternalServerError)
+	targetName := vars["targetName"]
+
+	op, l, ok := h.loadGitOperation(ctx, l, w, r, a, projectName, cgwr)
+	if !ok {
+		return
+	}
+	cwr, werr := h.resolveManifest(ctx, l, op, targetName)
+	if werr != nil {
+		h.errorResponse(w, werr.message, werr.status)
 		return
 	}
 
-	cwr, err := h.loadCreateWorkflowRequestFromGit(projectEntry.Repository, cgwr.CommitHash, cgwr.Path)
+	requireSigned, err := h.requiresSignedCommit(ctx, op.projectEntry, targetName, cwr.Type)
 	if err != nil {
-		level.Error(l).Log("message", "error loading workflow data from git", "error", err)
-		h.errorResponse(w, "error loading workflow data from git", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading target data", "error", err)
+		h.errorResponse(w, "error reading target data", http.StatusInternalServerError)
 		return
 	}
 
+	var signature git.Signature
+	if requireSigned {
+		if signature, ok = h.verifySignedCommit(ctx, l, w, op); !ok {
+			return
+		}
+		l = log.With(l, "signature-key", signature.KeyID)
+	}
+
 	log.With(l, "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type, "workflow-template", cwr.WorkflowTemplateName)
 
 	level.Debug(l).Log("message", "creating workflow")
-	h.createWorkflowFromRequest(ctx, w, r, a, cwr, l)
+	h.createWorkflowFromRequest(ctx, w, r, a, cwr, op.commitHash, signature, l)
 }
 
-// Creates a workflow
-func (h handler) createWorkflow(w http.ResponseWriter, r *http.Request) {
-	l := h.requestLogger(r, "op", "create-workflow")
+// previewWorkflowFromGit returns the manifest of the request resolved for the
+// target, without creating a workflow.
+func (h handler) previewWorkflowFromGit(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "preview-workflow-from-git")
 
 	ctx := r.Context()
 
-	level.Debug(l).Log("message", "validating authorization header for create workflow")
+	level.Debug(l).Log("message", "validating authorization header for preview workflow from git")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
 	if err != nil {
@@ -233,315 +265,1276 @@ func (h handler) createWorkflow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	level.Debug(l).Log("message", "reading request body")
-	var cwr requests.CreateWorkflow
-	reqBody, err := io.ReadAll(r.Body)
-	if err != nil {
-		level.Error(l).Log("message", "error reading workflow request data", "error", err)
-		h.errorResponse(w, "error reading workflow request data", http.StatusInternalServerError)
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+	targetName := vars["targetName"]
+
+	if !h.authorizeWorkflowRead(w, r, l, projectName, targetName) {
 		return
 	}
 
-	if err := json.Unmarshal(reqBody, &cwr); err != nil {
-		level.Error(l).Log("message", "error deserializing workflow data", "error", err)
-		h.errorResponse(w, "error deserializing workflow data", http.StatusBadRequest)
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
 		return
 	}
 
-	log.With(l, "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type, "workflow-template", cwr.WorkflowTemplateName)
-	level.Debug(l).Log("message", "creating workflow")
-	h.createWorkflowFromRequest(ctx, w, r, a, cwr, l)
-}
-
-// Creates a workflow
-// Context is not currently used as Argo has its own and Vault doesn't
-// currently support it.
-func (h handler) createWorkflowFromRequest(_ context.Context, w http.ResponseWriter, r *http.Request, a *credentials.Authorization, cwr requests.CreateWorkflow, l log.Logger) {
-	types, err := h.config.listTypes(cwr.Framework)
+	var cgwr requests.CreateGitWorkflow
+	err = json.Unmarshal(reqBody, &cgwr)
 	if err != nil {
-		level.Error(l).Log("message", "error invalid framework", "error", err)
-		h.errorResponse(
-			w,
-			fmt.Sprintf("invalid request, framework must be one of '%s'", strings.Join(h.config.listFrameworks(), " ")),
-			http.StatusBadRequest,
-		)
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
 		return
 	}
 
-	level.Debug(l).Log("message", "validating workflow parameters")
-	if err := cwr.Validate(
-		cwr.ValidateType(types),
-	); err != nil {
+	if err := cgwr.Validate(); err != nil {
 		level.Error(l).Log("message", "error validating request", "error", err)
-		h.errorResponse(w, fmt.Sprintf("error invalid request, %s", err), http.StatusBadRequest)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
 		return
 	}
 
-	workflowFrom := fmt.Sprintf("workflowtemplate/%s", cwr.WorkflowTemplateName)
-	executeContainerImageURI := cwr.Parameters["execute_container_image_uri"]
-	environmentVariablesString := generateEnvVariablesString(cwr.EnvironmentVariables)
-
-	level.Debug(l).Log("message", "generating command to execute")
-	commandDefinition, err := h.config.getCommandDefinition(cwr.Framework, cwr.Type)
-	if err != nil {
-		level.Error(l).Log("message", "unable to get command definition", "error", err)
-		h.errorResponse(w, "unable to retrieve command definition", http.StatusInternalServerError)
+	op, l, ok := h.loadGitOperation(ctx, l, w, r, a, projectName, cgwr)
+	if !ok {
 		return
 	}
-	executeCommand, err := generateExecuteCommand(commandDefinition, environmentVariablesString, cwr.Arguments)
-	if err != nil {
-		level.Error(l).Log("message", "unable to generate command", "error", err)
-		h.errorResponse(w, "unable to generate command", http.StatusInternalServerError)
+	cwr, werr := h.resolveManifest(ctx, l, op, targetName)
+	if werr != nil {
+		h.errorResponse(w, werr.message, werr.status)
 		return
 	}
 
-	level.Debug(l).Log("message", "creating new credentials provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	data, err := json.Marshal(responses.PreviewOperation{Manifest: cwr, SHA: op.commitHash})
 	if err != nil {
-		level.Error(l).Log("message", "bad or unknown credentials provider", "error", err)
-		h.errorResponse(w, "bad or unknown credentials provider", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error serializing preview", "error", err)
+		h.errorResponse(w, "error serializing preview", http.StatusInternalServerError)
 		return
 	}
+	fmt.Fprint(w, string(data))
+}
 
-	level.Debug(l).Log("message", "getting credentials provider token")
-	credentialsToken, err := cp.GetToken()
+// gitOperation is an operation of the manifest at a commit of the repository
+// of a project.
+type gitOperation struct {
+	commitHash   string
+	manifest     requests.CreateWorkflow
+	path         string
+	projectEntry db.ProjectEntry
+	projectName  string
+	repository   git.Repository
+}
+
+// loadGitOperation reads the manifest of the request from the repository of
+// the project, resolving its ref. The type of the request overrides the type
+// of the manifest. Errors are written to the response and false returned.
+func (h handler) loadGitOperation(ctx context.Context, l log.Logger, w http.ResponseWriter, r *http.Request, a *credentials.Authorization, projectName string, cgwr requests.CreateGitWorkflow) (gitOperation, log.Logger, bool) {
+	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
 	if err != nil {
-		level.Error(l).Log("message", "error getting credentials provider token", "error", err)
-		h.errorResponse(w, "error retrieving credentials provider token", http.StatusInternalServerError)
-		return
+		level.Error(l).Log("message", "error reading project data", "error", err)
+		h.errorResponse(w, "error reading project data", http.StatusInternalServerError)
+		return gitOperation{}, l, false
+	}
+
+	if !inManifestRoot(projectEntry.ManifestRoot, cgwr.Path) {
+		level.Error(l).Log("message", "error path is outside the manifest root", "path", cgwr.Path, "manifestRoot", projectEntry.ManifestRoot)
+		h.errorResponse(w, fmt.Sprintf("invalid request, path must be in the manifest root '%s'", projectEntry.ManifestRoot), http.StatusBadRequest)
+		return gitOperation{}, l, false
 	}
 
-	projectExists, err := cp.ProjectExists(cwr.ProjectName)
+	repository, err := h.projectRepository(ctx, r, a, projectEntry)
 	if err != nil {
-		level.Error(l).Log("message", "error checking project", "error", err)
-		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
-		return
+		level.Error(l).Log("message", "error reading git credentials", "error", err)
+		h.errorResponse(w, "error reading git credentials", http.StatusInternalServerError)
+		return gitOperation{}, l, false
 	}
 
-	if !projectExists {
-		level.Error(l).Log("message", "project does not exist", "error", err)
-		h.errorResponse(w, "project does not exist", http.StatusBadRequest)
-		return
+	// Abbreviated shas are resolved to the full sha of their commit.
+	commitHash := cgwr.CommitHash
+	ref, refField := cgwr.Ref, "ref"
+	if ref == "" && !validations.IsFullCommitSHA(commitHash) {
+		ref, refField = commitHash, "sha"
 	}
+	if ref != "" {
+		level.Debug(l).Log("message", "resolving git ref", "ref", ref)
+		commitHash, err = h.gitClient.ResolveRef(ctx, repository, ref)
+		if err != nil {
+			level.Error(l).Log("message", "error resolving git ref", "error", err)
+			if errors.Is(err, git.ErrRefNotFound) {
+				h.errorResponse(w, fmt.Sprintf("invalid request, %s '%s' not found", refField, ref), http.StatusBadRequest)
+			} else if errors.Is(err, git.ErrAmbiguousRef) {
+				h.errorResponse(w, fmt.Sprintf("invalid request, %s '%s' matches several commits", refField, ref), http.StatusBadRequest)
+			} else {
+				h.errorResponse(w, "error resolving git ref", http.StatusInternalServerError)
+			}
+			return gitOperation{}, l, false
+		}
+	}
+	l = log.With(l, "sha", commitHash)
 
-	targetExists, err := cp.TargetExists(cwr.ProjectName, cwr.TargetName)
+	cwr, err := h.loadCreateWorkflowRequestFromGit(ctx, repository, commitHash, projectEntry.ManifestRoot, cgwr.Path)
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving target", "error", err)
-		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
-		return
+		level.Error(l).Log("message", "error loading workflow data from git", "error", err)
+		if errors.Is(err, git.ErrInvalidPath) || errors.Is(err, git.ErrManifestTooLarge) {
+			h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		} else {
+			h.errorResponse(w, "error loading workflow data from git", http.StatusInternalServerError)
+		}
+		return gitOperation{}, l, false
 	}
-	if !targetExists {
-		level.Error(l).Log("message", "target not found")
-		h.errorResponse(w, "target not found", http.StatusBadRequest)
-		return
+
+	if cgwr.Type != "" {
+		cwr.Type = cgwr.Type
 	}
 
-	level.Debug(l).Log("message", "creating workflow parameters")
-	parameters := workflow.NewParameters(environmentVariablesString, executeCommand, executeContainerImageURI, cwr.TargetName, cwr.ProjectName, cwr.Parameters, credentialsToken, cwr.Type)
+	return gitOperation{
+		commitHash:   commitHash,
+		manifest:     cwr,
+		path:         cgwr.Path,
+		projectEntry: projectEntry,
+		projectName:  projectName,
+		repository:   repository,
+	}, l, true
+}
 
-	workflowLabels := map[string]string{txIDHeader: r.Header.Get(txIDHeader)}
+// resolveManifest returns the workflow of the manifest of the operation for
+// the target. The overrides of the manifest target matching it and the
+// overlays matching it are applied, and the manifest variables interpolated,
+// before the workflow is validated.
+func (h handler) resolveManifest(ctx context.Context, l log.Logger, op gitOperation, targetName string) (requests.CreateWorkflow, *workflowError) {
+	cwr := op.manifest
+
+	// Manifests with targets run the workflow of the target with its
+	// overrides.
+	if len(cwr.Targets) > 0 {
+		if err := cwr.ValidateTargets(); err != nil {
+			level.Error(l).Log("message", "error invalid manifest targets", "error", err)
+			return requests.CreateWorkflow{}, &workflowError{err: err, message: fmt.Sprintf("invalid request, %s", err), status: http.StatusBadRequest}
+		}
 
-	level.Debug(l).Log("message", "creating workflow")
-	workflowName, err := h.argo.Submit(h.argoCtx, workflowFrom, parameters, workflowLabels)
-	if err != nil {
-		level.Error(l).Log("message", "error creating workflow", "error", err)
-		h.errorResponse(w, "error creating workflow", http.StatusInternalServerError)
-		return
+		mt, ok := cwr.MatchTarget(targetName)
+		if !ok {
+			level.Error(l).Log("message", "error manifest targets do not include the target")
+			return requests.CreateWorkflow{}, &workflowError{
+				err:     errors.New("manifest targets do not include the target"),
+				message: fmt.Sprintf("invalid request, manifest targets do not include target '%s'", targetName),
+				status:  http.StatusBadRequest,
+			}
+		}
+		cwr = cwr.ForTarget(mt, targetName)
 	}
 
-	l = log.With(l, "workflow", workflowName)
-	level.Debug(l).Log("message", "workflow created")
-	tokenHead := credentialsToken[0:8]
+	if err := cwr.ValidateOverlays(); err != nil {
+		level.Error(l).Log("message", "error invalid manifest overlays", "error", err)
+		return requests.CreateWorkflow{}, &workflowError{err: err, message: fmt.Sprintf("invalid request, %s", err), status: http.StatusBadRequest}
+	}
+	cwr = cwr.ApplyOverlays(targetName)
 
-	level.Info(l).Log("message", fmt.Sprintf("Received token '%s...'", tokenHead))
-	var cwresp workflow.CreateWorkflowResponse
-	cwresp.WorkflowName = workflowName
-	jsonData, err := json.Marshal(cwresp)
+	// The project and target of the route are authoritative, so manifests
+	// can't run operations against other targets.
+	if err := cwr.SetTarget(op.projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error manifest does not match the target", "error", err)
+		return requests.CreateWorkflow{}, &workflowError{err: err, message: fmt.Sprintf("invalid request, %s", err), status: http.StatusBadRequest}
+	}
+
+	vars, err := h.manifestVariables(ctx, op, targetName)
 	if err != nil {
-		level.Error(l).Log("message", "error serializing workflow response", "error", err)
-		h.errorResponse(w, "error serializing workflow response", http.StatusInternalServerError)
-		return
+		level.Error(l).Log("message", "error reading target data", "error", err)
+		return requests.CreateWorkflow{}, &workflowError{err: err, message: "error reading target data", status: http.StatusInternalServerError}
 	}
-	fmt.Fprintln(w, string(jsonData))
-}
 
-// Gets a workflow
-func (h handler) getWorkflow(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	workflowName := vars["workflowName"]
-	l := h.requestLogger(r, "op", "get-workflow", "workflow", workflowName)
+	cwr, err = cwr.Interpolate(vars)
+	if err != nil {
+		level.Error(l).Log("message", "error interpolating manifest variables", "error", err)
+		return requests.CreateWorkflow{}, &workflowError{err: err, message: fmt.Sprintf("invalid request, %s", err), status: http.StatusBadRequest}
+	}
 
-	level.Debug(l).Log("message", "getting workflow status")
-	status, err := h.argo.Status(h.argoCtx, workflowName)
+	return cwr, nil
+}
+
+// manifestVariables returns the variables manifests of the operation can
+// reference for the target. The properties of targets in the inventory are
+// 'target.<property>' variables.
+func (h handler) manifestVariables(ctx context.Context, op gitOperation, targetName string) (map[string]string, error) {
+	vars := map[string]string{
+		"path":    op.path,
+		"project": op.projectName,
+		"sha":     op.commitHash,
+		"target":  targetName,
+	}
 
+	te, err := h.dbClient.ReadTargetEntry(ctx, op.projectName, targetName)
 	if err != nil {
-		if strings.Contains(err.Error(), "code = NotFound") {
-			level.Error(l).Log("message", "error getting workflow", "error", err)
-			h.errorResponse(w, "workflow not found", http.StatusNotFound)
-		} else {
-			level.Error(l).Log("message", "error getting workflow", "error", err)
-			h.errorResponse(w, "error getting workflow", http.StatusInternalServerError)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			return vars, nil
 		}
+		return nil, err
+	}
 
-		return
+	// Entries which haven't been imported only hold the region.
+	region := te.Region
+	if region == "" {
+		region = te.Properties.Region
 	}
 
-	level.Debug(l).Log("message", "decoding get workflow response")
-	jsonData, err := json.Marshal(status)
-	if err != nil {
-		level.Error(l).Log("message", "error serializing workflow", "error", err)
-		h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
-		return
+	for k, v := range map[string]string{
+		"credential_type": te.Properties.CredentialType,
+		"owner":           te.Owner,
+		"region":          region,
+		"role_arn":        te.Properties.RoleArn,
+		"tier":            te.Tier,
+		"type":            te.Type,
+	} {
+		vars["target."+k] = v
 	}
 
-	fmt.Fprint(w, string(jsonData))
+	return vars, nil
 }
 
-// Gets a target
-func (h handler) getTarget(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	projectName := vars["projectName"]
-	targetName := vars["targetName"]
-
-	l := h.requestLogger(r, "op", "get-target", "project", projectName, "target", targetName)
-
-	level.Debug(l).Log("message", "validating authorization header for get target")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
-	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
-		return
-	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
-		return
+// verifySignedCommit verifies the signature of the commit of the operation.
+// Errors are written to the response and false returned.
+func (h handler) verifySignedCommit(ctx context.Context, l log.Logger, w http.ResponseWriter, op gitOperation) (git.Signature, bool) {
+	level.Debug(l).Log("message", "verifying commit signature")
+	signature, err := h.gitClient.VerifyCommit(ctx, op.repository, op.commitHash)
+	if err != nil {
+		level.Error(l).Log("message", "error verifying commit signature", "error", err)
+		switch {
+		case errors.Is(err, git.ErrUnsignedCommit):
+			h.errorResponse(w, fmt.Sprintf("error forbidden, commit %s is not signed", op.commitHash), http.StatusForbidden)
+		case errors.Is(err, git.ErrUntrustedSignature):
+			h.errorResponse(w, fmt.Sprintf("error forbidden, commit %s is not signed by a trusted key", op.commitHash), http.StatusForbidden)
+		default:
+			h.errorResponse(w, "error verifying commit signature", http.StatusInternalServerError)
+		}
+		return git.Signature{}, false
 	}
+	return signature, true
+}
 
-	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
-	if err != nil {
-		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
-		return
+// requiresSignedCommit returns whether the project or the target require
+// signed commits for the operation type. Targets without entries only use
+// the policy of the project.
+func (h handler) requiresSignedCommit(ctx context.Context, pe db.ProjectEntry, targetName, operation string) (bool, error) {
+	if types.SignedCommitPolicy(pe.RequireSignedCommits).Requires(operation) {
+		return true, nil
 	}
 
-	targetExists, err := cp.TargetExists(projectName, targetName)
+	te, err := h.dbClient.ReadTargetEntry(ctx, pe.ProjectID, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return false, nil
+	}
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving target", "error", err)
-		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
-		return
+		return false, err
 	}
 
-	if !targetExists {
-		level.Error(l).Log("message", "target not found")
-		h.errorResponse(w, "target not found", http.StatusNotFound)
-		return
-	}
+	return types.SignedCommitPolicy(te.RequireSignedCommits).Requires(operation), nil
+}
 
-	level.Debug(l).Log("message", "getting target information")
-	targetInfo, err := cp.GetTarget(projectName, targetName)
+// rejectSignedCommitOperation returns an error when the project or the target
+// of the workflow require signed commits for its operation type. Projects
+// without entries only use the policy of the target.
+func (h handler) rejectSignedCommitOperation(ctx context.Context, l log.Logger, cwr requests.CreateWorkflow) *workflowError {
+	pe, err := h.dbClient.ReadProjectEntry(ctx, cwr.ProjectName)
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving target information", "error", err)
-		h.errorResponse(w, "error retrieving target information", http.StatusInternalServerError)
-		return
+		if !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Error(l).Log("message", "error reading project data", "error", err)
+			return &workflowError{err: err, message: "error reading project data", status: http.StatusInternalServerError}
+		}
+		pe = db.ProjectEntry{ProjectID: cwr.ProjectName}
 	}
 
-	jsonResult, err := json.Marshal(targetInfo)
+	requireSigned, err := h.requiresSignedCommit(ctx, pe, cwr.TargetName, cwr.Type)
 	if err != nil {
-		level.Error(l).Log("message", "error serializing json target data", "error", err)
-		h.errorResponse(w, "error serializing json target data", http.StatusInternalServerError)
-		return
+		level.Error(l).Log("message", "error reading target data", "error", err)
+		return &workflowError{err: err, message: "error reading target data", status: http.StatusInternalServerError}
 	}
 
-	fmt.Fprint(w, string(jsonResult))
+	if requireSigned {
+		level.Error(l).Log("message", "error operation requires a signed commit")
+		return &workflowError{
+			err:     errors.New("operation requires a signed commit"),
+			message: fmt.Sprintf("error forbidden, %s operations of target %s require a signed commit from git", cwr.Type, cwr.TargetName),
+			status:  http.StatusForbidden,
+		}
+	}
+	return nil
 }
 
-// Returns the logs for a workflow
-func (h handler) getWorkflowLogs(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	workflowName := vars["workflowName"]
+// inManifestRoot returns whether the path is in the manifest root of a
+// project. Any path of the repository is in an empty root.
+func inManifestRoot(root, path string) bool {
+	if root == "" {
+		return true
+	}
+	return validations.IsInRepositoryRoot(root, path)
+}
 
-	l := h.requestLogger(r, "op", "get-workflow-logs", "workflow", workflowName)
+// projectRepository returns the repository of the project. The git
+// credentials of the project are read when it has any, otherwise the
+// repository is accessed with the global git credentials.
+func (h handler) projectRepository(ctx context.Context, r *http.Request, a *credentials.Authorization, pe db.ProjectEntry) (git.Repository, error) {
+	repository := git.Repository{URL: pe.Repository}
+	if pe.GitCredentials == "" {
+		return repository, nil
+	}
 
-	level.Debug(l).Log("message", "retrieving workflow logs")
-	argoWorkflowLogs, err := h.argo.Logs(h.argoCtx, workflowName)
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
 	if err != nil {
-		level.Error(l).Log("message", "error getting workflow logs", "error", err)
-		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
-		return
+		return git.Repository{}, err
 	}
 
-	jsonData, err := json.Marshal(argoWorkflowLogs)
+	gcp, ok := cp.(credentials.GitCredentialsProvider)
+	if !ok {
+		return git.Repository{}, errors.New("credentials provider does not support git credentials")
+	}
+
+	repository.Credentials, err = gcp.ReadGitCredentials(ctx, pe.GitCredentials)
 	if err != nil {
-		level.Error(l).Log("message", "error serializing workflow logs", "error", err)
-		h.errorResponse(w, "error serializing workflow logs", http.StatusInternalServerError)
-		return
+		return git.Repository{}, err
 	}
-	fmt.Fprintln(w, string(jsonData))
+	return repository, nil
 }
 
-// Streams workflow logs
-func (h handler) getWorkflowLogStream(w http.ResponseWriter, r *http.Request) {
+// Creates a workflow
+func (h handler) createWorkflow(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "create-workflow")
+
+	ctx := r.Context()
+
+	level.Debug(l).Log("message", "validating authorization header for create workflow")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	var cwr requests.CreateWorkflow
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading workflow request data", "error", err)
+		h.errorResponse(w, "error reading workflow request data", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.Unmarshal(reqBody, &cwr); err != nil {
+		level.Error(l).Log("message", "error deserializing workflow data", "error", err)
+		h.errorResponse(w, "error deserializing workflow data", http.StatusBadRequest)
+		return
+	}
+
+	if len(cwr.Targets) > 0 {
+		level.Error(l).Log("message", "error targets are only supported by manifests")
+		h.errorResponse(w, "invalid request, targets are only supported by manifests in git", http.StatusBadRequest)
+		return
+	}
+
+	log.With(l, "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type, "workflow-template", cwr.WorkflowTemplateName)
+	level.Debug(l).Log("message", "creating workflow")
+	h.createWorkflowFromRequest(ctx, w, r, a, cwr, "", git.Signature{}, l)
+}
+
+// workflowError is an error creating a workflow, with the response to return
+// for it.
+type workflowError struct {
+	err     error
+	message string
+	status  int
+}
+
+func (e *workflowError) Error() string {
+	return fmt.Sprintf("%s: %v", e.message, e.err)
+}
+
+// Creates a workflow. The commit hash of workflows created from git is
+// recorded in the workflow labels and returned.
+func (h handler) createWorkflowFromRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, a *credentials.Authorization, cwr requests.CreateWorkflow, commitHash string, signature git.Signature, l log.Logger) {
+	level.Debug(l).Log("message", "creating new credentials provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "bad or unknown credentials provider", "error", err)
+		h.errorResponse(w, "bad or unknown credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	if werr := h.validateWorkflow(ctx, l, cp, cwr); werr != nil {
+		h.errorResponse(w, werr.message, werr.status)
+		return
+	}
+
+	// Workflows not created from git have no commit proving they were
+	// signed, so they can't run operations requiring signed commits.
+	if commitHash == "" {
+		if werr := h.rejectSignedCommitOperation(ctx, l, cwr); werr != nil {
+			h.errorResponse(w, werr.message, werr.status)
+			return
+		}
+	}
+
+	workflowLabels := map[string]string{txIDHeader: r.Header.Get(txIDHeader)}
+	if commitHash != "" {
+		workflowLabels[gitSHALabel] = commitHash
+	}
+	if signature.KeyID != "" {
+		workflowLabels[gitSignatureTypeLabel] = signature.Type
+		workflowLabels[gitSignatureKeyLabel] = signature.KeyID
+	}
+
+	workflowName, werr := h.submitWorkflow(ctx, l, cp, cwr, workflowLabels)
+	if werr != nil {
+		h.errorResponse(w, werr.message, werr.status)
+		return
+	}
+
+	var cwresp workflow.CreateWorkflowResponse
+	cwresp.WorkflowName = workflowName
+	cwresp.SHA = commitHash
+	jsonData, err := json.Marshal(cwresp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow response", "error", err)
+		h.errorResponse(w, "error serializing workflow response", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, string(jsonData))
+}
+
+// validateWorkflow validates the workflow, and that its project and target
+// exist and the token is allowed to run it.
+func (h handler) validateWorkflow(ctx context.Context, l log.Logger, cp credentials.Provider, cwr requests.CreateWorkflow) *workflowError {
+	types, err := h.config.listTypes(cwr.Framework)
+	if err != nil {
+		level.Error(l).Log("message", "error invalid framework", "error", err)
+		return &workflowError{
+			err:     err,
+			message: fmt.Sprintf("invalid request, framework must be one of '%s'", strings.Join(h.config.listFrameworks(), " ")),
+			status:  http.StatusBadRequest,
+		}
+	}
+
+	level.Debug(l).Log("message", "validating workflow parameters")
+	if err := cwr.Validate(
+		cwr.ValidateType(types),
+	); err != nil {
+		level.Error(l).Log("message", "error validating request", "error", err)
+		return &workflowError{err: err, message: fmt.Sprintf("error invalid request, %s", err), status: http.StatusBadRequest}
+	}
+
+	projectExists, err := cp.ProjectExists(ctx, cwr.ProjectName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		return &workflowError{err: err, message: "error checking project", status: http.StatusInternalServerError}
+	}
+
+	if !projectExists {
+		level.Error(l).Log("message", "project does not exist", "error", err)
+		return &workflowError{err: errors.New("project does not exist"), message: "project does not exist", status: http.StatusBadRequest}
+	}
+
+	targetExists, err := cp.TargetExists(ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		return &workflowError{err: err, message: "error retrieving target", status: http.StatusInternalServerError}
+	}
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		return &workflowError{err: errors.New("target not found"), message: "target not found", status: http.StatusBadRequest}
+	}
+
+	level.Debug(l).Log("message", "checking token scopes")
+	scopes, err := h.readTokenScopes(ctx, cp, cwr.ProjectName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving token scopes", "error", err)
+		if errors.Is(err, credentials.ErrProjectTokenNotFound) {
+			return &workflowError{err: err, message: "error unauthorized, token is not valid for project", status: http.StatusUnauthorized}
+		}
+		return &workflowError{err: err, message: "error retrieving token scopes", status: http.StatusInternalServerError}
+	}
+
+	if !scopes.AllowsOperation(cwr.Type) || !scopes.AllowsTarget(cwr.TargetName) {
+		level.Error(l).Log("message", "token scopes do not allow operation", "type", cwr.Type)
+		return &workflowError{
+			err:     errors.New("token scopes do not allow operation"),
+			message: fmt.Sprintf("error forbidden, token is not allowed to run '%s' on target '%s'", cwr.Type, cwr.TargetName),
+			status:  http.StatusForbidden,
+		}
+	}
+
+	return nil
+}
+
+// submitWorkflow submits the validated workflow with the labels, and returns
+// its name.
+func (h handler) submitWorkflow(ctx context.Context, l log.Logger, cp credentials.Provider, cwr requests.CreateWorkflow, workflowLabels map[string]string) (string, *workflowError) {
+	level.Debug(l).Log("message", "getting credentials provider token")
+	credentialsToken, err := cp.GetToken(ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		level.Error(l).Log("message", "error getting credentials provider token", "error", err)
+		return "", &workflowError{err: err, message: "error retrieving credentials provider token", status: http.StatusInternalServerError}
+	}
+
+	level.Debug(l).Log("message", "retrieving target region")
+	region, err := h.readTargetRegion(ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target region", "error", err)
+		return "", &workflowError{err: err, message: "error retrieving target", status: http.StatusInternalServerError}
+	}
+
+	workflowFrom := fmt.Sprintf("workflowtemplate/%s", cwr.WorkflowTemplateName)
+	executeContainerImageURI := cwr.Parameters["execute_container_image_uri"]
+	environmentVariablesString := generateEnvVariablesString(addRegionEnvVariables(cwr.EnvironmentVariables, region))
+
+	level.Debug(l).Log("message", "generating command to execute")
+	commandDefinition, err := h.config.getCommandDefinition(cwr.Framework, cwr.Type)
+	if err != nil {
+		level.Error(l).Log("message", "unable to get command definition", "error", err)
+		return "", &workflowError{err: err, message: "unable to retrieve command definition", status: http.StatusInternalServerError}
+	}
+	executeCommand, err := generateExecuteCommand(commandDefinition, environmentVariablesString, cwr.Arguments)
+	if err != nil {
+		level.Error(l).Log("message", "unable to generate command", "error", err)
+		return "", &workflowError{err: err, message: "unable to generate command", status: http.StatusInternalServerError}
+	}
+
+	level.Debug(l).Log("message", "creating workflow parameters")
+	parameters := workflow.NewParameters(environmentVariablesString, executeCommand, executeContainerImageURI, cwr.TargetName, cwr.ProjectName, cwr.Parameters, credentialsToken, cwr.Type)
+
+	level.Debug(l).Log("message", "creating workflow")
+	workflowName, err := h.argo.Submit(h.argoContext(ctx), workflowFrom, parameters, workflowLabels)
+	if err != nil {
+		level.Error(l).Log("message", "error creating workflow", "error", err)
+		return "", &workflowError{err: err, message: "error creating workflow", status: http.StatusInternalServerError}
+	}
+
+	l = log.With(l, "workflow", workflowName)
+	level.Debug(l).Log("message", "workflow created")
+	tokenHead := credentialsToken[0:8]
+
+	level.Info(l).Log("message", fmt.Sprintf("Received token '%s...'", tokenHead))
+	return workflowName, nil
+}
+
+// Gets a workflow
+func (h handler) getWorkflow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workflowName := vars["workflowName"]
+	l := h.requestLogger(r, "op", "get-workflow", "workflow", workflowName)
+
+	if !h.authorizeWorkflowReadByName(w, r, l, workflowName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "getting workflow status")
+	status, err := h.argo.Status(h.argoContext(r.Context()), workflowName)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "code = NotFound") {
+			level.Error(l).Log("message", "error getting workflow", "error", err)
+			h.errorResponse(w, "workflow not found", http.StatusNotFound)
+		} else {
+			level.Error(l).Log("message", "error getting workflow", "error", err)
+			h.errorResponse(w, "error getting workflow", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	level.Debug(l).Log("message", "decoding get workflow response")
+	jsonData, err := json.Marshal(status)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow", "error", err)
+		h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Gets a target
+func (h handler) getTarget(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+	targetName := vars["targetName"]
+
+	l := h.requestLogger(r, "op", "get-target", "project", projectName, "target", targetName)
+
+	ctx := r.Context()
+
+	level.Debug(l).Log("message", "validating authorization header for get target")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		h.errorResponse(w, "target not found", http.StatusNotFound)
+		return
+	}
+
+	level.Debug(l).Log("message", "getting target information")
+	targetInfo, err := h.readTarget(ctx, cp, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target information", "error", err)
+		h.errorResponse(w, "error retrieving target information", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResult, err := json.Marshal(targetInfo)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing json target data", "error", err)
+		h.errorResponse(w, "error serializing json target data", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(jsonResult))
+}
+
+// Returns the credentials of a target to a workflow, for providers which
+// issue them
+func (h handler) getTargetCredentials(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+	targetName := vars["targetName"]
+
+	l := h.requestLogger(r, "op", "get-target-credentials", "project", projectName, "target", targetName)
+
+	ctx := r.Context()
+
+	level.Debug(l).Log("message", "validating authorization header for get target credentials")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	tcp, ok := cp.(credentials.TargetCredentialsProvider)
+	if !ok {
+		level.Error(l).Log("message", "credentials provider does not issue target credentials", "provider", a.Provider)
+		h.errorResponse(w, "credentials provider does not issue target credentials", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "getting target credentials")
+	creds, err := tcp.GetTargetCredentials(ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target credentials", "error", err)
+		switch {
+		case errors.Is(err, credentials.ErrProjectTokenNotFound):
+			h.errorResponse(w, "error unauthorized, invalid credentials token", http.StatusUnauthorized)
+		case errors.Is(err, credentials.ErrTargetNotFound):
+			h.errorResponse(w, "target not found", http.StatusNotFound)
+		default:
+			h.errorResponse(w, "error retrieving target credentials", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(responses.GetTargetCredentials(creds)); err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Returns the logs for a workflow
+func (h handler) getWorkflowLogs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workflowName := vars["workflowName"]
+
+	l := h.requestLogger(r, "op", "get-workflow-logs", "workflow", workflowName)
+
+	if !h.authorizeWorkflowReadByName(w, r, l, workflowName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "retrieving workflow logs")
+	argoWorkflowLogs, err := h.argo.Logs(h.argoContext(r.Context()), workflowName)
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow logs", "error", err)
+		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(argoWorkflowLogs)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow logs", "error", err)
+		h.errorResponse(w, "error serializing workflow logs", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, string(jsonData))
+}
+
+// Streams workflow logs
+func (h handler) getWorkflowLogStream(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.Header().Set("X-Accel-Buffering", "no")
 	vars := mux.Vars(r)
 	workflowName := vars["workflowName"]
 
-	l := h.requestLogger(r, "op", "get-workflow-log-stream", "workflow", workflowName)
+	l := h.requestLogger(r, "op", "get-workflow-log-stream", "workflow", workflowName)
+
+	if !h.authorizeWorkflowReadByName(w, r, l, workflowName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "retrieving workflow logs", "workflow", workflowName)
+	err := h.argo.LogStream(h.argoContext(r.Context()), workflowName, w)
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow logstream", "error", err)
+		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Returns a new Cello token
+func newCelloToken(provider string, tok types.Token) *token {
+	return &token{
+		Token: fmt.Sprintf("%s:%s:%s", provider, tok.RoleID, tok.Secret),
+	}
+}
+
+// projectExists checks if a project exists using both the credential provider and database
+func (h handler) projectExists(ctx context.Context, l log.Logger, cp credentials.Provider, w http.ResponseWriter, projectName string) (bool, error) {
+	// Checking credential provider
+	level.Debug(l).Log("message", "checking if project exists")
+	projectExists, err := cp.ProjectExists(ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking credentials provider for project", "error", err)
+		h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
+		return false, err
+	}
+
+	if !projectExists {
+		level.Debug(l).Log("message", "project does not exist in credentials provider")
+		h.errorResponse(w, "project does not exist", http.StatusNotFound)
+		return false, err
+	}
+
+	// Checking database
+	_, err = h.dbClient.ReadProjectEntry(ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving project from database", "error", err)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "project does not exist", http.StatusNotFound)
+		} else {
+			h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
+		}
+		return false, err
+	}
+
+	return true, err
+}
+
+// readTargetRegion returns the region stored for the target. Targets without a
+// database entry have no region.
+func (h handler) readTargetRegion(ctx context.Context, projectName, targetName string) (string, error) {
+	te, err := h.dbClient.ReadTargetEntry(ctx, projectName, targetName)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return te.Region, nil
+}
+
+// readTarget returns the target from the database. Targets which haven't
+// been imported into the database yet are read from the credentials provider.
+func (h handler) readTarget(ctx context.Context, cp credentials.Provider, projectName, targetName string) (responses.GetTarget, error) {
+	te, err := h.dbClient.ReadTargetEntry(ctx, projectName, targetName)
+	if err != nil && !errors.Is(err, upper.ErrNoMoreRows) {
+		return responses.GetTarget{}, err
+	}
+
+	if te.IsImported() {
+		return responses.GetTarget{
+			Target:    te.Target(),
+			CreatedAt: te.CreatedAt,
+			UpdatedAt: te.UpdatedAt,
+		}, nil
+	}
+
+	target, err := cp.GetTarget(ctx, projectName, targetName)
+	if err != nil {
+		return responses.GetTarget{}, err
+	}
+
+	// Entries which haven't been imported only hold the region.
+	target.Properties.Region = te.Region
+	return responses.GetTarget{Target: target}, nil
+}
+
+// readTargets returns the targets of the project matching the filter ordered
+// by target. Targets which haven't been imported into the database yet are
+// read from the credentials provider, which requires admin credentials.
+func (h handler) readTargets(ctx context.Context, cp credentials.Provider, projectName string, filter db.TargetFilter) (responses.ListTargets, error) {
+	entries, err := h.dbClient.ListTargetEntries(ctx, projectName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := responses.ListTargets{}
+	listed := map[string]bool{}
+	for _, te := range entries {
+		// Entries which haven't been imported only hold the region.
+		if !te.IsImported() {
+			continue
+		}
+		targets = append(targets, responses.GetTarget{
+			Target:    te.Target(),
+			CreatedAt: te.CreatedAt,
+			UpdatedAt: te.UpdatedAt,
+		})
+		listed[te.TargetName] = true
+	}
+
+	cpTargets, err := cp.ListTargets(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range cpTargets {
+		if listed[name] {
+			continue
+		}
+
+		// Imported targets which don't match the filter are read from the
+		// database too, and filtered out below.
+		target, err := h.readTarget(ctx, cp, projectName, name)
+		if err != nil {
+			return nil, err
+		}
+		if (filter.Tier != "" && target.Tier != filter.Tier) || (filter.Type != "" && target.Type != filter.Type) {
+			continue
+		}
+		targets = append(targets, target)
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+// readTokenScopes returns the scopes of the project token used with the
+// credentials provider. Tokens without a database entry are unscoped.
+func (h handler) readTokenScopes(ctx context.Context, cp credentials.Provider, projectName string) (types.TokenScopes, error) {
+	projectToken, err := cp.LookupProjectToken(ctx, projectName)
+	if err != nil {
+		return types.TokenScopes{}, err
+	}
+
+	te, err := h.dbClient.ReadTokenEntry(ctx, projectToken.ID)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			return types.TokenScopes{}, nil
+		}
+		return types.TokenScopes{}, err
+	}
+
+	// Rotated tokens expire in the database before they are revoked.
+	if te.IsExpired(time.Now()) {
+		return types.TokenScopes{}, credentials.ErrProjectTokenNotFound
+	}
+
+	return types.TokenScopes(te.Scopes), nil
+}
+
+// authorizeWorkflowRead enforces the scopes of the project token used to read
+// workflows of the project targets. Requests with admin credentials are not
+// restricted. It writes the error response and returns false when the request
+// is not authorized.
+func (h handler) authorizeWorkflowRead(w http.ResponseWriter, r *http.Request, l log.Logger, projectName string, targetNames ...string) bool {
+	a, ok := h.readAuthorization(w, r, l)
+	if !ok {
+		return false
+	}
+	if a.Key == "admin" {
+		return true
+	}
+
+	if projectName == "" || len(targetNames) == 0 || slices.Contains(targetNames, "") {
+		level.Error(l).Log("message", "unable to determine project and target of workflow")
+		h.errorResponse(w, "workflow not found", http.StatusNotFound)
+		return false
+	}
+
+	scopes, ok := h.readProjectScopes(w, r, l, *a, projectName)
+	if !ok {
+		return false
+	}
+	return h.authorizeTargetsRead(w, l, scopes, targetNames...)
+}
+
+// readAuthorization returns the validated authorization of the request. It
+// writes the error response and returns false when the authorization header
+// is not valid.
+func (h handler) readAuthorization(w http.ResponseWriter, r *http.Request, l log.Logger) (*credentials.Authorization, bool) {
+	level.Debug(l).Log("message", "validating authorization header for reading workflows")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return nil, false
+	}
+	if err := a.Validate(); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if a.Key == "admin" {
+		if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+			h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+			return nil, false
+		}
+	}
+	return a, true
+}
+
+// readProjectScopes returns the scopes of the project token of the request.
+// It writes the error response and returns false when the token isn't valid
+// for the project.
+func (h handler) readProjectScopes(w http.ResponseWriter, r *http.Request, l log.Logger, a credentials.Authorization, projectName string) (types.TokenScopes, bool) {
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return types.TokenScopes{}, false
+	}
+
+	level.Debug(l).Log("message", "checking token scopes")
+	scopes, err := h.readTokenScopes(r.Context(), cp, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving token scopes", "error", err)
+		if errors.Is(err, credentials.ErrProjectTokenNotFound) {
+			h.errorResponse(w, "error unauthorized, token is not valid for project", http.StatusUnauthorized)
+		} else {
+			h.errorResponse(w, "error retrieving token scopes", http.StatusInternalServerError)
+		}
+		return types.TokenScopes{}, false
+	}
+	return scopes, true
+}
+
+// authorizeTargetsRead writes the error response and returns false when the
+// scopes don't allow reading workflows of any of the targets.
+func (h handler) authorizeTargetsRead(w http.ResponseWriter, l log.Logger, scopes types.TokenScopes, targetNames ...string) bool {
+	for _, targetName := range targetNames {
+		if !scopes.AllowsTarget(targetName) {
+			level.Error(l).Log("message", "token scopes do not allow target")
+			h.errorResponse(w, fmt.Sprintf("error forbidden, token is not allowed to read target '%s'", targetName), http.StatusForbidden)
+			return false
+		}
+	}
+
+	return true
+}
+
+// authorizeWorkflowReadByName is authorizeWorkflowRead for the project target
+// the workflow was created for.
+func (h handler) authorizeWorkflowReadByName(w http.ResponseWriter, r *http.Request, l log.Logger, workflowName string) bool {
+	projectName, targetName := parseWorkflowName(workflowName)
+	return h.authorizeWorkflowRead(w, r, l, projectName, targetName)
+}
 
-	level.Debug(l).Log("message", "retrieving workflow logs", "workflow", workflowName)
-	err := h.argo.LogStream(h.argoCtx, workflowName, w)
+// parseWorkflowName returns the project and target from a workflow name, which
+// is generated as '<project>-<target>-<suffix>'. Empty strings are returned
+// when the name is not in that format.
+func parseWorkflowName(workflowName string) (string, string) {
+	parts := strings.SplitN(workflowName, "-", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", ""
+	}
+
+	return parts[0], parts[1]
+}
+
+// Creates a project
+func (h handler) createProject(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "create-project")
+
+	level.Debug(l).Log("message", "validating authorization header for create project")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
 	if err != nil {
-		level.Error(l).Log("message", "error getting workflow logstream", "error", err)
-		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
 		return
 	}
-}
 
-// Returns a new Cello token
-func newCelloToken(provider string, tok types.Token) *token {
-	return &token{
-		Token: fmt.Sprintf("%s:%s:%s", provider, tok.RoleID, tok.Secret),
+	ctx := r.Context()
+
+	var capp requests.CreateProject
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request body", "error", err)
+		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(reqBody, &capp); err != nil {
+		level.Error(l).Log("message", "error decoding request", "error", err)
+		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+		return
+	}
+	if err := capp.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
 	}
-}
 
-// projectExists checks if a project exists using both the credential provider and database
-func (h handler) projectExists(ctx context.Context, l log.Logger, cp credentials.Provider, w http.ResponseWriter, projectName string) (bool, error) {
-	// Checking credential provider
-	level.Debug(l).Log("message", "checking if project exists")
-	projectExists, err := cp.ProjectExists(projectName)
+	l = log.With(l, "project", capp.Name)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
 	if err != nil {
-		level.Error(l).Log("message", "error checking credentials provider for project", "error", err)
-		h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
-		return false, err
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
 	}
 
-	if !projectExists {
-		level.Debug(l).Log("message", "project does not exist in credentials provider")
-		h.errorResponse(w, "project does not exist", http.StatusNotFound)
-		return false, err
+	projectExists, err := cp.ProjectExists(ctx, capp.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return
 	}
 
-	// Checking database
-	_, err = h.dbClient.ReadProjectEntry(ctx, projectName)
+	if projectExists {
+		level.Error(l).Log("error", "project already exists")
+		h.errorResponse(w, "project already exists", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "inserting into db")
+	err = h.dbClient.CreateProjectEntry(ctx, db.ProjectEntry{
+		Contact:              capp.Contact,
+		Description:          capp.Description,
+		Labels:               capp.Labels,
+		ManifestRoot:         capp.ManifestRoot,
+		RequireSignedCommits: db.SignedCommitPolicy(capp.RequireSignedCommits),
+		ProjectID:            capp.Name,
+		Repository:           capp.Repository,
+		Team:                 capp.Team,
+	})
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving project from database", "error", err)
+		level.Error(l).Log("message", "error inserting project to db", "error", err)
+		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		return
+	}
+	level.Debug(l).Log("message", "creating project")
+	token, err := cp.CreateProject(ctx, capp.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error creating project", "error", err)
+		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "inserting token into DB")
+	err = h.dbClient.CreateTokenEntry(ctx, token)
+	if err != nil {
+		level.Error(l).Log("message", "error inserting token into DB", "error", err)
+		h.errorResponse(w, "error creating token", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "retrieving Cello token")
+	celloToken := newCelloToken(a.Provider, token)
+
+	resp := responses.CreateProject{
+		Token:   celloToken.Token,
+		TokenID: token.ProjectToken.ID,
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error serializing token", "error", err)
+		h.errorResponse(w, "error serializing token", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Get a project
+func (h handler) getProject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+
+	l := h.requestLogger(r, "op", "get-project", "project", projectName)
+
+	level.Debug(l).Log("message", "validating authorization header for get project")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "getting project from database")
+	ctx := r.Context()
+	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving project", "error", err)
 		if errors.Is(err, upper.ErrNoMoreRows) {
-			h.errorResponse(w, "project does not exist", http.StatusNotFound)
+			h.errorResponse(w, "error retrieving project", http.StatusNotFound)
 		} else {
 			h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
 		}
-		return false, err
+		return
 	}
 
-	return true, err
+	resp := responses.GetProject{
+		ProjectMetadata: projectEntry.Metadata(),
+		Name:            projectName,
+		Repository:      projectEntry.Repository,
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
 }
 
-// Creates a project
-func (h handler) createProject(w http.ResponseWriter, r *http.Request) {
-	l := h.requestLogger(r, "op", "create-project")
+// List projects
+func (h handler) listProjects(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "list-projects")
 
-	level.Debug(l).Log("message", "validating authorization header for create project")
+	level.Debug(l).Log("message", "validating authorization header for list projects")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	lpr, err := parseListProjects(r.URL.Query())
+	if err == nil {
+		err = lpr.Validate()
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	filter := db.ProjectFilter{
+		Limit:  uint(lpr.Limit),
+		Name:   lpr.Name,
+		Offset: uint(lpr.Offset),
+	}
+
+	level.Debug(l).Log("message", "listing projects from database")
+	projectEntries, total, err := h.dbClient.ListProjectEntries(r.Context(), filter)
+	if err != nil {
+		level.Error(l).Log("message", "error listing projects", "error", err)
+		h.errorResponse(w, "error listing projects", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.ListProjects{
+		Limit:    int(filter.Limit),
+		Offset:   int(filter.Offset),
+		Projects: []responses.GetProject{},
+		Total:    int(total),
+	}
+	for _, pe := range projectEntries {
+		resp.Projects = append(resp.Projects, responses.GetProject{
+			ProjectMetadata: pe.Metadata(),
+			Name:            pe.ProjectID,
+			Repository:      pe.Repository,
+		})
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseListProjects reads the list projects request from the query
+// parameters.
+func parseListProjects(query url.Values) (requests.ListProjects, error) {
+	req := requests.ListProjects{
+		Limit: requests.DefaultListProjectsLimit,
+		Name:  query.Get("name"),
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return req, errors.New("limit must be a number")
+		}
+		req.Limit = limit
+	}
+
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil {
+			return req, errors.New("offset must be a number")
+		}
+		req.Offset = offset
+	}
+
+	return req, nil
+}
+
+// Update a project
+func (h handler) updateProject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+
+	l := h.requestLogger(r, "op", "update-project", "project", projectName)
+
+	level.Debug(l).Log("message", "validating authorization header for update project")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
 	if err != nil {
@@ -555,26 +1548,97 @@ func (h handler) createProject(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	var capp requests.CreateProject
+	var upr requests.UpdateProject
 	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
 		level.Error(l).Log("message", "error reading request body", "error", err)
 		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
 		return
 	}
-	if err := json.Unmarshal(reqBody, &capp); err != nil {
+	if err := json.Unmarshal(reqBody, &upr); err != nil {
 		level.Error(l).Log("message", "error decoding request", "error", err)
 		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
 		return
 	}
-	if err := capp.Validate(); err != nil {
-		level.Error(l).Log("message", "error invalid request", "error", err)
-		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+	if err := upr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	projectExists, err := h.projectExists(ctx, l, cp, w, projectName)
+	if err != nil || !projectExists {
+		return
+	}
+
+	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving project", "error", err)
+		h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
+		return
+	}
+
+	metadata, repository := upr.Apply(projectEntry.Metadata(), projectEntry.Repository)
+	projectEntry = db.ProjectEntry{
+		Contact:              metadata.Contact,
+		Description:          metadata.Description,
+		Labels:               metadata.Labels,
+		ManifestRoot:         metadata.ManifestRoot,
+		RequireSignedCommits: db.SignedCommitPolicy(metadata.RequireSignedCommits),
+		ProjectID:            projectName,
+		Repository:           repository,
+		Team:                 metadata.Team,
+	}
+
+	level.Debug(l).Log("message", "updating project in db")
+	if err := h.dbClient.UpdateProjectEntry(ctx, projectEntry); err != nil {
+		level.Error(l).Log("message", "error updating project", "error", err)
+		h.errorResponse(w, "error updating project", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetProject{
+		ProjectMetadata: metadata,
+		Name:            projectName,
+		Repository:      repository,
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Delete a project
+func (h handler) deleteProject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+
+	l := h.requestLogger(r, "op", "delete-project", "project", projectName)
+
+	level.Debug(l).Log("message", "validating authorization header for delete project")
+	ctx := r.Context()
+
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
 		return
 	}
 
-	l = log.With(l, "project", capp.Name)
-
 	level.Debug(l).Log("message", "creating credential provider")
 	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
 	if err != nil {
@@ -583,68 +1647,88 @@ func (h handler) createProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectExists, err := cp.ProjectExists(capp.Name)
+	level.Debug(l).Log("message", "checking if project exists")
+	projectExists, err := cp.ProjectExists(ctx, projectName)
 	if err != nil {
 		level.Error(l).Log("message", "error checking project", "error", err)
 		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
 		return
 	}
 
-	if projectExists {
-		level.Error(l).Log("error", "project already exists")
-		h.errorResponse(w, "project already exists", http.StatusBadRequest)
+	if !projectExists {
+		level.Debug(l).Log("message", "no action required because project does not exist")
 		return
 	}
 
-	level.Debug(l).Log("message", "inserting into db")
-	err = h.dbClient.CreateProjectEntry(ctx, db.ProjectEntry{
-		ProjectID:  capp.Name,
-		Repository: capp.Repository,
-	})
+	level.Debug(l).Log("message", "getting all targets in project")
+	targets, err := cp.ListTargets(ctx, projectName)
 	if err != nil {
-		level.Error(l).Log("message", "error inserting project to db", "error", err)
-		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error getting all targets", "error", err)
+		h.errorResponse(w, "error getting all targets", http.StatusInternalServerError)
 		return
 	}
-	level.Debug(l).Log("message", "creating project")
-	token, err := cp.CreateProject(capp.Name)
-	if err != nil {
-		level.Error(l).Log("message", "error creating project", "error", err)
-		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+
+	if len(targets) > 0 {
+		level.Error(l).Log("error", "project has existing targets, not deleting")
+		h.errorResponse(w, "project has existing targets, not deleting", http.StatusBadRequest)
 		return
 	}
 
-	level.Debug(l).Log("message", "inserting token into DB")
-	err = h.dbClient.CreateTokenEntry(ctx, token)
+	level.Debug(l).Log("message", "deleting project git credentials")
+	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
+	if err != nil && !errors.Is(err, upper.ErrNoMoreRows) {
+		level.Error(l).Log("message", "error retrieving project", "error", err)
+		h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
+		return
+	}
+	if err := h.removeGitCredentials(ctx, cp, projectEntry); err != nil {
+		level.Error(l).Log("message", "error deleting git credentials", "error", err)
+		h.errorResponse(w, "error deleting git credentials", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "deleting project")
+	err = cp.DeleteProject(ctx, projectName)
 	if err != nil {
-		level.Error(l).Log("message", "error inserting token into DB", "error", err)
-		h.errorResponse(w, "error creating token", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error deleting project", "error", err)
+		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "retrieving Cello token")
-	celloToken := newCelloToken("vault", token)
+	level.Debug(h.logger).Log("message", "deleting from db")
+	if err = h.dbClient.DeleteProjectEntry(ctx, projectName); err != nil {
+		level.Error(l).Log("message", "error deleting project in database", "error", err)
+		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
+		return
+	}
+}
 
-	resp := responses.CreateProject{
-		Token:   celloToken.Token,
-		TokenID: token.ProjectToken.ID,
+// removeGitCredentials deletes the git credentials of the project when it has
+// any.
+func (h handler) removeGitCredentials(ctx context.Context, cp credentials.Provider, pe db.ProjectEntry) error {
+	if pe.GitCredentials == "" {
+		return nil
 	}
 
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		level.Error(l).Log("message", "error serializing token", "error", err)
-		h.errorResponse(w, "error serializing token", http.StatusInternalServerError)
-		return
+	gcp, ok := cp.(credentials.GitCredentialsProvider)
+	if !ok {
+		return errors.New("credentials provider does not support git credentials")
+	}
+
+	if err := gcp.DeleteGitCredentials(ctx, pe.GitCredentials); err != nil {
+		return err
 	}
+	return h.dbClient.UpdateProjectGitCredentials(ctx, pe.ProjectID, "")
 }
 
-// Get a project
-func (h handler) getProject(w http.ResponseWriter, r *http.Request) {
+// Sets the git credentials of a project
+func (h handler) updateProjectGitCredentials(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectName := vars["projectName"]
 
-	l := h.requestLogger(r, "op", "get-project", "project", projectName)
+	l := h.requestLogger(r, "op", "update-project-git-credentials", "project", projectName)
 
-	level.Debug(l).Log("message", "validating authorization header for get project")
+	level.Debug(l).Log("message", "validating authorization header for update project git credentials")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
 	if err != nil {
@@ -656,41 +1740,83 @@ func (h handler) getProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	level.Debug(l).Log("message", "getting project from database")
 	ctx := r.Context()
-	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
+
+	var ugcr requests.UpdateGitCredentials
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request body", "error", err)
+		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(reqBody, &ugcr); err != nil {
+		level.Error(l).Log("message", "error decoding request", "error", err)
+		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+		return
+	}
+	if err := ugcr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if err := git.ValidateCredentials(types.GitCredentials(ugcr)); err != nil {
+		level.Error(l).Log("message", "error invalid git credentials", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
 	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	gcp, ok := cp.(credentials.GitCredentialsProvider)
+	if !ok {
+		level.Error(l).Log("message", "credentials provider does not support git credentials", "provider", a.Provider)
+		h.errorResponse(w, "credentials provider does not support git credentials", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.dbClient.ReadProjectEntry(ctx, projectName); err != nil {
 		level.Error(l).Log("message", "error retrieving project", "error", err)
 		if errors.Is(err, upper.ErrNoMoreRows) {
-			h.errorResponse(w, "error retrieving project", http.StatusNotFound)
+			h.errorResponse(w, "project does not exist", http.StatusNotFound)
 		} else {
 			h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	resp := responses.GetProject{
-		Name:       projectName,
-		Repository: projectEntry.Repository,
+	level.Debug(l).Log("message", "writing project git credentials")
+	ref, err := gcp.WriteGitCredentials(ctx, projectName, types.GitCredentials(ugcr))
+	if err != nil {
+		level.Error(l).Log("message", "error writing git credentials", "error", err)
+		h.errorResponse(w, "error writing git credentials", http.StatusInternalServerError)
+		return
 	}
 
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		level.Error(l).Log("message", "error creating response", "error", err)
-		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+	level.Debug(l).Log("message", "updating project git credentials in db")
+	if err := h.dbClient.UpdateProjectGitCredentials(ctx, projectName, ref); err != nil {
+		level.Error(l).Log("message", "error updating project git credentials", "error", err)
+		h.errorResponse(w, "error updating project git credentials", http.StatusInternalServerError)
 		return
 	}
+
+	fmt.Fprint(w, "{}")
 }
 
-// Delete a project
-func (h handler) deleteProject(w http.ResponseWriter, r *http.Request) {
+// Deletes the git credentials of a project, so its repository is accessed
+// with the global git credentials
+func (h handler) deleteProjectGitCredentials(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectName := vars["projectName"]
 
-	l := h.requestLogger(r, "op", "delete-project", "project", projectName)
-
-	level.Debug(l).Log("message", "validating authorization header for delete project")
-	ctx := r.Context()
+	l := h.requestLogger(r, "op", "delete-project-git-credentials", "project", projectName)
 
+	level.Debug(l).Log("message", "validating authorization header for delete project git credentials")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
 	if err != nil {
@@ -702,6 +1828,8 @@ func (h handler) deleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx := r.Context()
+
 	level.Debug(l).Log("message", "creating credential provider")
 	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
 	if err != nil {
@@ -710,47 +1838,31 @@ func (h handler) deleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	level.Debug(l).Log("message", "checking if project exists")
-	projectExists, err := cp.ProjectExists(projectName)
-	if err != nil {
-		level.Error(l).Log("message", "error checking project", "error", err)
-		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
-		return
-	}
-
-	if !projectExists {
-		level.Debug(l).Log("message", "no action required because project does not exist")
+	if _, ok := cp.(credentials.GitCredentialsProvider); !ok {
+		level.Error(l).Log("message", "credentials provider does not support git credentials", "provider", a.Provider)
+		h.errorResponse(w, "credentials provider does not support git credentials", http.StatusBadRequest)
 		return
 	}
 
-	level.Debug(l).Log("message", "getting all targets in project")
-	targets, err := cp.ListTargets(projectName)
+	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
 	if err != nil {
-		level.Error(l).Log("message", "error getting all targets", "error", err)
-		h.errorResponse(w, "error getting all targets", http.StatusInternalServerError)
-		return
-	}
-
-	if len(targets) > 0 {
-		level.Error(l).Log("error", "project has existing targets, not deleting")
-		h.errorResponse(w, "project has existing targets, not deleting", http.StatusBadRequest)
+		level.Error(l).Log("message", "error retrieving project", "error", err)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "project does not exist", http.StatusNotFound)
+		} else {
+			h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	level.Debug(l).Log("message", "deleting project")
-	err = cp.DeleteProject(projectName)
-	if err != nil {
-		level.Error(l).Log("message", "error deleting project", "error", err)
-		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
+	level.Debug(l).Log("message", "deleting project git credentials")
+	if err := h.removeGitCredentials(ctx, cp, projectEntry); err != nil {
+		level.Error(l).Log("message", "error deleting git credentials", "error", err)
+		h.errorResponse(w, "error deleting git credentials", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(h.logger).Log("message", "deleting from db")
-	if err = h.dbClient.DeleteProjectEntry(ctx, projectName); err != nil {
-		level.Error(l).Log("message", "error deleting project in database", "error", err)
-		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
-		return
-	}
+	fmt.Fprint(w, "{}")
 }
 
 // Creates a target
@@ -760,6 +1872,8 @@ func (h handler) createTarget(w http.ResponseWriter, r *http.Request) {
 
 	l := h.requestLogger(r, "op", "create-target", "project", projectName)
 
+	ctx := r.Context()
+
 	level.Debug(l).Log("message", "validating authorization header for create target")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
@@ -802,7 +1916,7 @@ func (h handler) createTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectExists, err := cp.ProjectExists(projectName)
+	projectExists, err := cp.ProjectExists(ctx, projectName)
 	if err != nil {
 		level.Error(l).Log("message", "error determining if project exists", "error", err)
 	}
@@ -814,7 +1928,7 @@ func (h handler) createTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	targetExists, err := cp.TargetExists(projectName, ctr.Name)
+	targetExists, err := cp.TargetExists(ctx, projectName, ctr.Name)
 	if err != nil {
 		level.Error(l).Log("message", "error retrieving target", "error", err)
 		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
@@ -827,7 +1941,9 @@ func (h handler) createTarget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	level.Debug(l).Log("message", "creating target")
-	err = cp.CreateTarget(projectName, types.Target(ctr))
+	err = h.dbClient.UpsertTargetEntry(ctx, db.NewTargetEntry(projectName, types.Target(ctr)), func() error {
+		return cp.CreateTarget(ctx, projectName, types.Target(ctr))
+	})
 	if err != nil {
 		level.Error(l).Log("message", "error creating target", "error", err)
 		h.errorResponse(w, "error creating target", http.StatusInternalServerError)
@@ -844,6 +1960,8 @@ func (h handler) deleteTarget(w http.ResponseWriter, r *http.Request) {
 
 	l := h.requestLogger(r, "op", "delete-target", "project", projectName, "target", targetName)
 
+	ctx := r.Context()
+
 	level.Debug(l).Log("message", "validating authorization header for delete target")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
@@ -865,7 +1983,9 @@ func (h handler) deleteTarget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	level.Debug(l).Log("message", "deleting target")
-	err = cp.DeleteTarget(projectName, targetName)
+	err = h.dbClient.DeleteTargetEntry(ctx, projectName, targetName, func() error {
+		return cp.DeleteTarget(ctx, projectName, targetName)
+	})
 	if err != nil {
 		level.Error(l).Log("message", "error deleting target", "error", err)
 		h.errorResponse(w, "error deleting target", http.StatusInternalServerError)
@@ -880,6 +2000,8 @@ func (h handler) listTargets(w http.ResponseWriter, r *http.Request) {
 
 	l := h.requestLogger(r, "op", "list-targets", "project", projectName)
 
+	ctx := r.Context()
+
 	level.Debug(l).Log("message", "validating authorization header for target list")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
@@ -901,7 +2023,7 @@ func (h handler) listTargets(w http.ResponseWriter, r *http.Request) {
 	}
 
 	level.Debug(l).Log("message", "checking if project exists")
-	projectExists, err := cp.ProjectExists(projectName)
+	projectExists, err := cp.ProjectExists(ctx, projectName)
 	if err != nil {
 		level.Error(l).Log("message", "error checking project", "error", err)
 		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
@@ -914,7 +2036,17 @@ func (h handler) listTargets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	targets, err := cp.ListTargets(projectName)
+	filter := db.TargetFilter{
+		Tier: r.URL.Query().Get("tier"),
+		Type: r.URL.Query().Get("type"),
+	}
+	if filter.Tier != "" && !types.IsValidTargetTier(filter.Tier) {
+		level.Error(l).Log("message", "error invalid tier", "tier", filter.Tier)
+		h.errorResponse(w, fmt.Sprintf("invalid request, tier must be one of '%s'", strings.Join(types.TargetTiers, "', '")), http.StatusBadRequest)
+		return
+	}
+
+	targets, err := h.readTargets(ctx, cp, projectName, filter)
 	if err != nil {
 		level.Error(l).Log("message", "error listing targets", "error", err)
 		h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
@@ -939,6 +2071,8 @@ func (h handler) updateTarget(w http.ResponseWriter, r *http.Request) {
 
 	l := h.requestLogger(r, "op", "update-target", "project", projectName, "target", targetName)
 
+	ctx := r.Context()
+
 	level.Debug(l).Log("message", "validating authorization header for update target")
 	ah := r.Header.Get("Authorization")
 	a, err := credentials.NewAuthorization(ah)
@@ -959,7 +2093,7 @@ func (h handler) updateTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectExists, err := cp.ProjectExists(projectName)
+	projectExists, err := cp.ProjectExists(ctx, projectName)
 	if err != nil {
 		level.Error(l).Log("message", "error determining if project exists", "error", err)
 		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
@@ -972,7 +2106,7 @@ func (h handler) updateTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	targetExists, err := cp.TargetExists(projectName, targetName)
+	targetExists, err := cp.TargetExists(ctx, projectName, targetName)
 	if err != nil {
 		level.Error(l).Log("message", "error retrieving target", "error", err)
 		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
@@ -984,12 +2118,13 @@ func (h handler) updateTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	target, err := cp.GetTarget(projectName, targetName)
+	current, err := h.readTarget(ctx, cp, projectName, targetName)
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving existing target")
+		level.Error(l).Log("message", "error retrieving existing target", "error", err)
 		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
 		return
 	}
+	target := current.Target
 	targetType := target.Type
 
 	level.Debug(l).Log("message", "reading request body")
@@ -1017,7 +2152,9 @@ func (h handler) updateTarget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	level.Debug(l).Log("message", "updating target")
-	err = cp.UpdateTarget(projectName, target)
+	err = h.dbClient.UpsertTargetEntry(ctx, db.NewTargetEntry(projectName, target), func() error {
+		return cp.UpdateTarget(ctx, projectName, target)
+	})
 	if err != nil {
 		level.Error(l).Log("message", "error updating target", "error", err)
 		h.errorResponse(w, "error updating target", http.StatusInternalServerError)
@@ -1043,14 +2180,119 @@ func (h handler) deleteToken(w http.ResponseWriter, r *http.Request) {
 
 	level.Debug(l).Log("message", "validating authorization header for delete token")
 
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+
+	level.Debug(l).Log("message", "checking if project exists")
+	projectExists, err := h.projectExists(ctx, l, cp, w, projectName)
+
+	if err != nil || !projectExists {
+		return
+	}
+
+	// check if token exists in CP and DB
+	projectToken, err := cp.GetProjectToken(ctx, projectName, tokenID)
+	if err != nil {
+		// do not return an error if project token is not found
+		if !errors.Is(err, credentials.ErrProjectTokenNotFound) {
+			level.Error(l).Log("message", "error retrieving token from credentials provider", "error", err)
+			h.errorResponse(w, "error retrieving token", http.StatusInternalServerError)
+			return
+		}
+		level.Warn(l).Log("message", "token does not exist in credential provider", "error", err)
+	}
+
+	dbProjectToken, err := h.dbClient.ReadTokenEntry(ctx, tokenID)
+	if err != nil {
+		// do not return an error if project token is not found
+		if !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Error(l).Log("message", "error retrieving token from DB", "error", err)
+			h.errorResponse(w, "error retrieving token", http.StatusInternalServerError)
+			return
+		}
+		level.Warn(l).Log("message", "token does not exist in DB", "error", err)
+	}
+
+	// delete token from DB and CP
+	// only delete token if exists in DB
+	if !dbProjectToken.IsEmpty() {
+		level.Debug(l).Log("message", "deleting token from database")
+		if err = h.dbClient.DeleteTokenEntry(ctx, tokenID); err != nil {
+			level.Error(l).Log("message", "error deleting token from database", "error", err)
+			h.errorResponse(w, "error deleting token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// only delete token if exists in CP
+	if !projectToken.IsEmpty() {
+		level.Debug(l).Log("message", "deleting token from credentials provider")
+		if err = cp.DeleteProjectToken(ctx, projectName, tokenID); err != nil {
+			level.Error(l).Log("message", "error deleting token from credentials provider", "error", err)
+			h.errorResponse(w, "error deleting token", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Rotates a project token. The replacement token has the same scopes.
+func (h handler) rotateToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectName := vars["projectName"]
+	tokenID := vars["tokenID"]
+
+	l := h.requestLogger(r, "op", "rotate-token", "project", projectName, "tokenID", tokenID)
+
+	level.Debug(l).Log("message", "validating authorization header for rotate token")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
 		return
 	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+
+	// An empty body revokes the token immediately.
+	var rtr requests.RotateToken
+	if len(reqBody) > 0 {
+		if err := json.Unmarshal(reqBody, &rtr); err != nil {
+			level.Error(l).Log("message", "error deserializing request body", "error", err)
+			h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := rtr.Validate(rtr.ValidateMaxTTL(h.env.TokenMaxTTL)); err != nil {
+		level.Error(l).Log("message", "error validating request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
 		return
 	}
 
@@ -1071,48 +2313,89 @@ func (h handler) deleteToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// check if token exists in CP and DB
-	projectToken, err := cp.GetProjectToken(projectName, tokenID)
-	if err != nil {
-		// do not return an error if project token is not found
-		if !errors.Is(err, credentials.ErrProjectTokenNotFound) {
-			level.Error(l).Log("message", "error retrieving token from credentials provider", "error", err)
+	// The token must exist in both the CP and DB to be rotated.
+	if _, err := cp.GetProjectToken(ctx, projectName, tokenID); err != nil {
+		level.Error(l).Log("message", "error retrieving token from credentials provider", "error", err)
+		if errors.Is(err, credentials.ErrProjectTokenNotFound) {
+			h.errorResponse(w, "token does not exist", http.StatusNotFound)
+		} else {
 			h.errorResponse(w, "error retrieving token", http.StatusInternalServerError)
-			return
 		}
-		level.Warn(l).Log("message", "token does not exist in credential provider", "error", err)
+		return
 	}
 
-	dbProjectToken, err := h.dbClient.ReadTokenEntry(ctx, tokenID)
+	oldToken, err := h.dbClient.ReadTokenEntry(ctx, tokenID)
+	if err == nil && oldToken.ProjectID != projectName {
+		err = upper.ErrNoMoreRows
+	}
 	if err != nil {
-		// do not return an error if project token is not found
-		if !errors.Is(err, upper.ErrNoMoreRows) {
-			level.Error(l).Log("message", "error retrieving token from DB", "error", err)
+		level.Error(l).Log("message", "error retrieving token from DB", "error", err)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "token does not exist", http.StatusNotFound)
+		} else {
 			h.errorResponse(w, "error retrieving token", http.StatusInternalServerError)
-			return
 		}
-		level.Warn(l).Log("message", "token does not exist in DB", "error", err)
+		return
 	}
 
-	// delete token from DB and CP
-	// only delete token if exists in DB
-	if !dbProjectToken.IsEmpty() {
-		level.Debug(l).Log("message", "deleting token from database")
-		if err = h.dbClient.DeleteTokenEntry(ctx, tokenID); err != nil {
-			level.Error(l).Log("message", "error deleting token from database", "error", err)
-			h.errorResponse(w, "error deleting token", http.StatusInternalServerError)
-			return
-		}
+	level.Debug(l).Log("message", "creating replacement token")
+	token, err := cp.CreateToken(ctx, projectName, rtr.TTLDuration())
+	if err != nil {
+		level.Error(l).Log("message", "error creating token with credentials provider", "error", err)
+		h.errorResponse(w, "error creating token with credentials provider", http.StatusInternalServerError)
+		return
 	}
+	token.Scopes = types.TokenScopes(oldToken.Scopes)
 
-	// only delete token if exists in CP
-	if !projectToken.IsEmpty() {
-		level.Debug(l).Log("message", "deleting token from credentials provider")
-		if err = cp.DeleteProjectToken(projectName, tokenID); err != nil {
-			level.Error(l).Log("message", "error deleting token from credentials provider", "error", err)
-			h.errorResponse(w, "error deleting token", http.StatusInternalServerError)
-			return
+	// Without a grace period the old token is revoked within the DB
+	// transaction so the tokens table is left unchanged if revoking fails.
+	var (
+		oldExpiresAt time.Time
+		revoke       func() error
+	)
+	if gracePeriod := rtr.GracePeriodDuration(); gracePeriod > 0 {
+		oldExpiresAt = time.Now().Add(gracePeriod)
+	} else {
+		revoke = func() error { return cp.DeleteProjectToken(ctx, projectName, tokenID) }
+	}
+
+	level.Debug(l).Log("message", "rotating token in db")
+	var oldExpiry string
+	if !oldExpiresAt.IsZero() {
+		oldExpiry = oldExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	if err := h.dbClient.RotateTokenEntry(ctx, tokenID, token, oldExpiry, revoke); err != nil {
+		level.Error(l).Log("message", "error rotating token", "error", err)
+
+		// Don't leave the replacement token behind as it was never returned.
+		if err := cp.DeleteProjectToken(ctx, projectName, token.ProjectToken.ID); err != nil {
+			level.Error(l).Log("message", "error deleting replacement token from credentials provider", "error", err)
 		}
+
+		h.errorResponse(w, "error rotating token", http.StatusInternalServerError)
+		return
+	}
+
+	// The credentials provider keeps accepting the old token until its own
+	// TTL, so it's revoked when the grace period ends.
+	if !oldExpiresAt.IsZero() {
+		h.scheduleTokenRevocation(a.Provider, projectName, tokenID, oldExpiresAt)
+	}
+
+	celloToken := newCelloToken(a.Provider, token)
+
+	resp := responses.CreateToken{
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+		Scopes:    token.Scopes,
+		Token:     celloToken.Token,
+		TokenID:   token.ProjectToken.ID,
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error serializing project token", "error", err)
+		h.errorResponse(w, "error serializing project token", http.StatusInternalServerError)
+		return
 	}
 }
 
@@ -1135,6 +2418,29 @@ func (h handler) createToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	// An empty body creates an unscoped token.
+	var ctr requests.CreateToken
+	if len(reqBody) > 0 {
+		if err := json.Unmarshal(reqBody, &ctr); err != nil {
+			level.Error(l).Log("message", "error deserializing request body", "error", err)
+			h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := ctr.Validate(ctr.ValidateMaxTTL(h.env.TokenMaxTTL)); err != nil {
+		level.Error(l).Log("message", "error validating request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
 
 	level.Debug(l).Log("message", "creating credential provider")
@@ -1157,20 +2463,22 @@ func (h handler) createToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(tokens) >= numOfTokensLimit {
+	if len(tokens) >= h.env.TokenLimit {
 		level.Error(l).Log("message", "number of tokens allowed per project has been reached")
 		h.errorResponse(w, "token limit reached", http.StatusInternalServerError)
 		return
 	}
 
 	level.Debug(l).Log("message", "creating token")
-	token, err := cp.CreateToken(projectName)
+	token, err := cp.CreateToken(ctx, projectName, ctr.TTLDuration())
 	if err != nil {
 		level.Error(l).Log("message", "error creating token with credentials provider", "error", err)
 		h.errorResponse(w, "error creating token with credentials provider", http.StatusInternalServerError)
 		return
 	}
 
+	token.Scopes = ctr.Scopes
+
 	level.Debug(l).Log("message", "inserting into db")
 	err = h.dbClient.CreateTokenEntry(ctx, token)
 	if err != nil {
@@ -1179,11 +2487,12 @@ func (h handler) createToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	celloToken := newCelloToken("vault", token)
+	celloToken := newCelloToken(a.Provider, token)
 
 	resp := responses.CreateToken{
 		CreatedAt: token.CreatedAt,
 		ExpiresAt: token.ExpiresAt,
+		Scopes:    token.Scopes,
 		Token:     celloToken.Token,
 		TokenID:   token.ProjectToken.ID,
 	}
@@ -1242,6 +2551,7 @@ func (h handler) listTokens(w http.ResponseWriter, r *http.Request) {
 		resp = append(resp, responses.ListTokens{
 			CreatedAt: tokenEntry.CreatedAt,
 			ExpiresAt: tokenEntry.ExpiresAt,
+			Scopes:    types.TokenScopes(tokenEntry.Scopes),
 			TokenID:   tokenEntry.TokenID,
 		})
 	}
@@ -1253,6 +2563,274 @@ func (h handler) listTokens(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h handler) getReconciliation(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "get-reconciliation")
+
+	level.Debug(l).Log("message", "validating authorization header for get reconciliation")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	entry, err := h.dbClient.ReadReconciliationEntry(r.Context(), reconciliationName)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "reconciliation has not run", http.StatusNotFound)
+			return
+		}
+		level.Error(l).Log("message", "error reading reconciliation", "error", err)
+		h.errorResponse(w, "error reading reconciliation", http.StatusInternalServerError)
+		return
+	}
+
+	// Reconciliations which haven't completed yet have an empty report.
+	if entry.Report == "" || entry.Report == "{}" {
+		h.errorResponse(w, "reconciliation has not run", http.StatusNotFound)
+		return
+	}
+
+	fmt.Fprint(w, entry.Report)
+}
+
+// Returns the statistics of the database connection pool.
+func (h handler) getDBStats(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "get-db-stats")
+
+	level.Debug(l).Log("message", "validating authorization header for get db stats")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	stats := h.dbClient.Stats()
+	resp := responses.GetDBStats{
+		Idle:              stats.Idle,
+		InUse:             stats.InUse,
+		MaxIdleClosed:     stats.MaxIdleClosed,
+		MaxIdleTimeClosed: stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed: stats.MaxLifetimeClosed,
+		MaxOpenConns:      stats.MaxOpenConnections,
+		OpenConns:         stats.OpenConnections,
+		WaitCount:         stats.WaitCount,
+		WaitDuration:      stats.WaitDuration.String(),
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error serializing db stats", "error", err)
+		h.errorResponse(w, "error getting db stats", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Lists the repositories in the git cache, most recently used first.
+func (h handler) listGitCache(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "list-git-cache")
+
+	level.Debug(l).Log("message", "validating authorization header for list git cache")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	resp := responses.ListGitCache{}
+	for _, repo := range h.gitClient.ListCachedRepositories() {
+		resp = append(resp, responses.GitCacheRepository{
+			LastUsed:   repo.LastUsed.UTC().Format(time.RFC3339),
+			Name:       repo.Name,
+			Repository: repo.Repository,
+			SizeBytes:  repo.Size,
+		})
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error serializing git cache", "error", err)
+		h.errorResponse(w, "error listing git cache", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Removes a repository from the git cache. It's fetched again by the next
+// read.
+func (h handler) purgeGitCache(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "purge-git-cache")
+
+	level.Debug(l).Log("message", "validating authorization header for purge git cache")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	repository := r.URL.Query().Get("repository")
+	if repository == "" {
+		h.errorResponse(w, "invalid request, repository is required", http.StatusBadRequest)
+		return
+	}
+	l = log.With(l, "repository", repository)
+
+	level.Debug(l).Log("message", "purging repository from git cache")
+	if err := h.gitClient.PurgeCachedRepository(repository); err != nil {
+		level.Error(l).Log("message", "error purging repository from git cache", "error", err)
+		if errors.Is(err, git.ErrRepositoryNotCached) {
+			h.errorResponse(w, "repository not cached", http.StatusNotFound)
+		} else {
+			h.errorResponse(w, "error purging repository from git cache", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	fmt.Fprint(w, "{}")
+}
+
+// Moves the Vault AppRoles, policies and AWS roles of projects from another
+// project prefix, by default the legacy one, to the configured one. The tokens
+// of moved projects are invalidated and removed from the database.
+func (h handler) migrateVault(w http.ResponseWriter, r *http.Request) {
+	l := h.requestLogger(r, "op", "migrate-vault")
+
+	level.Debug(l).Log("message", "validating authorization header for migrate vault")
+	ah := r.Header.Get("Authorization")
+	a, err := credentials.NewAuthorization(ah)
+	if err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	dryRun, err := parseDryRun(r.URL.Query())
+	if err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = credentials.VaultLegacyProjectPrefix
+	}
+
+	l = log.With(l, "from", from, "dryRun", dryRun)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	m, ok := cp.(credentials.VaultMigrator)
+	if !ok {
+		level.Error(l).Log("message", "credentials provider does not support vault migrations", "provider", a.Provider)
+		h.errorResponse(w, "credentials provider does not support vault migrations", http.StatusBadRequest)
+		return
+	}
+
+	if from == h.env.VaultProjectPrefix {
+		h.errorResponse(w, "invalid request, from must be a project prefix other than the current one", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "migrating vault projects")
+	resp, err := m.MigrateProjects(ctx, from, dryRun)
+	if err != nil {
+		level.Error(l).Log("message", "error migrating vault projects", "error", err)
+		h.errorResponse(w, "error migrating vault projects", http.StatusInternalServerError)
+		return
+	}
+
+	if !dryRun {
+		for _, p := range resp.Projects {
+			if err := h.deleteTokenEntries(ctx, p.Name); err != nil {
+				level.Error(l).Log("message", "error deleting tokens of migrated project", "project", p.Name, "error", err)
+				h.errorResponse(w, "error deleting tokens of migrated projects", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+}
+
+// deleteTokenEntries deletes the database entries of all tokens of the
+// project.
+func (h handler) deleteTokenEntries(ctx context.Context, project string) error {
+	tokens, err := h.dbClient.ListTokenEntries(ctx, project)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tokens {
+		if err := h.dbClient.DeleteTokenEntry(ctx, t.TokenID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// argoRequestContext is a context with the values of the Argo context, which
+// the Argo client needs, and the deadline and cancellation of a request.
+type argoRequestContext struct {
+	context.Context
+	argo context.Context
+}
+
+func (c argoRequestContext) Value(key interface{}) interface{} {
+	if v := c.argo.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// argoContext returns the Argo context cancelled with ctx, so Argo calls stop
+// when the client disconnects.
+func (h handler) argoContext(ctx context.Context) context.Context {
+	if h.argoCtx == nil {
+		return ctx
+	}
+	return argoRequestContext{Context: ctx, argo: h.argoCtx}
+}
+
+// newAdminCredentialsProvider returns the named credentials provider with the
+// admin credentials of the service, for operations not made on behalf of a
+// request. The provider is the one of the request's token when there is one.
+func (h handler) newAdminCredentialsProvider(provider string) (credentials.Provider, error) {
+	a := credentials.Authorization{Provider: provider, Key: "admin", Secret: h.env.AdminSecret}
+	return h.newCredentialsProvider(a, h.env, http.Header{}, credentials.NewVaultConfig, credentials.NewVaultSvc)
+}
+
 // Convenience method that writes a failure response in a standard manner
 func (h handler) errorResponse(w http.ResponseWriter, message string, httpStatus int) {
 	r := generateErrorResponseJSON(message)
@@ -1274,6 +2852,27 @@ func generateEnvVariablesString(environmentVariables map[string]string) string {
 	return r
 }
 
+// addRegionEnvVariables sets the AWS region environment variables to the
+// target region unless they are already provided.
+func addRegionEnvVariables(environmentVariables map[string]string, region string) map[string]string {
+	if region == "" {
+		return environmentVariables
+	}
+
+	vars := map[string]string{}
+	for k, v := range environmentVariables {
+		vars[k] = v
+	}
+
+	for _, k := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if _, ok := vars[k]; !ok {
+			vars[k] = region
+		}
+	}
+
+	return vars
+}
+
 func (h handler) requestLogger(r *http.Request, fields ...interface{}) log.Logger {
 	return log.With(
 		h.logger,
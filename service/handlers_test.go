@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,13 +12,18 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
 	"github.com/cello-proj/cello/internal/types"
 	"github.com/cello-proj/cello/service/internal/credentials"
 	"github.com/cello-proj/cello/service/internal/db"
 	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/git"
 	"github.com/cello-proj/cello/service/internal/workflow"
 	th "github.com/cello-proj/cello/service/test/testhelpers"
 
@@ -47,8 +53,11 @@ type test struct {
 	method     string
 	cpMock     *th.CredsProviderMock
 	dbMock     *th.DBClientMock
-	gitMock    *th.GitClientMock
-	wfMock     *th.WorkflowMock
+	// db is used instead of dbMock when set, e.g. a SQLite database shared
+	// by the tests of a scenario.
+	db      db.Client
+	gitMock *th.GitClientMock
+	wfMock  *th.WorkflowMock
 }
 
 func TestCreateProject(t *testing.T) {
@@ -71,8 +80,8 @@ func TestCreateProject(t *testing.T) {
 			url:        "/projects",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
-				CreateProjectFunc: func(s string) (types.Token, error) {
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
+				CreateProjectFunc: func(ctx context.Context, s string) (types.Token, error) {
 					return types.Token{
 						CreatedAt: "createdAt",
 						ExpiresAt: "expiresAt",
@@ -90,6 +99,47 @@ func TestCreateProject(t *testing.T) {
 				CreateTokenEntryFunc:   func(ctx context.Context, token types.Token) error { return nil },
 			},
 		},
+		{
+			name:       "can create project with metadata",
+			req:        loadJSON(t, "TestCreateProject/can_create_project_with_metadata_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestCreateProject/can_create_project_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
+				CreateProjectFunc: func(ctx context.Context, s string) (types.Token, error) {
+					return types.Token{
+						CreatedAt: "createdAt",
+						ExpiresAt: "expiresAt",
+						ProjectID: "project1",
+						ProjectToken: types.ProjectToken{
+							ID: "secret-id-accessor",
+						},
+						RoleID: "role-id",
+						Secret: "secret",
+					}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				CreateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error {
+					want := db.ProjectEntry{
+						Contact:     "platform@example.com",
+						Description: "Platform infrastructure",
+						Labels:      db.ProjectLabels{"cost-center": "1234"},
+						ProjectID:   "PROJECT",
+						Repository:  "git@github.com:myorg/myrepo.git",
+						Team:        "platform",
+					}
+					if !reflect.DeepEqual(want, pe) {
+						return fmt.Errorf("unexpected project entry %+v", pe)
+					}
+					return nil
+				},
+				CreateTokenEntryFunc: func(ctx context.Context, token types.Token) error { return nil },
+			},
+		},
 		{
 			name:       "bad request",
 			req:        loadJSON(t, "TestCreateProject/bad_request.json"),
@@ -107,7 +157,7 @@ func TestCreateProject(t *testing.T) {
 			url:        "/projects",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 		},
 		{
@@ -118,7 +168,7 @@ func TestCreateProject(t *testing.T) {
 			url:        "/projects",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
 			},
 			dbMock: &th.DBClientMock{
 				CreateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error { return errors.New("db error") },
@@ -132,7 +182,7 @@ func TestCreateProject(t *testing.T) {
 			url:        "/projects",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				CreateProjectFunc: func(s string) (types.Token, error) {
+				CreateProjectFunc: func(ctx context.Context, s string) (types.Token, error) {
 					return types.Token{
 						CreatedAt: "createdAt",
 						ExpiresAt: "expiresAt",
@@ -144,7 +194,7 @@ func TestCreateProject(t *testing.T) {
 						Secret: "secret",
 					}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
 			},
 			dbMock: &th.DBClientMock{
 				CreateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error { return nil },
@@ -166,7 +216,7 @@ func TestCreateToken(t *testing.T) {
 			url:        "/projects/undeletableprojecttargets/tokens",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				CreateTokenFunc: func(s string) (types.Token, error) {
+				CreateTokenFunc: func(ctx context.Context, s string, ttl time.Duration) (types.Token, error) {
 					return types.Token{
 						CreatedAt: "2022-06-21T14:56:10.341066-07:00",
 						ExpiresAt: "2023-06-21T14:56:10.341066-07:00",
@@ -178,7 +228,7 @@ func TestCreateToken(t *testing.T) {
 						Secret: "secret",
 					}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
 				CreateTokenEntryFunc: func(ctx context.Context, t types.Token) error { return nil },
@@ -195,6 +245,65 @@ func TestCreateToken(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "can create scoped token",
+			req:        loadJSON(t, "TestCreateToken/scoped_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestCreateToken/can_create_scoped_token_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/tokens",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				CreateTokenFunc: func(ctx context.Context, s string, ttl time.Duration) (types.Token, error) {
+					if ttl != 720*time.Hour {
+						return types.Token{}, errors.New("token ttl not set")
+					}
+					return types.Token{
+						CreatedAt: "2022-06-21T14:56:10.341066-07:00",
+						ExpiresAt: "2023-06-21T14:56:10.341066-07:00",
+						ProjectID: "project1",
+						ProjectToken: types.ProjectToken{
+							ID: "secret-id-accessor",
+						},
+						RoleID: "role-id",
+						Secret: "secret",
+					}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				CreateTokenEntryFunc: func(ctx context.Context, t types.Token) error {
+					if !t.Scopes.AllowsOperation("diff") || t.Scopes.AllowsOperation("sync") {
+						return errors.New("token scopes not stored")
+					}
+					return nil
+				},
+				ListTokenEntriesFunc: func(ctx context.Context, p string) ([]db.TokenEntry, error) {
+					return []db.TokenEntry{}, nil
+				},
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+			},
+		},
+		{
+			name:       "token scopes must be valid",
+			req:        loadJSON(t, "TestCreateToken/invalid_scopes_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestCreateToken/invalid_scopes_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/tokens",
+			method:     "POST",
+		},
+		{
+			name:       "token ttl cannot be greater than max",
+			req:        loadJSON(t, "TestCreateToken/ttl_too_long_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestCreateToken/ttl_too_long_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/tokens",
+			method:     "POST",
+		},
 		{
 			name:       "project does not exist",
 			req:        loadJSON(t, "TestCreateToken/request.json"),
@@ -204,7 +313,7 @@ func TestCreateToken(t *testing.T) {
 			url:        "/projects/project1234/tokens",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
 			},
 		},
 		{
@@ -224,8 +333,10 @@ func TestCreateToken(t *testing.T) {
 			url:        "/projects/tokendberror/tokens",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				CreateTokenFunc:   func(s string) (types.Token, error) { return types.Token{}, errors.New("error") },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				CreateTokenFunc: func(ctx context.Context, s string, ttl time.Duration) (types.Token, error) {
+					return types.Token{}, errors.New("error")
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
 				ListTokenEntriesFunc: func(ctx context.Context, p string) ([]db.TokenEntry, error) {
@@ -250,7 +361,7 @@ func TestCreateToken(t *testing.T) {
 			url:        "/projects/projectlisttokenslimit/tokens",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
 				ListTokenEntriesFunc: func(ctx context.Context, p string) ([]db.TokenEntry, error) {
@@ -280,7 +391,7 @@ func TestCreateToken(t *testing.T) {
 			url:        "/projects/projectlisttokenserror/tokens",
 			method:     "POST",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
 				ListTokenEntriesFunc: func(ctx context.Context, p string) ([]db.TokenEntry, error) {
@@ -313,7 +424,7 @@ func TestGetTarget(t *testing.T) {
 			url:        "/projects/undeletableprojecttargets/targets/TARGET_EXISTS",
 			method:     "GET",
 			cpMock: &th.CredsProviderMock{
-				GetTargetFunc: func(s1, s2 string) (types.Target, error) {
+				GetTargetFunc: func(ctx context.Context, s1, s2 string) (types.Target, error) {
 					return types.Target{
 						Name: "TARGET",
 						Properties: types.TargetProperties{
@@ -325,7 +436,43 @@ func TestGetTarget(t *testing.T) {
 						Type: "aws_account",
 					}, nil
 				},
-				TargetExistsFunc: func(s1, s2 string) (bool, error) { return true, nil },
+				TargetExistsFunc: func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+		},
+		{
+			name:       "can get target from database",
+			want:       http.StatusOK,
+			respFile:   "TestGetTarget/can_get_target_from_database_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/targets/TARGET",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				TargetExistsFunc: func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{
+						ProjectID:   project,
+						TargetName:  target,
+						Region:      "us-west-2",
+						CreatedAt:   "2022-06-01T00:00:00Z",
+						Description: "production account",
+						Owner:       "platform",
+						Properties: db.TargetProperties{
+							CredentialType: "assumed_role",
+							PolicyArns:     []string{"arn:aws:iam::012345678901:policy/test-policy"},
+							RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+						},
+						Tier:      "production",
+						Type:      "aws_account",
+						UpdatedAt: "2022-07-01T00:00:00Z",
+					}, nil
+				},
 			},
 		},
 		{
@@ -336,7 +483,7 @@ func TestGetTarget(t *testing.T) {
 			url:        "/projects/undeletableprojecttargets/targets/targetdoesnotexist",
 			method:     "GET",
 			cpMock: &th.CredsProviderMock{
-				TargetExistsFunc: func(s1, s2 string) (bool, error) { return false, nil },
+				TargetExistsFunc: func(ctx context.Context, s1, s2 string) (bool, error) { return false, nil },
 			},
 		},
 	}
@@ -344,6 +491,37 @@ func TestGetTarget(t *testing.T) {
 }
 
 func TestListTargets(t *testing.T) {
+	entries := []db.TargetEntry{
+		{
+			ProjectID:   "undeletableprojecttargets",
+			TargetName:  "target1",
+			Region:      "us-west-2",
+			CreatedAt:   "2022-06-01T00:00:00Z",
+			Description: "production account",
+			Owner:       "platform",
+			Properties: db.TargetProperties{
+				CredentialType: "assumed_role",
+				PolicyArns:     []string{"arn:aws:iam::012345678901:policy/test-policy"},
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+			},
+			Tier:      "production",
+			Type:      "aws_account",
+			UpdatedAt: "2022-07-01T00:00:00Z",
+		},
+		{
+			ProjectID:  "undeletableprojecttargets",
+			TargetName: "target2",
+			CreatedAt:  "2022-06-01T00:00:00Z",
+			Properties: db.TargetProperties{
+				CredentialType: "assumed_role",
+				RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+			},
+			Tier:      "development",
+			Type:      "aws_account",
+			UpdatedAt: "2022-06-01T00:00:00Z",
+		},
+	}
+
 	tests := []test{
 		{
 			name:       "fails to list targets when not admin",
@@ -356,15 +534,105 @@ func TestListTargets(t *testing.T) {
 		{
 			name:       "can list targets",
 			want:       http.StatusOK,
-			respFile:   "TestListTargets/can_get_target_response.json",
+			respFile:   "TestListTargets/can_list_targets_response.json",
 			authHeader: adminAuthHeader,
 			url:        "/projects/undeletableprojecttargets/targets",
 			method:     "GET",
 			cpMock: &th.CredsProviderMock{
-				ListTargetsFunc: func(s string) ([]string, error) {
-					return []string{"target1", "target2", "undeletabletarget"}, nil
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{"target1", "target2"}, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+					if project != "undeletableprojecttargets" || filter != (db.TargetFilter{}) {
+						return nil, errors.New("unexpected filter")
+					}
+					return entries, nil
+				},
+			},
+		},
+		{
+			name:       "can filter targets by type and tier",
+			want:       http.StatusOK,
+			respFile:   "TestListTargets/can_list_targets_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/targets?type=aws_account&tier=production",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{"target1", "target2"}, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+					if filter != (db.TargetFilter{Tier: "production", Type: "aws_account"}) {
+						return nil, errors.New("unexpected filter")
+					}
+					return entries, nil
+				},
+			},
+		},
+		{
+			name:       "lists targets not imported into the database",
+			want:       http.StatusOK,
+			respFile:   "TestListTargets/not_imported_targets_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/targets",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{"target2", "target1"}, nil },
+				GetTargetFunc: func(ctx context.Context, project, target string) (types.Target, error) {
+					if target != "target1" {
+						return types.Target{}, errors.New("unexpected target")
+					}
+					return entries[0].Target(), nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+					// target1 only holds its region.
+					return []db.TargetEntry{{ProjectID: project, TargetName: "target1", Region: "us-west-2"}, entries[1]}, nil
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{ProjectID: project, TargetName: target, Region: "us-west-2"}, nil
+				},
+			},
+		},
+		{
+			name:       "filters targets not imported into the database",
+			want:       http.StatusOK,
+			respFile:   "TestListTargets/no_targets_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/targets?tier=staging",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{"target1", "target2"}, nil },
+				GetTargetFunc: func(ctx context.Context, project, target string) (types.Target, error) {
+					return entries[0].Target(), nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+					return []db.TargetEntry{}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					if target == "target2" {
+						return entries[1], nil
+					}
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+		},
+		{
+			name:       "invalid tier",
+			want:       http.StatusBadRequest,
+			respFile:   "TestListTargets/invalid_tier_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/targets?tier=prod",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 		},
 		{
@@ -375,7 +643,7 @@ func TestListTargets(t *testing.T) {
 			url:        "/projects/badproject/targets",
 			method:     "GET",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
 			},
 		},
 		{
@@ -386,10 +654,13 @@ func TestListTargets(t *testing.T) {
 			url:        "/projects/projectalreadyexists/targets",
 			method:     "GET",
 			cpMock: &th.CredsProviderMock{
-				ListTargetsFunc: func(s string) ([]string, error) {
-					return []string{}, nil
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{}, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+					return []db.TargetEntry{}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
 			},
 		},
 	}
@@ -412,11 +683,14 @@ func TestDeleteProject(t *testing.T) {
 			url:        "/projects/projectalreadyexists",
 			method:     "DELETE",
 			cpMock: &th.CredsProviderMock{
-				DeleteProjectFunc: func(s string) error { return nil },
-				ListTargetsFunc:   func(s string) ([]string, error) { return []string{}, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				DeleteProjectFunc: func(ctx context.Context, s string) error { return nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{}, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: project}, nil
+				},
 				DeleteProjectEntryFunc: func(ctx context.Context, project string) error { return nil },
 			},
 		},
@@ -427,8 +701,8 @@ func TestDeleteProject(t *testing.T) {
 			url:        "/projects/undeletableprojecttargets",
 			method:     "DELETE",
 			cpMock: &th.CredsProviderMock{
-				ListTargetsFunc:   func(s string) ([]string, error) { return []string{"target"}, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{"target"}, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 		},
 		{
@@ -438,9 +712,14 @@ func TestDeleteProject(t *testing.T) {
 			url:        "/projects/undeletableproject",
 			method:     "DELETE",
 			cpMock: &th.CredsProviderMock{
-				DeleteProjectFunc: func(s string) error { return errors.New("cp error") },
-				ListTargetsFunc:   func(s string) ([]string, error) { return []string{}, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				DeleteProjectFunc: func(ctx context.Context, s string) error { return errors.New("cp error") },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{}, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: project}, nil
+				},
 			},
 		},
 		{
@@ -450,14 +729,33 @@ func TestDeleteProject(t *testing.T) {
 			url:        "/projects/somedeletedberror",
 			method:     "DELETE",
 			cpMock: &th.CredsProviderMock{
-				DeleteProjectFunc: func(s string) error { return nil },
-				ListTargetsFunc:   func(s string) ([]string, error) { return []string{}, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				DeleteProjectFunc: func(ctx context.Context, s string) error { return nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{}, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: project}, nil
+				},
 				DeleteProjectEntryFunc: func(ctx context.Context, project string) error { return errors.New("error") },
 			},
 		},
+		{
+			name:       "fails to delete project when reading project fails",
+			want:       http.StatusInternalServerError,
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectalreadyexists",
+			method:     "DELETE",
+			cpMock: &th.CredsProviderMock{
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{}, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{}, errors.New("error")
+				},
+			},
+		},
 	}
 	runTests(t, tests)
 }
@@ -483,6 +781,26 @@ func TestGetProject(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "project with metadata",
+			want:       http.StatusOK,
+			respFile:   "TestGetProject/project_with_metadata_response.json",
+			authHeader: adminAuthHeader,
+			method:     "GET",
+			url:        "/projects/project1",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{
+						Contact:     "platform@example.com",
+						Description: "Platform infrastructure",
+						Labels:      db.ProjectLabels{"cost-center": "1234"},
+						ProjectID:   "project1",
+						Repository:  "repo",
+						Team:        "platform",
+					}, nil
+				},
+			},
+		},
 		{
 			name:       "project does not exist",
 			want:       http.StatusNotFound,
@@ -499,90 +817,286 @@ func TestGetProject(t *testing.T) {
 	runTests(t, tests)
 }
 
-func TestCreateTarget(t *testing.T) {
+func TestListProjects(t *testing.T) {
 	tests := []test{
 		{
-			name:       "can create target",
-			req:        loadJSON(t, "TestCreateTarget/can_create_target_request.json"),
+			name:       "fails to list projects when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestListProjects/fails_to_list_projects_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/projects",
+		},
+		{
+			name:       "can list projects",
 			want:       http.StatusOK,
-			respFile:   "TestCreateTarget/can_create_target_response.json",
+			respFile:   "TestListProjects/can_list_projects_response.json",
 			authHeader: adminAuthHeader,
-			url:        "/projects/projectalreadyexists/targets",
-			method:     "POST",
-			cpMock: &th.CredsProviderMock{
-				CreateTargetFunc:  func(s string, target types.Target) error { return nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return false, nil },
+			method:     "GET",
+			url:        "/projects?limit=2&name=proj",
+			dbMock: &th.DBClientMock{
+				ListProjectEntriesFunc: func(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error) {
+					if filter != (db.ProjectFilter{Limit: 2, Name: "proj"}) {
+						return nil, 0, fmt.Errorf("unexpected filter %+v", filter)
+					}
+					return []db.ProjectEntry{
+						{
+							Description: "Platform infrastructure",
+							Labels:      db.ProjectLabels{"cost-center": "1234"},
+							ProjectID:   "project1",
+							Repository:  "git@github.com:myorg/project1.git",
+							Team:        "platform",
+						},
+						{
+							ProjectID:  "project2",
+							Repository: "git@github.com:myorg/project2.git",
+						},
+					}, 3, nil
+				},
 			},
 		},
 		{
-			name:       "fails to create target when not admin",
-			req:        loadJSON(t, "TestCreateTarget/fails_to_create_target_when_not_admin_request.json"),
-			want:       http.StatusUnauthorized,
-			respFile:   "TestCreateTarget/fails_to_create_target_when_not_admin_response.json",
-			authHeader: userAuthHeader,
-			url:        "/projects/projectalreadyexists/targets",
-			method:     "POST",
-		},
-		{
-			name:       "fails to create target when using a bad auth header",
-			req:        loadJSON(t, "TestCreateTarget/can_create_target_request.json"),
-			want:       http.StatusUnauthorized,
-			respFile:   "TestCreateTarget/fails_to_create_target_when_bad_auth_header_response.json",
-			authHeader: invalidAuthHeader,
-			url:        "/projects/projectalreadyexists/targets",
-			method:     "POST",
+			name:       "no projects",
+			want:       http.StatusOK,
+			respFile:   "TestListProjects/no_projects_response.json",
+			authHeader: adminAuthHeader,
+			method:     "GET",
+			url:        "/projects",
+			dbMock: &th.DBClientMock{
+				ListProjectEntriesFunc: func(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error) {
+					return []db.ProjectEntry{}, 0, nil
+				},
+			},
 		},
 		{
-			name:       "bad request",
-			req:        loadJSON(t, "TestCreateTarget/bad_request.json"),
+			name:       "limit too large",
 			want:       http.StatusBadRequest,
-			respFile:   "TestCreateTarget/bad_response.json",
+			respFile:   "TestListProjects/limit_too_large_response.json",
 			authHeader: adminAuthHeader,
-			url:        "/projects/projectalreadyexists/targets",
-			method:     "POST",
+			method:     "GET",
+			url:        "/projects?limit=501",
 		},
 		{
-			name:       "target name cannot already exist",
-			req:        loadJSON(t, "TestCreateTarget/target_name_cannot_already_exist_request.json"),
+			name:       "name must be alphanumeric",
 			want:       http.StatusBadRequest,
-			respFile:   "TestCreateTarget/target_name_cannot_already_exist_response.json",
+			respFile:   "TestListProjects/name_must_be_alphanumeric_response.json",
 			authHeader: adminAuthHeader,
-			url:        "/projects/projectalreadyexists/targets",
-			method:     "POST",
-			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return true, nil },
-			},
+			method:     "GET",
+			url:        "/projects?name=proj_",
 		},
 		{
-			name:       "project must exist",
-			req:        loadJSON(t, "TestCreateTarget/project_must_exist_request.json"),
-			want:       http.StatusBadRequest,
-			respFile:   "TestCreateTarget/project_must_exist_response.json",
+			name:       "list projects error",
+			want:       http.StatusInternalServerError,
+			respFile:   "TestListProjects/list_projects_error_response.json",
 			authHeader: adminAuthHeader,
-			url:        "/projects/projectdoesnotexist/targets",
-			method:     "POST",
-			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+			method:     "GET",
+			url:        "/projects?offset=50",
+			dbMock: &th.DBClientMock{
+				ListProjectEntriesFunc: func(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error) {
+					return nil, 0, errors.New("error")
+				},
 			},
 		},
 	}
 	runTests(t, tests)
 }
 
-func TestDeleteTarget(t *testing.T) {
+func TestUpdateProject(t *testing.T) {
 	tests := []test{
 		{
-			name:       "fails to delete target when not admin",
+			name:       "fails to update project when not admin",
+			req:        loadJSON(t, "TestUpdateProject/can_update_project_request.json"),
 			want:       http.StatusUnauthorized,
+			respFile:   "TestUpdateProject/fails_to_update_project_when_not_admin_response.json",
 			authHeader: userAuthHeader,
-			url:        "/projects/projectalreadyexists/targets/target1",
-			method:     "DELETE",
+			method:     "PATCH",
+			url:        "/projects/project1",
 		},
 		{
-			name:       "fails to delete target when using a bad auth header",
-			want:       http.StatusUnauthorized,
+			name:       "can update project",
+			req:        loadJSON(t, "TestUpdateProject/can_update_project_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestUpdateProject/can_update_project_response.json",
+			authHeader: adminAuthHeader,
+			method:     "PATCH",
+			url:        "/projects/project1",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{
+						Description: "Platform infrastructure",
+						Labels:      db.ProjectLabels{"cost-center": "1234"},
+						ProjectID:   "project1",
+						Repository:  "git@github.com:myorg/repo.git",
+						Team:        "platform",
+					}, nil
+				},
+				UpdateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error {
+					want := db.ProjectEntry{
+						Description: "Platform infrastructure",
+						Labels:      db.ProjectLabels{"tier": "1"},
+						ProjectID:   "project1",
+						Repository:  "https://github.com/myorg/newrepo.git",
+						Team:        "platform",
+					}
+					if !reflect.DeepEqual(want, pe) {
+						return fmt.Errorf("unexpected project entry %+v", pe)
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:       "invalid repository",
+			req:        loadJSON(t, "TestUpdateProject/invalid_repository_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestUpdateProject/invalid_repository_response.json",
+			authHeader: adminAuthHeader,
+			method:     "PATCH",
+			url:        "/projects/project1",
+		},
+		{
+			name:       "project does not exist",
+			req:        loadJSON(t, "TestUpdateProject/can_update_project_request.json"),
+			want:       http.StatusNotFound,
+			respFile:   "TestUpdateProject/project_does_not_exist_response.json",
+			authHeader: adminAuthHeader,
+			method:     "PATCH",
+			url:        "/projects/project1",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
+			},
+		},
+		{
+			name:       "update error",
+			req:        loadJSON(t, "TestUpdateProject/can_update_project_request.json"),
+			want:       http.StatusInternalServerError,
+			respFile:   "TestUpdateProject/update_error_response.json",
+			authHeader: adminAuthHeader,
+			method:     "PATCH",
+			url:        "/projects/project1",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "git@github.com:myorg/repo.git"}, nil
+				},
+				UpdateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error {
+					return errors.New("error")
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestCreateTarget(t *testing.T) {
+	tests := []test{
+		{
+			name:       "can create target",
+			req:        loadJSON(t, "TestCreateTarget/can_create_target_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestCreateTarget/can_create_target_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectalreadyexists/targets",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				CreateTargetFunc:  func(ctx context.Context, s string, target types.Target) error { return nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return false, nil },
+			},
+			dbMock: &th.DBClientMock{
+				UpsertTargetEntryFunc: func(ctx context.Context, te db.TargetEntry, write func() error) error { return write() },
+			},
+		},
+		{
+			name:       "fails to create target",
+			req:        loadJSON(t, "TestCreateTarget/can_create_target_request.json"),
+			want:       http.StatusInternalServerError,
+			respFile:   "TestCreateTarget/fails_to_create_target_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectalreadyexists/targets",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				CreateTargetFunc:  func(ctx context.Context, s string, target types.Target) error { return errors.New("error") },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return false, nil },
+			},
+			dbMock: &th.DBClientMock{
+				UpsertTargetEntryFunc: func(ctx context.Context, te db.TargetEntry, write func() error) error { return write() },
+			},
+		},
+		{
+			name:       "fails to create target when not admin",
+			req:        loadJSON(t, "TestCreateTarget/fails_to_create_target_when_not_admin_request.json"),
+			want:       http.StatusUnauthorized,
+			respFile:   "TestCreateTarget/fails_to_create_target_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/projects/projectalreadyexists/targets",
+			method:     "POST",
+		},
+		{
+			name:       "fails to create target when using a bad auth header",
+			req:        loadJSON(t, "TestCreateTarget/can_create_target_request.json"),
+			want:       http.StatusUnauthorized,
+			respFile:   "TestCreateTarget/fails_to_create_target_when_bad_auth_header_response.json",
+			authHeader: invalidAuthHeader,
+			url:        "/projects/projectalreadyexists/targets",
+			method:     "POST",
+		},
+		{
+			name:       "bad request",
+			req:        loadJSON(t, "TestCreateTarget/bad_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestCreateTarget/bad_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectalreadyexists/targets",
+			method:     "POST",
+		},
+		{
+			name:       "target name cannot already exist",
+			req:        loadJSON(t, "TestCreateTarget/target_name_cannot_already_exist_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestCreateTarget/target_name_cannot_already_exist_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectalreadyexists/targets",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+			},
+		},
+		{
+			name:       "project must exist",
+			req:        loadJSON(t, "TestCreateTarget/project_must_exist_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestCreateTarget/project_must_exist_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectdoesnotexist/targets",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestDeleteTarget(t *testing.T) {
+	tests := []test{
+		{
+			name:       "fails to delete target when not admin",
+			want:       http.StatusUnauthorized,
+			authHeader: userAuthHeader,
+			url:        "/projects/projectalreadyexists/targets/target1",
+			method:     "DELETE",
+		},
+		{
+			name:       "fails to delete target when using a bad auth header",
+			want:       http.StatusUnauthorized,
 			authHeader: invalidAuthHeader,
 			url:        "/projects/projectalreadyexists/targets/target1",
 			method:     "DELETE",
@@ -594,7 +1108,10 @@ func TestDeleteTarget(t *testing.T) {
 			url:        "/projects/projectalreadyexists/targets/target1",
 			method:     "DELETE",
 			cpMock: &th.CredsProviderMock{
-				DeleteTargetFunc: func(s1, s2 string) error { return nil },
+				DeleteTargetFunc: func(ctx context.Context, s1, s2 string) error { return nil },
+			},
+			dbMock: &th.DBClientMock{
+				DeleteTargetEntryFunc: func(ctx context.Context, project, target string, remove func() error) error { return remove() },
 			},
 		},
 		{
@@ -604,7 +1121,10 @@ func TestDeleteTarget(t *testing.T) {
 			url:        "/projects/projectalreadyexists/targets/undeletabletarget",
 			method:     "DELETE",
 			cpMock: &th.CredsProviderMock{
-				DeleteTargetFunc: func(s1, s2 string) error { return errors.New("error") },
+				DeleteTargetFunc: func(ctx context.Context, s1, s2 string) error { return errors.New("error") },
+			},
+			dbMock: &th.DBClientMock{
+				DeleteTargetEntryFunc: func(ctx context.Context, project, target string, remove func() error) error { return remove() },
 			},
 		},
 	}
@@ -622,7 +1142,7 @@ func TestUpdateTarget(t *testing.T) {
 			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS",
 			method:     "PATCH",
 			cpMock: &th.CredsProviderMock{
-				GetTargetFunc: func(s1, s2 string) (types.Target, error) {
+				GetTargetFunc: func(ctx context.Context, s1, s2 string) (types.Target, error) {
 					return types.Target{
 						Name: "TARGET_EXISTS",
 						Properties: types.TargetProperties{
@@ -634,9 +1154,15 @@ func TestUpdateTarget(t *testing.T) {
 						Type: "aws_account",
 					}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return true, nil },
-				UpdateTargetFunc:  func(s string, target types.Target) error { return nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				UpdateTargetFunc:  func(ctx context.Context, s string, target types.Target) error { return nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+				UpsertTargetEntryFunc: func(ctx context.Context, te db.TargetEntry, write func() error) error { return write() },
 			},
 		},
 		{
@@ -666,7 +1192,7 @@ func TestUpdateTarget(t *testing.T) {
 			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS",
 			method:     "PATCH",
 			cpMock: &th.CredsProviderMock{
-				GetTargetFunc: func(s1, s2 string) (types.Target, error) {
+				GetTargetFunc: func(ctx context.Context, s1, s2 string) (types.Target, error) {
 					return types.Target{
 						Name: "TARGET_EXISTS",
 						Properties: types.TargetProperties{
@@ -678,8 +1204,14 @@ func TestUpdateTarget(t *testing.T) {
 						Type: "aws_account",
 					}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return true, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+				UpsertTargetEntryFunc: func(ctx context.Context, te db.TargetEntry, write func() error) error { return write() },
 			},
 		},
 		{
@@ -691,7 +1223,7 @@ func TestUpdateTarget(t *testing.T) {
 			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS",
 			method:     "PATCH",
 			cpMock: &th.CredsProviderMock{
-				GetTargetFunc: func(s1, s2 string) (types.Target, error) {
+				GetTargetFunc: func(ctx context.Context, s1, s2 string) (types.Target, error) {
 					return types.Target{
 						Name: "TARGET_EXISTS",
 						Properties: types.TargetProperties{
@@ -703,9 +1235,15 @@ func TestUpdateTarget(t *testing.T) {
 						Type: "aws_account",
 					}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return true, nil },
-				UpdateTargetFunc:  func(s string, target types.Target) error { return nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				UpdateTargetFunc:  func(ctx context.Context, s string, target types.Target) error { return nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+				UpsertTargetEntryFunc: func(ctx context.Context, te db.TargetEntry, write func() error) error { return write() },
 			},
 		},
 		{
@@ -717,8 +1255,8 @@ func TestUpdateTarget(t *testing.T) {
 			url:        "/projects/projectalreadyexists/targets/INVALID_TARGET",
 			method:     "PATCH",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return false, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return false, nil },
 			},
 		},
 		{
@@ -730,7 +1268,7 @@ func TestUpdateTarget(t *testing.T) {
 			url:        "/projects/projectdoesnotexist/targets/TARGET_EXISTS",
 			method:     "PATCH",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
 			},
 		},
 	}
@@ -748,15 +1286,29 @@ func TestCreateWorkflow(t *testing.T) {
 			method:     "POST",
 			url:        "/workflows",
 			cpMock: &th.CredsProviderMock{
-				GetTokenFunc:      func() (string, error) { return testPassword, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return true, nil },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
 			},
 			wfMock: &th.WorkflowMock{
 				SubmitFunc: func(ctx context.Context, from string, parameters, labels map[string]string) (string, error) {
 					return workflowResponse, nil
 				},
 			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: project, Repository: "repo"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
 		},
 		// We test this specific validation as it's server side only.
 		{
@@ -768,6 +1320,15 @@ func TestCreateWorkflow(t *testing.T) {
 			method:     "POST",
 			url:        "/workflows",
 		},
+		{
+			name:       "targets are only supported by manifests",
+			req:        loadJSON(t, "TestCreateWorkflow/targets_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflow/targets_response.json",
+			method:     "POST",
+			url:        "/workflows",
+		},
 		// We test this specific validation as it's server side only.
 		{
 			name:       "type must be valid",
@@ -786,8 +1347,8 @@ func TestCreateWorkflow(t *testing.T) {
 			method:     "POST",
 			url:        "/workflows",
 			cpMock: &th.CredsProviderMock{
-				GetTokenFunc:      func() (string, error) { return testPassword, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
 			},
 		},
 		{
@@ -798,734 +1359,2806 @@ func TestCreateWorkflow(t *testing.T) {
 			method:     "POST",
 			url:        "/workflows",
 			cpMock: &th.CredsProviderMock{
-				GetTokenFunc:      func() (string, error) { return testPassword, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return false, nil },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return false, nil },
 			},
 		},
 		{
-			name:       "cannot create workflow with bad auth header",
-			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_response.json"),
-			want:       http.StatusUnauthorized,
-			authHeader: invalidAuthHeader,
-			method:     "POST",
-			url:        "/workflows",
-		},
-		// TODO with admin credentials should fail
-	}
-	runTests(t, tests)
-}
-
-func TestCreateWorkflowFromGit(t *testing.T) {
-	tests := []test{
-		{
-			name:       "can create workflows",
-			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			name:       "sets target region environment variables",
+			req:        loadJSON(t, "TestCreateWorkflow/target_region_request.json"),
 			want:       http.StatusOK,
 			authHeader: userAuthHeader,
-			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			respFile:   "TestCreateWorkflow/can_create_workflow_response.json",
 			method:     "POST",
-			url:        "/projects/project1/targets/target1/operations",
+			url:        "/workflows",
 			cpMock: &th.CredsProviderMock{
-				GetTokenFunc:      func() (string, error) { return testPassword, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return true, nil },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
 			},
 			dbMock: &th.DBClientMock{
 				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{
-						ProjectID:  "project1",
-						Repository: "repo",
-					}, nil
+					return db.ProjectEntry{ProjectID: project, Repository: "repo"}, nil
 				},
-			},
-			gitMock: &th.GitClientMock{
-				GetManifestFileFunc: func(repository, commitHash, path string) ([]byte, error) {
-					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{ProjectID: project, TargetName: target, Region: "eu-west-1"}, nil
 				},
 			},
 			wfMock: &th.WorkflowMock{
-				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+				SubmitFunc: func(ctx context.Context, from string, parameters, labels map[string]string) (string, error) {
+					if !strings.Contains(parameters["environment_variables_string"], "AWS_DEFAULT_REGION='eu-west-1'") {
+						return "", errors.New("failed to set AWS_DEFAULT_REGION from target region")
+					}
+					if !strings.Contains(parameters["environment_variables_string"], "AWS_REGION='us-west-2'") {
+						return "", errors.New("overwrote AWS_REGION provided in request")
+					}
 					return workflowResponse, nil
 				},
 			},
 		},
 		{
-			name:       "workflows environment variables",
-			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
-			want:       http.StatusOK,
+			name:       "project requires signed commits",
+			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_request.json"),
+			want:       http.StatusForbidden,
 			authHeader: userAuthHeader,
-			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			body:       `{"error_message":"error forbidden, sync operations of target TARGET_EXISTS require a signed commit from git"}`,
 			method:     "POST",
-			url:        "/projects/project1/targets/target1/operations",
+			url:        "/workflows",
 			cpMock: &th.CredsProviderMock{
-				GetTokenFunc:      func() (string, error) { return testPassword, nil },
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-				TargetExistsFunc:  func(s1, s2 string) (bool, error) { return true, nil },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
 			},
 			dbMock: &th.DBClientMock{
 				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{
-						ProjectID:  "project1",
-						Repository: "repo",
-					}, nil
+					return db.ProjectEntry{ProjectID: project, Repository: "repo", RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
 				},
-			},
-			gitMock: &th.GitClientMock{
-				GetManifestFileFunc: func(repository, commitHash, path string) ([]byte, error) {
-					return loadFileBytes("TestCreateWorkflow/create_workflow_env_variables.json")
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
 				},
-			},
-			wfMock: &th.WorkflowMock{
-				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
-					if !strings.Contains(parameters["environment_variables_string"], "variable_with_single_quote='someones value'") {
-						return "", errors.New("failed to quote string with single quote in it")
-					}
-					if !strings.Contains(parameters["environment_variables_string"], "single_quoted_variable='single_quoted_variable'") {
-						return "", errors.New("failed to quote string with single quotes quited it")
-					}
-					if !strings.Contains(parameters["environment_variables_string"], "double_quoted_variable='double_quoted_variable'") {
-						return "", errors.New("failed to quote string with double quotes quited it")
-					}
-					if !strings.Contains(parameters["environment_variables_string"], "user='first_name last_name'") {
-						return "", errors.New("failed to quote string with empty space it")
-					}
-					if !strings.Contains(parameters["environment_variables_string"], "foobar='barfoo'") {
-						return "", errors.New("failed to quote string")
-					}
-					if !strings.Contains(parameters["environment_variables_string"], "variable_with_double_quote='I love book Harry Potter'") {
-						return "", errors.New("failed to quote string with double quote in it")
-					}
-					return workflowResponse, nil
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
 				},
 			},
 		},
 		{
-			name:       "bad request",
-			req:        loadJSON(t, "TestCreateWorkflowFromGit/bad_request.json"),
-			want:       http.StatusBadRequest,
+			name:       "target requires signed commits",
+			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_request.json"),
+			want:       http.StatusForbidden,
 			authHeader: userAuthHeader,
-			respFile:   "TestCreateWorkflowFromGit/bad_response.json",
+			body:       `{"error_message":"error forbidden, sync operations of target TARGET_EXISTS require a signed commit from git"}`,
 			method:     "POST",
-			url:        "/projects/project1/targets/target1/operations",
-		},
-		// TODO with admin credentials should fail
-	}
-	runTests(t, tests)
-}
-
-func TestGetWorkflow(t *testing.T) {
-	tests := []test{
-		{
-			name:       "workflow exists, successful get workflow",
-			want:       http.StatusOK,
-			authHeader: adminAuthHeader,
-			method:     "GET",
-			url:        "/workflows/WORKFLOW_ALREADY_EXISTS",
-			wfMock: &th.WorkflowMock{
-				StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
-					return &workflow.Status{Status: "success"}, nil
+			url:        "/workflows",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
 				},
 			},
-		},
-		{
-			name:       "workflow does not exist",
-			want:       http.StatusNotFound,
-			authHeader: adminAuthHeader,
-			method:     "GET",
-			url:        "/workflows/WORKFLOW_DOES_NOT_EXIST",
-			wfMock: &th.WorkflowMock{
-				StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
-					return &workflow.Status{Status: "failed"}, errors.New("rpc error: code = NotFound desc = workflows.argoproj.io \"WORKFLOW_DOES_NOT_EXIST\" not found")
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
 				},
 			},
 		},
 		{
-			name:       "workflow internal error",
-			want:       http.StatusInternalServerError,
-			authHeader: adminAuthHeader,
-			method:     "GET",
-			url:        "/workflows/WORKFLOW_ERROR",
-			wfMock: &th.WorkflowMock{
-				StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
-					return &workflow.Status{Status: "failed"}, errors.New("rpc error: code = workflow error desc = unknown")
+			name:       "token scopes must allow operation",
+			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_request.json"),
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflow/token_scopes_must_allow_operation_response.json",
+			method:     "POST",
+			url:        "/workflows",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
 				},
 			},
-		},
-	}
-	runTests(t, tests)
-}
-
-func TestGetWorkflowLogs(t *testing.T) {
-	tests := []test{
-		{
-			// TODO: this should be renamed to logs already exists, and a test case created to
-			// test an actual success
-			name:       "successful get workflow logs",
-			want:       http.StatusOK,
-			authHeader: adminAuthHeader,
-			method:     "GET",
-			url:        "/workflows/WORKFLOW_ALREADY_EXISTS/logs",
-			wfMock: &th.WorkflowMock{
-				LogsFunc: func(ctx context.Context, workflowName string) (*workflow.Logs, error) { return nil, nil },
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: token, Scopes: db.TokenScopes{Operations: []string{"diff"}}}, nil
+				},
 			},
 		},
 		{
-			name:       "workflow does not exist",
-			want:       http.StatusInternalServerError,
-			authHeader: adminAuthHeader,
-			method:     "GET",
-			url:        "/workflows/WORKFLOW_DOES_NOT_EXIST/logs",
-			wfMock: &th.WorkflowMock{
-				LogsFunc: func(ctx context.Context, workflowName string) (*workflow.Logs, error) {
-					return nil, errors.New("workflow does not exist")
+			name:       "token scopes must allow target",
+			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_request.json"),
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			method:     "POST",
+			url:        "/workflows",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: token, Scopes: db.TokenScopes{Targets: []string{"prod_*"}}}, nil
 				},
 			},
 		},
-	}
-	runTests(t, tests)
-}
-
-func TestListWorkflows(t *testing.T) {
-	tests := []test{
 		{
-			name:       "can get workflows",
-			want:       http.StatusOK,
+			name:       "token must not be expired",
+			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_request.json"),
+			want:       http.StatusUnauthorized,
 			authHeader: userAuthHeader,
-			method:     "GET",
-			url:        "/projects/projects1/targets/target1/workflows",
-			wfMock: &th.WorkflowMock{
-				ListStatusFunc: func(ctx context.Context) ([]workflow.Status, error) {
-					return []workflow.Status{
-						{
-							Name:     "project1-target1-abcde",
-							Status:   "succeeded",
-							Created:  "1658514800",
-							Finished: "1658514856",
-						},
-						{
-							Name:     "project2-target2-12345",
-							Status:   "succeeded",
-							Created:  "1658514764",
-							Finished: "1658514793",
-						},
-					}, nil
+			method:     "POST",
+			url:        "/workflows",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: token, ExpiresAt: "2022-06-21T14:56:10.341066-07:00"}, nil
 				},
 			},
 		},
 		{
-			name:       "no workflows",
-			want:       http.StatusOK,
+			name:       "token must belong to project",
+			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_request.json"),
+			want:       http.StatusUnauthorized,
 			authHeader: userAuthHeader,
-			method:     "GET",
-			url:        "/projects/projects1/targets/target1/workflows",
-			wfMock: &th.WorkflowMock{
-				ListStatusFunc: func(ctx context.Context) ([]workflow.Status, error) {
-					return []workflow.Status{}, nil
+			method:     "POST",
+			url:        "/workflows",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{}, credentials.ErrProjectTokenNotFound
 				},
 			},
 		},
+		{
+			name:       "cannot create workflow with bad auth header",
+			req:        loadJSON(t, "TestCreateWorkflow/can_create_workflow_response.json"),
+			want:       http.StatusUnauthorized,
+			authHeader: invalidAuthHeader,
+			method:     "POST",
+			url:        "/workflows",
+		},
+		// TODO with admin credentials should fail
 	}
 	runTests(t, tests)
 }
 
-func TestDeleteToken(t *testing.T) {
+func TestCreateWorkflowFromGit(t *testing.T) {
 	tests := []test{
 		{
-			name:       "fails to delete tokens when not admin",
-			want:       http.StatusUnauthorized,
-			respFile:   "TestDeleteToken/fails_to_delete_token_when_not_admin_response.json",
-			authHeader: userAuthHeader,
-			url:        "/projects/project/tokens/existingtoken",
-			method:     "DELETE",
-		},
-		{
-			name:       "can delete token",
+			name:       "can create workflows",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
 			want:       http.StatusOK,
-			respFile:   "TestDeleteToken/can_delete_token_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/project/tokens/existingtoken",
-			method:     "DELETE",
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
 			cpMock: &th.CredsProviderMock{
-				GetProjectTokenFunc: func(s1 string, s2 string) (types.ProjectToken, error) {
-					return types.ProjectToken{ID: "1234"}, nil
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
 				},
-				ProjectExistsFunc:      func(s string) (bool, error) { return true, nil },
-				DeleteProjectTokenFunc: func(p, t string) error { return nil },
 			},
 			dbMock: &th.DBClientMock{
-				DeleteTokenEntryFunc: func(ctx context.Context, token string) error { return nil },
 				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{ProjectID: "project1"}, nil
+					return db.ProjectEntry{
+						ProjectID:  "project1",
+						Repository: "repo",
+					}, nil
 				},
 				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
-					return db.TokenEntry{ProjectID: "project1", TokenID: "1234", CreatedAt: "2022-06-21T14:42:50.182037-07:00"}, nil
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
 				},
 			},
-		},
-		{
-			name:       "project does not exist",
-			want:       http.StatusNotFound,
-			respFile:   "TestDeleteToken/project_does_not_exist_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/projectdoesnotexist/tokens/tokendoesnotexist",
-			method:     "DELETE",
-			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					return workflowResponse, nil
+				},
 			},
 		},
 		{
-			name:       "token does not exist in DB or CP",
+			name:       "workflows environment variables",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
 			want:       http.StatusOK,
-			respFile:   "TestDeleteToken/can_delete_token_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/project/tokens/tokendoesnotexist",
-			method:     "DELETE",
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
 			cpMock: &th.CredsProviderMock{
-				GetProjectTokenFunc: func(s1 string, s2 string) (types.ProjectToken, error) {
-					return types.ProjectToken{}, nil
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
 				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{ProjectID: "project1"}, nil
+					return db.ProjectEntry{
+						ProjectID:  "project1",
+						Repository: "repo",
+					}, nil
 				},
 				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
-					return db.TokenEntry{}, nil
+					return db.TokenEntry{}, upper.ErrNoMoreRows
 				},
-			},
-		},
-		{
-			name:       "token exists in CP but not in DB",
-			want:       http.StatusOK,
-			respFile:   "TestDeleteToken/can_delete_token_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/project/tokens/tokenonlyincp",
-			method:     "DELETE",
-			cpMock: &th.CredsProviderMock{
-				DeleteProjectTokenFunc: func(s1, s2 string) error { return nil },
-				GetProjectTokenFunc: func(s1 string, s2 string) (types.ProjectToken, error) {
-					return types.ProjectToken{ID: "tokenonlyincp"}, nil
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
 			},
-			dbMock: &th.DBClientMock{
-				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{ProjectID: "project1"}, nil
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/create_workflow_env_variables.json")
 				},
-				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
-					return db.TokenEntry{}, nil
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if !strings.Contains(parameters["environment_variables_string"], "variable_with_single_quote='someones value'") {
+						return "", errors.New("failed to quote string with single quote in it")
+					}
+					if !strings.Contains(parameters["environment_variables_string"], "single_quoted_variable='single_quoted_variable'") {
+						return "", errors.New("failed to quote string with single quotes quited it")
+					}
+					if !strings.Contains(parameters["environment_variables_string"], "double_quoted_variable='double_quoted_variable'") {
+						return "", errors.New("failed to quote string with double quotes quited it")
+					}
+					if !strings.Contains(parameters["environment_variables_string"], "user='first_name last_name'") {
+						return "", errors.New("failed to quote string with empty space it")
+					}
+					if !strings.Contains(parameters["environment_variables_string"], "foobar='barfoo'") {
+						return "", errors.New("failed to quote string")
+					}
+					if !strings.Contains(parameters["environment_variables_string"], "variable_with_double_quote='I love book Harry Potter'") {
+						return "", errors.New("failed to quote string with double quote in it")
+					}
+					return workflowResponse, nil
 				},
 			},
 		},
 		{
-			name:       "token exists in DB but not in CP",
+			name:       "can create workflows from ref",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/ref_request.json"),
 			want:       http.StatusOK,
-			respFile:   "TestDeleteToken/can_delete_token_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/project/tokens/tokenonlyindb",
-			method:     "DELETE",
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/ref_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
 			cpMock: &th.CredsProviderMock{
-				GetProjectTokenFunc: func(s1 string, s2 string) (types.ProjectToken, error) {
-					return types.ProjectToken{}, nil
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
-				DeleteTokenEntryFunc: func(ctx context.Context, token string) error { return nil },
 				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{ProjectID: "project1"}, nil
+					return db.ProjectEntry{
+						ProjectID:  "project1",
+						Repository: "repo",
+					}, nil
 				},
 				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
-					return db.TokenEntry{TokenID: "tokenonlyindb"}, nil
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
 				},
 			},
-		},
-		{
-			name:       "token delete error",
-			want:       http.StatusInternalServerError,
-			respFile:   "TestDeleteToken/token_delete_error_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/project/tokens/deletetokenerror",
-			method:     "DELETE",
+			gitMock: &th.GitClientMock{
+				ResolveRefFunc: func(ctx context.Context, repository git.Repository, ref string) (string, error) {
+					if repository.URL != "repo" || ref != "main" {
+						return "", fmt.Errorf("unexpected ref %s of repository %s", ref, repository.URL)
+					}
+					return "8458fd753f9fde51882414564c20df6d4c34a90e", nil
+				},
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					if commitHash != "8458fd753f9fde51882414564c20df6d4c34a90e" {
+						return nil, fmt.Errorf("unexpected commit %s", commitHash)
+					}
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if labels["git-sha"] != "8458fd753f9fde51882414564c20df6d4c34a90e" {
+						return "", fmt.Errorf("unexpected git-sha label %s", labels["git-sha"])
+					}
+					return workflowResponse, nil
+				},
+			},
+		},
+		{
+			name:       "can create workflows from abbreviated shas",
+			req:        requests.CreateGitWorkflow{CommitHash: "8458fd7", Path: "path/to/manifest.yaml", Type: "sync"},
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/ref_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
 			cpMock: &th.CredsProviderMock{
-				DeleteProjectTokenFunc: func(s1, s2 string) error { return errors.New("error deleting token from Vault") },
-				GetProjectTokenFunc: func(s1 string, s2 string) (types.ProjectToken, error) {
-					return types.ProjectToken{ID: "1234"}, nil
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
 				},
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
 			},
 			dbMock: &th.DBClientMock{
-				DeleteTokenEntryFunc: func(ctx context.Context, token string) error { return errors.New("error deleting entry from DB") },
 				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{ProjectID: "project1"}, nil
+					return db.ProjectEntry{
+						ProjectID:  "project1",
+						Repository: "repo",
+					}, nil
 				},
 				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
-					return db.TokenEntry{ProjectID: "project1", TokenID: "1234", CreatedAt: "2022-06-21T14:42:50.182037-07:00"}, nil
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			gitMock: &th.GitClientMock{
+				ResolveRefFunc: func(ctx context.Context, repository git.Repository, ref string) (string, error) {
+					if repository.URL != "repo" || ref != "8458fd7" {
+						return "", fmt.Errorf("unexpected ref %s of repository %s", ref, repository.URL)
+					}
+					return "8458fd753f9fde51882414564c20df6d4c34a90e", nil
+				},
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					if commitHash != "8458fd753f9fde51882414564c20df6d4c34a90e" {
+						return nil, fmt.Errorf("unexpected commit %s", commitHash)
+					}
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if labels["git-sha"] != "8458fd753f9fde51882414564c20df6d4c34a90e" {
+						return "", fmt.Errorf("unexpected git-sha label %s", labels["git-sha"])
+					}
+					return workflowResponse, nil
 				},
 			},
 		},
-	}
-	runTests(t, tests)
-}
-
-func TestListTokens(t *testing.T) {
-	tests := []test{
 		{
-			name:       "fails to list tokens when not admin",
-			want:       http.StatusUnauthorized,
-			respFile:   "TestListTokens/fails_to_list_tokens_when_not_admin_response.json",
+			name:       "ref not found",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/ref_request.json"),
+			want:       http.StatusBadRequest,
 			authHeader: userAuthHeader,
-			url:        "/projects/undeletableprojecttargets/tokens",
-			method:     "GET",
+			respFile:   "TestCreateWorkflowFromGit/ref_not_found_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				ResolveRefFunc: func(ctx context.Context, repository git.Repository, ref string) (string, error) {
+					return "", fmt.Errorf("%w: %s", git.ErrRefNotFound, ref)
+				},
+			},
 		},
 		{
-			name:       "can list tokens",
+			name:       "abbreviated sha not found",
+			req:        requests.CreateGitWorkflow{CommitHash: "8458fd7", Path: "path/to/manifest.yaml", Type: "sync"},
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			body:       `{"error_message":"invalid request, sha '8458fd7' not found"}`,
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				ResolveRefFunc: func(ctx context.Context, repository git.Repository, ref string) (string, error) {
+					return "", fmt.Errorf("%w: %s", git.ErrRefNotFound, ref)
+				},
+			},
+		},
+		{
+			name:       "ambiguous abbreviated sha",
+			req:        requests.CreateGitWorkflow{CommitHash: "8458", Path: "path/to/manifest.yaml", Type: "sync"},
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			body:       `{"error_message":"invalid request, sha '8458' matches several commits"}`,
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				ResolveRefFunc: func(ctx context.Context, repository git.Repository, ref string) (string, error) {
+					return "", fmt.Errorf("%w: %s", git.ErrAmbiguousRef, ref)
+				},
+			},
+		},
+		{
+			name:       "sha must be a commit sha",
+			req:        requests.CreateGitWorkflow{CommitHash: "main", Path: "path/to/manifest.yaml", Type: "sync"},
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			body:       `{"error_message":"invalid request, sha must be a full or abbreviated commit sha"}`,
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+		},
+		{
+			name:       "fails to resolve ref",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/ref_request.json"),
+			want:       http.StatusInternalServerError,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/fails_to_resolve_ref_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				ResolveRefFunc: func(ctx context.Context, repository git.Repository, ref string) (string, error) {
+					return "", errors.New("connection refused")
+				},
+			},
+		},
+		{
+			name:       "manifests inherit the project and target and the type overrides the manifest",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
 			want:       http.StatusOK,
-			respFile:   "TestListTokens/can_list_tokens_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/undeletableprojecttargets/tokens",
-			method:     "GET",
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/target1/operations",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
 			},
 			dbMock: &th.DBClientMock{
-				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
 					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
 				},
-				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
-					return []db.TokenEntry{
-						{
-							CreatedAt: "2022-06-21T14:56:10.341066-07:00",
-							ExpiresAt: "2023-06-21T14:56:10.341066-07:00",
-							TokenID:   "ghi789",
-						},
-						{
-							CreatedAt: "2022-06-21T14:43:16.172896-07:00",
-							ExpiresAt: "2023-06-21T14:43:16.172896-07:00",
-							TokenID:   "def456",
-						},
-						{
-							CreatedAt: "2022-06-21T14:42:50.182037-07:00",
-							ExpiresAt: "2023-06-21T14:42:50.182037-07:00",
-							TokenID:   "abc123",
-						},
-					}, nil
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflowFromGit/manifest_without_target.json")
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if parameters["project_name"] != "project1" || parameters["target_name"] != "target1" || parameters["type"] != "sync" {
+						return "", fmt.Errorf("unexpected parameters %+v", parameters)
+					}
+					return workflowResponse, nil
 				},
 			},
 		},
 		{
-			name:       "project not found",
-			want:       http.StatusNotFound,
-			respFile:   "TestListTokens/project_not_found_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/projectdoesnotexist/tokens",
-			method:     "GET",
-			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, nil },
+			name:       "manifest target does not match",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/target_mismatch_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/prod/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "projectalreadyexists", Repository: "repo"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
 			},
 		},
 		{
-			name:       "no tokens",
+			name:       "runs the target of manifests with targets",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
 			want:       http.StatusOK,
-			respFile:   "TestListTokens/no_tokens_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/projectnotokens/tokens",
-			method:     "GET",
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/prod_east/operations",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
 			},
 			dbMock: &th.DBClientMock{
-				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{ProjectID: "abc123", Repository: "repo"}, nil
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
 				},
-				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
-					return []db.TokenEntry{}, nil
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateBatchFromGit/manifest.json")
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if parameters["target_name"] != "prod_east" || !strings.Contains(parameters["environment_variables_string"], "STAGE='prod'") {
+						return "", fmt.Errorf("unexpected parameters %+v", parameters)
+					}
+					return workflowResponse, nil
 				},
 			},
 		},
 		{
-			name:       "project read error",
-			want:       http.StatusInternalServerError,
-			respFile:   "TestListTokens/project_read_error_response.json",
-			authHeader: adminAuthHeader,
-			url:        "/projects/projectreaderror/tokens",
-			method:     "GET",
+			name:       "applies manifest overlays and variables",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/prod_east/operations",
 			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return false, errors.New("error retrieving project") },
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{ProjectID: project, TargetName: target, Region: "us-east-1"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestPreviewWorkflowFromGit/manifest.json")
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if !strings.Contains(parameters["environment_variables_string"], "STAGE='prod'") ||
+						!strings.Contains(parameters["environment_variables_string"], "AWS_REGION='us-east-1'") ||
+						!strings.Contains(parameters["execute_command"], "project1-prod_east") {
+						return "", fmt.Errorf("unexpected parameters %+v", parameters)
+					}
+					return workflowResponse, nil
+				},
+			},
+		},
+		{
+			name:       "manifest targets must include the target",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/manifest_targets_mismatch_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/staging_east/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateBatchFromGit/manifest.json")
+				},
+			},
+		},
+		{
+			name:       "manifest project does not match",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/project_mismatch_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
 			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+			},
+		},
+		{
+			name:       "path outside the repository",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/path_traversal_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/path_traversal_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+		},
+		{
+			name:       "path outside the manifest root",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/outside_manifest_root_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo", ManifestRoot: "cello"}, nil
+				},
+			},
+		},
+		{
+			name:       "manifest too large",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/manifest_too_large_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo", ManifestRoot: "path/"}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return nil, fmt.Errorf("%w: '%s' is 2048 bytes, the max is 1024 bytes", git.ErrManifestTooLarge, path)
+				},
+			},
+		},
+		{
+			name:       "project requires signed commits",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "projectalreadyexists", Repository: "repo", RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+				VerifyCommitFunc: func(ctx context.Context, repository git.Repository, commitHash string) (git.Signature, error) {
+					if repository.URL != "repo" || commitHash != "1234567890abcdef1234567890abcdef12345678" {
+						return git.Signature{}, fmt.Errorf("unexpected commit %s of repository %s", commitHash, repository.URL)
+					}
+					return git.Signature{KeyID: "0123456789ABCDEF0123456789ABCDEF01234567", Type: "gpg"}, nil
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if labels["git-signature-type"] != "gpg" || labels["git-signature-key"] != "0123456789ABCDEF0123456789ABCDEF01234567" {
+						return "", fmt.Errorf("unexpected signature labels %+v", labels)
+					}
+					return workflowResponse, nil
+				},
+			},
+		},
+		{
+			name:       "target requires signed commits",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "projectalreadyexists", Repository: "repo"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+				VerifyCommitFunc: func(ctx context.Context, repository git.Repository, commitHash string) (git.Signature, error) {
+					if repository.URL != "repo" || commitHash != "1234567890abcdef1234567890abcdef12345678" {
+						return git.Signature{}, fmt.Errorf("unexpected commit %s of repository %s", commitHash, repository.URL)
+					}
+					return git.Signature{KeyID: "0123456789ABCDEF0123456789ABCDEF01234567", Type: "gpg"}, nil
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if labels["git-signature-type"] != "gpg" || labels["git-signature-key"] != "0123456789ABCDEF0123456789ABCDEF01234567" {
+						return "", fmt.Errorf("unexpected signature labels %+v", labels)
+					}
+					return workflowResponse, nil
+				},
+			},
+		},
+		{
+			name:       "operations without signed commit policy are not verified",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/diff_request.json"),
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			cpMock: &th.CredsProviderMock{
+				GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+				TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "projectalreadyexists", Repository: "repo", RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+					if _, ok := labels["git-signature-key"]; ok {
+						return "", fmt.Errorf("unexpected signature labels %+v", labels)
+					}
+					return workflowResponse, nil
+				},
+			},
+		},
+		{
+			name:       "unsigned commit",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/unsigned_commit_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "projectalreadyexists", Repository: "repo", RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+				VerifyCommitFunc: func(ctx context.Context, repository git.Repository, commitHash string) (git.Signature, error) {
+					return git.Signature{}, git.ErrUnsignedCommit
+				},
+			},
+		},
+		{
+			name:       "commit not signed by a trusted key",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/untrusted_signature_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "projectalreadyexists", Repository: "repo"}, nil
+				},
+				ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+					return db.TargetEntry{RequireSignedCommits: db.SignedCommitPolicy{"sync"}}, nil
+				},
+			},
+			gitMock: &th.GitClientMock{
+				GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+					return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+				},
+				VerifyCommitFunc: func(ctx context.Context, repository git.Repository, commitHash string) (git.Signature, error) {
+					return git.Signature{}, fmt.Errorf("%w: ssh key is not trusted", git.ErrUntrustedSignature)
+				},
+			},
+		},
+		{
+			name:       "bad request",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/bad_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestCreateWorkflowFromGit/bad_response.json",
+			method:     "POST",
+			url:        "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations",
+		},
+		// TODO with admin credentials should fail
+	}
+	runTests(t, tests)
+}
+
+func TestPreviewWorkflowFromGit(t *testing.T) {
+	cpMock := &th.CredsProviderMock{
+		LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+			return types.ProjectToken{ID: "secret-id-accessor"}, nil
+		},
+	}
+	gitMock := func(manifest string) *th.GitClientMock {
+		return &th.GitClientMock{
+			GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+				return loadFileBytes(manifest)
+			},
+		}
+	}
+	dbMock := func(scopes db.TokenScopes) *th.DBClientMock {
+		return &th.DBClientMock{
+			ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+				return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+			},
+			ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+				return db.TokenEntry{TokenID: token, Scopes: scopes}, nil
+			},
+			ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+				if target == "prod_east" {
+					return db.TargetEntry{ProjectID: project, TargetName: target, Region: "us-east-1"}, nil
+				}
+				return db.TargetEntry{ProjectID: project, TargetName: target, Properties: db.TargetProperties{Region: "us-west-2"}}, nil
+			},
+		}
+	}
+
+	tests := []test{
+		{
+			name:       "applies the overlays of the target and interpolates variables",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			respFile:   "TestPreviewWorkflowFromGit/good_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/prod_east/operations/preview",
+			cpMock:     cpMock,
+			dbMock:     dbMock(db.TokenScopes{}),
+			gitMock:    gitMock("TestPreviewWorkflowFromGit/manifest.json"),
+		},
+		{
+			name:       "targets without overlays use the base manifest",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			respFile:   "TestPreviewWorkflowFromGit/dev_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/dev_west/operations/preview",
+			cpMock:     cpMock,
+			dbMock:     dbMock(db.TokenScopes{}),
+			gitMock:    gitMock("TestPreviewWorkflowFromGit/manifest.json"),
+		},
+		{
+			name:       "token scopes must allow the target",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			respFile:   "TestPreviewWorkflowFromGit/forbidden_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/prod_east/operations/preview",
+			cpMock:     cpMock,
+			dbMock:     dbMock(db.TokenScopes{Targets: []string{"dev_*"}}),
+			gitMock:    gitMock("TestPreviewWorkflowFromGit/manifest.json"),
+		},
+		{
+			name:       "manifest references unknown target variable",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusBadRequest,
+			authHeader: userAuthHeader,
+			respFile:   "TestPreviewWorkflowFromGit/unknown_variable_response.json",
+			method:     "POST",
+			url:        "/projects/project1/targets/prod_east/operations/preview",
+			cpMock:     cpMock,
+			dbMock:     dbMock(db.TokenScopes{}),
+			gitMock:    gitMock("TestPreviewWorkflowFromGit/manifest_unknown_variable.json"),
+		},
+		{
+			name:       "bad auth header",
+			req:        loadJSON(t, "TestCreateWorkflowFromGit/good_request.json"),
+			want:       http.StatusUnauthorized,
+			authHeader: invalidAuthHeader,
+			method:     "POST",
+			url:        "/projects/project1/targets/prod_east/operations/preview",
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestGetWorkflow(t *testing.T) {
+	tests := []test{
+		{
+			name:   "authorization header is required",
+			want:   http.StatusUnauthorized,
+			body:   `{"error_message":"error unauthorized, invalid authorization header format"}`,
+			method: "GET",
+			url:    "/workflows/project1-target1-abcde",
+		},
+		{
+			name:       "workflow exists, successful get workflow",
+			want:       http.StatusOK,
+			authHeader: adminAuthHeader,
+			method:     "GET",
+			url:        "/workflows/WORKFLOW_ALREADY_EXISTS",
+			wfMock: &th.WorkflowMock{
+				StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
+					return &workflow.Status{Status: "success"}, nil
+				},
+			},
+		},
+		{
+			name:       "workflow does not exist",
+			want:       http.StatusNotFound,
+			authHeader: adminAuthHeader,
+			method:     "GET",
+			url:        "/workflows/WORKFLOW_DOES_NOT_EXIST",
+			wfMock: &th.WorkflowMock{
+				StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
+					return &workflow.Status{Status: "failed"}, errors.New("rpc error: code = NotFound desc = workflows.argoproj.io \"WORKFLOW_DOES_NOT_EXIST\" not found")
+				},
+			},
+		},
+		{
+			name:       "workflow internal error",
+			want:       http.StatusInternalServerError,
+			authHeader: adminAuthHeader,
+			method:     "GET",
+			url:        "/workflows/WORKFLOW_ERROR",
+			wfMock: &th.WorkflowMock{
+				StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
+					return &workflow.Status{Status: "failed"}, errors.New("rpc error: code = workflow error desc = unknown")
+				},
+			},
+		},
+		{
+			name:       "token scopes must allow target",
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/workflows/project1-target1-abcde",
+			cpMock: &th.CredsProviderMock{
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: token, Scopes: db.TokenScopes{ReadOnly: true, Targets: []string{"prod_*"}}}, nil
+				},
+			},
+		},
+		{
+			name:       "token scopes allow target",
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/workflows/project1-prod_account-abcde",
+			cpMock: &th.CredsProviderMock{
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: token, Scopes: db.TokenScopes{ReadOnly: true, Targets: []string{"prod_*"}}}, nil
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				StatusFunc: func(ctx context.Context, workflowName string) (*workflow.Status, error) {
+					return &workflow.Status{Status: "success"}, nil
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestGetWorkflowLogs(t *testing.T) {
+	tests := []test{
+		{
+			// TODO: this should be renamed to logs already exists, and a test case created to
+			// test an actual success
+			name:       "successful get workflow logs",
+			want:       http.StatusOK,
+			authHeader: adminAuthHeader,
+			method:     "GET",
+			url:        "/workflows/WORKFLOW_ALREADY_EXISTS/logs",
+			wfMock: &th.WorkflowMock{
+				LogsFunc: func(ctx context.Context, workflowName string) (*workflow.Logs, error) { return nil, nil },
+			},
+		},
+		{
+			name:       "workflow does not exist",
+			want:       http.StatusInternalServerError,
+			authHeader: adminAuthHeader,
+			method:     "GET",
+			url:        "/workflows/WORKFLOW_DOES_NOT_EXIST/logs",
+			wfMock: &th.WorkflowMock{
+				LogsFunc: func(ctx context.Context, workflowName string) (*workflow.Logs, error) {
+					return nil, errors.New("workflow does not exist")
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestListWorkflows(t *testing.T) {
+	tests := []test{
+		{
+			name:       "can get workflows",
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/projects/projects1/targets/target1/workflows",
+			cpMock: &th.CredsProviderMock{
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				ListStatusFunc: func(ctx context.Context) ([]workflow.Status, error) {
+					return []workflow.Status{
+						{
+							Name:     "project1-target1-abcde",
+							Status:   "succeeded",
+							Created:  "1658514800",
+							Finished: "1658514856",
+						},
+						{
+							Name:     "project2-target2-12345",
+							Status:   "succeeded",
+							Created:  "1658514764",
+							Finished: "1658514793",
+						},
+					}, nil
+				},
+			},
+		},
+		{
+			name:       "no workflows",
+			want:       http.StatusOK,
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/projects/projects1/targets/target1/workflows",
+			cpMock: &th.CredsProviderMock{
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, upper.ErrNoMoreRows
+				},
+			},
+			wfMock: &th.WorkflowMock{
+				ListStatusFunc: func(ctx context.Context) ([]workflow.Status, error) {
+					return []workflow.Status{}, nil
+				},
+			},
+		},
+		{
+			name:       "token scopes must allow target",
+			want:       http.StatusForbidden,
+			authHeader: userAuthHeader,
+			method:     "GET",
+			url:        "/projects/projects1/targets/target1/workflows",
+			cpMock: &th.CredsProviderMock{
+				LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "secret-id-accessor"}, nil
+				},
+			},
+			dbMock: &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: token, Scopes: db.TokenScopes{ReadOnly: true, Targets: []string{"prod_*"}}}, nil
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestDeleteToken(t *testing.T) {
+	tests := []test{
+		{
+			name:       "fails to delete tokens when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestDeleteToken/fails_to_delete_token_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/projects/project/tokens/existingtoken",
+			method:     "DELETE",
+		},
+		{
+			name:       "can delete token",
+			want:       http.StatusOK,
+			respFile:   "TestDeleteToken/can_delete_token_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project/tokens/existingtoken",
+			method:     "DELETE",
+			cpMock: &th.CredsProviderMock{
+				GetProjectTokenFunc: func(ctx context.Context, s1 string, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "1234"}, nil
+				},
+				ProjectExistsFunc:      func(ctx context.Context, s string) (bool, error) { return true, nil },
+				DeleteProjectTokenFunc: func(ctx context.Context, p, t string) error { return nil },
+			},
+			dbMock: &th.DBClientMock{
+				DeleteTokenEntryFunc: func(ctx context.Context, token string) error { return nil },
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{ProjectID: "project1", TokenID: "1234", CreatedAt: "2022-06-21T14:42:50.182037-07:00"}, nil
+				},
+			},
+		},
+		{
+			name:       "project does not exist",
+			want:       http.StatusNotFound,
+			respFile:   "TestDeleteToken/project_does_not_exist_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectdoesnotexist/tokens/tokendoesnotexist",
+			method:     "DELETE",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
+			},
+		},
+		{
+			name:       "token does not exist in DB or CP",
+			want:       http.StatusOK,
+			respFile:   "TestDeleteToken/can_delete_token_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project/tokens/tokendoesnotexist",
+			method:     "DELETE",
+			cpMock: &th.CredsProviderMock{
+				GetProjectTokenFunc: func(ctx context.Context, s1 string, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, nil
+				},
+			},
+		},
+		{
+			name:       "token exists in CP but not in DB",
+			want:       http.StatusOK,
+			respFile:   "TestDeleteToken/can_delete_token_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project/tokens/tokenonlyincp",
+			method:     "DELETE",
+			cpMock: &th.CredsProviderMock{
+				DeleteProjectTokenFunc: func(ctx context.Context, s1, s2 string) error { return nil },
+				GetProjectTokenFunc: func(ctx context.Context, s1 string, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "tokenonlyincp"}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{}, nil
+				},
+			},
+		},
+		{
+			name:       "token exists in DB but not in CP",
+			want:       http.StatusOK,
+			respFile:   "TestDeleteToken/can_delete_token_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project/tokens/tokenonlyindb",
+			method:     "DELETE",
+			cpMock: &th.CredsProviderMock{
+				GetProjectTokenFunc: func(ctx context.Context, s1 string, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				DeleteTokenEntryFunc: func(ctx context.Context, token string) error { return nil },
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{TokenID: "tokenonlyindb"}, nil
+				},
+			},
+		},
+		{
+			name:       "token delete error",
+			want:       http.StatusInternalServerError,
+			respFile:   "TestDeleteToken/token_delete_error_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project/tokens/deletetokenerror",
+			method:     "DELETE",
+			cpMock: &th.CredsProviderMock{
+				DeleteProjectTokenFunc: func(ctx context.Context, s1, s2 string) error { return errors.New("error deleting token from Vault") },
+				GetProjectTokenFunc: func(ctx context.Context, s1 string, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "1234"}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				DeleteTokenEntryFunc: func(ctx context.Context, token string) error { return errors.New("error deleting entry from DB") },
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return db.TokenEntry{ProjectID: "project1", TokenID: "1234", CreatedAt: "2022-06-21T14:42:50.182037-07:00"}, nil
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestRotateToken(t *testing.T) {
+	oldToken := db.TokenEntry{
+		CreatedAt: "2022-06-21T14:42:50.182037-07:00",
+		ExpiresAt: "2023-06-21T14:42:50.182037-07:00",
+		ProjectID: "project1",
+		Scopes:    db.TokenScopes{Operations: []string{"diff"}},
+		TokenID:   "1234",
+	}
+	newToken := func(ctx context.Context, s string, ttl time.Duration) (types.Token, error) {
+		return types.Token{
+			CreatedAt: "2022-06-21T14:56:10.341066-07:00",
+			ExpiresAt: "2023-06-21T14:56:10.341066-07:00",
+			ProjectID: "project1",
+			ProjectToken: types.ProjectToken{
+				ID: "secret-id-accessor",
+			},
+			RoleID: "role-id",
+			Secret: "secret",
+		}, nil
+	}
+
+	tests := []test{
+		{
+			name:       "fails to rotate token when not admin",
+			want:       http.StatusUnauthorized,
+			authHeader: userAuthHeader,
+			url:        "/projects/project1/tokens/1234/rotate",
+			method:     "POST",
+		},
+		{
+			name:       "can rotate token",
+			want:       http.StatusOK,
+			respFile:   "TestRotateToken/can_rotate_token_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project1/tokens/1234/rotate",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				CreateTokenFunc:        newToken,
+				DeleteProjectTokenFunc: func(ctx context.Context, s1, s2 string) error { return nil },
+				GetProjectTokenFunc: func(ctx context.Context, s1, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "1234"}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return oldToken, nil
+				},
+				RotateTokenEntryFunc: func(ctx context.Context, old string, token types.Token, oldExpiresAt string, revoke func() error) error {
+					if old != "1234" || oldExpiresAt != "" || revoke == nil {
+						return errors.New("old token not revoked")
+					}
+					if !token.Scopes.AllowsOperation("diff") || token.Scopes.AllowsOperation("sync") {
+						return errors.New("token scopes not kept")
+					}
+					return revoke()
+				},
+			},
+		},
+		{
+			name:       "can rotate token with grace period",
+			req:        loadJSON(t, "TestRotateToken/grace_period_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestRotateToken/can_rotate_token_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project1/tokens/1234/rotate",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				CreateTokenFunc: newToken,
+				GetProjectTokenFunc: func(ctx context.Context, s1, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "1234"}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return oldToken, nil
+				},
+				RotateTokenEntryFunc: func(ctx context.Context, old string, token types.Token, oldExpiresAt string, revoke func() error) error {
+					if oldExpiresAt == "" || revoke != nil {
+						return errors.New("old token revoked before grace period")
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:       "token does not exist",
+			want:       http.StatusNotFound,
+			respFile:   "TestRotateToken/token_does_not_exist_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project1/tokens/1234/rotate",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				GetProjectTokenFunc: func(ctx context.Context, s1, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{}, credentials.ErrProjectTokenNotFound
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+			},
+		},
+		{
+			name:       "token belongs to another project",
+			want:       http.StatusNotFound,
+			respFile:   "TestRotateToken/token_does_not_exist_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project2/tokens/1234/rotate",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				GetProjectTokenFunc: func(ctx context.Context, s1, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "1234"}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project2", Repository: "repo"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return oldToken, nil
+				},
+			},
+		},
+		{
+			name:       "replacement token is deleted when rotating fails",
+			want:       http.StatusInternalServerError,
+			authHeader: adminAuthHeader,
+			url:        "/projects/project1/tokens/1234/rotate",
+			method:     "POST",
+			cpMock: &th.CredsProviderMock{
+				CreateTokenFunc: newToken,
+				DeleteProjectTokenFunc: func(ctx context.Context, s1, s2 string) error {
+					if s2 != "secret-id-accessor" {
+						return errors.New("deleted wrong token")
+					}
+					return nil
+				},
+				GetProjectTokenFunc: func(ctx context.Context, s1, s2 string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: "1234"}, nil
+				},
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return oldToken, nil
+				},
+				RotateTokenEntryFunc: func(ctx context.Context, old string, token types.Token, oldExpiresAt string, revoke func() error) error {
+					return errors.New("error from DB")
+				},
+			},
+		},
+		{
+			name:       "token ttl cannot be greater than max",
+			req:        loadJSON(t, "TestCreateToken/ttl_too_long_request.json"),
+			want:       http.StatusBadRequest,
+			respFile:   "TestCreateToken/ttl_too_long_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project1/tokens/1234/rotate",
+			method:     "POST",
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestListTokens(t *testing.T) {
+	tests := []test{
+		{
+			name:       "fails to list tokens when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestListTokens/fails_to_list_tokens_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/projects/undeletableprojecttargets/tokens",
+			method:     "GET",
+		},
+		{
+			name:       "can list tokens",
+			want:       http.StatusOK,
+			respFile:   "TestListTokens/can_list_tokens_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/undeletableprojecttargets/tokens",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1", Repository: "repo"}, nil
+				},
+				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
+					return []db.TokenEntry{
+						{
+							CreatedAt: "2022-06-21T14:56:10.341066-07:00",
+							ExpiresAt: "2023-06-21T14:56:10.341066-07:00",
+							Scopes:    db.TokenScopes{ReadOnly: true, Targets: []string{"prod_*"}},
+							TokenID:   "ghi789",
+						},
+						{
+							CreatedAt: "2022-06-21T14:43:16.172896-07:00",
+							ExpiresAt: "2023-06-21T14:43:16.172896-07:00",
+							TokenID:   "def456",
+						},
+						{
+							CreatedAt: "2022-06-21T14:42:50.182037-07:00",
+							ExpiresAt: "2023-06-21T14:42:50.182037-07:00",
+							TokenID:   "abc123",
+						},
+					}, nil
+				},
+			},
+		},
+		{
+			name:       "project not found",
+			want:       http.StatusNotFound,
+			respFile:   "TestListTokens/project_not_found_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectdoesnotexist/tokens",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
+			},
+		},
+		{
+			name:       "no tokens",
+			want:       http.StatusOK,
+			respFile:   "TestListTokens/no_tokens_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectnotokens/tokens",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, p string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "abc123", Repository: "repo"}, nil
+				},
+				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
+					return []db.TokenEntry{}, nil
+				},
+			},
+		},
+		{
+			name:       "project read error",
+			want:       http.StatusInternalServerError,
+			respFile:   "TestListTokens/project_read_error_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectreaderror/tokens",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) {
+					return false, errors.New("error retrieving project")
+				},
+			},
+		},
+		{
+			name:       "list tokens read error",
+			want:       http.StatusInternalServerError,
+			respFile:   "TestListTokens/list_tokens_error_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/projectlisttokenserror/tokens",
+			method:     "GET",
+			cpMock: &th.CredsProviderMock{
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			dbMock: &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return db.ProjectEntry{ProjectID: "project1"}, nil
+				},
+				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
+					return []db.TokenEntry{}, errors.New("error from DB")
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name                  string
+		endpoint              string // Used to cause a connection error.
+		vaultStatusCode       int
+		writeBadContentLength bool // Used to create response body error.
+		wantResponseBody      string
+		wantStatusCode        int
+		wantResponseHeader    string
+		dbMock                *th.DBClientMock
+	}{
+		{
+			name:               "good_vault_200",
+			vaultStatusCode:    http.StatusOK,
+			wantResponseBody:   "Health check succeeded\n",
+			wantStatusCode:     http.StatusOK,
+			wantResponseHeader: "text/plain",
+			dbMock: &th.DBClientMock{
+				HealthFunc: func(ctx context.Context) error {
+					return nil
+				},
+			},
+		},
+		{
+			name:               "good_vault_429",
+			vaultStatusCode:    http.StatusTooManyRequests,
+			wantResponseBody:   "Health check succeeded\n",
+			wantStatusCode:     http.StatusOK,
+			wantResponseHeader: "text/plain",
+			dbMock: &th.DBClientMock{
+				HealthFunc: func(ctx context.Context) error {
+					return nil
+				},
+			},
+		},
+		{
+			// We want successful health check in this vault error scenario.
+			name:                  "error_vault_read_response",
+			vaultStatusCode:       http.StatusOK,
+			writeBadContentLength: true,
+			wantResponseBody:      "Health check succeeded\n",
+			wantStatusCode:        http.StatusOK,
+			wantResponseHeader:    "text/plain",
+			dbMock: &th.DBClientMock{
+				HealthFunc: func(ctx context.Context) error {
+					return nil
+				},
+			},
+		},
+		{
+			name:             "error_vault_connection",
+			endpoint:         string('\f'),
+			wantResponseBody: "Health check failed\n",
+			wantStatusCode:   http.StatusServiceUnavailable,
+		},
+		{
+			name:             "error_vault_unhealthy_status_code",
+			vaultStatusCode:  http.StatusInternalServerError,
+			wantResponseBody: "Health check failed\n",
+			wantStatusCode:   http.StatusServiceUnavailable,
+		},
+		{
+			name:             "bad_db",
+			vaultStatusCode:  http.StatusOK,
+			wantResponseBody: "Health check failed\n",
+			wantStatusCode:   http.StatusServiceUnavailable,
+			dbMock: &th.DBClientMock{
+				HealthFunc: func(ctx context.Context) error {
+					return errors.New("too many connections")
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantURL := "/v1/sys/health"
+
+			vaultSvc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != wantURL {
+					http.NotFound(w, r)
+				}
+
+				if r.Method != http.MethodGet {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				if tt.writeBadContentLength {
+					w.Header().Set("Content-Length", "1")
+				}
+
+				w.WriteHeader(tt.vaultStatusCode)
+			}))
+			defer vaultSvc.Close()
+
+			vaultEndpoint := vaultSvc.URL
+			if tt.endpoint != "" {
+				vaultEndpoint = tt.endpoint
+			}
+
+			h := handler{
+				logger: log.NewNopLogger(),
+				env: env.Vars{
+					VaultAddress: vaultEndpoint,
+				},
+				dbClient: tt.dbMock,
+			}
+
+			// Dummy request.
+			req, err := http.NewRequest("", "", nil)
+			if err != nil {
+				assert.Nil(t, err)
+			}
+
+			resp := httptest.NewRecorder()
+
+			h.healthCheck(resp, req)
+
+			respResult := resp.Result()
+			defer respResult.Body.Close()
+
+			body, err := io.ReadAll(respResult.Body)
+			assert.Nil(t, err)
+
+			assert.Equal(t, tt.wantStatusCode, respResult.StatusCode)
+			assert.Equal(t, tt.wantResponseBody, string(body))
+			assert.Equal(t, tt.wantResponseHeader, respResult.Header.Get("Content-Type"))
+		})
+	}
+}
+
+// Serialize a type to JSON-encoded byte buffer.
+func serialize(toMarshal interface{}) *bytes.Buffer {
+	jsonStr, _ := json.Marshal(toMarshal)
+	return bytes.NewBuffer(jsonStr)
+}
+
+func TestGetReconciliation(t *testing.T) {
+	tests := []test{
+		{
+			name:       "fails to get reconciliation when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestGetReconciliation/fails_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/admin/reconciliation",
+			method:     "GET",
+		},
+		{
+			name:       "can get reconciliation",
+			want:       http.StatusOK,
+			respFile:   "TestGetReconciliation/can_get_reconciliation_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/reconciliation",
+			method:     "GET",
+			dbMock: &th.DBClientMock{
+				ReadReconciliationEntryFunc: func(ctx context.Context, name string) (db.ReconciliationEntry, error) {
+					return db.ReconciliationEntry{
+						Name:   "default",
+						Holder: "host1",
+						Report: `{"errors":[],"findings":[{"issue":"expired_token","project":"project1","repaired":true,"token_id":"abc123"}],"finished_at":"2022-07-01T00:00:01Z","holder":"host1","repair":false,"started_at":"2022-07-01T00:00:00Z"}`,
+					}, nil
+				},
+			},
+		},
+		{
+			name:       "reconciliation has not run",
+			want:       http.StatusNotFound,
+			respFile:   "TestGetReconciliation/reconciliation_has_not_run_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/reconciliation",
+			method:     "GET",
+			dbMock: &th.DBClientMock{
+				ReadReconciliationEntryFunc: func(ctx context.Context, name string) (db.ReconciliationEntry, error) {
+					return db.ReconciliationEntry{}, upper.ErrNoMoreRows
+				},
+			},
+		},
+		{
+			name:       "reconciliation has not completed",
+			want:       http.StatusNotFound,
+			respFile:   "TestGetReconciliation/reconciliation_has_not_run_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/reconciliation",
+			method:     "GET",
+			dbMock: &th.DBClientMock{
+				ReadReconciliationEntryFunc: func(ctx context.Context, name string) (db.ReconciliationEntry, error) {
+					return db.ReconciliationEntry{Name: "default", Holder: "host1", Report: "{}"}, nil
+				},
+			},
+		},
+		{
+			name:       "reconciliation read error",
+			want:       http.StatusInternalServerError,
+			respFile:   "TestGetReconciliation/read_error_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/reconciliation",
+			method:     "GET",
+			dbMock: &th.DBClientMock{
+				ReadReconciliationEntryFunc: func(ctx context.Context, name string) (db.ReconciliationEntry, error) {
+					return db.ReconciliationEntry{}, errors.New("error")
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestGetDBStats(t *testing.T) {
+	tests := []test{
+		{
+			name:       "fails to get db stats when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestGetDBStats/fails_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/admin/db/stats",
+			method:     "GET",
+		},
+		{
+			name:       "can get db stats",
+			want:       http.StatusOK,
+			respFile:   "TestGetDBStats/can_get_db_stats_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/db/stats",
+			method:     "GET",
+			dbMock: &th.DBClientMock{
+				StatsFunc: func() sql.DBStats {
+					return sql.DBStats{
+						Idle:               3,
+						InUse:              2,
+						MaxIdleClosed:      4,
+						MaxIdleTimeClosed:  5,
+						MaxLifetimeClosed:  6,
+						MaxOpenConnections: 20,
+						OpenConnections:    5,
+						WaitCount:          7,
+						WaitDuration:       1500 * time.Millisecond,
+					}
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestListGitCache(t *testing.T) {
+	tests := []test{
+		{
+			name:       "fails to list git cache when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestListGitCache/fails_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/admin/git/cache",
+			method:     "GET",
+		},
+		{
+			name:       "can list git cache",
+			want:       http.StatusOK,
+			respFile:   "TestListGitCache/can_list_git_cache_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/git/cache",
+			method:     "GET",
+			gitMock: &th.GitClientMock{
+				ListCachedRepositoriesFunc: func() []git.CachedRepository {
+					return []git.CachedRepository{
+						{
+							Name:       "https:github.comcello-projcello.git",
+							Repository: "https://github.com/cello-proj/cello.git",
+							Size:       1048576,
+							LastUsed:   time.Date(2022, 6, 27, 21, 59, 58, 0, time.UTC),
+						},
+					}
+				},
+			},
+		},
+		{
+			name:       "can list empty git cache",
+			want:       http.StatusOK,
+			respFile:   "TestListGitCache/can_list_empty_git_cache_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/git/cache",
+			method:     "GET",
+			gitMock: &th.GitClientMock{
+				ListCachedRepositoriesFunc: func() []git.CachedRepository { return nil },
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+func TestPurgeGitCache(t *testing.T) {
+	tests := []test{
+		{
+			name:       "fails to purge git cache when not admin",
+			want:       http.StatusUnauthorized,
+			respFile:   "TestPurgeGitCache/fails_when_not_admin_response.json",
+			authHeader: userAuthHeader,
+			url:        "/admin/git/cache?repository=https://github.com/cello-proj/cello.git",
+			method:     "DELETE",
+		},
+		{
+			name:       "can purge repository",
+			want:       http.StatusOK,
+			respFile:   "TestPurgeGitCache/can_purge_repository_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/git/cache?repository=https://github.com/cello-proj/cello.git",
+			method:     "DELETE",
+			gitMock: &th.GitClientMock{
+				PurgeCachedRepositoryFunc: func(repository string) error {
+					if repository != "https://github.com/cello-proj/cello.git" {
+						return fmt.Errorf("unexpected repository %s", repository)
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name:       "repository is required",
+			want:       http.StatusBadRequest,
+			respFile:   "TestPurgeGitCache/repository_required_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/git/cache",
+			method:     "DELETE",
+		},
+		{
+			name:       "repository not cached",
+			want:       http.StatusNotFound,
+			respFile:   "TestPurgeGitCache/repository_not_cached_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/admin/git/cache?repository=https://github.com/cello-proj/cello.git",
+			method:     "DELETE",
+			gitMock: &th.GitClientMock{
+				PurgeCachedRepositoryFunc: func(repository string) error {
+					return git.ErrRepositoryNotCached
+				},
+			},
+		},
+	}
+	runTests(t, tests)
+}
+
+// newTestDB returns a migrated in-memory SQLite database.
+func newTestDB(t *testing.T) db.SQLClient {
+	t.Helper()
+
+	d, err := db.NewSQLiteClient(":memory:")
+	if err != nil {
+		t.Fatalf("unable to create test database: %s", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if _, err := d.MigrateUp(context.Background()); err != nil {
+		t.Fatalf("unable to migrate test database: %s", err)
+	}
+	return d
+}
+
+func TestProjectsWithDatabase(t *testing.T) {
+	database := newTestDB(t)
+
+	cpMock := &th.CredsProviderMock{
+		ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return false, nil },
+		CreateProjectFunc: func(ctx context.Context, s string) (types.Token, error) {
+			return types.Token{
+				CreatedAt:    "2022-06-01T00:00:00Z",
+				ExpiresAt:    "2023-06-01T00:00:00Z",
+				ProjectID:    s,
+				ProjectToken: types.ProjectToken{ID: s + "-token"},
+				RoleID:       "role-id",
+				Secret:       "secret",
+			}, nil
+		},
+	}
+
+	tests := []test{
+		{
+			name:       "creates project1",
+			req:        loadJSON(t, "TestProjectsWithDatabase/create_project1_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestProjectsWithDatabase/create_project1_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects",
+			method:     "POST",
+			cpMock:     cpMock,
+			db:         database,
+		},
+		{
+			name:       "creates project2",
+			req:        loadJSON(t, "TestProjectsWithDatabase/create_project2_request.json"),
+			want:       http.StatusOK,
+			respFile:   "TestProjectsWithDatabase/create_project2_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects",
+			method:     "POST",
+			cpMock:     cpMock,
+			db:         database,
+		},
+		{
+			name:       "gets project1",
+			want:       http.StatusOK,
+			respFile:   "TestProjectsWithDatabase/get_project_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects/project1",
+			method:     "GET",
+			cpMock:     cpMock,
+			db:         database,
+		},
+		{
+			name:       "lists projects",
+			want:       http.StatusOK,
+			respFile:   "TestProjectsWithDatabase/list_projects_response.json",
+			authHeader: adminAuthHeader,
+			url:        "/projects?limit=1&offset=1&name=PROJ",
+			method:     "GET",
+			db:         database,
+		},
+	}
+	runTests(t, tests)
+}
+
+func runTests(t *testing.T, tests []test) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadConfig(testConfigPath)
+			if err != nil {
+				panic(fmt.Sprintf("Unable to load config %s", err))
+			}
+
+			defaultCP := func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+				return &th.CredsProviderMock{}, nil
+			}
+
+			h := handler{
+				logger:                 log.NewNopLogger(),
+				newCredentialsProvider: defaultCP,
+				argoCtx:                context.Background(),
+				config:                 config,
+				gitClient:              &th.GitClientMock{},
+				env: env.Vars{
+					AdminSecret: testPassword,
+					TokenLimit:  2,
+					TokenMaxTTL: 8776 * time.Hour,
+				},
+				batches: newBatchRunner(time.Millisecond),
+				revoker: newTokenRevoker(),
+			}
+
+			if tt.dbMock != nil {
+				h.dbClient = tt.dbMock
+			}
+
+			if tt.db != nil {
+				h.dbClient = tt.db
+			}
+
+			if tt.cpMock != nil {
+				mockCP := func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					return tt.cpMock, nil
+				}
+
+				h.newCredentialsProvider = mockCP
+			}
+
+			if tt.gitMock != nil {
+				h.gitClient = tt.gitMock
+			}
+
+			if tt.wfMock != nil {
+				h.argo = tt.wfMock
+			}
+
+			resp := executeRequestWithHandler(h, tt.method, tt.url, serialize(tt.req), tt.authHeader)
+			h.batches.wait()
+			h.revoker.stop()
+			if resp.StatusCode != tt.want {
+				t.Errorf("Unexpected status code %d", resp.StatusCode)
+			}
+
+			if tt.body != "" {
+				bodyBytes, err := io.ReadAll(resp.Body)
+				defer resp.Body.Close()
+				if err != nil {
+					t.Errorf("Error loading body")
+				}
+				if tt.body != string(bodyBytes) {
+					t.Errorf("Unexpected body '%s', expected '%s'", bodyBytes, tt.body)
+				}
+			}
+
+			if tt.respFile != "" {
+				wantBody, err := loadFileBytes(tt.respFile)
+				if err != nil {
+					t.Fatalf("unable to read response file '%s', err: '%s'", tt.respFile, err)
+				}
+
+				body, err := io.ReadAll(resp.Body)
+				assert.Nil(t, err)
+
+				defer resp.Body.Close()
+
+				bodyStr := string(body)
+				wantBodyStr := string(wantBody)
+
+				// don't use assert.JSONEq for empty strings
+				if bodyStr == "" && wantBodyStr == "" {
+					assert.Equal(t, wantBodyStr, bodyStr)
+				} else {
+					assert.JSONEq(t, wantBodyStr, bodyStr)
+				}
+			}
+		})
+	}
+}
+
+func executeRequestWithHandler(h handler, method string, url string, body *bytes.Buffer, authHeader string) *http.Response {
+	router := setupRouter(h)
+	req, _ := http.NewRequest(method, url, body)
+
+	req.Header.Add("Authorization", authHeader)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+// loadFileBytes returns the contents of a file in the 'testdata' directory.
+func loadFileBytes(filename string) ([]byte, error) {
+	file := filepath.Join("test/testdata", filename)
+	return os.ReadFile(file)
+}
+
+// loadJSON unmarshals a JSON file from the testdata directory into output.
+func loadJSON(t *testing.T, filename string) (output interface{}) {
+	data, err := loadFileBytes(filename)
+	if err != nil {
+		t.Fatalf("failed to read file %s: %v", filename, err)
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatalf("failed to decode file %s: %v", filename, err)
+	}
+	return output
+}
+
+// targetCredentialsProviderMock is a credentials provider issuing target
+// credentials.
+type targetCredentialsProviderMock struct {
+	*th.CredsProviderMock
+	GetTargetCredentialsFunc func(ctx context.Context, projectName, targetName string) (types.TargetCredentials, error)
+}
+
+func (m targetCredentialsProviderMock) GetTargetCredentials(ctx context.Context, projectName, targetName string) (types.TargetCredentials, error) {
+	return m.GetTargetCredentialsFunc(ctx, projectName, targetName)
+}
+
+func TestGetTargetCredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		authHeader string
+		cp         credentials.Provider
+		want       int
+		body       string
+		respFile   string
+	}{
+		{
+			name:       "fails to get target credentials with invalid authorization header",
+			authHeader: invalidAuthHeader,
+			cp:         &th.CredsProviderMock{},
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "fails to get target credentials when provider does not issue them",
+			authHeader: userAuthHeader,
+			cp:         &th.CredsProviderMock{},
+			want:       http.StatusBadRequest,
+			body:       `{"error_message":"credentials provider does not issue target credentials"}`,
+		},
+		{
+			name:       "fails to get target credentials with invalid token",
+			authHeader: userAuthHeader,
+			cp: targetCredentialsProviderMock{
+				GetTargetCredentialsFunc: func(ctx context.Context, projectName, targetName string) (types.TargetCredentials, error) {
+					return types.TargetCredentials{}, credentials.ErrProjectTokenNotFound
+				},
+			},
+			want: http.StatusUnauthorized,
+			body: `{"error_message":"error unauthorized, invalid credentials token"}`,
+		},
+		{
+			name:       "fails to get target credentials when target does not exist",
+			authHeader: userAuthHeader,
+			cp: targetCredentialsProviderMock{
+				GetTargetCredentialsFunc: func(ctx context.Context, projectName, targetName string) (types.TargetCredentials, error) {
+					return types.TargetCredentials{}, credentials.ErrTargetNotFound
+				},
+			},
+			want: http.StatusNotFound,
+			body: `{"error_message":"target not found"}`,
+		},
+		{
+			name:       "fails to get target credentials when issuing fails",
+			authHeader: userAuthHeader,
+			cp: targetCredentialsProviderMock{
+				GetTargetCredentialsFunc: func(ctx context.Context, projectName, targetName string) (types.TargetCredentials, error) {
+					return types.TargetCredentials{}, errors.New("error")
+				},
+			},
+			want: http.StatusInternalServerError,
+			body: `{"error_message":"error retrieving target credentials"}`,
+		},
+		{
+			name:       "succeeds to get target credentials",
+			authHeader: userAuthHeader,
+			cp: targetCredentialsProviderMock{
+				GetTargetCredentialsFunc: func(ctx context.Context, projectName, targetName string) (types.TargetCredentials, error) {
+					if projectName != "project1" || targetName != "target1" {
+						return types.TargetCredentials{}, credentials.ErrTargetNotFound
+					}
+					return types.TargetCredentials{
+						AccessKeyID:     "AKIA",
+						Expiration:      "2022-07-01T01:00:00Z",
+						SecretAccessKey: "secret",
+						SessionToken:    "token",
+					}, nil
+				},
+			},
+			want:     http.StatusOK,
+			respFile: "TestGetTargetCredentials/succeeds_to_get_target_credentials_response.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler{
+				logger: log.NewNopLogger(),
+				newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					return tt.cp, nil
+				},
+				env: env.Vars{AdminSecret: testPassword},
+			}
+
+			resp := executeRequestWithHandler(h, http.MethodGet, "/projects/project1/targets/target1/credentials", serialize(nil), tt.authHeader)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.Nil(t, err)
+
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, string(body))
+			}
+
+			if tt.respFile != "" {
+				wantBody, err := loadFileBytes(tt.respFile)
+				assert.Nil(t, err)
+				assert.JSONEq(t, string(wantBody), string(body))
+			}
+		})
+	}
+}
+
+// vaultMigratorMock is a credentials provider migrating Vault projects.
+type vaultMigratorMock struct {
+	*th.CredsProviderMock
+	MigrateProjectsFunc func(ctx context.Context, from string, dryRun bool) (responses.VaultMigration, error)
+}
+
+func (m vaultMigratorMock) MigrateProjects(ctx context.Context, from string, dryRun bool) (responses.VaultMigration, error) {
+	return m.MigrateProjectsFunc(ctx, from, dryRun)
+}
+
+func TestMigrateVault(t *testing.T) {
+	migrator := func(err error) vaultMigratorMock {
+		return vaultMigratorMock{
+			MigrateProjectsFunc: func(ctx context.Context, from string, dryRun bool) (responses.VaultMigration, error) {
+				return responses.VaultMigration{
+					DryRun: dryRun,
+					From:   from,
+					Projects: []responses.VaultMigrationProject{
+						{Name: "project1", Targets: []string{"target1"}, Tokens: 1},
+					},
+					To: "cello-a",
+				}, err
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		authHeader  string
+		url         string
+		cp          credentials.Provider
+		want        int
+		body        string
+		respFile    string
+		wantDeletes []string
+	}{
+		{
+			name:       "fails to migrate vault when not admin",
+			authHeader: userAuthHeader,
+			url:        "/admin/vault/migrate",
+			cp:         migrator(nil),
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "fails to migrate vault when provider does not support it",
+			authHeader: adminAuthHeader,
+			url:        "/admin/vault/migrate",
+			cp:         &th.CredsProviderMock{},
+			want:       http.StatusBadRequest,
+			body:       `{"error_message":"credentials provider does not support vault migrations"}`,
+		},
+		{
+			name:       "fails to migrate vault with invalid dry run",
+			authHeader: adminAuthHeader,
+			url:        "/admin/vault/migrate?dry_run=maybe",
+			cp:         migrator(nil),
+			want:       http.StatusBadRequest,
+			body:       `{"error_message":"invalid request, dry_run must be a boolean"}`,
+		},
+		{
+			name:       "fails to migrate vault from the current prefix",
+			authHeader: adminAuthHeader,
+			url:        "/admin/vault/migrate?from=cello-a",
+			cp:         migrator(nil),
+			want:       http.StatusBadRequest,
+			body:       `{"error_message":"invalid request, from must be a project prefix other than the current one"}`,
+		},
+		{
+			name:       "fails to migrate vault when migration fails",
+			authHeader: adminAuthHeader,
+			url:        "/admin/vault/migrate",
+			cp:         migrator(errors.New("error")),
+			want:       http.StatusInternalServerError,
+			body:       `{"error_message":"error migrating vault projects"}`,
+		},
+		{
+			name:       "succeeds to dry run vault migration",
+			authHeader: adminAuthHeader,
+			url:        "/admin/vault/migrate?dry_run=true",
+			cp:         migrator(nil),
+			want:       http.StatusOK,
+			body:       `{"dry_run":true,"from":"argo-cloudops-projects","projects":[{"name":"project1","targets":["target1"],"tokens":1}],"to":"cello-a"}`,
+		},
+		{
+			name:        "succeeds to migrate vault",
+			authHeader:  adminAuthHeader,
+			url:         "/admin/vault/migrate",
+			cp:          migrator(nil),
+			want:        http.StatusOK,
+			respFile:    "TestMigrateVault/succeeds_to_migrate_vault_response.json",
+			wantDeletes: []string{"token1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deletes := []string{}
+			dbMock := &th.DBClientMock{
+				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
+					return []db.TokenEntry{{ProjectID: project, TokenID: "token1"}}, nil
+				},
+				DeleteTokenEntryFunc: func(ctx context.Context, token string) error {
+					deletes = append(deletes, token)
+					return nil
+				},
+			}
+
+			h := handler{
+				logger: log.NewNopLogger(),
+				newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					return tt.cp, nil
+				},
+				dbClient: dbMock,
+				env:      env.Vars{AdminSecret: testPassword, VaultProjectPrefix: "cello-a"},
+			}
+
+			resp := executeRequestWithHandler(h, http.MethodPost, tt.url, serialize(nil), tt.authHeader)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.Nil(t, err)
+
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, string(body))
+			}
+
+			if tt.respFile != "" {
+				wantBody, err := loadFileBytes(tt.respFile)
+				assert.Nil(t, err)
+				assert.JSONEq(t, string(wantBody), string(body))
+			}
+
+			if tt.wantDeletes == nil {
+				tt.wantDeletes = []string{}
+			}
+			assert.Equal(t, tt.wantDeletes, deletes)
+		})
+	}
+}
+
+// gitCredentialsProviderMock is a credentials provider keeping git
+// credentials.
+type gitCredentialsProviderMock struct {
+	*th.CredsProviderMock
+	DeleteGitCredentialsFunc func(ctx context.Context, ref string) error
+	ReadGitCredentialsFunc   func(ctx context.Context, ref string) (types.GitCredentials, error)
+	WriteGitCredentialsFunc  func(ctx context.Context, projectName string, c types.GitCredentials) (string, error)
+}
+
+func (m gitCredentialsProviderMock) DeleteGitCredentials(ctx context.Context, ref string) error {
+	return m.DeleteGitCredentialsFunc(ctx, ref)
+}
+
+func (m gitCredentialsProviderMock) ReadGitCredentials(ctx context.Context, ref string) (types.GitCredentials, error) {
+	return m.ReadGitCredentialsFunc(ctx, ref)
+}
+
+func (m gitCredentialsProviderMock) WriteGitCredentials(ctx context.Context, projectName string, c types.GitCredentials) (string, error) {
+	return m.WriteGitCredentialsFunc(ctx, projectName, c)
+}
+
+func TestProjectGitCredentials(t *testing.T) {
+	const ref = "secret/data/cello/project1/git"
+
+	provider := func(writeErr, deleteErr error) gitCredentialsProviderMock {
+		return gitCredentialsProviderMock{
+			CredsProviderMock: &th.CredsProviderMock{
+				DeleteProjectFunc: func(ctx context.Context, s string) error { return nil },
+				ListTargetsFunc:   func(ctx context.Context, s string) ([]string, error) { return []string{}, nil },
+				ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+			},
+			DeleteGitCredentialsFunc: func(ctx context.Context, ref string) error { return deleteErr },
+			WriteGitCredentialsFunc: func(ctx context.Context, projectName string, c types.GitCredentials) (string, error) {
+				if c.HTTPSUsername != "user" || c.HTTPSPassword != "token" {
+					return "", fmt.Errorf("unexpected credentials %+v", c)
+				}
+				return "secret/data/cello/" + projectName + "/git", writeErr
+			},
+		}
+	}
+
+	httpsCredentials := map[string]string{"https_username": "user", "https_password": "token"}
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		authHeader  string
+		req         interface{}
+		cp          credentials.Provider
+		entry       db.ProjectEntry
+		readErr     error
+		want        int
+		body        string
+		wantRefs    []string
+		wantDeletes []string
+	}{
+		{
+			name:       "fails to set git credentials when not admin",
+			method:     http.MethodPut,
+			url:        "/projects/project1/git-credentials",
+			authHeader: userAuthHeader,
+			req:        httpsCredentials,
+			cp:         provider(nil, nil),
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "fails to set git credentials with invalid request",
+			method:     http.MethodPut,
+			url:        "/projects/project1/git-credentials",
+			authHeader: adminAuthHeader,
+			req:        map[string]string{"https_username": "user"},
+			cp:         provider(nil, nil),
+			want:       http.StatusBadRequest,
+			body:       `{"error_message":"invalid request, ssh_private_key, or https_username and https_password, are required"}`,
+		},
+		{
+			name:       "fails to set git credentials with invalid ssh private key",
+			method:     http.MethodPut,
+			url:        "/projects/project1/git-credentials",
+			authHeader: adminAuthHeader,
+			req:        map[string]string{"ssh_private_key": "invalid"},
+			cp:         provider(nil, nil),
+			want:       http.StatusBadRequest,
+		},
+		{
+			name:       "fails to set git credentials when provider does not support it",
+			method:     http.MethodPut,
+			url:        "/projects/project1/git-credentials",
+			authHeader: adminAuthHeader,
+			req:        httpsCredentials,
+			cp:         &th.CredsProviderMock{},
+			want:       http.StatusBadRequest,
+			body:       `{"error_message":"credentials provider does not support git credentials"}`,
+		},
+		{
+			name:       "fails to set git credentials when project does not exist",
+			method:     http.MethodPut,
+			url:        "/projects/project1/git-credentials",
+			authHeader: adminAuthHeader,
+			req:        httpsCredentials,
+			cp:         provider(nil, nil),
+			readErr:    upper.ErrNoMoreRows,
+			want:       http.StatusNotFound,
+			body:       `{"error_message":"project does not exist"}`,
 		},
 		{
-			name:       "list tokens read error",
+			name:       "fails to set git credentials when write fails",
+			method:     http.MethodPut,
+			url:        "/projects/project1/git-credentials",
+			authHeader: adminAuthHeader,
+			req:        httpsCredentials,
+			cp:         provider(errors.New("error"), nil),
 			want:       http.StatusInternalServerError,
-			respFile:   "TestListTokens/list_tokens_error_response.json",
+			body:       `{"error_message":"error writing git credentials"}`,
+		},
+		{
+			name:       "sets git credentials",
+			method:     http.MethodPut,
+			url:        "/projects/project1/git-credentials",
 			authHeader: adminAuthHeader,
-			url:        "/projects/projectlisttokenserror/tokens",
-			method:     "GET",
-			cpMock: &th.CredsProviderMock{
-				ProjectExistsFunc: func(s string) (bool, error) { return true, nil },
-			},
-			dbMock: &th.DBClientMock{
-				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
-					return db.ProjectEntry{ProjectID: "project1"}, nil
-				},
-				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
-					return []db.TokenEntry{}, errors.New("error from DB")
-				},
-			},
+			req:        httpsCredentials,
+			cp:         provider(nil, nil),
+			want:       http.StatusOK,
+			body:       `{}`,
+			wantRefs:   []string{ref},
 		},
-	}
-	runTests(t, tests)
-}
-
-func TestHealthCheck(t *testing.T) {
-	tests := []struct {
-		name                  string
-		endpoint              string // Used to cause a connection error.
-		vaultStatusCode       int
-		writeBadContentLength bool // Used to create response body error.
-		wantResponseBody      string
-		wantStatusCode        int
-		wantResponseHeader    string
-		dbMock                *th.DBClientMock
-	}{
 		{
-			name:               "good_vault_200",
-			vaultStatusCode:    http.StatusOK,
-			wantResponseBody:   "Health check succeeded\n",
-			wantStatusCode:     http.StatusOK,
-			wantResponseHeader: "text/plain",
-			dbMock: &th.DBClientMock{
-				HealthFunc: func(ctx context.Context) error {
-					return nil
-				},
-			},
+			name:       "fails to delete git credentials when not admin",
+			method:     http.MethodDelete,
+			url:        "/projects/project1/git-credentials",
+			authHeader: userAuthHeader,
+			cp:         provider(nil, nil),
+			want:       http.StatusUnauthorized,
 		},
 		{
-			name:               "good_vault_429",
-			vaultStatusCode:    http.StatusTooManyRequests,
-			wantResponseBody:   "Health check succeeded\n",
-			wantStatusCode:     http.StatusOK,
-			wantResponseHeader: "text/plain",
-			dbMock: &th.DBClientMock{
-				HealthFunc: func(ctx context.Context) error {
-					return nil
-				},
-			},
+			name:       "fails to delete git credentials when project does not exist",
+			method:     http.MethodDelete,
+			url:        "/projects/project1/git-credentials",
+			authHeader: adminAuthHeader,
+			cp:         provider(nil, nil),
+			readErr:    upper.ErrNoMoreRows,
+			want:       http.StatusNotFound,
+			body:       `{"error_message":"project does not exist"}`,
 		},
 		{
-			// We want successful health check in this vault error scenario.
-			name:                  "error_vault_read_response",
-			vaultStatusCode:       http.StatusOK,
-			writeBadContentLength: true,
-			wantResponseBody:      "Health check succeeded\n",
-			wantStatusCode:        http.StatusOK,
-			wantResponseHeader:    "text/plain",
-			dbMock: &th.DBClientMock{
-				HealthFunc: func(ctx context.Context) error {
-					return nil
-				},
-			},
+			name:        "fails to delete git credentials when delete fails",
+			method:      http.MethodDelete,
+			url:         "/projects/project1/git-credentials",
+			authHeader:  adminAuthHeader,
+			cp:          provider(nil, errors.New("error")),
+			entry:       db.ProjectEntry{ProjectID: "project1", GitCredentials: ref},
+			want:        http.StatusInternalServerError,
+			body:        `{"error_message":"error deleting git credentials"}`,
+			wantDeletes: []string{ref},
 		},
 		{
-			name:             "error_vault_connection",
-			endpoint:         string('\f'),
-			wantResponseBody: "Health check failed\n",
-			wantStatusCode:   http.StatusServiceUnavailable,
+			name:        "deletes git credentials",
+			method:      http.MethodDelete,
+			url:         "/projects/project1/git-credentials",
+			authHeader:  adminAuthHeader,
+			cp:          provider(nil, nil),
+			entry:       db.ProjectEntry{ProjectID: "project1", GitCredentials: ref},
+			want:        http.StatusOK,
+			body:        `{}`,
+			wantRefs:    []string{""},
+			wantDeletes: []string{ref},
 		},
 		{
-			name:             "error_vault_unhealthy_status_code",
-			vaultStatusCode:  http.StatusInternalServerError,
-			wantResponseBody: "Health check failed\n",
-			wantStatusCode:   http.StatusServiceUnavailable,
+			name:       "deletes nothing when project has no git credentials",
+			method:     http.MethodDelete,
+			url:        "/projects/project1/git-credentials",
+			authHeader: adminAuthHeader,
+			cp:         provider(nil, nil),
+			entry:      db.ProjectEntry{ProjectID: "project1"},
+			want:       http.StatusOK,
+			body:       `{}`,
 		},
 		{
-			name:             "bad_db",
-			vaultStatusCode:  http.StatusOK,
-			wantResponseBody: "Health check failed\n",
-			wantStatusCode:   http.StatusServiceUnavailable,
-			dbMock: &th.DBClientMock{
-				HealthFunc: func(ctx context.Context) error {
-					return errors.New("too many connections")
-				},
-			},
+			name:        "deletes git credentials of deleted projects",
+			method:      http.MethodDelete,
+			url:         "/projects/project1",
+			authHeader:  adminAuthHeader,
+			cp:          provider(nil, nil),
+			entry:       db.ProjectEntry{ProjectID: "project1", GitCredentials: ref},
+			want:        http.StatusOK,
+			wantRefs:    []string{""},
+			wantDeletes: []string{ref},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			wantURL := "/v1/sys/health"
-
-			vaultSvc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if r.URL.Path != wantURL {
-					http.NotFound(w, r)
-				}
+			refs := []string{}
+			deletes := []string{}
 
-				if r.Method != http.MethodGet {
-					w.WriteHeader(http.StatusMethodNotAllowed)
-					return
-				}
-
-				if tt.writeBadContentLength {
-					w.Header().Set("Content-Length", "1")
+			if cp, ok := tt.cp.(gitCredentialsProviderMock); ok {
+				deleteFunc := cp.DeleteGitCredentialsFunc
+				cp.DeleteGitCredentialsFunc = func(ctx context.Context, ref string) error {
+					deletes = append(deletes, ref)
+					return deleteFunc(ctx, ref)
 				}
+				tt.cp = cp
+			}
 
-				w.WriteHeader(tt.vaultStatusCode)
-			}))
-			defer vaultSvc.Close()
-
-			vaultEndpoint := vaultSvc.URL
-			if tt.endpoint != "" {
-				vaultEndpoint = tt.endpoint
+			dbMock := &th.DBClientMock{
+				ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+					return tt.entry, tt.readErr
+				},
+				UpdateProjectGitCredentialsFunc: func(ctx context.Context, project, ref string) error {
+					refs = append(refs, ref)
+					return nil
+				},
+				DeleteProjectEntryFunc: func(ctx context.Context, project string) error { return nil },
 			}
 
 			h := handler{
 				logger: log.NewNopLogger(),
-				env: env.Vars{
-					VaultAddress: vaultEndpoint,
+				newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					return tt.cp, nil
 				},
-				dbClient: tt.dbMock,
-			}
-
-			// Dummy request.
-			req, err := http.NewRequest("", "", nil)
-			if err != nil {
-				assert.Nil(t, err)
+				dbClient: dbMock,
+				env:      env.Vars{AdminSecret: testPassword},
 			}
 
-			resp := httptest.NewRecorder()
+			resp := executeRequestWithHandler(h, tt.method, tt.url, serialize(tt.req), tt.authHeader)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode)
 
-			h.healthCheck(resp, req)
+			body, err := io.ReadAll(resp.Body)
+			assert.Nil(t, err)
 
-			respResult := resp.Result()
-			defer respResult.Body.Close()
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, string(body))
+			}
 
-			body, err := io.ReadAll(respResult.Body)
-			assert.Nil(t, err)
+			if tt.wantRefs == nil {
+				tt.wantRefs = []string{}
+			}
+			assert.Equal(t, tt.wantRefs, refs)
 
-			assert.Equal(t, tt.wantStatusCode, respResult.StatusCode)
-			assert.Equal(t, tt.wantResponseBody, string(body))
-			assert.Equal(t, tt.wantResponseHeader, respResult.Header.Get("Content-Type"))
+			if tt.wantDeletes == nil {
+				tt.wantDeletes = []string{}
+			}
+			assert.Equal(t, tt.wantDeletes, deletes)
 		})
 	}
 }
 
-// Serialize a type to JSON-encoded byte buffer.
-func serialize(toMarshal interface{}) *bytes.Buffer {
-	jsonStr, _ := json.Marshal(toMarshal)
-	return bytes.NewBuffer(jsonStr)
-}
+func TestCreateWorkflowFromGitCredentials(t *testing.T) {
+	const ref = "secret/data/cello/project1/git"
+
+	tests := []struct {
+		name    string
+		readErr error
+		want    int
+		body    string
+	}{
+		{
+			name: "reads manifests with the git credentials of the project",
+			want: http.StatusOK,
+		},
+		{
+			name:    "fails when the git credentials can't be read",
+			readErr: errors.New("error"),
+			want:    http.StatusInternalServerError,
+			body:    `{"error_message":"error reading git credentials"}`,
+		},
+	}
 
-func runTests(t *testing.T, tests []test) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			config, err := loadConfig(testConfigPath)
-			if err != nil {
-				panic(fmt.Sprintf("Unable to load config %s", err))
+			cp := gitCredentialsProviderMock{
+				CredsProviderMock: &th.CredsProviderMock{
+					GetTokenFunc:      func(ctx context.Context, projectName, targetName string) (string, error) { return testPassword, nil },
+					ProjectExistsFunc: func(ctx context.Context, s string) (bool, error) { return true, nil },
+					TargetExistsFunc:  func(ctx context.Context, s1, s2 string) (bool, error) { return true, nil },
+					LookupProjectTokenFunc: func(ctx context.Context, s string) (types.ProjectToken, error) {
+						return types.ProjectToken{ID: "secret-id-accessor"}, nil
+					},
+				},
+				ReadGitCredentialsFunc: func(ctx context.Context, r string) (types.GitCredentials, error) {
+					if r != ref {
+						return types.GitCredentials{}, fmt.Errorf("unexpected ref %s", r)
+					}
+					return types.GitCredentials{HTTPSUsername: "user", HTTPSPassword: "token"}, tt.readErr
+				},
 			}
 
-			defaultCP := func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
-				return &th.CredsProviderMock{}, nil
-			}
+			config, err := loadConfig(testConfigPath)
+			assert.Nil(t, err)
 
 			h := handler{
-				logger:                 log.NewNopLogger(),
-				newCredentialsProvider: defaultCP,
-				argoCtx:                context.Background(),
-				config:                 config,
-				gitClient:              &th.GitClientMock{},
-				env: env.Vars{
-					AdminSecret: testPassword,
+				logger:  log.NewNopLogger(),
+				argoCtx: context.Background(),
+				config:  config,
+				newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					return cp, nil
 				},
+				dbClient: &th.DBClientMock{
+					ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
+						return db.ProjectEntry{ProjectID: "project1", Repository: "repo", GitCredentials: ref}, nil
+					},
+					ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+						return db.TokenEntry{}, upper.ErrNoMoreRows
+					},
+					ReadTargetEntryFunc: func(ctx context.Context, project, target string) (db.TargetEntry, error) {
+						return db.TargetEntry{}, upper.ErrNoMoreRows
+					},
+				},
+				gitClient: &th.GitClientMock{
+					GetManifestFileFunc: func(ctx context.Context, repository git.Repository, commitHash, root, path string) ([]byte, error) {
+						if repository.URL != "repo" || repository.Credentials.HTTPSUsername != "user" {
+							return nil, fmt.Errorf("unexpected repository %s", repository.URL)
+						}
+						return loadFileBytes("TestCreateWorkflow/can_create_workflow_request.json")
+					},
+				},
+				argo: &th.WorkflowMock{
+					SubmitFunc: func(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error) {
+						return workflowResponse, nil
+					},
+				},
+				env: env.Vars{AdminSecret: testPassword},
 			}
 
-			if tt.dbMock != nil {
-				h.dbClient = tt.dbMock
-			}
-
-			if tt.cpMock != nil {
-				mockCP := func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
-					return tt.cpMock, nil
-				}
-
-				h.newCredentialsProvider = mockCP
-			}
-
-			if tt.gitMock != nil {
-				h.gitClient = tt.gitMock
-			}
-
-			if tt.wfMock != nil {
-				h.argo = tt.wfMock
-			}
-
-			resp := executeRequestWithHandler(h, tt.method, tt.url, serialize(tt.req), tt.authHeader)
-			if resp.StatusCode != tt.want {
-				t.Errorf("Unexpected status code %d", resp.StatusCode)
-			}
+			resp := executeRequestWithHandler(h, http.MethodPost, "/projects/projectalreadyexists/targets/TARGET_EXISTS/operations", serialize(loadJSON(t, "TestCreateWorkflowFromGit/good_request.json")), userAuthHeader)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode)
 
 			if tt.body != "" {
-				bodyBytes, err := io.ReadAll(resp.Body)
-				defer resp.Body.Close()
-				if err != nil {
-					t.Errorf("Error loading body")
-				}
-				if tt.body != string(bodyBytes) {
-					t.Errorf("Unexpected body '%s', expected '%s'", bodyBytes, tt.body)
-				}
-			}
-
-			if tt.respFile != "" {
-				wantBody, err := loadFileBytes(tt.respFile)
-				if err != nil {
-					t.Fatalf("unable to read response file '%s', err: '%s'", tt.respFile, err)
-				}
-
 				body, err := io.ReadAll(resp.Body)
 				assert.Nil(t, err)
-
-				defer resp.Body.Close()
-
-				bodyStr := string(body)
-				wantBodyStr := string(wantBody)
-
-				// don't use assert.JSONEq for empty strings
-				if bodyStr == "" && wantBodyStr == "" {
-					assert.Equal(t, wantBodyStr, bodyStr)
-				} else {
-					assert.JSONEq(t, wantBodyStr, bodyStr)
-				}
+				assert.JSONEq(t, tt.body, string(body))
 			}
 		})
 	}
 }
 
-func executeRequestWithHandler(h handler, method string, url string, body *bytes.Buffer, authHeader string) *http.Response {
-	router := setupRouter(h)
-	req, _ := http.NewRequest(method, url, body)
+func TestInManifestRoot(t *testing.T) {
+	tests := []struct {
+		root string
+		path string
+		want bool
+	}{
+		{root: "", path: "path/to/manifest.yaml", want: true},
+		{root: "/", path: "path/to/manifest.yaml", want: true},
+		{root: "cello/", path: "cello/manifest.yaml", want: true},
+		{root: "cello", path: "/cello/nested/manifest.yaml", want: true},
+		{root: "cello", path: "cello-other/manifest.yaml"},
+		{root: "cello", path: "cello/../manifest.yaml"},
+		{root: "cello", path: "cello"},
+		{root: "cello", path: "../cello/manifest.yaml"},
+	}
 
-	req.Header.Add("Authorization", authHeader)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-	return w.Result()
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, inManifestRoot(tt.root, tt.path), "root %q path %q", tt.root, tt.path)
+	}
 }
 
-// loadFileBytes returns the contents of a file in the 'testdata' directory.
-func loadFileBytes(filename string) ([]byte, error) {
-	file := filepath.Join("test/testdata", filename)
-	return os.ReadFile(file)
-}
+type argoCtxKey struct{}
 
-// loadJSON unmarshals a JSON file from the testdata directory into output.
-func loadJSON(t *testing.T, filename string) (output interface{}) {
-	data, err := loadFileBytes(filename)
-	if err != nil {
-		t.Fatalf("failed to read file %s: %v", filename, err)
-	}
+func TestArgoContext(t *testing.T) {
+	h := handler{argoCtx: context.WithValue(context.Background(), argoCtxKey{}, "argo")}
 
-	if err := json.Unmarshal(data, &output); err != nil {
-		t.Fatalf("failed to decode file %s: %v", filename, err)
-	}
-	return output
+	reqCtx, cancel := context.WithCancel(context.Background())
+	ctx := h.argoContext(reqCtx)
+
+	assert.Equal(t, "argo", ctx.Value(argoCtxKey{}))
+	assert.NoError(t, ctx.Err())
+
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
 }
@@ -0,0 +1,145 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// staticCredentialsDefault is the key of the credentials used for targets
+	// whose role has no credentials in the file.
+	staticCredentialsDefault = "*"
+	// stsRoleSessionNameMaxLength is the maximum length of an STS role session
+	// name.
+	stsRoleSessionNameMaxLength = 64
+)
+
+// CredentialsIssuer issues the credentials of targets.
+type CredentialsIssuer interface {
+	Issue(ctx context.Context, projectName string, target types.Target) (types.TargetCredentials, error)
+}
+
+// StaticCredentialsIssuer issues credentials read from a file, e.g. for
+// development and integration tests.
+type StaticCredentialsIssuer struct {
+	credentials map[string]types.TargetCredentials
+}
+
+// NewStaticCredentialsIssuer returns a StaticCredentialsIssuer with the
+// credentials of the YAML file at path. The file maps role ARNs to
+// credentials, credentials with the key "*" are used for any other role.
+func NewStaticCredentialsIssuer(path string) (StaticCredentialsIssuer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return StaticCredentialsIssuer{}, fmt.Errorf("unable to read static credentials file: %w", err)
+	}
+
+	credentials := map[string]types.TargetCredentials{}
+	if err := yaml.UnmarshalStrict(b, &credentials); err != nil {
+		return StaticCredentialsIssuer{}, fmt.Errorf("unable to parse static credentials file: %w", err)
+	}
+
+	return StaticCredentialsIssuer{credentials: credentials}, nil
+}
+
+// Issue returns the credentials of the target role.
+func (i StaticCredentialsIssuer) Issue(ctx context.Context, projectName string, target types.Target) (types.TargetCredentials, error) {
+	if c, ok := i.credentials[target.Properties.RoleArn]; ok {
+		return c, nil
+	}
+
+	if c, ok := i.credentials[staticCredentialsDefault]; ok {
+		return c, nil
+	}
+
+	return types.TargetCredentials{}, fmt.Errorf("no static credentials for role '%s'", target.Properties.RoleArn)
+}
+
+// STSCredentialsIssuer issues credentials by assuming the target role with
+// STS or an AWS-compatible stand-in like LocalStack. The issuer's own
+// credentials are read from the environment as with the AWS CLI.
+type STSCredentialsIssuer struct {
+	svc stsiface.STSAPI
+}
+
+// NewSTSCredentialsIssuer returns a new STSCredentialsIssuer. The default STS
+// endpoint of the region is used when endpoint is empty.
+func NewSTSCredentialsIssuer(endpoint, region string) (STSCredentialsIssuer, error) {
+	config := aws.NewConfig().WithRegion(region)
+	if endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return STSCredentialsIssuer{}, err
+	}
+
+	return STSCredentialsIssuer{svc: sts.New(sess)}, nil
+}
+
+// Issue assumes the target role with the properties of the target.
+func (i STSCredentialsIssuer) Issue(ctx context.Context, projectName string, target types.Target) (types.TargetCredentials, error) {
+	properties := target.Properties
+	if properties.CredentialType != "assumed_role" {
+		return types.TargetCredentials{}, fmt.Errorf("credential type '%s' is not supported", properties.CredentialType)
+	}
+
+	sessionName := fmt.Sprintf("cello-%s-%s", projectName, target.Name)
+	if len(sessionName) > stsRoleSessionNameMaxLength {
+		sessionName = sessionName[:stsRoleSessionNameMaxLength]
+	}
+
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(properties.RoleArn),
+		RoleSessionName: aws.String(sessionName),
+	}
+
+	if properties.DefaultSTSTTL > 0 {
+		input.DurationSeconds = aws.Int64(int64(properties.DefaultSTSTTL))
+	}
+
+	if properties.ExternalID != "" {
+		input.ExternalId = aws.String(properties.ExternalID)
+	}
+
+	if properties.PolicyDocument != "" {
+		input.Policy = aws.String(properties.PolicyDocument)
+	}
+
+	for _, arn := range properties.PolicyArns {
+		input.PolicyArns = append(input.PolicyArns, &sts.PolicyDescriptorType{Arn: aws.String(arn)})
+	}
+
+	keys := make([]string, 0, len(properties.SessionTags))
+	for k := range properties.SessionTags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		input.Tags = append(input.Tags, &sts.Tag{Key: aws.String(k), Value: aws.String(properties.SessionTags[k])})
+	}
+
+	out, err := i.svc.AssumeRoleWithContext(ctx, input)
+	if err != nil {
+		return types.TargetCredentials{}, fmt.Errorf("sts assume role error: %w", err)
+	}
+
+	return types.TargetCredentials{
+		AccessKeyID:     aws.StringValue(out.Credentials.AccessKeyId),
+		Expiration:      aws.TimeValue(out.Credentials.Expiration).Format(time.RFC3339),
+		SecretAccessKey: aws.StringValue(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(out.Credentials.SessionToken),
+	}, nil
+}
@@ -0,0 +1,133 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticCredentialsIssuer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	err := os.WriteFile(path, []byte(`arn:aws:iam::123456789012:role/target1:
+  access_key_id: AKIA1
+  secret_access_key: secret1
+"*":
+  access_key_id: AKIA2
+  secret_access_key: secret2
+  session_token: token2
+`), 0600)
+	assert.NoError(t, err)
+
+	issuer, err := NewStaticCredentialsIssuer(path)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		roleArn string
+		want    types.TargetCredentials
+	}{
+		{
+			name:    "role credentials",
+			roleArn: "arn:aws:iam::123456789012:role/target1",
+			want:    types.TargetCredentials{AccessKeyID: "AKIA1", SecretAccessKey: "secret1"},
+		},
+		{
+			name:    "default credentials",
+			roleArn: "arn:aws:iam::123456789012:role/target2",
+			want:    types.TargetCredentials{AccessKeyID: "AKIA2", SecretAccessKey: "secret2", SessionToken: "token2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := issuer.Issue(context.Background(), "project1", types.Target{Name: "target", Properties: types.TargetProperties{RoleArn: tt.roleArn}})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = StaticCredentialsIssuer{}.Issue(context.Background(), "project1", types.Target{Properties: types.TargetProperties{RoleArn: "arn:aws:iam::123456789012:role/target1"}})
+	assert.EqualError(t, err, "no static credentials for role 'arn:aws:iam::123456789012:role/target1'")
+
+	_, err = NewStaticCredentialsIssuer(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+type mockSTS struct {
+	stsiface.STSAPI
+	input *sts.AssumeRoleInput
+	err   error
+}
+
+func (m *mockSTS) AssumeRoleWithContext(ctx aws.Context, input *sts.AssumeRoleInput, opts ...request.Option) (*sts.AssumeRoleOutput, error) {
+	m.input = input
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("AKIA"),
+			Expiration:      aws.Time(time.Date(2022, 7, 1, 1, 0, 0, 0, time.UTC)),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+		},
+	}, nil
+}
+
+func TestSTSCredentialsIssuer(t *testing.T) {
+	target := types.Target{
+		Name: "target1",
+		Properties: types.TargetProperties{
+			CredentialType: "assumed_role",
+			DefaultSTSTTL:  900,
+			ExternalID:     "external-id",
+			PolicyArns:     []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			PolicyDocument: "{}",
+			RoleArn:        "arn:aws:iam::123456789012:role/target1",
+			SessionTags:    map[string]string{"team": "platform", "cost": "1234"},
+		},
+	}
+
+	svc := &mockSTS{}
+	got, err := STSCredentialsIssuer{svc: svc}.Issue(context.Background(), "project1", target)
+	assert.NoError(t, err)
+	assert.Equal(t, types.TargetCredentials{
+		AccessKeyID:     "AKIA",
+		Expiration:      "2022-07-01T01:00:00Z",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+	}, got)
+
+	assert.Equal(t, &sts.AssumeRoleInput{
+		DurationSeconds: aws.Int64(900),
+		ExternalId:      aws.String("external-id"),
+		Policy:          aws.String("{}"),
+		PolicyArns:      []*sts.PolicyDescriptorType{{Arn: aws.String("arn:aws:iam::aws:policy/ReadOnlyAccess")}},
+		RoleArn:         aws.String("arn:aws:iam::123456789012:role/target1"),
+		RoleSessionName: aws.String("cello-project1-target1"),
+		Tags: []*sts.Tag{
+			{Key: aws.String("cost"), Value: aws.String("1234")},
+			{Key: aws.String("team"), Value: aws.String("platform")},
+		},
+	}, svc.input)
+
+	target.Properties.CredentialType = "iam_user"
+	_, err = STSCredentialsIssuer{svc: svc}.Issue(context.Background(), "project1", target)
+	assert.EqualError(t, err, "credential type 'iam_user' is not supported")
+
+	target.Properties.CredentialType = "assumed_role"
+	_, err = STSCredentialsIssuer{svc: &mockSTS{err: errors.New("error")}}.Issue(context.Background(), "project1", target)
+	assert.EqualError(t, err, "sts assume role error: error")
+}
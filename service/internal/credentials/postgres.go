@@ -0,0 +1,739 @@
+package credentials
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/env"
+
+	"github.com/google/uuid"
+	"github.com/upper/db/v4"
+)
+
+// Postgres
+const (
+	postgresProjectsTable = "credentials_projects"
+	postgresSessionsTable = "credentials_sessions"
+	postgresTargetsTable  = "credentials_targets"
+	postgresTokensTable   = "credentials_tokens"
+
+	// The token and session settings match the ones of Vault AppRoles.
+	postgresSecretTTL   = 8776 * time.Hour // 1 year
+	postgresSessionKey  = "session"
+	postgresSessionTTL  = 10 * time.Minute
+	postgresSessionUses = 3
+)
+
+// ErrNoCredentialsIssuer conveys that the provider has no issuer of target
+// credentials configured.
+var ErrNoCredentialsIssuer = errors.New("no credentials issuer configured")
+
+// TargetCredentialsProvider is implemented by providers which issue target
+// credentials to workflows themselves, instead of workflows using
+// GetToken with the service of the provider.
+type TargetCredentialsProvider interface {
+	GetTargetCredentials(context.Context, string, string) (types.TargetCredentials, error)
+}
+
+// PostgresProject is a project stored in a PostgresStore.
+type PostgresProject struct {
+	Name   string `db:"project"`
+	RoleID string `db:"role_id"`
+}
+
+// PostgresToken is a project token stored in a PostgresStore. Only the hash of
+// its secret is stored.
+type PostgresToken struct {
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	Project    string    `db:"project"`
+	SecretHash string    `db:"secret_hash"`
+	TokenID    string    `db:"token_id"`
+}
+
+// PostgresSession is a short lived token a workflow uses to get the
+// credentials of its target.
+type PostgresSession struct {
+	ExpiresAt  time.Time `db:"expires_at"`
+	Project    string    `db:"project"`
+	SecretHash string    `db:"secret_hash"`
+	Target     string    `db:"target"`
+	TokenID    string    `db:"token_id"`
+	UsesLeft   int       `db:"uses_left"`
+}
+
+// PostgresStore stores the projects, targets and tokens of a
+// PostgresProvider. Reads return ErrNotFound, ErrTargetNotFound or
+// ErrProjectTokenNotFound when the item doesn't exist.
+type PostgresStore interface {
+	CreateProject(ctx context.Context, p PostgresProject) error
+	ReadProject(ctx context.Context, name string) (PostgresProject, error)
+	ReadProjectByRoleID(ctx context.Context, roleID string) (PostgresProject, error)
+	DeleteProject(ctx context.Context, name string) error
+	ListProjects(ctx context.Context) ([]string, error)
+	UpsertTarget(ctx context.Context, project string, target types.Target) error
+	ReadTarget(ctx context.Context, project, target string) (types.Target, error)
+	DeleteTarget(ctx context.Context, project, target string) error
+	ListTargets(ctx context.Context, project string) ([]string, error)
+	CreateToken(ctx context.Context, t PostgresToken) error
+	ReadToken(ctx context.Context, project, tokenID string) (PostgresToken, error)
+	ReadTokenBySecretHash(ctx context.Context, project, secretHash string) (PostgresToken, error)
+	DeleteToken(ctx context.Context, project, tokenID string) error
+	ListTokens(ctx context.Context, project string) ([]PostgresToken, error)
+	CreateSession(ctx context.Context, s PostgresSession) error
+	UseSession(ctx context.Context, secretHash string, now time.Time) (PostgresSession, error)
+}
+
+// PostgresProvider stores projects, targets and tokens in Postgres instead
+// of Vault. Workflows get the credentials of their target from the service,
+// which are issued by its CredentialsIssuer.
+type PostgresProvider struct {
+	issuer   CredentialsIssuer
+	now      func() time.Time
+	roleID   string
+	secretID string
+	store    PostgresStore
+}
+
+// NewPostgresProviderFn returns a ProviderFn of PostgresProviders using the
+// store and issuer. The issuer can be nil when target credentials are not
+// needed.
+func NewPostgresProviderFn(store PostgresStore, issuer CredentialsIssuer) ProviderFn {
+	return func(a Authorization, env env.Vars, h http.Header, vaultConfigFn VaultConfigFn, vaultSvcFn VaultSvcFn) (Provider, error) {
+		return &PostgresProvider{
+			issuer:   issuer,
+			now:      time.Now,
+			roleID:   a.Key,
+			secretID: a.Secret,
+			store:    store,
+		}, nil
+	}
+}
+
+func (p PostgresProvider) isAdmin() bool {
+	return p.roleID == authorizationKeyAdmin
+}
+
+func (p PostgresProvider) CreateProject(ctx context.Context, name string) (types.Token, error) {
+	if !p.isAdmin() {
+		return types.Token{}, errors.New("admin credentials must be used to create project")
+	}
+
+	if err := p.store.CreateProject(ctx, PostgresProject{Name: name, RoleID: uuid.NewString()}); err != nil {
+		return types.Token{}, fmt.Errorf("postgres create project error: %w", err)
+	}
+
+	return p.CreateToken(ctx, name, 0)
+}
+
+// CreateToken creates a token for the project. Tokens expire after a year
+// when ttl is 0.
+func (p PostgresProvider) CreateToken(ctx context.Context, name string, ttl time.Duration) (types.Token, error) {
+	token := types.Token{}
+
+	if !p.isAdmin() {
+		return token, errors.New("admin credentials must be used to create token")
+	}
+
+	project, err := p.store.ReadProject(ctx, name)
+	if err != nil {
+		return token, fmt.Errorf("postgres read project error: %w", err)
+	}
+
+	if ttl == 0 {
+		ttl = postgresSecretTTL
+	}
+
+	secret, err := newPostgresSecret()
+	if err != nil {
+		return token, err
+	}
+
+	now := p.now()
+	pt := PostgresToken{
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+		Project:    name,
+		SecretHash: hashPostgresSecret(secret),
+		TokenID:    uuid.NewString(),
+	}
+	if err := p.store.CreateToken(ctx, pt); err != nil {
+		return token, fmt.Errorf("postgres create token error: %w", err)
+	}
+
+	token.CreatedAt = pt.CreatedAt.Format(time.RFC3339Nano)
+	token.ExpiresAt = pt.ExpiresAt.Format(time.RFC3339Nano)
+	token.ProjectID = name
+	token.ProjectToken.ID = pt.TokenID
+	token.RoleID = project.RoleID
+	token.Secret = secret
+
+	return token, nil
+}
+
+// CreateTarget creates a target for the project.
+func (p PostgresProvider) CreateTarget(ctx context.Context, projectName string, target types.Target) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to create target")
+	}
+
+	return p.store.UpsertTarget(ctx, projectName, target)
+}
+
+// UpdateTarget updates a target of the project.
+func (p PostgresProvider) UpdateTarget(ctx context.Context, projectName string, target types.Target) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to update target")
+	}
+
+	return p.store.UpsertTarget(ctx, projectName, target)
+}
+
+// DeleteProject deletes the project with its targets and tokens.
+func (p PostgresProvider) DeleteProject(ctx context.Context, name string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete project")
+	}
+
+	if err := p.store.DeleteProject(ctx, name); err != nil {
+		return fmt.Errorf("postgres delete project error: %w", err)
+	}
+	return nil
+}
+
+func (p PostgresProvider) DeleteTarget(ctx context.Context, projectName, targetName string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete target")
+	}
+
+	return p.store.DeleteTarget(ctx, projectName, targetName)
+}
+
+func (p PostgresProvider) GetProject(ctx context.Context, projectName string) (responses.GetProject, error) {
+	if _, err := p.store.ReadProject(ctx, projectName); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return responses.GetProject{}, ErrNotFound
+		}
+		return responses.GetProject{}, fmt.Errorf("postgres get project error: %w", err)
+	}
+
+	return responses.GetProject{Name: projectName}, nil
+}
+
+func (p PostgresProvider) GetTarget(ctx context.Context, projectName, targetName string) (types.Target, error) {
+	if !p.isAdmin() {
+		return types.Target{}, errors.New("admin credentials must be used to get target information")
+	}
+
+	return p.store.ReadTarget(ctx, projectName, targetName)
+}
+
+// GetToken returns a token the workflow of the target uses to get the
+// credentials of the target with GetTargetCredentials.
+func (p PostgresProvider) GetToken(ctx context.Context, projectName, targetName string) (string, error) {
+	if p.isAdmin() {
+		return "", errors.New("admin credentials cannot be used to get tokens")
+	}
+
+	project, err := p.store.ReadProjectByRoleID(ctx, p.roleID)
+	if err != nil {
+		return "", fmt.Errorf("postgres read project error: %w", err)
+	}
+
+	if project.Name != projectName {
+		return "", ErrProjectTokenNotFound
+	}
+
+	token, err := p.readToken(ctx, project.Name)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := newPostgresSecret()
+	if err != nil {
+		return "", err
+	}
+
+	session := PostgresSession{
+		ExpiresAt:  p.now().Add(postgresSessionTTL),
+		Project:    project.Name,
+		SecretHash: hashPostgresSecret(secret),
+		Target:     targetName,
+		TokenID:    token.TokenID,
+		UsesLeft:   postgresSessionUses,
+	}
+	if err := p.store.CreateSession(ctx, session); err != nil {
+		return "", fmt.Errorf("postgres create session error: %w", err)
+	}
+
+	return fmt.Sprintf("%s:%s:%s", ProviderPostgres, postgresSessionKey, secret), nil
+}
+
+// GetTargetCredentials returns the credentials of the target. The
+// authorization must be a token returned by GetToken for the target.
+func (p PostgresProvider) GetTargetCredentials(ctx context.Context, projectName, targetName string) (types.TargetCredentials, error) {
+	if p.roleID != postgresSessionKey {
+		return types.TargetCredentials{}, ErrProjectTokenNotFound
+	}
+
+	session, err := p.store.UseSession(ctx, hashPostgresSecret(p.secretID), p.now())
+	if err != nil {
+		return types.TargetCredentials{}, err
+	}
+
+	if session.Project != projectName || session.Target != targetName {
+		return types.TargetCredentials{}, ErrProjectTokenNotFound
+	}
+
+	target, err := p.store.ReadTarget(ctx, projectName, targetName)
+	if err != nil {
+		return types.TargetCredentials{}, err
+	}
+
+	if p.issuer == nil {
+		return types.TargetCredentials{}, ErrNoCredentialsIssuer
+	}
+
+	return p.issuer.Issue(ctx, projectName, target)
+}
+
+func (p PostgresProvider) DeleteProjectToken(ctx context.Context, projectName, tokenID string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete tokens")
+	}
+
+	return p.store.DeleteToken(ctx, projectName, tokenID)
+}
+
+func (p PostgresProvider) GetProjectToken(ctx context.Context, projectName, tokenID string) (types.ProjectToken, error) {
+	if !p.isAdmin() {
+		return types.ProjectToken{}, errors.New("admin credentials must be used to delete tokens")
+	}
+
+	token, err := p.store.ReadToken(ctx, projectName, tokenID)
+	if err != nil {
+		return types.ProjectToken{}, err
+	}
+
+	return types.ProjectToken{
+		CreatedAt: token.CreatedAt.Format(time.RFC3339Nano),
+		ID:        token.TokenID,
+	}, nil
+}
+
+// ListProjects lists the names of all projects.
+func (p PostgresProvider) ListProjects(ctx context.Context) ([]string, error) {
+	if !p.isAdmin() {
+		return nil, errors.New("admin credentials must be used to list projects")
+	}
+
+	return p.store.ListProjects(ctx)
+}
+
+// ListProjectTokens lists the tokens of a project which have not expired.
+func (p PostgresProvider) ListProjectTokens(ctx context.Context, projectName string) ([]types.ProjectToken, error) {
+	if !p.isAdmin() {
+		return nil, errors.New("admin credentials must be used to list tokens")
+	}
+
+	tokens, err := p.store.ListTokens(ctx, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("postgres list tokens error: %w", err)
+	}
+
+	now := p.now()
+	list := make([]types.ProjectToken, 0)
+	for _, t := range tokens {
+		if t.ExpiresAt.After(now) {
+			list = append(list, types.ProjectToken{ID: t.TokenID})
+		}
+	}
+
+	return list, nil
+}
+
+func (p PostgresProvider) ListTargets(ctx context.Context, project string) ([]string, error) {
+	if !p.isAdmin() {
+		return nil, errors.New("admin credentials must be used to list targets")
+	}
+
+	return p.store.ListTargets(ctx, project)
+}
+
+// LookupProjectToken returns the project token of the authorization. It
+// returns ErrProjectTokenNotFound if the authorization is not a token of the
+// project.
+func (p PostgresProvider) LookupProjectToken(ctx context.Context, projectName string) (types.ProjectToken, error) {
+	if p.isAdmin() {
+		return types.ProjectToken{}, errors.New("admin credentials cannot be used to lookup project tokens")
+	}
+
+	project, err := p.store.ReadProject(ctx, projectName)
+	if err != nil {
+		return types.ProjectToken{}, fmt.Errorf("postgres read project error: %w", err)
+	}
+
+	if project.RoleID != p.roleID {
+		return types.ProjectToken{}, ErrProjectTokenNotFound
+	}
+
+	token, err := p.readToken(ctx, projectName)
+	if err != nil {
+		return types.ProjectToken{}, err
+	}
+
+	return types.ProjectToken{ID: token.TokenID}, nil
+}
+
+func (p PostgresProvider) ProjectExists(ctx context.Context, name string) (bool, error) {
+	_, err := p.GetProject(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p PostgresProvider) TargetExists(ctx context.Context, projectName, targetName string) (bool, error) {
+	_, err := p.store.ReadTarget(ctx, projectName, targetName)
+	if errors.Is(err, ErrTargetNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// readToken returns the token of the project with the secret of the
+// authorization. Expired tokens are not returned.
+func (p PostgresProvider) readToken(ctx context.Context, projectName string) (PostgresToken, error) {
+	token, err := p.store.ReadTokenBySecretHash(ctx, projectName, hashPostgresSecret(p.secretID))
+	if err != nil {
+		return PostgresToken{}, err
+	}
+
+	if !token.ExpiresAt.After(p.now()) {
+		return PostgresToken{}, ErrProjectTokenNotFound
+	}
+
+	return token, nil
+}
+
+func newPostgresSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashPostgresSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// SQLPostgresStore is a PostgresStore using the credentials tables of a
+// Postgres database.
+type SQLPostgresStore struct {
+	session func() (db.Session, error)
+}
+
+// NewSQLPostgresStore returns a new SQLPostgresStore using the sessions
+// returned by session, which are shared and never closed by the store. It's
+// usually the pooled session of the database client.
+func NewSQLPostgresStore(session func() (db.Session, error)) SQLPostgresStore {
+	return SQLPostgresStore{session: session}
+}
+
+type postgresTargetEntry struct {
+	Name       string                   `db:"target"`
+	Project    string                   `db:"project"`
+	Properties postgresTargetProperties `db:"properties"`
+	Type       string                   `db:"type"`
+}
+
+// postgresTargetProperties stores types.TargetProperties as JSON.
+type postgresTargetProperties types.TargetProperties
+
+// Value implements driver.Valuer.
+func (p postgresTargetProperties) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (p *postgresTargetProperties) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan target properties from %T", src)
+	}
+
+	return json.Unmarshal(b, p)
+}
+
+func (s SQLPostgresStore) CreateProject(ctx context.Context, p PostgresProject) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	_, err = sess.WithContext(ctx).Collection(postgresProjectsTable).Insert(p)
+	return err
+}
+
+func (s SQLPostgresStore) ReadProject(ctx context.Context, name string) (PostgresProject, error) {
+	return s.readProject(ctx, db.Cond{"project": name})
+}
+
+func (s SQLPostgresStore) ReadProjectByRoleID(ctx context.Context, roleID string) (PostgresProject, error) {
+	return s.readProject(ctx, db.Cond{"role_id": roleID})
+}
+
+func (s SQLPostgresStore) readProject(ctx context.Context, cond db.Cond) (PostgresProject, error) {
+	res := PostgresProject{}
+
+	sess, err := s.session()
+	if err != nil {
+		return res, err
+	}
+
+	err = sess.WithContext(ctx).Collection(postgresProjectsTable).Find(cond).One(&res)
+	if errors.Is(err, db.ErrNoMoreRows) {
+		return res, ErrNotFound
+	}
+	return res, err
+}
+
+// DeleteProject deletes the project. Its targets, tokens and sessions are
+// deleted by the database.
+func (s SQLPostgresStore) DeleteProject(ctx context.Context, name string) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Collection(postgresProjectsTable).Find("project", name).Delete()
+}
+
+func (s SQLPostgresStore) ListProjects(ctx context.Context) ([]string, error) {
+	sess, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []PostgresProject{}
+	if err := sess.WithContext(ctx).Collection(postgresProjectsTable).Find().OrderBy("project").All(&res); err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(res))
+	for _, p := range res {
+		list = append(list, p.Name)
+	}
+	return list, nil
+}
+
+// UpsertTarget stores the target. The region is not stored as it's stored
+// with the target entry of the service, as for Vault.
+func (s SQLPostgresStore) UpsertTarget(ctx context.Context, project string, target types.Target) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	entry := postgresTargetEntry{
+		Name:       target.Name,
+		Project:    project,
+		Properties: postgresTargetProperties(target.Properties),
+		Type:       target.Type,
+	}
+	entry.Properties.Region = ""
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(postgresTargetsTable).Find(db.Cond{"project": project, "target": target.Name}).Delete(); err != nil {
+			return err
+		}
+
+		_, err := sess.Collection(postgresTargetsTable).Insert(entry)
+		return err
+	})
+}
+
+func (s SQLPostgresStore) ReadTarget(ctx context.Context, project, target string) (types.Target, error) {
+	sess, err := s.session()
+	if err != nil {
+		return types.Target{}, err
+	}
+
+	entry := postgresTargetEntry{}
+	err = sess.WithContext(ctx).Collection(postgresTargetsTable).Find(db.Cond{"project": project, "target": target}).One(&entry)
+	if errors.Is(err, db.ErrNoMoreRows) {
+		return types.Target{}, ErrTargetNotFound
+	}
+	if err != nil {
+		return types.Target{}, err
+	}
+
+	return types.Target{
+		Name:       entry.Name,
+		Properties: types.TargetProperties(entry.Properties),
+		Type:       entry.Type,
+	}, nil
+}
+
+func (s SQLPostgresStore) DeleteTarget(ctx context.Context, project, target string) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Collection(postgresTargetsTable).Find(db.Cond{"project": project, "target": target}).Delete()
+}
+
+func (s SQLPostgresStore) ListTargets(ctx context.Context, project string) ([]string, error) {
+	sess, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []postgresTargetEntry{}
+	if err := sess.WithContext(ctx).Collection(postgresTargetsTable).Find("project", project).OrderBy("target").All(&res); err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(res))
+	for _, t := range res {
+		list = append(list, t.Name)
+	}
+	return list, nil
+}
+
+func (s SQLPostgresStore) CreateToken(ctx context.Context, t PostgresToken) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	_, err = sess.WithContext(ctx).Collection(postgresTokensTable).Insert(t)
+	return err
+}
+
+func (s SQLPostgresStore) ReadToken(ctx context.Context, project, tokenID string) (PostgresToken, error) {
+	return s.readToken(ctx, db.Cond{"project": project, "token_id": tokenID})
+}
+
+func (s SQLPostgresStore) ReadTokenBySecretHash(ctx context.Context, project, secretHash string) (PostgresToken, error) {
+	return s.readToken(ctx, db.Cond{"project": project, "secret_hash": secretHash})
+}
+
+func (s SQLPostgresStore) readToken(ctx context.Context, cond db.Cond) (PostgresToken, error) {
+	res := PostgresToken{}
+
+	sess, err := s.session()
+	if err != nil {
+		return res, err
+	}
+
+	err = sess.WithContext(ctx).Collection(postgresTokensTable).Find(cond).One(&res)
+	if errors.Is(err, db.ErrNoMoreRows) {
+		return res, ErrProjectTokenNotFound
+	}
+	return res, err
+}
+
+func (s SQLPostgresStore) DeleteToken(ctx context.Context, project, tokenID string) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Collection(postgresTokensTable).Find(db.Cond{"project": project, "token_id": tokenID}).Delete()
+}
+
+func (s SQLPostgresStore) ListTokens(ctx context.Context, project string) ([]PostgresToken, error) {
+	res := []PostgresToken{}
+
+	sess, err := s.session()
+	if err != nil {
+		return res, err
+	}
+
+	err = sess.WithContext(ctx).Collection(postgresTokensTable).Find("project", project).OrderBy("-created_at").All(&res)
+	return res, err
+}
+
+// CreateSession stores the session.
+func (s SQLPostgresStore) CreateSession(ctx context.Context, session PostgresSession) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	// Expired sessions are deleted when a new one is created.
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(postgresSessionsTable).Find(db.Cond{"expires_at <": db.Raw("NOW()")}).Delete(); err != nil {
+			return err
+		}
+
+		_, err := sess.Collection(postgresSessionsTable).Insert(session)
+		return err
+	})
+}
+
+// UseSession decrements the uses left of the session. It returns
+// ErrProjectTokenNotFound when the session doesn't exist, has expired or has
+// no uses left.
+func (s SQLPostgresStore) UseSession(ctx context.Context, secretHash string, now time.Time) (PostgresSession, error) {
+	res := PostgresSession{}
+
+	sess, err := s.session()
+	if err != nil {
+		return res, err
+	}
+
+	// Decrementing in the update makes concurrent uses of a session safe.
+	query := fmt.Sprintf(`UPDATE %s SET uses_left = uses_left - 1
+WHERE secret_hash = ? AND expires_at > ? AND uses_left > 0
+RETURNING expires_at, project, secret_hash, target, token_id, uses_left`, postgresSessionsTable)
+
+	row, err := sess.WithContext(ctx).SQL().QueryRow(query, secretHash, now)
+	if err != nil {
+		return res, err
+	}
+
+	err = row.Scan(&res.ExpiresAt, &res.Project, &res.SecretHash, &res.Target, &res.TokenID, &res.UsesLeft)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return res, ErrProjectTokenNotFound
+		}
+		return res, err
+	}
+
+	return res, nil
+}
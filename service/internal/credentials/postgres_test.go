@@ -0,0 +1,393 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/env"
+
+	"github.com/stretchr/testify/assert"
+	upper "github.com/upper/db/v4"
+)
+
+// memoryPostgresStore is a PostgresStore keeping everything in memory.
+type memoryPostgresStore struct {
+	projects map[string]PostgresProject
+	sessions map[string]PostgresSession
+	targets  map[string]map[string]types.Target
+	tokens   map[string]PostgresToken
+}
+
+func newMemoryPostgresStore() *memoryPostgresStore {
+	return &memoryPostgresStore{
+		projects: map[string]PostgresProject{},
+		sessions: map[string]PostgresSession{},
+		targets:  map[string]map[string]types.Target{},
+		tokens:   map[string]PostgresToken{},
+	}
+}
+
+func (m *memoryPostgresStore) CreateProject(ctx context.Context, p PostgresProject) error {
+	m.projects[p.Name] = p
+	m.targets[p.Name] = map[string]types.Target{}
+	return nil
+}
+
+func (m *memoryPostgresStore) ReadProject(ctx context.Context, name string) (PostgresProject, error) {
+	p, ok := m.projects[name]
+	if !ok {
+		return PostgresProject{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (m *memoryPostgresStore) ReadProjectByRoleID(ctx context.Context, roleID string) (PostgresProject, error) {
+	for _, p := range m.projects {
+		if p.RoleID == roleID {
+			return p, nil
+		}
+	}
+	return PostgresProject{}, ErrNotFound
+}
+
+func (m *memoryPostgresStore) DeleteProject(ctx context.Context, name string) error {
+	delete(m.projects, name)
+	delete(m.targets, name)
+	for id, t := range m.tokens {
+		if t.Project == name {
+			delete(m.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (m *memoryPostgresStore) ListProjects(ctx context.Context) ([]string, error) {
+	list := []string{}
+	for name := range m.projects {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (m *memoryPostgresStore) UpsertTarget(ctx context.Context, project string, target types.Target) error {
+	if _, ok := m.projects[project]; !ok {
+		return errors.New("foreign key violation")
+	}
+	target.Properties.Region = ""
+	m.targets[project][target.Name] = target
+	return nil
+}
+
+func (m *memoryPostgresStore) ReadTarget(ctx context.Context, project, target string) (types.Target, error) {
+	t, ok := m.targets[project][target]
+	if !ok {
+		return types.Target{}, ErrTargetNotFound
+	}
+	return t, nil
+}
+
+func (m *memoryPostgresStore) DeleteTarget(ctx context.Context, project, target string) error {
+	delete(m.targets[project], target)
+	return nil
+}
+
+func (m *memoryPostgresStore) ListTargets(ctx context.Context, project string) ([]string, error) {
+	list := []string{}
+	for name := range m.targets[project] {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (m *memoryPostgresStore) CreateToken(ctx context.Context, t PostgresToken) error {
+	m.tokens[t.TokenID] = t
+	return nil
+}
+
+func (m *memoryPostgresStore) ReadToken(ctx context.Context, project, tokenID string) (PostgresToken, error) {
+	t, ok := m.tokens[tokenID]
+	if !ok || t.Project != project {
+		return PostgresToken{}, ErrProjectTokenNotFound
+	}
+	return t, nil
+}
+
+func (m *memoryPostgresStore) ReadTokenBySecretHash(ctx context.Context, project, secretHash string) (PostgresToken, error) {
+	for _, t := range m.tokens {
+		if t.Project == project && t.SecretHash == secretHash {
+			return t, nil
+		}
+	}
+	return PostgresToken{}, ErrProjectTokenNotFound
+}
+
+func (m *memoryPostgresStore) DeleteToken(ctx context.Context, project, tokenID string) error {
+	delete(m.tokens, tokenID)
+	return nil
+}
+
+func (m *memoryPostgresStore) ListTokens(ctx context.Context, project string) ([]PostgresToken, error) {
+	list := []PostgresToken{}
+	for _, t := range m.tokens {
+		if t.Project == project {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].TokenID < list[j].TokenID })
+	return list, nil
+}
+
+func (m *memoryPostgresStore) CreateSession(ctx context.Context, s PostgresSession) error {
+	m.sessions[s.SecretHash] = s
+	return nil
+}
+
+func (m *memoryPostgresStore) UseSession(ctx context.Context, secretHash string, now time.Time) (PostgresSession, error) {
+	s, ok := m.sessions[secretHash]
+	if !ok || !s.ExpiresAt.After(now) || s.UsesLeft < 1 {
+		return PostgresSession{}, ErrProjectTokenNotFound
+	}
+	s.UsesLeft--
+	m.sessions[secretHash] = s
+	return s, nil
+}
+
+type mockCredentialsIssuer struct {
+	creds types.TargetCredentials
+}
+
+func (m mockCredentialsIssuer) Issue(ctx context.Context, projectName string, target types.Target) (types.TargetCredentials, error) {
+	return m.creds, nil
+}
+
+// newTestPostgresProvider returns a provider for the authorization using the
+// store at now.
+func newTestPostgresProvider(t *testing.T, store PostgresStore, issuer CredentialsIssuer, key, secret string, now time.Time) *PostgresProvider {
+	fn := NewPostgresProviderFn(store, issuer)
+	cp, err := fn(Authorization{Provider: ProviderPostgres, Key: key, Secret: secret}, env.Vars{}, nil, nil, nil)
+	assert.NoError(t, err)
+
+	p := cp.(*PostgresProvider)
+	p.now = func() time.Time { return now }
+	return p
+}
+
+func TestPostgresProvider(t *testing.T) {
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	creds := types.TargetCredentials{AccessKeyID: "AKIA", SecretAccessKey: "secret", SessionToken: "session"}
+	target := types.Target{
+		Name: "target1",
+		Type: "aws_account",
+		Properties: types.TargetProperties{
+			CredentialType: "assumed_role",
+			PolicyArns:     []string{},
+			Region:         "us-west-2",
+			RoleArn:        "arn:aws:iam::123456789012:role/target1",
+		},
+	}
+
+	store := newMemoryPostgresStore()
+	admin := newTestPostgresProvider(t, store, mockCredentialsIssuer{creds: creds}, authorizationKeyAdmin, "adminSecret", now)
+
+	// Admin creates the project and a target.
+	token, err := admin.CreateProject(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.Equal(t, "project1", token.ProjectID)
+	assert.Equal(t, now.Format(time.RFC3339Nano), token.CreatedAt)
+	assert.Equal(t, now.Add(postgresSecretTTL).Format(time.RFC3339Nano), token.ExpiresAt)
+	assert.NotEmpty(t, token.RoleID)
+	assert.NotEmpty(t, token.Secret)
+	assert.NotContains(t, store.tokens[token.ProjectToken.ID].SecretHash, token.Secret)
+
+	assert.NoError(t, admin.CreateTarget(context.Background(), "project1", target))
+
+	exists, err := admin.ProjectExists(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = admin.TargetExists(context.Background(), "project1", "target1")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	got, err := admin.GetTarget(context.Background(), "project1", "target1")
+	assert.NoError(t, err)
+	assert.Equal(t, "", got.Properties.Region)
+	assert.Equal(t, target.Properties.RoleArn, got.Properties.RoleArn)
+
+	projects, err := admin.ListProjects(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"project1"}, projects)
+
+	tokens, err := admin.ListProjectTokens(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ProjectToken{{ID: token.ProjectToken.ID}}, tokens)
+
+	// The project token is looked up and exchanged for target credentials.
+	user := newTestPostgresProvider(t, store, mockCredentialsIssuer{creds: creds}, token.RoleID, token.Secret, now)
+
+	pt, err := user.LookupProjectToken(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.Equal(t, token.ProjectToken.ID, pt.ID)
+
+	sessionToken, err := user.GetToken(context.Background(), "project1", "target1")
+	assert.NoError(t, err)
+
+	a, err := NewAuthorization(sessionToken)
+	assert.NoError(t, err)
+	assert.Equal(t, ProviderPostgres, a.Provider)
+
+	session := newTestPostgresProvider(t, store, mockCredentialsIssuer{creds: creds}, a.Key, a.Secret, now)
+
+	_, err = session.GetTargetCredentials(context.Background(), "project2", "target1")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+
+	gotCreds, err := session.GetTargetCredentials(context.Background(), "project1", "target1")
+	assert.NoError(t, err)
+	assert.Equal(t, creds, gotCreds)
+
+	_, err = session.GetTargetCredentials(context.Background(), "project1", "target2")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+
+	// The session has no uses left.
+	_, err = session.GetTargetCredentials(context.Background(), "project1", "target1")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+
+	// Project tokens can't get target credentials directly.
+	_, err = user.GetTargetCredentials(context.Background(), "project1", "target1")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+
+	// Admins can't use project token operations and users can't use admin
+	// operations.
+	_, err = admin.GetToken(context.Background(), "project1", "target1")
+	assert.EqualError(t, err, "admin credentials cannot be used to get tokens")
+
+	_, err = user.ListProjects(context.Background())
+	assert.EqualError(t, err, "admin credentials must be used to list projects")
+
+	// Deleting the project deletes its targets and tokens.
+	assert.NoError(t, admin.DeleteProject(context.Background(), "project1"))
+
+	exists, err = admin.ProjectExists(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = user.GetToken(context.Background(), "project1", "target1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPostgresProviderExpiredToken(t *testing.T) {
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	store := newMemoryPostgresStore()
+	admin := newTestPostgresProvider(t, store, nil, authorizationKeyAdmin, "adminSecret", now)
+
+	token, err := admin.CreateProject(context.Background(), "project1")
+	assert.NoError(t, err)
+
+	expired, err := admin.CreateToken(context.Background(), "project1", time.Hour)
+	assert.NoError(t, err)
+
+	later := now.Add(2 * time.Hour)
+	admin.now = func() time.Time { return later }
+
+	tokens, err := admin.ListProjectTokens(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ProjectToken{{ID: token.ProjectToken.ID}}, tokens)
+
+	user := newTestPostgresProvider(t, store, nil, expired.RoleID, expired.Secret, later)
+
+	_, err = user.LookupProjectToken(context.Background(), "project1")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+
+	_, err = user.GetToken(context.Background(), "project1", "target1")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+}
+
+func TestPostgresProviderSessionTarget(t *testing.T) {
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	creds := types.TargetCredentials{AccessKeyID: "key"}
+
+	store := newMemoryPostgresStore()
+	admin := newTestPostgresProvider(t, store, nil, authorizationKeyAdmin, "adminSecret", now)
+
+	token, err := admin.CreateProject(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.NoError(t, admin.CreateTarget(context.Background(), "project1", types.Target{Name: "targetA"}))
+	assert.NoError(t, admin.CreateTarget(context.Background(), "project1", types.Target{Name: "targetB"}))
+
+	user := newTestPostgresProvider(t, store, nil, token.RoleID, token.Secret, now)
+
+	// Sessions are only issued for the project of the token.
+	_, err = user.GetToken(context.Background(), "project2", "targetA")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+
+	sessionToken, err := user.GetToken(context.Background(), "project1", "targetA")
+	assert.NoError(t, err)
+
+	a, err := NewAuthorization(sessionToken)
+	assert.NoError(t, err)
+
+	// A session issued for a target can't get the credentials of the other
+	// targets of the project.
+	session := newTestPostgresProvider(t, store, mockCredentialsIssuer{creds: creds}, a.Key, a.Secret, now)
+	_, err = session.GetTargetCredentials(context.Background(), "project1", "targetB")
+	assert.ErrorIs(t, err, ErrProjectTokenNotFound)
+
+	gotCreds, err := session.GetTargetCredentials(context.Background(), "project1", "targetA")
+	assert.NoError(t, err)
+	assert.Equal(t, creds, gotCreds)
+}
+
+func TestPostgresProviderNoIssuer(t *testing.T) {
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	store := newMemoryPostgresStore()
+	admin := newTestPostgresProvider(t, store, nil, authorizationKeyAdmin, "adminSecret", now)
+
+	token, err := admin.CreateProject(context.Background(), "project1")
+	assert.NoError(t, err)
+	assert.NoError(t, admin.CreateTarget(context.Background(), "project1", types.Target{Name: "target1"}))
+
+	user := newTestPostgresProvider(t, store, nil, token.RoleID, token.Secret, now)
+	sessionToken, err := user.GetToken(context.Background(), "project1", "target1")
+	assert.NoError(t, err)
+
+	a, err := NewAuthorization(sessionToken)
+	assert.NoError(t, err)
+
+	session := newTestPostgresProvider(t, store, nil, a.Key, a.Secret, now)
+	_, err = session.GetTargetCredentials(context.Background(), "project1", "target1")
+	assert.ErrorIs(t, err, ErrNoCredentialsIssuer)
+}
+
+func TestSQLPostgresStoreSharesSession(t *testing.T) {
+	ctx := context.Background()
+
+	// The in-memory database is lost when its session is closed.
+	client, err := db.NewSQLiteClient(":memory:")
+	assert.Nil(t, err)
+	t.Cleanup(func() { client.Close() })
+	_, err = client.MigrateUp(ctx)
+	assert.Nil(t, err)
+
+	calls := 0
+	s := NewSQLPostgresStore(func() (upper.Session, error) {
+		calls++
+		return client.Session()
+	})
+
+	assert.Nil(t, s.CreateProject(ctx, PostgresProject{Name: "project1", RoleID: "role1"}))
+	p, err := s.ReadProject(ctx, "project1")
+	assert.Nil(t, err)
+	assert.Equal(t, PostgresProject{Name: "project1", RoleID: "role1"}, p)
+	assert.Equal(t, 2, calls)
+
+	assert.Nil(t, client.Health(ctx))
+}
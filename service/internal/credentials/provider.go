@@ -0,0 +1,74 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/cello-proj/cello/service/internal/env"
+)
+
+// Provider names, used as the provider prefix of tokens.
+const (
+	ProviderPostgres = "postgres"
+	ProviderVault    = "vault"
+)
+
+// ErrUnknownProvider conveys that no provider is registered for the name.
+var ErrUnknownProvider = errors.New("unknown credentials provider")
+
+// ProviderFn returns the Provider for an authorization.
+type ProviderFn func(a Authorization, env env.Vars, h http.Header, vaultConfigFn VaultConfigFn, vaultSvcFn VaultSvcFn) (Provider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]ProviderFn{
+		ProviderVault: NewVaultProvider,
+	}
+)
+
+// RegisterProvider registers the provider used for authorizations with the
+// name as their provider. Registering a name again replaces its provider.
+func RegisterProvider(name string, fn ProviderFn) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[name] = fn
+}
+
+// RegisteredProviders returns the sorted names of the registered providers.
+func RegisteredProviders() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewProvider returns the registered provider of the authorization.
+func NewProvider(a Authorization, env env.Vars, h http.Header, vaultConfigFn VaultConfigFn, vaultSvcFn VaultSvcFn) (Provider, error) {
+	providersMu.RLock()
+	fn, ok := providers[a.Provider]
+	providersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownProvider, a.Provider)
+	}
+
+	return fn(a, env, h, vaultConfigFn, vaultSvcFn)
+}
+
+func isRegisteredProvider(name string) bool {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	_, ok := providers[name]
+	return ok
+}
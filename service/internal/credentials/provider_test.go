@@ -0,0 +1,37 @@
+package credentials
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/cello-proj/cello/service/internal/env"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProvider(t *testing.T) {
+	want := &PostgresProvider{}
+	RegisterProvider("test", func(a Authorization, env env.Vars, h http.Header, vaultConfigFn VaultConfigFn, vaultSvcFn VaultSvcFn) (Provider, error) {
+		return want, nil
+	})
+	defer func() {
+		providersMu.Lock()
+		delete(providers, "test")
+		providersMu.Unlock()
+	}()
+
+	assert.Equal(t, []string{"test", ProviderVault}, RegisteredProviders())
+
+	got, err := NewProvider(Authorization{Provider: "test", Key: "key", Secret: "secret"}, env.Vars{}, http.Header{}, NewVaultConfig, NewVaultSvc)
+	assert.NoError(t, err)
+	assert.Same(t, want, got)
+
+	_, err = NewProvider(Authorization{Provider: "unknown", Key: "key", Secret: "secret"}, env.Vars{}, http.Header{}, NewVaultConfig, NewVaultSvc)
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+
+	a := Authorization{Provider: "test", Key: "key", Secret: "secret"}
+	assert.NoError(t, a.Validate())
+
+	a = Authorization{Provider: "unknown", Key: "key", Secret: "secret"}
+	assert.EqualError(t, a.Validate(), "provider must be one of test, vault")
+}
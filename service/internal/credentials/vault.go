@@ -3,10 +3,13 @@
 package credentials
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/cello-proj/cello/internal/responses"
 	"github.com/cello-proj/cello/internal/types"
@@ -22,40 +25,37 @@ const (
 
 // Provider defines the interface required by providers.
 type Provider interface {
-	CreateProject(string) (types.Token, error)
-	CreateTarget(string, types.Target) error
-	CreateToken(string) (types.Token, error)
-	UpdateTarget(string, types.Target) error
-	DeleteProject(string) error
-	DeleteTarget(string, string) error
-	GetProject(string) (responses.GetProject, error)
-	GetTarget(string, string) (types.Target, error)
-	GetToken() (string, error)
-	DeleteProjectToken(string, string) error
-	GetProjectToken(string, string) (types.ProjectToken, error)
-	ListTargets(string) ([]string, error)
-	ProjectExists(string) (bool, error)
-	TargetExists(string, string) (bool, error)
+	CreateProject(context.Context, string) (types.Token, error)
+	CreateTarget(context.Context, string, types.Target) error
+	CreateToken(context.Context, string, time.Duration) (types.Token, error)
+	UpdateTarget(context.Context, string, types.Target) error
+	DeleteProject(context.Context, string) error
+	DeleteTarget(context.Context, string, string) error
+	GetProject(context.Context, string) (responses.GetProject, error)
+	GetTarget(context.Context, string, string) (types.Target, error)
+	GetToken(context.Context, string, string) (string, error)
+	DeleteProjectToken(context.Context, string, string) error
+	GetProjectToken(context.Context, string, string) (types.ProjectToken, error)
+	ListProjects(context.Context) ([]string, error)
+	ListProjectTokens(context.Context, string) ([]types.ProjectToken, error)
+	ListTargets(context.Context, string) ([]string, error)
+	LookupProjectToken(context.Context, string) (types.ProjectToken, error)
+	ProjectExists(context.Context, string) (bool, error)
+	TargetExists(context.Context, string, string) (bool, error)
 }
 
 type vaultLogical interface {
-	Delete(path string) (*vault.Secret, error)
-	List(path string) (*vault.Secret, error)
-	Read(path string) (*vault.Secret, error)
-	Write(path string, data map[string]interface{}) (*vault.Secret, error)
+	DeleteWithContext(ctx context.Context, path string) (*vault.Secret, error)
+	ListWithContext(ctx context.Context, path string) (*vault.Secret, error)
+	ReadWithContext(ctx context.Context, path string) (*vault.Secret, error)
+	WriteWithContext(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error)
 }
 
 type vaultSys interface {
-	DeletePolicy(name string) error
-	PutPolicy(name, rules string) error
+	DeletePolicyWithContext(ctx context.Context, name string) error
+	PutPolicyWithContext(ctx context.Context, name, rules string) error
 }
 
-// Vault
-const (
-	vaultAppRolePrefix = "auth/approle/role"
-	vaultProjectPrefix = "argo-cloudops-projects"
-)
-
 var (
 	// ErrNotFound conveys that the item was not found.
 	ErrNotFound = errors.New("item not found")
@@ -66,6 +66,8 @@ var (
 )
 
 type VaultProvider struct {
+	call            vaultCallOptions
+	layout          VaultLayout
 	roleID          string
 	secretID        string
 	vaultLogicalSvc vaultLogical
@@ -75,11 +77,36 @@ type VaultProvider struct {
 // NewVaultProvider returns a new VaultProvider
 func NewVaultProvider(a Authorization, env env.Vars, h http.Header, vaultConfigFn VaultConfigFn, vaultSvcFn VaultSvcFn) (Provider, error) {
 	config := vaultConfigFn(&vault.Config{Address: env.VaultAddress}, env.VaultRole, env.VaultSecret)
-	svc, err := vaultSvcFn(*config, h)
+	return newVaultProvider(a, env, *config, h, vaultSvcFn)
+}
+
+// NewVaultProviderFn returns a ProviderFn creating VaultProviders which use
+// the token of the token source instead of logging in to Vault.
+func NewVaultProviderFn(ts *VaultTokenSource) ProviderFn {
+	return func(a Authorization, env env.Vars, h http.Header, vaultConfigFn VaultConfigFn, vaultSvcFn VaultSvcFn) (Provider, error) {
+		token, err := ts.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		config := vaultConfigFn(&vault.Config{Address: env.VaultAddress}, env.VaultRole, env.VaultSecret)
+		config.token = token
+		return newVaultProvider(a, env, *config, h, vaultSvcFn)
+	}
+}
+
+func newVaultProvider(a Authorization, env env.Vars, config VaultConfig, h http.Header, vaultSvcFn VaultSvcFn) (Provider, error) {
+	layout := NewVaultLayout(env)
+	config.loginPath = layout.login()
+	config.namespace = env.VaultNamespace
+
+	svc, err := vaultSvcFn(config, h)
 	if err != nil {
 		return nil, err
 	}
 	return &VaultProvider{
+		call:            newVaultCallOptions(env),
+		layout:          layout,
 		vaultLogicalSvc: vaultLogical(svc.Logical()),
 		vaultSysSvc:     vaultSys(svc.Sys()),
 		roleID:          a.Key,
@@ -88,9 +115,12 @@ func NewVaultProvider(a Authorization, env env.Vars, h http.Header, vaultConfigF
 }
 
 type VaultConfig struct {
-	config *vault.Config
-	role   string
-	secret string
+	config    *vault.Config
+	loginPath string
+	namespace string
+	role      string
+	secret    string
+	token     string
 }
 
 type VaultConfigFn func(config *vault.Config, role, secret string) *VaultConfig
@@ -106,8 +136,8 @@ func NewVaultConfig(config *vault.Config, role, secret string) *VaultConfig {
 
 type VaultSvcFn func(c VaultConfig, h http.Header) (svc *vault.Client, err error)
 
-// NewVaultSvc returns a new vault.Client.
-// TODO before open sourcing we should provide the token instead of generating it
+// NewVaultSvc returns a new vault.Client. The client uses the token of the
+// config when set, otherwise it logs in with the AppRole of the config.
 // TODO rename to client?
 func NewVaultSvc(c VaultConfig, h http.Header) (*vault.Client, error) {
 	vaultSvc, err := vault.NewClient(c.config)
@@ -116,13 +146,26 @@ func NewVaultSvc(c VaultConfig, h http.Header) (*vault.Client, error) {
 	}
 
 	vaultSvc.SetHeaders(h)
+	if c.namespace != "" {
+		vaultSvc.SetNamespace(c.namespace)
+	}
+
+	if c.token != "" {
+		vaultSvc.SetToken(c.token)
+		return vaultSvc, nil
+	}
 
 	options := map[string]interface{}{
 		"role_id":   c.role,
 		"secret_id": c.secret,
 	}
 
-	sec, err := vaultSvc.Logical().Write("auth/approle/login", options)
+	loginPath := c.loginPath
+	if loginPath == "" {
+		loginPath = "auth/approle/login"
+	}
+
+	sec, err := vaultSvc.Logical().Write(loginPath, options)
 	if err != nil {
 		return nil, err
 	}
@@ -141,8 +184,8 @@ type Authorization struct {
 func (a Authorization) Validate(optionalValidations ...func() error) error {
 	v := []func() error{
 		func() error {
-			if a.Provider != "vault" {
-				return errors.New("provider must be vault")
+			if !isRegisteredProvider(a.Provider) {
+				return fmt.Errorf("provider must be one of %s", strings.Join(RegisteredProviders(), ", "))
 			}
 			return nil
 		},
@@ -185,32 +228,30 @@ func NewAuthorization(authorizationHeader string) (*Authorization, error) {
 	return &a, nil
 }
 
-func (v VaultProvider) createPolicyState(name, policy string) error {
-	return v.vaultSysSvc.PutPolicy(fmt.Sprintf("%s-%s", vaultProjectPrefix, name), policy)
-}
-
-func genProjectAppRole(name string) string {
-	return fmt.Sprintf("%s/%s-%s", vaultAppRolePrefix, vaultProjectPrefix, name)
+func (v VaultProvider) createPolicyState(ctx context.Context, name, policy string) error {
+	return v.putPolicy(ctx, v.layout.policy(name), policy)
 }
 
-func (v VaultProvider) CreateToken(name string) (types.Token, error) {
+// CreateToken creates a token for the project. The AppRole secret ID TTL is
+// used when ttl is 0.
+func (v VaultProvider) CreateToken(ctx context.Context, name string, ttl time.Duration) (types.Token, error) {
 	token := types.Token{}
 
 	if !v.isAdmin() {
 		return token, errors.New("admin credentials must be used to create token")
 	}
 
-	secret, err := v.generateSecrets(name)
+	secret, err := v.generateSecrets(ctx, name, ttl)
 	if err != nil {
 		return token, err
 	}
 
-	roleID, err := v.readRoleID(name)
+	roleID, err := v.readRoleID(ctx, name)
 	if err != nil {
 		return token, err
 	}
 
-	accessor, err := v.readSecretIDAccessor(name, secret.Data["secret_id_accessor"].(string))
+	accessor, err := v.readSecretIDAccessor(ctx, name, secret.Data["secret_id_accessor"].(string))
 	if err != nil {
 		return token, err
 	}
@@ -225,79 +266,86 @@ func (v VaultProvider) CreateToken(name string) (types.Token, error) {
 	return token, nil
 }
 
-func (v VaultProvider) CreateProject(name string) (types.Token, error) {
+func (v VaultProvider) CreateProject(ctx context.Context, name string) (types.Token, error) {
 	token := types.Token{}
 	if !v.isAdmin() {
 		return token, errors.New("admin credentials must be used to create project")
 	}
 
-	policy := defaultVaultReadonlyPolicyAWS(name)
-	err := v.createPolicyState(name, policy)
+	policy := v.layout.readonlyPolicy(name)
+	err := v.createPolicyState(ctx, name, policy)
 	if err != nil {
 		return token, err
 	}
 
-	if err := v.writeProjectState(name); err != nil {
+	if err := v.writeProjectState(ctx, name); err != nil {
 		return token, err
 	}
 
-	return v.CreateToken(name)
+	return v.CreateToken(ctx, name, 0)
 }
 
 // CreateTarget creates a target for the project.
 // TODO validate policy and other information is correct in target
 // TODO Validate role exists (if possible, etc)
-func (v VaultProvider) CreateTarget(projectName string, target types.Target) error {
+func (v VaultProvider) CreateTarget(ctx context.Context, projectName string, target types.Target) error {
 	if !v.isAdmin() {
 		return errors.New("admin credentials must be used to create target")
 	}
 
-	options := map[string]interface{}{
-		"credential_type": target.Properties.CredentialType,
-		"policy_arns":     target.Properties.PolicyArns,
-		"policy_document": target.Properties.PolicyDocument,
-		"role_arns":       target.Properties.RoleArn,
-	}
-
-	path := fmt.Sprintf("aws/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, target.Name)
-	_, err := v.vaultLogicalSvc.Write(path, options)
+	path := v.layout.awsRole(projectName, target.Name)
+	_, err := v.write(ctx, path, targetRoleOptions(target.Properties))
 	return err
 }
 
-func defaultVaultReadonlyPolicyAWS(projectName string) string {
-	return fmt.Sprintf(
-		"path \"aws/sts/argo-cloudops-projects-%s-target-*\" { capabilities = [\"read\"] }",
-		projectName,
-	)
+// targetRoleOptions returns the Vault AWS role options for the target
+// properties. The region is not stored in Vault as AWS roles have no field
+// for it.
+func targetRoleOptions(properties types.TargetProperties) map[string]interface{} {
+	sessionTags := properties.SessionTags
+	if sessionTags == nil {
+		sessionTags = map[string]string{}
+	}
+
+	return map[string]interface{}{
+		"credential_type": properties.CredentialType,
+		"default_sts_ttl": properties.DefaultSTSTTL,
+		"external_id":     properties.ExternalID,
+		"max_sts_ttl":     properties.MaxSTSTTL,
+		"policy_arns":     properties.PolicyArns,
+		"policy_document": properties.PolicyDocument,
+		"role_arns":       properties.RoleArn,
+		"session_tags":    sessionTags,
+	}
 }
 
-func (v VaultProvider) deletePolicyState(name string) error {
-	return v.vaultSysSvc.DeletePolicy(fmt.Sprintf("%s-%s", vaultProjectPrefix, name))
+func (v VaultProvider) deletePolicyState(ctx context.Context, name string) error {
+	return v.deletePolicy(ctx, v.layout.policy(name))
 }
 
-func (v VaultProvider) DeleteProject(name string) error {
+func (v VaultProvider) DeleteProject(ctx context.Context, name string) error {
 	if !v.isAdmin() {
 		return errors.New("admin credentials must be used to delete project")
 	}
 
-	err := v.deletePolicyState(name)
+	err := v.deletePolicyState(ctx, name)
 	if err != nil {
 		return fmt.Errorf("vault delete project error: %w", err)
 	}
 
-	if _, err = v.vaultLogicalSvc.Delete(genProjectAppRole(name)); err != nil {
+	if _, err = v.delete(ctx, v.layout.appRole(name)); err != nil {
 		return fmt.Errorf("vault delete project error: %w", err)
 	}
 	return nil
 }
 
-func (v VaultProvider) DeleteTarget(projectName string, targetName string) error {
+func (v VaultProvider) DeleteTarget(ctx context.Context, projectName string, targetName string) error {
 	if !v.isAdmin() {
 		return errors.New("admin credentials must be used to delete target")
 	}
 
-	path := fmt.Sprintf("aws/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, targetName)
-	_, err := v.vaultLogicalSvc.Delete(path)
+	path := v.layout.awsRole(projectName, targetName)
+	_, err := v.delete(ctx, path)
 	return err
 }
 
@@ -309,8 +357,8 @@ const (
 	vaultTokenNumUses = 3
 )
 
-func (v VaultProvider) GetProject(projectName string) (responses.GetProject, error) {
-	sec, err := v.vaultLogicalSvc.Read(genProjectAppRole(projectName))
+func (v VaultProvider) GetProject(ctx context.Context, projectName string) (responses.GetProject, error) {
+	sec, err := v.read(ctx, v.layout.appRole(projectName))
 	if err != nil {
 		return responses.GetProject{}, fmt.Errorf("vault get project error: %w", err)
 	}
@@ -321,12 +369,12 @@ func (v VaultProvider) GetProject(projectName string) (responses.GetProject, err
 	return responses.GetProject{Name: projectName}, nil
 }
 
-func (v VaultProvider) GetTarget(projectName, targetName string) (types.Target, error) {
+func (v VaultProvider) GetTarget(ctx context.Context, projectName, targetName string) (types.Target, error) {
 	if !v.isAdmin() {
 		return types.Target{}, errors.New("admin credentials must be used to get target information")
 	}
 
-	sec, err := v.vaultLogicalSvc.Read(fmt.Sprintf("aws/roles/argo-cloudops-projects-%s-target-%s", projectName, targetName))
+	sec, err := v.read(ctx, v.layout.awsRole(projectName, targetName))
 	if err != nil {
 		return types.Target{}, fmt.Errorf("vault get target error: %w", err)
 	}
@@ -353,20 +401,69 @@ func (v VaultProvider) GetTarget(projectName, targetName string) (types.Target,
 		policyDocument = val.(string)
 	}
 
+	// Optional.
+	var externalID string
+	if val, ok := sec.Data["external_id"].(string); ok {
+		externalID = val
+	}
+
+	// Optional.
+	var sessionTags map[string]string
+	if val, ok := sec.Data["session_tags"].(map[string]interface{}); ok && len(val) > 0 {
+		sessionTags = map[string]string{}
+		for k, v := range val {
+			sessionTags[k] = fmt.Sprint(v)
+		}
+	}
+
+	defaultSTSTTL, err := secondsFromData(sec.Data["default_sts_ttl"])
+	if err != nil {
+		return types.Target{}, fmt.Errorf("vault get target error, invalid default_sts_ttl: %w", err)
+	}
+
+	maxSTSTTL, err := secondsFromData(sec.Data["max_sts_ttl"])
+	if err != nil {
+		return types.Target{}, fmt.Errorf("vault get target error, invalid max_sts_ttl: %w", err)
+	}
+
 	return types.Target{
 		Name: targetName,
 		// target 'Type' always 'aws_account', currently not stored in Vault
 		Type: "aws_account",
 		Properties: types.TargetProperties{
 			CredentialType: credentialType,
+			DefaultSTSTTL:  defaultSTSTTL,
+			ExternalID:     externalID,
+			MaxSTSTTL:      maxSTSTTL,
 			PolicyArns:     policies,
 			PolicyDocument: policyDocument,
 			RoleArn:        roleArn,
+			SessionTags:    sessionTags,
 		},
 	}, nil
 }
 
-func (v VaultProvider) DeleteProjectToken(projectName, tokenID string) error {
+// secondsFromData converts a Vault duration field to seconds. Vault returns
+// durations as a json.Number, a missing field is treated as 0.
+func secondsFromData(val interface{}) (int, error) {
+	switch v := val.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("unexpected type %T", val)
+	}
+}
+
+func (v VaultProvider) DeleteProjectToken(ctx context.Context, projectName, tokenID string) error {
 	if !v.isAdmin() {
 		return errors.New("admin credentials must be used to delete tokens")
 	}
@@ -375,8 +472,8 @@ func (v VaultProvider) DeleteProjectToken(projectName, tokenID string) error {
 		"secret_id_accessor": tokenID,
 	}
 
-	path := fmt.Sprintf("%s/secret-id-accessor/destroy", genProjectAppRole(projectName))
-	_, err := v.vaultLogicalSvc.Write(path, data)
+	path := fmt.Sprintf("%s/secret-id-accessor/destroy", v.layout.appRole(projectName))
+	_, err := v.write(ctx, path, data)
 	if err != nil {
 		return err
 	}
@@ -384,7 +481,7 @@ func (v VaultProvider) DeleteProjectToken(projectName, tokenID string) error {
 	return nil
 }
 
-func (v VaultProvider) GetProjectToken(projectName, tokenID string) (types.ProjectToken, error) {
+func (v VaultProvider) GetProjectToken(ctx context.Context, projectName, tokenID string) (types.ProjectToken, error) {
 	token := types.ProjectToken{}
 
 	if !v.isAdmin() {
@@ -395,8 +492,8 @@ func (v VaultProvider) GetProjectToken(projectName, tokenID string) (types.Proje
 		"secret_id_accessor": tokenID,
 	}
 
-	path := fmt.Sprintf("%s/secret-id-accessor/lookup", genProjectAppRole(projectName))
-	projectToken, err := v.vaultLogicalSvc.Write(path, data)
+	path := fmt.Sprintf("%s/secret-id-accessor/lookup", v.layout.appRole(projectName))
+	projectToken, err := v.lookup(ctx, path, data)
 	if err != nil {
 		if !isSecretIDAccessorExists(err) {
 			return token, ErrProjectTokenNotFound
@@ -408,12 +505,14 @@ func (v VaultProvider) GetProjectToken(projectName, tokenID string) (types.Proje
 		return token, nil
 	}
 
+	createdAt, _ := projectToken.Data["creation_time"].(string)
 	return types.ProjectToken{
-		ID: projectToken.Data["secret_id_accessor"].(string),
+		CreatedAt: createdAt,
+		ID:        projectToken.Data["secret_id_accessor"].(string),
 	}, nil
 }
 
-func (v VaultProvider) GetToken() (string, error) {
+func (v VaultProvider) GetToken(ctx context.Context, projectName, targetName string) (string, error) {
 	if v.isAdmin() {
 		return "", errors.New("admin credentials cannot be used to get tokens")
 	}
@@ -423,7 +522,7 @@ func (v VaultProvider) GetToken() (string, error) {
 		"secret_id": v.secretID,
 	}
 
-	sec, err := v.vaultLogicalSvc.Write("auth/approle/login", options)
+	sec, err := v.write(ctx, v.layout.login(), options)
 	if err != nil {
 		fmt.Println(err.Error())
 		return "", err
@@ -432,17 +531,104 @@ func (v VaultProvider) GetToken() (string, error) {
 	return sec.Auth.ClientToken, nil
 }
 
+// LookupProjectToken returns the project token of the authorization. It
+// returns ErrProjectTokenNotFound if the authorization is not a token of the
+// project.
+func (v VaultProvider) LookupProjectToken(ctx context.Context, projectName string) (types.ProjectToken, error) {
+	token := types.ProjectToken{}
+
+	if v.isAdmin() {
+		return token, errors.New("admin credentials cannot be used to lookup project tokens")
+	}
+
+	roleID, err := v.readRoleID(ctx, projectName)
+	if err != nil {
+		return token, fmt.Errorf("vault get role ID error: %w", err)
+	}
+
+	if roleID != v.roleID {
+		return token, ErrProjectTokenNotFound
+	}
+
+	data := map[string]interface{}{
+		"secret_id": v.secretID,
+	}
+
+	path := fmt.Sprintf("%s/secret-id/lookup", v.layout.appRole(projectName))
+	sec, err := v.lookup(ctx, path, data)
+	if err != nil {
+		return token, fmt.Errorf("vault lookup secret ID error: %w", err)
+	}
+
+	// Vault returns no data when the secret ID does not exist.
+	if sec == nil || sec.Data == nil {
+		return token, ErrProjectTokenNotFound
+	}
+
+	accessor, ok := sec.Data["secret_id_accessor"].(string)
+	if !ok {
+		return token, ErrProjectTokenNotFound
+	}
+
+	return types.ProjectToken{ID: accessor}, nil
+}
+
 // TODO See if this can be removed when refactoring auth.
 func (v VaultProvider) isAdmin() bool {
 	return v.roleID == authorizationKeyAdmin
 }
 
-func (v VaultProvider) ListTargets(project string) ([]string, error) {
+// ListProjects lists the names of all projects.
+func (v VaultProvider) ListProjects(ctx context.Context) ([]string, error) {
+	if !v.isAdmin() {
+		return nil, errors.New("admin credentials must be used to list projects")
+	}
+
+	sec, err := v.list(ctx, v.layout.appRoles())
+	if err != nil {
+		return nil, fmt.Errorf("vault list error: %w", err)
+	}
+
+	// allow empty array to render json as []
+	list := make([]string, 0)
+	if sec != nil {
+		for _, role := range sec.Data["keys"].([]interface{}) {
+			if project, ok := v.layout.project(role.(string)); ok {
+				list = append(list, project)
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// ListProjectTokens lists the tokens of a project.
+func (v VaultProvider) ListProjectTokens(ctx context.Context, projectName string) ([]types.ProjectToken, error) {
+	if !v.isAdmin() {
+		return nil, errors.New("admin credentials must be used to list tokens")
+	}
+
+	sec, err := v.list(ctx, fmt.Sprintf("%s/secret-id", v.layout.appRole(projectName)))
+	if err != nil {
+		return nil, fmt.Errorf("vault list error: %w", err)
+	}
+
+	list := make([]types.ProjectToken, 0)
+	if sec != nil {
+		for _, accessor := range sec.Data["keys"].([]interface{}) {
+			list = append(list, types.ProjectToken{ID: accessor.(string)})
+		}
+	}
+
+	return list, nil
+}
+
+func (v VaultProvider) ListTargets(ctx context.Context, project string) ([]string, error) {
 	if !v.isAdmin() {
 		return nil, errors.New("admin credentials must be used to list targets")
 	}
 
-	sec, err := v.vaultLogicalSvc.List("aws/roles/")
+	sec, err := v.list(ctx, v.layout.awsRoles())
 	if err != nil {
 		return nil, fmt.Errorf("vault list error: %w", err)
 	}
@@ -452,7 +638,7 @@ func (v VaultProvider) ListTargets(project string) ([]string, error) {
 	if sec != nil {
 		for _, target := range sec.Data["keys"].([]interface{}) {
 			value := target.(string)
-			prefix := fmt.Sprintf("argo-cloudops-projects-%s-target-", project)
+			prefix := v.layout.awsRolePrefix(project)
 			if strings.HasPrefix(value, prefix) {
 				list = append(list, strings.Replace(value, prefix, "", 1))
 			}
@@ -462,8 +648,8 @@ func (v VaultProvider) ListTargets(project string) ([]string, error) {
 	return list, nil
 }
 
-func (v VaultProvider) ProjectExists(name string) (bool, error) {
-	p, err := v.GetProject(name)
+func (v VaultProvider) ProjectExists(ctx context.Context, name string) (bool, error) {
+	p, err := v.GetProject(ctx, name)
 	if errors.Is(err, ErrNotFound) {
 		return false, nil
 	}
@@ -475,71 +661,76 @@ func (v VaultProvider) ProjectExists(name string) (bool, error) {
 	return p.Name != "", nil
 }
 
-func (v VaultProvider) readRoleID(appRoleName string) (string, error) {
-	secret, err := v.vaultLogicalSvc.Read(fmt.Sprintf("%s/role-id", genProjectAppRole(appRoleName)))
+func (v VaultProvider) readRoleID(ctx context.Context, appRoleName string) (string, error) {
+	secret, err := v.read(ctx, fmt.Sprintf("%s/role-id", v.layout.appRole(appRoleName)))
 	if err != nil {
 		return "", err
 	}
 	return secret.Data["role_id"].(string), nil
 }
 
-func (v VaultProvider) readSecretIDAccessor(appRoleName, accessor string) (*vault.Secret, error) {
+func (v VaultProvider) readSecretIDAccessor(ctx context.Context, appRoleName, accessor string) (*vault.Secret, error) {
 	options := map[string]interface{}{
 		"secret_id_accessor": accessor,
 	}
 
-	secret, err := v.vaultLogicalSvc.Write(fmt.Sprintf("%s/secret-id-accessor/lookup", genProjectAppRole(appRoleName)), options)
+	secret, err := v.lookup(ctx, fmt.Sprintf("%s/secret-id-accessor/lookup", v.layout.appRole(appRoleName)), options)
 	if err != nil {
 		return secret, err
 	}
 	return secret, nil
 }
 
-func (v VaultProvider) generateSecrets(appRoleName string) (*vault.Secret, error) {
+func (v VaultProvider) generateSecrets(ctx context.Context, appRoleName string, ttl time.Duration) (*vault.Secret, error) {
 	options := map[string]interface{}{
 		"force": true,
 	}
 
-	secret, err := v.vaultLogicalSvc.Write(fmt.Sprintf("%s/secret-id", genProjectAppRole(appRoleName)), options)
+	if ttl > 0 {
+		options["ttl"] = int(ttl.Seconds())
+	}
+
+	secret, err := v.write(ctx, fmt.Sprintf("%s/secret-id", v.layout.appRole(appRoleName)), options)
 	if err != nil {
 		return secret, err
 	}
 	return secret, nil
 }
 
-func (v VaultProvider) TargetExists(projectName, targetName string) (bool, error) {
-	_, err := v.GetTarget(projectName, targetName)
-	return !errors.Is(err, ErrTargetNotFound), nil
+func (v VaultProvider) TargetExists(ctx context.Context, projectName, targetName string) (bool, error) {
+	_, err := v.GetTarget(ctx, projectName, targetName)
+	if errors.Is(err, ErrTargetNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 // UpdateTarget updates a targets policies for the project.
-func (v VaultProvider) UpdateTarget(projectName string, target types.Target) error {
+func (v VaultProvider) UpdateTarget(ctx context.Context, projectName string, target types.Target) error {
 	if !v.isAdmin() {
 		return errors.New("admin credentials must be used to update target")
 	}
 
-	options := map[string]interface{}{
-		"credential_type": target.Properties.CredentialType,
-		"policy_arns":     target.Properties.PolicyArns,
-		"policy_document": target.Properties.PolicyDocument,
-		"role_arns":       target.Properties.RoleArn,
-	}
-
-	path := fmt.Sprintf("aws/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, target.Name)
-	_, err := v.vaultLogicalSvc.Write(path, options)
+	path := v.layout.awsRole(projectName, target.Name)
+	_, err := v.write(ctx, path, targetRoleOptions(target.Properties))
 	return err
 }
 
-func (v VaultProvider) writeProjectState(name string) error {
+func (v VaultProvider) writeProjectState(ctx context.Context, name string) error {
 	options := map[string]interface{}{
 		"secret_id_ttl":           vaultSecretTTL,
 		"token_max_ttl":           vaultTokenMaxTTL,
 		"token_no_default_policy": "true",
 		"token_num_uses":          vaultTokenNumUses,
-		"token_policies":          fmt.Sprintf("%s-%s", vaultProjectPrefix, name),
+		"token_policies":          v.layout.policy(name),
 	}
 
-	_, err := v.vaultLogicalSvc.Write(genProjectAppRole(name), options)
+	_, err := v.write(ctx, v.layout.appRole(name), options)
 	if err != nil {
 		return err
 	}
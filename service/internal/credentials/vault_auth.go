@@ -0,0 +1,225 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/env"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+const (
+	// vaultTokenRetryInterval is the time to wait before retrying a failed
+	// renewal or login.
+	vaultTokenRetryInterval = 10 * time.Second
+)
+
+// ErrNoVaultToken conveys that the service has not logged in to Vault.
+var ErrNoVaultToken = errors.New("no vault token")
+
+// VaultTokenSource logs the service in to Vault and keeps its token renewed so
+// requests don't each have to log in.
+type VaultTokenSource struct {
+	login func(ctx context.Context) (*vault.Secret, error)
+	renew func(ctx context.Context, token string) (*vault.Secret, error)
+
+	mu        sync.RWMutex
+	token     string
+	ttl       time.Duration
+	loginTTL  time.Duration
+	renewable bool
+}
+
+// NewVaultTokenSource returns a VaultTokenSource logging in with the
+// Kubernetes auth method when a Kubernetes role is configured, falling back
+// to AppRole when it fails and an AppRole role and secret are configured.
+func NewVaultTokenSource(env env.Vars) (*VaultTokenSource, error) {
+	svc, err := vault.NewClient(&vault.Config{Address: env.VaultAddress})
+	if err != nil {
+		return nil, err
+	}
+	// The token of the environment must not be used to log in.
+	svc.ClearToken()
+	if env.VaultNamespace != "" {
+		svc.SetNamespace(env.VaultNamespace)
+	}
+
+	logins := []func(ctx context.Context) (*vault.Secret, error){}
+	if env.VaultK8SRole != "" {
+		logins = append(logins, func(ctx context.Context) (*vault.Secret, error) {
+			return vaultKubernetesLogin(ctx, svc.Logical(), env.VaultK8SMount, env.VaultK8SRole, env.VaultK8STokenFile)
+		})
+	}
+	if env.VaultRole != "" && env.VaultSecret != "" {
+		logins = append(logins, func(ctx context.Context) (*vault.Secret, error) {
+			return vaultAppRoleLogin(ctx, svc.Logical(), NewVaultLayout(env).login(), env.VaultRole, env.VaultSecret)
+		})
+	}
+
+	if len(logins) == 0 {
+		return nil, errors.New("vault kubernetes role, or approle role and secret, are required")
+	}
+
+	return &VaultTokenSource{
+		login: func(ctx context.Context) (*vault.Secret, error) {
+			var errs []string
+			for _, login := range logins {
+				sec, err := login(ctx)
+				if err == nil {
+					return sec, nil
+				}
+				errs = append(errs, err.Error())
+			}
+			return nil, fmt.Errorf("vault login error: %s", strings.Join(errs, ", "))
+		},
+		renew: func(ctx context.Context, token string) (*vault.Secret, error) {
+			return svc.Auth().Token().RenewTokenAsSelfWithContext(ctx, token, 0)
+		},
+	}, nil
+}
+
+func vaultKubernetesLogin(ctx context.Context, svc vaultLogical, mount, role, tokenFile string) (*vault.Secret, error) {
+	jwt, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read kubernetes service account token: %w", err)
+	}
+
+	options := map[string]interface{}{
+		"jwt":  strings.TrimSpace(string(jwt)),
+		"role": role,
+	}
+
+	sec, err := svc.WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", mount), options)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes login error: %w", err)
+	}
+	return sec, nil
+}
+
+func vaultAppRoleLogin(ctx context.Context, svc vaultLogical, path, role, secret string) (*vault.Secret, error) {
+	options := map[string]interface{}{
+		"role_id":   role,
+		"secret_id": secret,
+	}
+
+	sec, err := svc.WriteWithContext(ctx, path, options)
+	if err != nil {
+		return nil, fmt.Errorf("approle login error: %w", err)
+	}
+	return sec, nil
+}
+
+// Token returns the cached token.
+func (s *VaultTokenSource) Token() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.token == "" {
+		return "", ErrNoVaultToken
+	}
+	return s.token, nil
+}
+
+// Login logs in to Vault and caches the token.
+func (s *VaultTokenSource) Login(ctx context.Context) error {
+	sec, err := s.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.set(sec); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.loginTTL = s.ttl
+	s.mu.Unlock()
+	return nil
+}
+
+// Renew renews the cached token. The token is replaced by logging in again
+// when it isn't renewable or renewals were capped by its max TTL.
+func (s *VaultTokenSource) Renew(ctx context.Context) error {
+	s.mu.RLock()
+	token, renewable, loginTTL := s.token, s.renewable, s.loginTTL
+	s.mu.RUnlock()
+
+	if !renewable {
+		return s.Login(ctx)
+	}
+
+	sec, err := s.renew(ctx, token)
+	if err != nil {
+		return s.Login(ctx)
+	}
+
+	if sec == nil || sec.Auth == nil || time.Duration(sec.Auth.LeaseDuration)*time.Second < loginTTL/3 {
+		return s.Login(ctx)
+	}
+
+	return s.set(sec)
+}
+
+func (s *VaultTokenSource) set(sec *vault.Secret) error {
+	if sec == nil || sec.Auth == nil || sec.Auth.ClientToken == "" {
+		return errors.New("vault login returned no token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = sec.Auth.ClientToken
+	s.ttl = time.Duration(sec.Auth.LeaseDuration) * time.Second
+	s.renewable = sec.Auth.Renewable
+	return nil
+}
+
+// nextRenewal returns the time to wait before renewing the token, two thirds
+// of its TTL. It returns false when the token doesn't expire.
+func (s *VaultTokenSource) nextRenewal() (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.ttl <= 0 {
+		return 0, false
+	}
+	return s.ttl * 2 / 3, true
+}
+
+// Run renews the token before its lease ends until the context is done.
+// Failures are passed to errFn and retried.
+func (s *VaultTokenSource) Run(ctx context.Context, errFn func(error)) {
+	for {
+		wait, ok := s.nextRenewal()
+		if !ok {
+			<-ctx.Done()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		for {
+			err := s.Renew(ctx)
+			if err == nil {
+				break
+			}
+			errFn(err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(vaultTokenRetryInterval):
+			}
+		}
+	}
+}
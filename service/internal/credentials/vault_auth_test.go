@@ -0,0 +1,143 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cello-proj/cello/service/internal/env"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func vaultAuthSecret(token string, ttl int, renewable bool) *vault.Secret {
+	return &vault.Secret{Auth: &vault.SecretAuth{ClientToken: token, LeaseDuration: ttl, Renewable: renewable}}
+}
+
+func TestVaultTokenSource(t *testing.T) {
+	logins := 0
+	renewTTL := 3600
+	var renewErr error
+
+	ts := &VaultTokenSource{
+		login: func(ctx context.Context) (*vault.Secret, error) {
+			logins++
+			if logins == 1 {
+				return vaultAuthSecret("token1", 3600, true), nil
+			}
+			return vaultAuthSecret("token2", 3600, false), nil
+		},
+		renew: func(ctx context.Context, token string) (*vault.Secret, error) {
+			assert.Equal(t, "token1", token)
+			return vaultAuthSecret(token, renewTTL, true), renewErr
+		},
+	}
+
+	_, err := ts.Token()
+	assert.ErrorIs(t, err, ErrNoVaultToken)
+
+	assert.NoError(t, ts.Login(context.Background()))
+	token, err := ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	wait, ok := ts.nextRenewal()
+	assert.True(t, ok)
+	assert.Equal(t, "40m0s", wait.String())
+
+	// Renewing keeps the token.
+	assert.NoError(t, ts.Renew(context.Background()))
+	assert.Equal(t, 1, logins)
+
+	// Renewals capped by the max TTL log in again.
+	renewTTL = 60
+	assert.NoError(t, ts.Renew(context.Background()))
+	assert.Equal(t, 2, logins)
+	token, _ = ts.Token()
+	assert.Equal(t, "token2", token)
+
+	// Tokens which aren't renewable log in again.
+	assert.NoError(t, ts.Renew(context.Background()))
+	assert.Equal(t, 3, logins)
+
+	// Failed renewals log in again.
+	ts.renewable = true
+	renewErr = errors.New("error")
+	ts.renew = func(ctx context.Context, token string) (*vault.Secret, error) { return nil, renewErr }
+	assert.NoError(t, ts.Renew(context.Background()))
+	assert.Equal(t, 4, logins)
+
+	// Failed logins keep the cached token.
+	ts.login = func(ctx context.Context) (*vault.Secret, error) { return nil, errors.New("error") }
+	assert.Error(t, ts.Renew(context.Background()))
+	token, err = ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", token)
+}
+
+func TestVaultTokenSourceNoExpiry(t *testing.T) {
+	ts := &VaultTokenSource{
+		login: func(ctx context.Context) (*vault.Secret, error) { return vaultAuthSecret("root", 0, false), nil },
+	}
+
+	assert.NoError(t, ts.Login(context.Background()))
+	_, ok := ts.nextRenewal()
+	assert.False(t, ok)
+}
+
+func TestNewVaultTokenSource(t *testing.T) {
+	_, err := NewVaultTokenSource(env.Vars{VaultAddress: "http://localhost:8200"})
+	assert.EqualError(t, err, "vault kubernetes role, or approle role and secret, are required")
+
+	ts, err := NewVaultTokenSource(env.Vars{VaultAddress: "http://localhost:8200", VaultRole: "role", VaultSecret: "secret"})
+	assert.NoError(t, err)
+	assert.NotNil(t, ts)
+}
+
+func TestVaultLogins(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("jwt\n"), 0600))
+
+	svc := &mockVaultLogical{token: "token"}
+	sec, err := vaultKubernetesLogin(context.Background(), svc, "kubernetes", "cello", tokenFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "token", sec.Auth.ClientToken)
+
+	_, err = vaultKubernetesLogin(context.Background(), svc, "kubernetes", "cello", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	sec, err = vaultAppRoleLogin(context.Background(), svc, "auth/approle/login", "role", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "token", sec.Auth.ClientToken)
+
+	_, err = vaultAppRoleLogin(context.Background(), &mockVaultLogical{err: errTest}, "auth/approle/login", "role", "secret")
+	assert.EqualError(t, err, "approle login error: error")
+}
+
+func TestNewVaultProviderFn(t *testing.T) {
+	ts := &VaultTokenSource{
+		login: func(ctx context.Context) (*vault.Secret, error) { return vaultAuthSecret("token", 3600, true), nil },
+	}
+
+	var gotToken string
+	svcFn := func(c VaultConfig, h http.Header) (*vault.Client, error) {
+		gotToken = c.token
+		return vault.NewClient(c.config)
+	}
+
+	fn := NewVaultProviderFn(ts)
+	a := Authorization{Provider: ProviderVault, Key: "role", Secret: "secret"}
+
+	_, err := fn(a, env.Vars{}, http.Header{}, NewVaultConfig, svcFn)
+	assert.ErrorIs(t, err, ErrNoVaultToken)
+
+	assert.NoError(t, ts.Login(context.Background()))
+	cp, err := fn(a, env.Vars{}, http.Header{}, NewVaultConfig, svcFn)
+	assert.NoError(t, err)
+	assert.Equal(t, "token", gotToken)
+	assert.Equal(t, "role", cp.(*VaultProvider).roleID)
+}
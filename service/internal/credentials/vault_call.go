@@ -0,0 +1,114 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/env"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// vaultCallOptions configures the timeout of each Vault call and the retries
+// of idempotent ones.
+type vaultCallOptions struct {
+	// backoff is the wait before the first retry, it doubles for each
+	// retry.
+	backoff time.Duration
+	retries int
+	timeout time.Duration
+}
+
+func newVaultCallOptions(env env.Vars) vaultCallOptions {
+	return vaultCallOptions{
+		backoff: env.VaultRetryBackoff,
+		retries: env.VaultReadRetries,
+		timeout: env.VaultTimeout,
+	}
+}
+
+// do calls fn with the timeout. It's retried with backoff when retry is set
+// and the error isn't caused by the request or the context.
+func (o vaultCallOptions) do(ctx context.Context, retry bool, fn func(ctx context.Context) (*vault.Secret, error)) (*vault.Secret, error) {
+	backoff := o.backoff
+	for attempt := 0; ; attempt++ {
+		sec, err := o.once(ctx, fn)
+		if err == nil || !retry || attempt >= o.retries || ctx.Err() != nil || !isRetryableVaultError(err) {
+			return sec, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (o vaultCallOptions) once(ctx context.Context, fn func(ctx context.Context) (*vault.Secret, error)) (*vault.Secret, error) {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// isRetryableVaultError returns whether the error is a server or connection
+// error. Client errors won't succeed when retried.
+func isRetryableVaultError(err error) bool {
+	var respErr *vault.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode >= http.StatusInternalServerError || respErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func (v VaultProvider) read(ctx context.Context, path string) (*vault.Secret, error) {
+	return v.call.do(ctx, true, func(ctx context.Context) (*vault.Secret, error) {
+		return v.vaultLogicalSvc.ReadWithContext(ctx, path)
+	})
+}
+
+func (v VaultProvider) list(ctx context.Context, path string) (*vault.Secret, error) {
+	return v.call.do(ctx, true, func(ctx context.Context) (*vault.Secret, error) {
+		return v.vaultLogicalSvc.ListWithContext(ctx, path)
+	})
+}
+
+// lookup writes to a path which only reads data, like the lookup of a secret
+// ID, so it's retried like a read.
+func (v VaultProvider) lookup(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	return v.call.do(ctx, true, func(ctx context.Context) (*vault.Secret, error) {
+		return v.vaultLogicalSvc.WriteWithContext(ctx, path, data)
+	})
+}
+
+func (v VaultProvider) write(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	return v.call.do(ctx, false, func(ctx context.Context) (*vault.Secret, error) {
+		return v.vaultLogicalSvc.WriteWithContext(ctx, path, data)
+	})
+}
+
+func (v VaultProvider) delete(ctx context.Context, path string) (*vault.Secret, error) {
+	return v.call.do(ctx, false, func(ctx context.Context) (*vault.Secret, error) {
+		return v.vaultLogicalSvc.DeleteWithContext(ctx, path)
+	})
+}
+
+func (v VaultProvider) putPolicy(ctx context.Context, name, rules string) error {
+	_, err := v.call.do(ctx, false, func(ctx context.Context) (*vault.Secret, error) {
+		return nil, v.vaultSysSvc.PutPolicyWithContext(ctx, name, rules)
+	})
+	return err
+}
+
+func (v VaultProvider) deletePolicy(ctx context.Context, name string) error {
+	_, err := v.call.do(ctx, false, func(ctx context.Context) (*vault.Secret, error) {
+		return nil, v.vaultSysSvc.DeletePolicyWithContext(ctx, name)
+	})
+	return err
+}
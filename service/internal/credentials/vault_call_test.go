@@ -0,0 +1,92 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultCallOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		retry     bool
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "success",
+			retry:     true,
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "retries server errors",
+			retry:     true,
+			errs:      []error{&vault.ResponseError{StatusCode: http.StatusServiceUnavailable}, errors.New("connection refused"), nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "stops after retries",
+			retry:     true,
+			errs:      []error{errTest, errTest, errTest, nil},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "does not retry client errors",
+			retry:     true,
+			errs:      []error{&vault.ResponseError{StatusCode: http.StatusBadRequest}, nil},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "does not retry writes",
+			errs:      []error{errTest, nil},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := vaultCallOptions{backoff: time.Millisecond, retries: 2, timeout: time.Second}
+
+			calls := 0
+			_, err := o.do(context.Background(), tt.retry, func(ctx context.Context) (*vault.Secret, error) {
+				_, ok := ctx.Deadline()
+				assert.True(t, ok)
+
+				calls++
+				return nil, tt.errs[calls-1]
+			})
+
+			assert.Equal(t, tt.wantCalls, calls)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVaultCallOptionsCancelled(t *testing.T) {
+	o := vaultCallOptions{backoff: time.Hour, retries: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, err := o.do(ctx, true, func(ctx context.Context) (*vault.Secret, error) {
+		calls++
+		cancel()
+		return nil, errTest
+	})
+
+	assert.Equal(t, 1, calls)
+	assert.Error(t, err)
+}
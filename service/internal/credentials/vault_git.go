@@ -0,0 +1,98 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cello-proj/cello/internal/types"
+)
+
+// ErrGitCredentialsNotFound conveys that the git credentials were not found.
+var ErrGitCredentialsNotFound = errors.New("git credentials not found")
+
+// GitCredentialsProvider keeps the git credentials of projects. Credentials
+// are read with the reference returned when they're written, which is kept
+// in the project entry.
+type GitCredentialsProvider interface {
+	DeleteGitCredentials(ctx context.Context, ref string) error
+	ReadGitCredentials(ctx context.Context, ref string) (types.GitCredentials, error)
+	WriteGitCredentials(ctx context.Context, projectName string, c types.GitCredentials) (string, error)
+}
+
+// WriteGitCredentials writes the git credentials of the project to the KV
+// secrets engine, and returns their path as the reference.
+func (v VaultProvider) WriteGitCredentials(ctx context.Context, projectName string, c types.GitCredentials) (string, error) {
+	if !v.isAdmin() {
+		return "", errors.New("admin credentials must be used to write git credentials")
+	}
+
+	data := map[string]interface{}{}
+	for k, val := range map[string]string{
+		"https_password":  c.HTTPSPassword,
+		"https_username":  c.HTTPSUsername,
+		"ssh_private_key": c.SSHPrivateKey,
+	} {
+		if val != "" {
+			data[k] = val
+		}
+	}
+
+	path := v.layout.gitCredentials(projectName)
+	if _, err := v.write(ctx, path, map[string]interface{}{"data": data}); err != nil {
+		return "", fmt.Errorf("vault write git credentials error: %w", err)
+	}
+	return path, nil
+}
+
+// ReadGitCredentials reads the git credentials at the path of the reference.
+// They're read with the credentials of the service, so the tokens of projects
+// never have access to them.
+func (v VaultProvider) ReadGitCredentials(ctx context.Context, ref string) (types.GitCredentials, error) {
+	if _, ok := v.layout.gitCredentialsMetadata(ref); !ok {
+		return types.GitCredentials{}, fmt.Errorf("git credentials reference '%s' is not in the kv secrets engine", ref)
+	}
+
+	sec, err := v.read(ctx, ref)
+	if err != nil {
+		return types.GitCredentials{}, fmt.Errorf("vault read git credentials error: %w", err)
+	}
+
+	if sec == nil || sec.Data == nil {
+		return types.GitCredentials{}, ErrGitCredentialsNotFound
+	}
+
+	data, ok := sec.Data["data"].(map[string]interface{})
+	if !ok {
+		return types.GitCredentials{}, ErrGitCredentialsNotFound
+	}
+
+	value := func(k string) string {
+		s, _ := data[k].(string)
+		return s
+	}
+
+	return types.GitCredentials{
+		HTTPSPassword: value("https_password"),
+		HTTPSUsername: value("https_username"),
+		SSHPrivateKey: value("ssh_private_key"),
+	}, nil
+}
+
+// DeleteGitCredentials deletes all versions of the git credentials at the path
+// of the reference.
+func (v VaultProvider) DeleteGitCredentials(ctx context.Context, ref string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to delete git credentials")
+	}
+
+	path, ok := v.layout.gitCredentialsMetadata(ref)
+	if !ok {
+		return fmt.Errorf("git credentials reference '%s' is not in the kv secrets engine", ref)
+	}
+
+	if _, err := v.delete(ctx, path); err != nil {
+		return fmt.Errorf("vault delete git credentials error: %w", err)
+	}
+	return nil
+}
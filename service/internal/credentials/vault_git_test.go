@@ -0,0 +1,53 @@
+package credentials
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultGitCredentials(t *testing.T) {
+	const ref = "secret/data/argo-cloudops-projects/project1/git"
+
+	mv := &memoryVault{data: map[string]map[string]interface{}{}}
+	admin := VaultProvider{
+		layout:          testVaultLayout,
+		roleID:          authorizationKeyAdmin,
+		vaultLogicalSvc: mv,
+	}
+	user := admin
+	user.roleID = TestRole
+
+	creds := types.GitCredentials{HTTPSUsername: "user", HTTPSPassword: "token"}
+
+	_, err := user.WriteGitCredentials(context.Background(), "project1", creds)
+	assert.EqualError(t, err, "admin credentials must be used to write git credentials")
+
+	got, err := admin.WriteGitCredentials(context.Background(), "project1", creds)
+	assert.NoError(t, err)
+	assert.Equal(t, ref, got)
+	assert.Equal(t, map[string]interface{}{
+		"data": map[string]interface{}{"https_password": "token", "https_username": "user"},
+	}, mv.data[ref])
+
+	// Credentials are read with the credentials of the service.
+	read, err := user.ReadGitCredentials(context.Background(), ref)
+	assert.NoError(t, err)
+	assert.Equal(t, creds, read)
+
+	_, err = user.ReadGitCredentials(context.Background(), "auth/approle/role/argo-cloudops-projects-project1")
+	assert.EqualError(t, err, "git credentials reference 'auth/approle/role/argo-cloudops-projects-project1' is not in the kv secrets engine")
+
+	_, err = user.ReadGitCredentials(context.Background(), "secret/data/argo-cloudops-projects/project2/git")
+	assert.ErrorIs(t, err, ErrGitCredentialsNotFound)
+
+	assert.EqualError(t, user.DeleteGitCredentials(context.Background(), ref), "admin credentials must be used to delete git credentials")
+
+	// All versions are deleted with the metadata.
+	mv.data["secret/metadata/argo-cloudops-projects/project1/git"] = map[string]interface{}{}
+	assert.NoError(t, admin.DeleteGitCredentials(context.Background(), ref))
+	assert.NotContains(t, mv.data, "secret/metadata/argo-cloudops-projects/project1/git")
+}
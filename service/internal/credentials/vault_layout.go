@@ -0,0 +1,111 @@
+package credentials
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cello-proj/cello/service/internal/env"
+)
+
+// VaultLegacyProjectPrefix is the project prefix used before it was
+// configurable.
+const VaultLegacyProjectPrefix = "argo-cloudops-projects"
+
+// VaultLayout is where the vault provider keeps the AppRoles, policies, AWS
+// roles and git credentials of projects. Instances sharing a Vault are
+// isolated by using different project prefixes or mounts.
+type VaultLayout struct {
+	AppRoleMount  string
+	AWSMount      string
+	KVMount       string
+	ProjectPrefix string
+}
+
+// NewVaultLayout returns the layout configured by the environment.
+func NewVaultLayout(env env.Vars) VaultLayout {
+	return VaultLayout{
+		AppRoleMount:  strings.Trim(env.VaultAppRoleMount, "/"),
+		AWSMount:      strings.Trim(env.VaultAWSMount, "/"),
+		KVMount:       strings.Trim(env.VaultKVMount, "/"),
+		ProjectPrefix: env.VaultProjectPrefix,
+	}
+}
+
+// WithProjectPrefix returns the layout using the project prefix.
+func (l VaultLayout) WithProjectPrefix(prefix string) VaultLayout {
+	l.ProjectPrefix = prefix
+	return l
+}
+
+func (l VaultLayout) appRoles() string {
+	return fmt.Sprintf("auth/%s/role", l.AppRoleMount)
+}
+
+func (l VaultLayout) appRoleName(project string) string {
+	return fmt.Sprintf("%s-%s", l.ProjectPrefix, project)
+}
+
+func (l VaultLayout) appRole(project string) string {
+	return fmt.Sprintf("%s/%s", l.appRoles(), l.appRoleName(project))
+}
+
+// project returns the project of the AppRole name, or false when the AppRole
+// isn't a project of the layout.
+func (l VaultLayout) project(appRoleName string) (string, bool) {
+	prefix := fmt.Sprintf("%s-", l.ProjectPrefix)
+	if !strings.HasPrefix(appRoleName, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(appRoleName, prefix), true
+}
+
+func (l VaultLayout) login() string {
+	return fmt.Sprintf("auth/%s/login", l.AppRoleMount)
+}
+
+func (l VaultLayout) policy(project string) string {
+	return fmt.Sprintf("%s-%s", l.ProjectPrefix, project)
+}
+
+func (l VaultLayout) awsRoles() string {
+	return fmt.Sprintf("%s/roles/", l.AWSMount)
+}
+
+func (l VaultLayout) awsRoleName(project, target string) string {
+	return fmt.Sprintf("%s%s", l.awsRolePrefix(project), target)
+}
+
+func (l VaultLayout) awsRolePrefix(project string) string {
+	return fmt.Sprintf("%s-%s-target-", l.ProjectPrefix, project)
+}
+
+func (l VaultLayout) awsRole(project, target string) string {
+	return fmt.Sprintf("%s%s", l.awsRoles(), l.awsRoleName(project, target))
+}
+
+// gitCredentials returns the path of the git credentials of the project in the
+// KV version 2 secrets engine.
+func (l VaultLayout) gitCredentials(project string) string {
+	return fmt.Sprintf("%s/data/%s/%s/git", l.KVMount, l.ProjectPrefix, project)
+}
+
+// gitCredentialsMetadata returns the metadata path of the git credentials at
+// the path, which deletes all their versions, or false when the path isn't in
+// the KV secrets engine.
+func (l VaultLayout) gitCredentialsMetadata(path string) (string, bool) {
+	prefix := fmt.Sprintf("%s/data/", l.KVMount)
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	return fmt.Sprintf("%s/metadata/%s", l.KVMount, strings.TrimPrefix(path, prefix)), true
+}
+
+// readonlyPolicy returns the policy allowing project tokens to read the
+// credentials of the project's targets.
+func (l VaultLayout) readonlyPolicy(project string) string {
+	return fmt.Sprintf(
+		"path \"%s/sts/%s*\" { capabilities = [\"read\"] }",
+		l.AWSMount,
+		l.awsRolePrefix(project),
+	)
+}
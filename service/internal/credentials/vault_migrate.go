@@ -0,0 +1,152 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cello-proj/cello/internal/responses"
+)
+
+// VaultMigrator moves projects from another project prefix to the one of the
+// provider.
+type VaultMigrator interface {
+	MigrateProjects(ctx context.Context, from string, dryRun bool) (responses.VaultMigration, error)
+}
+
+// deprecatedAppRoleFields are returned when reading an AppRole but conflict
+// with their replacements when written.
+var deprecatedAppRoleFields = []string{"bound_cidr_list", "period", "policies"}
+
+// MigrateProjects moves the AppRoles, policies and AWS roles of all projects
+// with the from prefix to the project prefix of the provider. Role IDs are
+// kept but secret IDs can't be moved, so the tokens of moved projects are
+// invalidated.
+func (v VaultProvider) MigrateProjects(ctx context.Context, from string, dryRun bool) (responses.VaultMigration, error) {
+	resp := responses.VaultMigration{
+		DryRun:   dryRun,
+		From:     from,
+		Projects: []responses.VaultMigrationProject{},
+		To:       v.layout.ProjectPrefix,
+	}
+
+	if !v.isAdmin() {
+		return resp, errors.New("admin credentials must be used to migrate projects")
+	}
+
+	if from == "" || from == v.layout.ProjectPrefix {
+		return resp, errors.New("from must be a project prefix other than the current one")
+	}
+
+	legacy := v.layout.WithProjectPrefix(from)
+
+	// A legacy provider lists what is to be moved.
+	lv := v
+	lv.layout = legacy
+
+	projects, err := lv.ListProjects(ctx)
+	if err != nil {
+		return resp, err
+	}
+	sort.Strings(projects)
+
+	for _, project := range projects {
+		// The new prefix may itself start with the legacy one.
+		if _, ok := v.layout.project(legacy.appRoleName(project)); ok {
+			continue
+		}
+
+		targets, err := lv.ListTargets(ctx, project)
+		if err != nil {
+			return resp, err
+		}
+		sort.Strings(targets)
+
+		tokens, err := lv.ListProjectTokens(ctx, project)
+		if err != nil {
+			return resp, err
+		}
+
+		if !dryRun {
+			if err := v.migrateProject(ctx, legacy, project, targets); err != nil {
+				return resp, fmt.Errorf("vault migrate project '%s' error: %w", project, err)
+			}
+		}
+
+		resp.Projects = append(resp.Projects, responses.VaultMigrationProject{
+			Name:    project,
+			Targets: targets,
+			Tokens:  len(tokens),
+		})
+	}
+
+	return resp, nil
+}
+
+func (v VaultProvider) migrateProject(ctx context.Context, legacy VaultLayout, project string, targets []string) error {
+	for _, target := range targets {
+		sec, err := v.read(ctx, legacy.awsRole(project, target))
+		if err != nil {
+			return err
+		}
+		if sec == nil {
+			continue
+		}
+
+		if _, err := v.write(ctx, v.layout.awsRole(project, target), sec.Data); err != nil {
+			return err
+		}
+	}
+
+	if err := v.createPolicyState(ctx, project, v.layout.readonlyPolicy(project)); err != nil {
+		return err
+	}
+
+	appRole, err := v.read(ctx, legacy.appRole(project))
+	if err != nil {
+		return err
+	}
+
+	roleID, err := v.read(ctx, fmt.Sprintf("%s/role-id", legacy.appRole(project)))
+	if err != nil {
+		return err
+	}
+
+	if appRole == nil || roleID == nil {
+		return ErrNotFound
+	}
+
+	options := map[string]interface{}{}
+	for k, val := range appRole.Data {
+		options[k] = val
+	}
+	for _, k := range deprecatedAppRoleFields {
+		delete(options, k)
+	}
+	options["token_policies"] = v.layout.policy(project)
+
+	if _, err := v.write(ctx, v.layout.appRole(project), options); err != nil {
+		return err
+	}
+
+	// Role IDs are unique so the legacy AppRole is deleted before its role
+	// ID is moved.
+	if _, err := v.delete(ctx, legacy.appRole(project)); err != nil {
+		return err
+	}
+
+	if _, err := v.write(ctx, fmt.Sprintf("%s/role-id", v.layout.appRole(project)), map[string]interface{}{
+		"role_id": roleID.Data["role_id"],
+	}); err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if _, err := v.delete(ctx, legacy.awsRole(project, target)); err != nil {
+			return err
+		}
+	}
+
+	return v.deletePolicy(ctx, legacy.policy(project))
+}
@@ -0,0 +1,162 @@
+package credentials
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cello-proj/cello/internal/responses"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryVault is a vaultLogical and vaultSys keeping everything in memory.
+type memoryVault struct {
+	vault.Sys
+	data     map[string]map[string]interface{}
+	policies map[string]string
+}
+
+func (m *memoryVault) ReadWithContext(ctx context.Context, path string) (*vault.Secret, error) {
+	data, ok := m.data[path]
+	if !ok {
+		return nil, nil
+	}
+	return &vault.Secret{Data: data}, nil
+}
+
+func (m *memoryVault) ListWithContext(ctx context.Context, path string) (*vault.Secret, error) {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	keys := map[string]bool{}
+	for p := range m.data {
+		if strings.HasPrefix(p, prefix) {
+			keys[strings.SplitN(strings.TrimPrefix(p, prefix), "/", 2)[0]] = true
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	list := []interface{}{}
+	for k := range keys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].(string) < list[j].(string) })
+	return &vault.Secret{Data: map[string]interface{}{"keys": list}}, nil
+}
+
+func (m *memoryVault) WriteWithContext(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	m.data[path] = data
+	return nil, nil
+}
+
+func (m *memoryVault) DeleteWithContext(ctx context.Context, path string) (*vault.Secret, error) {
+	for p := range m.data {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(m.data, p)
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryVault) PutPolicyWithContext(ctx context.Context, name, rules string) error {
+	m.policies[name] = rules
+	return nil
+}
+
+func (m *memoryVault) DeletePolicyWithContext(ctx context.Context, name string) error {
+	delete(m.policies, name)
+	return nil
+}
+
+func newLegacyMemoryVault() *memoryVault {
+	return &memoryVault{
+		data: map[string]map[string]interface{}{
+			"auth/approle/role/argo-cloudops-projects-project1": {
+				"policies":       []interface{}{"argo-cloudops-projects-project1"},
+				"token_num_uses": 3,
+				"token_policies": []interface{}{"argo-cloudops-projects-project1"},
+			},
+			"auth/approle/role/argo-cloudops-projects-project1/role-id":        {"role_id": "role1"},
+			"auth/approle/role/argo-cloudops-projects-project1/secret-id/abcd": {},
+			"auth/approle/role/other":                                          {},
+			"aws/roles/argo-cloudops-projects-project1-target-target1":         {"role_arns": []interface{}{"arn:aws:iam::123456789012:role/target1"}},
+			"aws/roles/other": {},
+		},
+		policies: map[string]string{
+			"argo-cloudops-projects-project1": `path "aws/sts/argo-cloudops-projects-project1-target-*" { capabilities = ["read"] }`,
+		},
+	}
+}
+
+func TestVaultMigrateProjects(t *testing.T) {
+	want := responses.VaultMigration{
+		From: VaultLegacyProjectPrefix,
+		Projects: []responses.VaultMigrationProject{
+			{Name: "project1", Targets: []string{"target1"}, Tokens: 1},
+		},
+		To: "cello-a",
+	}
+
+	// Dry runs don't change anything.
+	mv := newLegacyMemoryVault()
+	v := VaultProvider{
+		layout:          testVaultLayout.WithProjectPrefix("cello-a"),
+		roleID:          authorizationKeyAdmin,
+		vaultLogicalSvc: mv,
+		vaultSysSvc:     mv,
+	}
+
+	want.DryRun = true
+	got, err := v.MigrateProjects(context.Background(), VaultLegacyProjectPrefix, true)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, newLegacyMemoryVault(), mv)
+
+	want.DryRun = false
+	got, err = v.MigrateProjects(context.Background(), VaultLegacyProjectPrefix, false)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	assert.Equal(t, map[string]map[string]interface{}{
+		"auth/approle/role/cello-a-project1": {
+			"token_num_uses": 3,
+			"token_policies": "cello-a-project1",
+		},
+		"auth/approle/role/cello-a-project1/role-id": {"role_id": "role1"},
+		"auth/approle/role/other":                    {},
+		"aws/roles/cello-a-project1-target-target1":  {"role_arns": []interface{}{"arn:aws:iam::123456789012:role/target1"}},
+		"aws/roles/other":                            {},
+	}, mv.data)
+	assert.Equal(t, map[string]string{
+		"cello-a-project1": `path "aws/sts/cello-a-project1-target-*" { capabilities = ["read"] }`,
+	}, mv.policies)
+
+	projects, err := v.ListProjects(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"project1"}, projects)
+
+	// Nothing is left to migrate.
+	got, err = v.MigrateProjects(context.Background(), VaultLegacyProjectPrefix, false)
+	assert.NoError(t, err)
+	assert.Empty(t, got.Projects)
+}
+
+func TestVaultMigrateProjectsErrors(t *testing.T) {
+	mv := newLegacyMemoryVault()
+	v := VaultProvider{
+		layout:          testVaultLayout,
+		roleID:          authorizationKeyAdmin,
+		vaultLogicalSvc: mv,
+		vaultSysSvc:     mv,
+	}
+
+	_, err := v.MigrateProjects(context.Background(), VaultLegacyProjectPrefix, false)
+	assert.EqualError(t, err, "from must be a project prefix other than the current one")
+
+	v.roleID = TestRole
+	_, err = v.MigrateProjects(context.Background(), "other", false)
+	assert.EqualError(t, err, "admin credentials must be used to migrate projects")
+}
@@ -1,8 +1,12 @@
 package credentials
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/cello-proj/cello/internal/types"
 
@@ -12,6 +16,13 @@ import (
 
 const TestRole = "testRole"
 
+var testVaultLayout = VaultLayout{
+	AppRoleMount:  "approle",
+	AWSMount:      "aws",
+	KVMount:       "secret",
+	ProjectPrefix: VaultLegacyProjectPrefix,
+}
+
 var errTest = fmt.Errorf("error")
 
 func TestVaultCreateProject(t *testing.T) {
@@ -51,6 +62,7 @@ func TestVaultCreateProject(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout: testVaultLayout,
 				roleID: role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, data: map[string]interface{}{
 					"secret_id":          tt.expectedSecret,
@@ -62,7 +74,7 @@ func TestVaultCreateProject(t *testing.T) {
 				vaultSysSvc: &mockVaultSys{},
 			}
 
-			token, err := v.CreateProject("testProject")
+			token, err := v.CreateProject(context.Background(), "testProject")
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -113,11 +125,12 @@ func TestVaultCreateTarget(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout:          testVaultLayout,
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr},
 			}
 
-			err := v.CreateTarget("test", types.Target{})
+			err := v.CreateTarget(context.Background(), "test", types.Target{})
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -162,11 +175,12 @@ func TestVaultUpdateTarget(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout:          testVaultLayout,
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr},
 			}
 
-			err := v.UpdateTarget("test", types.Target{})
+			err := v.UpdateTarget(context.Background(), "test", types.Target{})
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -218,12 +232,13 @@ func TestVaultDeleteProject(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout:          testVaultLayout,
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr},
 				vaultSysSvc:     &mockVaultSys{err: tt.vaultPolicyErr},
 			}
 
-			err := v.DeleteProject("testProject")
+			err := v.DeleteProject(context.Background(), "testProject")
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -268,11 +283,12 @@ func TestVaultDeleteTarget(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout:          testVaultLayout,
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr},
 			}
 
-			err := v.DeleteTarget("testProject", "testTarget")
+			err := v.DeleteTarget(context.Background(), "testProject", "testTarget")
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -336,11 +352,12 @@ func TestVaultGetProjectToken(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout:          testVaultLayout,
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, data: tt.mockVaultData},
 			}
 
-			projectToken, err := v.GetProjectToken("projectName", "tokenID")
+			projectToken, err := v.GetProjectToken(context.Background(), "projectName", "tokenID")
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -358,6 +375,79 @@ func TestVaultGetProjectToken(t *testing.T) {
 	}
 }
 
+func TestVaultLookupProjectToken(t *testing.T) {
+	tests := []struct {
+		name            string
+		admin           bool
+		expectedTokenID string
+		mockVaultData   map[string]interface{}
+		vaultErr        error
+		wantErr         error
+	}{
+		{
+			name:            "lookup project token success",
+			expectedTokenID: "secret-id-accessor",
+			mockVaultData: map[string]interface{}{
+				"role_id":            TestRole,
+				"secret_id_accessor": "secret-id-accessor",
+			},
+		},
+		{
+			name: "token role does not match project",
+			mockVaultData: map[string]interface{}{
+				"role_id":            "other-role",
+				"secret_id_accessor": "secret-id-accessor",
+			},
+			wantErr: ErrProjectTokenNotFound,
+		},
+		{
+			name: "secret id does not exist",
+			mockVaultData: map[string]interface{}{
+				"role_id": TestRole,
+			},
+			wantErr: ErrProjectTokenNotFound,
+		},
+		{
+			name:     "lookup project token error",
+			vaultErr: errTest,
+			wantErr:  errTest,
+		},
+		{
+			name:    "lookup project token admin",
+			admin:   true,
+			wantErr: errors.New("admin credentials cannot be used to lookup project tokens"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := TestRole
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			v := VaultProvider{
+				layout:          testVaultLayout,
+				roleID:          role,
+				secretID:        "secret",
+				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, data: tt.mockVaultData},
+			}
+
+			projectToken, err := v.LookupProjectToken(context.Background(), "projectName")
+			if tt.wantErr != nil {
+				if err == nil || (!errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error()) {
+					t.Errorf("\nwant error: %v\n got: %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("\ndid not expect error, got: %v", err)
+			}
+
+			if !cmp.Equal(projectToken.ID, tt.expectedTokenID) {
+				t.Errorf("\nwant: %v\n got: %v", tt.expectedTokenID, projectToken.ID)
+			}
+		})
+	}
+}
+
 func TestVaultGetTarget(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -389,6 +479,7 @@ func TestVaultGetTarget(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout: testVaultLayout,
 				roleID: role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, data: map[string]interface{}{
 					"role_arns":       []interface{}{"test-role-arn"},
@@ -398,7 +489,7 @@ func TestVaultGetTarget(t *testing.T) {
 				}},
 			}
 
-			_, err := v.GetTarget("testProject", "testTarget")
+			_, err := v.GetTarget(context.Background(), "testProject", "testTarget")
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -412,6 +503,40 @@ func TestVaultGetTarget(t *testing.T) {
 	}
 }
 
+func TestVaultGetTargetSessionSettings(t *testing.T) {
+	v := VaultProvider{
+		layout: testVaultLayout,
+		roleID: authorizationKeyAdmin,
+		vaultLogicalSvc: &mockVaultLogical{data: map[string]interface{}{
+			"role_arns":       []interface{}{"test-role-arn"},
+			"credential_type": "assumed_role",
+			"default_sts_ttl": json.Number("3600"),
+			"max_sts_ttl":     json.Number("7200"),
+			"external_id":     "test-external-id",
+			"session_tags":    map[string]interface{}{"cello-project": "testProject"},
+		}},
+	}
+
+	want := types.TargetProperties{
+		CredentialType: "assumed_role",
+		DefaultSTSTTL:  3600,
+		ExternalID:     "test-external-id",
+		MaxSTSTTL:      7200,
+		PolicyArns:     []string{},
+		RoleArn:        "test-role-arn",
+		SessionTags:    map[string]string{"cello-project": "testProject"},
+	}
+
+	target, err := v.GetTarget(context.Background(), "testProject", "testTarget")
+	if err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+
+	if !cmp.Equal(target.Properties, want) {
+		t.Errorf("\nwant: %v\n got: %v", want, target.Properties)
+	}
+}
+
 func TestVaultGetToken(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -443,11 +568,12 @@ func TestVaultGetToken(t *testing.T) {
 				role = authorizationKeyAdmin
 			}
 			v := VaultProvider{
+				layout:          testVaultLayout,
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, token: tt.token},
 			}
 
-			token, err := v.GetToken()
+			token, err := v.GetToken(context.Background(), "", "")
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -464,6 +590,127 @@ func TestVaultGetToken(t *testing.T) {
 	}
 }
 
+func TestVaultListProjects(t *testing.T) {
+	tests := []struct {
+		name      string
+		admin     bool
+		roles     []interface{}
+		want      []string
+		vaultErr  error
+		errResult bool
+	}{
+		{
+			name:  "list projects success",
+			admin: true,
+			roles: []interface{}{"argo-cloudops-projects-project1", "argo-cloudops", "argo-cloudops-projects-project2"},
+			want:  []string{"project1", "project2"},
+		},
+		{
+			name:  "no projects",
+			admin: true,
+			want:  []string{},
+		},
+		{
+			name:      "list projects admin error",
+			errResult: true,
+		},
+		{
+			name:      "list projects error",
+			admin:     true,
+			vaultErr:  errTest,
+			errResult: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := TestRole
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			v := VaultProvider{
+				layout: testVaultLayout,
+				roleID: role,
+				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, data: map[string]interface{}{
+					"keys": tt.roles,
+				}},
+			}
+
+			projects, err := v.ListProjects(context.Background())
+			if err != nil {
+				if !tt.errResult {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+			} else {
+				if tt.errResult {
+					t.Errorf("\nexpected error")
+				}
+				if !cmp.Equal(projects, tt.want) {
+					t.Errorf("\nwant: %v\n got: %v", tt.want, projects)
+				}
+			}
+		})
+	}
+}
+
+func TestVaultListProjectTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		admin     bool
+		accessors []interface{}
+		want      []types.ProjectToken
+		vaultErr  error
+		errResult bool
+	}{
+		{
+			name:      "list project tokens success",
+			admin:     true,
+			accessors: []interface{}{"abc123", "def456"},
+			want:      []types.ProjectToken{{ID: "abc123"}, {ID: "def456"}},
+		},
+		{
+			name:      "list project tokens admin error",
+			errResult: true,
+		},
+		{
+			name:      "list project tokens error",
+			admin:     true,
+			vaultErr:  errTest,
+			errResult: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := TestRole
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			v := VaultProvider{
+				layout: testVaultLayout,
+				roleID: role,
+				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, data: map[string]interface{}{
+					"keys": tt.accessors,
+				}},
+			}
+
+			tokens, err := v.ListProjectTokens(context.Background(), "project1")
+			if err != nil {
+				if !tt.errResult {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+			} else {
+				if tt.errResult {
+					t.Errorf("\nexpected error")
+				}
+				if !cmp.Equal(tokens, tt.want) {
+					t.Errorf("\nwant: %v\n got: %v", tt.want, tokens)
+				}
+			}
+		})
+	}
+}
+
 func TestVaultListTargets(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -502,13 +749,14 @@ func TestVaultListTargets(t *testing.T) {
 				testTargets = append(testTargets, fmt.Sprintf("argo-cloudops-projects-test-target-%s", i))
 			}
 			v := VaultProvider{
+				layout: testVaultLayout,
 				roleID: role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, data: map[string]interface{}{
 					"keys": testTargets,
 				}},
 			}
 
-			targets, err := v.ListTargets("test")
+			targets, err := v.ListTargets(context.Background(), "test")
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -557,10 +805,11 @@ func TestVaultProjectExists(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			v := VaultProvider{
+				layout:          testVaultLayout,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr},
 			}
 
-			status, err := v.ProjectExists(tt.path)
+			status, err := v.ProjectExists(context.Background(), tt.path)
 			if err != nil {
 				if !tt.expectErr {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -578,6 +827,68 @@ func TestVaultProjectExists(t *testing.T) {
 	}
 }
 
+func TestVaultTargetExists(t *testing.T) {
+	tests := []struct {
+		name         string
+		vaultLogical vaultLogical
+		exists       bool
+		expectErr    bool
+	}{
+		{
+			name: "target exists",
+			vaultLogical: &mockVaultLogical{data: map[string]interface{}{
+				"role_arns":       []interface{}{"test-role-arn"},
+				"credential_type": "test-cred-type",
+			}},
+			exists: true,
+		},
+		{
+			name:         "target doesn't exist",
+			vaultLogical: &mockVaultLogical{err: ErrTargetNotFound},
+			exists:       false,
+		},
+		{
+			name:         "vault error",
+			vaultLogical: &mockVaultLogical{err: errTest},
+			expectErr:    true,
+		},
+		{
+			name:         "vault timeout",
+			vaultLogical: &timeoutVaultLogical{},
+			expectErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := VaultProvider{
+				call:            vaultCallOptions{timeout: time.Millisecond},
+				layout:          testVaultLayout,
+				roleID:          authorizationKeyAdmin,
+				vaultLogicalSvc: tt.vaultLogical,
+			}
+
+			exists, err := v.TargetExists(context.Background(), "testProject", "testTarget")
+			if err != nil {
+				if !tt.expectErr {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+				if !cmp.Equal(exists, false) {
+					t.Errorf("\nwant: false\n got: %v", exists)
+				}
+			} else {
+				if tt.expectErr {
+					t.Errorf("\nexpected error")
+				}
+
+				if !cmp.Equal(exists, tt.exists) {
+					t.Errorf("\nwant: %v\n got: %v", tt.exists, exists)
+				}
+			}
+		})
+	}
+}
+
 func TestValidateAuthorizedAdmin(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -669,43 +980,54 @@ type mockVaultLogical struct {
 	err   error
 }
 
-func (m mockVaultLogical) Read(path string) (*vault.Secret, error) {
+func (m mockVaultLogical) ReadWithContext(ctx context.Context, path string) (*vault.Secret, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return &vault.Secret{Data: m.data}, nil
 }
 
-func (m mockVaultLogical) List(path string) (*vault.Secret, error) {
+func (m mockVaultLogical) ListWithContext(ctx context.Context, path string) (*vault.Secret, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return &vault.Secret{Data: m.data}, nil
 }
 
-func (m mockVaultLogical) Write(path string, data map[string]interface{}) (*vault.Secret, error) {
+func (m mockVaultLogical) WriteWithContext(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return &vault.Secret{Data: m.data, Auth: &vault.SecretAuth{ClientToken: m.token}}, nil
 }
 
-func (m mockVaultLogical) Delete(path string) (*vault.Secret, error) {
+func (m mockVaultLogical) DeleteWithContext(ctx context.Context, path string) (*vault.Secret, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return &vault.Secret{}, nil
 }
 
+// timeoutVaultLogical is a Vault which doesn't respond before the context is
+// done.
+type timeoutVaultLogical struct {
+	vault.Logical
+}
+
+func (m timeoutVaultLogical) ReadWithContext(ctx context.Context, path string) (*vault.Secret, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
 type mockVaultSys struct {
 	vault.Sys
 	err error
 }
 
-func (m mockVaultSys) PutPolicy(name, rules string) error {
+func (m mockVaultSys) PutPolicyWithContext(ctx context.Context, name, rules string) error {
 	return m.err
 }
 
-func (m mockVaultSys) DeletePolicy(name string) error {
+func (m mockVaultSys) DeletePolicyWithContext(ctx context.Context, name string) error {
 	return m.err
 }
@@ -4,6 +4,14 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/cello-proj/cello/internal/types"
 
@@ -12,20 +20,351 @@ import (
 )
 
 type ProjectEntry struct {
-	ProjectID  string `db:"project"`
-	Repository string `db:"repository"`
+	Contact string `db:"contact"`
+	// CreatedAt is set by CreateProjectEntry.
+	CreatedAt   string `db:"created_at,omitempty"`
+	Description string `db:"description"`
+	// GitCredentials references the git credentials of the project in the
+	// credentials provider. It's only updated with
+	// UpdateProjectGitCredentials.
+	GitCredentials       string             `db:"git_credentials,omitempty"`
+	Labels               ProjectLabels      `db:"labels"`
+	ManifestRoot         string             `db:"manifest_root"`
+	ProjectID            string             `db:"project"`
+	Repository           string             `db:"repository"`
+	RequireSignedCommits SignedCommitPolicy `db:"require_signed_commits"`
+	Team                 string             `db:"team"`
+}
+
+// Metadata returns the metadata of the project.
+func (p ProjectEntry) Metadata() types.ProjectMetadata {
+	return types.ProjectMetadata{
+		Contact:              p.Contact,
+		Description:          p.Description,
+		Labels:               p.Labels,
+		ManifestRoot:         p.ManifestRoot,
+		RequireSignedCommits: types.SignedCommitPolicy(p.RequireSignedCommits),
+		Team:                 p.Team,
+	}
+}
+
+// ProjectFilter filters and paginates project entries. A Limit of 0 returns
+// all entries.
+type ProjectFilter struct {
+	Limit  uint
+	Name   string
+	Offset uint
+}
+
+// ProjectLabels stores project labels as JSON.
+type ProjectLabels map[string]string
+
+// Value implements driver.Valuer.
+func (l ProjectLabels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *ProjectLabels) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan project labels from %T", src)
+	}
+
+	return json.Unmarshal(b, l)
 }
 
 type TokenEntry struct {
 	CreatedAt string `db:"created_at"`
 	ExpiresAt string `db:"expires_at"`
 	ProjectID string `db:"project"`
-	TokenID   string `db:"token_id"`
+	// Rotated is set by RotateTokenEntry on tokens kept for a grace period.
+	Rotated bool        `db:"rotated"`
+	Scopes  TokenScopes `db:"scopes"`
+	TokenID string      `db:"token_id"`
 }
 
 // IsEmpty returns whether a struct is empty.
 func (t TokenEntry) IsEmpty() bool {
-	return t == (TokenEntry{})
+	return reflect.DeepEqual(t, TokenEntry{})
+}
+
+// IsExpired returns whether the token expired before now. Tokens with an
+// expiry which cannot be parsed are not considered expired.
+func (t TokenEntry) IsExpired(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339Nano, t.ExpiresAt)
+	if err != nil {
+		return false
+	}
+
+	return expiresAt.Before(now)
+}
+
+// TokenScopes stores types.TokenScopes as JSON.
+type TokenScopes types.TokenScopes
+
+// Value implements driver.Valuer.
+func (s TokenScopes) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (s *TokenScopes) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*s = TokenScopes{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan token scopes from %T", src)
+	}
+
+	return json.Unmarshal(b, s)
+}
+
+// SignedCommitPolicy stores types.SignedCommitPolicy as JSON.
+type SignedCommitPolicy types.SignedCommitPolicy
+
+// Value implements driver.Valuer.
+func (p SignedCommitPolicy) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner. Empty policies are scanned as nil.
+func (p *SignedCommitPolicy) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan signed commit policy from %T", src)
+	}
+
+	if err := json.Unmarshal(b, p); err != nil {
+		return err
+	}
+	if len(*p) == 0 {
+		*p = nil
+	}
+	return nil
+}
+
+// TargetEntry holds the inventory of targets. The credentials provider only
+// holds the credentials of targets. Entries created before the inventory was
+// kept have no type until they're imported from the credentials provider.
+type TargetEntry struct {
+	ProjectID            string             `db:"project"`
+	TargetName           string             `db:"target"`
+	Region               string             `db:"region"`
+	CreatedAt            string             `db:"created_at"`
+	Description          string             `db:"description"`
+	Owner                string             `db:"owner"`
+	Properties           TargetProperties   `db:"properties"`
+	RequireSignedCommits SignedCommitPolicy `db:"require_signed_commits"`
+	Tier                 string             `db:"tier"`
+	Type                 string             `db:"type"`
+	UpdatedAt            string             `db:"updated_at"`
+}
+
+// NewTargetEntry returns the entry of the target of the project.
+func NewTargetEntry(project string, target types.Target) TargetEntry {
+	return TargetEntry{
+		ProjectID:            project,
+		TargetName:           target.Name,
+		Region:               target.Properties.Region,
+		Description:          target.Description,
+		Owner:                target.Owner,
+		Properties:           TargetProperties(target.Properties),
+		RequireSignedCommits: SignedCommitPolicy(target.RequireSignedCommits),
+		Tier:                 target.Tier,
+		Type:                 target.Type,
+	}
+}
+
+// IsImported returns whether the entry holds the target, rather than only
+// its region.
+func (t TargetEntry) IsImported() bool {
+	return t.Type != ""
+}
+
+// Target returns the target of the entry.
+func (t TargetEntry) Target() types.Target {
+	properties := types.TargetProperties(t.Properties)
+	properties.Region = t.Region
+
+	return types.Target{
+		TargetMetadata: types.TargetMetadata{
+			Description:          t.Description,
+			Owner:                t.Owner,
+			RequireSignedCommits: types.SignedCommitPolicy(t.RequireSignedCommits),
+			Tier:                 t.Tier,
+		},
+		Name:       t.TargetName,
+		Properties: properties,
+		Type:       t.Type,
+	}
+}
+
+// TargetFilter filters target entries. Empty fields match all entries.
+type TargetFilter struct {
+	Tier string
+	Type string
+}
+
+// TargetProperties stores types.TargetProperties as JSON.
+type TargetProperties types.TargetProperties
+
+// Value implements driver.Valuer.
+func (p TargetProperties) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (p *TargetProperties) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*p = TargetProperties{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan target properties from %T", src)
+	}
+
+	return json.Unmarshal(b, p)
+}
+
+// ReconciliationEntry holds the lease and latest report of a reconciler so
+// only one replica reconciles at a time.
+type ReconciliationEntry struct {
+	Name           string `db:"name"`
+	Holder         string `db:"holder"`
+	LeaseExpiresAt string `db:"lease_expires_at"`
+	Report         string `db:"report"`
+}
+
+// Batch statuses. Workflows of batches have the statuses of their workflows
+// once submitted.
+const (
+	BatchStatusFailed      = "failed"
+	BatchStatusInterrupted = "interrupted"
+	BatchStatusPending     = "pending"
+	BatchStatusRunning     = "running"
+	BatchStatusSkipped     = "skipped"
+	BatchStatusSucceeded   = "succeeded"
+)
+
+// BatchEntry holds an operation fanned out to several targets, run in waves.
+type BatchEntry struct {
+	BatchID       string         `db:"batch_id"`
+	ProjectID     string         `db:"project"`
+	CommitHash    string         `db:"sha"`
+	Type          string         `db:"type"`
+	Status        string         `db:"status"`
+	Parallelism   int            `db:"parallelism"`
+	StopOnFailure bool           `db:"stop_on_failure"`
+	Workflows     BatchWorkflows `db:"workflows"`
+	CreatedAt     string         `db:"created_at"`
+	UpdatedAt     string         `db:"updated_at"`
+}
+
+// Interrupt marks the batch, and its pending and running workflows, as
+// interrupted. Submitted workflows keep running in Argo.
+func (be *BatchEntry) Interrupt() {
+	be.Status = BatchStatusInterrupted
+	for i, bw := range be.Workflows {
+		if bw.Status == BatchStatusPending || bw.Status == BatchStatusRunning {
+			be.Workflows[i].Status = BatchStatusInterrupted
+		}
+	}
+}
+
+// BatchWorkflow is the workflow of a target of a batch. WorkflowName is empty
+// until the workflow is submitted, and Error holds why it couldn't be.
+type BatchWorkflow struct {
+	Error        string `json:"error,omitempty"`
+	Status       string `json:"status"`
+	Target       string `json:"target"`
+	Wave         int    `json:"wave"`
+	WorkflowName string `json:"workflow_name,omitempty"`
+}
+
+// BatchWorkflows stores the workflows of a batch as JSON.
+type BatchWorkflows []BatchWorkflow
+
+// Value implements driver.Valuer.
+func (w BatchWorkflows) Value() (driver.Value, error) {
+	if w == nil {
+		return "[]", nil
+	}
+
+	b, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (w *BatchWorkflows) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*w = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan batch workflows from %T", src)
+	}
+
+	return json.Unmarshal(b, w)
 }
 
 // Client allows for db crud operations
@@ -33,65 +372,220 @@ type Client interface {
 	CreateProjectEntry(ctx context.Context, pe ProjectEntry) error
 	DeleteProjectEntry(ctx context.Context, project string) error
 	ReadProjectEntry(ctx context.Context, project string) (ProjectEntry, error)
+	UpdateProjectEntry(ctx context.Context, pe ProjectEntry) error
+	UpdateProjectGitCredentials(ctx context.Context, project, ref string) error
+	ListProjectEntries(ctx context.Context, filter ProjectFilter) ([]ProjectEntry, uint64, error)
 	CreateTokenEntry(ctx context.Context, token types.Token) error
 	DeleteTokenEntry(ctx context.Context, token string) error
 	ReadTokenEntry(ctx context.Context, token string) (TokenEntry, error)
 	ListTokenEntries(ctx context.Context, project string) ([]TokenEntry, error)
+	ListRotatedTokenEntries(ctx context.Context, expiresAfter time.Time) ([]TokenEntry, error)
+	RotateTokenEntry(ctx context.Context, oldToken string, token types.Token, oldExpiresAt string, revoke func() error) error
+	UpsertTargetEntry(ctx context.Context, te TargetEntry, write func() error) error
+	DeleteTargetEntry(ctx context.Context, project, target string, remove func() error) error
+	ReadTargetEntry(ctx context.Context, project, target string) (TargetEntry, error)
+	ListTargetEntries(ctx context.Context, project string, filter TargetFilter) ([]TargetEntry, error)
+	AcquireReconciliationLease(ctx context.Context, name, holder string, now, expiresAt time.Time) (bool, error)
+	UpdateReconciliationReport(ctx context.Context, name, holder, report string) error
+	ReadReconciliationEntry(ctx context.Context, name string) (ReconciliationEntry, error)
+	CreateBatchEntry(ctx context.Context, be BatchEntry) error
+	ReadBatchEntry(ctx context.Context, project, batchID string) (BatchEntry, error)
+	UpdateBatchEntry(ctx context.Context, be BatchEntry) error
+	InterruptBatchEntries(ctx context.Context, updatedBefore time.Time) ([]string, error)
 	Health(ctx context.Context) error
+	Stats() sql.DBStats
 }
 
-// SQLClient allows for db crud operations using postgres db
+// SQLClient allows for db crud operations using a postgres or sqlite db. Clients
+// share one pooled session for the lifetime of the process.
 type SQLClient struct {
-	host     string
-	database string
-	user     string
-	password string
-	options  map[string]string
+	pool *sqlPool
+}
+
+// PoolConfig configures the connection pool of a SQLClient. Zero values use
+// the defaults of database/sql.
+type PoolConfig struct {
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+	MaxIdleConns    int
+	MaxOpenConns    int
 }
 
+// defaultMaxIdleConns is the default of database/sql, which can't be restored
+// by setting 0.
+const defaultMaxIdleConns = 2
+
+func (c PoolConfig) maxIdleConns() int {
+	if c.MaxIdleConns > 0 {
+		return c.MaxIdleConns
+	}
+	return defaultMaxIdleConns
+}
+
+// sqlPool opens the session on first use so the service can start, and
+// recover, while the database is unavailable.
+type sqlPool struct {
+	config PoolConfig
+	driver string
+	open   func() (db.Session, error)
+
+	mu   sync.Mutex
+	sess db.Session
+}
+
+// Drivers of the SQLClient.
 const (
-	ProjectEntryDB = "projects"
-	TokenEntryDB   = "tokens"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
-func NewSQLClient(host, database, user, password string, options map[string]string) (SQLClient, error) {
+const (
+	BatchEntryDB          = "batches"
+	ProjectEntryDB        = "projects"
+	ReconciliationEntryDB = "reconciliations"
+	TargetEntryDB         = "targets"
+	TokenEntryDB          = "tokens"
+)
+
+func NewSQLClient(host, database, user, password string, options map[string]string, config PoolConfig) (SQLClient, error) {
 	return SQLClient{
-		host:     host,
-		database: database,
-		user:     user,
-		password: password,
-		options:  options,
+		pool: &sqlPool{
+			config: config,
+			driver: DriverPostgres,
+			open: func() (db.Session, error) {
+				return postgresql.Open(postgresql.ConnectionURL{
+					Host:     host,
+					Database: database,
+					User:     user,
+					Password: password,
+					Options:  options,
+				})
+			},
+		},
 	}, nil
 }
 
-func (d SQLClient) createSession() (db.Session, error) {
-	settings := postgresql.ConnectionURL{
-		Host:     d.host,
-		Database: d.database,
-		User:     d.user,
-		Password: d.password,
-		Options:  d.options,
+// session returns the pooled session, opening it when needed. Connections
+// broken by a failover are discarded by the pool and replaced on next use.
+func (d SQLClient) session() (db.Session, error) {
+	d.pool.mu.Lock()
+	defer d.pool.mu.Unlock()
+
+	if d.pool.sess != nil {
+		return d.pool.sess, nil
 	}
 
-	return postgresql.Open(settings)
+	sess, err := d.pool.open()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pool.config.ConnMaxIdleTime > 0 {
+		sess.SetConnMaxIdleTime(d.pool.config.ConnMaxIdleTime)
+	}
+	if d.pool.config.ConnMaxLifetime > 0 {
+		sess.SetConnMaxLifetime(d.pool.config.ConnMaxLifetime)
+	}
+	sess.SetMaxIdleConns(d.pool.config.maxIdleConns())
+	if d.pool.config.MaxOpenConns > 0 {
+		sess.SetMaxOpenConns(d.pool.config.MaxOpenConns)
+	}
+
+	d.pool.sess = sess
+	return sess, nil
+}
+
+// Session returns the pooled session, so stores using the same database share
+// its connections. The session must not be closed.
+func (d SQLClient) Session() (db.Session, error) {
+	return d.session()
 }
 
+// sqlDB returns the database of the pooled session.
+func (d SQLClient) sqlDB() (*sql.DB, error) {
+	sess, err := d.session()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, ok := sess.Driver().(*sql.DB)
+	if !ok {
+		return nil, fmt.Errorf("unexpected session driver %T", sess.Driver())
+	}
+	return sqlDB, nil
+}
+
+// resetIdle closes the idle connections of the pool so new connections are
+// made to the current primary, e.g. after a failover.
+func (d SQLClient) resetIdle() {
+	d.pool.mu.Lock()
+	defer d.pool.mu.Unlock()
+
+	if d.pool.sess == nil {
+		return
+	}
+
+	if sqlDB, ok := d.pool.sess.Driver().(*sql.DB); ok {
+		sqlDB.SetMaxIdleConns(0)
+		sqlDB.SetMaxIdleConns(d.pool.config.maxIdleConns())
+	}
+}
+
+// Close closes the pooled session.
+func (d SQLClient) Close() error {
+	d.pool.mu.Lock()
+	defer d.pool.mu.Unlock()
+
+	if d.pool.sess == nil {
+		return nil
+	}
+
+	err := d.pool.sess.Close()
+	d.pool.sess = nil
+	return err
+}
+
+// Stats returns the statistics of the connection pool. They are empty until
+// the pool is first used.
+func (d SQLClient) Stats() sql.DBStats {
+	d.pool.mu.Lock()
+	defer d.pool.mu.Unlock()
+
+	if d.pool.sess == nil {
+		return sql.DBStats{}
+	}
+
+	if sqlDB, ok := d.pool.sess.Driver().(*sql.DB); ok {
+		return sqlDB.Stats()
+	}
+	return sql.DBStats{}
+}
+
+// Health pings the database. Idle connections are closed when it fails so
+// requests don't keep using connections to a failed database.
 func (d SQLClient) Health(ctx context.Context) error {
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return err
 	}
-	defer sess.Close()
 
-	return sess.WithContext(ctx).Ping()
+	if err := sess.WithContext(ctx).Ping(); err != nil {
+		d.resetIdle()
+		return err
+	}
+	return nil
 }
 
+// CreateProjectEntry replaces any existing entry of the project, e.g. when the
+// project was deleted from the credentials provider only. Use
+// UpdateProjectEntry to update an existing project.
 func (d SQLClient) CreateProjectEntry(ctx context.Context, pe ProjectEntry) error {
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return err
 	}
-	defer sess.Close()
+
+	pe.CreatedAt = time.Now().UTC().Format(time.RFC3339)
 
 	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
 		if err := sess.Collection(ProjectEntryDB).Find("project", pe.ProjectID).Delete(); err != nil {
@@ -109,38 +603,58 @@ func (d SQLClient) CreateProjectEntry(ctx context.Context, pe ProjectEntry) erro
 func (d SQLClient) ReadProjectEntry(ctx context.Context, project string) (ProjectEntry, error) {
 	res := ProjectEntry{}
 
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return res, err
 	}
-	defer sess.Close()
 
 	err = sess.WithContext(ctx).Collection(ProjectEntryDB).Find("project", project).One(&res)
 	return res, err
 }
 
+// UpdateProjectEntry updates the entry of the project in place so entries
+// referencing the project are kept.
+func (d SQLClient) UpdateProjectEntry(ctx context.Context, pe ProjectEntry) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Collection(ProjectEntryDB).Find("project", pe.ProjectID).Update(pe)
+}
+
+// UpdateProjectGitCredentials updates the reference of the git credentials of
+// the project. An empty reference removes it.
+func (d SQLClient) UpdateProjectGitCredentials(ctx context.Context, project, ref string) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Collection(ProjectEntryDB).Find("project", project).Update(map[string]interface{}{"git_credentials": ref})
+}
+
 func (d SQLClient) DeleteProjectEntry(ctx context.Context, project string) error {
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return err
 	}
-	defer sess.Close()
 
 	return sess.WithContext(ctx).Collection(ProjectEntryDB).Find("project", project).Delete()
 }
 
 func (d SQLClient) CreateTokenEntry(ctx context.Context, token types.Token) error {
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return err
 	}
-	defer sess.Close()
 
 	err = sess.WithContext(ctx).Tx(func(sess db.Session) error {
 		res := TokenEntry{
 			CreatedAt: token.CreatedAt,
 			ExpiresAt: token.ExpiresAt,
 			ProjectID: token.ProjectID,
+			Scopes:    TokenScopes(token.Scopes),
 			TokenID:   token.ProjectToken.ID,
 		}
 
@@ -152,23 +666,62 @@ func (d SQLClient) CreateTokenEntry(ctx context.Context, token types.Token) erro
 	return err
 }
 
+// RotateTokenEntry replaces the old token entry with the token. The old entry
+// is deleted when oldExpiresAt is empty, otherwise it expires at oldExpiresAt.
+// revoke, when not nil, is called before committing so the entries are left
+// unchanged when revoking the old token fails.
+func (d SQLClient) RotateTokenEntry(ctx context.Context, oldToken string, token types.Token, oldExpiresAt string, revoke func() error) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		res := TokenEntry{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+			ProjectID: token.ProjectID,
+			Scopes:    TokenScopes(token.Scopes),
+			TokenID:   token.ProjectToken.ID,
+		}
+
+		if _, err := sess.Collection(TokenEntryDB).Insert(res); err != nil {
+			return err
+		}
+
+		old := sess.Collection(TokenEntryDB).Find("token_id", oldToken)
+		if oldExpiresAt == "" {
+			if err := old.Delete(); err != nil {
+				return err
+			}
+		} else {
+			if err := old.Update(map[string]interface{}{"expires_at": oldExpiresAt, "rotated": true}); err != nil {
+				return err
+			}
+		}
+
+		if revoke != nil {
+			return revoke()
+		}
+		return nil
+	})
+}
+
 func (d SQLClient) DeleteTokenEntry(ctx context.Context, token string) error {
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return err
 	}
-	defer sess.Close()
 
 	return sess.WithContext(ctx).Collection(TokenEntryDB).Find("token_id", token).Delete()
 }
 
 func (d SQLClient) ReadTokenEntry(ctx context.Context, token string) (TokenEntry, error) {
 	res := TokenEntry{}
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return res, err
 	}
-	defer sess.Close()
 
 	err = sess.WithContext(ctx).Collection(TokenEntryDB).Find("token_id", token).One(&res)
 	return res, err
@@ -177,12 +730,284 @@ func (d SQLClient) ReadTokenEntry(ctx context.Context, token string) (TokenEntry
 func (d SQLClient) ListTokenEntries(ctx context.Context, project string) ([]TokenEntry, error) {
 	res := []TokenEntry{}
 
-	sess, err := d.createSession()
+	sess, err := d.session()
 	if err != nil {
 		return res, err
 	}
-	defer sess.Close()
 
 	err = sess.WithContext(ctx).Collection(TokenEntryDB).Find("project", project).OrderBy("-created_at").All(&res)
 	return res, err
 }
+
+// ListRotatedTokenEntries lists the entries of the rotated tokens of all
+// projects which expire after expiresAfter, i.e. whose grace period hasn't
+// ended. Expiries are compared as UTC timestamps.
+func (d SQLClient) ListRotatedTokenEntries(ctx context.Context, expiresAfter time.Time) ([]TokenEntry, error) {
+	res := []TokenEntry{}
+
+	sess, err := d.session()
+	if err != nil {
+		return res, err
+	}
+
+	cond := db.Cond{"rotated": true, "expires_at >": expiresAfter.UTC().Format(time.RFC3339Nano)}
+	err = sess.WithContext(ctx).Collection(TokenEntryDB).Find(cond).OrderBy("expires_at").All(&res)
+	return res, err
+}
+
+// UpsertTargetEntry creates or replaces the entry of the target, keeping the
+// creation time of replaced entries. write, when not nil, is called before
+// committing so the entry is left unchanged when writing the target to the
+// credentials provider fails.
+func (d SQLClient) UpsertTargetEntry(ctx context.Context, te TargetEntry, write func() error) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	te.CreatedAt = now
+	te.UpdatedAt = now
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		find := sess.Collection(TargetEntryDB).Find(db.Cond{"project": te.ProjectID, "target": te.TargetName})
+
+		existing := TargetEntry{}
+		err := find.One(&existing)
+		switch {
+		case err == nil:
+			if existing.CreatedAt != "" {
+				te.CreatedAt = existing.CreatedAt
+			}
+			if err := find.Update(te); err != nil {
+				return err
+			}
+		case errors.Is(err, db.ErrNoMoreRows):
+			if _, err := sess.Collection(TargetEntryDB).Insert(te); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if write != nil {
+			return write()
+		}
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetEntry(ctx context.Context, project, target string) (TargetEntry, error) {
+	res := TargetEntry{}
+
+	sess, err := d.session()
+	if err != nil {
+		return res, err
+	}
+
+	err = sess.WithContext(ctx).Collection(TargetEntryDB).Find(db.Cond{"project": project, "target": target}).One(&res)
+	return res, err
+}
+
+// ListTargetEntries returns the entries of the project matching the filter
+// ordered by target.
+func (d SQLClient) ListTargetEntries(ctx context.Context, project string, filter TargetFilter) ([]TargetEntry, error) {
+	res := []TargetEntry{}
+
+	sess, err := d.session()
+	if err != nil {
+		return res, err
+	}
+
+	cond := db.Cond{"project": project}
+	if filter.Tier != "" {
+		cond["tier"] = filter.Tier
+	}
+	if filter.Type != "" {
+		cond["type"] = filter.Type
+	}
+
+	err = sess.WithContext(ctx).Collection(TargetEntryDB).Find(cond).OrderBy("target").All(&res)
+	return res, err
+}
+
+// DeleteTargetEntry deletes the entry of the target. remove, when not nil, is
+// called before committing so the entry is kept when deleting the target from
+// the credentials provider fails.
+func (d SQLClient) DeleteTargetEntry(ctx context.Context, project, target string, remove func() error) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TargetEntryDB).Find(db.Cond{"project": project, "target": target}).Delete(); err != nil {
+			return err
+		}
+
+		if remove != nil {
+			return remove()
+		}
+		return nil
+	})
+}
+
+// ListProjectEntries returns the entries matching the filter ordered by
+// project, and the total number of matching entries.
+func (d SQLClient) ListProjectEntries(ctx context.Context, filter ProjectFilter) ([]ProjectEntry, uint64, error) {
+	res := []ProjectEntry{}
+
+	sess, err := d.session()
+	if err != nil {
+		return res, 0, err
+	}
+
+	cond := db.Cond{}
+	if filter.Name != "" {
+		cond[fmt.Sprintf("project %s", d.dialect().ilike)] = "%" + filter.Name + "%"
+	}
+
+	find := sess.WithContext(ctx).Collection(ProjectEntryDB).Find(cond)
+	total, err := find.Count()
+	if err != nil {
+		return res, 0, err
+	}
+
+	find = find.OrderBy("project").Offset(int(filter.Offset))
+	if filter.Limit > 0 {
+		find = find.Limit(int(filter.Limit))
+	}
+
+	err = find.All(&res)
+	return res, total, err
+}
+
+// AcquireReconciliationLease acquires or extends the named lease for the
+// holder until expiresAt. It returns false when another holder has a lease
+// which has not expired.
+func (d SQLClient) AcquireReconciliationLease(ctx context.Context, name, holder string, now, expiresAt time.Time) (bool, error) {
+	sess, err := d.session()
+	if err != nil {
+		return false, err
+	}
+
+	// The upsert only updates the row when the lease can be taken, which
+	// makes acquiring atomic across replicas.
+	query := fmt.Sprintf(`INSERT INTO %[1]s (name, holder, lease_expires_at) VALUES (?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, lease_expires_at = EXCLUDED.lease_expires_at
+WHERE %[1]s.lease_expires_at < ? OR %[1]s.holder = EXCLUDED.holder`, ReconciliationEntryDB)
+
+	// Times are compared as text by SQLite, which requires a single zone.
+	res, err := sess.WithContext(ctx).SQL().Exec(query, name, holder, expiresAt.UTC(), now.UTC())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// UpdateReconciliationReport stores the report when the holder still has the
+// lease.
+func (d SQLClient) UpdateReconciliationReport(ctx context.Context, name, holder, report string) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Collection(ReconciliationEntryDB).Find(db.Cond{"name": name, "holder": holder}).Update(map[string]interface{}{"report": report})
+}
+
+func (d SQLClient) ReadReconciliationEntry(ctx context.Context, name string) (ReconciliationEntry, error) {
+	res := ReconciliationEntry{}
+
+	sess, err := d.session()
+	if err != nil {
+		return res, err
+	}
+
+	err = sess.WithContext(ctx).Collection(ReconciliationEntryDB).Find("name", name).One(&res)
+	return res, err
+}
+
+// CreateBatchEntry creates the entry of the batch.
+func (d SQLClient) CreateBatchEntry(ctx context.Context, be BatchEntry) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	be.CreatedAt = now
+	be.UpdatedAt = now
+
+	_, err = sess.WithContext(ctx).Collection(BatchEntryDB).Insert(be)
+	return err
+}
+
+// ReadBatchEntry returns the entry of the batch of the project.
+func (d SQLClient) ReadBatchEntry(ctx context.Context, project, batchID string) (BatchEntry, error) {
+	res := BatchEntry{}
+
+	sess, err := d.session()
+	if err != nil {
+		return res, err
+	}
+
+	err = sess.WithContext(ctx).Collection(BatchEntryDB).Find(db.Cond{"project": project, "batch_id": batchID}).One(&res)
+	return res, err
+}
+
+// UpdateBatchEntry updates the status and workflows of the batch.
+func (d SQLClient) UpdateBatchEntry(ctx context.Context, be BatchEntry) error {
+	sess, err := d.session()
+	if err != nil {
+		return err
+	}
+
+	return sess.WithContext(ctx).Collection(BatchEntryDB).Find("batch_id", be.BatchID).Update(map[string]interface{}{
+		"status":     be.Status,
+		"workflows":  be.Workflows,
+		"updated_at": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// InterruptBatchEntries interrupts the running batches which weren't updated
+// since updatedBefore, e.g. because the replica running them stopped. It
+// returns the IDs of the interrupted batches.
+func (d SQLClient) InterruptBatchEntries(ctx context.Context, updatedBefore time.Time) ([]string, error) {
+	sess, err := d.session()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	err = sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		var stale []BatchEntry
+		cond := db.Cond{"status": BatchStatusRunning, "updated_at <": updatedBefore.UTC().Format(time.RFC3339)}
+		if err := sess.Collection(BatchEntryDB).Find(cond).All(&stale); err != nil {
+			return err
+		}
+
+		for _, be := range stale {
+			be.Interrupt()
+			if err := sess.Collection(BatchEntryDB).Find("batch_id", be.BatchID).Update(map[string]interface{}{
+				"status":     be.Status,
+				"workflows":  be.Workflows,
+				"updated_at": time.Now().UTC().Format(time.RFC3339),
+			}); err != nil {
+				return err
+			}
+			ids = append(ids, be.BatchID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,39 @@
+package db
+
+// dialect holds the queries which differ between drivers.
+type dialect struct {
+	// ilike is the case insensitive LIKE operator.
+	ilike string
+	// lockMigrations and unlockMigrations hold and release a lock across
+	// replicas while migrating. They are empty when the database can't be
+	// shared by replicas.
+	lockMigrations   string
+	unlockMigrations string
+	// migrations is the directory of the migrations of the driver.
+	migrations string
+
+	insertSchemaVersion   string
+	schemaMigrationsExist string
+}
+
+var dialects = map[string]dialect{
+	DriverPostgres: {
+		ilike:                 "ILIKE",
+		lockMigrations:        "SELECT pg_advisory_lock($1)",
+		unlockMigrations:      "SELECT pg_advisory_unlock($1)",
+		migrations:            "migrations/postgres",
+		insertSchemaVersion:   "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)",
+		schemaMigrationsExist: "SELECT to_regclass('schema_migrations') IS NOT NULL",
+	},
+	DriverSQLite: {
+		// LIKE is case insensitive for ASCII in SQLite.
+		ilike:                 "LIKE",
+		migrations:            "migrations/sqlite",
+		insertSchemaVersion:   "INSERT INTO schema_migrations (version, dirty) VALUES (?, false)",
+		schemaMigrationsExist: "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'",
+	},
+}
+
+func (d SQLClient) dialect() dialect {
+	return dialects[d.pool.driver]
+}
@@ -0,0 +1,288 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migrationFiles are the schema migrations of each driver, with the same
+// versions. The postgres migrations are also applied with golang-migrate by
+// the db migration image, so the schema_migrations table is kept compatible
+// with it.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the key of the advisory lock held while migrating so
+// replicas starting together don't apply migrations concurrently.
+const migrationLockID = 4280617183
+
+// Queries of the schema_migrations table of golang-migrate. The queries
+// which differ between drivers are in their dialect.
+const (
+	createSchemaMigrationsQuery = "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)"
+	deleteSchemaVersionQuery    = "DELETE FROM schema_migrations"
+	selectSchemaVersionQuery    = "SELECT version, dirty FROM schema_migrations LIMIT 1"
+)
+
+var (
+	// ErrUnknownSchemaVersion conveys that the database was migrated by a newer
+	// version of the service.
+	ErrUnknownSchemaVersion = errors.New("unknown database schema version")
+	// ErrDirtySchema conveys that a migration failed part way, which requires
+	// a manual fix.
+	ErrDirtySchema = errors.New("dirty database schema")
+)
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a schema migration.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaStatus is the schema version of the database and the latest version
+// known by the service.
+type SchemaStatus struct {
+	Dirty   bool
+	Latest  uint
+	Version uint
+}
+
+// Check returns an error when the service can't use the schema.
+func (s SchemaStatus) Check() error {
+	if s.Version > s.Latest {
+		return fmt.Errorf("%w %d, latest known version is %d", ErrUnknownSchemaVersion, s.Version, s.Latest)
+	}
+
+	if s.Dirty {
+		return fmt.Errorf("%w at version %d", ErrDirtySchema, s.Version)
+	}
+	return nil
+}
+
+// Migrations returns the embedded migrations of the driver ordered by
+// version.
+func Migrations(driver string) ([]Migration, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown db driver %s", driver)
+	}
+	return parseMigrations(migrationFiles, d.migrations)
+}
+
+func parseMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, e := range entries {
+		m := migrationFileRegex.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", e.Name())
+		}
+
+		version, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", e.Name())
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[uint(version)]
+		if !ok {
+			mig = &Migration{Version: uint(version), Name: m[2]}
+			byVersion[uint(version)] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has different names %s and %s", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d requires up and down files", mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, mig := range migrations {
+		if mig.Version != uint(i+1) {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaStatus returns the schema version of the database.
+func (d SQLClient) SchemaStatus(ctx context.Context) (SchemaStatus, error) {
+	migrations, err := Migrations(d.pool.driver)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+
+	sqlDB, err := d.sqlDB()
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+
+	status := SchemaStatus{Latest: uint(len(migrations))}
+	status.Version, status.Dirty, err = readSchemaVersion(ctx, sqlDB, d.dialect())
+	return status, err
+}
+
+// MigrateUp applies all pending migrations.
+func (d SQLClient) MigrateUp(ctx context.Context) (SchemaStatus, error) {
+	return d.migrate(ctx, func(status SchemaStatus, migrations []Migration) []migrationStep {
+		var steps []migrationStep
+		for _, mig := range migrations[status.Version:] {
+			steps = append(steps, migrationStep{query: mig.Up, version: mig.Version})
+		}
+		return steps
+	})
+}
+
+// MigrateDown reverts the number of migrations.
+func (d SQLClient) MigrateDown(ctx context.Context, n uint) (SchemaStatus, error) {
+	return d.migrate(ctx, func(status SchemaStatus, migrations []Migration) []migrationStep {
+		var steps []migrationStep
+		for v := status.Version; v > 0 && uint(len(steps)) < n; v-- {
+			steps = append(steps, migrationStep{query: migrations[v-1].Down, version: v - 1})
+		}
+		return steps
+	})
+}
+
+// migrationStep is a query and the version of the schema once it's applied.
+type migrationStep struct {
+	query   string
+	version uint
+}
+
+func (d SQLClient) migrate(ctx context.Context, plan func(SchemaStatus, []Migration) []migrationStep) (SchemaStatus, error) {
+	migrations, err := Migrations(d.pool.driver)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+
+	sqlDB, err := d.sqlDB()
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+
+	dialect := d.dialect()
+
+	// Advisory locks are held by a connection.
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	defer conn.Close()
+
+	if dialect.lockMigrations != "" {
+		if _, err := conn.ExecContext(ctx, dialect.lockMigrations, migrationLockID); err != nil {
+			return SchemaStatus{}, fmt.Errorf("unable to lock migrations: %w", err)
+		}
+		// Unlock even when the context is done.
+		defer conn.ExecContext(context.Background(), dialect.unlockMigrations, migrationLockID)
+	}
+
+	if _, err := conn.ExecContext(ctx, createSchemaMigrationsQuery); err != nil {
+		return SchemaStatus{}, err
+	}
+
+	status := SchemaStatus{Latest: uint(len(migrations))}
+	status.Version, status.Dirty, err = readSchemaVersion(ctx, conn, dialect)
+	if err != nil {
+		return status, err
+	}
+
+	if err := status.Check(); err != nil {
+		return status, err
+	}
+
+	for _, step := range plan(status, migrations) {
+		if err := applyMigrationStep(ctx, conn, dialect, step); err != nil {
+			return status, err
+		}
+		status.Version = step.version
+	}
+	return status, nil
+}
+
+// applyMigrationStep applies the query and sets the version in one
+// transaction, so failed migrations leave the schema unchanged.
+func applyMigrationStep(ctx context.Context, conn *sql.Conn, dialect dialect, step migrationStep) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, step.query); err != nil {
+		return fmt.Errorf("unable to migrate to version %d: %w", step.version, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, deleteSchemaVersionQuery); err != nil {
+		return err
+	}
+
+	// Like golang-migrate, the table is empty at version 0.
+	if step.version > 0 {
+		if _, err := tx.ExecContext(ctx, dialect.insertSchemaVersion, step.version); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func readSchemaVersion(ctx context.Context, q queryer, dialect dialect) (uint, bool, error) {
+	var exists bool
+	if err := q.QueryRowContext(ctx, dialect.schemaMigrationsExist).Scan(&exists); err != nil {
+		return 0, false, err
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	var version int64
+	var dirty bool
+	err := q.QueryRowContext(ctx, selectSchemaVersionQuery).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
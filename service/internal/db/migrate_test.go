@@ -0,0 +1,94 @@
+package db
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	postgres, err := Migrations(DriverPostgres)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(16), postgres[len(postgres)-1].Version)
+	assert.Equal(t, "createtables", postgres[0].Name)
+
+	// Drivers have the same migrations so schema versions match.
+	sqlite, err := Migrations(DriverSQLite)
+	assert.NoError(t, err)
+	assert.Len(t, sqlite, len(postgres))
+	for i := range postgres {
+		assert.Equal(t, postgres[i].Version, sqlite[i].Version)
+		assert.Equal(t, postgres[i].Name, sqlite[i].Name)
+	}
+
+	_, err = Migrations("mysql")
+	assert.EqualError(t, err, "unknown db driver mysql")
+}
+
+func TestParseMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "parses migrations ordered by version",
+			files: fstest.MapFS{
+				"m/000002_b.down.sql": {Data: []byte("down2")},
+				"m/000002_b.up.sql":   {Data: []byte("up2")},
+				"m/000001_a.down.sql": {Data: []byte("down1")},
+				"m/000001_a.up.sql":   {Data: []byte("up1")},
+			},
+			want: []Migration{
+				{Version: 1, Name: "a", Up: "up1", Down: "down1"},
+				{Version: 2, Name: "b", Up: "up2", Down: "down2"},
+			},
+		},
+		{
+			name:    "invalid file name",
+			files:   fstest.MapFS{"m/a.sql": {Data: []byte("up")}},
+			wantErr: "invalid migration file name a.sql",
+		},
+		{
+			name:    "missing down",
+			files:   fstest.MapFS{"m/000001_a.up.sql": {Data: []byte("up1")}},
+			wantErr: "migration 1 requires up and down files",
+		},
+		{
+			name: "missing version",
+			files: fstest.MapFS{
+				"m/000002_b.down.sql": {Data: []byte("down2")},
+				"m/000002_b.up.sql":   {Data: []byte("up2")},
+			},
+			wantErr: "migration 1 is missing",
+		},
+		{
+			name: "different names",
+			files: fstest.MapFS{
+				"m/000001_a.down.sql": {Data: []byte("down1")},
+				"m/000001_b.up.sql":   {Data: []byte("up1")},
+			},
+			wantErr: "migration 1 has different names a and b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMigrations(tt.files, "m")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSchemaStatusCheck(t *testing.T) {
+	assert.NoError(t, SchemaStatus{Latest: 8, Version: 8}.Check())
+	assert.NoError(t, SchemaStatus{Latest: 8, Version: 3}.Check())
+	assert.ErrorIs(t, SchemaStatus{Latest: 8, Version: 9}.Check(), ErrUnknownSchemaVersion)
+	assert.ErrorIs(t, SchemaStatus{Latest: 8, Version: 8, Dirty: true}.Check(), ErrDirtySchema)
+}
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS targets
+(
+    project VARCHAR(80) NOT NULL,
+    target VARCHAR(80) NOT NULL,
+    region VARCHAR(32) NOT NULL DEFAULT '',
+    CONSTRAINT targets_pkey PRIMARY KEY (project, target),
+    FOREIGN KEY (project) REFERENCES projects(project) on delete cascade on update cascade
+);
+GRANT ALL PRIVILEGES ON targets TO cello;
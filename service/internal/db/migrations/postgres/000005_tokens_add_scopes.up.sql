@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS tokens ADD COLUMN IF NOT EXISTS scopes JSONB NOT NULL DEFAULT '{}';
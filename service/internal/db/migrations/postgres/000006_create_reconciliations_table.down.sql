@@ -0,0 +1,2 @@
+REVOKE ALL PRIVILEGES ON reconciliations FROM cello;
+DROP TABLE IF EXISTS reconciliations;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS reconciliations
+(
+    name VARCHAR(80) NOT NULL,
+    holder VARCHAR(200) NOT NULL,
+    lease_expires_at TIMESTAMPTZ NOT NULL,
+    report JSONB NOT NULL DEFAULT '{}',
+    CONSTRAINT reconciliations_pkey PRIMARY KEY (name)
+);
+GRANT ALL PRIVILEGES ON reconciliations TO cello;
@@ -0,0 +1,4 @@
+ALTER TABLE IF EXISTS projects DROP COLUMN IF EXISTS labels;
+ALTER TABLE IF EXISTS projects DROP COLUMN IF EXISTS contact;
+ALTER TABLE IF EXISTS projects DROP COLUMN IF EXISTS team;
+ALTER TABLE IF EXISTS projects DROP COLUMN IF EXISTS description;
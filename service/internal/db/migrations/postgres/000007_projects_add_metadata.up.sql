@@ -0,0 +1,4 @@
+ALTER TABLE IF EXISTS projects ADD COLUMN IF NOT EXISTS description VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS projects ADD COLUMN IF NOT EXISTS team VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS projects ADD COLUMN IF NOT EXISTS contact VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS projects ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+REVOKE ALL PRIVILEGES ON credentials_sessions FROM cello;
+REVOKE ALL PRIVILEGES ON credentials_tokens FROM cello;
+REVOKE ALL PRIVILEGES ON credentials_targets FROM cello;
+REVOKE ALL PRIVILEGES ON credentials_projects FROM cello;
+DROP TABLE IF EXISTS credentials_sessions;
+DROP TABLE IF EXISTS credentials_tokens;
+DROP TABLE IF EXISTS credentials_targets;
+DROP TABLE IF EXISTS credentials_projects;
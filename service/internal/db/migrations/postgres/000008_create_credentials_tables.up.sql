@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS credentials_projects
+(
+    project VARCHAR(80) NOT NULL,
+    role_id VARCHAR(64) NOT NULL,
+    CONSTRAINT credentials_projects_pkey PRIMARY KEY (project),
+    CONSTRAINT credentials_projects_role_id_key UNIQUE (role_id)
+);
+CREATE TABLE IF NOT EXISTS credentials_targets
+(
+    project VARCHAR(80) NOT NULL,
+    target VARCHAR(80) NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    properties JSONB NOT NULL DEFAULT '{}',
+    CONSTRAINT credentials_targets_pkey PRIMARY KEY (project, target),
+    FOREIGN KEY (project) REFERENCES credentials_projects(project) on delete cascade on update cascade
+);
+CREATE TABLE IF NOT EXISTS credentials_tokens
+(
+    token_id VARCHAR(64) NOT NULL,
+    project VARCHAR(80) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT credentials_tokens_pkey PRIMARY KEY (token_id),
+    FOREIGN KEY (project) REFERENCES credentials_projects(project) on delete cascade on update cascade
+);
+CREATE TABLE IF NOT EXISTS credentials_sessions
+(
+    secret_hash VARCHAR(64) NOT NULL,
+    project VARCHAR(80) NOT NULL,
+    token_id VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    uses_left INTEGER NOT NULL,
+    CONSTRAINT credentials_sessions_pkey PRIMARY KEY (secret_hash),
+    FOREIGN KEY (token_id) REFERENCES credentials_tokens(token_id) on delete cascade
+);
+GRANT ALL PRIVILEGES ON credentials_projects TO cello;
+GRANT ALL PRIVILEGES ON credentials_targets TO cello;
+GRANT ALL PRIVILEGES ON credentials_tokens TO cello;
+GRANT ALL PRIVILEGES ON credentials_sessions TO cello;
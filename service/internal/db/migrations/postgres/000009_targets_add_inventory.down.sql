@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS targets_project_type_tier_idx;
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS created_at;
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS owner;
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS tier;
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS description;
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS properties;
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS type;
@@ -0,0 +1,8 @@
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS type VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS properties JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS description VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS tier VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS owner VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS targets_project_type_tier_idx ON targets (project, type, tier);
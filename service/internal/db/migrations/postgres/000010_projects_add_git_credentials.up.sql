@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS projects ADD COLUMN IF NOT EXISTS git_credentials VARCHAR(512) NOT NULL DEFAULT '';
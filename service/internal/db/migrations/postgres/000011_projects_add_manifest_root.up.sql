@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS projects ADD COLUMN IF NOT EXISTS manifest_root VARCHAR(255) NOT NULL DEFAULT '';
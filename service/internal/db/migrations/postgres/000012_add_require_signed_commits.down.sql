@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS targets DROP COLUMN IF EXISTS require_signed_commits;
+ALTER TABLE IF EXISTS projects DROP COLUMN IF EXISTS require_signed_commits;
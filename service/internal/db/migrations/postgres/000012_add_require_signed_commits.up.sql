@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS projects ADD COLUMN IF NOT EXISTS require_signed_commits JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE IF EXISTS targets ADD COLUMN IF NOT EXISTS require_signed_commits JSONB NOT NULL DEFAULT '[]';
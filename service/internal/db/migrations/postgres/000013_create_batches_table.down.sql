@@ -0,0 +1,2 @@
+REVOKE ALL PRIVILEGES ON batches FROM cello;
+DROP TABLE IF EXISTS batches;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS batches
+(
+    batch_id VARCHAR(36) NOT NULL,
+    project VARCHAR(80) NOT NULL,
+    sha VARCHAR(64) NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    parallelism INTEGER NOT NULL DEFAULT 0,
+    stop_on_failure BOOLEAN NOT NULL DEFAULT false,
+    workflows JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT batches_pkey PRIMARY KEY (batch_id),
+    FOREIGN KEY (project) REFERENCES projects(project) on delete cascade on update cascade
+);
+CREATE INDEX IF NOT EXISTS batches_project_idx ON batches (project);
+GRANT ALL PRIVILEGES ON batches TO cello;
@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS credentials_sessions DROP COLUMN IF EXISTS target;
@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS credentials_sessions ADD COLUMN IF NOT EXISTS target VARCHAR(80) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS tokens ADD COLUMN IF NOT EXISTS rotated BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS projects
+(
+    project VARCHAR(80) NOT NULL,
+    repository VARCHAR(200),
+    CONSTRAINT projects_pkey PRIMARY KEY (project)
+);
@@ -0,0 +1 @@
+ALTER TABLE tokens ADD COLUMN scopes TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS reconciliations
+(
+    name VARCHAR(80) NOT NULL,
+    holder VARCHAR(200) NOT NULL,
+    lease_expires_at TEXT NOT NULL,
+    report TEXT NOT NULL DEFAULT '{}',
+    CONSTRAINT reconciliations_pkey PRIMARY KEY (name)
+);
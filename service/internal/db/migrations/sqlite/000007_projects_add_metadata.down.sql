@@ -0,0 +1,4 @@
+ALTER TABLE projects DROP COLUMN labels;
+ALTER TABLE projects DROP COLUMN contact;
+ALTER TABLE projects DROP COLUMN team;
+ALTER TABLE projects DROP COLUMN description;
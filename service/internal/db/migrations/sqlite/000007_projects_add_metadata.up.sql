@@ -0,0 +1,4 @@
+ALTER TABLE projects ADD COLUMN description VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN team VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN contact VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN labels TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS credentials_sessions;
+DROP TABLE IF EXISTS credentials_tokens;
+DROP TABLE IF EXISTS credentials_targets;
+DROP TABLE IF EXISTS credentials_projects;
@@ -0,0 +1,8 @@
+DROP INDEX targets_project_type_tier_idx;
+ALTER TABLE targets DROP COLUMN updated_at;
+ALTER TABLE targets DROP COLUMN created_at;
+ALTER TABLE targets DROP COLUMN owner;
+ALTER TABLE targets DROP COLUMN tier;
+ALTER TABLE targets DROP COLUMN description;
+ALTER TABLE targets DROP COLUMN properties;
+ALTER TABLE targets DROP COLUMN type;
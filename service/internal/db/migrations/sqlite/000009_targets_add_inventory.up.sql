@@ -0,0 +1,8 @@
+ALTER TABLE targets ADD COLUMN type VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE targets ADD COLUMN properties TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE targets ADD COLUMN description VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE targets ADD COLUMN tier VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE targets ADD COLUMN owner VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE targets ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+ALTER TABLE targets ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+CREATE INDEX targets_project_type_tier_idx ON targets (project, type, tier);
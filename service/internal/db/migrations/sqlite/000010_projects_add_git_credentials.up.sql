@@ -0,0 +1 @@
+ALTER TABLE projects ADD COLUMN git_credentials VARCHAR(512) NOT NULL DEFAULT '';
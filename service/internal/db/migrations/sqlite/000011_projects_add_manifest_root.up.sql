@@ -0,0 +1 @@
+ALTER TABLE projects ADD COLUMN manifest_root VARCHAR(255) NOT NULL DEFAULT '';
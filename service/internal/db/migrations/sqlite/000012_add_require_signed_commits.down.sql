@@ -0,0 +1,2 @@
+ALTER TABLE targets DROP COLUMN require_signed_commits;
+ALTER TABLE projects DROP COLUMN require_signed_commits;
@@ -0,0 +1,2 @@
+ALTER TABLE projects ADD COLUMN require_signed_commits TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE targets ADD COLUMN require_signed_commits TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1 @@
+ALTER TABLE credentials_sessions DROP COLUMN target;
@@ -0,0 +1 @@
+ALTER TABLE credentials_sessions ADD COLUMN target VARCHAR(80) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE projects ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
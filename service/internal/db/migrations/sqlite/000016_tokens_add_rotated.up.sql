@@ -0,0 +1 @@
+ALTER TABLE tokens ADD COLUMN rotated BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/sqlite"
+
+	// Pure Go SQLite driver, so the service builds without cgo.
+	_ "modernc.org/sqlite"
+)
+
+// NewSQLiteClient returns a SQLClient storing entries in the SQLite database
+// file, for local development and single node installs. ":memory:" is an
+// in-memory database.
+func NewSQLiteClient(file string) (SQLClient, error) {
+	if file == "" {
+		return SQLClient{}, fmt.Errorf("sqlite database file is required")
+	}
+
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Set("_time_format", "sqlite")
+	dsn := fmt.Sprintf("file:%s?%s", file, q.Encode())
+
+	return SQLClient{
+		pool: &sqlPool{
+			// SQLite allows one writer at a time, and each connection to an
+			// in-memory database is a different database.
+			config: PoolConfig{MaxIdleConns: 1, MaxOpenConns: 1},
+			driver: DriverSQLite,
+			open: func() (db.Session, error) {
+				sqlDB, err := sql.Open("sqlite", dsn)
+				if err != nil {
+					return nil, err
+				}
+				return sqlite.New(sqlDB)
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,354 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	upper "github.com/upper/db/v4"
+)
+
+func newTestSQLiteClient(t *testing.T) SQLClient {
+	t.Helper()
+
+	d, err := NewSQLiteClient(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { d.Close() })
+
+	_, err = d.MigrateUp(context.Background())
+	require.NoError(t, err)
+	return d
+}
+
+func TestNewSQLiteClient(t *testing.T) {
+	_, err := NewSQLiteClient("")
+	assert.EqualError(t, err, "sqlite database file is required")
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	d, err := NewSQLiteClient(":memory:")
+	require.NoError(t, err)
+	defer d.Close()
+
+	status, err := d.SchemaStatus(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaStatus{Latest: 16}, status)
+
+	status, err = d.MigrateUp(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaStatus{Latest: 16, Version: 16}, status)
+
+	status, err = d.MigrateDown(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaStatus{Latest: 16, Version: 14}, status)
+
+	status, err = d.SchemaStatus(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaStatus{Latest: 16, Version: 14}, status)
+
+	status, err = d.MigrateDown(ctx, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaStatus{Latest: 16}, status)
+
+	status, err = d.MigrateUp(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaStatus{Latest: 16, Version: 16}, status)
+}
+
+func TestSQLiteProjectEntries(t *testing.T) {
+	ctx := context.Background()
+	d := newTestSQLiteClient(t)
+
+	assert.NoError(t, d.Health(ctx))
+
+	pe := ProjectEntry{ProjectID: "project1", Repository: "repo1", ManifestRoot: "cello", RequireSignedCommits: SignedCommitPolicy{"sync"}, Team: "team1", Labels: ProjectLabels{"env": "dev"}}
+	assert.NoError(t, d.CreateProjectEntry(ctx, pe))
+	assert.NoError(t, d.CreateProjectEntry(ctx, ProjectEntry{ProjectID: "Project2", Repository: "repo2"}))
+	assert.NoError(t, d.CreateProjectEntry(ctx, ProjectEntry{ProjectID: "other", Repository: "repo3"}))
+
+	got, err := d.ReadProjectEntry(ctx, "project1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, got.CreatedAt)
+	createdAt := got.CreatedAt
+	pe.CreatedAt = got.CreatedAt
+	assert.Equal(t, pe, got)
+
+	// Updates keep the creation time.
+	pe.CreatedAt = ""
+	pe.Description = "updated"
+	assert.NoError(t, d.UpdateProjectEntry(ctx, pe))
+	got, err = d.ReadProjectEntry(ctx, "project1")
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", got.Description)
+	assert.Equal(t, createdAt, got.CreatedAt)
+
+	// Updates of entries keep the git credentials, which are only updated on
+	// their own.
+	assert.NoError(t, d.UpdateProjectGitCredentials(ctx, "project1", "secret/data/projects/project1/git"))
+	assert.NoError(t, d.UpdateProjectEntry(ctx, pe))
+	got, err = d.ReadProjectEntry(ctx, "project1")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret/data/projects/project1/git", got.GitCredentials)
+
+	assert.NoError(t, d.UpdateProjectGitCredentials(ctx, "project1", ""))
+	got, err = d.ReadProjectEntry(ctx, "project1")
+	assert.NoError(t, err)
+	assert.Empty(t, got.GitCredentials)
+
+	// Name filters are case insensitive.
+	entries, total, err := d.ListProjectEntries(ctx, ProjectFilter{Name: "PROJECT", Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), total)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "project1", entries[0].ProjectID)
+
+	assert.NoError(t, d.DeleteProjectEntry(ctx, "project1"))
+	_, err = d.ReadProjectEntry(ctx, "project1")
+	assert.ErrorIs(t, err, upper.ErrNoMoreRows)
+}
+
+func TestSQLiteTokenAndTargetEntries(t *testing.T) {
+	ctx := context.Background()
+	d := newTestSQLiteClient(t)
+
+	assert.NoError(t, d.CreateProjectEntry(ctx, ProjectEntry{ProjectID: "project1", Repository: "repo1"}))
+
+	token := types.Token{
+		CreatedAt:    "2022-06-01T00:00:00Z",
+		ExpiresAt:    "2023-06-01T00:00:00Z",
+		ProjectID:    "project1",
+		ProjectToken: types.ProjectToken{ID: "token1"},
+		Scopes:       types.TokenScopes{ReadOnly: true},
+	}
+	assert.NoError(t, d.CreateTokenEntry(ctx, token))
+
+	got, err := d.ReadTokenEntry(ctx, "token1")
+	assert.NoError(t, err)
+	assert.Equal(t, TokenEntry{CreatedAt: token.CreatedAt, ExpiresAt: token.ExpiresAt, ProjectID: "project1", Scopes: TokenScopes{ReadOnly: true}, TokenID: "token1"}, got)
+
+	rotated := token
+	rotated.ProjectToken.ID = "token2"
+	assert.NoError(t, d.RotateTokenEntry(ctx, "token1", rotated, "2022-07-01T00:00:00Z", nil))
+
+	entries, err := d.ListTokenEntries(ctx, "project1")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	got, err = d.ReadTokenEntry(ctx, "token1")
+	assert.NoError(t, err)
+	assert.Equal(t, "2022-07-01T00:00:00Z", got.ExpiresAt)
+	assert.True(t, got.Rotated)
+
+	// Only rotated tokens whose grace period hasn't ended are listed.
+	entries, err = d.ListRotatedTokenEntries(ctx, time.Date(2022, 6, 30, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "token1", entries[0].TokenID)
+
+	entries, err = d.ListRotatedTokenEntries(ctx, time.Date(2022, 7, 2, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.NoError(t, d.DeleteTokenEntry(ctx, "token1"))
+	_, err = d.ReadTokenEntry(ctx, "token1")
+	assert.ErrorIs(t, err, upper.ErrNoMoreRows)
+
+	te := TargetEntry{ProjectID: "project1", TargetName: "target1", Region: "us-west-2"}
+	assert.NoError(t, d.UpsertTargetEntry(ctx, te, nil))
+
+	gotTarget, err := d.ReadTargetEntry(ctx, "project1", "target1")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2", gotTarget.Region)
+
+	// Deleting the project cascades to its tokens and targets.
+	assert.NoError(t, d.DeleteProjectEntry(ctx, "project1"))
+	entries, err = d.ListTokenEntries(ctx, "project1")
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	_, err = d.ReadTargetEntry(ctx, "project1", "target1")
+	assert.ErrorIs(t, err, upper.ErrNoMoreRows)
+}
+
+func TestSQLiteTargetEntries(t *testing.T) {
+	ctx := context.Background()
+	d := newTestSQLiteClient(t)
+
+	assert.NoError(t, d.CreateProjectEntry(ctx, ProjectEntry{ProjectID: "project1", Repository: "repo1"}))
+
+	target := types.Target{
+		TargetMetadata: types.TargetMetadata{Description: "target1", Owner: "platform", RequireSignedCommits: types.SignedCommitPolicy{"sync"}, Tier: "production"},
+		Name:           "target1",
+		Properties: types.TargetProperties{
+			CredentialType: "assumed_role",
+			PolicyArns:     []string{"arn:aws:iam::012345678901:policy/test-policy"},
+			Region:         "us-west-2",
+			RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+		},
+		Type: "aws_account",
+	}
+	assert.NoError(t, d.UpsertTargetEntry(ctx, NewTargetEntry("project1", target), nil))
+	assert.NoError(t, d.UpsertTargetEntry(ctx, TargetEntry{ProjectID: "project1", TargetName: "target2", Tier: "development", Type: "aws_account"}, nil))
+
+	got, err := d.ReadTargetEntry(ctx, "project1", "target1")
+	assert.NoError(t, err)
+	assert.True(t, got.IsImported())
+	assert.Equal(t, target, got.Target())
+	assert.NotEmpty(t, got.CreatedAt)
+	assert.Equal(t, got.CreatedAt, got.UpdatedAt)
+
+	// Failing writes roll back the entry.
+	updated := target
+	updated.Tier = "staging"
+	err = d.UpsertTargetEntry(ctx, NewTargetEntry("project1", updated), func() error { return errors.New("error") })
+	assert.EqualError(t, err, "error")
+	got, err = d.ReadTargetEntry(ctx, "project1", "target1")
+	assert.NoError(t, err)
+	assert.Equal(t, "production", got.Tier)
+
+	// Updates keep the creation time.
+	assert.NoError(t, d.UpsertTargetEntry(ctx, NewTargetEntry("project1", updated), func() error { return nil }))
+	updatedEntry, err := d.ReadTargetEntry(ctx, "project1", "target1")
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", updatedEntry.Tier)
+	assert.Equal(t, got.CreatedAt, updatedEntry.CreatedAt)
+
+	entries, err := d.ListTargetEntries(ctx, "project1", TargetFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "target1", entries[0].TargetName)
+
+	entries, err = d.ListTargetEntries(ctx, "project1", TargetFilter{Tier: "development", Type: "aws_account"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "target2", entries[0].TargetName)
+
+	entries, err = d.ListTargetEntries(ctx, "project2", TargetFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Failing removals keep the entry.
+	err = d.DeleteTargetEntry(ctx, "project1", "target1", func() error { return errors.New("error") })
+	assert.EqualError(t, err, "error")
+	_, err = d.ReadTargetEntry(ctx, "project1", "target1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, d.DeleteTargetEntry(ctx, "project1", "target1", nil))
+	_, err = d.ReadTargetEntry(ctx, "project1", "target1")
+	assert.ErrorIs(t, err, upper.ErrNoMoreRows)
+}
+
+func TestSQLiteReconciliationLease(t *testing.T) {
+	ctx := context.Background()
+	d := newTestSQLiteClient(t)
+
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	ok, err := d.AcquireReconciliationLease(ctx, "default", "host1", now, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Other holders can't take a lease which hasn't expired.
+	ok, err = d.AcquireReconciliationLease(ctx, "default", "host2", now.Add(time.Minute), now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// The holder can extend its lease.
+	ok, err = d.AcquireReconciliationLease(ctx, "default", "host1", now.Add(time.Minute), now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.NoError(t, d.UpdateReconciliationReport(ctx, "default", "host1", `{"repair":false}`))
+	entry, err := d.ReadReconciliationEntry(ctx, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "host1", entry.Holder)
+	assert.Equal(t, `{"repair":false}`, entry.Report)
+
+	// Expired leases can be taken, also from another zone.
+	ok, err = d.AcquireReconciliationLease(ctx, "default", "host2", now.Add(3*time.Hour).In(time.FixedZone("PDT", -7*3600)), now.Add(4*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestSQLiteBatchEntries(t *testing.T) {
+	ctx := context.Background()
+	d := newTestSQLiteClient(t)
+
+	assert.NoError(t, d.CreateProjectEntry(ctx, ProjectEntry{ProjectID: "project1", Repository: "repo1"}))
+
+	be := BatchEntry{
+		BatchID:       "batch1",
+		ProjectID:     "project1",
+		CommitHash:    "1234567",
+		Type:          "sync",
+		Status:        BatchStatusRunning,
+		Parallelism:   2,
+		StopOnFailure: true,
+		Workflows: BatchWorkflows{
+			{Status: BatchStatusPending, Target: "target1", Wave: 0},
+			{Status: BatchStatusPending, Target: "target2", Wave: 1},
+		},
+	}
+	assert.NoError(t, d.CreateBatchEntry(ctx, be))
+
+	got, err := d.ReadBatchEntry(ctx, "project1", "batch1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, got.CreatedAt)
+	be.CreatedAt = got.CreatedAt
+	be.UpdatedAt = got.UpdatedAt
+	assert.Equal(t, be, got)
+
+	be.Status = BatchStatusFailed
+	be.Workflows[0] = BatchWorkflow{Status: "failed", Target: "target1", WorkflowName: "wf-1"}
+	be.Workflows[1].Status = BatchStatusSkipped
+	assert.NoError(t, d.UpdateBatchEntry(ctx, be))
+
+	got, err = d.ReadBatchEntry(ctx, "project1", "batch1")
+	assert.NoError(t, err)
+	assert.Equal(t, BatchStatusFailed, got.Status)
+	assert.Equal(t, be.Workflows, got.Workflows)
+
+	// Batches are only read from their project.
+	_, err = d.ReadBatchEntry(ctx, "project2", "batch1")
+	assert.ErrorIs(t, err, upper.ErrNoMoreRows)
+
+	// Only running batches which weren't updated since are interrupted.
+	running := BatchEntry{
+		BatchID:   "batch2",
+		ProjectID: "project1",
+		Status:    BatchStatusRunning,
+		Workflows: BatchWorkflows{
+			{Status: BatchStatusSucceeded, Target: "target1", WorkflowName: "wf-1"},
+			{Status: BatchStatusRunning, Target: "target2", WorkflowName: "wf-2", Wave: 1},
+			{Status: BatchStatusPending, Target: "target3", Wave: 2},
+		},
+	}
+	assert.NoError(t, d.CreateBatchEntry(ctx, running))
+
+	ids, err := d.InterruptBatchEntries(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	ids, err = d.InterruptBatchEntries(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"batch2"}, ids)
+
+	got, err = d.ReadBatchEntry(ctx, "project1", "batch2")
+	assert.NoError(t, err)
+	assert.Equal(t, BatchStatusInterrupted, got.Status)
+	assert.Equal(t, BatchWorkflows{
+		{Status: BatchStatusSucceeded, Target: "target1", WorkflowName: "wf-1"},
+		{Status: BatchStatusInterrupted, Target: "target2", WorkflowName: "wf-2", Wave: 1},
+		{Status: BatchStatusInterrupted, Target: "target3", Wave: 2},
+	}, got.Workflows)
+
+	got, err = d.ReadBatchEntry(ctx, "project1", "batch1")
+	assert.NoError(t, err)
+	assert.Equal(t, BatchStatusFailed, got.Status)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
@@ -17,25 +18,65 @@ const legacyAppPrefix = "ARGO_CLOUDOPS"
 const appPrefix = "CELLO"
 
 type Vars struct {
-	AdminSecret    string   `split_words:"true" required:"true"`
-	VaultRole      string   `envconfig:"VAULT_ROLE" required:"true"`
-	VaultSecret    string   `envconfig:"VAULT_SECRET" required:"true"`
-	VaultAddress   string   `envconfig:"VAULT_ADDR" required:"true"`
-	ArgoAddress    string   `envconfig:"ARGO_ADDR" required:"true"`
-	ArgoNamespace  string   `envconfig:"WORKFLOW_EXECUTION_NAMESPACE" default:"argo"`
-	ConfigFilePath string   `envconfig:"CONFIG" default:"cello.yaml"`
-	SSHPEMFile     string   `envconfig:"SSH_PEM_FILE"`
-	GitAuthMethod  string   `split_words:"true" required:"true"`
-	GitHTTPSUser   string   `envconfig:"GIT_HTTPS_USER"`
-	GitHTTPSPass   string   `envconfig:"GIT_HTTPS_PASS"`
-	LogLevel       string   `split_words:"true"`
-	Port           int      `default:"8443"`
-	DBHost         string   `split_words:"true" required:"true"`
-	DBUser         string   `split_words:"true" required:"true"`
-	DBPassword     string   `split_words:"true" required:"true"`
-	DBName         string   `split_words:"true" required:"true"`
-	DBOptions      string   `split_words:"true"`
-	ImageURIs      []string `envconfig:"IMAGE_URIS"`
+	AdminSecret           string        `split_words:"true" required:"true"`
+	VaultRole             string        `envconfig:"VAULT_ROLE"`
+	VaultSecret           string        `envconfig:"VAULT_SECRET"`
+	VaultAddress          string        `envconfig:"VAULT_ADDR"`
+	VaultK8SRole          string        `envconfig:"VAULT_K8S_ROLE"`
+	VaultK8SMount         string        `envconfig:"VAULT_K8S_MOUNT" default:"kubernetes"`
+	VaultK8STokenFile     string        `envconfig:"VAULT_K8S_TOKEN_FILE" default:"/var/run/secrets/kubernetes.io/serviceaccount/token"`
+	VaultNamespace        string        `envconfig:"VAULT_NAMESPACE"`
+	VaultAppRoleMount     string        `envconfig:"VAULT_APPROLE_MOUNT" default:"approle"`
+	VaultAWSMount         string        `envconfig:"VAULT_AWS_MOUNT" default:"aws"`
+	VaultKVMount          string        `envconfig:"VAULT_KV_MOUNT" default:"secret"`
+	VaultProjectPrefix    string        `envconfig:"VAULT_PROJECT_PREFIX" default:"argo-cloudops-projects"`
+	VaultTimeout          time.Duration `split_words:"true" default:"10s"`
+	VaultReadRetries      int           `split_words:"true" default:"2"`
+	VaultRetryBackoff     time.Duration `split_words:"true" default:"250ms"`
+	CredentialsProvider   string        `split_words:"true" default:"vault"`
+	StaticCredentialsFile string        `split_words:"true"`
+	STSEndpoint           string        `envconfig:"STS_ENDPOINT"`
+	STSRegion             string        `envconfig:"STS_REGION" default:"us-east-1"`
+	ArgoAddress           string        `envconfig:"ARGO_ADDR" required:"true"`
+	ArgoNamespace         string        `envconfig:"WORKFLOW_EXECUTION_NAMESPACE" default:"argo"`
+	ConfigFilePath        string        `envconfig:"CONFIG" default:"cello.yaml"`
+	SSHPEMFile            string        `envconfig:"SSH_PEM_FILE"`
+	GitAuthMethod         string        `split_words:"true" required:"true"`
+	GitHTTPSUser          string        `envconfig:"GIT_HTTPS_USER"`
+	GitHTTPSPass          string        `envconfig:"GIT_HTTPS_PASS"`
+	GitTimeout            time.Duration `split_words:"true" default:"2m"`
+	GitFetchDepth         int           `split_words:"true" default:"1"`
+	GitCacheDir           string        `split_words:"true"`
+	GitCacheMaxSizeMB     int64         `split_words:"true" default:"5120"`
+	GitMaxManifestSizeKB  int64         `split_words:"true" default:"1024"`
+	GitTrustedGPGKeysFile string        `envconfig:"GIT_TRUSTED_GPG_KEYS_FILE"`
+	GitTrustedSSHKeysFile string        `envconfig:"GIT_TRUSTED_SSH_KEYS_FILE"`
+	LogLevel              string        `split_words:"true"`
+	Port                  int           `default:"8443"`
+	TokenLimit            int           `split_words:"true" default:"2"`
+	TokenMaxTTL           time.Duration `split_words:"true" default:"8776h"`
+	ReconcileInterval     time.Duration `split_words:"true" default:"1h"`
+	ReconcileRepair       bool          `split_words:"true"`
+	BatchPollInterval     time.Duration `split_words:"true" default:"10s"`
+	ImageURIs             []string      `envconfig:"IMAGE_URIS"`
+
+	DBVars
+}
+
+// DBVars are the database settings, which are all the migrate subcommand
+// needs.
+type DBVars struct {
+	DBDriver          string        `split_words:"true" default:"postgres"`
+	DBHost            string        `split_words:"true"`
+	DBUser            string        `split_words:"true"`
+	DBPassword        string        `split_words:"true"`
+	DBName            string        `split_words:"true" required:"true"`
+	DBOptions         string        `split_words:"true"`
+	DBMaxOpenConns    int           `split_words:"true" default:"20"`
+	DBMaxIdleConns    int           `split_words:"true" default:"5"`
+	DBConnMaxLifetime time.Duration `split_words:"true" default:"30m"`
+	DBConnMaxIdleTime time.Duration `split_words:"true" default:"5m"`
+	DBMigrate         bool          `split_words:"true"`
 }
 
 var (
@@ -55,10 +96,78 @@ func GetEnv() (Vars, error) {
 	return instance, err
 }
 
+// GetDBEnv returns the database settings only.
+func GetDBEnv() (DBVars, error) {
+	var vars DBVars
+	if err := envconfig.Process(appPrefix, &vars); err != nil {
+		return DBVars{}, err
+	}
+	return vars, vars.validate()
+}
+
 func (values Vars) validate() error {
 	if len(values.AdminSecret) < 16 {
 		return errors.New("admin secret must be at least 16 characers long")
 	}
+
+	if values.TokenLimit < 1 {
+		return errors.New("token limit must be at least 1")
+	}
+
+	if values.TokenMaxTTL <= 0 {
+		return errors.New("token max ttl must be a positive duration")
+	}
+
+	if values.BatchPollInterval <= 0 {
+		return errors.New("batch poll interval must be a positive duration")
+	}
+
+	if values.CredentialsProvider == "postgres" && values.DBDriver != "postgres" {
+		return errors.New("the postgres credentials provider requires the postgres db driver")
+	}
+
+	if values.CredentialsProvider == "vault" {
+		if values.VaultAddress == "" {
+			return errors.New("vault address is required with the vault credentials provider")
+		}
+
+		if values.VaultK8SRole == "" && (values.VaultRole == "" || values.VaultSecret == "") {
+			return errors.New("vault kubernetes role, or approle role and secret, are required with the vault credentials provider")
+		}
+	}
+
+	if values.VaultTimeout < 0 || values.VaultReadRetries < 0 || values.VaultRetryBackoff < 0 {
+		return errors.New("vault timeout, read retries and retry backoff cannot be negative")
+	}
+
+	if values.GitTimeout < 0 || values.GitFetchDepth < 0 || values.GitCacheMaxSizeMB < 0 || values.GitMaxManifestSizeKB < 0 {
+		return errors.New("git timeout, fetch depth, cache max size and max manifest size cannot be negative")
+	}
+
+	if err := values.DBVars.validate(); err != nil {
+		return err
+	}
+
+	if values.ReconcileInterval < 0 {
+		return errors.New("reconcile interval cannot be negative")
+	}
+	return nil
+}
+
+func (values DBVars) validate() error {
+	switch values.DBDriver {
+	case "postgres":
+		if values.DBHost == "" || values.DBUser == "" {
+			return errors.New("db host and user are required with the postgres db driver")
+		}
+	case "sqlite":
+	default:
+		return errors.New("db driver must be postgres or sqlite")
+	}
+
+	if values.DBMaxOpenConns < 0 || values.DBMaxIdleConns < 0 || values.DBConnMaxLifetime < 0 || values.DBConnMaxIdleTime < 0 {
+		return errors.New("db pool sizes and connection lifetimes cannot be negative")
+	}
 	return nil
 }
 
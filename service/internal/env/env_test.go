@@ -4,6 +4,7 @@ import (
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -18,21 +19,46 @@ var prefixedEnvVars = map[string]string{
 	"_GIT_AUTH_METHOD":              "https",
 	"_GIT_HTTPS_USER":               "testuser",
 	"_GIT_HTTPS_PASS":               "testpass",
+	"_GIT_TIMEOUT":                  "30s",
+	"_GIT_FETCH_DEPTH":              "10",
+	"_GIT_CACHE_DIR":                "/app/test/git",
+	"_GIT_CACHE_MAX_SIZE_MB":        "1024",
+	"_GIT_MAX_MANIFEST_SIZE_KB":     "64",
 	"_LOG_LEVEL":                    "DEBUG",
 	"_PORT":                         "1234",
+	"_TOKEN_LIMIT":                  "5",
+	"_TOKEN_MAX_TTL":                "720h",
+	"_RECONCILE_INTERVAL":           "10m",
+	"_RECONCILE_REPAIR":             "true",
+	"_BATCH_POLL_INTERVAL":          "30s",
 	"_DB_HOST":                      "localhost",
 	"_DB_NAME":                      "argocloudops",
 	"_DB_USER":                      "argoco",
 	"_DB_PASSWORD":                  "1234",
 	"_DB_OPTIONS":                   "sslrootcert=rds-ca.pem sslmode=verify-full",
+	"_CREDENTIALS_PROVIDER":         "postgres",
+	"_STATIC_CREDENTIALS_FILE":      "/app/test/credentials.yaml",
+	"_VAULT_TIMEOUT":                "5s",
+	"_VAULT_READ_RETRIES":           "4",
+	"_VAULT_RETRY_BACKOFF":          "1s",
+	"_DB_MAX_OPEN_CONNS":            "50",
+	"_DB_MAX_IDLE_CONNS":            "10",
+	"_DB_CONN_MAX_LIFETIME":         "1h",
+	"_DB_CONN_MAX_IDLE_TIME":        "10m",
+	"_DB_MIGRATE":                   "true",
 }
 
 var nonPrefixedEnvVars = map[string]string{
-	"VAULT_ROLE":   "vaultRole",
-	"VAULT_SECRET": testSecret,
-	"VAULT_ADDR":   "1.2.3.4",
-	"ARGO_ADDR":    "2.3.4.5",
-	"SSH_PEM_FILE": "/app/test/ssh.pem",
+	"VAULT_ROLE":           "vaultRole",
+	"VAULT_SECRET":         testSecret,
+	"VAULT_ADDR":           "1.2.3.4",
+	"VAULT_K8S_ROLE":       "cello",
+	"VAULT_K8S_MOUNT":      "k8s",
+	"VAULT_K8S_TOKEN_FILE": "/app/test/token",
+	"ARGO_ADDR":            "2.3.4.5",
+	"SSH_PEM_FILE":         "/app/test/ssh.pem",
+	"STS_ENDPOINT":         "http://localhost:4566",
+	"STS_REGION":           "us-west-2",
 }
 
 func reset() {
@@ -68,19 +94,44 @@ func TestGetEnv(t *testing.T) {
 	assert.Equal(t, "vaultRole", vars.VaultRole)
 	assert.Equal(t, testSecret, vars.VaultSecret)
 	assert.Equal(t, "1.2.3.4", vars.VaultAddress)
+	assert.Equal(t, "cello", vars.VaultK8SRole)
+	assert.Equal(t, "k8s", vars.VaultK8SMount)
+	assert.Equal(t, "/app/test/token", vars.VaultK8STokenFile)
+	assert.Equal(t, 5*time.Second, vars.VaultTimeout)
+	assert.Equal(t, 4, vars.VaultReadRetries)
+	assert.Equal(t, time.Second, vars.VaultRetryBackoff)
 	assert.Equal(t, "argo-ns", vars.ArgoNamespace)
 	assert.Equal(t, "/app/test/config/path", vars.ConfigFilePath)
 	assert.Equal(t, "/app/test/ssh.pem", vars.SSHPEMFile)
 	assert.Equal(t, "https", vars.GitAuthMethod)
 	assert.Equal(t, "testuser", vars.GitHTTPSUser)
 	assert.Equal(t, "testpass", vars.GitHTTPSPass)
+	assert.Equal(t, 30*time.Second, vars.GitTimeout)
+	assert.Equal(t, 10, vars.GitFetchDepth)
+	assert.Equal(t, "/app/test/git", vars.GitCacheDir)
+	assert.Equal(t, int64(1024), vars.GitCacheMaxSizeMB)
+	assert.Equal(t, int64(64), vars.GitMaxManifestSizeKB)
 	assert.Equal(t, "DEBUG", vars.LogLevel)
 	assert.Equal(t, 1234, vars.Port)
+	assert.Equal(t, 5, vars.TokenLimit)
+	assert.Equal(t, 720*time.Hour, vars.TokenMaxTTL)
+	assert.Equal(t, 10*time.Minute, vars.ReconcileInterval)
+	assert.True(t, vars.ReconcileRepair)
+	assert.Equal(t, 30*time.Second, vars.BatchPollInterval)
 	assert.Equal(t, "localhost", vars.DBHost)
 	assert.Equal(t, "argocloudops", vars.DBName)
 	assert.Equal(t, "argoco", vars.DBUser)
 	assert.Equal(t, "1234", vars.DBPassword)
 	assert.Equal(t, "sslrootcert=rds-ca.pem sslmode=verify-full", vars.DBOptions)
+	assert.Equal(t, 50, vars.DBMaxOpenConns)
+	assert.Equal(t, 10, vars.DBMaxIdleConns)
+	assert.Equal(t, time.Hour, vars.DBConnMaxLifetime)
+	assert.Equal(t, 10*time.Minute, vars.DBConnMaxIdleTime)
+	assert.True(t, vars.DBMigrate)
+	assert.Equal(t, "postgres", vars.CredentialsProvider)
+	assert.Equal(t, "/app/test/credentials.yaml", vars.StaticCredentialsFile)
+	assert.Equal(t, "http://localhost:4566", vars.STSEndpoint)
+	assert.Equal(t, "us-west-2", vars.STSRegion)
 }
 
 func TestDefaults(t *testing.T) {
@@ -92,14 +143,38 @@ func TestDefaults(t *testing.T) {
 	os.Setenv("VAULT_ADDR", "1.2.3.4")
 	os.Setenv("ARGO_ADDR", "2.3.4.5")
 	os.Setenv(appPrefix+"_GIT_AUTH_METHOD", "https")
+	os.Setenv(appPrefix+"_DB_HOST", "localhost")
+	os.Setenv(appPrefix+"_DB_NAME", "argocloudops")
+	os.Setenv(appPrefix+"_DB_USER", "argoco")
+	os.Setenv(appPrefix+"_DB_PASSWORD", "1234")
 
 	// When
 	vars, _ := GetEnv()
 
 	// Then
 	assert.Equal(t, "argo", vars.ArgoNamespace)
+	assert.Equal(t, "secret", vars.VaultKVMount)
 	assert.Equal(t, "cello.yaml", vars.ConfigFilePath)
 	assert.Equal(t, 8443, vars.Port)
+	assert.Equal(t, 2, vars.TokenLimit)
+	assert.Equal(t, 8776*time.Hour, vars.TokenMaxTTL)
+	assert.Equal(t, 10*time.Second, vars.VaultTimeout)
+	assert.Equal(t, 2, vars.VaultReadRetries)
+	assert.Equal(t, 250*time.Millisecond, vars.VaultRetryBackoff)
+	assert.Equal(t, 2*time.Minute, vars.GitTimeout)
+	assert.Equal(t, 1, vars.GitFetchDepth)
+	assert.Equal(t, int64(5120), vars.GitCacheMaxSizeMB)
+	assert.Equal(t, int64(1024), vars.GitMaxManifestSizeKB)
+	assert.Equal(t, time.Hour, vars.ReconcileInterval)
+	assert.False(t, vars.ReconcileRepair)
+	assert.Equal(t, 10*time.Second, vars.BatchPollInterval)
+	assert.Equal(t, 20, vars.DBMaxOpenConns)
+	assert.Equal(t, 5, vars.DBMaxIdleConns)
+	assert.Equal(t, 30*time.Minute, vars.DBConnMaxLifetime)
+	assert.Equal(t, 5*time.Minute, vars.DBConnMaxIdleTime)
+	assert.False(t, vars.DBMigrate)
+	assert.Equal(t, "vault", vars.CredentialsProvider)
+	assert.Equal(t, "us-east-1", vars.STSRegion)
 }
 
 func TestValidations(t *testing.T) {
@@ -119,6 +194,152 @@ func TestValidations(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestVaultVarsRequiredWithVaultProvider(t *testing.T) {
+	// Given
+	reset()
+	os.Setenv(appPrefix+"_ADMIN_SECRET", testSecret)
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv(appPrefix+"_GIT_AUTH_METHOD", "https")
+	os.Setenv(appPrefix+"_DB_HOST", "localhost")
+	os.Setenv(appPrefix+"_DB_NAME", "argocloudops")
+	os.Setenv(appPrefix+"_DB_USER", "argoco")
+	os.Setenv(appPrefix+"_DB_PASSWORD", "1234")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "vault address is required with the vault credentials provider")
+
+	// Given
+	instance = Vars{}
+	once = sync.Once{}
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("VAULT_ROLE", "vaultRole")
+
+	// When
+	_, err = GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "vault kubernetes role, or approle role and secret, are required with the vault credentials provider")
+
+	// Given
+	instance = Vars{}
+	once = sync.Once{}
+	os.Setenv("VAULT_K8S_ROLE", "cello")
+
+	// When
+	vars, err := GetEnv()
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "kubernetes", vars.VaultK8SMount)
+	assert.Equal(t, "/var/run/secrets/kubernetes.io/serviceaccount/token", vars.VaultK8STokenFile)
+
+	// Given
+	instance = Vars{}
+	once = sync.Once{}
+	os.Unsetenv("VAULT_ADDR")
+	os.Setenv(appPrefix+"_CREDENTIALS_PROVIDER", "postgres")
+
+	// When
+	_, err = GetEnv()
+
+	// Then
+	assert.NoError(t, err)
+}
+
+func TestGetDBEnv(t *testing.T) {
+	// Given
+	reset()
+	os.Setenv(appPrefix+"_DB_HOST", "localhost")
+	os.Setenv(appPrefix+"_DB_NAME", "argocloudops")
+	os.Setenv(appPrefix+"_DB_USER", "argoco")
+	os.Setenv(appPrefix+"_DB_PASSWORD", "1234")
+
+	// When
+	vars, err := GetDBEnv()
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", vars.DBHost)
+	assert.Equal(t, 20, vars.DBMaxOpenConns)
+	assert.Equal(t, "postgres", vars.DBDriver)
+
+	// Given
+	os.Setenv(appPrefix+"_DB_MAX_OPEN_CONNS", "-1")
+	defer os.Unsetenv(appPrefix + "_DB_MAX_OPEN_CONNS")
+
+	// When
+	_, err = GetDBEnv()
+
+	// Then
+	assert.EqualError(t, err, "db pool sizes and connection lifetimes cannot be negative")
+}
+
+func TestDBDriver(t *testing.T) {
+	// Given
+	reset()
+	os.Setenv(appPrefix+"_DB_NAME", "cello.db")
+	os.Setenv(appPrefix+"_DB_DRIVER", "sqlite")
+	defer os.Unsetenv(appPrefix + "_DB_DRIVER")
+
+	// When
+	vars, err := GetDBEnv()
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", vars.DBDriver)
+	assert.Equal(t, "cello.db", vars.DBName)
+
+	// Given
+	os.Setenv(appPrefix+"_DB_DRIVER", "postgres")
+
+	// When
+	_, err = GetDBEnv()
+
+	// Then
+	assert.EqualError(t, err, "db host and user are required with the postgres db driver")
+
+	// Given
+	os.Setenv(appPrefix+"_DB_DRIVER", "mysql")
+
+	// When
+	_, err = GetDBEnv()
+
+	// Then
+	assert.EqualError(t, err, "db driver must be postgres or sqlite")
+
+	// Given
+	instance = Vars{}
+	once = sync.Once{}
+	os.Setenv(appPrefix+"_DB_DRIVER", "sqlite")
+	os.Setenv(appPrefix+"_ADMIN_SECRET", testSecret)
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv(appPrefix+"_GIT_AUTH_METHOD", "https")
+	os.Setenv(appPrefix+"_CREDENTIALS_PROVIDER", "postgres")
+
+	// When
+	_, err = GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "the postgres credentials provider requires the postgres db driver")
+}
+
+func TestGitVars(t *testing.T) {
+	// Given
+	reset()
+	setEnvVars(prefixedEnvVars, appPrefix)
+	setEnvVars(nonPrefixedEnvVars, "")
+	os.Setenv(appPrefix+"_GIT_FETCH_DEPTH", "-1")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "git timeout, fetch depth, cache max size and max manifest size cannot be negative")
+}
+
 func TestRequiredVars(t *testing.T) {
 	// Given
 	reset()
@@ -0,0 +1,298 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	git "github.com/go-git/go-git/v5"
+)
+
+// cacheMarkerFile marks the directories of the cache directory which are
+// repositories cloned by the client.
+const cacheMarkerFile = ".cello-cache"
+
+// defaultCredentialsID identifies the credentials of the client.
+const defaultCredentialsID = "default"
+
+// ErrRepositoryNotCached conveys that a repository isn't in the cache
+// directory.
+var ErrRepositoryNotCached = errors.New("repository not cached")
+
+// CachedRepository is a repository in the cache directory.
+type CachedRepository struct {
+	Name       string
+	Repository string
+	Size       int64
+	LastUsed   time.Time
+}
+
+// repoCache tracks the size and last use of the repositories in the cache
+// directory, so the least recently used repositories can be evicted when the
+// cache is over its max size.
+type repoCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	repos   map[string]*CachedRepository
+}
+
+func newRepoCache() *repoCache {
+	return &repoCache{repos: map[string]*CachedRepository{}}
+}
+
+// touch records the use of the repository.
+func (c *repoCache) touch(repPath, repository string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	repo, ok := c.repos[repPath]
+	if !ok {
+		repo = &CachedRepository{Name: repPath, Repository: repository}
+		c.repos[repPath] = repo
+	}
+	repo.LastUsed = now
+}
+
+// setSize records the size of the repository, which changes when it's
+// fetched.
+func (c *repoCache) setSize(repPath string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if repo, ok := c.repos[repPath]; ok {
+		repo.Size = size
+	}
+}
+
+func (c *repoCache) remove(repPath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.repos, repPath)
+}
+
+// list returns the repositories, most recently used first.
+func (c *repoCache) list() []CachedRepository {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	repos := make([]CachedRepository, 0, len(c.repos))
+	for _, repo := range c.repos {
+		repos = append(repos, *repo)
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].LastUsed.Equal(repos[j].LastUsed) {
+			return repos[i].Name < repos[j].Name
+		}
+		return repos[i].LastUsed.After(repos[j].LastUsed)
+	})
+	return repos
+}
+
+// evictions returns the least recently used repositories to evict for the
+// cache to fit its max size, other than the repository in use.
+func (c *repoCache) evictions(inUse string) []string {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	repos := c.list()
+
+	var total int64
+	for _, repo := range repos {
+		total += repo.Size
+	}
+
+	var evict []string
+	for i := len(repos) - 1; i >= 0 && total > c.maxSize; i-- {
+		if repos[i].Name == inUse {
+			continue
+		}
+		evict = append(evict, repos[i].Name)
+		total -= repos[i].Size
+	}
+	return evict
+}
+
+// InitCache creates the cache directory and loads the repositories cloned
+// by earlier runs. Repositories which can't be opened, like partial clones of
+// runs which stopped while cloning, are removed, as are repositories cached
+// before they were cached apart per credentials. Directories which weren't
+// created by the client are left as they are. The least recently used
+// repositories are evicted when the cache is over its max size.
+func (g BasicClient) InitCache() error {
+	if err := os.MkdirAll(g.baseDir, 0o750); err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(g.fs, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		repPath := entry.Name()
+		filePath := filepath.Join(g.baseDir, repPath)
+
+		if _, err := fs.Stat(g.fs, path.Join(repPath, cacheMarkerFile)); err != nil {
+			continue
+		}
+
+		// Repositories cached without their credentials may hold commits
+		// other credentials can't access.
+		repository, err := g.remoteURL(filePath)
+		if err != nil || !strings.HasPrefix(repPath, repositoryPath(repository)+"-") {
+			if err := os.RemoveAll(filePath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		g.cache.touch(repPath, repository, info.ModTime())
+		g.cache.setSize(repPath, g.repositorySize(repPath))
+	}
+
+	g.evict("")
+	return nil
+}
+
+// ListCachedRepositories returns the repositories in the cache directory,
+// most recently used first.
+func (g BasicClient) ListCachedRepositories() []CachedRepository {
+	return g.cache.list()
+}
+
+// PurgeCachedRepository removes the repository, as cached with any
+// credentials, from the cache directory. It waits for reads and fetches of
+// the repository to finish.
+func (g BasicClient) PurgeCachedRepository(repository string) error {
+	purged := false
+	for _, cached := range g.cache.list() {
+		if cached.Repository != repository {
+			continue
+		}
+
+		if err := g.purge(cached.Name); err != nil {
+			return err
+		}
+		purged = true
+	}
+
+	if !purged {
+		return ErrRepositoryNotCached
+	}
+	return nil
+}
+
+func (g BasicClient) purge(repPath string) error {
+	lock := g.locks.get(repPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.RemoveAll(filepath.Join(g.baseDir, repPath)); err != nil {
+		return err
+	}
+
+	g.cache.remove(repPath)
+	return nil
+}
+
+// cachePath returns the directory the repository is cached in. Repositories
+// are cached apart per credentials, so commits fetched with the credentials
+// of a project aren't read with other credentials.
+func cachePath(repo Repository) string {
+	return repositoryPath(repo.URL) + "-" + credentialsID(repo.Credentials)
+}
+
+func repositoryPath(repository string) string {
+	return strings.ReplaceAll(repository, "/", "")
+}
+
+// credentialsID identifies the credentials without revealing them.
+func credentialsID(c types.GitCredentials) string {
+	if c.IsEmpty() {
+		return defaultCredentialsID
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{c.SSHPrivateKey, c.HTTPSUsername, c.HTTPSPassword}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// used records the use of the repository. The modification time of the
+// repository directory is updated, so it's loaded by later runs.
+func (g BasicClient) used(repository, repPath string) {
+	now := time.Now()
+	g.cache.touch(repPath, repository, now)
+	_ = os.Chtimes(filepath.Join(g.baseDir, repPath), now, now)
+}
+
+// evict removes the least recently used repositories over the max size of the
+// cache. Repositories which are being read or fetched are skipped.
+func (g BasicClient) evict(inUse string) {
+	for _, repPath := range g.cache.evictions(inUse) {
+		lock := g.locks.get(repPath)
+		if !lock.TryLock() {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(g.baseDir, repPath)); err == nil {
+			g.cache.remove(repPath)
+		}
+		lock.Unlock()
+	}
+}
+
+// remoteURL returns the URL of the remote of the repository.
+func (g BasicClient) remoteURL(filePath string) (string, error) {
+	repo, err := g.git.PlainOpen(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return "", err
+	}
+
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return "", errors.New("remote has no url")
+	}
+	return urls[0], nil
+}
+
+// repositorySize returns the size of the files of the repository.
+func (g BasicClient) repositorySize(repPath string) int64 {
+	var size int64
+	_ = fs.WalkDir(g.fs, repPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,253 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newCacheTestClient returns a client caching repositories in a temporary
+// directory.
+func newCacheTestClient(t *testing.T, dir string, opts ...Option) BasicClient {
+	t.Helper()
+
+	cl := newBasicClient(nil, append([]Option{WithCacheDir(dir)}, opts...)...)
+	if err := cl.InitCache(); err != nil {
+		t.Fatal(err)
+	}
+	return cl
+}
+
+// cacheTestRemote returns a remote repository and the hash of its commit.
+func cacheTestRemote(t *testing.T) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	_, hash := newTestRepo(t, dir, map[string]string{"manifest.yaml": "manifest"})
+	return dir, hash.String()
+}
+
+func cachedNames(cl BasicClient) []string {
+	var names []string
+	for _, repo := range cl.ListCachedRepositories() {
+		names = append(names, repo.Name)
+	}
+	return names
+}
+
+func TestInitCache(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	remote, hash := cacheTestRemote(t)
+
+	cl := newCacheTestClient(t, cacheDir)
+	_, err := cl.GetManifestFile(context.Background(), Repository{URL: remote}, hash, "", "manifest.yaml")
+	assertNoErr(t, err)
+
+	// Repositories cached before they were cached apart per credentials
+	// are removed.
+	legacyRemote, legacyHash := cacheTestRemote(t)
+	_, err = cl.GetManifestFile(context.Background(), Repository{URL: legacyRemote}, legacyHash, "", "manifest.yaml")
+	assertNoErr(t, err)
+	legacy := filepath.Join(cacheDir, repositoryPath(legacyRemote))
+	assertNoErr(t, os.Rename(filepath.Join(cacheDir, cachePath(Repository{URL: legacyRemote})), legacy))
+
+	// Partial clones can't be opened, while files and directories which
+	// weren't created by the client are left as they are.
+	broken := filepath.Join(cacheDir, "broken")
+	assertNoErr(t, os.MkdirAll(filepath.Join(broken, "objects"), 0o750))
+	assertNoErr(t, os.WriteFile(filepath.Join(broken, cacheMarkerFile), nil, 0o600))
+	unknown := filepath.Join(cacheDir, "unknown")
+	assertNoErr(t, os.MkdirAll(filepath.Join(unknown, "objects"), 0o750))
+	other, _ := cacheTestRemote(t)
+	assertNoErr(t, os.Rename(other, filepath.Join(cacheDir, "other")))
+	assertNoErr(t, os.WriteFile(filepath.Join(cacheDir, "file"), []byte("file"), 0o600))
+
+	cl = newCacheTestClient(t, cacheDir)
+
+	for _, removed := range []string{broken, legacy} {
+		if _, err := os.Stat(removed); !os.IsNotExist(err) {
+			t.Errorf("want %s removed, got: %v\n", removed, err)
+		}
+	}
+	for _, kept := range []string{"unknown", "other", "file"} {
+		if _, err := os.Stat(filepath.Join(cacheDir, kept)); err != nil {
+			t.Errorf("want %s kept, got: %v\n", kept, err)
+		}
+	}
+
+	repos := cl.ListCachedRepositories()
+	if len(repos) != 1 {
+		t.Fatalf("want 1 cached repository, got: %+v\n", repos)
+	}
+
+	repo := repos[0]
+	if repo.Name != cachePath(Repository{URL: remote}) || repo.Repository != remote {
+		t.Errorf("want repository %s, got: %+v\n", remote, repo)
+	}
+	if repo.Size == 0 || repo.LastUsed.IsZero() {
+		t.Errorf("want size and last use, got: %+v\n", repo)
+	}
+
+	// Cached repositories are read without fetching.
+	got, err := cl.GetManifestFile(context.Background(), Repository{URL: remote}, hash, "", "manifest.yaml")
+	assertNoErr(t, err)
+	if string(got) != "manifest" {
+		t.Errorf("want: manifest got: %s\n", got)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	cacheDir := t.TempDir()
+	first, firstHash := cacheTestRemote(t)
+	second, secondHash := cacheTestRemote(t)
+	firstName := cachePath(Repository{URL: first})
+	secondName := cachePath(Repository{URL: second})
+
+	t.Run("evicts least recently used repositories", func(t *testing.T) {
+		cl := newCacheTestClient(t, cacheDir, WithCacheMaxSize(1))
+
+		_, err := cl.GetManifestFile(context.Background(), Repository{URL: first}, firstHash, "", "manifest.yaml")
+		assertNoErr(t, err)
+
+		// Repositories in use are kept, even over the max size.
+		if got := cachedNames(cl); !cmp.Equal(got, []string{firstName}) {
+			t.Errorf("want: %v got: %v\n", []string{firstName}, got)
+		}
+
+		_, err = cl.GetManifestFile(context.Background(), Repository{URL: second}, secondHash, "", "manifest.yaml")
+		assertNoErr(t, err)
+
+		if got := cachedNames(cl); !cmp.Equal(got, []string{secondName}) {
+			t.Errorf("want: %v got: %v\n", []string{secondName}, got)
+		}
+		if _, err := os.Stat(filepath.Join(cacheDir, firstName)); !os.IsNotExist(err) {
+			t.Errorf("want evicted repository removed, got: %v\n", err)
+		}
+	})
+
+	t.Run("skips repositories being read", func(t *testing.T) {
+		cl := newCacheTestClient(t, cacheDir)
+		WithCacheMaxSize(1)(&cl)
+
+		lock := cl.locks.get(secondName)
+		lock.RLock()
+		_, err := cl.GetManifestFile(context.Background(), Repository{URL: first}, firstHash, "", "manifest.yaml")
+		lock.RUnlock()
+		assertNoErr(t, err)
+
+		if got := cachedNames(cl); !cmp.Equal(got, []string{firstName, secondName}) {
+			t.Errorf("want: %v got: %v\n", []string{firstName, secondName}, got)
+		}
+	})
+
+	t.Run("keeps repositories without max size", func(t *testing.T) {
+		cl := newCacheTestClient(t, cacheDir)
+
+		_, err := cl.GetManifestFile(context.Background(), Repository{URL: second}, secondHash, "", "manifest.yaml")
+		assertNoErr(t, err)
+
+		if got := cachedNames(cl); !cmp.Equal(got, []string{secondName, firstName}) {
+			t.Errorf("want: %v got: %v\n", []string{secondName, firstName}, got)
+		}
+	})
+}
+
+func TestRepoCacheEvictions(t *testing.T) {
+	now := time.Now()
+	cache := newRepoCache()
+	cache.maxSize = 10
+	for i, size := range []int64{4, 4, 4, 4} {
+		name := string(rune('a' + i))
+		cache.touch(name, name, now.Add(time.Duration(i)*time.Minute))
+		cache.setSize(name, size)
+	}
+
+	tests := []struct {
+		name  string
+		inUse string
+		want  []string
+	}{
+		{
+			name: "evicts least recently used",
+			want: []string{"a", "b"},
+		},
+		{
+			name:  "skips repository in use",
+			inUse: "a",
+			want:  []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cache.evictions(tt.inUse); !cmp.Equal(got, tt.want) {
+				t.Errorf("want: %v got: %v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCacheCredentials(t *testing.T) {
+	cacheDir := t.TempDir()
+	remote, hash := cacheTestRemote(t)
+	first := Repository{URL: remote, Credentials: types.GitCredentials{HTTPSUsername: "first", HTTPSPassword: "token"}}
+	second := Repository{URL: remote, Credentials: types.GitCredentials{HTTPSUsername: "second", HTTPSPassword: "token"}}
+
+	cl := newCacheTestClient(t, cacheDir)
+	_, err := cl.GetManifestFile(context.Background(), first, hash, "", "manifest.yaml")
+	assertNoErr(t, err)
+
+	// The remote is no longer accessible, like a private repository the
+	// credentials of another project can't access.
+	assertNoErr(t, os.RemoveAll(remote))
+
+	// Commits fetched with the credentials of a project aren't read with
+	// other credentials.
+	if _, err := cl.GetManifestFile(context.Background(), second, hash, "", "manifest.yaml"); err == nil {
+		t.Error("want error reading with other credentials, got nil")
+	}
+	if _, err := cl.GetManifestFile(context.Background(), Repository{URL: remote}, hash, "", "manifest.yaml"); err == nil {
+		t.Error("want error reading with the client credentials, got nil")
+	}
+
+	got, err := cl.GetManifestFile(context.Background(), first, hash, "", "manifest.yaml")
+	assertNoErr(t, err)
+	if string(got) != "manifest" {
+		t.Errorf("want: manifest got: %s\n", got)
+	}
+
+	// Purging removes the repository as cached with any credentials.
+	assertNoErr(t, cl.PurgeCachedRepository(remote))
+	if repos := cl.ListCachedRepositories(); len(repos) != 0 {
+		t.Errorf("want no cached repositories, got: %+v\n", repos)
+	}
+}
+
+func TestPurgeCachedRepository(t *testing.T) {
+	cacheDir := t.TempDir()
+	remote, hash := cacheTestRemote(t)
+
+	cl := newCacheTestClient(t, cacheDir)
+	_, err := cl.GetManifestFile(context.Background(), Repository{URL: remote}, hash, "", "manifest.yaml")
+	assertNoErr(t, err)
+
+	assertNoErr(t, cl.PurgeCachedRepository(remote))
+
+	if _, err := os.Stat(filepath.Join(cacheDir, cachePath(Repository{URL: remote}))); !os.IsNotExist(err) {
+		t.Errorf("want purged repository removed, got: %v\n", err)
+	}
+	if repos := cl.ListCachedRepositories(); len(repos) != 0 {
+		t.Errorf("want no cached repositories, got: %+v\n", repos)
+	}
+
+	if err := cl.PurgeCachedRepository(remote); !errors.Is(err, ErrRepositoryNotCached) {
+		t.Errorf("want: %v got: %v\n", ErrRepositoryNotCached, err)
+	}
+}
@@ -3,55 +3,136 @@
 package git
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/internal/validations"
 )
 
 // Client allows for retrieving data from git repo
 type Client interface {
-	GetManifestFile(repository, commitHash, path string) ([]byte, error)
+	GetManifestFile(ctx context.Context, repo Repository, commitHash, root, path string) ([]byte, error)
+	VerifyCommit(ctx context.Context, repo Repository, commitHash string) (Signature, error)
+	ResolveRef(ctx context.Context, repo Repository, ref string) (string, error)
+	ListCachedRepositories() []CachedRepository
+	PurgeCachedRepository(repository string) error
 }
 
 type gitSvc interface {
-	PlainClone(path string, isBare bool, o *git.CloneOptions) (*git.Repository, error)
+	PlainInit(path string, isBare bool) (*git.Repository, error)
+	MarkCached(path string) error
+	CreateRemote(r *git.Repository, c *config.RemoteConfig) error
 	PlainOpen(path string) (*git.Repository, error)
-	Fetch(r *git.Repository, o *git.FetchOptions) error
-	Worktree(r *git.Repository) (*git.Worktree, error)
-	Checkout(w *git.Worktree, opts *git.CheckoutOptions) error
+	FetchContext(ctx context.Context, r *git.Repository, o *git.FetchOptions) error
+	CommitObject(r *git.Repository, h plumbing.Hash) (*object.Commit, error)
+	ListRemote(ctx context.Context, url string, o *git.ListOptions) ([]*plumbing.Reference, error)
 }
 
 type gitSvcImpl struct{}
 
-func (g gitSvcImpl) PlainClone(path string, isBare bool, o *git.CloneOptions) (*git.Repository, error) {
-	return git.PlainClone(path, isBare, o)
+func (g gitSvcImpl) PlainInit(path string, isBare bool) (*git.Repository, error) {
+	return git.PlainInit(path, isBare)
+}
+
+func (g gitSvcImpl) MarkCached(path string) error {
+	return os.WriteFile(filepath.Join(path, cacheMarkerFile), nil, 0o600)
+}
+
+func (g gitSvcImpl) CreateRemote(r *git.Repository, c *config.RemoteConfig) error {
+	_, err := r.CreateRemote(c)
+	return err
 }
 
 func (g gitSvcImpl) PlainOpen(path string) (*git.Repository, error) {
 	return git.PlainOpen(path)
 }
 
-func (g gitSvcImpl) Fetch(r *git.Repository, o *git.FetchOptions) error {
-	return r.Fetch(o)
+func (g gitSvcImpl) FetchContext(ctx context.Context, r *git.Repository, o *git.FetchOptions) error {
+	return r.FetchContext(ctx, o)
 }
 
-func (g gitSvcImpl) Worktree(r *git.Repository) (*git.Worktree, error) {
-	return r.Worktree()
+func (g gitSvcImpl) CommitObject(r *git.Repository, h plumbing.Hash) (*object.Commit, error) {
+	return r.CommitObject(h)
 }
 
-func (g gitSvcImpl) Checkout(w *git.Worktree, opts *git.CheckoutOptions) error {
-	return w.Checkout(opts)
+func (g gitSvcImpl) ListRemote(ctx context.Context, url string, o *git.ListOptions) ([]*plumbing.Reference, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	})
+	return remote.ListContext(ctx, o)
+}
+
+// Repository is a git repository with the credentials to access it. The
+// credentials of the client are used when the credentials are empty.
+type Repository struct {
+	URL         string
+	Credentials types.GitCredentials
+}
+
+// ErrRefNotFound conveys that a branch, tag or commit doesn't exist in the
+// repository.
+var ErrRefNotFound = errors.New("ref not found")
+
+// ErrAmbiguousRef conveys that an abbreviated commit sha matches several
+// commits of the repository.
+var ErrAmbiguousRef = errors.New("ref is ambiguous")
+
+// ErrInvalidPath conveys that a path, or the symlink it points to, is outside
+// the repository or the manifest root.
+var ErrInvalidPath = errors.New("path is outside the repository")
+
+// ErrManifestTooLarge conveys that a file is over the max manifest size.
+var ErrManifestTooLarge = errors.New("manifest is too large")
+
+// maxSymlinks is the number of symlinks followed when reading a file.
+const maxSymlinks = 10
+
+// errCommitNotFetched conveys that the repository or commit needs to be
+// fetched before files can be read.
+var errCommitNotFetched = errors.New("commit not fetched")
+
+// repoLocks holds a lock per repository. Reads of fetched commits share the
+// lock, while clones and fetches hold it exclusively.
+type repoLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.RWMutex
+}
+
+func newRepoLocks() *repoLocks {
+	return &repoLocks{locks: map[string]*sync.RWMutex{}}
+}
+
+func (l *repoLocks) get(repository string) *sync.RWMutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, ok := l.locks[repository]
+	if !ok {
+		lock = &sync.RWMutex{}
+		l.locks[repository] = lock
+	}
+	return lock
 }
 
 // Option is a function for configuring the BasicClient
@@ -63,14 +144,69 @@ func WithProgressWriter(w io.Writer) Option {
 	}
 }
 
+// WithCacheDir sets the directory repositories are cached in. Only
+// directories created by the client are loaded, or removed, by InitCache.
+func WithCacheDir(dir string) Option {
+	return func(c *BasicClient) {
+		c.baseDir = dir
+		c.fs = os.DirFS(dir)
+	}
+}
+
+// WithCacheMaxSize sets the size in bytes over which the least recently used
+// repositories are evicted from the cache. A zero size never evicts
+// repositories.
+func WithCacheMaxSize(size int64) Option {
+	return func(c *BasicClient) {
+		c.cache.maxSize = size
+	}
+}
+
+// WithTimeout limits how long each fetch of a repository may take. A zero
+// timeout only stops fetches when the request is canceled.
+func WithTimeout(d time.Duration) Option {
+	return func(c *BasicClient) {
+		c.timeout = d
+	}
+}
+
+// WithFetchDepth sets the depth of fetches of a single commit. A zero depth
+// always fetches the full history.
+func WithFetchDepth(depth int) Option {
+	return func(c *BasicClient) {
+		c.depth = depth
+	}
+}
+
+// WithMaxManifestSize sets the size in bytes over which files aren't read. A
+// zero size reads files of any size.
+func WithMaxManifestSize(size int64) Option {
+	return func(c *BasicClient) {
+		c.maxManifestSize = size
+	}
+}
+
+// WithTrustedKeys sets the keys trusted to sign commits verified by
+// VerifyCommit.
+func WithTrustedKeys(k Keyring) Option {
+	return func(c *BasicClient) {
+		c.trustedKeys = k
+	}
+}
+
 // BasicClient connects to git using ssh
 type BasicClient struct {
-	auth    transport.AuthMethod
-	mu      *sync.Mutex
-	git     gitSvc
-	fs      fs.FS
-	baseDir string // base directory to run git operations from
-	pw      io.Writer
+	auth            transport.AuthMethod
+	cache           *repoCache
+	locks           *repoLocks
+	git             gitSvc
+	fs              fs.FS
+	baseDir         string // base directory to run git operations from
+	pw              io.Writer
+	timeout         time.Duration
+	depth           int
+	maxManifestSize int64
+	trustedKeys     Keyring
 }
 
 // NewSSHBasicClient creates a new ssh based git client
@@ -94,13 +230,18 @@ func NewHTTPSBasicClient(user, pass string, opts ...Option) (BasicClient, error)
 }
 
 func newBasicClient(auth transport.AuthMethod, opts ...Option) BasicClient {
+	cacheDir := filepath.Join(os.TempDir(), "cello-git")
 	cl := BasicClient{
-		auth:    auth,
-		mu:      &sync.Mutex{},
-		git:     gitSvcImpl{},
-		fs:      os.DirFS(os.TempDir()),
-		baseDir: os.TempDir(),
-		pw:      io.Discard,
+		auth:            auth,
+		cache:           newRepoCache(),
+		locks:           newRepoLocks(),
+		git:             gitSvcImpl{},
+		fs:              os.DirFS(cacheDir),
+		baseDir:         cacheDir,
+		pw:              io.Discard,
+		timeout:         2 * time.Minute,
+		depth:           1,
+		maxManifestSize: 1 << 20,
 	}
 
 	for _, o := range opts {
@@ -110,63 +251,452 @@ func newBasicClient(auth transport.AuthMethod, opts ...Option) BasicClient {
 	return cl
 }
 
-func (g BasicClient) GetManifestFile(repository, commitHash, path string) ([]byte, error) {
+// GetManifestFile returns the file at the path of the commit. Files are read
+// from the commit tree, so reads of different commits of a repository don't
+// wait for each other. The repository is only fetched when the commit is
+// missing. Paths and symlinks outside the repository, or outside the root
+// when it's not empty, return ErrInvalidPath, and files over the max manifest
+// size return ErrManifestTooLarge.
+func (g BasicClient) GetManifestFile(ctx context.Context, repo Repository, commitHash, root, path string) ([]byte, error) {
+	if !validations.IsInRepositoryRoot(root, path) {
+		return []byte{}, fmt.Errorf("%w: '%s'", ErrInvalidPath, path)
+	}
+
+	var b []byte
+	err := g.withCommit(ctx, repo, commitHash, func(commit *object.Commit) error {
+		var err error
+		b, err = g.readFile(commit, root, path)
+		return err
+	})
+	if err != nil {
+		return []byte{}, err
+	}
+	return b, nil
+}
+
+// VerifyCommit verifies the signature of the commit against the trusted keys,
+// and returns the key which made it. Unsigned commits return
+// ErrUnsignedCommit, and commits which aren't signed by a trusted key return
+// ErrUntrustedSignature.
+func (g BasicClient) VerifyCommit(ctx context.Context, repo Repository, commitHash string) (Signature, error) {
+	var sig Signature
+	err := g.withCommit(ctx, repo, commitHash, func(commit *object.Commit) error {
+		var err error
+		sig, err = g.trustedKeys.Verify(commit)
+		return err
+	})
+	if err != nil {
+		return Signature{}, err
+	}
+	return sig, nil
+}
+
+// withCommit runs fn with the commit of the repository. Commits are read from
+// the cache, so reads of different commits of a repository don't wait for
+// each other. The repository is only fetched when the commit is missing.
+func (g BasicClient) withCommit(ctx context.Context, repo Repository, commitHash string, fn func(*object.Commit) error) error {
+	repository := repo.URL
 	// filePath should only be used for git calls. direct fs calls should use repository directly
-	repPath := strings.ReplaceAll(repository, "/", "")
+	repPath := cachePath(repo)
 	filePath := filepath.Join(g.baseDir, repPath)
+	hash := plumbing.NewHash(commitHash)
 
-	// Locking here since we need to make sure nobody else is using the repo at the same time to ensure the right sha is checked out
-	// TODO: use a lock per repository instead of a single global lock
-	g.mu.Lock()
-	defer g.mu.Unlock()
+	lock := g.locks.get(repPath)
 
-	var repo *git.Repository
+	lock.RLock()
+	commit, err := g.commitObject(repPath, filePath, hash)
+	if !errors.Is(err, errCommitNotFetched) {
+		g.used(repository, repPath)
+		if err == nil {
+			err = fn(commit)
+		}
+		lock.RUnlock()
+		return err
+	}
+	lock.RUnlock()
 
-	if _, err := fs.Stat(g.fs, repPath); os.IsNotExist(err) {
-		// TODO: use context version and make depth configurable
-		repo, err = g.git.PlainClone(filePath, false, &git.CloneOptions{
-			URL:      repository,
-			Auth:     g.auth,
-			Progress: g.pw,
-		})
+	lock.Lock()
+	defer lock.Unlock()
+
+	auth, err := g.authMethod(repo.Credentials)
+	if err != nil {
+		return err
+	}
+
+	err = g.fetchCommit(ctx, auth, repository, repPath, filePath, hash)
+	if _, statErr := fs.Stat(g.fs, repPath); statErr == nil {
+		g.used(repository, repPath)
+		g.cache.setSize(repPath, g.repositorySize(repPath))
+		g.evict(repPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	commit, err = g.commitObject(repPath, filePath, hash)
+	if errors.Is(err, errCommitNotFetched) {
+		return fmt.Errorf("commit %s not found in repository", commitHash)
+	}
+	if err != nil {
+		return err
+	}
+	return fn(commit)
+}
+
+// ResolveRef returns the commit sha of the branch, tag or commit sha of the
+// repository. Full commit shas are returned as they are, while branches and
+// tags are listed from the remote, so they're never resolved from stale
+// fetches. Abbreviated commit shas which aren't branches or tags are resolved
+// from the commits of the remote refs, or else from the history of the
+// branches.
+func (g BasicClient) ResolveRef(ctx context.Context, repo Repository, ref string) (string, error) {
+	if plumbing.IsHash(ref) {
+		return strings.ToLower(ref), nil
+	}
+
+	auth, err := g.authMethod(repo.Credentials)
+	if err != nil {
+		return "", err
+	}
+
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	refs, err := g.git.ListRemote(ctx, repo.URL, &git.ListOptions{
+		Auth:          auth,
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error listing repository refs: %w", err)
+	}
+
+	hashes := map[plumbing.ReferenceName]plumbing.Hash{}
+	targets := map[plumbing.ReferenceName]plumbing.ReferenceName{}
+	for _, r := range refs {
+		if r.Type() == plumbing.SymbolicReference {
+			targets[r.Name()] = r.Target()
+			continue
+		}
+		hashes[r.Name()] = r.Hash()
+	}
+
+	names := []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	if !strings.HasPrefix(ref, "refs/") && ref != string(plumbing.HEAD) {
+		names = []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+
+	for _, name := range names {
+		if target, ok := targets[name]; ok {
+			name = target
+		}
+
+		// Annotated tags are peeled to the commit they point to.
+		if hash, ok := hashes[name+"^{}"]; ok {
+			return hash.String(), nil
+		}
+		if hash, ok := hashes[name]; ok {
+			return hash.String(), nil
+		}
+	}
+
+	if !validations.IsValidCommitSHA(ref) {
+		return "", fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+	}
+
+	prefix := strings.ToLower(ref)
+	matches := map[string]bool{}
+	for _, hash := range hashes {
+		if strings.HasPrefix(hash.String(), prefix) {
+			matches[hash.String()] = true
+		}
+	}
+	if len(matches) == 0 {
+		return g.resolveAbbreviatedSHA(ctx, auth, repo, prefix)
+	}
+	return uniqueSHA(matches, ref)
+}
+
+// resolveAbbreviatedSHA returns the commit sha of the cached repository
+// starting with the prefix. The history of the branches is fetched when no
+// cached commit matches it.
+func (g BasicClient) resolveAbbreviatedSHA(ctx context.Context, auth transport.AuthMethod, r Repository, prefix string) (string, error) {
+	repository := r.URL
+	repPath := cachePath(r)
+	filePath := filepath.Join(g.baseDir, repPath)
+
+	lock := g.locks.get(repPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	repo, err := g.openRepository(repository, repPath, filePath)
+	if err != nil {
+		return "", err
+	}
+
+	matches, err := commitsWithPrefix(repo, prefix)
+	if err != nil || len(matches) > 0 {
+		g.used(repository, repPath)
 		if err != nil {
-			return []byte{}, err
+			return "", err
+		}
+		return uniqueSHA(matches, prefix)
+	}
+
+	err = g.fetch(ctx, repo, &git.FetchOptions{Auth: auth})
+	g.used(repository, repPath)
+	g.cache.setSize(repPath, g.repositorySize(repPath))
+	g.evict(repPath)
+	if err != nil {
+		return "", err
+	}
+
+	matches, err = commitsWithPrefix(repo, prefix)
+	if err != nil {
+		return "", err
+	}
+	return uniqueSHA(matches, prefix)
+}
+
+// commitsWithPrefix returns the shas of the commits of the repository
+// starting with the prefix.
+func commitsWithPrefix(repo *git.Repository, prefix string) (map[string]bool, error) {
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		return nil, err
+	}
+	defer commits.Close()
+
+	matches := map[string]bool{}
+	err = commits.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), prefix) {
+			matches[c.Hash.String()] = true
 		}
-	} else {
-		repo, err = g.git.PlainOpen(filePath)
+		return nil
+	})
+	return matches, err
+}
+
+// uniqueSHA returns the only sha of the matches of the ref.
+func uniqueSHA(matches map[string]bool, ref string) (string, error) {
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+	case 1:
+		for sha := range matches {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrAmbiguousRef, ref)
+}
+
+// ValidateCredentials returns an error when the credentials can't be used to
+// access repositories, like ssh private keys which can't be parsed.
+func ValidateCredentials(c types.GitCredentials) error {
+	if c.SSHPrivateKey == "" {
+		return nil
+	}
+
+	if _, err := ssh.NewPublicKeys("git", []byte(c.SSHPrivateKey), ""); err != nil {
+		return fmt.Errorf("invalid ssh private key: %w", err)
+	}
+	return nil
+}
+
+// authMethod returns the auth method of the credentials, or the auth method
+// of the client when the credentials are empty.
+func (g BasicClient) authMethod(c types.GitCredentials) (transport.AuthMethod, error) {
+	switch {
+	case c.SSHPrivateKey != "":
+		auth, err := ssh.NewPublicKeys("git", []byte(c.SSHPrivateKey), "")
 		if err != nil {
-			return []byte{}, err
+			return nil, fmt.Errorf("invalid ssh private key: %w", err)
 		}
-		err = g.git.Fetch(repo, &git.FetchOptions{
-			Progress: g.pw,
-			Auth:     g.auth,
+		return auth, nil
+	case c.HTTPSUsername != "" || c.HTTPSPassword != "":
+		return &http.BasicAuth{
+			Username: c.HTTPSUsername,
+			Password: c.HTTPSPassword,
+		}, nil
+	default:
+		return g.auth, nil
+	}
+}
+
+// fetchCommit fetches the commit into the repository, which is created when
+// missing. Only the commit is fetched, with limited depth, when the remote
+// allows fetching a commit by its hash. Otherwise the full history of the
+// branches is fetched. The lock of the repository must be held exclusively.
+func (g BasicClient) fetchCommit(ctx context.Context, auth transport.AuthMethod, repository, repPath, filePath string, hash plumbing.Hash) error {
+	repo, err := g.openRepository(repository, repPath, filePath)
+	if err != nil {
+		return err
+	}
+
+	// Another read may have fetched the commit while waiting for the lock.
+	if _, err := g.git.CommitObject(repo, hash); err == nil {
+		return nil
+	}
+
+	if g.depth > 0 {
+		sha := hash.String()
+		err := g.fetch(ctx, repo, &git.FetchOptions{
+			Auth:     auth,
+			RefSpecs: []config.RefSpec{config.RefSpec(sha + ":refs/commits/" + sha)},
+			Depth:    g.depth,
 		})
-		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
-			return []byte{}, err
+		// Fetches which timed out or were canceled aren't retried, as the
+		// full fetch would take even longer.
+		if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
 		}
 	}
 
-	w, err := g.git.Worktree(repo)
+	return g.fetch(ctx, repo, &git.FetchOptions{Auth: auth})
+}
+
+// openRepository opens the repository, or creates it without a worktree, as
+// files are read from the commit tree.
+func (g BasicClient) openRepository(repository, repPath, filePath string) (*git.Repository, error) {
+	if _, err := fs.Stat(g.fs, repPath); !os.IsNotExist(err) {
+		return g.git.PlainOpen(filePath)
+	}
+
+	repo, err := g.git.PlainInit(filePath, true)
+	if err == nil {
+		err = g.git.MarkCached(filePath)
+	}
+	if err == nil {
+		err = g.git.CreateRemote(repo, &config.RemoteConfig{
+			Name: git.DefaultRemoteName,
+			URLs: []string{repository},
+		})
+	}
+	if err != nil {
+		// Repositories without the remote would be opened by later reads.
+		os.RemoveAll(filePath)
+		return nil, err
+	}
+	return repo, nil
+}
+
+// fetch runs the fetch with the timeout of the client.
+func (g BasicClient) fetch(ctx context.Context, repo *git.Repository, o *git.FetchOptions) error {
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	o.Progress = g.pw
+	err := g.git.FetchContext(ctx, repo, o)
+	if err != nil && ctx.Err() != nil {
+		// Fetches of stalled remotes don't always return the context error.
+		return fmt.Errorf("error fetching repository: %w", ctx.Err())
+	}
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	return nil
+}
+
+// commitObject returns the commit of the cached repository. It returns
+// errCommitNotFetched when the repository hasn't been cloned or the commit
+// is missing.
+func (g BasicClient) commitObject(repPath, filePath string, hash plumbing.Hash) (*object.Commit, error) {
+	if _, err := fs.Stat(g.fs, repPath); os.IsNotExist(err) {
+		return nil, errCommitNotFetched
+	}
+
+	repo, err := g.git.PlainOpen(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := g.git.CommitObject(repo, hash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, errCommitNotFetched
+	}
+	if err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
+
+// readFile returns the file at the path of the commit, inside the root.
+func (g BasicClient) readFile(commit *object.Commit, root, path string) ([]byte, error) {
+	tree, err := commit.Tree()
 	if err != nil {
 		return []byte{}, err
 	}
 
-	err = g.git.Checkout(w, &git.CheckoutOptions{
-		Hash: plumbing.NewHash(commitHash),
-	})
+	file, err := g.findFile(tree, root, path)
 	if err != nil {
 		return []byte{}, err
 	}
 
-	pathToManifest := filepath.Join(repPath, path)
-	fileStat, err := fs.Stat(g.fs, pathToManifest)
+	if g.maxManifestSize > 0 && file.Size > g.maxManifestSize {
+		return []byte{}, fmt.Errorf("%w: '%s' is %d bytes, the max is %d bytes", ErrManifestTooLarge, path, file.Size, g.maxManifestSize)
+	}
+
+	r, err := file.Reader()
 	if err != nil {
 		return []byte{}, err
 	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
 
-	if fileStat.IsDir() {
-		return []byte{}, fmt.Errorf("path provided is not a file '%s'", path)
+// findFile returns the file at the path of the tree. Symlinks are followed
+// as long as they point to files inside the repository and the root, when
+// it's not empty.
+func (g BasicClient) findFile(tree *object.Tree, root, p string) (*object.File, error) {
+	name, ok := validations.CleanRepositoryPath(p)
+	if !ok || !validations.IsInRepositoryRoot(root, name) {
+		return nil, fmt.Errorf("%w: '%s'", ErrInvalidPath, p)
 	}
 
-	return fs.ReadFile(g.fs, pathToManifest)
+	for i := 0; ; i++ {
+		entry, err := tree.FindEntry(name)
+		if err != nil {
+			return nil, fmt.Errorf("path not found '%s': %w", p, fs.ErrNotExist)
+		}
+
+		if !entry.Mode.IsFile() {
+			return nil, fmt.Errorf("path provided is not a file '%s'", p)
+		}
+
+		file, err := tree.TreeEntryFile(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Mode != filemode.Symlink {
+			return file, nil
+		}
+
+		if i == maxSymlinks {
+			return nil, fmt.Errorf("too many levels of symlinks '%s'", p)
+		}
+
+		target, err := file.Contents()
+		if err != nil {
+			return nil, err
+		}
+
+		if path.IsAbs(target) {
+			return nil, fmt.Errorf("%w: '%s' is a symlink to '%s'", ErrInvalidPath, p, target)
+		}
+
+		name, ok = validations.CleanRepositoryPath(path.Join(path.Dir(name), target))
+		if !ok {
+			return nil, fmt.Errorf("%w: '%s' is a symlink to '%s'", ErrInvalidPath, p, target)
+		}
+		if !validations.IsInRepositoryRoot(root, name) {
+			return nil, fmt.Errorf("%w: '%s' is a symlink to '%s' outside the manifest root '%s'", ErrInvalidPath, p, target, root)
+		}
+	}
 }
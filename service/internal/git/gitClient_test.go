@@ -1,64 +1,107 @@
 package git
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
-	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
-	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/google/go-cmp/cmp"
+
+	"github.com/cello-proj/cello/internal/types"
 )
 
 type mockGitSvc struct {
-	cloneOpts   *git.CloneOptions
-	fetchOpts   *git.FetchOptions
-	plainOpened bool
-	pcErr       error
-	poErr       error
-	fetchErr    error
-	wtErr       error
-	coErr       error
+	initialized  bool
+	remoteConfig *config.RemoteConfig
+	fetchOpts    []*git.FetchOptions
+	plainOpened  bool
+	piErr        error
+	crErr        error
+	poErr        error
+	fetchErr     error
+	shaFetchErr  error
+	coErr        error
+
+	// blockFetch makes fetches wait for the context to be done.
+	blockFetch bool
+
+	refs     []*plumbing.Reference
+	listOpts *git.ListOptions
+	listErr  error
+
+	// repo holds the commits returned once fetched, and initialized
+	// repositories are added to fs.
+	repo    *git.Repository
+	fs      fstest.MapFS
+	fetched bool
 }
 
-func (g *mockGitSvc) PlainClone(path string, isBare bool, o *git.CloneOptions) (*git.Repository, error) {
-	g.cloneOpts = o
-
-	if g.pcErr != nil {
-		return nil, g.pcErr
+func (g *mockGitSvc) PlainInit(path string, isBare bool) (*git.Repository, error) {
+	if g.piErr != nil {
+		return nil, g.piErr
 	}
 
+	g.initialized = true
+	g.fs[filepath.Base(path)] = &fstest.MapFile{Mode: os.ModeDir}
 	return nil, nil
 }
 
+func (g *mockGitSvc) MarkCached(path string) error {
+	g.fs[filepath.Join(filepath.Base(path), cacheMarkerFile)] = &fstest.MapFile{}
+	return nil
+}
+
+func (g *mockGitSvc) CreateRemote(r *git.Repository, c *config.RemoteConfig) error {
+	g.remoteConfig = c
+	return g.crErr
+}
+
 func (g *mockGitSvc) PlainOpen(path string) (*git.Repository, error) {
-	fmt.Println(path)
 	g.plainOpened = true
 
 	if g.poErr != nil {
 		return nil, g.poErr
 	}
 
-	if strings.HasSuffix(path, "myrepo3") {
+	if strings.HasSuffix(path, "myrepo3-default") {
 		return &git.Repository{}, nil
 	}
 
 	return nil, nil
 }
 
-func (g *mockGitSvc) Fetch(r *git.Repository, o *git.FetchOptions) error {
-	g.fetchOpts = o
+func (g *mockGitSvc) FetchContext(ctx context.Context, r *git.Repository, o *git.FetchOptions) error {
+	g.fetchOpts = append(g.fetchOpts, o)
+
+	if g.blockFetch {
+		<-ctx.Done()
+		return errors.New("connection closed")
+	}
+
+	if len(o.RefSpecs) > 0 && g.shaFetchErr != nil {
+		return g.shaFetchErr
+	}
+
 	if g.fetchErr != nil {
 		return g.fetchErr
 	}
 
+	g.fetched = true
 	if r != nil {
 		return git.NoErrAlreadyUpToDate
 	}
@@ -66,45 +109,86 @@ func (g *mockGitSvc) Fetch(r *git.Repository, o *git.FetchOptions) error {
 	return nil
 }
 
-func (g *mockGitSvc) Worktree(r *git.Repository) (*git.Worktree, error) {
-	if g.wtErr != nil {
-		return nil, g.wtErr
+func (g *mockGitSvc) CommitObject(r *git.Repository, h plumbing.Hash) (*object.Commit, error) {
+	if g.coErr != nil {
+		return nil, g.coErr
 	}
 
-	return nil, nil
+	if !g.fetched {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return g.repo.CommitObject(h)
 }
 
-func (g *mockGitSvc) Checkout(w *git.Worktree, opts *git.CheckoutOptions) error {
-	if g.coErr != nil {
-		return g.coErr
+func (g *mockGitSvc) ListRemote(ctx context.Context, url string, o *git.ListOptions) ([]*plumbing.Reference, error) {
+	g.listOpts = o
+	return g.refs, g.listErr
+}
+
+// newTestRepo creates a repository with a commit of the files, and returns
+// the repository and the hash of the commit.
+func newTestRepo(t *testing.T, dir string, files map[string]string) (*git.Repository, plumbing.Hash) {
+	t.Helper()
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	return nil
+	return repo, commitTestFiles(t, repo, dir, files)
 }
 
-func newGitClient() (BasicClient, *mockGitSvc) {
-	paths := []string{
-		"myrepo/path/to/manifest.yaml",
-		"myrepo2/path/to/manifest.yaml",
-		"myrepo3/path/to/manifest.yaml",
-	}
-	mapFs := fstest.MapFS{}
-	mapFs["aDir/aPath"] = &fstest.MapFile{
-		Mode: os.ModeDir,
+func commitTestFiles(t *testing.T, repo *git.Repository, dir string, files map[string]string) plumbing.Hash {
+	t.Helper()
+
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
 	}
-	for _, path := range paths {
-		mapFs[path] = &fstest.MapFile{
-			Data: []byte("my bytes"),
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
 		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := w.Commit("commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "cello", Email: "cello@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func newGitClient(t *testing.T) (BasicClient, *mockGitSvc, plumbing.Hash) {
+	repo, hash := newTestRepo(t, t.TempDir(), map[string]string{
+		"path/to/manifest.yaml": "my bytes",
+		"aDir/aPath/file":       "file",
+	})
+
+	mapFs := fstest.MapFS{}
+	for _, path := range []string{"myrepo-default", "myrepo3-default", "aDir"} {
+		mapFs[path] = &fstest.MapFile{Mode: os.ModeDir}
 	}
 
-	gitSvc := &mockGitSvc{}
+	gitSvc := &mockGitSvc{repo: repo, fs: mapFs}
 	return BasicClient{
-		auth: nil,
-		mu:   &sync.Mutex{},
-		git:  gitSvc,
-		fs:   mapFs,
-	}, gitSvc
+		auth:  nil,
+		cache: newRepoCache(),
+		locks: newRepoLocks(),
+		git:   gitSvc,
+		fs:    mapFs,
+		depth: 1,
+	}, gitSvc, hash
 }
 
 type progressWriter struct{}
@@ -118,18 +202,24 @@ func TestGetManifestErrors(t *testing.T) {
 		name string
 		repo string
 		path string
+		sha  string
 
-		pc     error
+		pi     error
+		cr     error
 		po     error
 		fetch  error
-		wt     error
 		co     error
 		errStr string
 	}{
 		{
-			name: "bubbles PlainClone error",
-			repo: "plainclone",
-			pc:   errors.New("PlainClone err"),
+			name: "bubbles PlainInit error",
+			repo: "plaininit",
+			pi:   errors.New("PlainInit err"),
+		},
+		{
+			name: "bubbles CreateRemote error",
+			repo: "createremote",
+			cr:   errors.New("CreateRemote err"),
 		},
 		{
 			name: "bubbles PlainOpen error",
@@ -140,41 +230,47 @@ func TestGetManifestErrors(t *testing.T) {
 			fetch: errors.New("Fetch err"),
 		},
 		{
-			name: "bubbles WorkTree error",
-			wt:   errors.New("WorkTree err"),
-		},
-		{
-			name: "bubbles Checkout error",
-			co:   errors.New("Checkout err"),
+			name: "bubbles CommitObject error",
+			co:   errors.New("CommitObject err"),
 		},
 		{
 			name:   "rejects when path is a dir",
-			repo:   "aDir",
-			path:   "aPath",
+			path:   "aDir/aPath",
 			errStr: "path provided is not a file",
 		},
+		{
+			name:   "rejects when path does not exist",
+			path:   "path/to/missing.yaml",
+			errStr: "path not found",
+		},
+		{
+			name:   "rejects when commit does not exist",
+			sha:    "0123456789012345678901234567890123456789",
+			errStr: "commit 0123456789012345678901234567890123456789 not found in repository",
+		},
 	}
 
-	cl, svc := newGitClient()
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			svc.pcErr = tt.pc
+			cl, svc, hash := newGitClient(t)
+			svc.piErr = tt.pi
+			svc.crErr = tt.cr
 			svc.poErr = tt.po
 			svc.fetchErr = tt.fetch
-			svc.wtErr = tt.wt
 			svc.coErr = tt.co
 
 			repo := defaultString(tt.repo, "myrepo3")
 			path := defaultString(tt.path, "path/to/manifest.yaml")
-			_, err := cl.GetManifestFile(repo, "123", path)
+			sha := defaultString(tt.sha, hash.String())
+			_, err := cl.GetManifestFile(context.Background(), Repository{URL: repo}, sha, "", path)
 
-			for _, want := range []error{tt.pc, tt.po, tt.fetch, tt.wt, tt.co} {
+			for _, want := range []error{tt.pi, tt.cr, tt.po, tt.fetch, tt.co} {
 				if want != nil && !errors.Is(err, want) {
 					t.Errorf("wanted: %+v got: %+v", want, err)
 				}
 			}
 
-			if tt.errStr != "" && !strings.Contains(err.Error(), tt.errStr) {
+			if tt.errStr != "" && (err == nil || !strings.Contains(err.Error(), tt.errStr)) {
 				t.Errorf("wanted: %+v got: %+v\n", tt.errStr, err)
 			}
 		})
@@ -183,45 +279,78 @@ func TestGetManifestErrors(t *testing.T) {
 
 func TestGetManifestFile(t *testing.T) {
 	tests := []struct {
-		name       string
-		repository string
-		commitHash string
-		path       string
-		errResult  bool
-		res        string
+		name        string
+		repository  string
+		fetched     bool
+		depth       int
+		shaFetchErr error
+		path        string
+		errResult   bool
+		res         string
+		wantInit    bool
+		wantFetches []string
 	}{
 		{
-			name:       "get manifest exists on fs success",
-			repository: "myrepo",
-			commitHash: "123",
-			path:       "path/to/manifest.yaml",
-			errResult:  false,
-			res:        "my bytes",
+			name:        "get manifest exists on fs success",
+			repository:  "myrepo",
+			depth:       1,
+			path:        "path/to/manifest.yaml",
+			res:         "my bytes",
+			wantFetches: []string{"sha"},
 		},
 		{
-			name:       "get manifest new clone success",
-			repository: "myrepo2",
-			commitHash: "123",
-			path:       "path/to/manifest.yaml",
-			errResult:  false,
-			res:        "my bytes",
+			name:        "get manifest new repository success",
+			repository:  "myrepo2",
+			depth:       1,
+			path:        "path/to/manifest.yaml",
+			res:         "my bytes",
+			wantInit:    true,
+			wantFetches: []string{"sha"},
 		},
 		{
-			name:       "get manifest fetch already updated",
+			name:        "get manifest fetch already updated",
+			repository:  "myrepo3",
+			depth:       1,
+			path:        "/path/to/manifest.yaml",
+			res:         "my bytes",
+			wantFetches: []string{"sha"},
+		},
+		{
+			name:       "does not fetch when commit exists",
 			repository: "myrepo3",
-			commitHash: "123",
+			fetched:    true,
+			depth:      1,
 			path:       "path/to/manifest.yaml",
-			errResult:  false,
 			res:        "my bytes",
 		},
+		{
+			name:        "falls back to full fetch when commit fetch fails",
+			repository:  "myrepo",
+			depth:       1,
+			shaFetchErr: git.ErrExactSHA1NotSupported,
+			path:        "path/to/manifest.yaml",
+			res:         "my bytes",
+			wantFetches: []string{"sha", "full"},
+		},
+		{
+			name:        "fetches full history without depth",
+			repository:  "myrepo",
+			path:        "path/to/manifest.yaml",
+			res:         "my bytes",
+			wantFetches: []string{"full"},
+		},
 	}
 
 	pw := &progressWriter{}
-	gitClient, gitSvc := newGitClient()
-	WithProgressWriter(pw)(&gitClient)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res, err := gitClient.GetManifestFile(tt.repository, tt.commitHash, tt.path)
+			gitClient, gitSvc, hash := newGitClient(t)
+			WithProgressWriter(pw)(&gitClient)
+			WithFetchDepth(tt.depth)(&gitClient)
+			gitSvc.fetched = tt.fetched
+			gitSvc.shaFetchErr = tt.shaFetchErr
+
+			res, err := gitClient.GetManifestFile(context.Background(), Repository{URL: tt.repository}, hash.String(), "", tt.path)
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -235,17 +364,524 @@ func TestGetManifestFile(t *testing.T) {
 				}
 			}
 
-			if !gitSvc.plainOpened && gitSvc.cloneOpts.Progress != pw {
-				t.Errorf("\ncloneOpts Progress not passed through: want: %v\n got: %v\n", pw, gitSvc.cloneOpts.Progress)
+			if gitSvc.initialized != tt.wantInit {
+				t.Errorf("\ninitialized: want: %v\n got: %v\n", tt.wantInit, gitSvc.initialized)
+			}
+
+			if tt.wantInit && (gitSvc.remoteConfig == nil || !cmp.Equal(gitSvc.remoteConfig.URLs, []string{tt.repository})) {
+				t.Errorf("\nremote not created for repository: want: %v\n got: %+v\n", tt.repository, gitSvc.remoteConfig)
+			}
+
+			if len(gitSvc.fetchOpts) != len(tt.wantFetches) {
+				t.Fatalf("\nfetches: want: %d\n got: %d\n", len(tt.wantFetches), len(gitSvc.fetchOpts))
+			}
+
+			for i, want := range tt.wantFetches {
+				opts := gitSvc.fetchOpts[i]
+				if opts.Progress != pw {
+					t.Errorf("\nfetchOpts Progress not passed through: want: %v\n got: %v\n", pw, opts.Progress)
+				}
+
+				wantOpts := &git.FetchOptions{Progress: pw}
+				if want == "sha" {
+					wantOpts.RefSpecs = []config.RefSpec{config.RefSpec(hash.String() + ":refs/commits/" + hash.String())}
+					wantOpts.Depth = tt.depth
+				}
+				if !cmp.Equal(opts.RefSpecs, wantOpts.RefSpecs) || opts.Depth != wantOpts.Depth {
+					t.Errorf("\nfetch %d: want: %v depth %d\n got: %v depth %d\n", i, wantOpts.RefSpecs, wantOpts.Depth, opts.RefSpecs, opts.Depth)
+				}
+			}
+		})
+	}
+}
+
+func TestGetManifestFilePaths(t *testing.T) {
+	dir := t.TempDir()
+	repo, _ := newTestRepo(t, dir, map[string]string{
+		"cello/manifest.yaml": "my bytes",
+		"other/manifest.yaml": "other bytes",
+		"cello/large.yaml":    strings.Repeat("a", 17),
+	})
+
+	w, err := repo.Worktree()
+	assertNoErr(t, err)
+
+	for name, target := range map[string]string{
+		"cello/link.yaml":        "manifest.yaml",
+		"cello/parent-link.yaml": "../other/manifest.yaml",
+		"cello/outside.yaml":     "../../otherrepo/manifest.yaml",
+		"cello/absolute.yaml":    "/etc/passwd",
+		"cello/loop.yaml":        "loop.yaml",
+	} {
+		assertNoErr(t, os.Symlink(target, filepath.Join(dir, name)))
+		_, err := w.Add(name)
+		assertNoErr(t, err)
+	}
+
+	hash, err := w.Commit("symlinks", &git.CommitOptions{
+		Author: &object.Signature{Name: "cello", Email: "cello@example.com", When: time.Now()},
+	})
+	assertNoErr(t, err)
+
+	tests := []struct {
+		name    string
+		root    string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "reads files",
+			path: "cello/manifest.yaml",
+			want: "my bytes",
+		},
+		{
+			name: "reads files inside the root",
+			root: "cello",
+			path: "cello/manifest.yaml",
+			want: "my bytes",
+		},
+		{
+			name: "follows symlinks inside the root",
+			root: "cello",
+			path: "cello/link.yaml",
+			want: "my bytes",
+		},
+		{
+			name:    "rejects paths outside the root",
+			root:    "cello",
+			path:    "other/manifest.yaml",
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "rejects symlinks outside the root",
+			root:    "cello",
+			path:    "cello/parent-link.yaml",
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name: "reads files of paths with parent directories inside the repository",
+			path: "other/../cello/manifest.yaml",
+			want: "my bytes",
+		},
+		{
+			name: "follows symlinks",
+			path: "cello/link.yaml",
+			want: "my bytes",
+		},
+		{
+			name: "follows symlinks to parent directories inside the repository",
+			path: "cello/parent-link.yaml",
+			want: "other bytes",
+		},
+		{
+			name:    "rejects paths outside the repository",
+			path:    "../otherrepo/manifest.yaml",
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "rejects symlinks outside the repository",
+			path:    "cello/outside.yaml",
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "rejects absolute symlinks",
+			path:    "cello/absolute.yaml",
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name: "rejects symlink loops",
+			path: "cello/loop.yaml",
+		},
+		{
+			name:    "rejects files over the max manifest size",
+			path:    "cello/large.yaml",
+			wantErr: ErrManifestTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapFs := fstest.MapFS{"myrepo-default": &fstest.MapFile{Mode: os.ModeDir}}
+			cl := BasicClient{
+				cache:           newRepoCache(),
+				locks:           newRepoLocks(),
+				git:             &mockGitSvc{repo: repo, fs: mapFs, fetched: true},
+				fs:              mapFs,
+				maxManifestSize: 16,
+			}
+
+			got, err := cl.GetManifestFile(context.Background(), Repository{URL: "myrepo"}, hash.String(), tt.root, tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatal("expected error, received nil")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("wanted: %+v got: %+v", tt.wantErr, err)
+				}
+				return
+			}
+
+			assertNoErr(t, err)
+			if string(got) != tt.want {
+				t.Errorf("want: %s got: %s\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetManifestFileTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  bool
+		want    error
+	}{
+		{
+			name:    "stops fetches which time out",
+			timeout: 10 * time.Millisecond,
+			want:    context.DeadlineExceeded,
+		},
+		{
+			name:   "stops fetches of canceled requests",
+			cancel: true,
+			want:   context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl, svc, hash := newGitClient(t)
+			WithTimeout(tt.timeout)(&cl)
+			svc.blockFetch = true
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			_, err := cl.GetManifestFile(ctx, Repository{URL: "myrepo"}, hash.String(), "", "path/to/manifest.yaml")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("want: %v got: %v\n", tt.want, err)
+			}
+
+			// Fetches which were stopped don't fall back to a full fetch.
+			if len(svc.fetchOpts) != 1 {
+				t.Errorf("fetches: want: 1 got: %d\n", len(svc.fetchOpts))
+			}
+		})
+	}
+}
+
+func TestGetManifestFileFromRepository(t *testing.T) {
+	t.Run("fetches full history when the remote does not allow fetching commits", func(t *testing.T) {
+		testGetManifestFileFromRepository(t, false)
+	})
+
+	t.Run("fetches commits when the remote allows it", func(t *testing.T) {
+		testGetManifestFileFromRepository(t, true)
+	})
+}
+
+func testGetManifestFileFromRepository(t *testing.T, allowCommitFetch bool) {
+	remoteDir := t.TempDir()
+	remote, first := newTestRepo(t, remoteDir, map[string]string{"manifest.yaml": "first"})
+
+	if allowCommitFetch {
+		cfg, err := remote.Config()
+		assertNoErr(t, err)
+		cfg.Raw.Section("uploadpack").SetOption("allowAnySHA1InWant", "true")
+		assertNoErr(t, remote.SetConfig(cfg))
+	}
+
+	baseDir := t.TempDir()
+	cl := newBasicClient(nil)
+	cl.baseDir = baseDir
+	cl.fs = os.DirFS(baseDir)
+
+	got, err := cl.GetManifestFile(context.Background(), Repository{URL: remoteDir}, first.String(), "", "manifest.yaml")
+	assertNoErr(t, err)
+	if string(got) != "first" {
+		t.Errorf("want: first got: %s\n", got)
+	}
+
+	// Repositories are created without a worktree.
+	repPath := cachePath(Repository{URL: remoteDir})
+	if _, err := os.Stat(filepath.Join(baseDir, repPath, "manifest.yaml")); !os.IsNotExist(err) {
+		t.Errorf("want no worktree, got: %v\n", err)
+	}
+
+	// Only commits fetched by hash are shallow.
+	_, err = os.Stat(filepath.Join(baseDir, repPath, "shallow"))
+	if shallow := err == nil; shallow != allowCommitFetch {
+		t.Errorf("shallow: want: %v got: %v\n", allowCommitFetch, shallow)
+	}
+
+	// Missing commits are fetched.
+	second := commitTestFiles(t, remote, remoteDir, map[string]string{"manifest.yaml": "second"})
+	got, err = cl.GetManifestFile(context.Background(), Repository{URL: remoteDir}, second.String(), "", "manifest.yaml")
+	assertNoErr(t, err)
+	if string(got) != "second" {
+		t.Errorf("want: second got: %s\n", got)
+	}
+
+	// Fetched commits are still read.
+	got, err = cl.GetManifestFile(context.Background(), Repository{URL: remoteDir}, first.String(), "", "manifest.yaml")
+	assertNoErr(t, err)
+	if string(got) != "first" {
+		t.Errorf("want: first got: %s\n", got)
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	const (
+		mainSHA   = "8458fd753f9fde51882414564c20df6d4c34a90e"
+		tagSHA    = "0123456789012345678901234567890123456789"
+		tagObjSHA = "9876543210987654321098765432109876543210"
+	)
+
+	refs := []*plumbing.Reference{
+		plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/main"),
+		plumbing.NewReferenceFromStrings("refs/heads/main", mainSHA),
+		plumbing.NewReferenceFromStrings("refs/heads/release/1.0", tagSHA),
+		plumbing.NewReferenceFromStrings("refs/tags/v1.0.0", tagSHA),
+		plumbing.NewReferenceFromStrings("refs/tags/v2.0.0", tagObjSHA),
+		plumbing.NewReferenceFromStrings("refs/tags/v2.0.0^{}", mainSHA),
+		plumbing.NewReferenceFromStrings("refs/tags/v3.0.0", "0123456789abcdef0123456789abcdef01234567"),
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		listErr error
+		want    string
+		wantErr error
+	}{
+		{
+			name: "returns commit shas",
+			ref:  strings.ToUpper(tagSHA[:10]) + tagSHA[10:],
+			want: tagSHA,
+		},
+		{
+			name: "resolves branches",
+			ref:  "main",
+			want: mainSHA,
+		},
+		{
+			name: "resolves branches with slashes",
+			ref:  "release/1.0",
+			want: tagSHA,
+		},
+		{
+			name: "resolves tags",
+			ref:  "v1.0.0",
+			want: tagSHA,
+		},
+		{
+			name: "resolves annotated tags to their commit",
+			ref:  "v2.0.0",
+			want: mainSHA,
+		},
+		{
+			name: "resolves full ref names",
+			ref:  "refs/tags/v1.0.0",
+			want: tagSHA,
+		},
+		{
+			name: "resolves HEAD",
+			ref:  "HEAD",
+			want: mainSHA,
+		},
+		{
+			name: "resolves abbreviated commit shas of refs",
+			ref:  strings.ToUpper(mainSHA[:7]),
+			want: mainSHA,
+		},
+		{
+			name:    "rejects ambiguous abbreviated commit shas",
+			ref:     "0123",
+			wantErr: ErrAmbiguousRef,
+		},
+		{
+			name:    "rejects missing refs",
+			ref:     "missing",
+			wantErr: ErrRefNotFound,
+		},
+		{
+			name:    "bubbles ListRemote error",
+			ref:     "main",
+			listErr: errors.New("ListRemote err"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl, svc, _ := newGitClient(t)
+			svc.refs = refs
+			svc.listErr = tt.listErr
+
+			got, err := cl.ResolveRef(context.Background(), Repository{URL: "myrepo"}, tt.ref)
+			for _, want := range []error{tt.wantErr, tt.listErr} {
+				if want != nil && !errors.Is(err, want) {
+					t.Errorf("wanted: %+v got: %+v", want, err)
+				}
+			}
+
+			if tt.want != "" {
+				assertNoErr(t, err)
+				if got != tt.want {
+					t.Errorf("want: %s got: %s\n", tt.want, got)
+				}
 			}
+		})
+	}
+}
+
+func TestResolveRefFromRepository(t *testing.T) {
+	remoteDir := t.TempDir()
+	remote, first := newTestRepo(t, remoteDir, map[string]string{"manifest.yaml": "first"})
+
+	_, err := remote.CreateTag("v1.0.0", first, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "cello", Email: "cello@example.com", When: time.Now()},
+		Message: "v1.0.0",
+	})
+	assertNoErr(t, err)
+
+	second := commitTestFiles(t, remote, remoteDir, map[string]string{"manifest.yaml": "second"})
+
+	third := commitTestFiles(t, remote, remoteDir, map[string]string{"manifest.yaml": "third"})
+
+	head, err := remote.Head()
+	assertNoErr(t, err)
 
-			if gitSvc.fetchOpts.Progress != pw {
-				t.Errorf("\nfetchOpts Progress not passed through: want: %v\n got: %v\n", pw, gitSvc.fetchOpts.Progress)
+	// The second commit isn't the commit of a ref, so it's resolved from the
+	// fetched history.
+	cl := newBasicClient(nil, WithCacheDir(t.TempDir()))
+	for ref, want := range map[string]plumbing.Hash{
+		head.Name().Short(): third,
+		"v1.0.0":            first,
+		second.String()[:7]: second,
+		third.String()[:7]:  third,
+	} {
+		got, err := cl.ResolveRef(context.Background(), Repository{URL: remoteDir}, ref)
+		assertNoErr(t, err)
+		if got != want.String() {
+			t.Errorf("ref %s: want: %s got: %s\n", ref, want, got)
+		}
+	}
+}
+
+func TestGetManifestFileLocksPerRepository(t *testing.T) {
+	cl, _, hash := newGitClient(t)
+
+	// Reads of other repositories don't wait for a repository being fetched.
+	lock := cl.locks.get("myrepo-default")
+	lock.Lock()
+	defer lock.Unlock()
+
+	done := make(chan error)
+	go func() {
+		_, err := cl.GetManifestFile(context.Background(), Repository{URL: "myrepo3"}, hash.String(), "", "path/to/manifest.yaml")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assertNoErr(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read of another repository waited for the lock")
+	}
+}
+
+func TestRepositoryCredentials(t *testing.T) {
+	pk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pk)})
+
+	global := &http.BasicAuth{Username: "global", Password: "pass"}
+
+	tests := []struct {
+		name     string
+		creds    types.GitCredentials
+		wantAuth string
+		wantErr  bool
+	}{
+		{
+			name:     "uses the client credentials without repository credentials",
+			wantAuth: "http-basic-auth",
+		},
+		{
+			name:     "uses the repository ssh private key",
+			creds:    types.GitCredentials{SSHPrivateKey: string(key)},
+			wantAuth: "ssh-public-keys",
+		},
+		{
+			name:     "uses the repository https credentials",
+			creds:    types.GitCredentials{HTTPSUsername: "user", HTTPSPassword: "token"},
+			wantAuth: "http-basic-auth",
+		},
+		{
+			name:    "rejects invalid ssh private keys",
+			creds:   types.GitCredentials{SSHPrivateKey: "invalid"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl, svc, hash := newGitClient(t)
+			cl.auth = global
+			svc.refs = []*plumbing.Reference{plumbing.NewReferenceFromStrings("refs/heads/main", hash.String())}
+
+			repo := Repository{URL: "newrepo", Credentials: tt.creds}
+
+			_, err := cl.GetManifestFile(context.Background(), repo, hash.String(), "", "path/to/manifest.yaml")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, received nil")
+				}
+				if len(svc.fetchOpts) != 0 {
+					t.Errorf("want no fetches, got: %d", len(svc.fetchOpts))
+				}
+				return
+			}
+			assertNoErr(t, err)
+
+			_, err = cl.ResolveRef(context.Background(), repo, "main")
+			assertNoErr(t, err)
+
+			auths := []transport.AuthMethod{svc.listOpts.Auth}
+			for _, o := range svc.fetchOpts {
+				auths = append(auths, o.Auth)
+			}
+
+			for _, auth := range auths {
+				if auth == nil || auth.Name() != tt.wantAuth {
+					t.Fatalf("want auth: %s got: %+v", tt.wantAuth, auth)
+				}
+				if tt.creds.IsEmpty() && auth != global {
+					t.Errorf("want the client auth, got: %+v", auth)
+				}
+				if b, ok := auth.(*http.BasicAuth); ok && !tt.creds.IsEmpty() && b.Username != tt.creds.HTTPSUsername {
+					t.Errorf("want username: %s got: %s", tt.creds.HTTPSUsername, b.Username)
+				}
 			}
 		})
 	}
 }
 
+func TestValidateCredentials(t *testing.T) {
+	pk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pk)})
+
+	assertNoErr(t, ValidateCredentials(types.GitCredentials{SSHPrivateKey: string(key)}))
+	assertNoErr(t, ValidateCredentials(types.GitCredentials{HTTPSUsername: "user", HTTPSPassword: "token"}))
+
+	if err := ValidateCredentials(types.GitCredentials{SSHPrivateKey: "invalid"}); err == nil {
+		t.Error("expected error, received nil")
+	}
+}
+
 func TestNewClient(t *testing.T) {
 	t.Run("NewSSHBasicClient creates client with ssh auth with valid PEM", func(t *testing.T) {
 		tmp, err := os.CreateTemp("", "tmpssh*.pem")
@@ -285,12 +921,16 @@ func TestNewClient(t *testing.T) {
 	})
 	t.Run("NewHTTPSBasicClient passes opts", func(t *testing.T) {
 		pw := &progressWriter{}
-		cl, err := NewHTTPSBasicClient("user", "pass", WithProgressWriter(pw))
+		cl, err := NewHTTPSBasicClient("user", "pass", WithProgressWriter(pw), WithTimeout(time.Second), WithFetchDepth(5), WithMaxManifestSize(1024))
 		assertNoErr(t, err)
 
 		if cl.pw != pw {
 			t.Errorf("want: %+v got: %+v\n", pw, cl.pw)
 		}
+
+		if cl.timeout != time.Second || cl.depth != 5 || cl.maxManifestSize != 1024 {
+			t.Errorf("want: timeout %v depth 5 max manifest size 1024 got: timeout %v depth %d max manifest size %d\n", time.Second, cl.timeout, cl.depth, cl.maxManifestSize)
+		}
 	})
 }
 
@@ -0,0 +1,241 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrUnsignedCommit conveys that a commit has no signature.
+var ErrUnsignedCommit = errors.New("commit is not signed")
+
+// ErrUntrustedSignature conveys that a commit signature is invalid, or isn't
+// made by a trusted key.
+var ErrUntrustedSignature = errors.New("commit is not signed by a trusted key")
+
+// Signature types.
+const (
+	SignatureTypeGPG = "gpg"
+	SignatureTypeSSH = "ssh"
+)
+
+// sshSignatureNamespace is the namespace of ssh signatures made by git.
+const sshSignatureNamespace = "git"
+
+// Signature is the verified signature of a commit.
+type Signature struct {
+	// KeyID is the fingerprint of the key, in hex. The fingerprint of ssh keys
+	// is truncated to the 20 bytes of gpg fingerprints so it fits in labels.
+	KeyID string
+	Type  string
+}
+
+// Keyring holds the gpg and ssh keys trusted to sign commits.
+type Keyring struct {
+	gpg openpgp.EntityList
+	ssh []ssh.PublicKey
+}
+
+// NewKeyring returns the keyring of the armored gpg public keys and the ssh
+// public keys in authorized_keys format. Either may be empty.
+func NewKeyring(gpgKeys, sshKeys []byte) (Keyring, error) {
+	var k Keyring
+
+	if len(bytes.TrimSpace(gpgKeys)) > 0 {
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(gpgKeys))
+		if err != nil {
+			return Keyring{}, fmt.Errorf("error reading gpg keys: %w", err)
+		}
+		k.gpg = entities
+	}
+
+	for rest := sshKeys; len(bytes.TrimSpace(rest)) > 0; {
+		key, _, _, r, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return Keyring{}, fmt.Errorf("error reading ssh keys: %w", err)
+		}
+		k.ssh = append(k.ssh, key)
+		rest = r
+	}
+
+	return k, nil
+}
+
+// LoadKeyring returns the keyring of the gpg and ssh key files. Empty paths
+// are skipped.
+func LoadKeyring(gpgFile, sshFile string) (Keyring, error) {
+	read := func(path string) ([]byte, error) {
+		if path == "" {
+			return nil, nil
+		}
+		return os.ReadFile(path)
+	}
+
+	gpgKeys, err := read(gpgFile)
+	if err != nil {
+		return Keyring{}, err
+	}
+
+	sshKeys, err := read(sshFile)
+	if err != nil {
+		return Keyring{}, err
+	}
+
+	return NewKeyring(gpgKeys, sshKeys)
+}
+
+// IsEmpty returns whether the keyring has no keys.
+func (k Keyring) IsEmpty() bool {
+	return len(k.gpg) == 0 && len(k.ssh) == 0
+}
+
+// Verify verifies the signature of the commit, and returns the key which made
+// it.
+func (k Keyring) Verify(c *object.Commit) (Signature, error) {
+	if c.PGPSignature == "" {
+		return Signature{}, ErrUnsignedCommit
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := c.EncodeWithoutSignature(encoded); err != nil {
+		return Signature{}, err
+	}
+
+	r, err := encoded.Reader()
+	if err != nil {
+		return Signature{}, err
+	}
+	defer r.Close()
+
+	message, err := io.ReadAll(r)
+	if err != nil {
+		return Signature{}, err
+	}
+
+	if strings.HasPrefix(c.PGPSignature, "-----BEGIN SSH SIGNATURE-----") {
+		return k.verifySSH(message, c.PGPSignature)
+	}
+	return k.verifyGPG(message, c.PGPSignature)
+}
+
+func (k Keyring) verifyGPG(message []byte, signature string) (Signature, error) {
+	if len(k.gpg) == 0 {
+		return Signature{}, fmt.Errorf("%w: no gpg keys are trusted", ErrUntrustedSignature)
+	}
+
+	entity, err := openpgp.CheckArmoredDetachedSignature(k.gpg, bytes.NewReader(message), strings.NewReader(signature), nil)
+	if err != nil {
+		return Signature{}, fmt.Errorf("%w: %s", ErrUntrustedSignature, err)
+	}
+
+	return Signature{
+		KeyID: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+		Type:  SignatureTypeGPG,
+	}, nil
+}
+
+// sshSignature is the ssh signature format of ssh-keygen -Y sign.
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data signed by ssh signatures.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Hash          []byte
+}
+
+const sshSignatureMagic = "SSHSIG"
+
+func (k Keyring) verifySSH(message []byte, armored string) (Signature, error) {
+	block, _ := pem.Decode([]byte(armored))
+	if block == nil || block.Type != "SSH SIGNATURE" || !bytes.HasPrefix(block.Bytes, []byte(sshSignatureMagic)) {
+		return Signature{}, fmt.Errorf("%w: invalid ssh signature", ErrUntrustedSignature)
+	}
+
+	var sig sshSignature
+	if err := ssh.Unmarshal(block.Bytes[len(sshSignatureMagic):], &sig); err != nil {
+		return Signature{}, fmt.Errorf("%w: invalid ssh signature: %s", ErrUntrustedSignature, err)
+	}
+
+	if sig.Version != 1 || sig.Namespace != sshSignatureNamespace {
+		return Signature{}, fmt.Errorf("%w: unsupported ssh signature", ErrUntrustedSignature)
+	}
+
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return Signature{}, fmt.Errorf("%w: invalid ssh signature key: %s", ErrUntrustedSignature, err)
+	}
+
+	if !k.trustsSSHKey(key) {
+		return Signature{}, fmt.Errorf("%w: ssh key %s is not trusted", ErrUntrustedSignature, ssh.FingerprintSHA256(key))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return Signature{}, fmt.Errorf("%w: unsupported ssh signature hash '%s'", ErrUntrustedSignature, sig.HashAlgorithm)
+	}
+	h.Write(message)
+
+	signed := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	var s ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &s); err != nil {
+		return Signature{}, fmt.Errorf("%w: invalid ssh signature: %s", ErrUntrustedSignature, err)
+	}
+
+	if err := key.Verify(signed, &s); err != nil {
+		return Signature{}, fmt.Errorf("%w: %s", ErrUntrustedSignature, err)
+	}
+
+	return Signature{
+		KeyID: sshKeyID(key),
+		Type:  SignatureTypeSSH,
+	}, nil
+}
+
+// sshKeyID returns the first 20 bytes of the SHA256 fingerprint of the key.
+func sshKeyID(key ssh.PublicKey) string {
+	fingerprint := sha256.Sum256(key.Marshal())
+	return strings.ToUpper(hex.EncodeToString(fingerprint[:20]))
+}
+
+func (k Keyring) trustsSSHKey(key ssh.PublicKey) bool {
+	for _, trusted := range k.ssh {
+		if bytes.Equal(trusted.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestGPGEntity(t *testing.T) (*openpgp.Entity, []byte) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("cello", "", "cello@example.com", nil)
+	assertNoErr(t, err)
+
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	assertNoErr(t, err)
+	assertNoErr(t, entity.Serialize(w))
+	assertNoErr(t, w.Close())
+
+	return entity, b.Bytes()
+}
+
+func newTestSSHSigner(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assertNoErr(t, err)
+
+	signer, err := ssh.NewSignerFromKey(key)
+	assertNoErr(t, err)
+
+	return signer, ssh.MarshalAuthorizedKey(signer.PublicKey())
+}
+
+func commitGPGSigned(t *testing.T, repo *git.Repository, dir string, entity *openpgp.Entity) plumbing.Hash {
+	t.Helper()
+
+	w, err := repo.Worktree()
+	assertNoErr(t, err)
+
+	assertNoErr(t, os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("gpg"), 0o600))
+	_, err = w.Add("manifest.yaml")
+	assertNoErr(t, err)
+
+	hash, err := w.Commit("signed", &git.CommitOptions{
+		Author:  &object.Signature{Name: "cello", Email: "cello@example.com", When: time.Now()},
+		SignKey: entity,
+	})
+	assertNoErr(t, err)
+	return hash
+}
+
+// commitSSHSigned stores a copy of the commit signed with the ssh signer, like
+// git commit -S with gpg.format ssh.
+func commitSSHSigned(t *testing.T, repo *git.Repository, hash plumbing.Hash, signer ssh.Signer, namespace string) plumbing.Hash {
+	t.Helper()
+
+	commit, err := repo.CommitObject(hash)
+	assertNoErr(t, err)
+
+	encoded := &plumbing.MemoryObject{}
+	assertNoErr(t, commit.EncodeWithoutSignature(encoded))
+	r, err := encoded.Reader()
+	assertNoErr(t, err)
+	var message bytes.Buffer
+	_, err = message.ReadFrom(r)
+	assertNoErr(t, err)
+
+	digest := sha512.Sum512(message.Bytes())
+	signed := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+		Hash:          digest[:],
+	})...)
+
+	sig, err := signer.Sign(rand.Reader, signed)
+	assertNoErr(t, err)
+
+	blob := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignature{
+		Version:       1,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+	commit.PGPSignature = string(pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob}))
+
+	obj := repo.Storer.NewEncodedObject()
+	assertNoErr(t, commit.Encode(obj))
+	signedHash, err := repo.Storer.SetEncodedObject(obj)
+	assertNoErr(t, err)
+	return signedHash
+}
+
+func TestNewKeyring(t *testing.T) {
+	_, gpgKey := newTestGPGEntity(t)
+	_, sshKey := newTestSSHSigner(t)
+	_, otherSSHKey := newTestSSHSigner(t)
+
+	tests := []struct {
+		name    string
+		gpgKeys []byte
+		sshKeys []byte
+		empty   bool
+		errResp bool
+	}{
+		{
+			name:  "no keys",
+			empty: true,
+		},
+		{
+			name:    "gpg and ssh keys",
+			gpgKeys: gpgKey,
+			sshKeys: append(append(sshKey, []byte("\n# comment\n")...), otherSSHKey...),
+		},
+		{
+			name:    "invalid gpg keys",
+			gpgKeys: []byte("not a key"),
+			errResp: true,
+		},
+		{
+			name:    "invalid ssh keys",
+			sshKeys: []byte("not a key"),
+			errResp: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.gpgKeys, tt.sshKeys)
+			if err != nil {
+				if !tt.errResp {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tt.errResp {
+				t.Fatal("expected error")
+			}
+			if k.IsEmpty() != tt.empty {
+				t.Errorf("empty: want: %v got: %v", tt.empty, k.IsEmpty())
+			}
+		})
+	}
+}
+
+func TestKeyringVerify(t *testing.T) {
+	dir := t.TempDir()
+	repo, unsigned := newTestRepo(t, dir, map[string]string{"manifest.yaml": "unsigned"})
+
+	entity, gpgKey := newTestGPGEntity(t)
+	_, untrustedGPGKey := newTestGPGEntity(t)
+	signer, sshKey := newTestSSHSigner(t)
+	_, untrustedSSHKey := newTestSSHSigner(t)
+
+	gpgSigned := commitGPGSigned(t, repo, dir, entity)
+	sshSigned := commitSSHSigned(t, repo, unsigned, signer, "git")
+	sshOtherNamespace := commitSSHSigned(t, repo, unsigned, signer, "file")
+
+	tests := []struct {
+		name    string
+		gpgKeys []byte
+		sshKeys []byte
+		commit  plumbing.Hash
+		want    Signature
+		err     error
+	}{
+		{
+			name:    "unsigned commit",
+			gpgKeys: gpgKey,
+			sshKeys: sshKey,
+			commit:  unsigned,
+			err:     ErrUnsignedCommit,
+		},
+		{
+			name:    "gpg signed by trusted key",
+			gpgKeys: gpgKey,
+			commit:  gpgSigned,
+			want: Signature{
+				KeyID: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+				Type:  SignatureTypeGPG,
+			},
+		},
+		{
+			name:    "gpg signed by untrusted key",
+			gpgKeys: untrustedGPGKey,
+			commit:  gpgSigned,
+			err:     ErrUntrustedSignature,
+		},
+		{
+			name:    "gpg signed without trusted gpg keys",
+			sshKeys: sshKey,
+			commit:  gpgSigned,
+			err:     ErrUntrustedSignature,
+		},
+		{
+			name:    "ssh signed by trusted key",
+			sshKeys: sshKey,
+			commit:  sshSigned,
+			want: Signature{
+				KeyID: sshKeyID(signer.PublicKey()),
+				Type:  SignatureTypeSSH,
+			},
+		},
+		{
+			name:    "ssh signed by untrusted key",
+			gpgKeys: gpgKey,
+			sshKeys: untrustedSSHKey,
+			commit:  sshSigned,
+			err:     ErrUntrustedSignature,
+		},
+		{
+			name:    "ssh signed for another namespace",
+			sshKeys: sshKey,
+			commit:  sshOtherNamespace,
+			err:     ErrUntrustedSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.gpgKeys, tt.sshKeys)
+			assertNoErr(t, err)
+
+			commit, err := repo.CommitObject(tt.commit)
+			assertNoErr(t, err)
+
+			got, err := k.Verify(commit)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err: want: %v got: %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("want: %+v got: %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestKeyringVerifyTamperedCommit(t *testing.T) {
+	dir := t.TempDir()
+	repo, unsigned := newTestRepo(t, dir, map[string]string{"manifest.yaml": "unsigned"})
+
+	signer, sshKey := newTestSSHSigner(t)
+	signed := commitSSHSigned(t, repo, unsigned, signer, "git")
+
+	k, err := NewKeyring(nil, sshKey)
+	assertNoErr(t, err)
+
+	commit, err := repo.CommitObject(signed)
+	assertNoErr(t, err)
+	commit.Message = "tampered"
+
+	if _, err := k.Verify(commit); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("want: %v got: %v", ErrUntrustedSignature, err)
+	}
+}
+
+func TestVerifyCommit(t *testing.T) {
+	remoteDir := t.TempDir()
+	remote, _ := newTestRepo(t, remoteDir, map[string]string{"manifest.yaml": "unsigned"})
+
+	entity, gpgKey := newTestGPGEntity(t)
+	signed := commitGPGSigned(t, remote, remoteDir, entity)
+
+	k, err := NewKeyring(gpgKey, nil)
+	assertNoErr(t, err)
+
+	baseDir := t.TempDir()
+	cl := newBasicClient(nil, WithTrustedKeys(k))
+	cl.baseDir = baseDir
+	cl.fs = os.DirFS(baseDir)
+
+	got, err := cl.VerifyCommit(context.Background(), Repository{URL: remoteDir}, signed.String())
+	assertNoErr(t, err)
+
+	want := Signature{
+		KeyID: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+		Type:  SignatureTypeGPG,
+	}
+	if got != want {
+		t.Errorf("want: %+v got: %+v", want, got)
+	}
+
+	// The fetched commit is verified from the cache.
+	got, err = cl.VerifyCommit(context.Background(), Repository{URL: remoteDir}, signed.String())
+	assertNoErr(t, err)
+	if got != want {
+		t.Errorf("want: %+v got: %+v", want, got)
+	}
+}
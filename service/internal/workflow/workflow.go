@@ -230,4 +230,5 @@ func NewParameters(environmentVariablesString, executeCommand, executeContainerI
 // CreateWorkflowResponse creates a workflow response.
 type CreateWorkflowResponse struct {
 	WorkflowName string `json:"workflow_name"`
+	SHA          string `json:"sha,omitempty"`
 }
@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/cello-proj/cello/internal/validations"
 	"github.com/cello-proj/cello/service/internal/credentials"
@@ -20,8 +23,13 @@ import (
 	"github.com/argoproj/argo-workflows/v3/cmd/argo/commands/client"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
 )
 
+// shutdownTimeout is how long requests are given to finish when the service
+// stops.
+const shutdownTimeout = 30 * time.Second
+
 var (
 // Populated during build/release
 // TODO expose these.
@@ -36,11 +44,27 @@ func main() {
 		errLogger = log.With(log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr)), "ts", log.DefaultTimestampUTC)
 	)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		dbEnv, err := env.GetDBEnv()
+		if err != nil {
+			panic(fmt.Sprintf("Unable to initialize environment variables %s", err))
+		}
+
+		if err := runMigrate(context.Background(), os.Args[2:], newDBClient(dbEnv, errLogger), os.Stdout); err != nil {
+			level.Error(errLogger).Log("message", "error migrating database", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	env, err := env.GetEnv()
 	if err != nil {
 		panic(fmt.Sprintf("Unable to initialize environment variables %s", err))
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	setLogLevel(&logger, env.LogLevel)
 
 	level.Info(logger).Log("message", fmt.Sprintf("loading config '%s'", env.ConfigFilePath))
@@ -60,31 +84,113 @@ func main() {
 		os.Exit(1)
 	}
 
-	dbClient, err := db.NewSQLClient(env.DBHost, env.DBName, env.DBUser, env.DBPassword, util.OptionsToMap(env.DBOptions))
+	dbClient := newDBClient(env.DBVars, errLogger)
+
+	if err := checkSchema(context.Background(), dbClient, env.DBMigrate, logger); err != nil {
+		level.Error(errLogger).Log("message", "error checking database schema", "error", err)
+		os.Exit(1)
+	}
+
+	issuer, err := credentialsIssuer(env)
 	if err != nil {
-		level.Error(errLogger).Log("message", "error creating db client", "error", err)
+		level.Error(errLogger).Log("message", "error creating credentials issuer", "error", err)
 		os.Exit(1)
 	}
 
+	if env.VaultAddress != "" {
+		ts, err := credentials.NewVaultTokenSource(env)
+		if err != nil {
+			level.Error(errLogger).Log("message", "error creating vault token source", "error", err)
+			os.Exit(1)
+		}
+
+		if err := ts.Login(context.Background()); err != nil {
+			level.Error(errLogger).Log("message", "error logging in to vault", "error", err)
+			os.Exit(1)
+		}
+
+		go ts.Run(context.Background(), func(err error) {
+			level.Error(errLogger).Log("message", "error renewing vault token", "error", err)
+		})
+
+		credentials.RegisterProvider(credentials.ProviderVault, credentials.NewVaultProviderFn(ts))
+	}
+
+	// The postgres provider stores credentials in the Postgres database.
+	if env.DBDriver == db.DriverPostgres {
+		credentials.RegisterProvider(credentials.ProviderPostgres, credentials.NewPostgresProviderFn(
+			credentials.NewSQLPostgresStore(dbClient.Session),
+			issuer,
+		))
+	}
+
 	// Any Argo Workflow client method calls need the context returned from NewAPIClient, otherwise
 	// nil errors will occur. Mux sets its params in context, so passing the Argo Workflow context to
 	// setupRouter and applying it to the request will wipe out Mux vars (or any other data Mux sets in its context).
 	h := handler{
 		logger:                 logger,
-		newCredentialsProvider: credentials.NewVaultProvider,
+		newCredentialsProvider: credentials.NewProvider,
 		argo:                   workflow.NewArgoWorkflow(argoClient.NewWorkflowServiceClient(), env.ArgoNamespace),
 		argoCtx:                argoCtx,
 		config:                 config,
 		gitClient:              gitClient(env, errLogger),
 		env:                    env,
 		dbClient:               dbClient,
+		batches:                newBatchRunner(env.BatchPollInterval),
+		revoker:                newTokenRevoker(),
+	}
+
+	// Tokens which aren't revoked when their grace period ends are purged by
+	// the reconciler once expired.
+	if err := h.resumeTokenRevocations(context.Background()); err != nil {
+		level.Error(errLogger).Log("message", "error scheduling token revocations", "error", err)
 	}
 
-	level.Info(logger).Log("message", "starting web service", "vault addr", env.VaultAddress, "argoAddr", env.ArgoAddress)
-	if err := http.ListenAndServeTLS(fmt.Sprintf(":%d", env.Port), "ssl/certificate.crt", "ssl/certificate.key", setupRouter(h)); err != nil {
+	if env.ReconcileInterval > 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			level.Error(errLogger).Log("message", "error reading hostname", "error", err)
+			os.Exit(1)
+		}
+
+		rc := reconciler{
+			dbClient:               dbClient,
+			env:                    env,
+			holder:                 fmt.Sprintf("%s-%s", hostname, uuid.NewString()),
+			logger:                 log.With(logger, "op", "reconcile"),
+			newCredentialsProvider: credentials.NewProvider,
+			now:                    time.Now,
+		}
+		go rc.run(ctx)
+	}
+
+	go h.interruptStaleBatches(ctx)
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", env.Port), Handler: setupRouter(h)}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServeTLS("ssl/certificate.crt", "ssl/certificate.key")
+	}()
+
+	level.Info(logger).Log("message", "starting web service", "credentialsProvider", env.CredentialsProvider, "dbDriver", env.DBDriver, "vault addr", env.VaultAddress, "argoAddr", env.ArgoAddress)
+	select {
+	case err := <-errc:
 		level.Error(errLogger).Log("message", "error starting service", "error", err)
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	level.Info(logger).Log("message", "stopping web service")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		level.Error(errLogger).Log("message", "error stopping web service", "error", err)
 	}
+
+	// Running batches are interrupted, and scheduled token revocations are
+	// scheduled again when the service starts.
+	h.batches.stop()
+	h.revoker.stop()
 }
 
 func setLogLevel(logger *log.Logger, logLevel string) {
@@ -96,11 +202,63 @@ func setLogLevel(logger *log.Logger, logLevel string) {
 	}
 }
 
+func newDBClient(env env.DBVars, errLogger log.Logger) db.SQLClient {
+	if env.DBDriver == db.DriverSQLite {
+		dbClient, err := db.NewSQLiteClient(env.DBName)
+		if err != nil {
+			level.Error(errLogger).Log("message", "error creating db client", "error", err)
+			os.Exit(1)
+		}
+		return dbClient
+	}
+
+	dbClient, err := db.NewSQLClient(env.DBHost, env.DBName, env.DBUser, env.DBPassword, util.OptionsToMap(env.DBOptions), db.PoolConfig{
+		ConnMaxIdleTime: env.DBConnMaxIdleTime,
+		ConnMaxLifetime: env.DBConnMaxLifetime,
+		MaxIdleConns:    env.DBMaxIdleConns,
+		MaxOpenConns:    env.DBMaxOpenConns,
+	})
+	if err != nil {
+		level.Error(errLogger).Log("message", "error creating db client", "error", err)
+		os.Exit(1)
+	}
+	return dbClient
+}
+
+// credentialsIssuer returns the issuer of target credentials of the postgres
+// provider. Static credentials take precedence over STS.
+func credentialsIssuer(env env.Vars) (credentials.CredentialsIssuer, error) {
+	if env.StaticCredentialsFile != "" {
+		return credentials.NewStaticCredentialsIssuer(env.StaticCredentialsFile)
+	}
+
+	if env.STSEndpoint != "" {
+		return credentials.NewSTSCredentialsIssuer(env.STSEndpoint, env.STSRegion)
+	}
+
+	return nil, nil
+}
+
 func gitClient(env env.Vars, errLogger log.Logger) git.BasicClient {
 	var cl git.BasicClient
 	var err error
 
-	var opts []git.Option
+	trustedKeys, err := git.LoadKeyring(env.GitTrustedGPGKeysFile, env.GitTrustedSSHKeysFile)
+	if err != nil {
+		level.Error(errLogger).Log("message", "error loading trusted git signing keys", "error", err)
+		os.Exit(1)
+	}
+
+	opts := []git.Option{
+		git.WithTrustedKeys(trustedKeys),
+		git.WithTimeout(env.GitTimeout),
+		git.WithFetchDepth(env.GitFetchDepth),
+		git.WithCacheMaxSize(env.GitCacheMaxSizeMB * 1024 * 1024),
+		git.WithMaxManifestSize(env.GitMaxManifestSizeKB * 1024),
+	}
+	if env.GitCacheDir != "" {
+		opts = append(opts, git.WithCacheDir(env.GitCacheDir))
+	}
 	if env.LogLevel == "DEBUG" {
 		opts = append(opts, git.WithProgressWriter(os.Stdout))
 	}
@@ -118,5 +276,10 @@ func gitClient(env env.Vars, errLogger log.Logger) git.BasicClient {
 		os.Exit(1)
 	}
 
+	if err := cl.InitCache(); err != nil {
+		level.Error(errLogger).Log("message", "error initializing git cache", "error", err)
+		os.Exit(1)
+	}
+
 	return cl
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+const migrateUsage = "usage: migrate up|down [n]|status"
+
+// schemaMigrator applies the embedded schema migrations.
+type schemaMigrator interface {
+	MigrateDown(ctx context.Context, n uint) (db.SchemaStatus, error)
+	MigrateUp(ctx context.Context) (db.SchemaStatus, error)
+	SchemaStatus(ctx context.Context) (db.SchemaStatus, error)
+}
+
+// runMigrate runs the migrate subcommand. down reverts one migration unless a
+// number is given.
+func runMigrate(ctx context.Context, args []string, m schemaMigrator, w io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	var status db.SchemaStatus
+	var err error
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		status, err = m.MigrateUp(ctx)
+	case "down":
+		n := uint64(1)
+		if len(args) == 2 {
+			n, err = strconv.ParseUint(args[1], 10, 32)
+			if err != nil || n == 0 {
+				return fmt.Errorf("invalid number of migrations %s", args[1])
+			}
+		} else if len(args) > 2 {
+			return errors.New(migrateUsage)
+		}
+		status, err = m.MigrateDown(ctx, uint(n))
+	case "status":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		status, err = m.SchemaStatus(ctx)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "version: %d\nlatest: %d\ndirty: %t\n", status.Version, status.Latest, status.Dirty)
+	return nil
+}
+
+// checkSchema applies pending migrations when enabled and returns an error
+// when the service can't use the schema of the database.
+func checkSchema(ctx context.Context, m schemaMigrator, migrate bool, logger log.Logger) error {
+	var status db.SchemaStatus
+	var err error
+
+	if migrate {
+		status, err = m.MigrateUp(ctx)
+	} else {
+		status, err = m.SchemaStatus(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := status.Check(); err != nil {
+		return err
+	}
+
+	if status.Version < status.Latest {
+		level.Warn(logger).Log("message", "database schema has pending migrations", "version", status.Version, "latest", status.Latest)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSchemaMigrator struct {
+	status db.SchemaStatus
+	err    error
+	calls  []string
+	downN  uint
+}
+
+func (m *fakeSchemaMigrator) MigrateDown(ctx context.Context, n uint) (db.SchemaStatus, error) {
+	m.calls = append(m.calls, "down")
+	m.downN = n
+	return m.status, m.err
+}
+
+func (m *fakeSchemaMigrator) MigrateUp(ctx context.Context) (db.SchemaStatus, error) {
+	m.calls = append(m.calls, "up")
+	return m.status, m.err
+}
+
+func (m *fakeSchemaMigrator) SchemaStatus(ctx context.Context) (db.SchemaStatus, error) {
+	m.calls = append(m.calls, "status")
+	return m.status, m.err
+}
+
+func TestRunMigrate(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		err       error
+		wantCall  string
+		wantDownN uint
+		wantOut   string
+		wantErr   string
+	}{
+		{
+			name:     "up",
+			args:     []string{"up"},
+			wantCall: "up",
+			wantOut:  "version: 8\nlatest: 8\ndirty: false\n",
+		},
+		{
+			name:      "down one migration by default",
+			args:      []string{"down"},
+			wantCall:  "down",
+			wantDownN: 1,
+			wantOut:   "version: 8\nlatest: 8\ndirty: false\n",
+		},
+		{
+			name:      "down number of migrations",
+			args:      []string{"down", "3"},
+			wantCall:  "down",
+			wantDownN: 3,
+			wantOut:   "version: 8\nlatest: 8\ndirty: false\n",
+		},
+		{
+			name:    "down invalid number of migrations",
+			args:    []string{"down", "0"},
+			wantErr: "invalid number of migrations 0",
+		},
+		{
+			name:     "status",
+			args:     []string{"status"},
+			wantCall: "status",
+			wantOut:  "version: 8\nlatest: 8\ndirty: false\n",
+		},
+		{
+			name:    "no command",
+			wantErr: migrateUsage,
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"force"},
+			wantErr: migrateUsage,
+		},
+		{
+			name:     "migration error",
+			args:     []string{"up"},
+			err:      errors.New("error"),
+			wantCall: "up",
+			wantErr:  "error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeSchemaMigrator{status: db.SchemaStatus{Latest: 8, Version: 8}, err: tt.err}
+			var out bytes.Buffer
+
+			err := runMigrate(context.Background(), tt.args, m, &out)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tt.wantCall != "" {
+				assert.Equal(t, []string{tt.wantCall}, m.calls)
+			} else {
+				assert.Empty(t, m.calls)
+			}
+			assert.Equal(t, tt.wantDownN, m.downN)
+			assert.Equal(t, tt.wantOut, out.String())
+		})
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		migrate  bool
+		status   db.SchemaStatus
+		wantCall string
+		wantErr  error
+	}{
+		{
+			name:     "applies migrations when enabled",
+			migrate:  true,
+			status:   db.SchemaStatus{Latest: 8, Version: 8},
+			wantCall: "up",
+		},
+		{
+			name:     "only reads the version when disabled",
+			status:   db.SchemaStatus{Latest: 8, Version: 7},
+			wantCall: "status",
+		},
+		{
+			name:     "refuses unknown versions",
+			status:   db.SchemaStatus{Latest: 8, Version: 9},
+			wantCall: "status",
+			wantErr:  db.ErrUnknownSchemaVersion,
+		},
+		{
+			name:     "refuses dirty schemas",
+			status:   db.SchemaStatus{Latest: 8, Version: 8, Dirty: true},
+			wantCall: "status",
+			wantErr:  db.ErrDirtySchema,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeSchemaMigrator{status: tt.status}
+
+			err := checkSchema(context.Background(), m, tt.migrate, log.NewNopLogger())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, []string{tt.wantCall}, m.calls)
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/env"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+const (
+	reconciliationName = "default"
+
+	issueExpiredToken             = "expired_token"
+	issueProjectMissingFromCP     = "project_missing_from_credentials_provider"
+	issueProjectMissingFromDB     = "project_missing_from_database"
+	issueTargetMissingFromCP      = "target_missing_from_credentials_provider"
+	issueTargetMissingFromDB      = "target_missing_from_database"
+	issueTokenMissingFromCP       = "token_missing_from_credentials_provider"
+	issueTokenMissingFromDB       = "token_missing_from_database"
+	reconciliationTimestampFormat = time.RFC3339
+	reconciliationLeaseIntervals  = 2
+	reconciliationGraceIntervals  = 2
+)
+
+// reconciler purges expired tokens, imports targets into the database and
+// finds projects, tokens and targets which only exist in either the
+// credentials provider or the database. Replicas share a lease in the database
+// so only one of them reconciles at a time. Requests write projects and tokens
+// to one store and then the other, so entries created within the grace
+// window are skipped until they're written to both.
+type reconciler struct {
+	dbClient               db.Client
+	env                    env.Vars
+	holder                 string
+	logger                 log.Logger
+	newCredentialsProvider func(a credentials.Authorization, env env.Vars, h http.Header, vaultConfig credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error)
+	now                    func() time.Time
+}
+
+// run reconciles every interval until the context is done.
+func (rc reconciler) run(ctx context.Context) {
+	ticker := time.NewTicker(rc.env.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := rc.reconcile(ctx); err != nil {
+			level.Error(rc.logger).Log("message", "error reconciling", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile reconciles when the lease can be acquired. It returns false when
+// another replica holds the lease.
+func (rc reconciler) reconcile(ctx context.Context) (bool, error) {
+	now := rc.now()
+
+	// The lease outlives the interval so the holder keeps it between runs,
+	// while another replica takes over when the holder stops.
+	leaseExpiresAt := now.Add(rc.env.ReconcileInterval * reconciliationLeaseIntervals)
+	acquired, err := rc.dbClient.AcquireReconciliationLease(ctx, reconciliationName, rc.holder, now, leaseExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("unable to acquire lease: %w", err)
+	}
+
+	if !acquired {
+		level.Debug(rc.logger).Log("message", "reconciliation lease held by another replica")
+		return false, nil
+	}
+
+	report, err := rc.reconcileStores(ctx)
+	if err != nil {
+		return true, err
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		return true, fmt.Errorf("unable to serialize report: %w", err)
+	}
+
+	if err := rc.dbClient.UpdateReconciliationReport(ctx, reconciliationName, rc.holder, string(b)); err != nil {
+		return true, fmt.Errorf("unable to store report: %w", err)
+	}
+
+	level.Info(rc.logger).Log("message", "reconciliation completed", "findings", len(report.Findings), "errors", len(report.Errors))
+	return true, nil
+}
+
+func (rc reconciler) reconcileStores(ctx context.Context) (responses.GetReconciliation, error) {
+	report := responses.GetReconciliation{
+		Errors:    []string{},
+		Findings:  []responses.ReconciliationFinding{},
+		Holder:    rc.holder,
+		Repair:    rc.env.ReconcileRepair,
+		StartedAt: rc.now().Format(reconciliationTimestampFormat),
+	}
+
+	a := credentials.Authorization{Provider: rc.env.CredentialsProvider, Key: "admin", Secret: rc.env.AdminSecret}
+	cp, err := rc.newCredentialsProvider(a, rc.env, http.Header{}, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		return report, fmt.Errorf("unable to create credentials provider: %w", err)
+	}
+
+	cpProjects, err := cp.ListProjects(ctx)
+	if err != nil {
+		return report, fmt.Errorf("unable to list projects from credentials provider: %w", err)
+	}
+
+	dbProjects, _, err := rc.dbClient.ListProjectEntries(ctx, db.ProjectFilter{})
+	if err != nil {
+		return report, fmt.Errorf("unable to list projects from database: %w", err)
+	}
+
+	inCP := map[string]bool{}
+	for _, p := range cpProjects {
+		inCP[p] = true
+	}
+
+	inDB := map[string]bool{}
+	createdAt := map[string]string{}
+	for _, p := range dbProjects {
+		inDB[p.ProjectID] = true
+		createdAt[p.ProjectID] = p.CreatedAt
+	}
+
+	projects := []string{}
+	for p := range inCP {
+		projects = append(projects, p)
+	}
+	for p := range inDB {
+		if !inCP[p] {
+			projects = append(projects, p)
+		}
+	}
+	sort.Strings(projects)
+
+	for _, project := range projects {
+		l := log.With(rc.logger, "project", project)
+
+		switch {
+		case !inDB[project]:
+			// The repository is unknown, so the project can't be repaired.
+			report.Findings = append(report.Findings, responses.ReconciliationFinding{Issue: issueProjectMissingFromDB, Project: project})
+			continue
+		case !inCP[project] && rc.inGracePeriod(createdAt[project]):
+			level.Debug(l).Log("message", "skipping project being created")
+			continue
+		case !inCP[project]:
+			finding := responses.ReconciliationFinding{Issue: issueProjectMissingFromCP, Project: project}
+			if rc.env.ReconcileRepair {
+				level.Info(l).Log("message", "deleting project from database")
+				if err := rc.dbClient.DeleteProjectEntry(ctx, project); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("unable to delete project '%s' from database: %s", project, err))
+				} else {
+					finding.Repaired = true
+				}
+			}
+			report.Findings = append(report.Findings, finding)
+			continue
+		}
+
+		findings, errs := rc.reconcileTokens(ctx, l, cp, project)
+		report.Findings = append(report.Findings, findings...)
+		report.Errors = append(report.Errors, errs...)
+
+		findings, errs = rc.reconcileTargets(ctx, l, cp, project)
+		report.Findings = append(report.Findings, findings...)
+		report.Errors = append(report.Errors, errs...)
+	}
+
+	report.FinishedAt = rc.now().Format(reconciliationTimestampFormat)
+	return report, nil
+}
+
+// reconcileTokens purges the expired tokens of the project and finds the
+// tokens which only exist in one of the stores.
+func (rc reconciler) reconcileTokens(ctx context.Context, l log.Logger, cp credentials.Provider, project string) ([]responses.ReconciliationFinding, []string) {
+	findings := []responses.ReconciliationFinding{}
+	errs := []string{}
+
+	cpTokens, err := cp.ListProjectTokens(ctx, project)
+	if err != nil {
+		return findings, append(errs, fmt.Sprintf("unable to list tokens of project '%s' from credentials provider: %s", project, err))
+	}
+
+	dbTokens, err := rc.dbClient.ListTokenEntries(ctx, project)
+	if err != nil {
+		return findings, append(errs, fmt.Sprintf("unable to list tokens of project '%s' from database: %s", project, err))
+	}
+
+	inCP := map[string]bool{}
+	for _, t := range cpTokens {
+		inCP[t.ID] = true
+	}
+
+	inDB := map[string]bool{}
+	now := rc.now()
+	for _, t := range dbTokens {
+		inDB[t.TokenID] = true
+
+		switch {
+		case t.IsExpired(now):
+			// Expired tokens are always purged.
+			finding := responses.ReconciliationFinding{Issue: issueExpiredToken, Project: project, TokenID: t.TokenID}
+			level.Info(l).Log("message", "purging expired token", "tokenID", t.TokenID)
+			if err := rc.deleteToken(ctx, cp, project, t.TokenID, inCP[t.TokenID], true); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				finding.Repaired = true
+			}
+			findings = append(findings, finding)
+		case !inCP[t.TokenID]:
+			finding := responses.ReconciliationFinding{Issue: issueTokenMissingFromCP, Project: project, TokenID: t.TokenID}
+			if rc.env.ReconcileRepair {
+				level.Info(l).Log("message", "deleting token from database", "tokenID", t.TokenID)
+				if err := rc.deleteToken(ctx, cp, project, t.TokenID, false, true); err != nil {
+					errs = append(errs, err.Error())
+				} else {
+					finding.Repaired = true
+				}
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	for _, t := range cpTokens {
+		if inDB[t.ID] {
+			continue
+		}
+
+		// Tokens are created in the credentials provider before the
+		// database.
+		token, err := cp.GetProjectToken(ctx, project, t.ID)
+		if errors.Is(err, credentials.ErrProjectTokenNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("unable to get token '%s' of project '%s' from credentials provider: %s", t.ID, project, err))
+			continue
+		}
+		if rc.inGracePeriod(token.CreatedAt) {
+			level.Debug(l).Log("message", "skipping token being created", "tokenID", t.ID)
+			continue
+		}
+
+		// Tokens without a database entry are unscoped and don't count
+		// towards the token limit.
+		finding := responses.ReconciliationFinding{Issue: issueTokenMissingFromDB, Project: project, TokenID: t.ID}
+		if rc.env.ReconcileRepair {
+			level.Info(l).Log("message", "deleting token from credentials provider", "tokenID", t.ID)
+			if err := rc.deleteToken(ctx, cp, project, t.ID, true, false); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				finding.Repaired = true
+			}
+		}
+		findings = append(findings, finding)
+	}
+
+	return findings, errs
+}
+
+// reconcileTargets imports the targets of the project which are missing from
+// the database and finds the targets which only exist in the database.
+func (rc reconciler) reconcileTargets(ctx context.Context, l log.Logger, cp credentials.Provider, project string) ([]responses.ReconciliationFinding, []string) {
+	findings := []responses.ReconciliationFinding{}
+	errs := []string{}
+
+	cpTargets, err := cp.ListTargets(ctx, project)
+	if err != nil {
+		return findings, append(errs, fmt.Sprintf("unable to list targets of project '%s' from credentials provider: %s", project, err))
+	}
+	sort.Strings(cpTargets)
+
+	dbTargets, err := rc.dbClient.ListTargetEntries(ctx, project, db.TargetFilter{})
+	if err != nil {
+		return findings, append(errs, fmt.Sprintf("unable to list targets of project '%s' from database: %s", project, err))
+	}
+
+	inCP := map[string]bool{}
+	for _, t := range cpTargets {
+		inCP[t] = true
+	}
+
+	entries := map[string]db.TargetEntry{}
+	for _, te := range dbTargets {
+		entries[te.TargetName] = te
+
+		if inCP[te.TargetName] {
+			continue
+		}
+
+		finding := responses.ReconciliationFinding{Issue: issueTargetMissingFromCP, Project: project, Target: te.TargetName}
+		if rc.env.ReconcileRepair {
+			level.Info(l).Log("message", "deleting target from database", "target", te.TargetName)
+			if err := rc.dbClient.DeleteTargetEntry(ctx, project, te.TargetName, nil); err != nil {
+				errs = append(errs, fmt.Sprintf("unable to delete target '%s' of project '%s' from database: %s", te.TargetName, project, err))
+			} else {
+				finding.Repaired = true
+			}
+		}
+		findings = append(findings, finding)
+	}
+
+	for _, name := range cpTargets {
+		te, ok := entries[name]
+		if ok && te.IsImported() {
+			continue
+		}
+
+		// The database holds the inventory of targets, so targets are
+		// always imported.
+		finding := responses.ReconciliationFinding{Issue: issueTargetMissingFromDB, Project: project, Target: name}
+		level.Info(l).Log("message", "importing target into database", "target", name)
+		if err := rc.importTarget(ctx, cp, project, name, te.Region); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			finding.Repaired = true
+		}
+		findings = append(findings, finding)
+	}
+
+	return findings, errs
+}
+
+// importTarget creates the entry of the target from the credentials provider
+// with the region of the existing entry.
+func (rc reconciler) importTarget(ctx context.Context, cp credentials.Provider, project, name, region string) error {
+	target, err := cp.GetTarget(ctx, project, name)
+	if err != nil {
+		return fmt.Errorf("unable to get target '%s' of project '%s' from credentials provider: %w", name, project, err)
+	}
+	target.Properties.Region = region
+
+	if err := rc.dbClient.UpsertTargetEntry(ctx, db.NewTargetEntry(project, target), nil); err != nil {
+		return fmt.Errorf("unable to import target '%s' of project '%s' into database: %w", name, project, err)
+	}
+	return nil
+}
+
+// inGracePeriod returns whether an entry created at createdAt may still be
+// being written to the other store. Entries without a creation time are
+// never in the grace period.
+func (rc reconciler) inGracePeriod(createdAt string) bool {
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return false
+	}
+
+	return rc.now().Sub(t) < rc.env.ReconcileInterval*reconciliationGraceIntervals
+}
+
+func (rc reconciler) deleteToken(ctx context.Context, cp credentials.Provider, project, tokenID string, fromCP, fromDB bool) error {
+	if fromCP {
+		if err := cp.DeleteProjectToken(ctx, project, tokenID); err != nil {
+			return fmt.Errorf("unable to delete token '%s' of project '%s' from credentials provider: %w", tokenID, project, err)
+		}
+	}
+
+	if fromDB {
+		if err := rc.dbClient.DeleteTokenEntry(ctx, tokenID); err != nil {
+			return fmt.Errorf("unable to delete token '%s' of project '%s' from database: %w", tokenID, project, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/env"
+	th "github.com/cello-proj/cello/service/test/testhelpers"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour).Format(time.RFC3339Nano)
+	valid := now.Add(time.Hour).Format(time.RFC3339Nano)
+	recent := now.Add(-10 * time.Minute).Format(time.RFC3339Nano)
+
+	tests := []struct {
+		name            string
+		repair          bool
+		leaseErr        error
+		leaseAcquired   bool
+		cpProjects      []string
+		dbProjects      []string
+		createdAt       map[string]string
+		cpTokens        map[string][]string
+		dbTokens        map[string][]db.TokenEntry
+		cpTargets       map[string][]string
+		dbTargets       map[string][]db.TargetEntry
+		wantAcquired    bool
+		wantErr         error
+		wantFindings    []responses.ReconciliationFinding
+		wantCPDeletes   []string
+		wantDBDeletes   []string
+		wantProjDeletes []string
+		wantImports     []db.TargetEntry
+		wantTgtDeletes  []string
+	}{
+		{
+			name:          "lease held by another replica",
+			leaseAcquired: false,
+			wantAcquired:  false,
+		},
+		{
+			name:     "lease error",
+			leaseErr: errors.New("error"),
+			wantErr:  errors.New("unable to acquire lease: error"),
+		},
+		{
+			name:          "reports drift without repairing",
+			leaseAcquired: true,
+			cpProjects:    []string{"project1", "project2"},
+			dbProjects:    []string{"project1", "project3"},
+			cpTokens: map[string][]string{
+				"project1": {"token1", "token2", "token3"},
+			},
+			dbTokens: map[string][]db.TokenEntry{
+				"project1": {
+					{TokenID: "token1", ExpiresAt: valid},
+					{TokenID: "token2", ExpiresAt: expired},
+					{TokenID: "token4", ExpiresAt: valid},
+				},
+			},
+			cpTargets: map[string][]string{
+				"project1": {"target3", "target1", "target2"},
+			},
+			dbTargets: map[string][]db.TargetEntry{
+				"project1": {
+					{TargetName: "target1", Type: "aws_account"},
+					{TargetName: "target2", Region: "us-west-2"},
+					{TargetName: "target4", Type: "aws_account"},
+				},
+			},
+			wantAcquired: true,
+			wantFindings: []responses.ReconciliationFinding{
+				{Issue: issueExpiredToken, Project: "project1", Repaired: true, TokenID: "token2"},
+				{Issue: issueTokenMissingFromCP, Project: "project1", TokenID: "token4"},
+				{Issue: issueTokenMissingFromDB, Project: "project1", TokenID: "token3"},
+				{Issue: issueTargetMissingFromCP, Project: "project1", Target: "target4"},
+				{Issue: issueTargetMissingFromDB, Project: "project1", Repaired: true, Target: "target2"},
+				{Issue: issueTargetMissingFromDB, Project: "project1", Repaired: true, Target: "target3"},
+				{Issue: issueProjectMissingFromDB, Project: "project2"},
+				{Issue: issueProjectMissingFromCP, Project: "project3"},
+			},
+			wantCPDeletes: []string{"token2"},
+			wantDBDeletes: []string{"token2"},
+			wantImports: []db.TargetEntry{
+				{ProjectID: "project1", TargetName: "target2", Region: "us-west-2", Properties: db.TargetProperties{Region: "us-west-2"}, Type: "aws_account"},
+				{ProjectID: "project1", TargetName: "target3", Type: "aws_account"},
+			},
+		},
+		{
+			name:          "repairs drift",
+			repair:        true,
+			leaseAcquired: true,
+			cpProjects:    []string{"project1", "project2"},
+			dbProjects:    []string{"project1", "project3"},
+			cpTokens: map[string][]string{
+				"project1": {"token1", "token3"},
+			},
+			dbTokens: map[string][]db.TokenEntry{
+				"project1": {
+					{TokenID: "token1", ExpiresAt: valid},
+					{TokenID: "token2", ExpiresAt: expired},
+					{TokenID: "token4", ExpiresAt: valid},
+				},
+			},
+			cpTargets: map[string][]string{
+				"project1": {"target3", "target1", "target2"},
+			},
+			dbTargets: map[string][]db.TargetEntry{
+				"project1": {
+					{TargetName: "target1", Type: "aws_account"},
+					{TargetName: "target2", Region: "us-west-2"},
+					{TargetName: "target4", Type: "aws_account"},
+				},
+			},
+			wantAcquired: true,
+			wantFindings: []responses.ReconciliationFinding{
+				{Issue: issueExpiredToken, Project: "project1", Repaired: true, TokenID: "token2"},
+				{Issue: issueTokenMissingFromCP, Project: "project1", Repaired: true, TokenID: "token4"},
+				{Issue: issueTokenMissingFromDB, Project: "project1", Repaired: true, TokenID: "token3"},
+				{Issue: issueTargetMissingFromCP, Project: "project1", Repaired: true, Target: "target4"},
+				{Issue: issueTargetMissingFromDB, Project: "project1", Repaired: true, Target: "target2"},
+				{Issue: issueTargetMissingFromDB, Project: "project1", Repaired: true, Target: "target3"},
+				{Issue: issueProjectMissingFromDB, Project: "project2"},
+				{Issue: issueProjectMissingFromCP, Project: "project3", Repaired: true},
+			},
+			wantCPDeletes:   []string{"token3"},
+			wantDBDeletes:   []string{"token2", "token4"},
+			wantProjDeletes: []string{"project3"},
+			wantImports: []db.TargetEntry{
+				{ProjectID: "project1", TargetName: "target2", Region: "us-west-2", Properties: db.TargetProperties{Region: "us-west-2"}, Type: "aws_account"},
+				{ProjectID: "project1", TargetName: "target3", Type: "aws_account"},
+			},
+			wantTgtDeletes: []string{"target4"},
+		},
+		{
+			name:          "skips projects and tokens being created",
+			repair:        true,
+			leaseAcquired: true,
+			cpProjects:    []string{"project1"},
+			dbProjects:    []string{"project1", "project2"},
+			createdAt: map[string]string{
+				"project2": recent,
+				"token2":   recent,
+			},
+			cpTokens: map[string][]string{
+				"project1": {"token1", "token2"},
+			},
+			dbTokens: map[string][]db.TokenEntry{
+				"project1": {
+					{TokenID: "token1", ExpiresAt: valid},
+				},
+			},
+			wantAcquired: true,
+			wantFindings: []responses.ReconciliationFinding{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				cpDeletes   []string
+				dbDeletes   []string
+				projDeletes []string
+				imports     []db.TargetEntry
+				tgtDeletes  []string
+				report      string
+			)
+
+			cpMock := &th.CredsProviderMock{
+				ListProjectsFunc: func(ctx context.Context) ([]string, error) { return tt.cpProjects, nil },
+				ListProjectTokensFunc: func(ctx context.Context, project string) ([]types.ProjectToken, error) {
+					tokens := []types.ProjectToken{}
+					for _, id := range tt.cpTokens[project] {
+						tokens = append(tokens, types.ProjectToken{ID: id})
+					}
+					return tokens, nil
+				},
+				GetProjectTokenFunc: func(ctx context.Context, project, id string) (types.ProjectToken, error) {
+					return types.ProjectToken{CreatedAt: tt.createdAt[id], ID: id}, nil
+				},
+				DeleteProjectTokenFunc: func(ctx context.Context, project, id string) error {
+					cpDeletes = append(cpDeletes, id)
+					return nil
+				},
+				ListTargetsFunc: func(ctx context.Context, project string) ([]string, error) {
+					return append([]string{}, tt.cpTargets[project]...), nil
+				},
+				GetTargetFunc: func(ctx context.Context, project, target string) (types.Target, error) {
+					return types.Target{Name: target, Type: "aws_account"}, nil
+				},
+			}
+
+			dbMock := &th.DBClientMock{
+				AcquireReconciliationLeaseFunc: func(ctx context.Context, name, holder string, n, expiresAt time.Time) (bool, error) {
+					assert.Equal(t, reconciliationName, name)
+					assert.Equal(t, now.Add(2*time.Hour), expiresAt)
+					return tt.leaseAcquired, tt.leaseErr
+				},
+				ListProjectEntriesFunc: func(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error) {
+					assert.Equal(t, db.ProjectFilter{}, filter)
+					entries := []db.ProjectEntry{}
+					for _, p := range tt.dbProjects {
+						entries = append(entries, db.ProjectEntry{CreatedAt: tt.createdAt[p], ProjectID: p})
+					}
+					return entries, uint64(len(entries)), nil
+				},
+				ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
+					return tt.dbTokens[project], nil
+				},
+				DeleteTokenEntryFunc: func(ctx context.Context, token string) error {
+					dbDeletes = append(dbDeletes, token)
+					return nil
+				},
+				DeleteProjectEntryFunc: func(ctx context.Context, project string) error {
+					projDeletes = append(projDeletes, project)
+					return nil
+				},
+				ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+					return tt.dbTargets[project], nil
+				},
+				UpsertTargetEntryFunc: func(ctx context.Context, te db.TargetEntry, write func() error) error {
+					imports = append(imports, te)
+					return nil
+				},
+				DeleteTargetEntryFunc: func(ctx context.Context, project, target string, remove func() error) error {
+					tgtDeletes = append(tgtDeletes, target)
+					return nil
+				},
+				UpdateReconciliationReportFunc: func(ctx context.Context, name, holder, r string) error {
+					report = r
+					return nil
+				},
+			}
+
+			rc := reconciler{
+				dbClient: dbMock,
+				env: env.Vars{
+					AdminSecret:       testPassword,
+					ReconcileInterval: time.Hour,
+					ReconcileRepair:   tt.repair,
+				},
+				holder: "holder1",
+				logger: log.NewNopLogger(),
+				newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					return cpMock, nil
+				},
+				now: func() time.Time { return now },
+			}
+
+			acquired, err := rc.reconcile(context.Background())
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantAcquired, acquired)
+
+			if !tt.wantAcquired {
+				assert.Empty(t, dbMock.ListProjectEntriesCalls())
+				return
+			}
+
+			var got responses.GetReconciliation
+			assert.NoError(t, json.Unmarshal([]byte(report), &got))
+			assert.Equal(t, tt.wantFindings, got.Findings)
+			assert.Empty(t, got.Errors)
+			assert.Equal(t, tt.repair, got.Repair)
+			assert.Equal(t, "holder1", got.Holder)
+			assert.Equal(t, tt.wantCPDeletes, cpDeletes)
+			assert.Equal(t, tt.wantDBDeletes, dbDeletes)
+			assert.Equal(t, tt.wantProjDeletes, projDeletes)
+			assert.Equal(t, tt.wantImports, imports)
+			assert.Equal(t, tt.wantTgtDeletes, tgtDeletes)
+		})
+	}
+}
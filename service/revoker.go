@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/credentials"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	upper "github.com/upper/db/v4"
+)
+
+// tokenRevoker revokes tokens when they expire, so tokens rotated with a grace
+// period stop authenticating with the credentials provider when the grace
+// period ends. Revocations are scheduled again when the service starts.
+type tokenRevoker struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	wg     sync.WaitGroup
+}
+
+func newTokenRevoker() *tokenRevoker {
+	return &tokenRevoker{timers: map[string]*time.Timer{}}
+}
+
+// schedule runs revoke for the token at the time, replacing the revocation
+// already scheduled for it.
+func (t *tokenRevoker) schedule(tokenID string, at time.Time, revoke func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if timer, ok := t.timers[tokenID]; ok && timer.Stop() {
+		t.wg.Done()
+	}
+
+	t.wg.Add(1)
+	t.timers[tokenID] = time.AfterFunc(time.Until(at), func() {
+		defer t.wg.Done()
+
+		t.mu.Lock()
+		delete(t.timers, tokenID)
+		t.mu.Unlock()
+
+		revoke()
+	})
+}
+
+// stop cancels the scheduled revocations and waits for the running ones to
+// finish.
+func (t *tokenRevoker) stop() {
+	t.mu.Lock()
+	for tokenID, timer := range t.timers {
+		if timer.Stop() {
+			t.wg.Done()
+		}
+		delete(t.timers, tokenID)
+	}
+	t.mu.Unlock()
+
+	t.wg.Wait()
+}
+
+// scheduleTokenRevocation revokes the token of the project from the named
+// credentials provider when it expires.
+func (h handler) scheduleTokenRevocation(provider, project, tokenID string, expiresAt time.Time) {
+	l := log.With(h.logger, "op", "revoke-token", "project", project, "tokenID", tokenID)
+
+	h.revoker.schedule(tokenID, expiresAt, func() {
+		if err := h.revokeExpiredToken(context.Background(), provider, project, tokenID); err != nil {
+			level.Error(l).Log("message", "error revoking expired token", "error", err)
+		}
+	})
+}
+
+// resumeTokenRevocations schedules the revocation of the rotated tokens whose
+// grace period hasn't ended. Tokens which already expired are purged by the
+// reconciler. The provider of the tokens isn't stored, so they're revoked from
+// the credentials provider of the service.
+func (h handler) resumeTokenRevocations(ctx context.Context) error {
+	tokens, err := h.dbClient.ListRotatedTokenEntries(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to list rotated tokens from database: %w", err)
+	}
+
+	for _, te := range tokens {
+		expiresAt, err := time.Parse(time.RFC3339Nano, te.ExpiresAt)
+		if err != nil {
+			continue
+		}
+		h.scheduleTokenRevocation(h.env.CredentialsProvider, te.ProjectID, te.TokenID, expiresAt)
+	}
+
+	return nil
+}
+
+// revokeExpiredToken deletes the token of the project from the named
+// credentials provider and the database when its entry expired. Tokens which
+// were deleted, or whose expiry changed, are left unchanged.
+func (h handler) revokeExpiredToken(ctx context.Context, provider, project, tokenID string) error {
+	te, err := h.dbClient.ReadTokenEntry(ctx, tokenID)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read token from database: %w", err)
+	}
+
+	if te.ProjectID != project || !te.IsExpired(time.Now()) {
+		return nil
+	}
+
+	cp, err := h.newAdminCredentialsProvider(provider)
+	if err != nil {
+		return fmt.Errorf("unable to create credentials provider: %w", err)
+	}
+
+	// Tokens which expired in the credentials provider too are only deleted
+	// from the database.
+	_, err = cp.GetProjectToken(ctx, project, tokenID)
+	switch {
+	case err == nil:
+		if err := cp.DeleteProjectToken(ctx, project, tokenID); err != nil {
+			return fmt.Errorf("unable to delete token from credentials provider: %w", err)
+		}
+	case !errors.Is(err, credentials.ErrProjectTokenNotFound):
+		return fmt.Errorf("unable to read token from credentials provider: %w", err)
+	}
+
+	if err := h.dbClient.DeleteTokenEntry(ctx, tokenID); err != nil {
+		return fmt.Errorf("unable to delete token from database: %w", err)
+	}
+	return nil
+}
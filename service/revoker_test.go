@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/env"
+	th "github.com/cello-proj/cello/service/test/testhelpers"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	upper "github.com/upper/db/v4"
+)
+
+func TestTokenRevoker(t *testing.T) {
+	t.Run("revokes at the scheduled time", func(t *testing.T) {
+		tr := newTokenRevoker()
+		revoked := make(chan string, 1)
+		tr.schedule("1234", time.Now().Add(-time.Second), func() { revoked <- "1234" })
+
+		select {
+		case got := <-revoked:
+			assert.Equal(t, "1234", got)
+		case <-time.After(time.Second):
+			t.Fatal("token not revoked")
+		}
+		tr.stop()
+	})
+
+	t.Run("replaces scheduled revocation", func(t *testing.T) {
+		tr := newTokenRevoker()
+		var (
+			mu   sync.Mutex
+			runs []string
+		)
+		tr.schedule("1234", time.Now().Add(time.Hour), func() {
+			mu.Lock()
+			defer mu.Unlock()
+			runs = append(runs, "first")
+		})
+		tr.schedule("1234", time.Now(), func() {
+			mu.Lock()
+			defer mu.Unlock()
+			runs = append(runs, "second")
+		})
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(runs) == 1
+		}, time.Second, time.Millisecond)
+		tr.stop()
+		assert.Equal(t, []string{"second"}, runs)
+	})
+
+	t.Run("stop cancels scheduled revocations", func(t *testing.T) {
+		tr := newTokenRevoker()
+		tr.schedule("1234", time.Now().Add(time.Hour), func() { t.Error("revoked after stop") })
+		tr.stop()
+	})
+}
+
+func TestRevokeExpiredToken(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).Format(time.RFC3339Nano)
+	valid := time.Now().Add(time.Hour).Format(time.RFC3339Nano)
+
+	tests := []struct {
+		name          string
+		entry         db.TokenEntry
+		readErr       error
+		cpErr         error
+		wantErr       error
+		wantCPDelete  bool
+		wantDBDeleted bool
+	}{
+		{
+			name:          "revokes expired token",
+			entry:         db.TokenEntry{ProjectID: "project1", TokenID: "1234", ExpiresAt: expired},
+			wantCPDelete:  true,
+			wantDBDeleted: true,
+		},
+		{
+			name:          "deletes token expired in credentials provider from database",
+			entry:         db.TokenEntry{ProjectID: "project1", TokenID: "1234", ExpiresAt: expired},
+			cpErr:         credentials.ErrProjectTokenNotFound,
+			wantDBDeleted: true,
+		},
+		{
+			name:    "credentials provider error",
+			entry:   db.TokenEntry{ProjectID: "project1", TokenID: "1234", ExpiresAt: expired},
+			cpErr:   errors.New("error"),
+			wantErr: errors.New("unable to read token from credentials provider: error"),
+		},
+		{
+			name:  "token not expired",
+			entry: db.TokenEntry{ProjectID: "project1", TokenID: "1234", ExpiresAt: valid},
+		},
+		{
+			name:    "token deleted",
+			readErr: upper.ErrNoMoreRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cpDeleted, dbDeleted bool
+			cpMock := &th.CredsProviderMock{
+				GetProjectTokenFunc: func(ctx context.Context, project, tokenID string) (types.ProjectToken, error) {
+					return types.ProjectToken{ID: tokenID}, tt.cpErr
+				},
+				DeleteProjectTokenFunc: func(ctx context.Context, project, tokenID string) error {
+					cpDeleted = true
+					return nil
+				},
+			}
+			dbMock := &th.DBClientMock{
+				ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
+					return tt.entry, tt.readErr
+				},
+				DeleteTokenEntryFunc: func(ctx context.Context, token string) error {
+					dbDeleted = true
+					return nil
+				},
+			}
+
+			h := handler{
+				logger: log.NewNopLogger(),
+				env:    env.Vars{CredentialsProvider: credentials.ProviderVault},
+				newCredentialsProvider: func(a credentials.Authorization, env env.Vars, h http.Header, f credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error) {
+					assert.Equal(t, credentials.ProviderPostgres, a.Provider)
+					return cpMock, nil
+				},
+				dbClient: dbMock,
+			}
+
+			err := h.revokeExpiredToken(context.Background(), credentials.ProviderPostgres, "project1", "1234")
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.wantCPDelete, cpDeleted)
+			assert.Equal(t, tt.wantDBDeleted, dbDeleted)
+		})
+	}
+}
+
+func TestResumeTokenRevocations(t *testing.T) {
+	t.Run("schedules rotated tokens in their grace period", func(t *testing.T) {
+		dbMock := &th.DBClientMock{
+			ListRotatedTokenEntriesFunc: func(ctx context.Context, expiresAfter time.Time) ([]db.TokenEntry, error) {
+				return []db.TokenEntry{
+					{ProjectID: "project1", Rotated: true, TokenID: "1234", ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339Nano)},
+				}, nil
+			},
+		}
+
+		h := handler{logger: log.NewNopLogger(), dbClient: dbMock, revoker: newTokenRevoker()}
+		assert.Nil(t, h.resumeTokenRevocations(context.Background()))
+
+		h.revoker.mu.Lock()
+		_, scheduled := h.revoker.timers["1234"]
+		h.revoker.mu.Unlock()
+		assert.True(t, scheduled)
+		h.revoker.stop()
+		assert.Len(t, dbMock.ListRotatedTokenEntriesCalls(), 1)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		dbMock := &th.DBClientMock{
+			ListRotatedTokenEntriesFunc: func(ctx context.Context, expiresAfter time.Time) ([]db.TokenEntry, error) {
+				return nil, errors.New("error")
+			},
+		}
+
+		h := handler{logger: log.NewNopLogger(), dbClient: dbMock, revoker: newTokenRevoker()}
+		assert.EqualError(t, h.resumeTokenRevocations(context.Background()), "unable to list rotated tokens from database: error")
+	})
+}
@@ -9,6 +9,17 @@ import (
 
 const (
 	txIDHeader = "X-B3-TraceId"
+	// gitSHALabel is the workflow label of the commit hash of workflows
+	// created from git.
+	gitSHALabel = "git-sha"
+	// gitSignatureTypeLabel and gitSignatureKeyLabel are the workflow labels
+	// of the signature of the commit of workflows created from git, when the
+	// project or target requires signed commits.
+	gitSignatureTypeLabel = "git-signature-type"
+	gitSignatureKeyLabel  = "git-signature-key"
+	// batchIDLabel is the workflow label of the batch of workflows created by
+	// fanning out manifests with targets.
+	batchIDLabel = "batch-id"
 )
 
 func setupRouter(h handler) *mux.Router {
@@ -21,18 +32,35 @@ func setupRouter(h handler) *mux.Router {
 	r.HandleFunc("/workflows/{workflowName}/logs", h.getWorkflowLogs).Methods(http.MethodGet)
 	r.HandleFunc("/workflows/{workflowName}/logstream", h.getWorkflowLogStream).Methods(http.MethodGet)
 	r.HandleFunc("/projects", h.createProject).Methods(http.MethodPost)
+	r.HandleFunc("/projects", h.listProjects).Methods(http.MethodGet)
 	r.HandleFunc("/projects/{projectName}", h.getProject).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{projectName}", h.updateProject).Methods(http.MethodPatch)
 	r.HandleFunc("/projects/{projectName}", h.deleteProject).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{projectName}/batches/{batchID}", h.getBatch).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{projectName}/git-credentials", h.updateProjectGitCredentials).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{projectName}/git-credentials", h.deleteProjectGitCredentials).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{projectName}/operations", h.createBatchFromGit).Methods(http.MethodPost)
 	r.HandleFunc("/projects/{projectName}/targets", h.listTargets).Methods(http.MethodGet)
 	r.HandleFunc("/projects/{projectName}/targets", h.createTarget).Methods(http.MethodPost)
 	r.HandleFunc("/projects/{projectName}/targets/{targetName}", h.getTarget).Methods(http.MethodGet)
 	r.HandleFunc("/projects/{projectName}/targets/{targetName}", h.deleteTarget).Methods(http.MethodDelete)
 	r.HandleFunc("/projects/{projectName}/targets/{targetName}", h.updateTarget).Methods(http.MethodPatch)
+	r.HandleFunc("/projects/{projectName}/targets/{targetName}/credentials", h.getTargetCredentials).Methods(http.MethodGet)
 	r.HandleFunc("/projects/{projectName}/targets/{targetName}/operations", h.createWorkflowFromGit).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{projectName}/targets/{targetName}/operations/preview", h.previewWorkflowFromGit).Methods(http.MethodPost)
 	r.HandleFunc("/projects/{projectName}/targets/{targetName}/workflows", h.listWorkflows).Methods(http.MethodGet)
 	r.HandleFunc("/projects/{projectName}/tokens", h.createToken).Methods(http.MethodPost)
 	r.HandleFunc("/projects/{projectName}/tokens", h.listTokens).Methods(http.MethodGet)
 	r.HandleFunc("/projects/{projectName}/tokens/{tokenID}", h.deleteToken).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{projectName}/tokens/{tokenID}/rotate", h.rotateToken).Methods(http.MethodPost)
+	r.HandleFunc("/admin/apply", h.apply).Methods(http.MethodPost)
+	r.HandleFunc("/admin/db/stats", h.getDBStats).Methods(http.MethodGet)
+	r.HandleFunc("/admin/export", h.export).Methods(http.MethodGet)
+	r.HandleFunc("/admin/git/cache", h.listGitCache).Methods(http.MethodGet)
+	r.HandleFunc("/admin/git/cache", h.purgeGitCache).Methods(http.MethodDelete)
+	r.HandleFunc("/admin/import", h.importInventory).Methods(http.MethodPost)
+	r.HandleFunc("/admin/reconciliation", h.getReconciliation).Methods(http.MethodGet)
+	r.HandleFunc("/admin/vault/migrate", h.migrateVault).Methods(http.MethodPost)
 	r.HandleFunc("/health/full", h.healthCheck).Methods(http.MethodGet)
 	return r
 }
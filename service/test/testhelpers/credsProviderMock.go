@@ -4,10 +4,12 @@
 package testhelpers
 
 import (
+	"context"
 	"github.com/cello-proj/cello/internal/responses"
 	"github.com/cello-proj/cello/internal/types"
 	"github.com/cello-proj/cello/service/internal/credentials"
 	"sync"
+	"time"
 )
 
 // Ensure, that CredsProviderMock does implement credentials.Provider.
@@ -16,110 +18,132 @@ var _ credentials.Provider = &CredsProviderMock{}
 
 // CredsProviderMock is a mock implementation of credentials.Provider.
 //
-// 	func TestSomethingThatUsesProvider(t *testing.T) {
+//	func TestSomethingThatUsesProvider(t *testing.T) {
 //
-// 		// make and configure a mocked credentials.Provider
-// 		mockedProvider := &CredsProviderMock{
-// 			CreateProjectFunc: func(s string) (types.Token, error) {
-// 				panic("mock out the CreateProject method")
-// 			},
-// 			CreateTargetFunc: func(s string, target types.Target) error {
-// 				panic("mock out the CreateTarget method")
-// 			},
-// 			CreateTokenFunc: func(s string) (types.Token, error) {
-// 				panic("mock out the CreateToken method")
-// 			},
-// 			DeleteProjectFunc: func(s string) error {
-// 				panic("mock out the DeleteProject method")
-// 			},
-// 			DeleteProjectTokenFunc: func(s1 string, s2 string) error {
-// 				panic("mock out the DeleteProjectToken method")
-// 			},
-// 			DeleteTargetFunc: func(s1 string, s2 string) error {
-// 				panic("mock out the DeleteTarget method")
-// 			},
-// 			GetProjectFunc: func(s string) (responses.GetProject, error) {
-// 				panic("mock out the GetProject method")
-// 			},
-// 			GetProjectTokenFunc: func(s1 string, s2 string) (types.ProjectToken, error) {
-// 				panic("mock out the GetProjectToken method")
-// 			},
-// 			GetTargetFunc: func(s1 string, s2 string) (types.Target, error) {
-// 				panic("mock out the GetTarget method")
-// 			},
-// 			GetTokenFunc: func() (string, error) {
-// 				panic("mock out the GetToken method")
-// 			},
-// 			ListTargetsFunc: func(s string) ([]string, error) {
-// 				panic("mock out the ListTargets method")
-// 			},
-// 			ProjectExistsFunc: func(s string) (bool, error) {
-// 				panic("mock out the ProjectExists method")
-// 			},
-// 			TargetExistsFunc: func(s1 string, s2 string) (bool, error) {
-// 				panic("mock out the TargetExists method")
-// 			},
-// 			UpdateTargetFunc: func(s string, target types.Target) error {
-// 				panic("mock out the UpdateTarget method")
-// 			},
-// 		}
+//		// make and configure a mocked credentials.Provider
+//		mockedProvider := &CredsProviderMock{
+//			CreateProjectFunc: func(contextMoqParam context.Context, s string) (types.Token, error) {
+//				panic("mock out the CreateProject method")
+//			},
+//			CreateTargetFunc: func(contextMoqParam context.Context, s string, target types.Target) error {
+//				panic("mock out the CreateTarget method")
+//			},
+//			CreateTokenFunc: func(contextMoqParam context.Context, s string, duration time.Duration) (types.Token, error) {
+//				panic("mock out the CreateToken method")
+//			},
+//			DeleteProjectFunc: func(contextMoqParam context.Context, s string) error {
+//				panic("mock out the DeleteProject method")
+//			},
+//			DeleteProjectTokenFunc: func(contextMoqParam context.Context, s1 string, s2 string) error {
+//				panic("mock out the DeleteProjectToken method")
+//			},
+//			DeleteTargetFunc: func(contextMoqParam context.Context, s1 string, s2 string) error {
+//				panic("mock out the DeleteTarget method")
+//			},
+//			GetProjectFunc: func(contextMoqParam context.Context, s string) (responses.GetProject, error) {
+//				panic("mock out the GetProject method")
+//			},
+//			GetProjectTokenFunc: func(contextMoqParam context.Context, s1 string, s2 string) (types.ProjectToken, error) {
+//				panic("mock out the GetProjectToken method")
+//			},
+//			GetTargetFunc: func(contextMoqParam context.Context, s1 string, s2 string) (types.Target, error) {
+//				panic("mock out the GetTarget method")
+//			},
+//			GetTokenFunc: func(contextMoqParam context.Context, s1 string, s2 string) (string, error) {
+//				panic("mock out the GetToken method")
+//			},
+//			ListProjectTokensFunc: func(contextMoqParam context.Context, s string) ([]types.ProjectToken, error) {
+//				panic("mock out the ListProjectTokens method")
+//			},
+//			ListProjectsFunc: func(contextMoqParam context.Context) ([]string, error) {
+//				panic("mock out the ListProjects method")
+//			},
+//			ListTargetsFunc: func(contextMoqParam context.Context, s string) ([]string, error) {
+//				panic("mock out the ListTargets method")
+//			},
+//			LookupProjectTokenFunc: func(contextMoqParam context.Context, s string) (types.ProjectToken, error) {
+//				panic("mock out the LookupProjectToken method")
+//			},
+//			ProjectExistsFunc: func(contextMoqParam context.Context, s string) (bool, error) {
+//				panic("mock out the ProjectExists method")
+//			},
+//			TargetExistsFunc: func(contextMoqParam context.Context, s1 string, s2 string) (bool, error) {
+//				panic("mock out the TargetExists method")
+//			},
+//			UpdateTargetFunc: func(contextMoqParam context.Context, s string, target types.Target) error {
+//				panic("mock out the UpdateTarget method")
+//			},
+//		}
 //
-// 		// use mockedProvider in code that requires credentials.Provider
-// 		// and then make assertions.
+//		// use mockedProvider in code that requires credentials.Provider
+//		// and then make assertions.
 //
-// 	}
+//	}
 type CredsProviderMock struct {
 	// CreateProjectFunc mocks the CreateProject method.
-	CreateProjectFunc func(s string) (types.Token, error)
+	CreateProjectFunc func(contextMoqParam context.Context, s string) (types.Token, error)
 
 	// CreateTargetFunc mocks the CreateTarget method.
-	CreateTargetFunc func(s string, target types.Target) error
+	CreateTargetFunc func(contextMoqParam context.Context, s string, target types.Target) error
 
 	// CreateTokenFunc mocks the CreateToken method.
-	CreateTokenFunc func(s string) (types.Token, error)
+	CreateTokenFunc func(contextMoqParam context.Context, s string, duration time.Duration) (types.Token, error)
 
 	// DeleteProjectFunc mocks the DeleteProject method.
-	DeleteProjectFunc func(s string) error
+	DeleteProjectFunc func(contextMoqParam context.Context, s string) error
 
 	// DeleteProjectTokenFunc mocks the DeleteProjectToken method.
-	DeleteProjectTokenFunc func(s1 string, s2 string) error
+	DeleteProjectTokenFunc func(contextMoqParam context.Context, s1 string, s2 string) error
 
 	// DeleteTargetFunc mocks the DeleteTarget method.
-	DeleteTargetFunc func(s1 string, s2 string) error
+	DeleteTargetFunc func(contextMoqParam context.Context, s1 string, s2 string) error
 
 	// GetProjectFunc mocks the GetProject method.
-	GetProjectFunc func(s string) (responses.GetProject, error)
+	GetProjectFunc func(contextMoqParam context.Context, s string) (responses.GetProject, error)
 
 	// GetProjectTokenFunc mocks the GetProjectToken method.
-	GetProjectTokenFunc func(s1 string, s2 string) (types.ProjectToken, error)
+	GetProjectTokenFunc func(contextMoqParam context.Context, s1 string, s2 string) (types.ProjectToken, error)
 
 	// GetTargetFunc mocks the GetTarget method.
-	GetTargetFunc func(s1 string, s2 string) (types.Target, error)
+	GetTargetFunc func(contextMoqParam context.Context, s1 string, s2 string) (types.Target, error)
 
 	// GetTokenFunc mocks the GetToken method.
-	GetTokenFunc func() (string, error)
+	GetTokenFunc func(contextMoqParam context.Context, s1 string, s2 string) (string, error)
+
+	// ListProjectTokensFunc mocks the ListProjectTokens method.
+	ListProjectTokensFunc func(contextMoqParam context.Context, s string) ([]types.ProjectToken, error)
+
+	// ListProjectsFunc mocks the ListProjects method.
+	ListProjectsFunc func(contextMoqParam context.Context) ([]string, error)
 
 	// ListTargetsFunc mocks the ListTargets method.
-	ListTargetsFunc func(s string) ([]string, error)
+	ListTargetsFunc func(contextMoqParam context.Context, s string) ([]string, error)
+
+	// LookupProjectTokenFunc mocks the LookupProjectToken method.
+	LookupProjectTokenFunc func(contextMoqParam context.Context, s string) (types.ProjectToken, error)
 
 	// ProjectExistsFunc mocks the ProjectExists method.
-	ProjectExistsFunc func(s string) (bool, error)
+	ProjectExistsFunc func(contextMoqParam context.Context, s string) (bool, error)
 
 	// TargetExistsFunc mocks the TargetExists method.
-	TargetExistsFunc func(s1 string, s2 string) (bool, error)
+	TargetExistsFunc func(contextMoqParam context.Context, s1 string, s2 string) (bool, error)
 
 	// UpdateTargetFunc mocks the UpdateTarget method.
-	UpdateTargetFunc func(s string, target types.Target) error
+	UpdateTargetFunc func(contextMoqParam context.Context, s string, target types.Target) error
 
 	// calls tracks calls to the methods.
 	calls struct {
 		// CreateProject holds details about calls to the CreateProject method.
 		CreateProject []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
 		}
 		// CreateTarget holds details about calls to the CreateTarget method.
 		CreateTarget []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
 			// Target is the target argument value.
@@ -127,16 +151,24 @@ type CredsProviderMock struct {
 		}
 		// CreateToken holds details about calls to the CreateToken method.
 		CreateToken []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
+			// Duration is the duration argument value.
+			Duration time.Duration
 		}
 		// DeleteProject holds details about calls to the DeleteProject method.
 		DeleteProject []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
 		}
 		// DeleteProjectToken holds details about calls to the DeleteProjectToken method.
 		DeleteProjectToken []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S1 is the s1 argument value.
 			S1 string
 			// S2 is the s2 argument value.
@@ -144,6 +176,8 @@ type CredsProviderMock struct {
 		}
 		// DeleteTarget holds details about calls to the DeleteTarget method.
 		DeleteTarget []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S1 is the s1 argument value.
 			S1 string
 			// S2 is the s2 argument value.
@@ -151,11 +185,15 @@ type CredsProviderMock struct {
 		}
 		// GetProject holds details about calls to the GetProject method.
 		GetProject []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
 		}
 		// GetProjectToken holds details about calls to the GetProjectToken method.
 		GetProjectToken []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S1 is the s1 argument value.
 			S1 string
 			// S2 is the s2 argument value.
@@ -163,6 +201,8 @@ type CredsProviderMock struct {
 		}
 		// GetTarget holds details about calls to the GetTarget method.
 		GetTarget []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S1 is the s1 argument value.
 			S1 string
 			// S2 is the s2 argument value.
@@ -170,19 +210,50 @@ type CredsProviderMock struct {
 		}
 		// GetToken holds details about calls to the GetToken method.
 		GetToken []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// S1 is the s1 argument value.
+			S1 string
+			// S2 is the s2 argument value.
+			S2 string
+		}
+		// ListProjectTokens holds details about calls to the ListProjectTokens method.
+		ListProjectTokens []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// S is the s argument value.
+			S string
+		}
+		// ListProjects holds details about calls to the ListProjects method.
+		ListProjects []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 		}
 		// ListTargets holds details about calls to the ListTargets method.
 		ListTargets []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
+			// S is the s argument value.
+			S string
+		}
+		// LookupProjectToken holds details about calls to the LookupProjectToken method.
+		LookupProjectToken []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
 		}
 		// ProjectExists holds details about calls to the ProjectExists method.
 		ProjectExists []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
 		}
 		// TargetExists holds details about calls to the TargetExists method.
 		TargetExists []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S1 is the s1 argument value.
 			S1 string
 			// S2 is the s2 argument value.
@@ -190,6 +261,8 @@ type CredsProviderMock struct {
 		}
 		// UpdateTarget holds details about calls to the UpdateTarget method.
 		UpdateTarget []struct {
+			// ContextMoqParam is the contextMoqParam argument value.
+			ContextMoqParam context.Context
 			// S is the s argument value.
 			S string
 			// Target is the target argument value.
@@ -206,36 +279,44 @@ type CredsProviderMock struct {
 	lockGetProjectToken    sync.RWMutex
 	lockGetTarget          sync.RWMutex
 	lockGetToken           sync.RWMutex
+	lockListProjectTokens  sync.RWMutex
+	lockListProjects       sync.RWMutex
 	lockListTargets        sync.RWMutex
+	lockLookupProjectToken sync.RWMutex
 	lockProjectExists      sync.RWMutex
 	lockTargetExists       sync.RWMutex
 	lockUpdateTarget       sync.RWMutex
 }
 
 // CreateProject calls CreateProjectFunc.
-func (mock *CredsProviderMock) CreateProject(s string) (types.Token, error) {
+func (mock *CredsProviderMock) CreateProject(contextMoqParam context.Context, s string) (types.Token, error) {
 	if mock.CreateProjectFunc == nil {
 		panic("CredsProviderMock.CreateProjectFunc: method is nil but Provider.CreateProject was just called")
 	}
 	callInfo := struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}{
-		S: s,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
 	}
 	mock.lockCreateProject.Lock()
 	mock.calls.CreateProject = append(mock.calls.CreateProject, callInfo)
 	mock.lockCreateProject.Unlock()
-	return mock.CreateProjectFunc(s)
+	return mock.CreateProjectFunc(contextMoqParam, s)
 }
 
 // CreateProjectCalls gets all the calls that were made to CreateProject.
 // Check the length with:
-//     len(mockedProvider.CreateProjectCalls())
+//
+//	len(mockedProvider.CreateProjectCalls())
 func (mock *CredsProviderMock) CreateProjectCalls() []struct {
-	S string
+	ContextMoqParam context.Context
+	S               string
 } {
 	var calls []struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}
 	mock.lockCreateProject.RLock()
 	calls = mock.calls.CreateProject
@@ -244,33 +325,38 @@ func (mock *CredsProviderMock) CreateProjectCalls() []struct {
 }
 
 // CreateTarget calls CreateTargetFunc.
-func (mock *CredsProviderMock) CreateTarget(s string, target types.Target) error {
+func (mock *CredsProviderMock) CreateTarget(contextMoqParam context.Context, s string, target types.Target) error {
 	if mock.CreateTargetFunc == nil {
 		panic("CredsProviderMock.CreateTargetFunc: method is nil but Provider.CreateTarget was just called")
 	}
 	callInfo := struct {
-		S      string
-		Target types.Target
+		ContextMoqParam context.Context
+		S               string
+		Target          types.Target
 	}{
-		S:      s,
-		Target: target,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
+		Target:          target,
 	}
 	mock.lockCreateTarget.Lock()
 	mock.calls.CreateTarget = append(mock.calls.CreateTarget, callInfo)
 	mock.lockCreateTarget.Unlock()
-	return mock.CreateTargetFunc(s, target)
+	return mock.CreateTargetFunc(contextMoqParam, s, target)
 }
 
 // CreateTargetCalls gets all the calls that were made to CreateTarget.
 // Check the length with:
-//     len(mockedProvider.CreateTargetCalls())
+//
+//	len(mockedProvider.CreateTargetCalls())
 func (mock *CredsProviderMock) CreateTargetCalls() []struct {
-	S      string
-	Target types.Target
+	ContextMoqParam context.Context
+	S               string
+	Target          types.Target
 } {
 	var calls []struct {
-		S      string
-		Target types.Target
+		ContextMoqParam context.Context
+		S               string
+		Target          types.Target
 	}
 	mock.lockCreateTarget.RLock()
 	calls = mock.calls.CreateTarget
@@ -279,29 +365,38 @@ func (mock *CredsProviderMock) CreateTargetCalls() []struct {
 }
 
 // CreateToken calls CreateTokenFunc.
-func (mock *CredsProviderMock) CreateToken(s string) (types.Token, error) {
+func (mock *CredsProviderMock) CreateToken(contextMoqParam context.Context, s string, duration time.Duration) (types.Token, error) {
 	if mock.CreateTokenFunc == nil {
 		panic("CredsProviderMock.CreateTokenFunc: method is nil but Provider.CreateToken was just called")
 	}
 	callInfo := struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
+		Duration        time.Duration
 	}{
-		S: s,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
+		Duration:        duration,
 	}
 	mock.lockCreateToken.Lock()
 	mock.calls.CreateToken = append(mock.calls.CreateToken, callInfo)
 	mock.lockCreateToken.Unlock()
-	return mock.CreateTokenFunc(s)
+	return mock.CreateTokenFunc(contextMoqParam, s, duration)
 }
 
 // CreateTokenCalls gets all the calls that were made to CreateToken.
 // Check the length with:
-//     len(mockedProvider.CreateTokenCalls())
+//
+//	len(mockedProvider.CreateTokenCalls())
 func (mock *CredsProviderMock) CreateTokenCalls() []struct {
-	S string
+	ContextMoqParam context.Context
+	S               string
+	Duration        time.Duration
 } {
 	var calls []struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
+		Duration        time.Duration
 	}
 	mock.lockCreateToken.RLock()
 	calls = mock.calls.CreateToken
@@ -310,29 +405,34 @@ func (mock *CredsProviderMock) CreateTokenCalls() []struct {
 }
 
 // DeleteProject calls DeleteProjectFunc.
-func (mock *CredsProviderMock) DeleteProject(s string) error {
+func (mock *CredsProviderMock) DeleteProject(contextMoqParam context.Context, s string) error {
 	if mock.DeleteProjectFunc == nil {
 		panic("CredsProviderMock.DeleteProjectFunc: method is nil but Provider.DeleteProject was just called")
 	}
 	callInfo := struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}{
-		S: s,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
 	}
 	mock.lockDeleteProject.Lock()
 	mock.calls.DeleteProject = append(mock.calls.DeleteProject, callInfo)
 	mock.lockDeleteProject.Unlock()
-	return mock.DeleteProjectFunc(s)
+	return mock.DeleteProjectFunc(contextMoqParam, s)
 }
 
 // DeleteProjectCalls gets all the calls that were made to DeleteProject.
 // Check the length with:
-//     len(mockedProvider.DeleteProjectCalls())
+//
+//	len(mockedProvider.DeleteProjectCalls())
 func (mock *CredsProviderMock) DeleteProjectCalls() []struct {
-	S string
+	ContextMoqParam context.Context
+	S               string
 } {
 	var calls []struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}
 	mock.lockDeleteProject.RLock()
 	calls = mock.calls.DeleteProject
@@ -341,33 +441,38 @@ func (mock *CredsProviderMock) DeleteProjectCalls() []struct {
 }
 
 // DeleteProjectToken calls DeleteProjectTokenFunc.
-func (mock *CredsProviderMock) DeleteProjectToken(s1 string, s2 string) error {
+func (mock *CredsProviderMock) DeleteProjectToken(contextMoqParam context.Context, s1 string, s2 string) error {
 	if mock.DeleteProjectTokenFunc == nil {
 		panic("CredsProviderMock.DeleteProjectTokenFunc: method is nil but Provider.DeleteProjectToken was just called")
 	}
 	callInfo := struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}{
-		S1: s1,
-		S2: s2,
+		ContextMoqParam: contextMoqParam,
+		S1:              s1,
+		S2:              s2,
 	}
 	mock.lockDeleteProjectToken.Lock()
 	mock.calls.DeleteProjectToken = append(mock.calls.DeleteProjectToken, callInfo)
 	mock.lockDeleteProjectToken.Unlock()
-	return mock.DeleteProjectTokenFunc(s1, s2)
+	return mock.DeleteProjectTokenFunc(contextMoqParam, s1, s2)
 }
 
 // DeleteProjectTokenCalls gets all the calls that were made to DeleteProjectToken.
 // Check the length with:
-//     len(mockedProvider.DeleteProjectTokenCalls())
+//
+//	len(mockedProvider.DeleteProjectTokenCalls())
 func (mock *CredsProviderMock) DeleteProjectTokenCalls() []struct {
-	S1 string
-	S2 string
+	ContextMoqParam context.Context
+	S1              string
+	S2              string
 } {
 	var calls []struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}
 	mock.lockDeleteProjectToken.RLock()
 	calls = mock.calls.DeleteProjectToken
@@ -376,33 +481,38 @@ func (mock *CredsProviderMock) DeleteProjectTokenCalls() []struct {
 }
 
 // DeleteTarget calls DeleteTargetFunc.
-func (mock *CredsProviderMock) DeleteTarget(s1 string, s2 string) error {
+func (mock *CredsProviderMock) DeleteTarget(contextMoqParam context.Context, s1 string, s2 string) error {
 	if mock.DeleteTargetFunc == nil {
 		panic("CredsProviderMock.DeleteTargetFunc: method is nil but Provider.DeleteTarget was just called")
 	}
 	callInfo := struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}{
-		S1: s1,
-		S2: s2,
+		ContextMoqParam: contextMoqParam,
+		S1:              s1,
+		S2:              s2,
 	}
 	mock.lockDeleteTarget.Lock()
 	mock.calls.DeleteTarget = append(mock.calls.DeleteTarget, callInfo)
 	mock.lockDeleteTarget.Unlock()
-	return mock.DeleteTargetFunc(s1, s2)
+	return mock.DeleteTargetFunc(contextMoqParam, s1, s2)
 }
 
 // DeleteTargetCalls gets all the calls that were made to DeleteTarget.
 // Check the length with:
-//     len(mockedProvider.DeleteTargetCalls())
+//
+//	len(mockedProvider.DeleteTargetCalls())
 func (mock *CredsProviderMock) DeleteTargetCalls() []struct {
-	S1 string
-	S2 string
+	ContextMoqParam context.Context
+	S1              string
+	S2              string
 } {
 	var calls []struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}
 	mock.lockDeleteTarget.RLock()
 	calls = mock.calls.DeleteTarget
@@ -411,29 +521,34 @@ func (mock *CredsProviderMock) DeleteTargetCalls() []struct {
 }
 
 // GetProject calls GetProjectFunc.
-func (mock *CredsProviderMock) GetProject(s string) (responses.GetProject, error) {
+func (mock *CredsProviderMock) GetProject(contextMoqParam context.Context, s string) (responses.GetProject, error) {
 	if mock.GetProjectFunc == nil {
 		panic("CredsProviderMock.GetProjectFunc: method is nil but Provider.GetProject was just called")
 	}
 	callInfo := struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}{
-		S: s,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
 	}
 	mock.lockGetProject.Lock()
 	mock.calls.GetProject = append(mock.calls.GetProject, callInfo)
 	mock.lockGetProject.Unlock()
-	return mock.GetProjectFunc(s)
+	return mock.GetProjectFunc(contextMoqParam, s)
 }
 
 // GetProjectCalls gets all the calls that were made to GetProject.
 // Check the length with:
-//     len(mockedProvider.GetProjectCalls())
+//
+//	len(mockedProvider.GetProjectCalls())
 func (mock *CredsProviderMock) GetProjectCalls() []struct {
-	S string
+	ContextMoqParam context.Context
+	S               string
 } {
 	var calls []struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}
 	mock.lockGetProject.RLock()
 	calls = mock.calls.GetProject
@@ -442,33 +557,38 @@ func (mock *CredsProviderMock) GetProjectCalls() []struct {
 }
 
 // GetProjectToken calls GetProjectTokenFunc.
-func (mock *CredsProviderMock) GetProjectToken(s1 string, s2 string) (types.ProjectToken, error) {
+func (mock *CredsProviderMock) GetProjectToken(contextMoqParam context.Context, s1 string, s2 string) (types.ProjectToken, error) {
 	if mock.GetProjectTokenFunc == nil {
 		panic("CredsProviderMock.GetProjectTokenFunc: method is nil but Provider.GetProjectToken was just called")
 	}
 	callInfo := struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}{
-		S1: s1,
-		S2: s2,
+		ContextMoqParam: contextMoqParam,
+		S1:              s1,
+		S2:              s2,
 	}
 	mock.lockGetProjectToken.Lock()
 	mock.calls.GetProjectToken = append(mock.calls.GetProjectToken, callInfo)
 	mock.lockGetProjectToken.Unlock()
-	return mock.GetProjectTokenFunc(s1, s2)
+	return mock.GetProjectTokenFunc(contextMoqParam, s1, s2)
 }
 
 // GetProjectTokenCalls gets all the calls that were made to GetProjectToken.
 // Check the length with:
-//     len(mockedProvider.GetProjectTokenCalls())
+//
+//	len(mockedProvider.GetProjectTokenCalls())
 func (mock *CredsProviderMock) GetProjectTokenCalls() []struct {
-	S1 string
-	S2 string
+	ContextMoqParam context.Context
+	S1              string
+	S2              string
 } {
 	var calls []struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}
 	mock.lockGetProjectToken.RLock()
 	calls = mock.calls.GetProjectToken
@@ -477,33 +597,38 @@ func (mock *CredsProviderMock) GetProjectTokenCalls() []struct {
 }
 
 // GetTarget calls GetTargetFunc.
-func (mock *CredsProviderMock) GetTarget(s1 string, s2 string) (types.Target, error) {
+func (mock *CredsProviderMock) GetTarget(contextMoqParam context.Context, s1 string, s2 string) (types.Target, error) {
 	if mock.GetTargetFunc == nil {
 		panic("CredsProviderMock.GetTargetFunc: method is nil but Provider.GetTarget was just called")
 	}
 	callInfo := struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}{
-		S1: s1,
-		S2: s2,
+		ContextMoqParam: contextMoqParam,
+		S1:              s1,
+		S2:              s2,
 	}
 	mock.lockGetTarget.Lock()
 	mock.calls.GetTarget = append(mock.calls.GetTarget, callInfo)
 	mock.lockGetTarget.Unlock()
-	return mock.GetTargetFunc(s1, s2)
+	return mock.GetTargetFunc(contextMoqParam, s1, s2)
 }
 
 // GetTargetCalls gets all the calls that were made to GetTarget.
 // Check the length with:
-//     len(mockedProvider.GetTargetCalls())
+//
+//	len(mockedProvider.GetTargetCalls())
 func (mock *CredsProviderMock) GetTargetCalls() []struct {
-	S1 string
-	S2 string
+	ContextMoqParam context.Context
+	S1              string
+	S2              string
 } {
 	var calls []struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}
 	mock.lockGetTarget.RLock()
 	calls = mock.calls.GetTarget
@@ -512,24 +637,38 @@ func (mock *CredsProviderMock) GetTargetCalls() []struct {
 }
 
 // GetToken calls GetTokenFunc.
-func (mock *CredsProviderMock) GetToken() (string, error) {
+func (mock *CredsProviderMock) GetToken(contextMoqParam context.Context, s1 string, s2 string) (string, error) {
 	if mock.GetTokenFunc == nil {
 		panic("CredsProviderMock.GetTokenFunc: method is nil but Provider.GetToken was just called")
 	}
 	callInfo := struct {
-	}{}
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
+	}{
+		ContextMoqParam: contextMoqParam,
+		S1:              s1,
+		S2:              s2,
+	}
 	mock.lockGetToken.Lock()
 	mock.calls.GetToken = append(mock.calls.GetToken, callInfo)
 	mock.lockGetToken.Unlock()
-	return mock.GetTokenFunc()
+	return mock.GetTokenFunc(contextMoqParam, s1, s2)
 }
 
 // GetTokenCalls gets all the calls that were made to GetToken.
 // Check the length with:
-//     len(mockedProvider.GetTokenCalls())
+//
+//	len(mockedProvider.GetTokenCalls())
 func (mock *CredsProviderMock) GetTokenCalls() []struct {
+	ContextMoqParam context.Context
+	S1              string
+	S2              string
 } {
 	var calls []struct {
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}
 	mock.lockGetToken.RLock()
 	calls = mock.calls.GetToken
@@ -537,30 +676,103 @@ func (mock *CredsProviderMock) GetTokenCalls() []struct {
 	return calls
 }
 
+// ListProjectTokens calls ListProjectTokensFunc.
+func (mock *CredsProviderMock) ListProjectTokens(contextMoqParam context.Context, s string) ([]types.ProjectToken, error) {
+	if mock.ListProjectTokensFunc == nil {
+		panic("CredsProviderMock.ListProjectTokensFunc: method is nil but Provider.ListProjectTokens was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		S               string
+	}{
+		ContextMoqParam: contextMoqParam,
+		S:               s,
+	}
+	mock.lockListProjectTokens.Lock()
+	mock.calls.ListProjectTokens = append(mock.calls.ListProjectTokens, callInfo)
+	mock.lockListProjectTokens.Unlock()
+	return mock.ListProjectTokensFunc(contextMoqParam, s)
+}
+
+// ListProjectTokensCalls gets all the calls that were made to ListProjectTokens.
+// Check the length with:
+//
+//	len(mockedProvider.ListProjectTokensCalls())
+func (mock *CredsProviderMock) ListProjectTokensCalls() []struct {
+	ContextMoqParam context.Context
+	S               string
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		S               string
+	}
+	mock.lockListProjectTokens.RLock()
+	calls = mock.calls.ListProjectTokens
+	mock.lockListProjectTokens.RUnlock()
+	return calls
+}
+
+// ListProjects calls ListProjectsFunc.
+func (mock *CredsProviderMock) ListProjects(contextMoqParam context.Context) ([]string, error) {
+	if mock.ListProjectsFunc == nil {
+		panic("CredsProviderMock.ListProjectsFunc: method is nil but Provider.ListProjects was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+	}{
+		ContextMoqParam: contextMoqParam,
+	}
+	mock.lockListProjects.Lock()
+	mock.calls.ListProjects = append(mock.calls.ListProjects, callInfo)
+	mock.lockListProjects.Unlock()
+	return mock.ListProjectsFunc(contextMoqParam)
+}
+
+// ListProjectsCalls gets all the calls that were made to ListProjects.
+// Check the length with:
+//
+//	len(mockedProvider.ListProjectsCalls())
+func (mock *CredsProviderMock) ListProjectsCalls() []struct {
+	ContextMoqParam context.Context
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+	}
+	mock.lockListProjects.RLock()
+	calls = mock.calls.ListProjects
+	mock.lockListProjects.RUnlock()
+	return calls
+}
+
 // ListTargets calls ListTargetsFunc.
-func (mock *CredsProviderMock) ListTargets(s string) ([]string, error) {
+func (mock *CredsProviderMock) ListTargets(contextMoqParam context.Context, s string) ([]string, error) {
 	if mock.ListTargetsFunc == nil {
 		panic("CredsProviderMock.ListTargetsFunc: method is nil but Provider.ListTargets was just called")
 	}
 	callInfo := struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}{
-		S: s,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
 	}
 	mock.lockListTargets.Lock()
 	mock.calls.ListTargets = append(mock.calls.ListTargets, callInfo)
 	mock.lockListTargets.Unlock()
-	return mock.ListTargetsFunc(s)
+	return mock.ListTargetsFunc(contextMoqParam, s)
 }
 
 // ListTargetsCalls gets all the calls that were made to ListTargets.
 // Check the length with:
-//     len(mockedProvider.ListTargetsCalls())
+//
+//	len(mockedProvider.ListTargetsCalls())
 func (mock *CredsProviderMock) ListTargetsCalls() []struct {
-	S string
+	ContextMoqParam context.Context
+	S               string
 } {
 	var calls []struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}
 	mock.lockListTargets.RLock()
 	calls = mock.calls.ListTargets
@@ -568,30 +780,71 @@ func (mock *CredsProviderMock) ListTargetsCalls() []struct {
 	return calls
 }
 
+// LookupProjectToken calls LookupProjectTokenFunc.
+func (mock *CredsProviderMock) LookupProjectToken(contextMoqParam context.Context, s string) (types.ProjectToken, error) {
+	if mock.LookupProjectTokenFunc == nil {
+		panic("CredsProviderMock.LookupProjectTokenFunc: method is nil but Provider.LookupProjectToken was just called")
+	}
+	callInfo := struct {
+		ContextMoqParam context.Context
+		S               string
+	}{
+		ContextMoqParam: contextMoqParam,
+		S:               s,
+	}
+	mock.lockLookupProjectToken.Lock()
+	mock.calls.LookupProjectToken = append(mock.calls.LookupProjectToken, callInfo)
+	mock.lockLookupProjectToken.Unlock()
+	return mock.LookupProjectTokenFunc(contextMoqParam, s)
+}
+
+// LookupProjectTokenCalls gets all the calls that were made to LookupProjectToken.
+// Check the length with:
+//
+//	len(mockedProvider.LookupProjectTokenCalls())
+func (mock *CredsProviderMock) LookupProjectTokenCalls() []struct {
+	ContextMoqParam context.Context
+	S               string
+} {
+	var calls []struct {
+		ContextMoqParam context.Context
+		S               string
+	}
+	mock.lockLookupProjectToken.RLock()
+	calls = mock.calls.LookupProjectToken
+	mock.lockLookupProjectToken.RUnlock()
+	return calls
+}
+
 // ProjectExists calls ProjectExistsFunc.
-func (mock *CredsProviderMock) ProjectExists(s string) (bool, error) {
+func (mock *CredsProviderMock) ProjectExists(contextMoqParam context.Context, s string) (bool, error) {
 	if mock.ProjectExistsFunc == nil {
 		panic("CredsProviderMock.ProjectExistsFunc: method is nil but Provider.ProjectExists was just called")
 	}
 	callInfo := struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}{
-		S: s,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
 	}
 	mock.lockProjectExists.Lock()
 	mock.calls.ProjectExists = append(mock.calls.ProjectExists, callInfo)
 	mock.lockProjectExists.Unlock()
-	return mock.ProjectExistsFunc(s)
+	return mock.ProjectExistsFunc(contextMoqParam, s)
 }
 
 // ProjectExistsCalls gets all the calls that were made to ProjectExists.
 // Check the length with:
-//     len(mockedProvider.ProjectExistsCalls())
+//
+//	len(mockedProvider.ProjectExistsCalls())
 func (mock *CredsProviderMock) ProjectExistsCalls() []struct {
-	S string
+	ContextMoqParam context.Context
+	S               string
 } {
 	var calls []struct {
-		S string
+		ContextMoqParam context.Context
+		S               string
 	}
 	mock.lockProjectExists.RLock()
 	calls = mock.calls.ProjectExists
@@ -600,33 +853,38 @@ func (mock *CredsProviderMock) ProjectExistsCalls() []struct {
 }
 
 // TargetExists calls TargetExistsFunc.
-func (mock *CredsProviderMock) TargetExists(s1 string, s2 string) (bool, error) {
+func (mock *CredsProviderMock) TargetExists(contextMoqParam context.Context, s1 string, s2 string) (bool, error) {
 	if mock.TargetExistsFunc == nil {
 		panic("CredsProviderMock.TargetExistsFunc: method is nil but Provider.TargetExists was just called")
 	}
 	callInfo := struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}{
-		S1: s1,
-		S2: s2,
+		ContextMoqParam: contextMoqParam,
+		S1:              s1,
+		S2:              s2,
 	}
 	mock.lockTargetExists.Lock()
 	mock.calls.TargetExists = append(mock.calls.TargetExists, callInfo)
 	mock.lockTargetExists.Unlock()
-	return mock.TargetExistsFunc(s1, s2)
+	return mock.TargetExistsFunc(contextMoqParam, s1, s2)
 }
 
 // TargetExistsCalls gets all the calls that were made to TargetExists.
 // Check the length with:
-//     len(mockedProvider.TargetExistsCalls())
+//
+//	len(mockedProvider.TargetExistsCalls())
 func (mock *CredsProviderMock) TargetExistsCalls() []struct {
-	S1 string
-	S2 string
+	ContextMoqParam context.Context
+	S1              string
+	S2              string
 } {
 	var calls []struct {
-		S1 string
-		S2 string
+		ContextMoqParam context.Context
+		S1              string
+		S2              string
 	}
 	mock.lockTargetExists.RLock()
 	calls = mock.calls.TargetExists
@@ -635,33 +893,38 @@ func (mock *CredsProviderMock) TargetExistsCalls() []struct {
 }
 
 // UpdateTarget calls UpdateTargetFunc.
-func (mock *CredsProviderMock) UpdateTarget(s string, target types.Target) error {
+func (mock *CredsProviderMock) UpdateTarget(contextMoqParam context.Context, s string, target types.Target) error {
 	if mock.UpdateTargetFunc == nil {
 		panic("CredsProviderMock.UpdateTargetFunc: method is nil but Provider.UpdateTarget was just called")
 	}
 	callInfo := struct {
-		S      string
-		Target types.Target
+		ContextMoqParam context.Context
+		S               string
+		Target          types.Target
 	}{
-		S:      s,
-		Target: target,
+		ContextMoqParam: contextMoqParam,
+		S:               s,
+		Target:          target,
 	}
 	mock.lockUpdateTarget.Lock()
 	mock.calls.UpdateTarget = append(mock.calls.UpdateTarget, callInfo)
 	mock.lockUpdateTarget.Unlock()
-	return mock.UpdateTargetFunc(s, target)
+	return mock.UpdateTargetFunc(contextMoqParam, s, target)
 }
 
 // UpdateTargetCalls gets all the calls that were made to UpdateTarget.
 // Check the length with:
-//     len(mockedProvider.UpdateTargetCalls())
+//
+//	len(mockedProvider.UpdateTargetCalls())
 func (mock *CredsProviderMock) UpdateTargetCalls() []struct {
-	S      string
-	Target types.Target
+	ContextMoqParam context.Context
+	S               string
+	Target          types.Target
 } {
 	var calls []struct {
-		S      string
-		Target types.Target
+		ContextMoqParam context.Context
+		S               string
+		Target          types.Target
 	}
 	mock.lockUpdateTarget.RLock()
 	calls = mock.calls.UpdateTarget
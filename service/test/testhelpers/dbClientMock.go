@@ -5,9 +5,11 @@ package testhelpers
 
 import (
 	"context"
+	"database/sql"
 	"github.com/cello-proj/cello/internal/types"
 	"github.com/cello-proj/cello/service/internal/db"
 	"sync"
+	"time"
 )
 
 // Ensure, that DBClientMock does implement db.Client.
@@ -20,6 +22,12 @@ var _ db.Client = &DBClientMock{}
 //
 //		// make and configure a mocked db.Client
 //		mockedClient := &DBClientMock{
+//			AcquireReconciliationLeaseFunc: func(ctx context.Context, name string, holder string, now time.Time, expiresAt time.Time) (bool, error) {
+//				panic("mock out the AcquireReconciliationLease method")
+//			},
+//			CreateBatchEntryFunc: func(ctx context.Context, be db.BatchEntry) error {
+//				panic("mock out the CreateBatchEntry method")
+//			},
 //			CreateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error {
 //				panic("mock out the CreateProjectEntry method")
 //			},
@@ -29,21 +37,66 @@ var _ db.Client = &DBClientMock{}
 //			DeleteProjectEntryFunc: func(ctx context.Context, project string) error {
 //				panic("mock out the DeleteProjectEntry method")
 //			},
+//			DeleteTargetEntryFunc: func(ctx context.Context, project string, target string, remove func() error) error {
+//				panic("mock out the DeleteTargetEntry method")
+//			},
 //			DeleteTokenEntryFunc: func(ctx context.Context, token string) error {
 //				panic("mock out the DeleteTokenEntry method")
 //			},
 //			HealthFunc: func(ctx context.Context) error {
 //				panic("mock out the Health method")
 //			},
+//			InterruptBatchEntriesFunc: func(ctx context.Context, updatedBefore time.Time) ([]string, error) {
+//				panic("mock out the InterruptBatchEntries method")
+//			},
+//			ListProjectEntriesFunc: func(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error) {
+//				panic("mock out the ListProjectEntries method")
+//			},
+//			ListTargetEntriesFunc: func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+//				panic("mock out the ListTargetEntries method")
+//			},
+//			ListRotatedTokenEntriesFunc: func(ctx context.Context, expiresAfter time.Time) ([]db.TokenEntry, error) {
+//				panic("mock out the ListRotatedTokenEntries method")
+//			},
 //			ListTokenEntriesFunc: func(ctx context.Context, project string) ([]db.TokenEntry, error) {
 //				panic("mock out the ListTokenEntries method")
 //			},
+//			ReadBatchEntryFunc: func(ctx context.Context, project string, batchID string) (db.BatchEntry, error) {
+//				panic("mock out the ReadBatchEntry method")
+//			},
 //			ReadProjectEntryFunc: func(ctx context.Context, project string) (db.ProjectEntry, error) {
 //				panic("mock out the ReadProjectEntry method")
 //			},
+//			ReadReconciliationEntryFunc: func(ctx context.Context, name string) (db.ReconciliationEntry, error) {
+//				panic("mock out the ReadReconciliationEntry method")
+//			},
+//			ReadTargetEntryFunc: func(ctx context.Context, project string, target string) (db.TargetEntry, error) {
+//				panic("mock out the ReadTargetEntry method")
+//			},
 //			ReadTokenEntryFunc: func(ctx context.Context, token string) (db.TokenEntry, error) {
 //				panic("mock out the ReadTokenEntry method")
 //			},
+//			RotateTokenEntryFunc: func(ctx context.Context, oldToken string, token types.Token, oldExpiresAt string, revoke func() error) error {
+//				panic("mock out the RotateTokenEntry method")
+//			},
+//			StatsFunc: func() sql.DBStats {
+//				panic("mock out the Stats method")
+//			},
+//			UpdateBatchEntryFunc: func(ctx context.Context, be db.BatchEntry) error {
+//				panic("mock out the UpdateBatchEntry method")
+//			},
+//			UpdateProjectEntryFunc: func(ctx context.Context, pe db.ProjectEntry) error {
+//				panic("mock out the UpdateProjectEntry method")
+//			},
+//			UpdateProjectGitCredentialsFunc: func(ctx context.Context, project string, ref string) error {
+//				panic("mock out the UpdateProjectGitCredentials method")
+//			},
+//			UpdateReconciliationReportFunc: func(ctx context.Context, name string, holder string, report string) error {
+//				panic("mock out the UpdateReconciliationReport method")
+//			},
+//			UpsertTargetEntryFunc: func(ctx context.Context, te db.TargetEntry, write func() error) error {
+//				panic("mock out the UpsertTargetEntry method")
+//			},
 //		}
 //
 //		// use mockedClient in code that requires db.Client
@@ -51,6 +104,12 @@ var _ db.Client = &DBClientMock{}
 //
 //	}
 type DBClientMock struct {
+	// AcquireReconciliationLeaseFunc mocks the AcquireReconciliationLease method.
+	AcquireReconciliationLeaseFunc func(ctx context.Context, name string, holder string, now time.Time, expiresAt time.Time) (bool, error)
+
+	// CreateBatchEntryFunc mocks the CreateBatchEntry method.
+	CreateBatchEntryFunc func(ctx context.Context, be db.BatchEntry) error
+
 	// CreateProjectEntryFunc mocks the CreateProjectEntry method.
 	CreateProjectEntryFunc func(ctx context.Context, pe db.ProjectEntry) error
 
@@ -60,23 +119,88 @@ type DBClientMock struct {
 	// DeleteProjectEntryFunc mocks the DeleteProjectEntry method.
 	DeleteProjectEntryFunc func(ctx context.Context, project string) error
 
+	// DeleteTargetEntryFunc mocks the DeleteTargetEntry method.
+	DeleteTargetEntryFunc func(ctx context.Context, project string, target string, remove func() error) error
+
 	// DeleteTokenEntryFunc mocks the DeleteTokenEntry method.
 	DeleteTokenEntryFunc func(ctx context.Context, token string) error
 
 	// HealthFunc mocks the Health method.
 	HealthFunc func(ctx context.Context) error
 
+	// InterruptBatchEntriesFunc mocks the InterruptBatchEntries method.
+	InterruptBatchEntriesFunc func(ctx context.Context, updatedBefore time.Time) ([]string, error)
+
+	// ListProjectEntriesFunc mocks the ListProjectEntries method.
+	ListProjectEntriesFunc func(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error)
+
+	// ListTargetEntriesFunc mocks the ListTargetEntries method.
+	ListTargetEntriesFunc func(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error)
+
+	// ListRotatedTokenEntriesFunc mocks the ListRotatedTokenEntries method.
+	ListRotatedTokenEntriesFunc func(ctx context.Context, expiresAfter time.Time) ([]db.TokenEntry, error)
+
 	// ListTokenEntriesFunc mocks the ListTokenEntries method.
 	ListTokenEntriesFunc func(ctx context.Context, project string) ([]db.TokenEntry, error)
 
+	// ReadBatchEntryFunc mocks the ReadBatchEntry method.
+	ReadBatchEntryFunc func(ctx context.Context, project string, batchID string) (db.BatchEntry, error)
+
 	// ReadProjectEntryFunc mocks the ReadProjectEntry method.
 	ReadProjectEntryFunc func(ctx context.Context, project string) (db.ProjectEntry, error)
 
+	// ReadReconciliationEntryFunc mocks the ReadReconciliationEntry method.
+	ReadReconciliationEntryFunc func(ctx context.Context, name string) (db.ReconciliationEntry, error)
+
+	// ReadTargetEntryFunc mocks the ReadTargetEntry method.
+	ReadTargetEntryFunc func(ctx context.Context, project string, target string) (db.TargetEntry, error)
+
 	// ReadTokenEntryFunc mocks the ReadTokenEntry method.
 	ReadTokenEntryFunc func(ctx context.Context, token string) (db.TokenEntry, error)
 
+	// RotateTokenEntryFunc mocks the RotateTokenEntry method.
+	RotateTokenEntryFunc func(ctx context.Context, oldToken string, token types.Token, oldExpiresAt string, revoke func() error) error
+
+	// StatsFunc mocks the Stats method.
+	StatsFunc func() sql.DBStats
+
+	// UpdateBatchEntryFunc mocks the UpdateBatchEntry method.
+	UpdateBatchEntryFunc func(ctx context.Context, be db.BatchEntry) error
+
+	// UpdateProjectEntryFunc mocks the UpdateProjectEntry method.
+	UpdateProjectEntryFunc func(ctx context.Context, pe db.ProjectEntry) error
+
+	// UpdateProjectGitCredentialsFunc mocks the UpdateProjectGitCredentials method.
+	UpdateProjectGitCredentialsFunc func(ctx context.Context, project string, ref string) error
+
+	// UpdateReconciliationReportFunc mocks the UpdateReconciliationReport method.
+	UpdateReconciliationReportFunc func(ctx context.Context, name string, holder string, report string) error
+
+	// UpsertTargetEntryFunc mocks the UpsertTargetEntry method.
+	UpsertTargetEntryFunc func(ctx context.Context, te db.TargetEntry, write func() error) error
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// AcquireReconciliationLease holds details about calls to the AcquireReconciliationLease method.
+		AcquireReconciliationLease []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+			// Holder is the holder argument value.
+			Holder string
+			// Now is the now argument value.
+			Now time.Time
+			// ExpiresAt is the expiresAt argument value.
+			ExpiresAt time.Time
+		}
+		// CreateBatchEntry holds details about calls to the CreateBatchEntry method.
+		CreateBatchEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Be is the be argument value.
+			Be db.BatchEntry
+		}
 		// CreateProjectEntry holds details about calls to the CreateProjectEntry method.
 		CreateProjectEntry []struct {
 			// Ctx is the ctx argument value.
@@ -98,6 +222,17 @@ type DBClientMock struct {
 			// Project is the project argument value.
 			Project string
 		}
+		// DeleteTargetEntry holds details about calls to the DeleteTargetEntry method.
+		DeleteTargetEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Project is the project argument value.
+			Project string
+			// Target is the target argument value.
+			Target string
+			// Remove is the remove argument value.
+			Remove func() error
+		}
 		// DeleteTokenEntry holds details about calls to the DeleteTokenEntry method.
 		DeleteTokenEntry []struct {
 			// Ctx is the ctx argument value.
@@ -110,6 +245,36 @@ type DBClientMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// InterruptBatchEntries holds details about calls to the InterruptBatchEntries method.
+		InterruptBatchEntries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UpdatedBefore is the updatedBefore argument value.
+			UpdatedBefore time.Time
+		}
+		// ListProjectEntries holds details about calls to the ListProjectEntries method.
+		ListProjectEntries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter db.ProjectFilter
+		}
+		// ListTargetEntries holds details about calls to the ListTargetEntries method.
+		ListTargetEntries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Project is the project argument value.
+			Project string
+			// Filter is the filter argument value.
+			Filter db.TargetFilter
+		}
+		// ListRotatedTokenEntries holds details about calls to the ListRotatedTokenEntries method.
+		ListRotatedTokenEntries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ExpiresAfter is the expiresAfter argument value.
+			ExpiresAfter time.Time
+		}
 		// ListTokenEntries holds details about calls to the ListTokenEntries method.
 		ListTokenEntries []struct {
 			// Ctx is the ctx argument value.
@@ -117,6 +282,15 @@ type DBClientMock struct {
 			// Project is the project argument value.
 			Project string
 		}
+		// ReadBatchEntry holds details about calls to the ReadBatchEntry method.
+		ReadBatchEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Project is the project argument value.
+			Project string
+			// BatchID is the batchID argument value.
+			BatchID string
+		}
 		// ReadProjectEntry holds details about calls to the ReadProjectEntry method.
 		ReadProjectEntry []struct {
 			// Ctx is the ctx argument value.
@@ -124,6 +298,22 @@ type DBClientMock struct {
 			// Project is the project argument value.
 			Project string
 		}
+		// ReadReconciliationEntry holds details about calls to the ReadReconciliationEntry method.
+		ReadReconciliationEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+		}
+		// ReadTargetEntry holds details about calls to the ReadTargetEntry method.
+		ReadTargetEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Project is the project argument value.
+			Project string
+			// Target is the target argument value.
+			Target string
+		}
 		// ReadTokenEntry holds details about calls to the ReadTokenEntry method.
 		ReadTokenEntry []struct {
 			// Ctx is the ctx argument value.
@@ -131,15 +321,175 @@ type DBClientMock struct {
 			// Token is the token argument value.
 			Token string
 		}
+		// RotateTokenEntry holds details about calls to the RotateTokenEntry method.
+		RotateTokenEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// OldToken is the oldToken argument value.
+			OldToken string
+			// Token is the token argument value.
+			Token types.Token
+			// OldExpiresAt is the oldExpiresAt argument value.
+			OldExpiresAt string
+			// Revoke is the revoke argument value.
+			Revoke func() error
+		}
+		// Stats holds details about calls to the Stats method.
+		Stats []struct {
+		}
+		// UpdateBatchEntry holds details about calls to the UpdateBatchEntry method.
+		UpdateBatchEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Be is the be argument value.
+			Be db.BatchEntry
+		}
+		// UpdateProjectEntry holds details about calls to the UpdateProjectEntry method.
+		UpdateProjectEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Pe is the pe argument value.
+			Pe db.ProjectEntry
+		}
+		// UpdateProjectGitCredentials holds details about calls to the UpdateProjectGitCredentials method.
+		UpdateProjectGitCredentials []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Project is the project argument value.
+			Project string
+			// Ref is the ref argument value.
+			Ref string
+		}
+		// UpdateReconciliationReport holds details about calls to the UpdateReconciliationReport method.
+		UpdateReconciliationReport []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+			// Holder is the holder argument value.
+			Holder string
+			// Report is the report argument value.
+			Report string
+		}
+		// UpsertTargetEntry holds details about calls to the UpsertTargetEntry method.
+		UpsertTargetEntry []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Te is the te argument value.
+			Te db.TargetEntry
+			// Write is the write argument value.
+			Write func() error
+		}
 	}
-	lockCreateProjectEntry sync.RWMutex
-	lockCreateTokenEntry   sync.RWMutex
-	lockDeleteProjectEntry sync.RWMutex
-	lockDeleteTokenEntry   sync.RWMutex
-	lockHealth             sync.RWMutex
-	lockListTokenEntries   sync.RWMutex
-	lockReadProjectEntry   sync.RWMutex
-	lockReadTokenEntry     sync.RWMutex
+	lockAcquireReconciliationLease  sync.RWMutex
+	lockCreateBatchEntry            sync.RWMutex
+	lockCreateProjectEntry          sync.RWMutex
+	lockCreateTokenEntry            sync.RWMutex
+	lockDeleteProjectEntry          sync.RWMutex
+	lockDeleteTargetEntry           sync.RWMutex
+	lockDeleteTokenEntry            sync.RWMutex
+	lockHealth                      sync.RWMutex
+	lockInterruptBatchEntries       sync.RWMutex
+	lockListProjectEntries          sync.RWMutex
+	lockListTargetEntries           sync.RWMutex
+	lockListRotatedTokenEntries     sync.RWMutex
+	lockListTokenEntries            sync.RWMutex
+	lockReadBatchEntry              sync.RWMutex
+	lockReadProjectEntry            sync.RWMutex
+	lockReadReconciliationEntry     sync.RWMutex
+	lockReadTargetEntry             sync.RWMutex
+	lockReadTokenEntry              sync.RWMutex
+	lockRotateTokenEntry            sync.RWMutex
+	lockStats                       sync.RWMutex
+	lockUpdateBatchEntry            sync.RWMutex
+	lockUpdateProjectEntry          sync.RWMutex
+	lockUpdateProjectGitCredentials sync.RWMutex
+	lockUpdateReconciliationReport  sync.RWMutex
+	lockUpsertTargetEntry           sync.RWMutex
+}
+
+// AcquireReconciliationLease calls AcquireReconciliationLeaseFunc.
+func (mock *DBClientMock) AcquireReconciliationLease(ctx context.Context, name string, holder string, now time.Time, expiresAt time.Time) (bool, error) {
+	if mock.AcquireReconciliationLeaseFunc == nil {
+		panic("DBClientMock.AcquireReconciliationLeaseFunc: method is nil but Client.AcquireReconciliationLease was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Name      string
+		Holder    string
+		Now       time.Time
+		ExpiresAt time.Time
+	}{
+		Ctx:       ctx,
+		Name:      name,
+		Holder:    holder,
+		Now:       now,
+		ExpiresAt: expiresAt,
+	}
+	mock.lockAcquireReconciliationLease.Lock()
+	mock.calls.AcquireReconciliationLease = append(mock.calls.AcquireReconciliationLease, callInfo)
+	mock.lockAcquireReconciliationLease.Unlock()
+	return mock.AcquireReconciliationLeaseFunc(ctx, name, holder, now, expiresAt)
+}
+
+// AcquireReconciliationLeaseCalls gets all the calls that were made to AcquireReconciliationLease.
+// Check the length with:
+//
+//	len(mockedClient.AcquireReconciliationLeaseCalls())
+func (mock *DBClientMock) AcquireReconciliationLeaseCalls() []struct {
+	Ctx       context.Context
+	Name      string
+	Holder    string
+	Now       time.Time
+	ExpiresAt time.Time
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Name      string
+		Holder    string
+		Now       time.Time
+		ExpiresAt time.Time
+	}
+	mock.lockAcquireReconciliationLease.RLock()
+	calls = mock.calls.AcquireReconciliationLease
+	mock.lockAcquireReconciliationLease.RUnlock()
+	return calls
+}
+
+// CreateBatchEntry calls CreateBatchEntryFunc.
+func (mock *DBClientMock) CreateBatchEntry(ctx context.Context, be db.BatchEntry) error {
+	if mock.CreateBatchEntryFunc == nil {
+		panic("DBClientMock.CreateBatchEntryFunc: method is nil but Client.CreateBatchEntry was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Be  db.BatchEntry
+	}{
+		Ctx: ctx,
+		Be:  be,
+	}
+	mock.lockCreateBatchEntry.Lock()
+	mock.calls.CreateBatchEntry = append(mock.calls.CreateBatchEntry, callInfo)
+	mock.lockCreateBatchEntry.Unlock()
+	return mock.CreateBatchEntryFunc(ctx, be)
+}
+
+// CreateBatchEntryCalls gets all the calls that were made to CreateBatchEntry.
+// Check the length with:
+//
+//	len(mockedClient.CreateBatchEntryCalls())
+func (mock *DBClientMock) CreateBatchEntryCalls() []struct {
+	Ctx context.Context
+	Be  db.BatchEntry
+} {
+	var calls []struct {
+		Ctx context.Context
+		Be  db.BatchEntry
+	}
+	mock.lockCreateBatchEntry.RLock()
+	calls = mock.calls.CreateBatchEntry
+	mock.lockCreateBatchEntry.RUnlock()
+	return calls
 }
 
 // CreateProjectEntry calls CreateProjectEntryFunc.
@@ -250,6 +600,50 @@ func (mock *DBClientMock) DeleteProjectEntryCalls() []struct {
 	return calls
 }
 
+// DeleteTargetEntry calls DeleteTargetEntryFunc.
+func (mock *DBClientMock) DeleteTargetEntry(ctx context.Context, project string, target string, remove func() error) error {
+	if mock.DeleteTargetEntryFunc == nil {
+		panic("DBClientMock.DeleteTargetEntryFunc: method is nil but Client.DeleteTargetEntry was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Project string
+		Target  string
+		Remove  func() error
+	}{
+		Ctx:     ctx,
+		Project: project,
+		Target:  target,
+		Remove:  remove,
+	}
+	mock.lockDeleteTargetEntry.Lock()
+	mock.calls.DeleteTargetEntry = append(mock.calls.DeleteTargetEntry, callInfo)
+	mock.lockDeleteTargetEntry.Unlock()
+	return mock.DeleteTargetEntryFunc(ctx, project, target, remove)
+}
+
+// DeleteTargetEntryCalls gets all the calls that were made to DeleteTargetEntry.
+// Check the length with:
+//
+//	len(mockedClient.DeleteTargetEntryCalls())
+func (mock *DBClientMock) DeleteTargetEntryCalls() []struct {
+	Ctx     context.Context
+	Project string
+	Target  string
+	Remove  func() error
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Project string
+		Target  string
+		Remove  func() error
+	}
+	mock.lockDeleteTargetEntry.RLock()
+	calls = mock.calls.DeleteTargetEntry
+	mock.lockDeleteTargetEntry.RUnlock()
+	return calls
+}
+
 // DeleteTokenEntry calls DeleteTokenEntryFunc.
 func (mock *DBClientMock) DeleteTokenEntry(ctx context.Context, token string) error {
 	if mock.DeleteTokenEntryFunc == nil {
@@ -318,6 +712,154 @@ func (mock *DBClientMock) HealthCalls() []struct {
 	return calls
 }
 
+// InterruptBatchEntries calls InterruptBatchEntriesFunc.
+func (mock *DBClientMock) InterruptBatchEntries(ctx context.Context, updatedBefore time.Time) ([]string, error) {
+	if mock.InterruptBatchEntriesFunc == nil {
+		panic("DBClientMock.InterruptBatchEntriesFunc: method is nil but Client.InterruptBatchEntries was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		UpdatedBefore time.Time
+	}{
+		Ctx:           ctx,
+		UpdatedBefore: updatedBefore,
+	}
+	mock.lockInterruptBatchEntries.Lock()
+	mock.calls.InterruptBatchEntries = append(mock.calls.InterruptBatchEntries, callInfo)
+	mock.lockInterruptBatchEntries.Unlock()
+	return mock.InterruptBatchEntriesFunc(ctx, updatedBefore)
+}
+
+// InterruptBatchEntriesCalls gets all the calls that were made to InterruptBatchEntries.
+// Check the length with:
+//
+//	len(mockedClient.InterruptBatchEntriesCalls())
+func (mock *DBClientMock) InterruptBatchEntriesCalls() []struct {
+	Ctx           context.Context
+	UpdatedBefore time.Time
+} {
+	var calls []struct {
+		Ctx           context.Context
+		UpdatedBefore time.Time
+	}
+	mock.lockInterruptBatchEntries.RLock()
+	calls = mock.calls.InterruptBatchEntries
+	mock.lockInterruptBatchEntries.RUnlock()
+	return calls
+}
+
+// ListProjectEntries calls ListProjectEntriesFunc.
+func (mock *DBClientMock) ListProjectEntries(ctx context.Context, filter db.ProjectFilter) ([]db.ProjectEntry, uint64, error) {
+	if mock.ListProjectEntriesFunc == nil {
+		panic("DBClientMock.ListProjectEntriesFunc: method is nil but Client.ListProjectEntries was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter db.ProjectFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockListProjectEntries.Lock()
+	mock.calls.ListProjectEntries = append(mock.calls.ListProjectEntries, callInfo)
+	mock.lockListProjectEntries.Unlock()
+	return mock.ListProjectEntriesFunc(ctx, filter)
+}
+
+// ListProjectEntriesCalls gets all the calls that were made to ListProjectEntries.
+// Check the length with:
+//
+//	len(mockedClient.ListProjectEntriesCalls())
+func (mock *DBClientMock) ListProjectEntriesCalls() []struct {
+	Ctx    context.Context
+	Filter db.ProjectFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter db.ProjectFilter
+	}
+	mock.lockListProjectEntries.RLock()
+	calls = mock.calls.ListProjectEntries
+	mock.lockListProjectEntries.RUnlock()
+	return calls
+}
+
+// ListTargetEntries calls ListTargetEntriesFunc.
+func (mock *DBClientMock) ListTargetEntries(ctx context.Context, project string, filter db.TargetFilter) ([]db.TargetEntry, error) {
+	if mock.ListTargetEntriesFunc == nil {
+		panic("DBClientMock.ListTargetEntriesFunc: method is nil but Client.ListTargetEntries was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Project string
+		Filter  db.TargetFilter
+	}{
+		Ctx:     ctx,
+		Project: project,
+		Filter:  filter,
+	}
+	mock.lockListTargetEntries.Lock()
+	mock.calls.ListTargetEntries = append(mock.calls.ListTargetEntries, callInfo)
+	mock.lockListTargetEntries.Unlock()
+	return mock.ListTargetEntriesFunc(ctx, project, filter)
+}
+
+// ListTargetEntriesCalls gets all the calls that were made to ListTargetEntries.
+// Check the length with:
+//
+//	len(mockedClient.ListTargetEntriesCalls())
+func (mock *DBClientMock) ListTargetEntriesCalls() []struct {
+	Ctx     context.Context
+	Project string
+	Filter  db.TargetFilter
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Project string
+		Filter  db.TargetFilter
+	}
+	mock.lockListTargetEntries.RLock()
+	calls = mock.calls.ListTargetEntries
+	mock.lockListTargetEntries.RUnlock()
+	return calls
+}
+
+// ListRotatedTokenEntries calls ListRotatedTokenEntriesFunc.
+func (mock *DBClientMock) ListRotatedTokenEntries(ctx context.Context, expiresAfter time.Time) ([]db.TokenEntry, error) {
+	if mock.ListRotatedTokenEntriesFunc == nil {
+		panic("DBClientMock.ListRotatedTokenEntriesFunc: method is nil but Client.ListRotatedTokenEntries was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		ExpiresAfter time.Time
+	}{
+		Ctx:          ctx,
+		ExpiresAfter: expiresAfter,
+	}
+	mock.lockListRotatedTokenEntries.Lock()
+	mock.calls.ListRotatedTokenEntries = append(mock.calls.ListRotatedTokenEntries, callInfo)
+	mock.lockListRotatedTokenEntries.Unlock()
+	return mock.ListRotatedTokenEntriesFunc(ctx, expiresAfter)
+}
+
+// ListRotatedTokenEntriesCalls gets all the calls that were made to ListRotatedTokenEntries.
+// Check the length with:
+//
+//	len(mockedClient.ListRotatedTokenEntriesCalls())
+func (mock *DBClientMock) ListRotatedTokenEntriesCalls() []struct {
+	Ctx          context.Context
+	ExpiresAfter time.Time
+} {
+	var calls []struct {
+		Ctx          context.Context
+		ExpiresAfter time.Time
+	}
+	mock.lockListRotatedTokenEntries.RLock()
+	calls = mock.calls.ListRotatedTokenEntries
+	mock.lockListRotatedTokenEntries.RUnlock()
+	return calls
+}
+
 // ListTokenEntries calls ListTokenEntriesFunc.
 func (mock *DBClientMock) ListTokenEntries(ctx context.Context, project string) ([]db.TokenEntry, error) {
 	if mock.ListTokenEntriesFunc == nil {
@@ -354,6 +896,46 @@ func (mock *DBClientMock) ListTokenEntriesCalls() []struct {
 	return calls
 }
 
+// ReadBatchEntry calls ReadBatchEntryFunc.
+func (mock *DBClientMock) ReadBatchEntry(ctx context.Context, project string, batchID string) (db.BatchEntry, error) {
+	if mock.ReadBatchEntryFunc == nil {
+		panic("DBClientMock.ReadBatchEntryFunc: method is nil but Client.ReadBatchEntry was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Project string
+		BatchID string
+	}{
+		Ctx:     ctx,
+		Project: project,
+		BatchID: batchID,
+	}
+	mock.lockReadBatchEntry.Lock()
+	mock.calls.ReadBatchEntry = append(mock.calls.ReadBatchEntry, callInfo)
+	mock.lockReadBatchEntry.Unlock()
+	return mock.ReadBatchEntryFunc(ctx, project, batchID)
+}
+
+// ReadBatchEntryCalls gets all the calls that were made to ReadBatchEntry.
+// Check the length with:
+//
+//	len(mockedClient.ReadBatchEntryCalls())
+func (mock *DBClientMock) ReadBatchEntryCalls() []struct {
+	Ctx     context.Context
+	Project string
+	BatchID string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Project string
+		BatchID string
+	}
+	mock.lockReadBatchEntry.RLock()
+	calls = mock.calls.ReadBatchEntry
+	mock.lockReadBatchEntry.RUnlock()
+	return calls
+}
+
 // ReadProjectEntry calls ReadProjectEntryFunc.
 func (mock *DBClientMock) ReadProjectEntry(ctx context.Context, project string) (db.ProjectEntry, error) {
 	if mock.ReadProjectEntryFunc == nil {
@@ -390,6 +972,82 @@ func (mock *DBClientMock) ReadProjectEntryCalls() []struct {
 	return calls
 }
 
+// ReadReconciliationEntry calls ReadReconciliationEntryFunc.
+func (mock *DBClientMock) ReadReconciliationEntry(ctx context.Context, name string) (db.ReconciliationEntry, error) {
+	if mock.ReadReconciliationEntryFunc == nil {
+		panic("DBClientMock.ReadReconciliationEntryFunc: method is nil but Client.ReadReconciliationEntry was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Name string
+	}{
+		Ctx:  ctx,
+		Name: name,
+	}
+	mock.lockReadReconciliationEntry.Lock()
+	mock.calls.ReadReconciliationEntry = append(mock.calls.ReadReconciliationEntry, callInfo)
+	mock.lockReadReconciliationEntry.Unlock()
+	return mock.ReadReconciliationEntryFunc(ctx, name)
+}
+
+// ReadReconciliationEntryCalls gets all the calls that were made to ReadReconciliationEntry.
+// Check the length with:
+//
+//	len(mockedClient.ReadReconciliationEntryCalls())
+func (mock *DBClientMock) ReadReconciliationEntryCalls() []struct {
+	Ctx  context.Context
+	Name string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Name string
+	}
+	mock.lockReadReconciliationEntry.RLock()
+	calls = mock.calls.ReadReconciliationEntry
+	mock.lockReadReconciliationEntry.RUnlock()
+	return calls
+}
+
+// ReadTargetEntry calls ReadTargetEntryFunc.
+func (mock *DBClientMock) ReadTargetEntry(ctx context.Context, project string, target string) (db.TargetEntry, error) {
+	if mock.ReadTargetEntryFunc == nil {
+		panic("DBClientMock.ReadTargetEntryFunc: method is nil but Client.ReadTargetEntry was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Project string
+		Target  string
+	}{
+		Ctx:     ctx,
+		Project: project,
+		Target:  target,
+	}
+	mock.lockReadTargetEntry.Lock()
+	mock.calls.ReadTargetEntry = append(mock.calls.ReadTargetEntry, callInfo)
+	mock.lockReadTargetEntry.Unlock()
+	return mock.ReadTargetEntryFunc(ctx, project, target)
+}
+
+// ReadTargetEntryCalls gets all the calls that were made to ReadTargetEntry.
+// Check the length with:
+//
+//	len(mockedClient.ReadTargetEntryCalls())
+func (mock *DBClientMock) ReadTargetEntryCalls() []struct {
+	Ctx     context.Context
+	Project string
+	Target  string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Project string
+		Target  string
+	}
+	mock.lockReadTargetEntry.RLock()
+	calls = mock.calls.ReadTargetEntry
+	mock.lockReadTargetEntry.RUnlock()
+	return calls
+}
+
 // ReadTokenEntry calls ReadTokenEntryFunc.
 func (mock *DBClientMock) ReadTokenEntry(ctx context.Context, token string) (db.TokenEntry, error) {
 	if mock.ReadTokenEntryFunc == nil {
@@ -425,3 +1083,274 @@ func (mock *DBClientMock) ReadTokenEntryCalls() []struct {
 	mock.lockReadTokenEntry.RUnlock()
 	return calls
 }
+
+// RotateTokenEntry calls RotateTokenEntryFunc.
+func (mock *DBClientMock) RotateTokenEntry(ctx context.Context, oldToken string, token types.Token, oldExpiresAt string, revoke func() error) error {
+	if mock.RotateTokenEntryFunc == nil {
+		panic("DBClientMock.RotateTokenEntryFunc: method is nil but Client.RotateTokenEntry was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		OldToken     string
+		Token        types.Token
+		OldExpiresAt string
+		Revoke       func() error
+	}{
+		Ctx:          ctx,
+		OldToken:     oldToken,
+		Token:        token,
+		OldExpiresAt: oldExpiresAt,
+		Revoke:       revoke,
+	}
+	mock.lockRotateTokenEntry.Lock()
+	mock.calls.RotateTokenEntry = append(mock.calls.RotateTokenEntry, callInfo)
+	mock.lockRotateTokenEntry.Unlock()
+	return mock.RotateTokenEntryFunc(ctx, oldToken, token, oldExpiresAt, revoke)
+}
+
+// RotateTokenEntryCalls gets all the calls that were made to RotateTokenEntry.
+// Check the length with:
+//
+//	len(mockedClient.RotateTokenEntryCalls())
+func (mock *DBClientMock) RotateTokenEntryCalls() []struct {
+	Ctx          context.Context
+	OldToken     string
+	Token        types.Token
+	OldExpiresAt string
+	Revoke       func() error
+} {
+	var calls []struct {
+		Ctx          context.Context
+		OldToken     string
+		Token        types.Token
+		OldExpiresAt string
+		Revoke       func() error
+	}
+	mock.lockRotateTokenEntry.RLock()
+	calls = mock.calls.RotateTokenEntry
+	mock.lockRotateTokenEntry.RUnlock()
+	return calls
+}
+
+// Stats calls StatsFunc.
+func (mock *DBClientMock) Stats() sql.DBStats {
+	if mock.StatsFunc == nil {
+		panic("DBClientMock.StatsFunc: method is nil but Client.Stats was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockStats.Lock()
+	mock.calls.Stats = append(mock.calls.Stats, callInfo)
+	mock.lockStats.Unlock()
+	return mock.StatsFunc()
+}
+
+// StatsCalls gets all the calls that were made to Stats.
+// Check the length with:
+//
+//	len(mockedClient.StatsCalls())
+func (mock *DBClientMock) StatsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockStats.RLock()
+	calls = mock.calls.Stats
+	mock.lockStats.RUnlock()
+	return calls
+}
+
+// UpdateBatchEntry calls UpdateBatchEntryFunc.
+func (mock *DBClientMock) UpdateBatchEntry(ctx context.Context, be db.BatchEntry) error {
+	if mock.UpdateBatchEntryFunc == nil {
+		panic("DBClientMock.UpdateBatchEntryFunc: method is nil but Client.UpdateBatchEntry was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Be  db.BatchEntry
+	}{
+		Ctx: ctx,
+		Be:  be,
+	}
+	mock.lockUpdateBatchEntry.Lock()
+	mock.calls.UpdateBatchEntry = append(mock.calls.UpdateBatchEntry, callInfo)
+	mock.lockUpdateBatchEntry.Unlock()
+	return mock.UpdateBatchEntryFunc(ctx, be)
+}
+
+// UpdateBatchEntryCalls gets all the calls that were made to UpdateBatchEntry.
+// Check the length with:
+//
+//	len(mockedClient.UpdateBatchEntryCalls())
+func (mock *DBClientMock) UpdateBatchEntryCalls() []struct {
+	Ctx context.Context
+	Be  db.BatchEntry
+} {
+	var calls []struct {
+		Ctx context.Context
+		Be  db.BatchEntry
+	}
+	mock.lockUpdateBatchEntry.RLock()
+	calls = mock.calls.UpdateBatchEntry
+	mock.lockUpdateBatchEntry.RUnlock()
+	return calls
+}
+
+// UpdateProjectEntry calls UpdateProjectEntryFunc.
+func (mock *DBClientMock) UpdateProjectEntry(ctx context.Context, pe db.ProjectEntry) error {
+	if mock.UpdateProjectEntryFunc == nil {
+		panic("DBClientMock.UpdateProjectEntryFunc: method is nil but Client.UpdateProjectEntry was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Pe  db.ProjectEntry
+	}{
+		Ctx: ctx,
+		Pe:  pe,
+	}
+	mock.lockUpdateProjectEntry.Lock()
+	mock.calls.UpdateProjectEntry = append(mock.calls.UpdateProjectEntry, callInfo)
+	mock.lockUpdateProjectEntry.Unlock()
+	return mock.UpdateProjectEntryFunc(ctx, pe)
+}
+
+// UpdateProjectEntryCalls gets all the calls that were made to UpdateProjectEntry.
+// Check the length with:
+//
+//	len(mockedClient.UpdateProjectEntryCalls())
+func (mock *DBClientMock) UpdateProjectEntryCalls() []struct {
+	Ctx context.Context
+	Pe  db.ProjectEntry
+} {
+	var calls []struct {
+		Ctx context.Context
+		Pe  db.ProjectEntry
+	}
+	mock.lockUpdateProjectEntry.RLock()
+	calls = mock.calls.UpdateProjectEntry
+	mock.lockUpdateProjectEntry.RUnlock()
+	return calls
+}
+
+// UpdateProjectGitCredentials calls UpdateProjectGitCredentialsFunc.
+func (mock *DBClientMock) UpdateProjectGitCredentials(ctx context.Context, project string, ref string) error {
+	if mock.UpdateProjectGitCredentialsFunc == nil {
+		panic("DBClientMock.UpdateProjectGitCredentialsFunc: method is nil but Client.UpdateProjectGitCredentials was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Project string
+		Ref     string
+	}{
+		Ctx:     ctx,
+		Project: project,
+		Ref:     ref,
+	}
+	mock.lockUpdateProjectGitCredentials.Lock()
+	mock.calls.UpdateProjectGitCredentials = append(mock.calls.UpdateProjectGitCredentials, callInfo)
+	mock.lockUpdateProjectGitCredentials.Unlock()
+	return mock.UpdateProjectGitCredentialsFunc(ctx, project, ref)
+}
+
+// UpdateProjectGitCredentialsCalls gets all the calls that were made to UpdateProjectGitCredentials.
+// Check the length with:
+//
+//	len(mockedClient.UpdateProjectGitCredentialsCalls())
+func (mock *DBClientMock) UpdateProjectGitCredentialsCalls() []struct {
+	Ctx     context.Context
+	Project string
+	Ref     string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Project string
+		Ref     string
+	}
+	mock.lockUpdateProjectGitCredentials.RLock()
+	calls = mock.calls.UpdateProjectGitCredentials
+	mock.lockUpdateProjectGitCredentials.RUnlock()
+	return calls
+}
+
+// UpdateReconciliationReport calls UpdateReconciliationReportFunc.
+func (mock *DBClientMock) UpdateReconciliationReport(ctx context.Context, name string, holder string, report string) error {
+	if mock.UpdateReconciliationReportFunc == nil {
+		panic("DBClientMock.UpdateReconciliationReportFunc: method is nil but Client.UpdateReconciliationReport was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Name   string
+		Holder string
+		Report string
+	}{
+		Ctx:    ctx,
+		Name:   name,
+		Holder: holder,
+		Report: report,
+	}
+	mock.lockUpdateReconciliationReport.Lock()
+	mock.calls.UpdateReconciliationReport = append(mock.calls.UpdateReconciliationReport, callInfo)
+	mock.lockUpdateReconciliationReport.Unlock()
+	return mock.UpdateReconciliationReportFunc(ctx, name, holder, report)
+}
+
+// UpdateReconciliationReportCalls gets all the calls that were made to UpdateReconciliationReport.
+// Check the length with:
+//
+//	len(mockedClient.UpdateReconciliationReportCalls())
+func (mock *DBClientMock) UpdateReconciliationReportCalls() []struct {
+	Ctx    context.Context
+	Name   string
+	Holder string
+	Report string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Name   string
+		Holder string
+		Report string
+	}
+	mock.lockUpdateReconciliationReport.RLock()
+	calls = mock.calls.UpdateReconciliationReport
+	mock.lockUpdateReconciliationReport.RUnlock()
+	return calls
+}
+
+// UpsertTargetEntry calls UpsertTargetEntryFunc.
+func (mock *DBClientMock) UpsertTargetEntry(ctx context.Context, te db.TargetEntry, write func() error) error {
+	if mock.UpsertTargetEntryFunc == nil {
+		panic("DBClientMock.UpsertTargetEntryFunc: method is nil but Client.UpsertTargetEntry was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Te    db.TargetEntry
+		Write func() error
+	}{
+		Ctx:   ctx,
+		Te:    te,
+		Write: write,
+	}
+	mock.lockUpsertTargetEntry.Lock()
+	mock.calls.UpsertTargetEntry = append(mock.calls.UpsertTargetEntry, callInfo)
+	mock.lockUpsertTargetEntry.Unlock()
+	return mock.UpsertTargetEntryFunc(ctx, te, write)
+}
+
+// UpsertTargetEntryCalls gets all the calls that were made to UpsertTargetEntry.
+// Check the length with:
+//
+//	len(mockedClient.UpsertTargetEntryCalls())
+func (mock *DBClientMock) UpsertTargetEntryCalls() []struct {
+	Ctx   context.Context
+	Te    db.TargetEntry
+	Write func() error
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Te    db.TargetEntry
+		Write func() error
+	}
+	mock.lockUpsertTargetEntry.RLock()
+	calls = mock.calls.UpsertTargetEntry
+	mock.lockUpsertTargetEntry.RUnlock()
+	return calls
+}
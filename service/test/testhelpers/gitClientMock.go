@@ -4,6 +4,7 @@
 package testhelpers
 
 import (
+	"context"
 	"github.com/cello-proj/cello/service/internal/git"
 	"sync"
 )
@@ -14,69 +15,136 @@ var _ git.Client = &GitClientMock{}
 
 // GitClientMock is a mock implementation of git.Client.
 //
-// 	func TestSomethingThatUsesClient(t *testing.T) {
+//	func TestSomethingThatUsesClient(t *testing.T) {
 //
-// 		// make and configure a mocked git.Client
-// 		mockedClient := &GitClientMock{
-// 			GetManifestFileFunc: func(repository string, commitHash string, path string) ([]byte, error) {
-// 				panic("mock out the GetManifestFile method")
-// 			},
-// 		}
+//		// make and configure a mocked git.Client
+//		mockedClient := &GitClientMock{
+//			GetManifestFileFunc: func(ctx context.Context, repo git.Repository, commitHash string, root string, path string) ([]byte, error) {
+//				panic("mock out the GetManifestFile method")
+//			},
+//			ListCachedRepositoriesFunc: func() []git.CachedRepository {
+//				panic("mock out the ListCachedRepositories method")
+//			},
+//			PurgeCachedRepositoryFunc: func(repository string) error {
+//				panic("mock out the PurgeCachedRepository method")
+//			},
+//			ResolveRefFunc: func(ctx context.Context, repo git.Repository, ref string) (string, error) {
+//				panic("mock out the ResolveRef method")
+//			},
+//			VerifyCommitFunc: func(ctx context.Context, repo git.Repository, commitHash string) (git.Signature, error) {
+//				panic("mock out the VerifyCommit method")
+//			},
+//		}
 //
-// 		// use mockedClient in code that requires git.Client
-// 		// and then make assertions.
+//		// use mockedClient in code that requires git.Client
+//		// and then make assertions.
 //
-// 	}
+//	}
 type GitClientMock struct {
 	// GetManifestFileFunc mocks the GetManifestFile method.
-	GetManifestFileFunc func(repository string, commitHash string, path string) ([]byte, error)
+	GetManifestFileFunc func(ctx context.Context, repo git.Repository, commitHash string, root string, path string) ([]byte, error)
+
+	// ListCachedRepositoriesFunc mocks the ListCachedRepositories method.
+	ListCachedRepositoriesFunc func() []git.CachedRepository
+
+	// PurgeCachedRepositoryFunc mocks the PurgeCachedRepository method.
+	PurgeCachedRepositoryFunc func(repository string) error
+
+	// ResolveRefFunc mocks the ResolveRef method.
+	ResolveRefFunc func(ctx context.Context, repo git.Repository, ref string) (string, error)
+
+	// VerifyCommitFunc mocks the VerifyCommit method.
+	VerifyCommitFunc func(ctx context.Context, repo git.Repository, commitHash string) (git.Signature, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
 		// GetManifestFile holds details about calls to the GetManifestFile method.
 		GetManifestFile []struct {
-			// Repository is the repository argument value.
-			Repository string
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Repo is the repo argument value.
+			Repo git.Repository
 			// CommitHash is the commitHash argument value.
 			CommitHash string
+			// Root is the root argument value.
+			Root string
 			// Path is the path argument value.
 			Path string
 		}
+		// ListCachedRepositories holds details about calls to the ListCachedRepositories method.
+		ListCachedRepositories []struct {
+		}
+		// PurgeCachedRepository holds details about calls to the PurgeCachedRepository method.
+		PurgeCachedRepository []struct {
+			// Repository is the repository argument value.
+			Repository string
+		}
+		// ResolveRef holds details about calls to the ResolveRef method.
+		ResolveRef []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Repo is the repo argument value.
+			Repo git.Repository
+			// Ref is the ref argument value.
+			Ref string
+		}
+		// VerifyCommit holds details about calls to the VerifyCommit method.
+		VerifyCommit []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Repo is the repo argument value.
+			Repo git.Repository
+			// CommitHash is the commitHash argument value.
+			CommitHash string
+		}
 	}
-	lockGetManifestFile sync.RWMutex
+	lockGetManifestFile        sync.RWMutex
+	lockListCachedRepositories sync.RWMutex
+	lockPurgeCachedRepository  sync.RWMutex
+	lockResolveRef             sync.RWMutex
+	lockVerifyCommit           sync.RWMutex
 }
 
 // GetManifestFile calls GetManifestFileFunc.
-func (mock *GitClientMock) GetManifestFile(repository string, commitHash string, path string) ([]byte, error) {
+func (mock *GitClientMock) GetManifestFile(ctx context.Context, repo git.Repository, commitHash string, root string, path string) ([]byte, error) {
 	if mock.GetManifestFileFunc == nil {
 		panic("GitClientMock.GetManifestFileFunc: method is nil but Client.GetManifestFile was just called")
 	}
 	callInfo := struct {
-		Repository string
+		Ctx        context.Context
+		Repo       git.Repository
 		CommitHash string
+		Root       string
 		Path       string
 	}{
-		Repository: repository,
+		Ctx:        ctx,
+		Repo:       repo,
 		CommitHash: commitHash,
+		Root:       root,
 		Path:       path,
 	}
 	mock.lockGetManifestFile.Lock()
 	mock.calls.GetManifestFile = append(mock.calls.GetManifestFile, callInfo)
 	mock.lockGetManifestFile.Unlock()
-	return mock.GetManifestFileFunc(repository, commitHash, path)
+	return mock.GetManifestFileFunc(ctx, repo, commitHash, root, path)
 }
 
 // GetManifestFileCalls gets all the calls that were made to GetManifestFile.
 // Check the length with:
-//     len(mockedClient.GetManifestFileCalls())
+//
+//	len(mockedClient.GetManifestFileCalls())
 func (mock *GitClientMock) GetManifestFileCalls() []struct {
-	Repository string
+	Ctx        context.Context
+	Repo       git.Repository
 	CommitHash string
+	Root       string
 	Path       string
 } {
 	var calls []struct {
-		Repository string
+		Ctx        context.Context
+		Repo       git.Repository
 		CommitHash string
+		Root       string
 		Path       string
 	}
 	mock.lockGetManifestFile.RLock()
@@ -84,3 +152,142 @@ func (mock *GitClientMock) GetManifestFileCalls() []struct {
 	mock.lockGetManifestFile.RUnlock()
 	return calls
 }
+
+// ListCachedRepositories calls ListCachedRepositoriesFunc.
+func (mock *GitClientMock) ListCachedRepositories() []git.CachedRepository {
+	if mock.ListCachedRepositoriesFunc == nil {
+		panic("GitClientMock.ListCachedRepositoriesFunc: method is nil but Client.ListCachedRepositories was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListCachedRepositories.Lock()
+	mock.calls.ListCachedRepositories = append(mock.calls.ListCachedRepositories, callInfo)
+	mock.lockListCachedRepositories.Unlock()
+	return mock.ListCachedRepositoriesFunc()
+}
+
+// ListCachedRepositoriesCalls gets all the calls that were made to ListCachedRepositories.
+// Check the length with:
+//
+//	len(mockedClient.ListCachedRepositoriesCalls())
+func (mock *GitClientMock) ListCachedRepositoriesCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListCachedRepositories.RLock()
+	calls = mock.calls.ListCachedRepositories
+	mock.lockListCachedRepositories.RUnlock()
+	return calls
+}
+
+// PurgeCachedRepository calls PurgeCachedRepositoryFunc.
+func (mock *GitClientMock) PurgeCachedRepository(repository string) error {
+	if mock.PurgeCachedRepositoryFunc == nil {
+		panic("GitClientMock.PurgeCachedRepositoryFunc: method is nil but Client.PurgeCachedRepository was just called")
+	}
+	callInfo := struct {
+		Repository string
+	}{
+		Repository: repository,
+	}
+	mock.lockPurgeCachedRepository.Lock()
+	mock.calls.PurgeCachedRepository = append(mock.calls.PurgeCachedRepository, callInfo)
+	mock.lockPurgeCachedRepository.Unlock()
+	return mock.PurgeCachedRepositoryFunc(repository)
+}
+
+// PurgeCachedRepositoryCalls gets all the calls that were made to PurgeCachedRepository.
+// Check the length with:
+//
+//	len(mockedClient.PurgeCachedRepositoryCalls())
+func (mock *GitClientMock) PurgeCachedRepositoryCalls() []struct {
+	Repository string
+} {
+	var calls []struct {
+		Repository string
+	}
+	mock.lockPurgeCachedRepository.RLock()
+	calls = mock.calls.PurgeCachedRepository
+	mock.lockPurgeCachedRepository.RUnlock()
+	return calls
+}
+
+// ResolveRef calls ResolveRefFunc.
+func (mock *GitClientMock) ResolveRef(ctx context.Context, repo git.Repository, ref string) (string, error) {
+	if mock.ResolveRefFunc == nil {
+		panic("GitClientMock.ResolveRefFunc: method is nil but Client.ResolveRef was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Repo git.Repository
+		Ref  string
+	}{
+		Ctx:  ctx,
+		Repo: repo,
+		Ref:  ref,
+	}
+	mock.lockResolveRef.Lock()
+	mock.calls.ResolveRef = append(mock.calls.ResolveRef, callInfo)
+	mock.lockResolveRef.Unlock()
+	return mock.ResolveRefFunc(ctx, repo, ref)
+}
+
+// ResolveRefCalls gets all the calls that were made to ResolveRef.
+// Check the length with:
+//
+//	len(mockedClient.ResolveRefCalls())
+func (mock *GitClientMock) ResolveRefCalls() []struct {
+	Ctx  context.Context
+	Repo git.Repository
+	Ref  string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Repo git.Repository
+		Ref  string
+	}
+	mock.lockResolveRef.RLock()
+	calls = mock.calls.ResolveRef
+	mock.lockResolveRef.RUnlock()
+	return calls
+}
+
+// VerifyCommit calls VerifyCommitFunc.
+func (mock *GitClientMock) VerifyCommit(ctx context.Context, repo git.Repository, commitHash string) (git.Signature, error) {
+	if mock.VerifyCommitFunc == nil {
+		panic("GitClientMock.VerifyCommitFunc: method is nil but Client.VerifyCommit was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		Repo       git.Repository
+		CommitHash string
+	}{
+		Ctx:        ctx,
+		Repo:       repo,
+		CommitHash: commitHash,
+	}
+	mock.lockVerifyCommit.Lock()
+	mock.calls.VerifyCommit = append(mock.calls.VerifyCommit, callInfo)
+	mock.lockVerifyCommit.Unlock()
+	return mock.VerifyCommitFunc(ctx, repo, commitHash)
+}
+
+// VerifyCommitCalls gets all the calls that were made to VerifyCommit.
+// Check the length with:
+//
+//	len(mockedClient.VerifyCommitCalls())
+func (mock *GitClientMock) VerifyCommitCalls() []struct {
+	Ctx        context.Context
+	Repo       git.Repository
+	CommitHash string
+} {
+	var calls []struct {
+		Ctx        context.Context
+		Repo       git.Repository
+		CommitHash string
+	}
+	mock.lockVerifyCommit.RLock()
+	calls = mock.calls.VerifyCommit
+	mock.lockVerifyCommit.RUnlock()
+	return calls
+}